@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func runAdopt(ctx context.Context, args []string) error {
+	f := newCommandFlags("adopt", "--selector selector [-n namespace | -A] [--take-over --domain domain [--url-template template]] [--provider-label key=value] [--generated-by value] [--dry-run]")
+	selector := f.String("selector", "", "the label selector of the ingresses to adopt")
+	allNamespaces := f.Bool("A", false, "adopt the ingresses of all the namespaces")
+	takeOver := f.Bool("take-over", false, "hand the ingresses to the controller, which replaces them with its own on the same host and path")
+	domain := f.String("domain", "", "the domain of the controller, required to keep the hosts of the ingresses taken over")
+	urlTemplate := f.String("url-template", "", "the URL template of the controller, the default one if empty")
+	providerLabel := f.String("provider-label", "", "the provider label of the controller, the legacy one if empty")
+	generatedBy := f.String("generated-by", "", "the generated-by annotation value of the controller, \"exposecontroller\" if empty")
+	dryRun := f.Bool("dry-run", false, "only print the services to expose")
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+	if f.NArg() > 0 {
+		return errors.Errorf("unexpected arguments %v", f.Args())
+	}
+	// adopting all the ingresses of the cluster is rarely meant
+	if *selector == "" {
+		return errors.New("--selector is required")
+	}
+	if *takeOver && *domain == "" {
+		return errors.New("--domain is required with --take-over")
+	}
+	provider, err := exposestrategy.ParseProviderLabel(*providerLabel)
+	if err != nil {
+		return err
+	}
+	provider.GeneratedBy = *generatedBy
+	client, namespace, err := f.client()
+	if err != nil {
+		return err
+	}
+	if *allNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+	changes, err := adoptIngresses(ctx, client, namespace, *selector, provider, *takeOver, *urlTemplate, *domain, *dryRun)
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	return err
+}
+
+// adoption is the URL of a service inferred from the first ingress routing to it
+type adoption struct {
+	ingress *networkingv1.Ingress
+	url     string
+	host    string
+	path    string
+	port    networkingv1.ServiceBackendPort
+}
+
+// adoptIngresses exposes the services backing the ingresses matching the selector with the URLs of the ingresses,
+// with takeOver, the ingresses of a single service are handed to the controller, which replaces them with its own,
+// their host and path are written to the annotations of the service, the host rendered by the URL template and the domain of the controller
+// the changes are returned as "kind namespace/name: change", sorted by object, with dryRun, the changes are only returned
+func adoptIngresses(ctx context.Context, client kubernetes.Interface, namespace, selector string,
+	provider exposestrategy.ProviderLabel, takeOver bool, urlTemplate, domain string, dryRun bool) ([]string, error) {
+	list, err := client.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list ingresses")
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Namespace+"/"+list.Items[i].Name < list.Items[j].Namespace+"/"+list.Items[j].Name
+	})
+	adoptions := map[string]adoption{}
+	var keys []string
+	var single []*networkingv1.Ingress
+	for i := range list.Items {
+		ingress := &list.Items[i]
+		if provider.IsGenerated(ingress.Annotations) {
+			continue
+		}
+		services := ingressServices(ingress)
+		for _, name := range services {
+			key := ingress.Namespace + "/" + name
+			if _, ok := adoptions[key]; ok {
+				continue
+			}
+			// the rules without host give no URL
+			if url, host, path, port := ingressServiceURL(ingress, name); url != "" {
+				adoptions[key] = adoption{ingress: ingress, url: url, host: host, path: path, port: port}
+				keys = append(keys, key)
+			}
+		}
+		if len(services) == 1 {
+			single = append(single, ingress)
+		}
+	}
+	sort.Strings(keys)
+	var changes []string
+	adopted := map[string]*v1.Service{}
+	for _, key := range keys {
+		a := adoptions[key]
+		parts := strings.SplitN(key, "/", 2)
+		svc, err := client.CoreV1().Services(parts[0]).Get(ctx, parts[1], metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			changes = append(changes, fmt.Sprintf("service %s: not found, ingress %s/%s skipped", key, a.ingress.Namespace, a.ingress.Name))
+			continue
+		} else if err != nil {
+			return changes, errors.Wrapf(err, "failed to get service %s", key)
+		}
+		if isExposedService(svc, nil) {
+			changes = append(changes, fmt.Sprintf("service %s: already exposed", key))
+			continue
+		}
+		adopted[key] = svc
+		changes = append(changes, fmt.Sprintf("service %s: exposed at %s from ingress %s/%s", key, a.url, a.ingress.Namespace, a.ingress.Name))
+		if dryRun {
+			continue
+		}
+		annotations := map[string]interface{}{
+			exposestrategy.ExposeAnnotation.Key: exposestrategy.ExposeAnnotation.Value,
+			exposestrategy.ExposeAnnotationKey:  a.url,
+		}
+		if port := backendPortValue(a.port); port != "" {
+			annotations[exposestrategy.ExposePortAnnotationKey] = port
+		}
+		err = patchServiceMetadata(ctx, client, svc.Namespace, svc.Name, nil, annotations)
+		if err != nil {
+			return changes, err
+		}
+	}
+	if !takeOver {
+		return changes, nil
+	}
+	for _, ingress := range single {
+		key := ingress.Namespace + "/" + ingressServices(ingress)[0]
+		svc, ok := adopted[key]
+		if !ok {
+			continue
+		}
+		a := adoptions[key]
+		hostName, err := exposestrategy.ServiceHostName(urlTemplate, svc.Namespace, domain, a.host)
+		if err != nil {
+			changes = append(changes, fmt.Sprintf("ingress %s/%s: not taken over, %v", ingress.Namespace, ingress.Name, err))
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("ingress %s/%s: taken over for service %s", ingress.Namespace, ingress.Name, key))
+		if dryRun {
+			continue
+		}
+		// the controller generates its ingress on the same host and path
+		annotations := map[string]interface{}{"fabric8.io/host.name": hostName}
+		if a.path != "" && a.path != "/" {
+			annotations["fabric8.io/ingress.path"] = a.path
+		}
+		err = patchServiceMetadata(ctx, client, svc.Namespace, svc.Name, nil, annotations)
+		if err != nil {
+			return changes, err
+		}
+		takeOverIngress(ingress, svc, provider)
+		_, err = client.NetworkingV1().Ingresses(ingress.Namespace).Update(ctx, ingress, metav1.UpdateOptions{})
+		if err != nil {
+			return changes, errors.Wrapf(err, "failed to update ingress %s/%s", ingress.Namespace, ingress.Name)
+		}
+	}
+	return changes, nil
+}
+
+// ingressServices returns the names of the services backing the rules of the ingress, in the order of the rules
+func ingressServices(ingress *networkingv1.Ingress) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service != nil && !seen[path.Backend.Service.Name] {
+				seen[path.Backend.Service.Name] = true
+				names = append(names, path.Backend.Service.Name)
+			}
+		}
+	}
+	return names
+}
+
+// ingressServiceURL returns the URL, host and path of the first rule of the ingress routing to the service and the port of its backend,
+// the URL is HTTPS if the ingress has TLS for the host
+func ingressServiceURL(ingress *networkingv1.Ingress, name string) (string, string, string, networkingv1.ServiceBackendPort) {
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil || rule.Host == "" {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service == nil || path.Backend.Service.Name != name {
+				continue
+			}
+			scheme := "http"
+			if ingressHasTLS(ingress, rule.Host) {
+				scheme = "https"
+			}
+			// the paths of the ingresses start with a slash
+			url := scheme + "://" + rule.Host + strings.TrimSuffix(path.Path, "/")
+			return url, rule.Host, path.Path, path.Backend.Service.Port
+		}
+	}
+	return "", "", "", networkingv1.ServiceBackendPort{}
+}
+
+// ingressHasTLS tells if a TLS entry of the ingress covers the host, directly or with a wildcard
+func ingressHasTLS(ingress *networkingv1.Ingress, host string) bool {
+	for _, tls := range ingress.Spec.TLS {
+		for _, tlsHost := range tls.Hosts {
+			if tlsHost == host {
+				return true
+			}
+			if parts := strings.SplitN(host, ".", 2); len(parts) == 2 && tlsHost == "*."+parts[1] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// backendPortValue returns the name or number of the port of the backend, empty if none
+func backendPortValue(port networkingv1.ServiceBackendPort) string {
+	if port.Name != "" {
+		return port.Name
+	}
+	if port.Number != 0 {
+		return fmt.Sprint(port.Number)
+	}
+	return ""
+}
+
+// takeOverIngress marks the ingress as generated by the controller for the service,
+// which then replaces it with its own ingress and garbage collects it with the service
+func takeOverIngress(ingress *networkingv1.Ingress, svc *v1.Service, provider exposestrategy.ProviderLabel) {
+	if ingress.Labels == nil {
+		ingress.Labels = map[string]string{}
+	}
+	if ingress.Annotations == nil {
+		ingress.Annotations = map[string]string{}
+	}
+	label := exposestrategy.LegacyProviderLabel
+	if provider.Key != "" {
+		label = provider
+	}
+	ingress.Labels[label.Key] = label.Value
+	ingress.Annotations[exposestrategy.GeneratedByAnnotationKey] = provider.Marker()
+	ingress.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: exposestrategy.ServiceAPIVersion,
+		Kind:       exposestrategy.ServiceKind,
+		Name:       svc.Name,
+		UID:        svc.UID,
+	}}
+}
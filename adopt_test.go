@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdoptIngresses(t *testing.T) {
+	newIngress := func(name, host string, tls bool, services ...string) *networkingv1.Ingress {
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "dev",
+				Name:            name,
+				Labels:          map[string]string{"app.kubernetes.io/managed-by": "Helm"},
+				ResourceVersion: "1",
+			},
+		}
+		rule := networkingv1.IngressRule{Host: host, IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{}}}
+		for i, svc := range services {
+			path := "/"
+			if i > 0 {
+				path = "/" + svc + "/"
+			}
+			rule.HTTP.Paths = append(rule.HTTP.Paths, networkingv1.HTTPIngressPath{
+				Path: path,
+				Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+					Name: svc,
+					Port: networkingv1.ServiceBackendPort{Name: "http"},
+				}},
+			})
+		}
+		ingress.Spec.Rules = []networkingv1.IngressRule{rule}
+		if tls {
+			ingress.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{"*.example.com"}, SecretName: "tls"}}
+		}
+		return ingress
+	}
+	newService := func(name string, annotations map[string]string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "dev",
+				Name:        name,
+				UID:         types.UID("uid-" + name),
+				Annotations: annotations,
+			},
+			Spec: v1.ServiceSpec{Ports: []v1.ServicePort{{Name: "http", Port: 80}}},
+		}
+	}
+	exposed := newService("exposed", map[string]string{exposestrategy.ExposeAnnotationKey: "https://exposed.example.com"})
+	client := fake.NewSimpleClientset(
+		newIngress("web", "web.example.com", true, "web"),
+		newIngress("shop", "shop.example.org", false, "shop", "api"),
+		newIngress("exposed", "exposed.example.com", true, "exposed"),
+		newIngress("gone", "gone.example.com", true, "gone"),
+		newIngress("docs", "docs.other.net", false, "docs"),
+		newService("web", nil), newService("docs", nil), newService("shop", nil), newService("api", nil), exposed,
+	)
+	ctx := context.Background()
+	expected := []string{
+		"service dev/api: exposed at http://shop.example.org/api from ingress dev/shop",
+		"service dev/docs: exposed at http://docs.other.net from ingress dev/docs",
+		"service dev/exposed: already exposed",
+		"service dev/gone: not found, ingress dev/gone skipped",
+		"service dev/shop: exposed at http://shop.example.org from ingress dev/shop",
+		"service dev/web: exposed at https://web.example.com from ingress dev/web",
+		"ingress dev/docs: not taken over, host docs.other.net does not match the URL template in namespace dev and domain example.com",
+		"ingress dev/web: taken over for service dev/web",
+	}
+
+	changes, err := adoptIngresses(ctx, client, "dev", "app.kubernetes.io/managed-by=Helm", exposestrategy.LegacyProviderLabel, true, "{{.Service}}.{{.Domain}}", "example.com", true)
+	require.NoError(t, err)
+	assert.Equal(t, expected, changes)
+	svc, err := client.CoreV1().Services("dev").Get(ctx, "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, svc.Annotations, "dry run")
+
+	changes, err = adoptIngresses(ctx, client, "dev", "app.kubernetes.io/managed-by=Helm", exposestrategy.LegacyProviderLabel, true, "{{.Service}}.{{.Domain}}", "example.com", false)
+	require.NoError(t, err)
+	assert.Equal(t, expected, changes)
+	svc, err = client.CoreV1().Services("dev").Get(ctx, "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		exposestrategy.ExposeAnnotation.Key:    "true",
+		exposestrategy.ExposeAnnotationKey:     "https://web.example.com",
+		exposestrategy.ExposePortAnnotationKey: "http",
+		"fabric8.io/host.name":                 "web",
+	}, svc.Annotations)
+	web, err := client.NetworkingV1().Ingresses("dev").Get(ctx, "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "fabric8", web.Labels["provider"])
+	assert.Equal(t, "exposecontroller", web.Annotations[exposestrategy.GeneratedByAnnotationKey])
+	require.Len(t, web.OwnerReferences, 1)
+	assert.Equal(t, "web", web.OwnerReferences[0].Name)
+	shop, err := client.NetworkingV1().Ingresses("dev").Get(ctx, "shop", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, shop.OwnerReferences, "the ingresses of several services are not taken over")
+
+	// the controller keeps the host and path of the ingress taken over
+	strategy, err := exposestrategy.NewIngressStrategy(nil, client, &exposestrategy.Config{
+		Exposer:       "ingress",
+		Namespace:     "dev",
+		Domain:        "example.com",
+		URLTemplate:   "{{.Service}}.{{.Domain}}",
+		TLSSecretName: "tls",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	svc.ResourceVersion = "1"
+	require.NoError(t, strategy.Add(svc))
+	web, err = client.NetworkingV1().Ingresses("dev").Get(ctx, "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, web.Spec.Rules, 1)
+	assert.Equal(t, "web.example.com", web.Spec.Rules[0].Host)
+	assert.Empty(t, web.Spec.Rules[0].HTTP.Paths[0].Path, "the whole host like the path /")
+	svc, err = client.CoreV1().Services("dev").Get(ctx, "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://web.example.com", svc.Annotations[exposestrategy.ExposeAnnotationKey])
+
+	changes, err = adoptIngresses(ctx, client, "dev", "app.kubernetes.io/managed-by=Helm", exposestrategy.LegacyProviderLabel, true, "{{.Service}}.{{.Domain}}", "example.com", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"service dev/api: already exposed",
+		"service dev/docs: already exposed",
+		"service dev/exposed: already exposed",
+		"service dev/gone: not found, ingress dev/gone skipped",
+		"service dev/shop: already exposed",
+	}, changes, "nothing left to adopt")
+}
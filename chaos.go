@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// chaos is a developer mode failing the share of the API requests with random errors, to exercise the retries,
+// such as in the soak tests, it is hidden from the usage
+var chaos = flag.Float64("chaos", 0, "share of the API requests failed with random errors, between 0 and 1")
+
+// hiddenFlags are not printed by the usage
+var hiddenFlags = map[string]bool{"chaos": true}
+
+func init() {
+	flag.Usage = usage
+}
+
+// usage prints the defaults of the flags except the hidden ones
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+	visible := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	visible.SetOutput(flag.CommandLine.Output())
+	flag.VisitAll(func(f *flag.Flag) {
+		if !hiddenFlags[f.Name] {
+			visible.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	visible.PrintDefaults()
+}
+
+// chaosTransport answers the share of the requests with the errors of a loaded API server instead of sending them,
+// conflicts for the mutations, throttling, timeouts and internal errors for all, the watches are never failed
+type chaosTransport struct {
+	next http.RoundTripper
+	rate float64
+
+	lock sync.Mutex
+	rng  *rand.Rand
+}
+
+// newChaosWrapper returns the wrapper of the transport of the clients failing the rate of the requests
+func newChaosWrapper(rate float64, seed int64) (func(http.RoundTripper) http.RoundTripper, error) {
+	if rate < 0 || rate > 1 {
+		return nil, errors.Errorf("invalid chaos rate %v, must be between 0 and 1", rate)
+	}
+	rng := rand.New(rand.NewSource(seed))
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &chaosTransport{next: next, rate: rate, rng: rng}
+	}, nil
+}
+
+func (t *chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Query().Get("watch") == "true" {
+		return t.next.RoundTrip(req)
+	}
+	err := t.pick(req)
+	if err == nil {
+		return t.next.RoundTrip(req)
+	}
+	status := err.ErrStatus
+	status.Kind = "Status"
+	status.APIVersion = "v1"
+	body, _ := json.Marshal(status)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status.Code, http.StatusText(int(status.Code))),
+		StatusCode:    int(status.Code),
+		Proto:         req.Proto,
+		ProtoMajor:    req.ProtoMajor,
+		ProtoMinor:    req.ProtoMinor,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// pick returns the error injected for the request, nil to send it
+func (t *chaosTransport) pick(req *http.Request) *apierrors.StatusError {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.rng.Float64() >= t.rate {
+		return nil
+	}
+	resource := schema.GroupResource{Resource: "chaos"}
+	name := path.Base(req.URL.Path)
+	kinds := 3
+	if req.Method != http.MethodGet {
+		kinds = 4
+	}
+	switch t.rng.Intn(kinds) {
+	case 0:
+		return apierrors.NewTooManyRequests("injected throttling", 1)
+	case 1:
+		return apierrors.NewServerTimeout(resource, req.Method, 1)
+	case 2:
+		return apierrors.NewInternalError(errors.New("injected failure"))
+	default:
+		return apierrors.NewConflict(resource, name, errors.New("injected conflict"))
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaosTransport(t *testing.T) {
+	ctx := context.Background()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"apiVersion":"v1","kind":"Service","metadata":{"namespace":"main","name":"web"}}`))
+	}))
+	defer server.Close()
+
+	_, err := newChaosWrapper(1.5, 1)
+	assert.Error(t, err)
+
+	wrapper, err := newChaosWrapper(1, 1)
+	require.NoError(t, err)
+	config := &rest.Config{Host: server.URL, QPS: 1000, Burst: 1000}
+	config.Wrap(wrapper)
+	client, err := kubernetes.NewForConfig(config)
+	require.NoError(t, err)
+	reasons := map[metav1.StatusReason]bool{}
+	for i := 0; i < 50; i++ {
+		_, err = client.CoreV1().Services("main").Get(ctx, "web", metav1.GetOptions{})
+		require.Error(t, err)
+		assert.False(t, apierrors.IsConflict(err), "no conflict on get")
+		reasons[apierrors.ReasonForError(err)] = true
+		_, err = client.CoreV1().Services("main").Update(ctx, &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "web"}}, metav1.UpdateOptions{})
+		require.Error(t, err)
+		reasons[apierrors.ReasonForError(err)] = true
+	}
+	assert.Equal(t, map[metav1.StatusReason]bool{
+		metav1.StatusReasonTooManyRequests: true,
+		metav1.StatusReasonServerTimeout:   true,
+		metav1.StatusReasonInternalError:   true,
+		metav1.StatusReasonConflict:        true,
+	}, reasons)
+	assert.Equal(t, 0, requests)
+
+	wrapper, err = newChaosWrapper(0, 1)
+	require.NoError(t, err)
+	config = &rest.Config{Host: server.URL, QPS: 1000, Burst: 1000}
+	config.Wrap(wrapper)
+	client, err = kubernetes.NewForConfig(config)
+	require.NoError(t, err)
+	svc, err := client.CoreV1().Services("main").Get(ctx, "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "web", svc.Name)
+	assert.Equal(t, 1, requests)
+}
+
+func TestUsage_hiddenFlags(t *testing.T) {
+	var out bytes.Buffer
+	flag.CommandLine.SetOutput(&out)
+	defer flag.CommandLine.SetOutput(nil)
+	usage()
+	assert.Contains(t, out.String(), "-daemon")
+	assert.NotContains(t, out.String(), "-chaos")
+}
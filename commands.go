@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+	"github.com/pkg/errors"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// urlPollInterval is how often the commands check the exposed URL of the service
+	urlPollInterval = 2 * time.Second
+	// urlCheckTimeout is the timeout of the requests checking that the URL answers
+	urlCheckTimeout = 10 * time.Second
+)
+
+// commands are the subcommands run instead of the controller, such as "exposecontroller expose svc/myapp"
+var commands = map[string]func(ctx context.Context, args []string) error{
+	"expose":   runExpose,
+	"unexpose": runUnexpose,
+	"wait":     runWait,
+	"prune":    runPrune,
+
+	"migrate-domain":   runMigrateDomain,
+	"convert-template": runConvertTemplate,
+	"adopt":            runAdopt,
+	"explain":          runExplain,
+}
+
+// runCommand runs the subcommand named by the first argument, false if there is none
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	run, ok := commands[args[0]]
+	if !ok {
+		return false
+	}
+	err := run(context.Background(), args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "exposecontroller %s: %s\n", args[0], err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// commandFlags are the flags common to the subcommands
+type commandFlags struct {
+	*flag.FlagSet
+	kubeConfig string
+	namespace  string
+	timeout    time.Duration
+}
+
+func newCommandFlags(name, usage string) *commandFlags {
+	f := &commandFlags{FlagSet: flag.NewFlagSet(name, flag.ContinueOnError)}
+	f.Usage = func() {
+		fmt.Fprintf(f.Output(), "Usage: exposecontroller %s %s\n", name, usage)
+		f.PrintDefaults()
+	}
+	f.StringVar(&f.kubeConfig, "kube-config", "", "path to Kubernetes config file, the default loading rules of kubectl if empty")
+	f.StringVar(&f.namespace, "n", "", "the namespace of the service, the one of the current context if empty")
+	f.StringVar(&f.namespace, "namespace", "", "the namespace of the service, the one of the current context if empty")
+	f.DurationVar(&f.timeout, "timeout", 2*time.Minute, "how long to wait for the URL of the service")
+	return f
+}
+
+// parse parses the flags placed before or after the service and returns its name
+func (f *commandFlags) parse(args []string) (string, error) {
+	if err := f.Parse(args); err != nil {
+		return "", err
+	}
+	if f.NArg() == 0 {
+		f.Usage()
+		return "", errors.New("missing service")
+	}
+	target := f.Arg(0)
+	if err := f.Parse(f.Args()[1:]); err != nil {
+		return "", err
+	}
+	if f.NArg() > 0 {
+		return "", errors.Errorf("unexpected arguments %v", f.Args())
+	}
+	return parseServiceName(target)
+}
+
+// restConfig loads the config of the cluster, outside of the cluster unlike the controller
+func (f *commandFlags) restConfig() (*rest.Config, string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = f.kubeConfig
+	config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{})
+	namespace := f.namespace
+	if namespace == "" {
+		var err error
+		namespace, _, err = config.Namespace()
+		if err != nil {
+			return nil, "", errors.Wrap(err, "failed to find the current namespace")
+		}
+	}
+	restConfig, err := config.ClientConfig()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to create REST client config")
+	}
+	return restConfig, namespace, nil
+}
+
+// client creates the client of the cluster and returns the namespace of the service
+func (f *commandFlags) client() (kubernetes.Interface, string, error) {
+	restConfig, namespace, err := f.restConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to create client")
+	}
+	return client, namespace, nil
+}
+
+// parseServiceName parses a service given as "svc/name", "service/name" or "name"
+func parseServiceName(target string) (string, error) {
+	name := target
+	if i := strings.Index(target, "/"); i >= 0 {
+		switch target[:i] {
+		case "svc", "service", "services":
+		default:
+			return "", errors.Errorf("invalid service \"%s\", must be svc/<name>", target)
+		}
+		name = target[i+1:]
+	}
+	if name == "" {
+		return "", errors.Errorf("invalid service \"%s\", must be svc/<name>", target)
+	}
+	return name, nil
+}
+
+func runExpose(ctx context.Context, args []string) error {
+	f := newCommandFlags("expose", "svc/<name> [-n namespace] [--port port] [--tls] [--wait] [--timeout duration]")
+	port := f.String("port", "", "the name or number of the port to expose, chosen by the controller if empty")
+	wait := f.Bool("wait", false, "wait for the controller to publish the URL of the service")
+	requireTLS := f.Bool("tls", false, "wait for the service to be exposed in HTTPS, the TLS being configured by the controller")
+	name, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+	client, namespace, err := f.client()
+	if err != nil {
+		return err
+	}
+	err = exposeService(ctx, client, namespace, name, *port)
+	if err != nil {
+		return err
+	}
+	if !*wait && !*requireTLS {
+		fmt.Printf("service %s/%s exposed\n", namespace, name)
+		return nil
+	}
+	url, err := waitForURL(ctx, client, namespace, name, f.timeout)
+	if err != nil {
+		return err
+	}
+	if *requireTLS && !strings.HasPrefix(url, "https://") {
+		return errors.Errorf("service %s/%s is exposed without TLS at %s, check the TLS settings of the controller", namespace, name, url)
+	}
+	fmt.Println(url)
+	return nil
+}
+
+func runUnexpose(ctx context.Context, args []string) error {
+	f := newCommandFlags("unexpose", "svc/<name> [-n namespace] [--wait] [--timeout duration]")
+	wait := f.Bool("wait", false, "wait for the controller to remove the URL of the service")
+	name, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+	client, namespace, err := f.client()
+	if err != nil {
+		return err
+	}
+	err = unexposeService(ctx, client, namespace, name)
+	if err != nil {
+		return err
+	}
+	if *wait {
+		err = waitForURLRemoval(ctx, client, namespace, name, f.timeout)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Printf("service %s/%s unexposed\n", namespace, name)
+	return nil
+}
+
+func runWait(ctx context.Context, args []string) error {
+	f := newCommandFlags("wait", "svc/<name> [-n namespace] [--timeout duration] [--ready] [--insecure-skip-tls-verify]")
+	ready := f.Bool("ready", false, "also wait for the URL to answer HTTP 200")
+	insecure := f.Bool("insecure-skip-tls-verify", false, "do not verify the certificate of the URL, e.g. issued by a staging ACME server")
+	name, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+	client, namespace, err := f.client()
+	if err != nil {
+		return err
+	}
+	// the timeout covers both the URL and its readiness
+	deadline := time.Now().Add(f.timeout)
+	url, err := waitForURL(ctx, client, namespace, name, f.timeout)
+	if err != nil {
+		return err
+	}
+	if *ready {
+		err = waitForHTTP(url, time.Until(deadline), *insecure)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Println(url)
+	return nil
+}
+
+func runConvertTemplate(ctx context.Context, args []string) error {
+	f := flag.NewFlagSet("convert-template", flag.ContinueOnError)
+	f.Usage = func() {
+		fmt.Fprintf(f.Output(), "Usage: exposecontroller convert-template <urltemplate>\n")
+	}
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+	if f.NArg() != 1 {
+		return errors.New("expected the URL template to convert")
+	}
+	converted, err := exposestrategy.ConvertURLTemplate(f.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Println(converted)
+	return nil
+}
+
+// patchServiceMetadata merges the labels and annotations into the service, the nil values removing the keys
+func patchServiceMetadata(ctx context.Context, client kubernetes.Interface, namespace, name string, labels, annotations map[string]interface{}) error {
+	metadata := map[string]interface{}{"annotations": annotations}
+	if labels != nil {
+		metadata["labels"] = labels
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+	if err != nil {
+		return errors.Wrap(err, "failed to create the annotation patch")
+	}
+	_, err = client.CoreV1().Services(namespace).
+		Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to annotate service %s/%s", namespace, name)
+	}
+	return nil
+}
+
+// exposeService sets the annotations exposing the service, and its port if not empty
+func exposeService(ctx context.Context, client kubernetes.Interface, namespace, name, port string) error {
+	annotations := map[string]interface{}{
+		exposestrategy.ExposeAnnotation.Key: exposestrategy.ExposeAnnotation.Value,
+	}
+	if port != "" {
+		annotations[exposestrategy.ExposePortAnnotationKey] = port
+	}
+	return patchServiceMetadata(ctx, client, namespace, name, nil, annotations)
+}
+
+// unexposeService removes the label and the annotations exposing the service, the controller then cleans it
+func unexposeService(ctx context.Context, client kubernetes.Interface, namespace, name string) error {
+	labels := map[string]interface{}{
+		exposestrategy.ExposeLabel.Key: nil,
+	}
+	annotations := map[string]interface{}{
+		exposestrategy.ExposeAnnotation.Key:    nil,
+		exposestrategy.InjectAnnotation.Key:    nil,
+		exposestrategy.ExposePortAnnotationKey: nil,
+	}
+	return patchServiceMetadata(ctx, client, namespace, name, labels, annotations)
+}
+
+// waitForURL waits for the controller to publish the URL of the service
+func waitForURL(ctx context.Context, client kubernetes.Interface, namespace, name string, timeout time.Duration) (string, error) {
+	url := ""
+	err := wait.PollImmediate(urlPollInterval, timeout, func() (bool, error) {
+		svc, err := client.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to get service %s/%s", namespace, name)
+		}
+		url = svc.Annotations[exposestrategy.ExposeAnnotationKey]
+		return url != "", nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return "", errors.Errorf("no URL published for service %s/%s after %v", namespace, name, timeout)
+	}
+	return url, err
+}
+
+// waitForURLRemoval waits for the controller to clean the URL of the service
+func waitForURLRemoval(ctx context.Context, client kubernetes.Interface, namespace, name string, timeout time.Duration) error {
+	err := wait.PollImmediate(urlPollInterval, timeout, func() (bool, error) {
+		svc, err := client.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to get service %s/%s", namespace, name)
+		}
+		_, exposed := svc.Annotations[exposestrategy.ExposeAnnotationKey]
+		return !exposed, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return errors.Errorf("the URL of service %s/%s is still published after %v", namespace, name, timeout)
+	}
+	return err
+}
+
+// waitForHTTP waits for the URL to answer HTTP 200
+func waitForHTTP(url string, timeout time.Duration, insecure bool) error {
+	client := &http.Client{Timeout: urlCheckTimeout}
+	if insecure {
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	var last error
+	err := wait.PollImmediate(urlPollInterval, timeout, func() (bool, error) {
+		res, err := client.Get(url)
+		if err != nil {
+			last = err
+			return false, nil
+		}
+		_ = res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			last = errors.Errorf("status %d", res.StatusCode)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return errors.Wrapf(last, "%s is not ready after %v", url, timeout)
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseServiceName(t *testing.T) {
+	for target, expected := range map[string]string{
+		"svc/myapp":     "myapp",
+		"service/myapp": "myapp",
+		"myapp":         "myapp",
+	} {
+		name, err := parseServiceName(target)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, name)
+	}
+	_, err := parseServiceName("deploy/myapp")
+	assert.EqualError(t, err, `invalid service "deploy/myapp", must be svc/<name>`)
+	_, err = parseServiceName("svc/")
+	assert.Error(t, err)
+
+	f := newCommandFlags("expose", "svc/<name>")
+	wait := f.Bool("wait", false, "")
+	name, err := f.parse([]string{"--wait", "svc/myapp", "-n", "dev", "--timeout", "1m"})
+	require.NoError(t, err)
+	assert.Equal(t, "myapp", name)
+	assert.Equal(t, "dev", f.namespace)
+	assert.True(t, *wait)
+	assert.Equal(t, time.Minute, f.timeout)
+}
+
+func TestExposeService(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "dev",
+			Name:      "myapp",
+			Labels:    map[string]string{"app": "myapp", "expose": "true"},
+		},
+	})
+	ctx := context.Background()
+	require.NoError(t, exposeService(ctx, client, "dev", "myapp", "8080"))
+	svc, err := client.CoreV1().Services("dev").Get(ctx, "myapp", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"fabric8.io/expose":                    "true",
+		exposestrategy.ExposePortAnnotationKey: "8080",
+	}, svc.Annotations)
+
+	svc.Annotations[exposestrategy.ExposeAnnotationKey] = "https://myapp.dev.my-domain.com"
+	_, err = client.CoreV1().Services("dev").Update(ctx, svc, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	url, err := waitForURL(ctx, client, "dev", "myapp", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "https://myapp.dev.my-domain.com", url)
+
+	require.NoError(t, unexposeService(ctx, client, "dev", "myapp"))
+	svc, err = client.CoreV1().Services("dev").Get(ctx, "myapp", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "myapp"}, svc.Labels)
+	assert.Equal(t, map[string]string{
+		exposestrategy.ExposeAnnotationKey: "https://myapp.dev.my-domain.com",
+	}, svc.Annotations, "the controller removes the URL")
+	err = waitForURLRemoval(ctx, client, "dev", "myapp", time.Millisecond)
+	assert.EqualError(t, err, "the URL of service dev/myapp is still published after 1ms")
+}
+
+func TestWaitForHTTP(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(status)
+	}))
+	defer server.Close()
+	assert.EqualError(t, waitForHTTP(server.URL, time.Millisecond, false),
+		server.URL+" is not ready after 1ms: status 503")
+	status = http.StatusOK
+	assert.NoError(t, waitForHTTP(server.URL, time.Second, false))
+}
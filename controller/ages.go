@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/utils/clock"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+const (
+	exposureAgeMetric   = "exposecontroller_service_exposure_age_seconds"
+	reconcileAgeMetric  = "exposecontroller_service_last_reconcile_age_seconds"
+	exposureAgeHelp     = "Seconds since the controller first exposed the service."
+	reconcileAgeHelp    = "Seconds since the last successful reconcile of the exposed service, since its first exposure if none."
+	defaultStrategyName = "auto"
+)
+
+// serviceAge tells when a service was first exposed and last reconciled
+type serviceAge struct {
+	namespace  string
+	name       string
+	exposed    time.Time
+	reconciled time.Time
+}
+
+// serviceAges tracks the exposed services to export their ages by service,
+// a service waiting for its URL for too long has a growing reconcile age
+type serviceAges struct {
+	lock     sync.Mutex
+	strategy string
+	clock    clock.PassiveClock
+	services map[string]*serviceAge
+}
+
+// exposureAges are the ages of the services exposed by the controller
+var exposureAges = &serviceAges{
+	strategy: defaultStrategyName,
+	clock:    clock.RealClock{},
+	services: map[string]*serviceAge{},
+}
+
+// reset forgets the services, for a new controller with the strategy of the config
+func (a *serviceAges) reset(config *Config) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.strategy = strings.ToLower(config.Exposer)
+	if a.strategy == "" {
+		a.strategy = defaultStrategyName
+	}
+	a.clock = config.clock()
+	a.services = map[string]*serviceAge{}
+}
+
+// result records the result of the exposure of the service, reconciled once it has its URL
+// as the strategies succeed while the URL of the ingress or load balancer is pending
+func (a *serviceAges) result(svc *v1.Service, err error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	key := svc.Namespace + "/" + svc.Name
+	age, ok := a.services[key]
+	if !ok {
+		age = &serviceAge{namespace: svc.Namespace, name: svc.Name, exposed: a.clock.Now()}
+		a.services[key] = age
+	}
+	if err == nil && svc.Annotations[exposestrategy.ExposeAnnotationKey] != "" {
+		age.reconciled = a.clock.Now()
+	}
+}
+
+// forget drops the service once unexposed
+func (a *serviceAges) forget(svc *v1.Service) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	delete(a.services, svc.Namespace+"/"+svc.Name)
+}
+
+func (a *serviceAges) write(w io.Writer) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	keys := make([]string, 0, len(a.services))
+	for key := range a.services {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	now := a.clock.Now()
+	for _, metric := range []struct {
+		name string
+		help string
+		age  func(*serviceAge) time.Duration
+	}{{
+		name: exposureAgeMetric,
+		help: exposureAgeHelp,
+		age:  func(age *serviceAge) time.Duration { return now.Sub(age.exposed) },
+	}, {
+		name: reconcileAgeMetric,
+		help: reconcileAgeHelp,
+		age: func(age *serviceAge) time.Duration {
+			if age.reconciled.IsZero() {
+				return now.Sub(age.exposed)
+			}
+			return now.Sub(age.reconciled)
+		},
+	}} {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", metric.name, metric.help, metric.name)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			age := a.services[key]
+			_, err = fmt.Fprintf(w, "%s{namespace=%s,service=%s,strategy=%s} %g\n", metric.name,
+				strconv.Quote(age.namespace), strconv.Quote(age.name), strconv.Quote(a.strategy), metric.age(age).Seconds())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceAges(t *testing.T) {
+	clock := clocktesting.NewFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	ages := &serviceAges{}
+	ages.reset(&Config{Exposer: "Ingress", Clock: clock})
+	newService := func(name string) *v1.Service {
+		return &v1.Service{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "dev",
+			Name:        name,
+			Annotations: map[string]string{exposestrategy.ExposeAnnotationKey: "http://" + name + ".dev.my-domain.com"},
+		}}
+	}
+
+	ages.result(newService("ok"), nil)
+	ages.result(newService("pending"), errors.New("no host"))
+	ages.result(newService("gone"), nil)
+	// the URL of the load balancer is not known yet
+	ages.result(&v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "waiting"}}, nil)
+	clock.Step(time.Minute)
+	ages.result(newService("ok"), nil)
+	ages.result(&v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "waiting"}}, nil)
+	ages.forget(newService("gone"))
+	clock.Step(30 * time.Second)
+
+	var buffer bytes.Buffer
+	require.NoError(t, ages.write(&buffer))
+	assert.Equal(t, `# HELP exposecontroller_service_exposure_age_seconds Seconds since the controller first exposed the service.
+# TYPE exposecontroller_service_exposure_age_seconds gauge
+exposecontroller_service_exposure_age_seconds{namespace="dev",service="ok",strategy="ingress"} 90
+exposecontroller_service_exposure_age_seconds{namespace="dev",service="pending",strategy="ingress"} 90
+exposecontroller_service_exposure_age_seconds{namespace="dev",service="waiting",strategy="ingress"} 90
+# HELP exposecontroller_service_last_reconcile_age_seconds Seconds since the last successful reconcile of the exposed service, since its first exposure if none.
+# TYPE exposecontroller_service_last_reconcile_age_seconds gauge
+exposecontroller_service_last_reconcile_age_seconds{namespace="dev",service="ok",strategy="ingress"} 30
+exposecontroller_service_last_reconcile_age_seconds{namespace="dev",service="pending",strategy="ingress"} 90
+exposecontroller_service_last_reconcile_age_seconds{namespace="dev",service="waiting",strategy="ingress"} 90
+`, buffer.String())
+
+	ages.reset(&Config{Clock: clock})
+	buffer.Reset()
+	require.NoError(t, ages.write(&buffer))
+	assert.NotContains(t, buffer.String(), "service=", "the services are forgotten on reset")
+}
@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	yaml "gopkg.in/yaml.v2"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+const (
+	// backstageCatalogKey is the key of the entities in the catalog config map
+	backstageCatalogKey = "catalog-info.yaml"
+	// BackstageKubernetesIDKey label of a service is the kubernetes-id of its component, the name of the service if empty
+	BackstageKubernetesIDKey = "backstage.io/kubernetes-id"
+	// backstageKubernetesNamespaceKey annotation of a component is the namespace of its service
+	backstageKubernetesNamespaceKey = "backstage.io/kubernetes-namespace"
+)
+
+// backstageEntity is a Backstage component of an exposed service
+type backstageEntity struct {
+	APIVersion string              `yaml:"apiVersion"`
+	Kind       string              `yaml:"kind"`
+	Metadata   backstageEntityMeta `yaml:"metadata"`
+	Spec       backstageEntitySpec `yaml:"spec"`
+}
+
+type backstageEntityMeta struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace"`
+	Annotations map[string]string `yaml:"annotations"`
+	Links       []backstageLink   `yaml:"links"`
+}
+
+type backstageLink struct {
+	URL   string `yaml:"url"`
+	Title string `yaml:"title"`
+}
+
+type backstageEntitySpec struct {
+	Type      string `yaml:"type"`
+	Lifecycle string `yaml:"lifecycle"`
+	Owner     string `yaml:"owner"`
+}
+
+// backstageCatalog writes the Backstage catalog config map of each namespace having exposed services,
+// for the portal ingestion to pick up the live URLs of the environments
+type backstageCatalog struct {
+	ctx    context.Context
+	client kubernetes.Interface
+	config *Config
+	name   string
+	store  cache.Store
+	// exposeValues tell which services of the store request the exposure
+	exposeValues *exposestrategy.ExposeValues
+
+	// publishing serializes the publications, such as of the resyncs and URL changes
+	publishing sync.Mutex
+	// the last catalog written in each namespace
+	written map[string]string
+}
+
+// newBackstageCatalog returns nil without catalog config map name
+func newBackstageCatalog(ctx context.Context, client kubernetes.Interface, config *Config) *backstageCatalog {
+	if config.BackstageCatalog == "" {
+		return nil
+	}
+	return &backstageCatalog{
+		ctx:     ctx,
+		client:  client,
+		config:  config,
+		name:    config.BackstageCatalog,
+		written: map[string]string{},
+	}
+}
+
+// urlChanged publishes the catalogs when the URL of the service changes
+func (c *backstageCatalog) urlChanged(oldSvc, svc *v1.Service) {
+	if c == nil || oldSvc.Annotations[exposestrategy.ExposeAnnotationKey] == svc.Annotations[exposestrategy.ExposeAnnotationKey] &&
+		oldSvc.Annotations[exposestrategy.ExposeURLsAnnotationKey] == svc.Annotations[exposestrategy.ExposeURLsAnnotationKey] {
+		return
+	}
+	c.publish()
+}
+
+// deleted publishes the catalogs when an exposed service is deleted
+func (c *backstageCatalog) deleted(svc *v1.Service) {
+	if c == nil || svc.Annotations[exposestrategy.ExposeAnnotationKey] == "" {
+		return
+	}
+	c.publish()
+}
+
+// entity returns the component of the exposed service
+func (c *backstageCatalog) entity(svc *v1.Service, url string) backstageEntity {
+	id := svc.Labels[BackstageKubernetesIDKey]
+	if id == "" {
+		id = svc.Name
+	}
+	owner := svc.Annotations[exposestrategy.TeamAnnotationKey]
+	if owner == "" {
+		owner = c.config.BackstageOwner
+	}
+	if owner == "" {
+		owner = svc.Namespace
+	}
+	links := []backstageLink{{URL: url, Title: svc.Name}}
+	var urls map[string]string
+	if text := svc.Annotations[exposestrategy.ExposeURLsAnnotationKey]; text != "" {
+		err := json.Unmarshal([]byte(text), &urls)
+		if err != nil {
+			klog.Warningf("Failed to parse annotation \"%s\" of service %s/%s: %v", exposestrategy.ExposeURLsAnnotationKey, svc.Namespace, svc.Name, err)
+		}
+	}
+	ports := make([]string, 0, len(urls))
+	for port := range urls {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	for _, port := range ports {
+		if urls[port] != url {
+			links = append(links, backstageLink{URL: urls[port], Title: svc.Name + " (" + port + ")"})
+		}
+	}
+	return backstageEntity{
+		APIVersion: "backstage.io/v1alpha1",
+		Kind:       "Component",
+		Metadata: backstageEntityMeta{
+			Name:      svc.Name,
+			Namespace: svc.Namespace,
+			Annotations: map[string]string{
+				BackstageKubernetesIDKey:        id,
+				backstageKubernetesNamespaceKey: svc.Namespace,
+			},
+			Links: links,
+		},
+		Spec: backstageEntitySpec{
+			Type:      "service",
+			Lifecycle: "production",
+			Owner:     owner,
+		},
+	}
+}
+
+// build builds the catalogs of the namespaces having services with an URL, as YAML documents sorted by service
+func (c *backstageCatalog) build() map[string]string {
+	entities := map[string][]backstageEntity{}
+	for _, obj := range c.store.List() {
+		svc := obj.(*v1.Service)
+		url := svc.Annotations[exposestrategy.ExposeAnnotationKey]
+		if url == "" || !c.exposeValues.IsRequested(svc) || !isServiceWhitelisted(svc.Name, c.config) {
+			continue
+		}
+		entities[svc.Namespace] = append(entities[svc.Namespace], c.entity(svc, url))
+	}
+	catalogs := map[string]string{}
+	for namespace, list := range entities {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Metadata.Name < list[j].Metadata.Name
+		})
+		documents := make([]string, 0, len(list))
+		for _, entity := range list {
+			data, err := yaml.Marshal(entity)
+			if err != nil {
+				klog.Errorf("Failed to encode the Backstage entity of service %s/%s: %v", namespace, entity.Metadata.Name, err)
+				continue
+			}
+			documents = append(documents, string(data))
+		}
+		catalogs[namespace] = strings.Join(documents, "---\n")
+	}
+	return catalogs
+}
+
+// publish writes the changed catalogs and removes those of the namespaces without exposed service anymore
+func (c *backstageCatalog) publish() {
+	if c == nil || c.store == nil {
+		return
+	}
+	c.publishing.Lock()
+	defer c.publishing.Unlock()
+	catalogs := c.build()
+	namespaces := make([]string, 0, len(catalogs))
+	for namespace := range catalogs {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		if c.written[namespace] == catalogs[namespace] {
+			continue
+		}
+		err := writeGeneratedConfigMap(c.ctx, c.client, c.config, namespace, c.name, "Backstage catalog",
+			map[string]string{backstageCatalogKey: catalogs[namespace]})
+		if err != nil {
+			klog.Errorf("Backstage catalog update failed: %v", err)
+			continue
+		}
+		c.written[namespace] = catalogs[namespace]
+	}
+	for namespace := range c.written {
+		if _, ok := catalogs[namespace]; ok {
+			continue
+		}
+		err := removeGeneratedConfigMap(c.ctx, c.client, c.config, namespace, c.name, "Backstage catalog")
+		if err != nil {
+			klog.Errorf("Backstage catalog removal failed: %v", err)
+			continue
+		}
+		delete(c.written, namespace)
+	}
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackstageCatalog(t *testing.T) {
+	ctx := context.Background()
+	newService := func(name, url string) *v1.Service {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "main",
+				Name:      name,
+				Annotations: map[string]string{
+					exposestrategy.ExposeAnnotation.Key: exposestrategy.ExposeAnnotation.Value,
+				},
+			},
+		}
+		if url != "" {
+			svc.Annotations[exposestrategy.ExposeAnnotationKey] = url
+		}
+		return svc
+	}
+	web := newService("web", "https://web.main.my-domain.com")
+	web.Labels = map[string]string{BackstageKubernetesIDKey: "storefront"}
+	web.Annotations[exposestrategy.TeamAnnotationKey] = "shop"
+	web.Annotations[exposestrategy.ExposeURLsAnnotationKey] = `{"admin":"https://web.main.my-domain.com/admin","http":"https://web.main.my-domain.com"}`
+	api := newService("api", "https://api.main.my-domain.com")
+	pending := newService("pending", "")
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, svc := range []*v1.Service{web, api, pending} {
+		require.NoError(t, store.Add(svc))
+	}
+	client := fake.NewSimpleClientset()
+
+	assert.Nil(t, newBackstageCatalog(ctx, client, &Config{}), "no catalog by default")
+	catalog := newBackstageCatalog(ctx, client, &Config{BackstageCatalog: "backstage-catalog", BackstageOwner: "platform"})
+	catalog.store = store
+	catalog.publish()
+
+	cm, err := client.CoreV1().ConfigMaps("main").Get(ctx, "backstage-catalog", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "exposecontroller", cm.Annotations["fabric8.io/generated-by"])
+	assert.Equal(t, map[string]string{backstageCatalogKey: `apiVersion: backstage.io/v1alpha1
+kind: Component
+metadata:
+  name: api
+  namespace: main
+  annotations:
+    backstage.io/kubernetes-id: api
+    backstage.io/kubernetes-namespace: main
+  links:
+  - url: https://api.main.my-domain.com
+    title: api
+spec:
+  type: service
+  lifecycle: production
+  owner: platform
+---
+apiVersion: backstage.io/v1alpha1
+kind: Component
+metadata:
+  name: web
+  namespace: main
+  annotations:
+    backstage.io/kubernetes-id: storefront
+    backstage.io/kubernetes-namespace: main
+  links:
+  - url: https://web.main.my-domain.com
+    title: web
+  - url: https://web.main.my-domain.com/admin
+    title: web (admin)
+spec:
+  type: service
+  lifecycle: production
+  owner: shop
+`}, cm.Data)
+
+	// the catalogs are published again when an URL changes
+	changed := api.DeepCopy()
+	changed.Annotations[exposestrategy.ExposeAnnotationKey] = "https://api.my-domain.com"
+	require.NoError(t, store.Update(changed))
+	client.ClearActions()
+	catalog.urlChanged(api, api)
+	assert.Empty(t, client.Actions(), "URL unchanged")
+	catalog.urlChanged(api, changed)
+	cm, err = client.CoreV1().ConfigMaps("main").Get(ctx, "backstage-catalog", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, cm.Data[backstageCatalogKey], "url: https://api.my-domain.com\n")
+
+	for _, svc := range []*v1.Service{web, changed} {
+		require.NoError(t, store.Delete(svc))
+	}
+	catalog.deleted(changed)
+	_, err = client.CoreV1().ConfigMaps("main").Get(ctx, "backstage-catalog", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "catalog deleted")
+}
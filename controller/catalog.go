@@ -0,0 +1,395 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/clock"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+// CatalogConfig configures the publication of the catalog of exposed URLs
+type CatalogConfig struct {
+	// Format is either "json" or "yaml"
+	Format     string           `yaml:"format,omitempty" json:"format"`
+	WebhookURL string           `yaml:"webhook-url,omitempty" json:"webhook_url"`
+	S3         *CatalogS3Config `yaml:"s3,omitempty" json:"s3"`
+}
+
+// CatalogS3Config configures the upload of the catalog to an S3 compatible bucket
+// the credentials are read from the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables
+type CatalogS3Config struct {
+	// Endpoint defaults to "s3.<region>.amazonaws.com", use "storage.googleapis.com" for GCS
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint"`
+	Region   string `yaml:"region,omitempty" json:"region"`
+	Bucket   string `yaml:"bucket" json:"bucket"`
+	Key      string `yaml:"key" json:"key"`
+}
+
+const (
+	catalogSyncPollPeriod = 100 * time.Millisecond
+	catalogMinBackoff     = 5 * time.Second
+	catalogMaxBackoff     = 5 * time.Minute
+)
+
+type catalogEntry struct {
+	Namespace string `yaml:"namespace" json:"namespace"`
+	Service   string `yaml:"service" json:"service"`
+	URL       string `yaml:"url" json:"url"`
+}
+
+type catalogSink interface {
+	publish(ctx context.Context, data []byte, contentType string) error
+}
+
+// buildCatalog lists the exposed services of the store, sorted by namespace and name
+func buildCatalog(store cache.Store, exposeValues *exposestrategy.ExposeValues) []catalogEntry {
+	entries := []catalogEntry{}
+	for _, obj := range store.List() {
+		svc, ok := obj.(*v1.Service)
+		if !ok || !exposeValues.IsRequested(svc) {
+			continue
+		}
+		url := svc.Annotations[exposestrategy.ExposeAnnotationKey]
+		if url == "" {
+			continue
+		}
+		entries = append(entries, catalogEntry{
+			Namespace: svc.Namespace,
+			Service:   svc.Name,
+			URL:       url,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Namespace != entries[j].Namespace {
+			return entries[i].Namespace < entries[j].Namespace
+		}
+		return entries[i].Service < entries[j].Service
+	})
+	return entries
+}
+
+func encodeCatalog(entries []catalogEntry, format string) ([]byte, string, error) {
+	switch strings.ToLower(format) {
+	case "", "json":
+		data, err := json.Marshal(entries)
+		return data, "application/json", err
+	case "yaml":
+		data, err := yaml.Marshal(entries)
+		return data, "application/yaml", err
+	default:
+		return nil, "", errors.Errorf("unknown catalog format \"%s\", must be one of \"json\", \"yaml\"", format)
+	}
+}
+
+func newCatalogSinks(config *CatalogConfig, clock clock.PassiveClock) ([]catalogSink, error) {
+	sinks := []catalogSink{}
+	if config.WebhookURL != "" {
+		sinks = append(sinks, &webhookPublisher{
+			url:    config.WebhookURL,
+			client: http.DefaultClient,
+		})
+	}
+	if config.S3 != nil {
+		if config.S3.Bucket == "" || config.S3.Key == "" {
+			return nil, errors.New("the catalog s3 bucket and key are required")
+		}
+		region := config.S3.Region
+		if region == "" {
+			region = "us-east-1"
+		}
+		endpoint := config.S3.Endpoint
+		if endpoint == "" {
+			endpoint = fmt.Sprintf("s3.%s.amazonaws.com", region)
+		}
+		sinks = append(sinks, &s3Publisher{
+			endpoint:        strings.TrimSuffix(endpoint, "/"),
+			region:          region,
+			bucket:          config.S3.Bucket,
+			key:             strings.TrimPrefix(config.S3.Key, "/"),
+			accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			client:          http.DefaultClient,
+			clock:           clock,
+		})
+	}
+	if len(sinks) == 0 {
+		return nil, errors.New("the catalog requires a webhook url or a s3 bucket")
+	}
+	return sinks, nil
+}
+
+// catalogPublisher publishes the catalog of the exposed services of a store
+type catalogPublisher struct {
+	format  string
+	sinks   []catalogSink
+	store   cache.Store
+	trigger chan struct{}
+	// exposeValues tell which services of the store request the exposure
+	exposeValues *exposestrategy.ExposeValues
+	last    []byte
+	clock   clock.Clock
+
+	pollPeriod time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// newCatalogPublisher creates the publisher of the catalog
+// returns nil if the config is nil
+func newCatalogPublisher(config *CatalogConfig, clock clock.Clock) (*catalogPublisher, error) {
+	if config == nil {
+		return nil, nil
+	}
+	sinks, err := newCatalogSinks(config, clock)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err = encodeCatalog(nil, config.Format); err != nil {
+		return nil, err
+	}
+	return &catalogPublisher{
+		format:  config.Format,
+		sinks:   sinks,
+		trigger: make(chan struct{}, 1),
+		clock:   clock,
+
+		pollPeriod: catalogSyncPollPeriod,
+		minBackoff: catalogMinBackoff,
+		maxBackoff: catalogMaxBackoff,
+	}, nil
+}
+
+// notify tells the background publisher that the catalog may have changed
+// it never blocks, so it is safe from the informer handlers
+func (p *catalogPublisher) notify() {
+	if p == nil {
+		return
+	}
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// publish publishes the catalog if it changed since the last successful publication
+func (p *catalogPublisher) publish(ctx context.Context) error {
+	data, contentType, err := encodeCatalog(buildCatalog(p.store, p.exposeValues), p.format)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(data, p.last) {
+		return nil
+	}
+	for _, sink := range p.sinks {
+		err := sink.publish(ctx, data, contentType)
+		if err != nil {
+			return err
+		}
+	}
+	klog.Infof("Published the catalog of exposed services")
+	p.last = data
+	return nil
+}
+
+// publishServices publishes the catalog of the services listed from the cluster
+// the informer may have stopped before receiving the exposed URLs
+func (p *catalogPublisher) publishServices(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	list, err := client.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list the services of the catalog")
+	}
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for i := range list.Items {
+		err = store.Add(&list.Items[i])
+		if err != nil {
+			return errors.Wrap(err, "failed to build the catalog")
+		}
+	}
+	p.store = store
+	return errors.Wrap(p.publish(ctx), "failed to publish the catalog")
+}
+
+// run publishes the catalog on every notification once hasSynced is true
+// failed publications are retried with an exponential backoff
+func (p *catalogPublisher) run(ctx context.Context, hasSynced func() bool) {
+	backoff := p.minBackoff
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.trigger:
+		case <-retry:
+		}
+		// the informer calls the handlers with its lock held, so that is checked from here
+		for !hasSynced() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.clock.After(p.pollPeriod):
+			}
+		}
+		err := p.publish(ctx)
+		if err == nil {
+			backoff = p.minBackoff
+			retry = nil
+			continue
+		}
+		klog.Errorf("Failed to publish the catalog, retrying in %s: %v", backoff, err)
+		retry = p.clock.After(backoff)
+		backoff *= 2
+		if backoff > p.maxBackoff {
+			backoff = p.maxBackoff
+		}
+	}
+}
+
+type webhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func (p *webhookPublisher) publish(ctx context.Context, data []byte, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "failed to build the webhook request")
+	}
+	req.Header.Set("Content-Type", contentType)
+	res, err := p.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to post the catalog to %s", p.url)
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return errors.Errorf("failed to post the catalog to %s: %s", p.url, res.Status)
+	}
+	return nil
+}
+
+type s3Publisher struct {
+	endpoint        string
+	region          string
+	bucket          string
+	key             string
+	accessKeyID     string
+	secretAccessKey string
+	client          *http.Client
+	clock           clock.PassiveClock
+}
+
+func (p *s3Publisher) publish(ctx context.Context, data []byte, contentType string) error {
+	scheme := "https"
+	host := p.endpoint
+	if i := strings.Index(host, "://"); i >= 0 {
+		scheme = host[:i]
+		host = host[i+3:]
+	}
+	path := "/" + awsURIEncode(p.bucket) + "/" + awsURIEncodePath(p.key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, scheme+"://"+host+path, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "failed to build the s3 request")
+	}
+	req.Header.Set("Content-Type", contentType)
+	p.sign(req, host, path, data)
+	res, err := p.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to upload the catalog to s3://%s/%s", p.bucket, p.key)
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return errors.Errorf("failed to upload the catalog to s3://%s/%s: %s", p.bucket, p.key, res.Status)
+	}
+	return nil
+}
+
+// sign signs the request with AWS signature version 4
+func (p *s3Publisher) sign(req *http.Request, host, path string, payload []byte) {
+	now := p.clock.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "content-type;host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		"",
+		"content-type:" + req.Header.Get("Content-Type"),
+		"host:" + host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + p.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+	key := hmacSHA256([]byte("AWS4"+p.secretAccessKey), date)
+	key = hmacSHA256(key, p.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.accessKeyID, scope, signedHeaders, signature))
+}
+
+// awsURIEncodePath encodes each segment of the path as required by the AWS signature
+func awsURIEncodePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = awsURIEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// awsURIEncode encodes all the bytes but the unreserved characters of RFC 3986
+func awsURIEncode(value string) string {
+	var buffer strings.Builder
+	for _, b := range []byte(value) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '.' || b == '_' || b == '~' {
+			buffer.WriteByte(b)
+		} else {
+			fmt.Fprintf(&buffer, "%%%02X", b)
+		}
+	}
+	return buffer.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
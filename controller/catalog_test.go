@@ -0,0 +1,227 @@
+package controller
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/clock"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCatalogStore(t *testing.T) cache.Store {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, svc := range []*v1.Service{{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns2",
+			Name:      "svc1",
+			Annotations: map[string]string{
+				exposestrategy.ExposeAnnotation.Key: exposestrategy.ExposeAnnotation.Value,
+				exposestrategy.ExposeAnnotationKey:  "https://svc1.ns2.my-domain.com",
+			},
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1",
+			Name:      "svc2",
+			Annotations: map[string]string{
+				exposestrategy.ExposeAnnotation.Key: exposestrategy.ExposeAnnotation.Value,
+				exposestrategy.ExposeAnnotationKey:  "http://svc2.ns1.my-domain.com",
+			},
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1",
+			Name:      "pending",
+			Annotations: map[string]string{
+				exposestrategy.ExposeAnnotation.Key: exposestrategy.ExposeAnnotation.Value,
+			},
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1",
+			Name:      "unexposed",
+			Annotations: map[string]string{
+				exposestrategy.ExposeAnnotationKey: "http://unexposed.ns1.my-domain.com",
+			},
+		},
+	}} {
+		require.NoError(t, store.Add(svc))
+	}
+	return store
+}
+
+func TestBuildCatalog(t *testing.T) {
+	entries := buildCatalog(newCatalogStore(t), nil)
+	expected := []catalogEntry{{
+		Namespace: "ns1",
+		Service:   "svc2",
+		URL:       "http://svc2.ns1.my-domain.com",
+	}, {
+		Namespace: "ns2",
+		Service:   "svc1",
+		URL:       "https://svc1.ns2.my-domain.com",
+	}}
+	assert.Equal(t, expected, entries)
+
+	data, contentType, err := encodeCatalog(entries, "yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "application/yaml", contentType)
+	assert.Equal(t, `- namespace: ns1
+  service: svc2
+  url: http://svc2.ns1.my-domain.com
+- namespace: ns2
+  service: svc1
+  url: https://svc1.ns2.my-domain.com
+`, string(data))
+
+	_, _, err = encodeCatalog(entries, "xml")
+	assert.Error(t, err)
+}
+
+func TestCatalogPublisher_run(t *testing.T) {
+	status := make(chan int, 10)
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		select {
+		case code := <-status:
+			res.WriteHeader(code)
+		default:
+			received <- string(body)
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := clocktesting.NewFakeClock(time.Now())
+	publisher, err := newCatalogPublisher(&CatalogConfig{WebhookURL: server.URL}, clock)
+	require.NoError(t, err)
+	publisher.store = newCatalogStore(t)
+	synced := make(chan struct{})
+	go publisher.run(ctx, func() bool {
+		select {
+		case <-synced:
+			return true
+		default:
+			return false
+		}
+	})
+
+	// nothing is published before the controller is synced, and failures are retried
+	status <- http.StatusServiceUnavailable
+	publisher.notify()
+	require.Eventually(t, clock.HasWaiters, 5*time.Second, time.Millisecond, "polling the sync")
+	assert.Empty(t, received, "unexpected publication")
+	close(synced)
+	clock.Step(catalogSyncPollPeriod)
+	require.Eventually(t, clock.HasWaiters, 5*time.Second, time.Millisecond, "waiting for the retry")
+	assert.Empty(t, status, "the failed publication is done first")
+	assert.Empty(t, received, "unexpected publication")
+	clock.Step(catalogMinBackoff)
+	select {
+	case body := <-received:
+		assert.Equal(t, `[{"namespace":"ns1","service":"svc2","url":"http://svc2.ns1.my-domain.com"},`+
+			`{"namespace":"ns2","service":"svc1","url":"https://svc1.ns2.my-domain.com"}]`, body)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "catalog not published")
+	}
+
+	// unchanged catalogs are not published again
+	publisher.notify()
+	select {
+	case body := <-received:
+		assert.Fail(t, "unexpected publication", body)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	_, err = newCatalogPublisher(&CatalogConfig{}, clock)
+	assert.Error(t, err, "no sink")
+	_, err = newCatalogPublisher(&CatalogConfig{WebhookURL: server.URL, Format: "xml"}, clock)
+	assert.Error(t, err, "unknown format")
+	publisher, err = newCatalogPublisher(nil, clock)
+	assert.NoError(t, err)
+	publisher.notify()
+}
+
+func TestCatalogPublisher_publishServices(t *testing.T) {
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		received <- string(body)
+	}))
+	defer server.Close()
+
+	client := fake.NewSimpleClientset(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				exposestrategy.ExposeAnnotation.Key: exposestrategy.ExposeAnnotation.Value,
+				exposestrategy.ExposeAnnotationKey:  "http://svc.main.my-domain.com",
+			},
+		},
+	})
+	publisher, err := newCatalogPublisher(&CatalogConfig{WebhookURL: server.URL, Format: "yaml"}, clock.RealClock{})
+	require.NoError(t, err)
+	err = publisher.publishServices(context.Background(), client, "main")
+	require.NoError(t, err)
+	select {
+	case body := <-received:
+		assert.Equal(t, `- namespace: main
+  service: svc
+  url: http://svc.main.my-domain.com
+`, body)
+	default:
+		assert.Fail(t, "catalog not published")
+	}
+}
+
+func TestS3Publisher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		assert.Equal(t, http.MethodPut, req.Method)
+		assert.Equal(t, "/my-bucket/envs/my%20catalog%2B1.json", req.URL.EscapedPath())
+		assert.Equal(t, "[]", string(body))
+		assert.Equal(t, "20240102T030405Z", req.Header.Get("X-Amz-Date"))
+		assert.Equal(t, sha256Hex(body), req.Header.Get("X-Amz-Content-Sha256"))
+		assert.True(t, strings.HasPrefix(req.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=my-key/20240102/eu-west-1/s3/aws4_request, "+
+				"SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature="),
+			req.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	publisher := &s3Publisher{
+		endpoint:        server.URL,
+		region:          "eu-west-1",
+		bucket:          "my-bucket",
+		key:             "envs/my catalog+1.json",
+		accessKeyID:     "my-key",
+		secretAccessKey: "my-secret",
+		client:          server.Client(),
+		clock:           clocktesting.NewFakePassiveClock(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+	}
+	err := publisher.publish(context.Background(), []byte("[]"), "application/json")
+	assert.NoError(t, err)
+}
+
+func TestAWSURIEncodePath(t *testing.T) {
+	assert.Equal(t, "envs/a%20b/c%3Dd~e_f-g.json", awsURIEncodePath("envs/a b/c=d~e_f-g.json"))
+}
@@ -4,10 +4,18 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	yaml "gopkg.in/yaml.v2"
 	"k8s.io/klog"
+	"k8s.io/utils/clock"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
 )
 
 // LoadFile loads the config from yaml file
@@ -61,22 +69,313 @@ type Config struct {
 	TLSAcme               bool     `yaml:"tls-acme" json:"tls_acme"`
 	TLSSecretName         string   `yaml:"tls-secret-name" json:"tls_secret_name"`
 	TLSUseWildcard        bool     `yaml:"tls-use-wildcard" json:"tls_use_wildcard"`
+	AcmeChallengeType     string   `yaml:"acme-challenge-type,omitempty" json:"acme_challenge_type" validate:"oneof=http01 dns01"`
+	AcmeIssuer            string   `yaml:"acme-issuer,omitempty" json:"acme_issuer"`
 	URLTemplate           string   `yaml:"urltemplate,omitempty" json:"url_template"`
 	Services              []string `yaml:"services,omitempty" json:"services"`
 	IngressClass          string   `yaml:"ingress-class" json:"ingress_class"`
 	NamePrefix            string   `yaml:"name-prefix,omitempty" json:"name_prefix"`
+	PortMapping           string   `yaml:"port-mapping,omitempty" json:"port_mapping"`
+	ServiceMonitor        bool     `yaml:"service-monitor" json:"service_monitor"`
+	SetOwnerReferences    *bool    `yaml:"set-owner-references,omitempty" json:"set_owner_references"`
+	HTTPRoute             bool     `yaml:"http-route" json:"http_route"`
+	GKEConfigs            bool     `yaml:"gke-configs" json:"gke_configs"`
+	ReadyEndpoints        string   `yaml:"ready-endpoints,omitempty" json:"ready_endpoints" validate:"oneof=warn wait"`
+	StrictAnnotations     bool     `yaml:"strict-annotations" json:"strict_annotations"`
+	AnnotationDenylist    []string `yaml:"annotation-denylist,omitempty" json:"annotation_denylist" validate:"regexp"`
+	AnnotationPropagation []string `yaml:"annotation-propagation,omitempty" json:"annotation_propagation" validate:"regexp"`
+	ExtraManifestKinds    []string `yaml:"extra-manifest-kinds,omitempty" json:"extra_manifest_kinds"`
+	DetectExposePort      bool     `yaml:"detect-expose-port" json:"detect_expose_port"`
+	DNSCheck              bool     `yaml:"dns-check" json:"dns_check"`
+	NeverDelete           bool     `yaml:"never-delete" json:"never_delete"`
+	DeletePropagation     string   `yaml:"delete-propagation,omitempty" json:"delete_propagation" validate:"oneof=foreground background orphan"`
+	NodePortDeadline      string   `yaml:"node-port-deadline,omitempty" json:"node_port_deadline" validate:"duration"`
+	DeleteGracePeriod     string   `yaml:"delete-grace-period,omitempty" json:"delete_grace_period" validate:"duration"`
+	IngressNamespace      string   `yaml:"ingress-namespace,omitempty" json:"ingress_namespace"`
+	IngressTemplate       string   `yaml:"ingress-template,omitempty" json:"ingress_template"`
+	HTTPRouteTemplate     string   `yaml:"http-route-template,omitempty" json:"http_route_template"`
+	HostConflictPolicy    string   `yaml:"host-conflict-policy,omitempty" json:"host_conflict_policy" validate:"oneof=skip adopt error"`
+	GatewayName           string   `yaml:"gateway-name,omitempty" json:"gateway_name"`
+	GatewayNamespace      string   `yaml:"gateway-namespace,omitempty" json:"gateway_namespace"`
+	URLOwner              string   `yaml:"url-owner,omitempty" json:"url_owner" validate:"oneof=ingress httproute"`
+	PermissionProfile     string   `yaml:"permission-profile,omitempty" json:"permission_profile" validate:"oneof=cluster namespace"`
+	ProviderLabel         string   `yaml:"provider-label,omitempty" json:"provider_label"`
+	GeneratedBy           string   `yaml:"generated-by,omitempty" json:"generated_by"`
+	ALBScheme             string   `yaml:"alb-scheme,omitempty" json:"alb_scheme" validate:"oneof=internet-facing internal"`
+	ALBTargetType         string   `yaml:"alb-target-type,omitempty" json:"alb_target_type" validate:"oneof=ip instance"`
+	ALBCertificateARN     string   `yaml:"alb-certificate-arn,omitempty" json:"alb_certificate_arn"`
+	ALBGroupName          string   `yaml:"alb-group-name,omitempty" json:"alb_group_name"`
+
+	// Catalog publishes the catalog of exposed services if set
+	Catalog *CatalogConfig `yaml:"catalog,omitempty" json:"catalog"`
+	// Notifications sends notifications on exposure changes if set
+	Notifications *NotificationsConfig `yaml:"notifications,omitempty" json:"notifications"`
+	// Hooks call HTTP endpoints with the URL changes of the services, such as to register them in a developer portal
+	Hooks []HookConfig `yaml:"hooks,omitempty" json:"hooks"`
+	// ExposeHooks are the hooks of the programs embedding the controller, called as the Hooks
+	ExposeHooks []ExposeHook `yaml:"-" json:"-"`
+	// Teams are the domain, ingress class and TLS secret of the services by "fabric8.io/expose.team" annotation
+	Teams map[string]exposestrategy.TeamConfig `yaml:"teams,omitempty" json:"teams"`
+	// DomainTLSPolicies are the TLS mode and secret of the hosts by domain, such as plain HTTP for the internal domain
+	DomainTLSPolicies map[string]exposestrategy.DomainTLSPolicy `yaml:"domain-tls-policies,omitempty" json:"domain_tls_policies"`
+	// TLSSecretsBySuffix are the secrets of the hosts by suffix, such as the wildcard certificates of the domains of the cluster
+	TLSSecretsBySuffix map[string]string `yaml:"tls-secrets-by-suffix,omitempty" json:"tls_secrets_by_suffix"`
+	// IngressLabels are stamped on the generated ingresses, such as for the cluster policies selecting them by labels
+	IngressLabels map[string]string `yaml:"ingress-labels,omitempty" json:"ingress_labels"`
+	// HTTP3Annotations are added to the ingresses of the services asking for HTTP/3, for the controllers not known to support it
+	HTTP3Annotations map[string]string `yaml:"http3-annotations,omitempty" json:"http3_annotations"`
+	// Domains are the domains the services select with their annotation, one or all of them, such as the domains of the regions
+	Domains []string `yaml:"domains,omitempty" json:"domains"`
+	// LoadBalancerSourceRanges are the default source ranges of the load balancers of the exposed services, by CIDR,
+	// overridden by the annotation of their namespace
+	LoadBalancerSourceRanges []string `yaml:"load-balancer-source-ranges,omitempty" json:"load_balancer_source_ranges"`
+	// ShardIndex and ShardCount split the namespaces between the replicas of the controller by hash of their names,
+	// each replica handling the namespaces of its shard, disabled if the count is less than 2
+	ShardIndex int `yaml:"shard-index,omitempty" json:"shard_index"`
+	ShardCount int `yaml:"shard-count,omitempty" json:"shard_count"`
+	// MaxExposedPerNamespace limits the number of services exposed by namespace, unlimited if 0
+	MaxExposedPerNamespace int `yaml:"max-exposed-per-namespace,omitempty" json:"max_exposed_per_namespace"`
+	// HTTPPort and HTTPSPort are the ports of the ingress controller in the exposed URLs, such as the node ports on bare metal
+	HTTPPort  int `yaml:"http-port,omitempty" json:"http_port"`
+	HTTPSPort int `yaml:"https-port,omitempty" json:"https_port"`
+	// IngressNodePortService is the "namespace/name" of the NodePort service of the ingress controller
+	// its node ports and the node IP make the exposed URLs reachable without load balancer nor DNS
+	IngressNodePortService string `yaml:"ingress-node-port-service,omitempty" json:"ingress_node_port_service"`
+	// PauseConfigMap is the "namespace/name" of the config map stopping all the mutations while its "paused" key is "true"
+	PauseConfigMap string `yaml:"pause-config-map,omitempty" json:"pause_config_map"`
+	// StatsInterval is how often the summary of the services and errors is logged in daemon mode, every 15 minutes if empty
+	StatsInterval string `yaml:"stats-interval,omitempty" json:"stats_interval" validate:"duration"`
+	// IngressStatusCheck publishes the URLs once the ingress controller wrote the status of the ingresses, for the controllers writing it
+	IngressStatusCheck bool `yaml:"ingress-status-check" json:"ingress_status_check"`
+	// IngressControllerHealthCheck checks that the ingress controller of the ingress class has a ready pod,
+	// reported by the readiness, the metrics and events on the ingress class, IngressControllerSelector are the labels
+	// of its deployments and daemon sets, inferred from the controller of the ingress class if empty
+	IngressControllerHealthCheck bool   `yaml:"ingress-controller-health-check" json:"ingress_controller_health_check"`
+	IngressControllerSelector    string `yaml:"ingress-controller-selector,omitempty" json:"ingress_controller_selector"`
+	// MigrateLegacyAnnotations renames the legacy ingress.kubernetes.io annotations of the nginx ingress controller
+	// to nginx.ingress.kubernetes.io on the generated ingresses, instead of setting both spellings
+	MigrateLegacyAnnotations bool `yaml:"migrate-legacy-annotations" json:"migrate_legacy_annotations"`
+	// AutoDomainService is the "namespace/name" of the load balancer service of the ingress controller,
+	// without domain, the services are exposed on the AutoDomainProvider domain of its IP, followed when it changes
+	AutoDomainService  string `yaml:"auto-domain-service,omitempty" json:"auto_domain_service"`
+	AutoDomainProvider string `yaml:"auto-domain-provider,omitempty" json:"auto_domain_provider" validate:"oneof=nip.io sslip.io"`
+	// HostRedirectGracePeriod is how long the old hosts of the services are redirected to their new one, never if empty
+	HostRedirectGracePeriod string `yaml:"host-redirect-grace-period,omitempty" json:"host_redirect_grace_period" validate:"duration"`
+	// TLSMergePolicy is "preserve" to keep the TLS entries added to the managed ingresses by others, such as cert-manager's ingress-shim
+	TLSMergePolicy string `yaml:"tls-merge-policy,omitempty" json:"tls_merge_policy" validate:"oneof=replace preserve"`
+	// URLTrailingSlash is "always" or "never" to end the paths of the published URLs with a slash or not, such as for the OAuth callbacks
+	URLTrailingSlash string `yaml:"url-trailing-slash,omitempty" json:"url_trailing_slash" validate:"oneof=always never preserve"`
+	// InternalDomainScheme is "http" or "https" for the hosts of the internal domain, independently from the external domain
+	// with "https", InternalDomainTLSSecretName holds their certificate if set
+	InternalDomainScheme        string `yaml:"internal-domain-scheme,omitempty" json:"internal_domain_scheme" validate:"oneof=http https"`
+	InternalDomainTLSSecretName string `yaml:"internal-domain-tls-secret-name,omitempty" json:"internal_domain_tls_secret_name"`
+	// OwnerReferenceController and OwnerReferenceBlockOwnerDeletion set the controller and blockOwnerDeletion fields
+	// of the owner references to the services, the latter needs the permission to update the finalizers of the services
+	OwnerReferenceController         bool `yaml:"owner-reference-controller" json:"owner_reference_controller"`
+	OwnerReferenceBlockOwnerDeletion bool `yaml:"owner-reference-block-owner-deletion" json:"owner_reference_block_owner_deletion"`
+	// HealthCheckPath is the probe path of the services routed on their hosts for the external uptime checks, such as "/healthz"
+	HealthCheckPath string `yaml:"health-check-path,omitempty" json:"health_check_path"`
+	// RecordLastApplied stores the desired ingresses in their last applied annotation, as kubectl apply,
+	// the labels and annotations added to the ingresses by others are then kept on update
+	RecordLastApplied bool `yaml:"record-last-applied" json:"record_last_applied"`
+	// SharedInformers shares the caches of the services and ingresses between the controller and the strategy,
+	// which reads them instead of listing the API server on each service, with a single watch by type
+	SharedInformers bool `yaml:"shared-informers" json:"shared_informers"`
+	// ExposeValues are the values of the expose label and annotations requesting the exposure, "true" only if empty,
+	// such as the "ingress" and "expose" values of the fabric8 era, StrictExposeValue only accepts "true" as is
+	ExposeValues      []string `yaml:"expose-values,omitempty" json:"expose_values" validate:"oneof=true ingress expose"`
+	StrictExposeValue bool     `yaml:"strict-expose-value" json:"strict_expose_value"`
+	// ExposureReport is the name of the config map summarizing the exposed services of each namespace, written after each sync
+	ExposureReport string `yaml:"exposure-report,omitempty" json:"exposure_report"`
+	// BackstageCatalog is the name of the config map holding the Backstage components of the exposed services of each namespace,
+	// owned by their "fabric8.io/expose.team" annotation, BackstageOwner or their namespace
+	BackstageCatalog string `yaml:"backstage-catalog,omitempty" json:"backstage_catalog"`
+	BackstageOwner   string `yaml:"backstage-owner,omitempty" json:"backstage_owner"`
+	// Clock schedules the resyncs, timeouts and retries, the real clock if nil
+	// a fake clock such as k8s.io/utils/clock/testing.FakeClock simulates them without waiting
+	Clock clock.WithDelayedExecution `yaml:"-" json:"-"`
 	// original is the input from which the config was parsed.
-	original string `json:"original"`
+	original string `json:"-"`
+}
+
+// clock returns the clock of the config, the real one by default
+func (c *Config) clock() clock.WithDelayedExecution {
+	if c.Clock == nil {
+		return clock.RealClock{}
+	}
+	return c.Clock
 }
 
 // DefaultConfig is the default values of Config
 var (
-	DefaultConfig = Config{}
+	DefaultConfig = Config{
+		WatchCurrentNamespace: true,
+	}
 )
 
+// ConfigEnvPrefix prefixes the environment variables overriding the config
+// such as EXPOSECONTROLLER_DOMAIN for "domain" or EXPOSECONTROLLER_TLS_ACME for "tls-acme"
+const ConfigEnvPrefix = "EXPOSECONTROLLER_"
+
+// ApplyEnv overrides the config with the environment variables of its top level keys
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	t := reflect.TypeOf(c).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		key := yamlKey(t.Field(i))
+		if key == "" {
+			continue
+		}
+		name := ConfigEnvPrefix + strings.ToUpper(strings.Replace(key, "-", "_", -1))
+		if text, ok := lookup(name); ok {
+			_, err := c.Set(key, text)
+			if err != nil {
+				return errors.Wrapf(err, "invalid environment variable %s", name)
+			}
+		}
+	}
+	return nil
+}
+
+// Set overrides the value of a top level key of the config, false if the key cannot be set
+// the lists are comma separated, the nested configs can only be set from a file
+func (c *Config) Set(key, text string) (bool, error) {
+	value := reflect.ValueOf(c).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		if yamlKey(value.Type().Field(i)) != key {
+			continue
+		}
+		err := setConfigField(value.Field(i), text)
+		if err != nil {
+			return true, err
+		}
+		// watching namespaces stops watching the current one
+		if key == "watch-namespaces" && text != "" {
+			c.WatchCurrentNamespace = false
+		}
+		c.original = ""
+		return true, nil
+	}
+	return false, nil
+}
+
+// yamlKey returns the key of the field in the config file, empty if it cannot be set from a string
+func yamlKey(field reflect.StructField) string {
+	key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if key == "" || key == "-" || field.PkgPath != "" {
+		return ""
+	}
+	switch field.Type.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int:
+		return key
+	case reflect.Slice:
+		if field.Type.Elem().Kind() == reflect.String {
+			return key
+		}
+	case reflect.Ptr:
+		if field.Type.Elem().Kind() == reflect.Bool {
+			return key
+		}
+	}
+	return ""
+}
+
+func setConfigField(field reflect.Value, text string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		i, err := strconv.Atoi(text)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(i))
+	case reflect.Ptr:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(&b))
+	case reflect.Slice:
+		var values []string
+		for _, v := range strings.Split(text, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	}
+	return nil
+}
+
+// Validate checks the values against the validate tags of the fields, the empty values being the defaults
+// "oneof=a b" restricts the value to a or b, "duration" requires a positive duration
+// "regexp" requires a regular expression, the rules apply to every value of a list
+func (c *Config) Validate() error {
+	value := reflect.ValueOf(c).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		rule := field.Tag.Get("validate")
+		if rule == "" {
+			continue
+		}
+		var texts []string
+		if field.Type.Kind() == reflect.String {
+			texts = []string{value.Field(i).String()}
+		} else if field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.String {
+			for j := 0; j < value.Field(i).Len(); j++ {
+				texts = append(texts, value.Field(i).Index(j).String())
+			}
+		}
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		for _, text := range texts {
+			if err := validateValue(key, rule, text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateValue checks a value of the key against the rule
+func validateValue(key, rule, text string) error {
+	if text == "" {
+		return nil
+	}
+	if strings.HasPrefix(rule, "oneof=") {
+		allowed := strings.Fields(strings.TrimPrefix(rule, "oneof="))
+		found := false
+		for _, a := range allowed {
+			found = found || a == text
+		}
+		if !found {
+			return errors.Errorf("invalid %s \"%s\", must be one of \"%s\"", key, text, strings.Join(allowed, "\", \""))
+		}
+	} else if rule == "duration" {
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s \"%s\"", key, text)
+		}
+		if d <= 0 {
+			return errors.Errorf("invalid %s \"%s\", must be positive", key, text)
+		}
+	} else if rule == "regexp" {
+		if _, err := regexp.Compile(text); err != nil {
+			return errors.Wrapf(err, "invalid %s \"%s\"", key, text)
+		}
+	}
+	return nil
+}
+
 // MapToConfig converts the ConfigMap data to a Config object
 func MapToConfig(data map[string]string) (*Config, error) {
 	answer := &Config{}
+	*answer = DefaultConfig
 
 	b, err := yaml.Marshal(data)
 	if err != nil {
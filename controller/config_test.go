@@ -2,6 +2,9 @@ package controller
 
 import (
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMapToConfig(t *testing.T) {
@@ -29,3 +32,94 @@ func assertStringEquals(t *testing.T, expected, actual, message string) {
 		t.Errorf("%s was not equal. Expected %s but got %s\n", message, expected, actual)
 	}
 }
+
+func TestConfigLayers(t *testing.T) {
+	config, err := Load("domain: file.my-domain.com\nexposer: ingress\nhttp: true\n")
+	require.NoError(t, err)
+	assert.True(t, config.WatchCurrentNamespace, "default")
+
+	env := map[string]string{
+		"EXPOSECONTROLLER_DOMAIN":                    "env.my-domain.com",
+		"EXPOSECONTROLLER_HTTP":                      "false",
+		"EXPOSECONTROLLER_SERVICES":                  "a, b,",
+		"EXPOSECONTROLLER_SET_OWNER_REFERENCES":      "false",
+		"EXPOSECONTROLLER_WATCH_NAMESPACES":          "apps",
+		"EXPOSECONTROLLER_MAX_EXPOSED_PER_NAMESPACE": "20",
+	}
+	err = config.ApplyEnv(func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "env.my-domain.com", config.Domain)
+	assert.Equal(t, "ingress", config.Exposer, "from the file")
+	assert.False(t, config.HTTP)
+	assert.Equal(t, []string{"a", "b"}, config.Services)
+	require.NotNil(t, config.SetOwnerReferences)
+	assert.False(t, *config.SetOwnerReferences)
+	assert.Equal(t, "apps", config.WatchNamespaces)
+	assert.False(t, config.WatchCurrentNamespace)
+	assert.Equal(t, 20, config.MaxExposedPerNamespace)
+	assert.Contains(t, config.String(), "env.my-domain.com", "the overrides are shown")
+
+	// the flags come last
+	set, err := config.Set("domain", "flag.my-domain.com")
+	require.NoError(t, err)
+	assert.True(t, set)
+	assert.Equal(t, "flag.my-domain.com", config.Domain)
+	set, err = config.Set("sync-period", "1m")
+	require.NoError(t, err)
+	assert.False(t, set, "not a config key")
+	_, err = config.Set("http", "maybe")
+	assert.Error(t, err)
+
+	err = config.ApplyEnv(func(name string) (string, bool) {
+		return "maybe", name == "EXPOSECONTROLLER_TLS_ACME"
+	})
+	assert.EqualError(t, err, "invalid environment variable EXPOSECONTROLLER_TLS_ACME: strconv.ParseBool: parsing \"maybe\": invalid syntax")
+}
+
+func TestConfigValidate(t *testing.T) {
+	examples := []struct {
+		config *Config
+		err    string
+	}{{
+		config: &Config{},
+	}, {
+		config: &Config{ReadyEndpoints: "wait", URLOwner: "httproute", NodePortDeadline: "10m"},
+	}, {
+		config: &Config{ReadyEndpoints: "always"},
+		err:    `invalid ready-endpoints "always", must be one of "warn", "wait"`,
+	}, {
+		config: &Config{PermissionProfile: "admin"},
+		err:    `invalid permission-profile "admin", must be one of "cluster", "namespace"`,
+	}, {
+		config: &Config{NodePortDeadline: "soon"},
+		err:    `invalid node-port-deadline "soon": time: invalid duration "soon"`,
+	}, {
+		config: &Config{NodePortDeadline: "0s"},
+		err:    `invalid node-port-deadline "0s", must be positive`,
+	}, {
+		config: &Config{AnnotationDenylist: []string{`nginx\.ingress\.kubernetes\.io/.*-snippet`}},
+	}, {
+		config: &Config{AnnotationDenylist: []string{"*-snippet"}},
+		err:    "invalid annotation-denylist \"*-snippet\": error parsing regexp: missing argument to repetition operator: `*`",
+	}, {
+		config: &Config{AnnotationPropagation: []string{"external-dns.alpha.kubernetes.io/(ttl"}},
+		err:    "invalid annotation-propagation \"external-dns.alpha.kubernetes.io/(ttl\": error parsing regexp: missing closing ): `external-dns.alpha.kubernetes.io/(ttl`",
+
+	}, {
+		config: &Config{ExposeValues: []string{"true", "ingress"}},
+	}, {
+		config: &Config{ExposeValues: []string{"true", "yes"}},
+		err:    `invalid expose-values "yes", must be one of "true", "ingress", "expose"`,
+	}}
+	for _, example := range examples {
+		err := example.config.Validate()
+		if example.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, example.err)
+		}
+	}
+}
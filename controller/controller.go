@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -15,8 +16,10 @@ import (
 	"k8s.io/klog"
 
 	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 
@@ -45,18 +48,41 @@ const (
 )
 
 // Run runs the controller until synced or timeout
-func Run(ctx context.Context, client kubernetes.Interface, namespace string, config *Config, timeout time.Duration) error {
+func Run(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, config *Config, timeout time.Duration) error {
 	var hasSyncedTimeout <-chan time.Time
 	if timeout > 0*time.Second {
-		hasSyncedTimeout = time.After(timeout)
+		hasSyncedTimeout = config.clock().After(timeout)
 	} else {
 		hasSyncedTimeout = make(chan time.Time)
 	}
 	hasSynced := make(chan struct{})
 	hasSyncedController := make(chan struct{})
 	hasSyncedStrategy := make(chan struct{})
+	pause, err := newPauseSwitch(ctx, client, config.PauseConfigMap, nil)
+	if err != nil {
+		return err
+	}
+	if pause.isPaused() {
+		klog.Warningf("Paused by config map %s, the services are not exposed", config.PauseConfigMap)
+		return nil
+	}
+	catalog, err := newCatalogPublisher(config.Catalog, config.clock())
+	if err != nil {
+		return errors.Wrap(err, "failed to create the catalog publisher")
+	}
+	notifier, err := newNotifier(ctx, config.Notifications)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the notifier")
+	}
+	defer notifier.flush()
+	hooks, err := newExposeHooks(ctx, config)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the hooks")
+	}
+	defer hooks.flush()
 
-	controller, err := createController(ctx, client, namespace, config, time.Hour, hasSyncedController, hasSyncedStrategy)
+	stats := newControllerStats(config)
+	controller, err := createController(ctx, client, dynamicClient, namespace, config, time.Hour, hasSyncedController, hasSyncedStrategy, nil, catalog, notifier, hooks, nil, stats)
 	if err != nil {
 		return err
 	}
@@ -71,20 +97,148 @@ func Run(ctx context.Context, client kubernetes.Interface, namespace string, con
 		close(hasSynced)
 	}()
 	controller.Run(hasSynced)
+	stats.log()
+	if err == nil && catalog != nil {
+		err = catalog.publishServices(ctx, client, namespace)
+	}
 	return err
 }
 
+// Controller is the controller of a daemon run
+type Controller interface {
+	cache.Controller
+	// Resync lists the services again as soon as possible, syncing the strategy and reconciling every service
+	Resync()
+	// LogStats logs the summary of the services and errors, such as on shutdown
+	LogStats()
+	// CacheStatus tells which caches are synced, by resource
+	CacheStatus() map[string]bool
+	// IngressControllerStatus is the last health of the ingress controller, nil if not checked
+	IngressControllerStatus() *IngressControllerStatus
+}
+
+type daemonController struct {
+	cache.Controller
+	resync        chan struct{}
+	stats         *controllerStats
+	ingressHealth *ingressHealthCheck
+	hooks         *exposeHooks
+}
+
+// Run runs the controller until stopped, then calls the hooks with the events queued meanwhile
+func (c *daemonController) Run(stopCh <-chan struct{}) {
+	c.Controller.Run(stopCh)
+	c.hooks.flush()
+}
+
+func (c *daemonController) Resync() {
+	select {
+	case c.resync <- struct{}{}:
+	default:
+		// a resync is already pending
+	}
+}
+
+func (c *daemonController) LogStats() {
+	c.stats.log()
+}
+
+func (c *daemonController) CacheStatus() map[string]bool {
+	if shared, ok := c.Controller.(*sharedController); ok {
+		return shared.informers.cacheStatus()
+	}
+	return map[string]bool{"services": c.HasSynced()}
+}
+
+func (c *daemonController) IngressControllerStatus() *IngressControllerStatus {
+	return c.ingressHealth.current()
+}
+
 // Daemon returns a controller for a daemon run
-func Daemon(ctx context.Context, client kubernetes.Interface, namespace string, config *Config, resyncPeriod time.Duration) (cache.Controller, error) {
-	return createController(ctx, client, namespace, config, resyncPeriod, nil, nil)
+func Daemon(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, config *Config, resyncPeriod time.Duration) (Controller, error) {
+	catalog, err := newCatalogPublisher(config.Catalog, config.clock())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the catalog publisher")
+	}
+	notifier, err := newNotifier(ctx, config.Notifications)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the notifier")
+	}
+	// the queued events are flushed once the controller stopped, with the context cancelled
+	hooks, err := newExposeHooks(context.Background(), config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the hooks")
+	}
+	statsInterval, err := parseStatsInterval(config.StatsInterval)
+	if err != nil {
+		return nil, err
+	}
+	resync := make(chan struct{}, 1)
+	pause, err := newPauseSwitch(ctx, client, config.PauseConfigMap, resync)
+	if err != nil {
+		return nil, err
+	}
+	health, err := newIngressHealthCheck(ctx, client, config)
+	if err != nil {
+		return nil, err
+	}
+	stats := newControllerStats(config)
+	controller, err := createController(ctx, client, dynamicClient, namespace, config, resyncPeriod, nil, nil, resync, catalog, notifier, hooks, pause, stats)
+	if err != nil {
+		return nil, err
+	}
+	if catalog != nil {
+		go catalog.run(ctx, controller.HasSynced)
+	}
+	go pause.run(ctx)
+	go stats.run(ctx, statsInterval)
+	go health.run(ctx)
+	return &daemonController{Controller: controller, resync: resync, stats: stats, ingressHealth: health, hooks: hooks}, nil
 }
 
-func createController(ctx context.Context, client kubernetes.Interface, namespace string, config *Config, resyncPeriod time.Duration, hasSyncedController, hasSyncedStrategy chan struct{}) (cache.Controller, error) {
-	strategy, err := getStrategy(ctx, client, namespace, config)
+func createController(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, config *Config, resyncPeriod time.Duration, hasSyncedController, hasSyncedStrategy chan struct{}, resync chan struct{}, catalog *catalogPublisher, notifier *notifier, hooks *exposeHooks, pause *pauseSwitch, stats *controllerStats) (cache.Controller, error) {
+	if config.PermissionProfile == exposestrategy.PermissionProfileNamespace && namespace == "" {
+		return nil, errors.New("the namespace permission profile requires watching a single namespace")
+	}
+	if config.ServiceMonitor && dynamicClient == nil {
+		return nil, errors.New("a dynamic client is required to generate service monitors")
+	}
+	if _, err := exposestrategy.ParseProviderLabel(config.ProviderLabel); err != nil {
+		return nil, err
+	}
+	shard := shardOf(config)
+	if err := shard.Check(); err != nil {
+		return nil, err
+	}
+	exposeValues, err := exposestrategy.NewExposeValues(config.ExposeValues, config.StrictExposeValue)
+	if err != nil {
+		return nil, err
+	}
+	scheduler := newExposeScheduler(resync, config.clock())
+	shared := newSharedInformers(client, namespace, config, resyncPeriod)
+	strategy, err := getStrategy(ctx, client, dynamicClient, namespace, config, scheduler, shared, exposeValues)
 	if err != nil {
 		return nil, err
 	}
 
+	endpoints, err := newEndpointsChecker(ctx, client, config.ReadyEndpoints, scheduler)
+	if err != nil {
+		return nil, err
+	}
+	quota := newExposeQuota(ctx, client, config.MaxExposedPerNamespace, scheduler)
+	writeBack := newWriteBackRegistry()
+	annotationErrors := newAnnotationErrorReporter(ctx, client)
+	retries := newExposeRetries(ctx, resync, config.clock())
+	exposeErrors := newExposeErrorReporter(ctx, client, retries)
+	exposeValueErrors := newExposeValueReporter(ctx, client, exposeValues)
+	report := newExposureReporter(ctx, client, config)
+	backstage := newBackstageCatalog(ctx, client, config)
+	exposureAges.reset(config)
+	teardown := newNamespaceTeardown(ctx, client, config, strategy)
+	manifests, err := newExtraManifests(ctx, client, dynamicClient, config)
+	if err != nil {
+		return nil, err
+	}
 	var controller cache.Controller
 	isSyncing := false
 	needCheckSynced := false
@@ -92,6 +246,9 @@ func createController(ctx context.Context, client kubernetes.Interface, namespac
 		needCheckSynced = true
 		if isSyncing && controller.HasSynced() {
 			isSyncing = false
+			stats.synced()
+			report.publish()
+			backstage.publish()
 			if hasSyncedController != nil && strategy.HasSynced() {
 				close(hasSyncedController)
 				hasSyncedController = nil
@@ -99,26 +256,93 @@ func createController(ctx context.Context, client kubernetes.Interface, namespac
 		}
 	}
 
+	// cleanService unexposes the service, deleted tells that the service is gone
+	// the services of a namespace being deleted are only forgotten, their objects are deleted with it
+	cleanService := func(svc *v1.Service, deleted bool) {
+		endpoints.forget(svc)
+		quota.forget(svc)
+		report.forget(svc)
+		exposureAges.forget(svc)
+		annotationErrors.report(svc, nil)
+		exposeErrors.forget(svc)
+		if teardown.isTerminating(svc) {
+			writeBack.forget(svc)
+			teardown.forget(svc)
+			return
+		}
+		var err error
+		if deleted {
+			err = strategy.Delete(svc)
+		} else {
+			err = strategy.Clean(svc)
+		}
+		if err != nil {
+			klog.Errorf("Remove failed: %v", err)
+			stats.failed()
+		}
+		err = writeBack.restore(ctx, client, svc)
+		if err != nil {
+			klog.Errorf("ConfigMap restoration failed: %v", err)
+		}
+		err = deleteServiceMonitor(ctx, dynamicClient, svc, config)
+		if err != nil {
+			klog.Errorf("ServiceMonitor removal failed: %v", err)
+		}
+		err = manifests.prune(svc, nil)
+		if err != nil {
+			klog.Errorf("Extra manifests removal failed: %v", err)
+		}
+	}
+
 	handlers := cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
+			defer catalog.notify()
+			if pause.isPaused() {
+				return
+			}
 			svc := obj.(*v1.Service)
-			if shouldExposeService(svc) {
-				if !isServiceWhitelisted(svc.Name, config) {
+			if isServiceWhitelisted(svc.Name, config) {
+				exposeValueErrors.report(svc)
+			}
+			exposed := exposeValues.IsRequested(svc) && isServiceWhitelisted(svc.Name, config) && !teardown.isForgotten(svc)
+			if exposed {
+				svc = withNamespaceDefaults(ctx, client, svc, config)
+				exposed = scheduler.isExposeActive(svc)
+			}
+			if exposed && (!endpoints.canExpose(svc) || !quota.canExpose(svc)) {
+				return
+			}
+			if exposed {
+				err := strategy.Add(svc)
+				if err != nil && teardown.isTerminating(svc) {
+					// nothing can be created in a namespace being deleted
+					cleanService(svc, true)
 					return
 				}
-				err := strategy.Add(svc)
 				if err != nil {
 					klog.Errorf("Add failed: %v", err)
+					stats.failed()
 				}
-				updateRelatedResources(ctx, client, svc, config)
-			} else if isSyncing {
-				if !isServiceWhitelisted(svc.Name, config) {
-					return
+				notifier.exposeResult(svc, err)
+				annotationErrors.report(svc, err)
+				exposeErrors.report(svc, err)
+				report.result(svc, err)
+				exposureAges.result(svc, err)
+				updateRelatedResources(ctx, client, svc, config, writeBack)
+				err = updateServiceMonitor(ctx, client, dynamicClient, svc, config)
+				if err != nil {
+					klog.Errorf("ServiceMonitor update failed: %v", err)
 				}
-				err := strategy.Clean(svc)
+				err = manifests.apply(svc)
 				if err != nil {
-					klog.Errorf("Remove failed: %v", err)
+					klog.Errorf("Extra manifests update failed: %v", err)
+				}
+			} else if isSyncing || exposeValues.IsRequested(svc) {
+				// out of its schedule, an exposed service is cleaned
+				if !isServiceWhitelisted(svc.Name, config) {
+					return
 				}
+				cleanService(svc, false)
 				if needCheckSynced {
 					needCheckSynced = false
 					go checkSynced()
@@ -132,24 +356,55 @@ func createController(ctx context.Context, client kubernetes.Interface, namespac
 			}
 		},
 		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
+			defer catalog.notify()
+			if pause.isPaused() {
+				return
+			}
 			svc := newObj.(*v1.Service)
-			if shouldExposeService(svc) {
-				if !isServiceWhitelisted(svc.Name, config) {
+			notifier.urlChanged(oldObj.(*v1.Service), svc)
+			hooks.urlChanged(oldObj.(*v1.Service), svc)
+			backstage.urlChanged(oldObj.(*v1.Service), svc)
+			if isServiceWhitelisted(svc.Name, config) {
+				exposeValueErrors.report(svc)
+			}
+			exposed := exposeValues.IsRequested(svc) && isServiceWhitelisted(svc.Name, config) && !teardown.isForgotten(svc)
+			if exposed {
+				svc = withNamespaceDefaults(ctx, client, svc, config)
+				exposed = scheduler.isExposeActive(svc)
+			}
+			if exposed && (!endpoints.canExpose(svc) || !quota.canExpose(svc)) {
+				return
+			}
+			if exposed {
+				err := strategy.Add(svc)
+				if err != nil && teardown.isTerminating(svc) {
+					// nothing can be created in a namespace being deleted
+					cleanService(svc, true)
 					return
 				}
-				err := strategy.Add(svc)
 				if err != nil {
 					klog.Errorf("Add failed: %v", err)
+					stats.failed()
 				}
-				updateRelatedResources(ctx, client, svc, config)
-			} else if shouldExposeService(oldObj.(*v1.Service)) {
-				if !isServiceWhitelisted(svc.Name, config) {
-					return
+				notifier.exposeResult(svc, err)
+				annotationErrors.report(svc, err)
+				exposeErrors.report(svc, err)
+				report.result(svc, err)
+				exposureAges.result(svc, err)
+				updateRelatedResources(ctx, client, svc, config, writeBack)
+				err = updateServiceMonitor(ctx, client, dynamicClient, svc, config)
+				if err != nil {
+					klog.Errorf("ServiceMonitor update failed: %v", err)
 				}
-				err := strategy.Clean(svc)
+				err = manifests.apply(svc)
 				if err != nil {
-					klog.Errorf("Remove failed: %v", err)
+					klog.Errorf("Extra manifests update failed: %v", err)
 				}
+			} else if exposeValues.IsRequested(oldObj.(*v1.Service)) || exposeValues.IsRequested(svc) {
+				if !isServiceWhitelisted(svc.Name, config) {
+					return
+				}
+				cleanService(svc, false)
 			} else {
 				return
 			}
@@ -159,15 +414,19 @@ func createController(ctx context.Context, client kubernetes.Interface, namespac
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
+			defer catalog.notify()
+			if pause.isPaused() {
+				return
+			}
 			svc := obj.(*v1.Service)
-			if shouldExposeService(svc) {
+			exposeValueErrors.forget(svc)
+			hooks.deleted(svc)
+			backstage.deleted(svc)
+			if exposeValues.IsRequested(svc) {
 				if !isServiceWhitelisted(svc.Name, config) {
 					return
 				}
-				err := strategy.Delete(svc)
-				if err != nil {
-					klog.Errorf("Remove failed: %v", err)
-				}
+				cleanService(svc, true)
 			} else {
 				return
 			}
@@ -180,37 +439,161 @@ func createController(ctx context.Context, client kubernetes.Interface, namespac
 
 	services := client.CoreV1().Services(namespace)
 
-	_, controller = cache.NewInformer(
-		&cache.ListWatch{
-			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			// that list satisfies a pending resync
+			select {
+			case <-resync:
+			default:
+			}
+			// nothing is cleaned while paused, the services are listed again once resumed
+			if !pause.isPaused() {
 				err := strategy.Sync()
 				if err != nil {
 					return nil, err
 				}
-				list, err := services.List(ctx, options)
+				teardown.reset()
+				err = cleanServiceMonitors(ctx, client, dynamicClient, namespace, config)
 				if err != nil {
 					return nil, err
 				}
-				isSyncing = true
-				go checkSynced()
-				return list, nil
-			},
-			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-				return services.Watch(ctx, options)
-			},
+				err = writeBack.sync(ctx, client, namespace)
+				if err != nil {
+					klog.Warningf("The written config maps are not restored: %v", err)
+				}
+			}
+			stats.syncStarted()
+			list, err := services.List(ctx, options)
+			if err != nil {
+				return nil, err
+			}
+			filterShardServices(list, shard)
+			isSyncing = true
+			go checkSynced()
+			return list, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			w, err := services.Watch(ctx, options)
+			if err != nil {
+				return w, err
+			}
+			w = filterShardWatch(w, shard)
+			if resync == nil {
+				return w, nil
+			}
+			return newResyncWatch(w, resync), nil
+		},
+	}
+	// the retries call the handlers outside of the informer, one at a time with its events
+	var handlersLock sync.Mutex
+	lockedHandlers := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			handlersLock.Lock()
+			defer handlersLock.Unlock()
+			handlers.OnAdd(obj)
 		},
-		&v1.Service{},
-		resyncPeriod,
-		handlers,
-	)
+		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
+			handlersLock.Lock()
+			defer handlersLock.Unlock()
+			handlers.OnUpdate(oldObj, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			handlersLock.Lock()
+			defer handlersLock.Unlock()
+			handlers.OnDelete(obj)
+		},
+	}
+	var store cache.Store
+	if shared != nil {
+		store, controller = shared.controller(listWatch, lockedHandlers)
+	} else {
+		store, controller = cache.NewInformer(listWatch, &v1.Service{}, resyncPeriod, lockedHandlers)
+	}
+	if retries != nil {
+		retries.store = store
+		retries.handler = lockedHandlers
+	}
+	if catalog != nil {
+		catalog.store = store
+		catalog.exposeValues = exposeValues
+	}
+	stats.store = store
+	stats.exposeValues = exposeValues
+	if report != nil {
+		report.store = store
+		report.exposeValues = exposeValues
+	}
+	if backstage != nil {
+		backstage.store = store
+		backstage.exposeValues = exposeValues
+	}
 
 	return controller, nil
 }
 
+// resyncWatch ends with an expired error when a resync is requested
+// so that the informer lists the services again
+type resyncWatch struct {
+	watch.Interface
+	result chan watch.Event
+	stop   chan struct{}
+	once   sync.Once
+}
+
+func newResyncWatch(w watch.Interface, resync <-chan struct{}) watch.Interface {
+	rw := &resyncWatch{
+		Interface: w,
+		result:    make(chan watch.Event),
+		stop:      make(chan struct{}),
+	}
+	go rw.run(resync)
+	return rw
+}
+
+func (w *resyncWatch) run(resync <-chan struct{}) {
+	defer close(w.result)
+	for {
+		var event watch.Event
+		select {
+		case <-w.stop:
+			return
+		case <-resync:
+			klog.Infof("Resync requested, listing the services again")
+			expired := apierrors.NewResourceExpired("resync requested")
+			event = watch.Event{Type: watch.Error, Object: &expired.ErrStatus}
+		case e, ok := <-w.Interface.ResultChan():
+			if !ok {
+				return
+			}
+			event = e
+		}
+		select {
+		case w.result <- event:
+		case <-w.stop:
+			return
+		}
+		if event.Type == watch.Error {
+			return
+		}
+	}
+}
+
+func (w *resyncWatch) ResultChan() <-chan watch.Event {
+	return w.result
+}
+
+func (w *resyncWatch) Stop() {
+	w.once.Do(func() {
+		close(w.stop)
+		w.Interface.Stop()
+	})
+}
+
 // for testing only
 var testStrategy exposestrategy.ExposeStrategy
 
-func getStrategy(ctx context.Context, client kubernetes.Interface, namespace string, config *Config) (exposestrategy.ExposeStrategy, error) {
+func getStrategy(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, config *Config, scheduler *exposeScheduler, shared *sharedInformers,
+	exposeValues *exposestrategy.ExposeValues) (exposestrategy.ExposeStrategy, error) {
 	// for testing only
 	if testStrategy != nil {
 		return testStrategy, nil
@@ -229,6 +612,69 @@ func getStrategy(ctx context.Context, client kubernetes.Interface, namespace str
 		URLTemplate:    config.URLTemplate,
 		PathMode:       config.PathMode,
 		IngressClass:   config.IngressClass,
+		PortMapping:    config.PortMapping,
+
+		AcmeChallengeType: config.AcmeChallengeType,
+		AcmeIssuer:        config.AcmeIssuer,
+
+		InternalDomainScheme:        config.InternalDomainScheme,
+		InternalDomainTLSSecretName: config.InternalDomainTLSSecretName,
+		MigrateLegacyAnnotations:    config.MigrateLegacyAnnotations,
+		AutoDomainService:           config.AutoDomainService,
+		AutoDomainProvider:          config.AutoDomainProvider,
+		TLSSecretsBySuffix:          config.TLSSecretsBySuffix,
+		HostRedirectGracePeriod:     config.HostRedirectGracePeriod,
+
+		OwnerReferenceController:         config.OwnerReferenceController,
+		OwnerReferenceBlockOwnerDeletion: config.OwnerReferenceBlockOwnerDeletion,
+		HealthCheckPath:                  config.HealthCheckPath,
+		RecordLastApplied:                config.RecordLastApplied,
+
+		SkipOwnerReferences:    config.SetOwnerReferences != nil && !*config.SetOwnerReferences,
+		HTTPRoute:              config.HTTPRoute,
+		GKEConfigs:             config.GKEConfigs,
+		StrictAnnotations:      config.StrictAnnotations,
+		DetectExposePort:       config.DetectExposePort,
+		DNSCheck:               config.DNSCheck,
+		AnnotationDenylist:     config.AnnotationDenylist,
+		AnnotationPropagation:  config.AnnotationPropagation,
+		NeverDelete:            config.NeverDelete,
+		DeletePropagation:      config.DeletePropagation,
+		HTTPPort:               config.HTTPPort,
+		HTTPSPort:              config.HTTPSPort,
+		IngressNodePortService: config.IngressNodePortService,
+		Teams:                  config.Teams,
+		DomainTLSPolicies:      config.DomainTLSPolicies,
+		NodePortDeadline:       config.NodePortDeadline,
+		DeleteGracePeriod:      config.DeleteGracePeriod,
+		IngressNamespace:       config.IngressNamespace,
+		IngressTemplate:        config.IngressTemplate,
+		HTTPRouteTemplate:      config.HTTPRouteTemplate,
+		HostConflictPolicy:     config.HostConflictPolicy,
+		Clock:                  config.clock(),
+		ScheduleResync:         scheduler.scheduleAfter,
+		IngressStatusCheck:     config.IngressStatusCheck,
+		IngressLabels:          config.IngressLabels,
+		HTTP3Annotations:       config.HTTP3Annotations,
+		Shard:                  shardOf(config),
+		Domains:                config.Domains,
+		TLSMergePolicy:         config.TLSMergePolicy,
+		URLTrailingSlash:       config.URLTrailingSlash,
+		GatewayName:            config.GatewayName,
+		GatewayNamespace:       config.GatewayNamespace,
+		URLOwner:               config.URLOwner,
+		DynamicClient:          dynamicClient,
+		ExposeValues:           exposeValues,
+		ServiceLister:          shared.serviceLister(),
+		IngressLister:          shared.ingressLister(),
+		PermissionProfile:      config.PermissionProfile,
+		ProviderLabel:          providerLabel(config),
+		ALBScheme:              config.ALBScheme,
+		ALBTargetType:          config.ALBTargetType,
+		ALBCertificateARN:      config.ALBCertificateARN,
+		ALBGroupName:           config.ALBGroupName,
+
+		LoadBalancerSourceRanges: config.LoadBalancerSourceRanges,
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create new strategy")
@@ -236,10 +682,22 @@ func getStrategy(ctx context.Context, client kubernetes.Interface, namespace str
 	return strategy, nil
 }
 
-func shouldExposeService(svc *v1.Service) bool {
-	return svc.Labels[exposestrategy.ExposeLabel.Key] == exposestrategy.ExposeLabel.Value ||
-		svc.Annotations[exposestrategy.ExposeAnnotation.Key] == exposestrategy.ExposeAnnotation.Value ||
-		svc.Annotations[exposestrategy.InjectAnnotation.Key] == exposestrategy.InjectAnnotation.Value
+// providerLabel returns the provider label set on the generated objects
+// the label is validated when creating the controller, the legacy one is returned if invalid
+func providerLabel(config *Config) exposestrategy.ProviderLabel {
+	provider, err := exposestrategy.ParseProviderLabel(config.ProviderLabel)
+	if err != nil {
+		provider = exposestrategy.LegacyProviderLabel
+	}
+	provider.GeneratedBy = config.GeneratedBy
+	return provider
+}
+
+// deleteOptions returns the options deleting the generated objects with the propagation policy of the config
+// the policy is validated with the config, the default one of the API server is used if invalid
+func deleteOptions(config *Config) metav1.DeleteOptions {
+	propagation, _ := exposestrategy.ParseDeletePropagation(config.DeletePropagation)
+	return exposestrategy.DeleteOptions(propagation, "")
 }
 
 // isServiceWhitelisted checks if a service is white-listed in the controller configuration, allow all services if
@@ -257,12 +715,12 @@ func isServiceWhitelisted(service string, config *Config) bool {
 	return false
 }
 
-func updateRelatedResources(ctx context.Context, c kubernetes.Interface, svc *v1.Service, config *Config) {
-	updateServiceConfigMap(ctx, c, svc, config)
+func updateRelatedResources(ctx context.Context, c kubernetes.Interface, svc *v1.Service, config *Config, writeBack *writeBackRegistry) {
+	updateServiceConfigMap(ctx, c, svc, config, writeBack)
 
 	exposeURL := svc.Annotations[exposestrategy.ExposeAnnotationKey]
 	if exposeURL != "" {
-		updateOtherConfigMaps(ctx, c, svc, config, exposeURL)
+		updateOtherConfigMaps(ctx, c, svc, config, exposeURL, writeBack)
 	}
 }
 
@@ -287,12 +745,13 @@ type configYaml struct {
 	Suffix     string
 }
 
-func updateServiceConfigMap(ctx context.Context, c kubernetes.Interface, svc *v1.Service, config *Config) {
+func updateServiceConfigMap(ctx context.Context, c kubernetes.Interface, svc *v1.Service, config *Config, writeBack *writeBackRegistry) {
 	name := svc.Name
 	ns := svc.Namespace
 	cm, err := c.CoreV1().ConfigMaps(ns).Get(ctx, name, metav1.GetOptions{})
 	if err == nil {
 		updated := false
+		write := writeBack.writer(cm, svc)
 
 		clusterIP := svc.Spec.ClusterIP
 		if clusterIP != "" {
@@ -301,8 +760,7 @@ func updateServiceConfigMap(ctx context.Context, c kubernetes.Interface, svc *v1
 			clusterIPPortIfEmptyKey := cm.Annotations[ExposeConfigClusterIPPortIfEmptyKeyAnnotation]
 
 			if clusterIPKey != "" {
-				if cm.Data[clusterIPKey] != clusterIP {
-					cm.Data[clusterIPKey] = clusterIP
+				if write(clusterIPKey, clusterIP) {
 					updated = true
 				}
 			}
@@ -312,14 +770,12 @@ func updateServiceConfigMap(ctx context.Context, c kubernetes.Interface, svc *v1
 				clusterIPAndPort := clusterIP + ":" + port
 
 				if clusterIPPortKey != "" {
-					if cm.Data[clusterIPPortKey] != clusterIPAndPort {
-						cm.Data[clusterIPPortKey] = clusterIPAndPort
+					if write(clusterIPPortKey, clusterIPAndPort) {
 						updated = true
 					}
 				}
 				if clusterIPPortIfEmptyKey != "" {
-					if cm.Data[clusterIPPortIfEmptyKey] == "" {
-						cm.Data[clusterIPPortIfEmptyKey] = clusterIPAndPort
+					if cm.Data[clusterIPPortIfEmptyKey] == "" && write(clusterIPPortIfEmptyKey, clusterIPAndPort) {
 						updated = true
 					}
 				}
@@ -338,14 +794,12 @@ func updateServiceConfigMap(ctx context.Context, c kubernetes.Interface, svc *v1
 			urlKey := cm.Annotations[ExposeConfigURLKeyAnnotation]
 			domainKey := cm.Annotations[ExposeConfigHostKeyAnnotation]
 			if urlKey != "" {
-				if cm.Data[urlKey] != exposeURL {
-					cm.Data[urlKey] = exposeURL
+				if write(urlKey, exposeURL) {
 					updated = true
 				}
 			}
 			if host != "" && domainKey != "" {
-				if cm.Data[domainKey] != host {
-					cm.Data[domainKey] = host
+				if write(domainKey, host) {
 					updated = true
 				}
 			}
@@ -353,8 +807,7 @@ func updateServiceConfigMap(ctx context.Context, c kubernetes.Interface, svc *v1
 			pathKey := cm.Annotations[ExposeConfigClusterPathKeyAnnotation]
 			if pathKey != "" {
 				path := urlPath(exposeURL)
-				if cm.Data[pathKey] != path {
-					cm.Data[pathKey] = path
+				if write(pathKey, path) {
 					updated = true
 				}
 				klog.Infof("Found key %s and has path %s\n", pathKey, path)
@@ -374,7 +827,7 @@ func updateServiceConfigMap(ctx context.Context, c kubernetes.Interface, svc *v1
 					}
 					fmt.Printf("Loading yaml config %#v\n", configs)
 					for _, c := range configs {
-						if c.updateConfigMap(cm, values) {
+						if c.updateConfigMap(cm, values, write) {
 							updated = true
 						}
 					}
@@ -412,7 +865,7 @@ func urlPath(urlText string) string {
 	return answer
 }
 
-func (c *configYaml) updateConfigMap(configMap *v1.ConfigMap, values map[string]string) bool {
+func (c *configYaml) updateConfigMap(configMap *v1.ConfigMap, values map[string]string, write func(key, value string) bool) bool {
 	key := c.Key
 	if key == "" {
 		klog.Warningf("ConfigMap %s does not have a key in yaml config %#v\n", configMap.Name, c)
@@ -438,16 +891,11 @@ func (c *configYaml) updateConfigMap(configMap *v1.ConfigMap, values map[string]
 		}
 		buffer.WriteString("\n")
 	}
-	newValue := buffer.String()
-	if newValue != value {
-		configMap.Data[key] = newValue
-		return true
-	}
-	return false
+	return write(key, buffer.String())
 }
 
 // updateOtherConfigMaps lets update all other configmaps which want to be injected by this svc exposeURL
-func updateOtherConfigMaps(ctx context.Context, c kubernetes.Interface, svc *v1.Service, config *Config, exposeURL string) error {
+func updateOtherConfigMaps(ctx context.Context, c kubernetes.Interface, svc *v1.Service, config *Config, exposeURL string, writeBack *writeBackRegistry) error {
 	serviceName := svc.Name
 	annotationKey := "expose.service-key.config.fabric8.io/" + serviceName
 	annotationFullKey := "expose-full.service-key.config.fabric8.io/" + serviceName
@@ -462,6 +910,7 @@ func updateOtherConfigMaps(ctx context.Context, c kubernetes.Interface, svc *v1.
 	}
 	for _, cm := range cms.Items {
 		update := false
+		write := writeBack.writer(&cm, svc)
 		updateKey := cm.Annotations[annotationKey]
 		if cm.Data == nil {
 			cm.Data = map[string]string{}
@@ -470,9 +919,7 @@ func updateOtherConfigMaps(ctx context.Context, c kubernetes.Interface, svc *v1.
 			exposeURL = strings.TrimSuffix(exposeURL, "/")
 			keys := strings.Split(updateKey, ",")
 			for _, key := range keys {
-				value := cm.Data[key]
-				if value != exposeURL {
-					cm.Data[key] = exposeURL
+				if write(key, exposeURL) {
 					klog.Infof("Updating ConfigMap %s in namespace %s with key %s", cm.Name, ns, key)
 					update = true
 				}
@@ -485,9 +932,7 @@ func updateOtherConfigMaps(ctx context.Context, c kubernetes.Interface, svc *v1.
 			}
 			keys := strings.Split(updateKey, ",")
 			for _, key := range keys {
-				value := cm.Data[key]
-				if value != exposeURL {
-					cm.Data[key] = exposeURL
+				if write(key, exposeURL) {
 					klog.Infof("Updating ConfigMap %s in namespace %s with key %s", cm.Name, ns, key)
 					update = true
 				}
@@ -506,9 +951,7 @@ func updateOtherConfigMaps(ctx context.Context, c kubernetes.Interface, svc *v1.
 				noPathURL := u.String()
 				keys := strings.Split(updateKey, ",")
 				for _, key := range keys {
-					value := cm.Data[key]
-					if value != noPathURL {
-						cm.Data[key] = noPathURL
+					if write(key, noPathURL) {
 						klog.Infof("Updating ConfigMap %s in namespace %s with key %s", cm.Name, ns, key)
 						update = true
 					}
@@ -525,9 +968,7 @@ func updateOtherConfigMaps(ctx context.Context, c kubernetes.Interface, svc *v1.
 			exposeURL = strings.TrimPrefix(exposeURL, "https://")
 			keys := strings.Split(updateKey, ",")
 			for _, key := range keys {
-				value := cm.Data[key]
-				if value != exposeURL {
-					cm.Data[key] = exposeURL
+				if write(key, exposeURL) {
 					klog.Infof("Updating ConfigMap %s in namespace %s with key %s", cm.Name, ns, key)
 					update = true
 				}
@@ -542,9 +983,7 @@ func updateOtherConfigMaps(ctx context.Context, c kubernetes.Interface, svc *v1.
 			exposeURL = strings.TrimPrefix(exposeURL, "https://")
 			keys := strings.Split(updateKey, ",")
 			for _, key := range keys {
-				value := cm.Data[key]
-				if value != exposeURL {
-					cm.Data[key] = exposeURL
+				if write(key, exposeURL) {
 					klog.Infof("Updating ConfigMap %s in namespace %s with key %s", cm.Name, ns, key)
 					update = true
 				}
@@ -558,9 +997,7 @@ func updateOtherConfigMaps(ctx context.Context, c kubernetes.Interface, svc *v1.
 			}
 			keys := strings.Split(updateKey, ",")
 			for _, key := range keys {
-				value := cm.Data[key]
-				if value != protocol {
-					cm.Data[key] = protocol
+				if write(key, protocol) {
 					klog.Infof("Updating ConfigMap %s in namespace %s with key %s", cm.Name, ns, key)
 					update = true
 				}
@@ -569,7 +1006,7 @@ func updateOtherConfigMaps(ctx context.Context, c kubernetes.Interface, svc *v1.
 		if update {
 			_, err = c.CoreV1().ConfigMaps(ns).Update(ctx, &cm, metav1.UpdateOptions{})
 			if err != nil {
-				return fmt.Errorf("Failed to update ConfigMap %s in namespace %s with key %s due to %v", cm.Name, ns, updateKey, err)
+				return errors.Wrapf(err, "Failed to update ConfigMap %s in namespace %s with key %s", cm.Name, ns, updateKey)
 			}
 		}
 	}
@@ -192,7 +192,7 @@ func TestRun_controllerSynced(t *testing.T) {
 		testStrategy = nil
 	}()
 
-	err := Run(ctx, client, "main", &Config{}, time.Second)
+	err := Run(ctx, client, nil, "main", &Config{}, time.Second)
 	require.NoError(t, err)
 	strategy.checkEnd()
 }
@@ -297,7 +297,7 @@ func TestRun_strategySynced(t *testing.T) {
 		testStrategy = nil
 	}()
 
-	err := Run(ctx, client, "main", &Config{}, time.Second)
+	err := Run(ctx, client, nil, "main", &Config{}, time.Second)
 	require.NoError(t, err)
 	strategy.checkEnd()
 }
@@ -334,7 +334,7 @@ func TestRun_timeout(t *testing.T) {
 	}()
 
 	ctx := context.Background()
-	err := Run(ctx, client, "main", &Config{}, time.Second)
+	err := Run(ctx, client, nil, "main", &Config{}, time.Second)
 	require.Error(t, err)
 	strategy.checkEnd()
 }
@@ -450,7 +450,7 @@ func TestDaemon(t *testing.T) {
 		testStrategy = nil
 	}()
 
-	controller, err := Daemon(ctx, client, "main", &Config{}, time.Hour)
+	controller, err := Daemon(ctx, client, nil, "main", &Config{}, time.Hour)
 	require.NoError(t, err)
 	stopChan := make(chan struct{})
 	defer close(stopChan)
@@ -506,3 +506,50 @@ func TestDaemon(t *testing.T) {
 	time.Sleep(500 * time.Millisecond)
 	strategy.checkEnd()
 }
+
+func TestDaemon_resync(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc1",
+			Annotations: map[string]string{
+				exposestrategy.ExposeAnnotation.Key: exposestrategy.ExposeAnnotation.Value,
+			},
+			ResourceVersion: "1",
+		},
+	})
+	strategy := fakeStrategy{
+		testing: t,
+		tasks: []map[string]bool{{
+			"Sync": true,
+		}, {
+			"Add:main/svc1:1": true,
+		}},
+	}
+	testStrategy = &strategy
+	defer func() {
+		testStrategy = nil
+	}()
+
+	controller, err := Daemon(ctx, client, nil, "main", &Config{}, time.Hour)
+	require.NoError(t, err)
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+	go controller.Run(stopChan)
+
+	time.Sleep(500 * time.Millisecond)
+	strategy.checkEnd()
+
+	// the services are listed again and reconciled
+	strategy.tasks = []map[string]bool{{
+		"Sync": true,
+	}, {
+		"Add:main/svc1:1": true,
+	}}
+	controller.Resync()
+	controller.Resync()
+
+	time.Sleep(2 * time.Second)
+	strategy.checkEnd()
+}
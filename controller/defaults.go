@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+// NamespaceDefaultsAnnotationKey annotation of a namespace holds the default annotations of its exposed services
+// in YAML format, the keys without prefix are in the "fabric8.io/" one
+const NamespaceDefaultsAnnotationKey = "fabric8.io/expose.defaults"
+
+// withNamespaceDefaults returns the service with the default annotations of its namespace
+// the annotations of the service take precedence, the service is returned as is on error
+func withNamespaceDefaults(ctx context.Context, c kubernetes.Interface, svc *v1.Service, config *Config) *v1.Service {
+	if config.PermissionProfile == exposestrategy.PermissionProfileNamespace {
+		// namespaces are cluster scoped
+		return svc
+	}
+	ns, err := c.CoreV1().Namespaces().Get(ctx, svc.Namespace, metav1.GetOptions{})
+	if err != nil {
+		klog.Warningf("Failed to get the expose defaults of namespace %s: %v", svc.Namespace, err)
+		return svc
+	}
+	defaults, err := parseNamespaceDefaults(ns.Annotations[NamespaceDefaultsAnnotationKey])
+	if err != nil {
+		klog.Errorf("Failed to parse annotation \"%s\" of namespace %s: %v", NamespaceDefaultsAnnotationKey, ns.Name, err)
+		return svc
+	}
+	if len(defaults) == 0 {
+		return svc
+	}
+	clone := svc.DeepCopy()
+	if clone.Annotations == nil {
+		clone.Annotations = map[string]string{}
+	}
+	for key, value := range defaults {
+		if _, ok := clone.Annotations[key]; !ok {
+			clone.Annotations[key] = value
+		}
+	}
+	return clone
+}
+
+// parseNamespaceDefaults parses the default annotations
+// the annotations telling whether a service is exposed and where cannot be defaulted
+func parseNamespaceDefaults(text string) (map[string]string, error) {
+	if text == "" {
+		return nil, nil
+	}
+	values := map[string]interface{}{}
+	err := yaml.Unmarshal([]byte(text), &values)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid YAML")
+	}
+	defaults := map[string]string{}
+	for key, value := range values {
+		if !strings.Contains(key, "/") {
+			key = "fabric8.io/" + key
+		}
+		switch key {
+		case exposestrategy.ExposeAnnotation.Key, exposestrategy.InjectAnnotation.Key, exposestrategy.ExposeAnnotationKey, exposestrategy.ExposeURLsAnnotationKey:
+			return nil, errors.Errorf("annotation \"%s\" cannot be defaulted", key)
+		}
+		switch v := value.(type) {
+		case nil:
+			continue
+		case string:
+			defaults[key] = v
+		case map[interface{}]interface{}, []interface{}:
+			data, err := yaml.Marshal(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid value of \"%s\"", key)
+			}
+			defaults[key] = string(data)
+		default:
+			defaults[key] = fmt.Sprint(v)
+		}
+	}
+	return defaults, nil
+}
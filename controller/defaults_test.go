@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithNamespaceDefaults(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "main",
+			Annotations: map[string]string{
+				NamespaceDefaultsAnnotationKey: `
+path.mode: path
+use.internal.domain: true
+ingress.annotations:
+  kubernetes.io/ingress.class: internal
+jenkins-x.io/skip.tls: "true"
+`,
+			},
+		},
+	}, &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "invalid",
+			Annotations: map[string]string{
+				NamespaceDefaultsAnnotationKey: "exposeURL: http://nowhere",
+			},
+		},
+	})
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				"fabric8.io/expose":    "true",
+				"fabric8.io/path.mode": "",
+			},
+		},
+	}
+
+	result := withNamespaceDefaults(context.Background(), client, svc, &Config{})
+	assert.Equal(t, map[string]string{
+		"fabric8.io/expose":              "true",
+		"fabric8.io/path.mode":           "",
+		"fabric8.io/use.internal.domain": "true",
+		"fabric8.io/ingress.annotations": "kubernetes.io/ingress.class: internal\n",
+		"jenkins-x.io/skip.tls":          "true",
+	}, result.Annotations)
+	assert.Len(t, svc.Annotations, 2, "the service is not modified")
+
+	result = withNamespaceDefaults(context.Background(), client, svc, &Config{PermissionProfile: "namespace"})
+	assert.Equal(t, svc, result, "no namespace access")
+
+	svc.Namespace = "invalid"
+	result = withNamespaceDefaults(context.Background(), client, svc, &Config{})
+	assert.Equal(t, svc, result, "invalid defaults")
+
+	svc.Namespace = "missing"
+	result = withNamespaceDefaults(context.Background(), client, svc, &Config{})
+	assert.Equal(t, svc, result, "missing namespace")
+}
+
+func TestParseNamespaceDefaults(t *testing.T) {
+	defaults, err := parseNamespaceDefaults("{path.mode: path, exposePort: 8080, host.name: ~}")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"fabric8.io/path.mode":  "path",
+		"fabric8.io/exposePort": "8080",
+	}, defaults)
+
+	for _, text := range []string{"expose: true", "fabric8.io/inject: true", "[a, b]"} {
+		_, err = parseNamespaceDefaults(text)
+		assert.Error(t, err, text)
+	}
+}
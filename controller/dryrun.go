@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// dryRunTransport sends the writes to the API server as server side dry runs, so that nothing is changed
+// while they are still validated and defaulted, and records them with the objects the API server would store
+type dryRunTransport struct {
+	next http.RoundTripper
+
+	lock    sync.Mutex
+	changes []dryRunChange
+}
+
+// dryRunChange is a write of the controller, the object is the one returned by the API server, nil on error
+type dryRunChange struct {
+	verb      string
+	resource  string
+	namespace string
+	name      string
+	object    *unstructured.Unstructured
+	err       string
+}
+
+var dryRunVerbs = map[string]string{
+	http.MethodPost:   "create",
+	http.MethodPut:    "update",
+	http.MethodPatch:  "patch",
+	http.MethodDelete: "delete",
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	verb, ok := dryRunVerbs[req.Method]
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	dryRun := req.Clone(req.Context())
+	query := dryRun.URL.Query()
+	query.Set("dryRun", "All")
+	dryRun.URL.RawQuery = query.Encode()
+	res, err := t.next.RoundTrip(dryRun)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	change := dryRunChange{verb: verb}
+	change.namespace, change.resource, change.name = parseResourcePath(req.URL.Path)
+	object := &unstructured.Unstructured{}
+	if json.Unmarshal(body, &object.Object) == nil && object.GetKind() != "Status" {
+		change.object = object
+		change.name = object.GetName()
+	} else if res.StatusCode >= http.StatusBadRequest {
+		change.err = res.Status
+	}
+	t.lock.Lock()
+	t.changes = append(t.changes, change)
+	t.lock.Unlock()
+	return res, nil
+}
+
+// recorded returns the writes recorded so far
+func (t *dryRunTransport) recorded() []dryRunChange {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]dryRunChange(nil), t.changes...)
+}
+
+// parseResourcePath returns the namespace, resource and name of an API path
+// such as "/apis/networking.k8s.io/v1/namespaces/dev/ingresses/web", the subresources being ignored
+func parseResourcePath(path string) (string, string, string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		parts = parts[3:]
+	default:
+		return "", "", ""
+	}
+	namespace := ""
+	if len(parts) >= 3 && parts[0] == "namespaces" {
+		namespace = parts[1]
+		parts = parts[2:]
+	}
+	resource, name := "", ""
+	if len(parts) >= 1 {
+		resource = parts[0]
+	}
+	if len(parts) >= 2 {
+		name = parts[1]
+	}
+	return namespace, resource, name
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+const (
+	// ReadyEndpointsWarn exposes the services without ready endpoint with a warning event
+	ReadyEndpointsWarn = "warn"
+	// ReadyEndpointsWait delays the exposure of the services until they have a ready endpoint,
+	// a single run skips them as it does not check them again
+	ReadyEndpointsWait = "wait"
+
+	// endpointsRecheckPeriod is how often the services waiting for endpoints are checked again
+	endpointsRecheckPeriod = time.Minute
+)
+
+// endpointsChecker checks that the services have a ready endpoint before exposing them
+// all the methods are called from the handlers of the informer
+type endpointsChecker struct {
+	ctx       context.Context
+	client    kubernetes.Interface
+	mode      string
+	scheduler *exposeScheduler
+	// the services without ready endpoint, an event is emitted once until they have one
+	notReady map[string]bool
+}
+
+// newEndpointsChecker returns nil if the endpoints are not checked
+func newEndpointsChecker(ctx context.Context, client kubernetes.Interface, mode string, scheduler *exposeScheduler) (*endpointsChecker, error) {
+	switch mode {
+	case "":
+		return nil, nil
+	case ReadyEndpointsWarn, ReadyEndpointsWait:
+	default:
+		return nil, errors.Errorf("unknown ready endpoints mode \"%s\", must be one of \"%s\", \"%s\"",
+			mode, ReadyEndpointsWarn, ReadyEndpointsWait)
+	}
+	return &endpointsChecker{
+		ctx:       ctx,
+		client:    client,
+		mode:      mode,
+		scheduler: scheduler,
+		notReady:  map[string]bool{},
+	}, nil
+}
+
+// canExpose tells if the service can be exposed now
+// an already exposed service stays exposed, the services are exposed if their endpoints cannot be checked
+func (c *endpointsChecker) canExpose(svc *v1.Service) bool {
+	if c == nil || svc.Spec.Type == v1.ServiceTypeExternalName {
+		return true
+	}
+	key := svc.Namespace + "/" + svc.Name
+	ready, err := hasReadyEndpoints(c.ctx, c.client, svc)
+	if err != nil {
+		klog.Warningf("Failed to check the endpoints of service %s: %v", key, err)
+		return true
+	}
+	if ready {
+		delete(c.notReady, key)
+		return true
+	}
+	_, exposed := svc.Annotations[exposestrategy.ExposeAnnotationKey]
+	if c.mode == ReadyEndpointsWarn || exposed {
+		if !c.notReady[key] {
+			exposestrategy.EmitServiceEvent(c.ctx, c.client, svc, v1.EventTypeWarning, "NoReadyEndpoints", "The service is exposed without any ready endpoint")
+		}
+		c.notReady[key] = true
+		return true
+	}
+	if !c.notReady[key] {
+		if c.scheduler == nil {
+			klog.Infof("Skipping service %s without ready endpoint, not checked again by a single run", key)
+		} else {
+			klog.Infof("Waiting for a ready endpoint to expose service %s", key)
+		}
+		exposestrategy.EmitServiceEvent(c.ctx, c.client, svc, v1.EventTypeNormal, "WaitingForEndpoints", "The service is exposed once it has a ready endpoint")
+	}
+	c.notReady[key] = true
+	c.scheduler.scheduleAfter(endpointsRecheckPeriod)
+	return false
+}
+
+// forget is called when the service is not exposed anymore
+func (c *endpointsChecker) forget(svc *v1.Service) {
+	if c != nil {
+		delete(c.notReady, svc.Namespace+"/"+svc.Name)
+	}
+}
+
+// hasReadyEndpoints tells if the service has a ready endpoint
+// the endpoint slices are checked, or the endpoints if they cannot be listed
+func hasReadyEndpoints(ctx context.Context, client kubernetes.Interface, svc *v1.Service) (bool, error) {
+	slices, err := client.DiscoveryV1().EndpointSlices(svc.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: discoveryv1.LabelServiceName + "=" + svc.Name,
+	})
+	if err == nil {
+		for _, slice := range slices.Items {
+			for _, endpoint := range slice.Endpoints {
+				// an unknown readiness is ready
+				if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+	endpoints, err2 := client.CoreV1().Endpoints(svc.Namespace).Get(ctx, svc.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err2) {
+		return false, nil
+	} else if err2 != nil {
+		return false, errors.Wrap(err2, "failed to get the endpoints")
+	}
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
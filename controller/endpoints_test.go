@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEndpointSlice(name string, ready ...bool) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      name,
+			Labels: map[string]string{
+				discoveryv1.LabelServiceName: "svc",
+			},
+		},
+	}
+	for i := range ready {
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+			Addresses:  []string{"10.0.0.1"},
+			Conditions: discoveryv1.EndpointConditions{Ready: &ready[i]},
+		})
+	}
+	return slice
+}
+
+func TestHasReadyEndpoints(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+		},
+	}
+	examples := []struct {
+		name    string
+		objects []runtime.Object
+		ready   bool
+	}{{
+		name: "no endpoint slice",
+	}, {
+		name:    "no ready endpoint",
+		objects: []runtime.Object{newEndpointSlice("svc-a", false, false)},
+	}, {
+		name:    "ready endpoint",
+		objects: []runtime.Object{newEndpointSlice("svc-a", false), newEndpointSlice("svc-b", false, true)},
+		ready:   true,
+	}}
+	for _, example := range examples {
+		client := fake.NewSimpleClientset(example.objects...)
+		ready, err := hasReadyEndpoints(context.Background(), client, svc)
+		require.NoError(t, err, example.name)
+		assert.Equal(t, example.ready, ready, example.name)
+	}
+
+	// the endpoints are checked without endpoint slices
+	client := fake.NewSimpleClientset(&v1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+		},
+		Subsets: []v1.EndpointSubset{{
+			NotReadyAddresses: []v1.EndpointAddress{{IP: "10.0.0.1"}},
+		}, {
+			Addresses: []v1.EndpointAddress{{IP: "10.0.0.2"}},
+		}},
+	})
+	client.PrependReactor("list", "endpointslices", func(ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("forbidden")
+	})
+	ready, err := hasReadyEndpoints(context.Background(), client, svc)
+	require.NoError(t, err)
+	assert.True(t, ready)
+
+	// the error of the endpoints is returned when both fail
+	client.PrependReactor("get", "endpoints", func(ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("timeout")
+	})
+	_, err = hasReadyEndpoints(context.Background(), client, svc)
+	assert.EqualError(t, err, "failed to get the endpoints: timeout")
+}
+
+func TestEndpointsChecker(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "main",
+			Name:        "svc",
+			Annotations: map[string]string{},
+		},
+	}
+	client := fake.NewSimpleClientset(newEndpointSlice("svc-a", false))
+	checker, err := newEndpointsChecker(context.Background(), client, ReadyEndpointsWait, nil)
+	require.NoError(t, err)
+	assert.False(t, checker.canExpose(svc))
+	assert.False(t, checker.canExpose(svc))
+	events, err := client.CoreV1().Events("main").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1, "one event until the service is ready")
+	assert.Equal(t, "WaitingForEndpoints", events.Items[0].Reason)
+	assert.Equal(t, "svc", events.Items[0].InvolvedObject.Name)
+
+	// an exposed service stays exposed
+	svc.Annotations[exposestrategy.ExposeAnnotationKey] = "http://svc.main.my-domain.com"
+	assert.True(t, checker.canExpose(svc))
+	delete(svc.Annotations, exposestrategy.ExposeAnnotationKey)
+
+	_, err = client.DiscoveryV1().EndpointSlices("main").Create(context.Background(), newEndpointSlice("svc-b", true), metav1.CreateOptions{})
+	require.NoError(t, err)
+	assert.True(t, checker.canExpose(svc))
+	assert.Empty(t, checker.notReady)
+
+	client = fake.NewSimpleClientset()
+	checker, err = newEndpointsChecker(context.Background(), client, ReadyEndpointsWarn, nil)
+	require.NoError(t, err)
+	assert.True(t, checker.canExpose(svc))
+	events, err = client.CoreV1().Events("main").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	assert.Equal(t, "NoReadyEndpoints", events.Items[0].Reason)
+	assert.Equal(t, v1.EventTypeWarning, events.Items[0].Type)
+
+	checker, err = newEndpointsChecker(context.Background(), client, "", nil)
+	require.NoError(t, err)
+	assert.True(t, checker.canExpose(svc), "not checked")
+	_, err = newEndpointsChecker(context.Background(), client, "always", nil)
+	assert.Error(t, err)
+}
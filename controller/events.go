@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+// annotationErrorReporter emits an event when an annotation of a service cannot be parsed
+// once until the error changes, and counts the failures by namespace
+type annotationErrorReporter struct {
+	ctx    context.Context
+	client kubernetes.Interface
+	// the last parse error by service key
+	last map[string]string
+}
+
+func newAnnotationErrorReporter(ctx context.Context, client kubernetes.Interface) *annotationErrorReporter {
+	return &annotationErrorReporter{
+		ctx:    ctx,
+		client: client,
+		last:   map[string]string{},
+	}
+}
+
+// report is called with the result of the exposure of the service
+func (r *annotationErrorReporter) report(svc *v1.Service, err error) {
+	key := svc.Namespace + "/" + svc.Name
+	var parseErr *exposestrategy.AnnotationParseError
+	if !errors.As(err, &parseErr) {
+		delete(r.last, key)
+		return
+	}
+	annotationParseFailures.inc(svc.Namespace)
+	message := parseErr.Error()
+	if r.last[key] != message {
+		exposestrategy.EmitServiceEvent(r.ctx, r.client, svc, v1.EventTypeWarning, "InvalidAnnotation", message)
+	}
+	r.last[key] = message
+}
+
+// exposeValueReporter emits an event when an expose annotation of a service has a value which is not accepted,
+// instead of silently not exposing the service, once until the value changes
+type exposeValueReporter struct {
+	ctx    context.Context
+	client kubernetes.Interface
+	values *exposestrategy.ExposeValues
+	// the last error by service key
+	last map[string]string
+}
+
+func newExposeValueReporter(ctx context.Context, client kubernetes.Interface, values *exposestrategy.ExposeValues) *exposeValueReporter {
+	return &exposeValueReporter{
+		ctx:    ctx,
+		client: client,
+		values: values,
+		last:   map[string]string{},
+	}
+}
+
+// report is called with every version of the service
+func (r *exposeValueReporter) report(svc *v1.Service) {
+	key := svc.Namespace + "/" + svc.Name
+	err := r.values.Invalid(svc)
+	if err == nil {
+		delete(r.last, key)
+		return
+	}
+	message := err.Error()
+	if r.last[key] != message {
+		klog.Warningf("%s", message)
+		exposestrategy.EmitServiceEvent(r.ctx, r.client, svc, v1.EventTypeWarning, "InvalidExposeValue", message)
+	}
+	r.last[key] = message
+}
+
+// forget is called when the service is deleted
+func (r *exposeValueReporter) forget(svc *v1.Service) {
+	delete(r.last, svc.Namespace+"/"+svc.Name)
+}
+
+// exposeErrorReporter decides what to do with the error of the exposure of a service from its kind:
+// the retryable errors expose the service again with a backoff, the errors of the service itself emit
+// an event once until the error changes, and the host conflicts already reported by the strategy are skipped
+type exposeErrorReporter struct {
+	ctx     context.Context
+	client  kubernetes.Interface
+	retries *exposeRetries
+	// the last event message by service key
+	last map[string]string
+}
+
+func newExposeErrorReporter(ctx context.Context, client kubernetes.Interface, retries *exposeRetries) *exposeErrorReporter {
+	return &exposeErrorReporter{
+		ctx:     ctx,
+		client:  client,
+		retries: retries,
+		last:    map[string]string{},
+	}
+}
+
+// report is called with the result of the exposure of the service
+func (r *exposeErrorReporter) report(svc *v1.Service, err error) {
+	key := svc.Namespace + "/" + svc.Name
+	if exposestrategy.Retryable(err) {
+		delete(r.last, key)
+		r.retries.failed(svc)
+		return
+	}
+	r.retries.forget(svc)
+	reason := ""
+	switch {
+	case errors.Is(err, exposestrategy.ErrNoPorts):
+		reason = "NoPortToExpose"
+	case errors.Is(err, exposestrategy.ErrHostTooLong):
+		reason = "HostTooLong"
+	case errors.Is(err, exposestrategy.ErrNotHTTP):
+		reason = "NonHTTPPort"
+	}
+	// the annotation parse errors and host conflicts have their own events
+	if reason == "" {
+		delete(r.last, key)
+		return
+	}
+	message := err.Error()
+	if r.last[key] != message {
+		exposestrategy.EmitServiceEvent(r.ctx, r.client, svc, v1.EventTypeWarning, reason, message)
+	}
+	r.last[key] = message
+}
+
+// forget is called when the service is unexposed
+func (r *exposeErrorReporter) forget(svc *v1.Service) {
+	delete(r.last, svc.Namespace+"/"+svc.Name)
+	r.retries.forget(svc)
+}
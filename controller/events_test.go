@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotationErrorReporter(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "reporter",
+			Name:      "svc",
+		},
+	}
+	parseErr := &exposestrategy.AnnotationParseError{
+		Namespace:  "reporter",
+		Service:    "svc",
+		Annotation: exposestrategy.IngressAnnotationsAnnotationKey,
+		Line:       2,
+		Column:     3,
+		Err:        errors.New("yaml: line 2: mapping values are not allowed in this context"),
+	}
+	client := fake.NewSimpleClientset()
+	reporter := newAnnotationErrorReporter(context.Background(), client)
+	reporter.report(svc, errors.Wrap(parseErr, "failed to expose"))
+	reporter.report(svc, errors.Wrap(parseErr, "failed to expose"))
+	assert.Equal(t, uint64(2), annotationParseFailures.get("reporter"))
+	events, err := client.CoreV1().Events("reporter").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1, "one event until the error changes")
+	assert.Equal(t, "InvalidAnnotation", events.Items[0].Reason)
+	assert.Equal(t, v1.EventTypeWarning, events.Items[0].Type)
+	assert.Contains(t, events.Items[0].Message, "at line 2, column 3")
+
+	// other errors are not counted
+	reporter.report(svc, errors.New("failed to expose"))
+	reporter.report(svc, nil)
+	assert.Equal(t, uint64(2), annotationParseFailures.get("reporter"))
+	assert.Empty(t, reporter.last)
+}
+
+func TestExposeValueReporter(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "reporter",
+			Name:      "svc",
+			Annotations: map[string]string{
+				exposestrategy.ExposeAnnotation.Key: "yes",
+			},
+		},
+	}
+	client := fake.NewSimpleClientset()
+	reporter := newExposeValueReporter(context.Background(), client, nil)
+	reporter.report(svc)
+	reporter.report(svc)
+	events, err := client.CoreV1().Events("reporter").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1, "one event until the value changes")
+	assert.Equal(t, "InvalidExposeValue", events.Items[0].Reason)
+	assert.Equal(t, v1.EventTypeWarning, events.Items[0].Type)
+	assert.Equal(t, `invalid expose value fabric8.io/expose="yes" in service reporter/svc, the accepted values are "true" and "false"`,
+		events.Items[0].Message)
+
+	svc.Annotations[exposestrategy.ExposeAnnotation.Key] = "false"
+	reporter.report(svc)
+	assert.Empty(t, reporter.last)
+	svc.Annotations[exposestrategy.ExposeAnnotation.Key] = "yes"
+	reporter.report(svc)
+	reporter.forget(svc)
+	assert.Empty(t, reporter.last)
+}
+
+func TestCounterVecWrite(t *testing.T) {
+	counter := newCounterVec("test_total", "Test counter.", "namespace")
+	counter.inc("b")
+	counter.inc("a")
+	counter.inc("b")
+	var buffer bytes.Buffer
+	require.NoError(t, counter.write(&buffer))
+	assert.Equal(t, `# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{namespace="a"} 1
+test_total{namespace="b"} 2
+`, buffer.String())
+}
+
+func TestExposeErrorReporter(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "reporter",
+			Name:      "svc",
+		},
+	}
+	client := fake.NewSimpleClientset()
+	clock := clocktesting.NewFakeClock(time.Now())
+	retries := newExposeRetries(context.Background(), make(chan struct{}, 1), clock)
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	require.NoError(t, store.Add(svc))
+	retried := 0
+	retries.store = store
+	retries.handler = cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			retried++
+		},
+	}
+	reporter := newExposeErrorReporter(context.Background(), client, retries)
+	noPorts := errors.Wrap(&exposestrategy.ServiceError{Namespace: "reporter", Service: "svc", Kind: exposestrategy.ErrNoPorts}, "failed to expose")
+	reporter.report(svc, noPorts)
+	reporter.report(svc, noPorts)
+	events, err := client.CoreV1().Events("reporter").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1, "one event until the error changes")
+	assert.Equal(t, "NoPortToExpose", events.Items[0].Reason)
+	clock.Step(exposeRetryPeriod)
+	assert.Equal(t, 0, retried, "not retried")
+
+	reporter.report(svc, &exposestrategy.ServiceError{Kind: exposestrategy.ErrConflict})
+	reporter.report(svc, errors.New("connection refused"))
+	events, err = client.CoreV1().Events("reporter").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, events.Items, 1, "reported by the strategy")
+	clock.Step(exposeRetryPeriod)
+	assert.Equal(t, 1, retried, "retried")
+	assert.Empty(t, reporter.last)
+}
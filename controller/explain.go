@@ -0,0 +1,301 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// explainer prints the steps of the evaluation of a service by the controller
+type explainer struct {
+	out  io.Writer
+	step int
+}
+
+func (e *explainer) printf(format string, args ...interface{}) {
+	e.step++
+	fmt.Fprintf(e.out, "%3d. %s\n", e.step, fmt.Sprintf(format, args...))
+}
+
+// Explain prints step by step how the controller evaluates the service with the config, without changing the cluster:
+// the strategy exposes the service with clients sending its writes as server side dry runs, which need its permissions
+func Explain(ctx context.Context, restConfig *rest.Config, namespace, name string, config *Config, out io.Writer) error {
+	httpClient, err := rest.HTTPClientFor(restConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the HTTP client")
+	}
+	transport := &dryRunTransport{next: httpClient.Transport}
+	if transport.next == nil {
+		transport.next = http.DefaultTransport
+	}
+	httpClient.Transport = transport
+	client, err := kubernetes.NewForConfigAndClient(restConfig, httpClient)
+	if err != nil {
+		return errors.Wrap(err, "failed to create client")
+	}
+	dynamicClient, err := dynamic.NewForConfigAndClient(restConfig, httpClient)
+	if err != nil {
+		return errors.Wrap(err, "failed to create dynamic client")
+	}
+
+	svc, err := client.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get service %s/%s", namespace, name)
+	}
+	fmt.Fprintf(out, "Service %s/%s\n", namespace, name)
+	printExplainAnnotations(out, svc)
+	fmt.Fprintln(out, "Steps:")
+	e := &explainer{out: out}
+
+	exposeValues, err := exposestrategy.NewExposeValues(config.ExposeValues, config.StrictExposeValue)
+	if err != nil {
+		return err
+	}
+	if !exposeValues.IsRequested(svc) {
+		e.printf("not exposed: neither the label %s nor the annotation %s requests it", exposestrategy.ExposeLabel.Key, exposestrategy.ExposeAnnotation.Key)
+		if err := exposeValues.Invalid(svc); err != nil {
+			e.printf("invalid expose value: %s", err)
+		}
+		return nil
+	}
+	e.printf("exposure requested")
+	if !isServiceWhitelisted(svc.Name, config) {
+		e.printf("not exposed: not one of the services of the config %v", config.Services)
+		return nil
+	}
+	if shard := shardOf(config); !shard.Owns(svc.Namespace) {
+		e.printf("not exposed by this controller: namespace %s belongs to another shard than %d", svc.Namespace, shard.Index)
+		return nil
+	}
+
+	defaulted := withNamespaceDefaults(ctx, client, svc, config)
+	added := map[string]string{}
+	for key, value := range defaulted.Annotations {
+		if _, ok := svc.Annotations[key]; !ok {
+			added[key] = value
+		}
+	}
+	if len(added) > 0 {
+		e.printf("namespace defaults: %s", formatExplainAnnotations(added))
+	} else {
+		e.printf("namespace defaults: none")
+	}
+	svc = defaulted
+	// without scheduler, the schedule is checked at the current time
+	var scheduler *exposeScheduler
+	if !scheduler.isExposeActive(svc) {
+		e.printf("not exposed: out of the schedule of annotation %s", ExposeScheduleAnnotationKey)
+		return nil
+	}
+	e.printf("exposer: %s", config.Exposer)
+
+	strategy, err := getStrategy(ctx, client, dynamicClient, namespace, config, nil, nil, exposeValues)
+	if err != nil {
+		return err
+	}
+	err = strategy.Sync()
+	if err != nil {
+		return errors.Wrap(err, "failed to sync the strategy")
+	}
+	synced := len(transport.recorded())
+	addErr := strategy.Add(svc)
+	changes := transport.recorded()[synced:]
+
+	for _, ingress := range explainIngresses(ctx, client, svc, config, changes) {
+		explainIngress(e, ingress)
+	}
+	if url := explainURL(svc, changes); url != "" {
+		e.printf("URL: %s", url)
+	} else {
+		e.printf("URL: none published")
+	}
+	if addErr != nil {
+		e.printf("error: %s", addErr)
+	}
+	explainChanges(out, changes)
+	return nil
+}
+
+// explainIngresses returns the ingresses of the service after its exposure, the ones written by the strategy
+// or left as they are, owned by the service or tracked by its labels
+func explainIngresses(ctx context.Context, client kubernetes.Interface, svc *v1.Service, config *Config, changes []dryRunChange) []*networkingv1.Ingress {
+	// the written ingresses by key in the order of the writes, nil once deleted
+	var keys []string
+	written := map[string]*networkingv1.Ingress{}
+	for _, change := range changes {
+		if change.resource != "ingresses" || (change.object == nil && change.verb != "delete") {
+			continue
+		}
+		key := change.namespace + "/" + change.name
+		if _, ok := written[key]; !ok {
+			keys = append(keys, key)
+		}
+		var ingress *networkingv1.Ingress
+		if change.object != nil {
+			ingress = &networkingv1.Ingress{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(change.object.Object, ingress); err != nil {
+				continue
+			}
+		}
+		written[key] = ingress
+	}
+	namespaces := []string{svc.Namespace}
+	if config.IngressNamespace != "" && config.IngressNamespace != svc.Namespace {
+		namespaces = append(namespaces, config.IngressNamespace)
+	}
+	var candidates []*networkingv1.Ingress
+	for _, namespace := range namespaces {
+		list, err := client.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			continue
+		}
+		for i := range list.Items {
+			if _, ok := written[list.Items[i].Namespace+"/"+list.Items[i].Name]; !ok {
+				candidates = append(candidates, &list.Items[i])
+			}
+		}
+	}
+	for _, key := range keys {
+		if ingress := written[key]; ingress != nil {
+			candidates = append(candidates, ingress)
+		}
+	}
+	var ingresses []*networkingv1.Ingress
+	for _, ingress := range candidates {
+		owned := false
+		for _, owner := range ingress.OwnerReferences {
+			owned = owned || (owner.Kind == exposestrategy.ServiceKind && owner.Name == svc.Name && ingress.Namespace == svc.Namespace)
+		}
+		tracked := ingress.Labels[exposestrategy.ExposedServiceLabelKey] == svc.Name &&
+			(ingress.Namespace == svc.Namespace || ingress.Labels[exposestrategy.ExposedNamespaceLabelKey] == svc.Namespace)
+		if owned || tracked {
+			ingresses = append(ingresses, ingress)
+		}
+	}
+	return ingresses
+}
+
+// explainURL returns the URL of the service written by the strategy, or the published one if left as is
+func explainURL(svc *v1.Service, changes []dryRunChange) string {
+	url := svc.Annotations[exposestrategy.ExposeAnnotationKey]
+	for _, change := range changes {
+		if change.resource == "services" && change.namespace == svc.Namespace && change.name == svc.Name && change.object != nil {
+			url = change.object.GetAnnotations()[exposestrategy.ExposeAnnotationKey]
+		}
+	}
+	return url
+}
+
+// explainIngress prints the port, hosts, name and TLS of the ingress of the service as generated by the strategy
+func explainIngress(e *explainer, ingress *networkingv1.Ingress) {
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service == nil {
+				continue
+			}
+			port := path.Backend.Service.Port.Name
+			if port == "" {
+				port = fmt.Sprint(path.Backend.Service.Port.Number)
+			}
+			e.printf("port: %s of service %s", port, path.Backend.Service.Name)
+			break
+		}
+		break
+	}
+	for _, rule := range ingress.Spec.Rules {
+		paths := []string{}
+		if rule.HTTP != nil {
+			for _, path := range rule.HTTP.Paths {
+				paths = append(paths, path.Path)
+			}
+		}
+		e.printf("host: %s, paths %q", rule.Host, paths)
+	}
+	class := ingress.Annotations["kubernetes.io/ingress.class"]
+	if ingress.Spec.IngressClassName != nil {
+		class = *ingress.Spec.IngressClassName
+	}
+	if class == "" {
+		class = "the default class of the cluster"
+	}
+	e.printf("ingress: %s/%s, ingress class %s", ingress.Namespace, ingress.Name, class)
+	if len(ingress.Spec.TLS) == 0 {
+		e.printf("TLS: none, plain HTTP")
+	}
+	for _, tls := range ingress.Spec.TLS {
+		secret := tls.SecretName
+		if secret == "" {
+			secret = "the default certificate of the ingress controller"
+		} else if ingress.Annotations["kubernetes.io/tls-acme"] == "true" {
+			secret += " issued by ACME"
+		}
+		e.printf("TLS: hosts %v, secret %s", tls.Hosts, secret)
+	}
+}
+
+// explainChanges prints the changes the controller would make
+func explainChanges(out io.Writer, changes []dryRunChange) {
+	fmt.Fprintln(out, "Changes, not applied:")
+	for _, change := range changes {
+		if change.resource == "events" && change.object != nil {
+			reason, _, _ := unstructured.NestedString(change.object.Object, "reason")
+			message, _, _ := unstructured.NestedString(change.object.Object, "message")
+			fmt.Fprintf(out, "  event %s: %s\n", reason, message)
+			continue
+		}
+		fmt.Fprintf(out, "  %s %s %s/%s", change.verb, change.resource, change.namespace, change.name)
+		if change.err != "" {
+			fmt.Fprintf(out, ", rejected: %s", change.err)
+		}
+		fmt.Fprintln(out)
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "  none")
+	}
+}
+
+// printExplainAnnotations prints the expose label and the fabric8.io annotations of the service, sorted by key
+func printExplainAnnotations(out io.Writer, svc *v1.Service) {
+	fmt.Fprintln(out, "Matched annotations:")
+	if value, ok := svc.Labels[exposestrategy.ExposeLabel.Key]; ok {
+		fmt.Fprintf(out, "  label %s: %q\n", exposestrategy.ExposeLabel.Key, value)
+	}
+	keys := []string{}
+	for key := range svc.Annotations {
+		if strings.HasPrefix(key, "fabric8.io/") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(out, "  %s: %q\n", key, svc.Annotations[key])
+	}
+}
+
+// formatExplainAnnotations formats the annotations on one line, sorted by key
+func formatExplainAnnotations(annotations map[string]string) string {
+	values := make([]string, 0, len(annotations))
+	for key, value := range annotations {
+		values = append(values, fmt.Sprintf("%s=%q", key, value))
+	}
+	sort.Strings(values)
+	return strings.Join(values, ", ")
+}
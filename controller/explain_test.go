@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// explainResources are the resources served by the explain test server, by name
+var explainResources = map[string]schema.GroupVersionKind{
+	"namespaces":     v1.SchemeGroupVersion.WithKind("Namespace"),
+	"services":       v1.SchemeGroupVersion.WithKind("Service"),
+	"endpoints":      v1.SchemeGroupVersion.WithKind("Endpoints"),
+	"configmaps":     v1.SchemeGroupVersion.WithKind("ConfigMap"),
+	"secrets":        v1.SchemeGroupVersion.WithKind("Secret"),
+	"events":         v1.SchemeGroupVersion.WithKind("Event"),
+	"ingresses":      networkingv1.SchemeGroupVersion.WithKind("Ingress"),
+	"ingressclasses": networkingv1.SchemeGroupVersion.WithKind("IngressClass"),
+}
+
+// newExplainServer serves the objects as an API server would, the writes must be dry runs and change nothing
+func newExplainServer(t *testing.T, tracker k8stesting.ObjectTracker) *rest.Config {
+	codec := scheme.Codecs.LegacyCodec(v1.SchemeGroupVersion, networkingv1.SchemeGroupVersion)
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		namespace, resource, name := parseResourcePath(req.URL.Path)
+		gvk, ok := explainResources[resource]
+		if !ok {
+			http.NotFound(res, req)
+			return
+		}
+		gvr := gvk.GroupVersion().WithResource(resource)
+		if req.Method != http.MethodGet {
+			assert.Equal(t, "All", req.URL.Query().Get("dryRun"), "dry run of %s %s", req.Method, req.URL.Path)
+		}
+		var obj runtime.Object
+		var err error
+		body, _ := io.ReadAll(req.Body)
+		switch {
+		case req.Method == http.MethodGet && name == "":
+			obj, err = tracker.List(gvr, gvk, namespace)
+		case req.Method == http.MethodGet:
+			obj, err = tracker.Get(gvr, namespace, name)
+		case req.Method == http.MethodPost || req.Method == http.MethodPut:
+			obj, err = runtime.Decode(scheme.Codecs.UniversalDeserializer(), body)
+		case req.Method == http.MethodPatch:
+			obj, err = tracker.Get(gvr, namespace, name)
+			if err == nil {
+				var data []byte
+				data, err = runtime.Encode(codec, obj)
+				if err == nil {
+					empty, _ := scheme.Scheme.New(gvk)
+					data, err = strategicpatch.StrategicMergePatch(data, body, empty)
+				}
+				if err == nil {
+					obj, err = runtime.Decode(scheme.Codecs.UniversalDeserializer(), data)
+				}
+			}
+		default:
+			obj = &metav1.Status{Status: metav1.StatusSuccess}
+		}
+		res.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			status := apierrors.NewInternalError(err).Status()
+			if apiStatus, ok := err.(apierrors.APIStatus); ok {
+				status = apiStatus.Status()
+			}
+			status.Kind, status.APIVersion = "Status", "v1"
+			res.WriteHeader(int(status.Code))
+			_ = json.NewEncoder(res).Encode(status)
+			return
+		}
+		if status, ok := obj.(*metav1.Status); ok {
+			status.Kind, status.APIVersion = "Status", "v1"
+			_ = json.NewEncoder(res).Encode(status)
+			return
+		}
+		_ = codec.Encode(obj, res)
+	}))
+	t.Cleanup(server.Close)
+	return &rest.Config{Host: server.URL}
+}
+
+func TestExplain(t *testing.T) {
+	ctx := context.Background()
+	newService := func(name string, annotations map[string]string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "dev",
+				Name:            name,
+				Annotations:     annotations,
+				ResourceVersion: "1",
+			},
+			Spec: v1.ServiceSpec{Ports: []v1.ServicePort{{Name: "http", Port: 8080}}},
+		}
+	}
+	tracker := k8stesting.NewObjectTracker(scheme.Scheme, scheme.Codecs.UniversalDecoder())
+	for _, svc := range []*v1.Service{
+		newService("myapp", map[string]string{"fabric8.io/expose": "true", "fabric8.io/host.name": "app"}),
+		newService("hidden", nil),
+		newService("bad", map[string]string{"fabric8.io/expose": "true", "fabric8.io/exposePort": "grpc"}),
+		newService("teamapp", map[string]string{"fabric8.io/expose": "true", "fabric8.io/expose.team": "web"}),
+	} {
+		require.NoError(t, tracker.Add(svc))
+	}
+	restConfig := newExplainServer(t, tracker)
+	config := &Config{
+		Exposer: "Ingress",
+		Domain:  "example.com",
+		HTTP:    true,
+		Teams:   map[string]exposestrategy.TeamConfig{"web": {Domain: "web.example.com"}},
+	}
+	explain := func(name string) string {
+		var out bytes.Buffer
+		require.NoError(t, Explain(ctx, restConfig, "dev", name, config, &out))
+		return out.String()
+	}
+
+	out := explain("myapp")
+	assert.Contains(t, out, `fabric8.io/host.name: "app"`)
+	assert.Contains(t, out, "port: 8080 of service myapp")
+	assert.Contains(t, out, `host: app.dev.example.com, paths [""]`)
+	assert.Contains(t, out, "ingress: dev/myapp")
+	assert.Contains(t, out, "TLS: none, plain HTTP")
+	assert.Contains(t, out, "URL: http://app.dev.example.com")
+	assert.Contains(t, out, "create ingresses dev/myapp")
+	assert.Contains(t, out, "patch services dev/myapp")
+	ingresses, err := tracker.List(networkingv1.SchemeGroupVersion.WithResource("ingresses"),
+		networkingv1.SchemeGroupVersion.WithKind("Ingress"), "dev")
+	require.NoError(t, err)
+	assert.Empty(t, ingresses.(*networkingv1.IngressList).Items, "nothing changed in the cluster")
+
+	// the host is the one computed by the strategy, such as on the domain of the team
+	assert.Contains(t, explain("teamapp"), "host: teamapp.dev.web.example.com")
+
+	assert.Contains(t, explain("hidden"), "not exposed")
+	assert.Contains(t, explain("bad"), "error: ")
+}
@@ -0,0 +1,322 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/utils/clock"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+const (
+	// HookExposed is sent when a service gets its first URL
+	HookExposed = "exposed"
+	// HookURLChanged is sent when the URL of a service changes
+	HookURLChanged = "url-changed"
+	// HookUnexposed is sent when a service loses its URL, or is deleted
+	HookUnexposed = "unexposed"
+
+	defaultHookRetries = 3
+	defaultHookBackoff = time.Second
+	defaultHookTimeout = 10 * time.Second
+	hookQueueSize      = 100
+)
+
+// HookEvent is the change of the URL of a service passed to the hooks
+type HookEvent struct {
+	Event     string `json:"event"`
+	Namespace string `json:"namespace"`
+	Service   string `json:"service"`
+	URL       string `json:"url,omitempty"`
+	OldURL    string `json:"old_url,omitempty"`
+}
+
+// ExposeHook registers the URLs of the services into an external system, such as a developer portal or a service catalog
+// the hooks are called in the background after the exposure, an error is retried with a backoff
+type ExposeHook interface {
+	// Name names the hook in the logs
+	Name() string
+	// Exposed is called with the change of the URL of a service
+	Exposed(ctx context.Context, event *HookEvent) error
+}
+
+// HookConfig is a hook calling an HTTP endpoint with the changes of the URLs
+type HookConfig struct {
+	Name string `yaml:"name" json:"name"`
+	URL  string `yaml:"url" json:"url"`
+	// Method is POST by default
+	Method string `yaml:"method,omitempty" json:"method"`
+	// Headers are added to the requests, the environment variables such as "${PORTAL_TOKEN}" are expanded
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers"`
+	// Body is a go template of the body given the HookEvent, the event as JSON if empty
+	Body string `yaml:"body,omitempty" json:"body"`
+	// Events are the events sent among "exposed", "url-changed" and "unexposed", all if empty
+	Events []string `yaml:"events,omitempty" json:"events"`
+	// Retries is the number of retries of a failed call, 3 by default, Backoff is the first delay, doubled on each retry
+	Retries int    `yaml:"retries,omitempty" json:"retries"`
+	Backoff string `yaml:"backoff,omitempty" json:"backoff"`
+	// Timeout is the timeout of a call, 10s by default
+	Timeout string `yaml:"timeout,omitempty" json:"timeout"`
+}
+
+// hookCall is a hook with its retries
+type hookCall struct {
+	hook    ExposeHook
+	events  map[string]bool
+	retries int
+	backoff time.Duration
+}
+
+// exposeHooks calls the hooks in the background with the changes of URLs of the services
+// all the methods do nothing on nil hooks
+type exposeHooks struct {
+	calls []hookCall
+	clock clock.Clock
+
+	queue chan *HookEvent
+	done  chan struct{}
+	// the handlers of the informers may still enqueue while flushing
+	lock   sync.Mutex
+	closed bool
+}
+
+// newExposeHooks creates the hooks of the config and starts calling them, nil without hook
+func newExposeHooks(ctx context.Context, config *Config) (*exposeHooks, error) {
+	if len(config.Hooks) == 0 && len(config.ExposeHooks) == 0 {
+		return nil, nil
+	}
+	h := &exposeHooks{
+		clock: config.clock(),
+		queue: make(chan *HookEvent, hookQueueSize),
+		done:  make(chan struct{}),
+	}
+	for i := range config.Hooks {
+		call, err := newHTTPHookCall(&config.Hooks[i])
+		if err != nil {
+			return nil, err
+		}
+		h.calls = append(h.calls, call)
+	}
+	for _, hook := range config.ExposeHooks {
+		h.calls = append(h.calls, hookCall{hook: hook, retries: defaultHookRetries, backoff: defaultHookBackoff})
+	}
+	go h.run(ctx)
+	return h, nil
+}
+
+// newHTTPHookCall checks the config of the HTTP hook
+func newHTTPHookCall(config *HookConfig) (hookCall, error) {
+	call := hookCall{retries: config.Retries, backoff: defaultHookBackoff}
+	if config.Name == "" || config.URL == "" {
+		return call, errors.New("the hooks require a name and a url")
+	}
+	hook := &httpHook{
+		name:    config.Name,
+		url:     config.URL,
+		method:  config.Method,
+		headers: config.Headers,
+		client:  &http.Client{Timeout: defaultHookTimeout},
+	}
+	if hook.method == "" {
+		hook.method = http.MethodPost
+	}
+	if config.Body != "" {
+		var err error
+		hook.body, err = template.New(config.Name).Parse(config.Body)
+		if err != nil {
+			return call, errors.Wrapf(err, "failed to parse the body template of hook %s", config.Name)
+		}
+	}
+	if config.Timeout != "" {
+		timeout, err := time.ParseDuration(config.Timeout)
+		if err != nil {
+			return call, errors.Wrapf(err, "invalid timeout \"%s\" of hook %s", config.Timeout, config.Name)
+		}
+		hook.client.Timeout = timeout
+	}
+	if config.Backoff != "" {
+		var err error
+		call.backoff, err = time.ParseDuration(config.Backoff)
+		if err != nil {
+			return call, errors.Wrapf(err, "invalid backoff \"%s\" of hook %s", config.Backoff, config.Name)
+		}
+	}
+	if call.retries <= 0 {
+		call.retries = defaultHookRetries
+	}
+	for _, event := range config.Events {
+		switch event {
+		case HookExposed, HookURLChanged, HookUnexposed:
+		default:
+			return call, errors.Errorf("invalid event \"%s\" of hook %s, must be \"%s\", \"%s\" or \"%s\"",
+				event, config.Name, HookExposed, HookURLChanged, HookUnexposed)
+		}
+		if call.events == nil {
+			call.events = map[string]bool{}
+		}
+		call.events[event] = true
+	}
+	call.hook = hook
+	return call, nil
+}
+
+// urlChanged calls the hooks when the URL of the service is set, changed or removed
+func (h *exposeHooks) urlChanged(oldSvc, svc *v1.Service) {
+	if h == nil {
+		return
+	}
+	oldURL := oldSvc.Annotations[exposestrategy.ExposeAnnotationKey]
+	url := svc.Annotations[exposestrategy.ExposeAnnotationKey]
+	if url == oldURL {
+		return
+	}
+	event := &HookEvent{Event: HookURLChanged, Namespace: svc.Namespace, Service: svc.Name, URL: url, OldURL: oldURL}
+	if oldURL == "" {
+		event.Event = HookExposed
+	} else if url == "" {
+		event.Event = HookUnexposed
+	}
+	h.enqueue(event)
+}
+
+// deleted calls the hooks when an exposed service is deleted
+func (h *exposeHooks) deleted(svc *v1.Service) {
+	if h == nil || svc.Annotations[exposestrategy.ExposeAnnotationKey] == "" {
+		return
+	}
+	h.enqueue(&HookEvent{
+		Event:     HookUnexposed,
+		Namespace: svc.Namespace,
+		Service:   svc.Name,
+		OldURL:    svc.Annotations[exposestrategy.ExposeAnnotationKey],
+	})
+}
+
+// enqueue queues the event, it never blocks, the events are dropped once flushed
+func (h *exposeHooks) enqueue(event *HookEvent) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.closed {
+		klog.Warningf("Hooks stopped, dropping the %s event of service %s/%s", event.Event, event.Namespace, event.Service)
+		return
+	}
+	select {
+	case h.queue <- event:
+	default:
+		klog.Warningf("Hook queue full, dropping the %s event of service %s/%s", event.Event, event.Namespace, event.Service)
+	}
+}
+
+func (h *exposeHooks) run(ctx context.Context) {
+	defer close(h.done)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-h.queue:
+			if !ok {
+				return
+			}
+			for _, call := range h.calls {
+				if call.events == nil || call.events[event.Event] {
+					h.call(ctx, call, event)
+				}
+			}
+		}
+	}
+}
+
+// call calls the hook until it succeeds or its retries are exhausted
+func (h *exposeHooks) call(ctx context.Context, call hookCall, event *HookEvent) {
+	backoff := call.backoff
+	for attempt := 0; ; attempt++ {
+		err := call.hook.Exposed(ctx, event)
+		if err == nil {
+			return
+		}
+		if attempt >= call.retries {
+			klog.Errorf("Hook %s failed for the %s event of service %s/%s, giving up: %v",
+				call.hook.Name(), event.Event, event.Namespace, event.Service, err)
+			return
+		}
+		klog.Warningf("Hook %s failed for the %s event of service %s/%s, retrying in %s: %v",
+			call.hook.Name(), event.Event, event.Namespace, event.Service, backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.clock.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// flush calls the hooks with the queued events and stops them
+func (h *exposeHooks) flush() {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.queue)
+	}
+	h.lock.Unlock()
+	<-h.done
+}
+
+// httpHook calls an HTTP endpoint with the event
+type httpHook struct {
+	name    string
+	url     string
+	method  string
+	headers map[string]string
+	body    *template.Template
+	client  *http.Client
+}
+
+func (h *httpHook) Name() string {
+	return h.name
+}
+
+func (h *httpHook) Exposed(ctx context.Context, event *HookEvent) error {
+	var body bytes.Buffer
+	if h.body != nil {
+		err := h.body.Execute(&body, event)
+		if err != nil {
+			return errors.Wrapf(err, "failed to render the body of hook %s", h.name)
+		}
+	} else {
+		err := json.NewEncoder(&body).Encode(event)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode the event of hook %s", h.name)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, h.method, h.url, &body)
+	if err != nil {
+		return errors.Wrapf(err, "failed to build the request of hook %s", h.name)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range h.headers {
+		req.Header.Set(key, os.ExpandEnv(value))
+	}
+	res, err := h.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to call %s", h.url)
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return errors.Errorf("failed to call %s: %s", h.url, res.Status)
+	}
+	return nil
+}
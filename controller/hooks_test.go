@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+type recordingHook struct {
+	lock   sync.Mutex
+	events []HookEvent
+}
+
+func (h *recordingHook) Name() string {
+	return "recording"
+}
+
+func (h *recordingHook) Exposed(ctx context.Context, event *HookEvent) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.events = append(h.events, *event)
+	return nil
+}
+
+func TestExposeHooks(t *testing.T) {
+	ctx := context.Background()
+	os.Setenv("HOOK_TEST_TOKEN", "secret")
+	defer os.Unsetenv("HOOK_TEST_TOKEN")
+
+	var lock sync.Mutex
+	var bodies []string
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.Equal(t, http.MethodPut, r.Method)
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	hooks, err := newExposeHooks(ctx, &Config{})
+	require.NoError(t, err)
+	assert.Nil(t, hooks, "no hook")
+	hooks.urlChanged(&v1.Service{}, &v1.Service{})
+	hooks.flush()
+
+	_, err = newExposeHooks(ctx, &Config{Hooks: []HookConfig{{Name: "portal", URL: server.URL, Events: []string{"created"}}}})
+	assert.Error(t, err)
+	_, err = newExposeHooks(ctx, &Config{Hooks: []HookConfig{{Name: "portal", URL: server.URL, Body: "{{"}}})
+	assert.Error(t, err)
+
+	recording := &recordingHook{}
+	hooks, err = newExposeHooks(ctx, &Config{
+		Hooks: []HookConfig{{
+			Name:    "portal",
+			URL:     server.URL,
+			Method:  http.MethodPut,
+			Headers: map[string]string{"Authorization": "Bearer ${HOOK_TEST_TOKEN}"},
+			Body:    `{{.Service}} {{.URL}}`,
+			Events:  []string{HookExposed, HookURLChanged},
+			Backoff: "1ms",
+		}},
+		ExposeHooks: []ExposeHook{recording},
+	})
+	require.NoError(t, err)
+
+	service := func(url string) *v1.Service {
+		svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "app", Annotations: map[string]string{}}}
+		if url != "" {
+			svc.Annotations[exposestrategy.ExposeAnnotationKey] = url
+		}
+		return svc
+	}
+	hooks.urlChanged(service(""), service("http://app.example.com"))
+	hooks.urlChanged(service("http://app.example.com"), service("http://app.example.com"))
+	hooks.urlChanged(service("http://app.example.com"), service("https://app.example.com"))
+	hooks.deleted(service("https://app.example.com"))
+	hooks.deleted(service(""))
+	hooks.flush()
+
+	assert.Equal(t, 3, calls, "retried once")
+	assert.Equal(t, []string{"app http://app.example.com", "app https://app.example.com"}, bodies, "unexposed filtered")
+	assert.Equal(t, []HookEvent{
+		{Event: HookExposed, Namespace: "main", Service: "app", URL: "http://app.example.com"},
+		{Event: HookURLChanged, Namespace: "main", Service: "app", URL: "https://app.example.com", OldURL: "http://app.example.com"},
+		{Event: HookUnexposed, Namespace: "main", Service: "app", OldURL: "https://app.example.com"},
+	}, recording.events)
+
+	var encoded []HookEvent
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event HookEvent
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		encoded = append(encoded, event)
+	}))
+	defer registry.Close()
+	hooks, err = newExposeHooks(ctx, &Config{Hooks: []HookConfig{{Name: "registry", URL: registry.URL}}})
+	require.NoError(t, err)
+	hooks.deleted(service("http://app.example.com"))
+	hooks.flush()
+	assert.Equal(t, []HookEvent{{Event: HookUnexposed, Namespace: "main", Service: "app", OldURL: "http://app.example.com"}}, encoded)
+
+	// the events of the handlers still running are dropped once flushed
+	assert.NotPanics(t, func() {
+		hooks.deleted(service("http://app.example.com"))
+		hooks.flush()
+	})
+	assert.Len(t, encoded, 1)
+}
+
+// stoppedController returns once stopped
+type stoppedController struct{}
+
+func (stoppedController) Run(stopCh <-chan struct{}) {
+	<-stopCh
+}
+
+func (stoppedController) HasSynced() bool {
+	return true
+}
+
+func (stoppedController) LastSyncResourceVersion() string {
+	return ""
+}
+
+func TestDaemonController_flushHooks(t *testing.T) {
+	recording := &recordingHook{}
+	hooks, err := newExposeHooks(context.Background(), &Config{ExposeHooks: []ExposeHook{recording}})
+	require.NoError(t, err)
+	hooks.urlChanged(&v1.Service{}, &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "main",
+			Name:        "app",
+			Annotations: map[string]string{exposestrategy.ExposeAnnotationKey: "http://app.example.com"},
+		},
+	})
+	stop := make(chan struct{})
+	close(stop)
+	contr := &daemonController{Controller: stoppedController{}, hooks: hooks}
+	contr.Run(stop)
+	assert.Equal(t, []HookEvent{{Event: HookExposed, Namespace: "main", Service: "app", URL: "http://app.example.com"}}, recording.events)
+}
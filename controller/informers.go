@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// sharedInformers are the informers of the services and ingresses shared between the controller and the strategy
+// the informer of the services is the one of the controller, its list syncs the strategy
+type sharedInformers struct {
+	factory informers.SharedInformerFactory
+	// listWatch is set by the controller before the informers are started
+	listWatch *cache.ListWatch
+	services  cache.SharedIndexInformer
+	// nil if the ingresses of the strategy are not all in the watched namespaces
+	ingresses cache.SharedIndexInformer
+	stop      <-chan struct{}
+}
+
+// newSharedInformers returns nil unless the informers are shared
+func newSharedInformers(client kubernetes.Interface, namespace string, config *Config, resyncPeriod time.Duration) *sharedInformers {
+	if !config.SharedInformers {
+		return nil
+	}
+	s := &sharedInformers{
+		factory:   informers.NewSharedInformerFactoryWithOptions(client, resyncPeriod, informers.WithNamespace(namespace)),
+		listWatch: &cache.ListWatch{},
+	}
+	s.services = s.factory.InformerFor(&v1.Service{}, func(kubernetes.Interface, time.Duration) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(s.listWatch, &v1.Service{}, resyncPeriod,
+			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	})
+	if namespace == "" || config.IngressNamespace == "" || config.IngressNamespace == namespace {
+		s.ingresses = s.factory.Networking().V1().Ingresses().Informer()
+	}
+	return s
+}
+
+// serviceLister returns nil if the informers are not shared
+func (s *sharedInformers) serviceLister() corelisters.ServiceLister {
+	if s == nil {
+		return nil
+	}
+	return corelisters.NewServiceLister(s.services.GetIndexer())
+}
+
+// ingressLister returns nil if the ingresses are not shared
+func (s *sharedInformers) ingressLister() networkinglisters.IngressLister {
+	if s == nil || s.ingresses == nil {
+		return nil
+	}
+	return networkinglisters.NewIngressLister(s.ingresses.GetIndexer())
+}
+
+// controller returns the controller running the shared informers with the list watch and handlers of the services
+// the services are listed once the other caches are synced, so that the strategy reads all the objects
+func (s *sharedInformers) controller(listWatch *cache.ListWatch, handlers cache.ResourceEventHandler) (cache.Store, cache.Controller) {
+	*s.listWatch = *listWatch
+	s.listWatch.ListFunc = func(options metav1.ListOptions) (runtime.Object, error) {
+		if s.ingresses != nil && !cache.WaitForCacheSync(s.stop, s.ingresses.HasSynced) {
+			return nil, errors.New("stopped before the ingresses cache is synced")
+		}
+		return listWatch.ListFunc(options)
+	}
+	_, _ = s.services.AddEventHandler(handlers)
+	return s.services.GetStore(), &sharedController{SharedIndexInformer: s.services, informers: s}
+}
+
+// cacheStatus tells which caches are synced, by resource
+func (s *sharedInformers) cacheStatus() map[string]bool {
+	status := map[string]bool{"services": s.services.HasSynced()}
+	if s.ingresses != nil {
+		status["ingresses"] = s.ingresses.HasSynced()
+	}
+	return status
+}
+
+// sharedController runs all the shared informers, it has synced once all their caches are
+type sharedController struct {
+	cache.SharedIndexInformer
+	informers *sharedInformers
+}
+
+func (c *sharedController) Run(stopCh <-chan struct{}) {
+	c.informers.stop = stopCh
+	c.informers.factory.Start(stopCh)
+	<-stopCh
+	c.informers.factory.Shutdown()
+}
+
+func (c *sharedController) HasSynced() bool {
+	for _, synced := range c.informers.cacheStatus() {
+		if !synced {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemon_sharedInformers(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc1",
+			Annotations: map[string]string{
+				exposestrategy.ExposeAnnotation.Key: exposestrategy.ExposeAnnotation.Value,
+			},
+			ResourceVersion: "1",
+		},
+	}, &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "chart",
+		},
+	})
+	strategy := fakeStrategy{
+		testing: t,
+		tasks: []map[string]bool{{
+			"Sync": true,
+		}, {
+			"Add:main/svc1:1": true,
+		}},
+	}
+	testStrategy = &strategy
+	defer func() {
+		testStrategy = nil
+	}()
+
+	controller, err := Daemon(ctx, client, nil, "main", &Config{SharedInformers: true}, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, controller.HasSynced())
+	assert.Equal(t, map[string]bool{"services": false, "ingresses": false}, controller.CacheStatus())
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+	go controller.Run(stopChan)
+
+	time.Sleep(500 * time.Millisecond)
+	strategy.checkEnd()
+	assert.True(t, controller.HasSynced())
+	assert.Equal(t, map[string]bool{"services": true, "ingresses": true}, controller.CacheStatus())
+}
+
+func TestSharedInformers(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	assert.Nil(t, newSharedInformers(client, "main", &Config{}, time.Hour), "not shared by default")
+	var shared *sharedInformers
+	assert.Nil(t, shared.serviceLister())
+	assert.Nil(t, shared.ingressLister())
+
+	shared = newSharedInformers(client, "main", &Config{SharedInformers: true, IngressNamespace: "ingresses"}, time.Hour)
+	assert.NotNil(t, shared.serviceLister())
+	assert.Nil(t, shared.ingressLister(), "the ingress namespace is not watched")
+	assert.Equal(t, map[string]bool{"services": false}, shared.cacheStatus())
+
+	shared = newSharedInformers(client, "", &Config{SharedInformers: true, IngressNamespace: "ingresses"}, time.Hour)
+	lister := shared.ingressLister()
+	if assert.NotNil(t, lister) {
+		ingresses, err := lister.List(labels.Everything())
+		assert.NoError(t, err)
+		assert.Empty(t, ingresses)
+	}
+}
@@ -0,0 +1,249 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/clock"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+const (
+	// ingressHealthInterval is how often the workloads of the ingress controller are checked
+	ingressHealthInterval  = time.Minute
+	ingressReadyMetric     = "exposecontroller_ingress_controller_ready"
+	ingressReadyPodsMetric = "exposecontroller_ingress_controller_ready_pods"
+	ingressReadyHelp       = "Whether the ingress controller of the ingress class has a ready pod, 1 if ready."
+	ingressReadyPodsHelp   = "Number of ready pods of the deployments and daemon sets of the ingress controller of the ingress class."
+)
+
+// ingressControllerWorkloadSelectors are the labels of the deployments and daemon sets of the well-known ingress controllers
+// by the controller of their ingress class, as installed by their charts
+var ingressControllerWorkloadSelectors = map[string]string{
+	"k8s.io/ingress-nginx":                   "app.kubernetes.io/name=ingress-nginx,app.kubernetes.io/component=controller",
+	"nginx.org/ingress-controller":           "app.kubernetes.io/name=nginx-ingress",
+	"traefik.io/ingress-controller":          "app.kubernetes.io/name=traefik",
+	"haproxy.org/ingress-controller/haproxy": "app.kubernetes.io/name=kubernetes-ingress",
+	"projectcontour.io/ingress-controller":   "app.kubernetes.io/name=contour",
+}
+
+// IngressControllerStatus is the health of the ingress controller of the ingress class of the generated ingresses
+type IngressControllerStatus struct {
+	IngressClass string `json:"ingressClass,omitempty"`
+	Controller   string `json:"controller,omitempty"`
+	// Workloads are the matching deployments and daemon sets, as "kind namespace/name"
+	Workloads []string  `json:"workloads,omitempty"`
+	ReadyPods int32     `json:"readyPods"`
+	Ready     bool      `json:"ready"`
+	Message   string    `json:"message,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// ingressHealthCheck checks that the ingress controller of the ingress class has a ready pod,
+// so that the services exposed but unreachable because the ingress controller is down are diagnosed
+// the changes emit events on the ingress class, the methods do nothing on a nil check
+type ingressHealthCheck struct {
+	ctx          context.Context
+	client       kubernetes.Interface
+	clock        clock.Clock
+	ingressClass string
+	selector     string
+
+	lock   sync.Mutex
+	status *IngressControllerStatus
+}
+
+// ingressHealth is the health check of the daemon, its metrics are written once checked
+var ingressHealth *ingressHealthCheck
+var ingressHealthLock sync.Mutex
+
+// newIngressHealthCheck returns the health check of the ingress controller, nil if not configured
+func newIngressHealthCheck(ctx context.Context, client kubernetes.Interface, config *Config) (*ingressHealthCheck, error) {
+	if !config.IngressControllerHealthCheck {
+		return nil, nil
+	}
+	if config.PermissionProfile == exposestrategy.PermissionProfileNamespace {
+		return nil, errors.New("the ingress controller health check requires the cluster permission profile, the ingress controllers are in other namespaces")
+	}
+	return &ingressHealthCheck{
+		ctx:          ctx,
+		client:       client,
+		clock:        config.clock(),
+		ingressClass: config.IngressClass,
+		selector:     config.IngressControllerSelector,
+	}, nil
+}
+
+// current returns the last status, nil if not checked yet
+func (h *ingressHealthCheck) current() *IngressControllerStatus {
+	if h == nil {
+		return nil
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.status
+}
+
+// run checks the ingress controller until the context is done
+func (h *ingressHealthCheck) run(ctx context.Context) {
+	if h == nil {
+		return
+	}
+	ingressHealthLock.Lock()
+	ingressHealth = h
+	ingressHealthLock.Unlock()
+	h.check()
+	timer := h.clock.NewTimer(ingressHealthInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+			h.check()
+			timer.Reset(ingressHealthInterval)
+		}
+	}
+}
+
+// check reads the workloads of the ingress controller and emits an event if its health changed,
+// the status is kept when the API server cannot be read
+func (h *ingressHealthCheck) check() {
+	status, class, err := h.read()
+	if err != nil {
+		klog.Warningf("Could not check the ingress controller: %v", err)
+		if h.current() != nil {
+			return
+		}
+		status.Message = err.Error()
+	}
+	h.lock.Lock()
+	previous := h.status
+	h.status = status
+	h.lock.Unlock()
+	if previous != nil && previous.Ready == status.Ready {
+		return
+	}
+	if !status.Ready {
+		klog.Warningf("The ingress controller is not ready, the exposed services are unreachable: %s", status.Message)
+		h.emitEvent(class, v1.EventTypeWarning, "IngressControllerUnavailable", status.Message)
+	} else if previous != nil {
+		klog.Infof("The ingress controller %s of ingress class %s is ready again", status.Controller, status.IngressClass)
+		h.emitEvent(class, v1.EventTypeNormal, "IngressControllerAvailable",
+			fmt.Sprintf("The ingress controller %s has %d ready pods", status.Controller, status.ReadyPods))
+	}
+}
+
+// read returns the status of the ingress controller and the name of its ingress class, empty if not installed
+func (h *ingressHealthCheck) read() (*IngressControllerStatus, string, error) {
+	status := &IngressControllerStatus{IngressClass: h.ingressClass, CheckedAt: h.clock.Now()}
+	classes, err := h.client.NetworkingV1().IngressClasses().List(h.ctx, metav1.ListOptions{})
+	if err != nil {
+		return status, "", errors.Wrap(err, "failed to list the ingress classes")
+	}
+	class := exposestrategy.InstalledIngressClass(classes.Items, h.ingressClass)
+	if class == nil {
+		status.Message = "no ingress class installed for the generated ingresses"
+		if h.ingressClass != "" {
+			status.Message = fmt.Sprintf("ingress class %s is not installed", h.ingressClass)
+		}
+		return status, "", nil
+	}
+	status.IngressClass = class.Name
+	status.Controller = class.Spec.Controller
+	selector := h.selector
+	if selector == "" {
+		selector = ingressControllerWorkloadSelectors[class.Spec.Controller]
+	}
+	if selector == "" {
+		status.Message = fmt.Sprintf("the workloads of controller %s of ingress class %s are unknown, configure ingress-controller-selector",
+			class.Spec.Controller, class.Name)
+		return status, class.Name, nil
+	}
+	options := metav1.ListOptions{LabelSelector: selector}
+	deployments, err := h.client.AppsV1().Deployments(metav1.NamespaceAll).List(h.ctx, options)
+	if err != nil {
+		return status, class.Name, errors.Wrap(err, "failed to list the deployments of the ingress controller")
+	}
+	daemonSets, err := h.client.AppsV1().DaemonSets(metav1.NamespaceAll).List(h.ctx, options)
+	if err != nil {
+		return status, class.Name, errors.Wrap(err, "failed to list the daemon sets of the ingress controller")
+	}
+	for _, d := range deployments.Items {
+		status.Workloads = append(status.Workloads, "deployment "+d.Namespace+"/"+d.Name)
+		status.ReadyPods += d.Status.ReadyReplicas
+	}
+	for _, ds := range daemonSets.Items {
+		status.Workloads = append(status.Workloads, "daemonset "+ds.Namespace+"/"+ds.Name)
+		status.ReadyPods += ds.Status.NumberReady
+	}
+	sort.Strings(status.Workloads)
+	status.Ready = status.ReadyPods > 0
+	if len(status.Workloads) == 0 {
+		status.Message = fmt.Sprintf("no deployment or daemon set of controller %s found with labels %s", class.Spec.Controller, selector)
+	} else if !status.Ready {
+		status.Message = fmt.Sprintf("the ingress controller %s has no ready pod", class.Spec.Controller)
+	}
+	return status, class.Name, nil
+}
+
+// emitEvent emits an event on the ingress class, cluster scoped, in the default namespace, errors are only logged
+func (h *ingressHealthCheck) emitEvent(class, eventType, reason, message string) {
+	if class == "" {
+		return
+	}
+	now := metav1.Now()
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: metav1.NamespaceDefault,
+			Name:      fmt.Sprintf("%s.%x", class, now.UnixNano()),
+		},
+		InvolvedObject: v1.ObjectReference{
+			Kind:       "IngressClass",
+			APIVersion: "networking.k8s.io/v1",
+			Name:       class,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         v1.EventSource{Component: "exposecontroller"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err := h.client.CoreV1().Events(metav1.NamespaceDefault).Create(h.ctx, event, metav1.CreateOptions{})
+	if err != nil {
+		klog.Warningf("Failed to emit event %s on ingress class %s: %v", reason, class, err)
+	}
+}
+
+// writeIngressHealth writes the metrics of the ingress controller once checked
+func writeIngressHealth(w io.Writer) error {
+	ingressHealthLock.Lock()
+	status := ingressHealth.current()
+	ingressHealthLock.Unlock()
+	if status == nil {
+		return nil
+	}
+	ready := 0
+	if status.Ready {
+		ready = 1
+	}
+	labels := fmt.Sprintf("{ingress_class=%s,controller=%s}", strconv.Quote(status.IngressClass), strconv.Quote(status.Controller))
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s%s %d\n# HELP %s %s\n# TYPE %s gauge\n%s%s %d\n",
+		ingressReadyMetric, ingressReadyHelp, ingressReadyMetric, ingressReadyMetric, labels, ready,
+		ingressReadyPodsMetric, ingressReadyPodsHelp, ingressReadyPodsMetric, ingressReadyPodsMetric, labels, status.ReadyPods)
+	return err
+}
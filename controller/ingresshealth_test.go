@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressHealthCheck(t *testing.T) {
+	ctx := context.Background()
+	class := &networkingv1.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "nginx",
+			Annotations: map[string]string{"ingressclass.kubernetes.io/is-default-class": "true"},
+		},
+		Spec: networkingv1.IngressClassSpec{Controller: "k8s.io/ingress-nginx"},
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ingress-nginx",
+			Name:      "ingress-nginx-controller",
+			Labels:    map[string]string{"app.kubernetes.io/name": "ingress-nginx", "app.kubernetes.io/component": "controller"},
+		},
+	}
+	client := fake.NewSimpleClientset(class, deployment)
+	clock := clocktesting.NewFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	health, err := newIngressHealthCheck(ctx, client, &Config{})
+	require.NoError(t, err)
+	assert.Nil(t, health, "disabled")
+	assert.Nil(t, health.current())
+	_, err = newIngressHealthCheck(ctx, client, &Config{IngressControllerHealthCheck: true, PermissionProfile: "namespace"})
+	assert.Error(t, err)
+
+	health, err = newIngressHealthCheck(ctx, client, &Config{IngressControllerHealthCheck: true, Clock: clock})
+	require.NoError(t, err)
+	health.check()
+	status := health.current()
+	require.NotNil(t, status)
+	assert.False(t, status.Ready)
+	assert.Equal(t, "nginx", status.IngressClass)
+	assert.Equal(t, []string{"deployment ingress-nginx/ingress-nginx-controller"}, status.Workloads)
+	assert.Equal(t, "the ingress controller k8s.io/ingress-nginx has no ready pod", status.Message)
+
+	deployment.Status.ReadyReplicas = 2
+	_, err = client.AppsV1().Deployments("ingress-nginx").Update(ctx, deployment, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	health.check()
+	status = health.current()
+	assert.True(t, status.Ready)
+	assert.Equal(t, int32(2), status.ReadyPods)
+	health.check()
+
+	events, err := client.CoreV1().Events(metav1.NamespaceDefault).List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	reasons := map[string]string{}
+	for _, event := range events.Items {
+		assert.Equal(t, "nginx", event.InvolvedObject.Name)
+		reasons[event.Reason] = event.Type
+	}
+	assert.Equal(t, map[string]string{
+		"IngressControllerUnavailable": v1.EventTypeWarning,
+		"IngressControllerAvailable":   v1.EventTypeNormal,
+	}, reasons, "once by change")
+
+	ingressHealthLock.Lock()
+	ingressHealth = health
+	ingressHealthLock.Unlock()
+	defer func() {
+		ingressHealthLock.Lock()
+		ingressHealth = nil
+		ingressHealthLock.Unlock()
+	}()
+	var metrics bytes.Buffer
+	require.NoError(t, writeIngressHealth(&metrics))
+	assert.Contains(t, metrics.String(), `exposecontroller_ingress_controller_ready{ingress_class="nginx",controller="k8s.io/ingress-nginx"} 1`)
+	assert.Contains(t, metrics.String(), `exposecontroller_ingress_controller_ready_pods{ingress_class="nginx",controller="k8s.io/ingress-nginx"} 2`)
+
+	unknown, err := newIngressHealthCheck(ctx, fake.NewSimpleClientset(), &Config{IngressControllerHealthCheck: true, IngressClass: "traefik"})
+	require.NoError(t, err)
+	unknown.check()
+	assert.Equal(t, "ingress class traefik is not installed", unknown.current().Message)
+}
@@ -0,0 +1,309 @@
+package controller
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+// ExtraManifestsAnnotationKey annotation of a service references the "config-map/key" holding the manifests
+// applied alongside its ingress, such as traefik middlewares or istio authorization policies, in YAML format
+const ExtraManifestsAnnotationKey = "fabric8.io/expose.manifests-from"
+
+// extraManifests applies the manifests referenced by the exposed services in their namespace,
+// only the kinds of the config are applied as the services could otherwise create anything the controller can
+// the applied objects are labeled with their service, to delete those removed from the manifests and the others on unexpose
+type extraManifests struct {
+	ctx           context.Context
+	client        kubernetes.Interface
+	dynamicClient dynamic.Interface
+	mapper        *restmapper.DeferredDiscoveryRESTMapper
+	config        *Config
+	kinds         []schema.GroupKind
+}
+
+// parseManifestKind parses a "Kind.group" kind, the kinds of the core group have no group
+func parseManifestKind(text string) (schema.GroupKind, error) {
+	gk := schema.ParseGroupKind(text)
+	if gk.Kind == "" || strings.ToUpper(gk.Kind[:1]) != gk.Kind[:1] {
+		return gk, errors.Errorf("invalid manifest kind \"%s\", must be \"Kind.group\"", text)
+	}
+	return gk, nil
+}
+
+// newExtraManifests returns nil if no kind is allowed
+func newExtraManifests(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, config *Config) (*extraManifests, error) {
+	if len(config.ExtraManifestKinds) == 0 {
+		return nil, nil
+	}
+	if dynamicClient == nil {
+		return nil, errors.New("a dynamic client is required to apply extra manifests")
+	}
+	m := &extraManifests{
+		ctx:           ctx,
+		client:        client,
+		dynamicClient: dynamicClient,
+		mapper:        restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(client.Discovery())),
+		config:        config,
+	}
+	for _, text := range config.ExtraManifestKinds {
+		gk, err := parseManifestKind(text)
+		if err != nil {
+			return nil, err
+		}
+		m.kinds = append(m.kinds, gk)
+	}
+	sort.Slice(m.kinds, func(i, j int) bool {
+		return m.kinds[i].String() < m.kinds[j].String()
+	})
+	return m, nil
+}
+
+// isAllowed tells if the kind is one of the config
+func (m *extraManifests) isAllowed(gk schema.GroupKind) bool {
+	for _, kind := range m.kinds {
+		if kind == gk {
+			return true
+		}
+	}
+	return false
+}
+
+// resource returns the namespaced resource of the kind
+func (m *extraManifests) resource(gk schema.GroupKind) (schema.GroupVersionResource, error) {
+	mapping, err := m.mapper.RESTMapping(gk)
+	// the kinds installed since the last discovery, such as the CRDs of a chart, are discovered again
+	if meta.IsNoMatchError(err) {
+		m.mapper.Reset()
+		mapping, err = m.mapper.RESTMapping(gk)
+	}
+	if err != nil {
+		return schema.GroupVersionResource{}, errors.Wrapf(err, "failed to find the resource of kind %s", gk)
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return schema.GroupVersionResource{}, errors.Errorf("kind %s is not namespaced", gk)
+	}
+	return mapping.Resource, nil
+}
+
+// parseManifests parses the manifests applied for the service in its namespace, they are all rejected if one is invalid
+func (m *extraManifests) parseManifests(svc *v1.Service, text string) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	decoder := utilyaml.NewYAMLOrJSONDecoder(strings.NewReader(text), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		err := decoder.Decode(&obj.Object)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "invalid manifests for service %s/%s", svc.Namespace, svc.Name)
+		}
+		if len(obj.Object) == 0 {
+			// an empty document
+			continue
+		}
+		gk := obj.GroupVersionKind().GroupKind()
+		if obj.GetAPIVersion() == "" || gk.Kind == "" || obj.GetName() == "" {
+			return nil, errors.Errorf("manifest %d for service %s/%s has no apiVersion, kind or name", len(objects)+1, svc.Namespace, svc.Name)
+		}
+		if !m.isAllowed(gk) {
+			return nil, errors.Errorf("kind %s of manifest %s for service %s/%s is not one of the extra manifest kinds",
+				gk, obj.GetName(), svc.Namespace, svc.Name)
+		}
+		if obj.GetNamespace() != "" && obj.GetNamespace() != svc.Namespace {
+			return nil, errors.Errorf("manifest %s %s for service %s/%s must be in the namespace of the service",
+				gk.Kind, obj.GetName(), svc.Namespace, svc.Name)
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// readManifests reads the manifests referenced by the annotation of the service
+func (m *extraManifests) readManifests(svc *v1.Service, from string) ([]*unstructured.Unstructured, error) {
+	parts := strings.SplitN(from, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.Errorf("annotation \"%s\" in service %s/%s must be \"config-map/key\", got \"%s\"",
+			ExtraManifestsAnnotationKey, svc.Namespace, svc.Name, from)
+	}
+	cm, err := m.client.CoreV1().ConfigMaps(svc.Namespace).Get(m.ctx, parts[0], metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get config map %s/%s referenced by service %s",
+			svc.Namespace, parts[0], svc.Name)
+	}
+	text, ok := cm.Data[parts[1]]
+	if !ok {
+		return nil, errors.Errorf("config map %s/%s referenced by service %s has no key \"%s\"",
+			svc.Namespace, parts[0], svc.Name, parts[1])
+	}
+	return m.parseManifests(svc, text)
+}
+
+// apply creates or updates the manifests of an exposed service, and deletes the objects removed from them
+func (m *extraManifests) apply(svc *v1.Service) error {
+	if m == nil {
+		return nil
+	}
+	var objects []*unstructured.Unstructured
+	if from := svc.Annotations[ExtraManifestsAnnotationKey]; from != "" {
+		var err error
+		objects, err = m.readManifests(svc, from)
+		if err != nil {
+			return err
+		}
+	}
+	skipOwnerRefs := exposestrategy.SkipOwnerReferences(svc,
+		m.config.SetOwnerReferences != nil && !*m.config.SetOwnerReferences)
+	provider := providerLabel(m.config)
+	applied := map[string]bool{}
+	for _, obj := range objects {
+		obj.SetNamespace(svc.Namespace)
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[provider.Key] = provider.Value
+		labels[exposestrategy.ExposedServiceLabelKey] = svc.Name
+		obj.SetLabels(labels)
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[exposestrategy.GeneratedByAnnotationKey] = provider.Marker()
+		obj.SetAnnotations(annotations)
+		if !skipOwnerRefs {
+			obj.SetOwnerReferences([]metav1.OwnerReference{{
+				Kind:       exposestrategy.ServiceKind,
+				APIVersion: exposestrategy.ServiceAPIVersion,
+				Name:       svc.Name,
+				UID:        svc.UID,
+			}})
+		}
+		err := m.applyObject(obj)
+		if err != nil {
+			return err
+		}
+		applied[obj.GroupVersionKind().GroupKind().String()+"/"+obj.GetName()] = true
+	}
+	return m.prune(svc, applied)
+}
+
+// applyObject creates or updates an object of the manifests
+func (m *extraManifests) applyObject(obj *unstructured.Unstructured) error {
+	gk := obj.GroupVersionKind().GroupKind()
+	resource, err := m.resource(gk)
+	if err != nil {
+		return err
+	}
+	objects := m.dynamicClient.Resource(resource).Namespace(obj.GetNamespace())
+	existing, err := objects.Get(m.ctx, obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.Infof("Creating %s %s/%s", gk.Kind, obj.GetNamespace(), obj.GetName())
+		_, err = objects.Create(m.ctx, obj, metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create %s %s/%s", gk.Kind, obj.GetNamespace(), obj.GetName())
+		}
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "could not check for existing %s %s/%s", gk.Kind, obj.GetNamespace(), obj.GetName())
+	}
+	if !providerLabel(m.config).IsGenerated(existing.GetAnnotations()) {
+		return errors.Errorf("%s %s/%s already exists and was not generated by exposecontroller",
+			gk.Kind, obj.GetNamespace(), obj.GetName())
+	}
+	if isManifestUpToDate(obj, existing) {
+		return nil
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	klog.Infof("Updating %s %s/%s", gk.Kind, obj.GetNamespace(), obj.GetName())
+	_, err = objects.Update(m.ctx, obj, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to update %s %s/%s", gk.Kind, obj.GetNamespace(), obj.GetName())
+	}
+	return nil
+}
+
+// isManifestUpToDate compares the content of the object outside of its metadata and status, and its managed metadata
+func isManifestUpToDate(obj, existing *unstructured.Unstructured) bool {
+	for key, value := range obj.Object {
+		if key != "metadata" && key != "status" && !reflect.DeepEqual(value, existing.Object[key]) {
+			return false
+		}
+	}
+	for key := range existing.Object {
+		if _, ok := obj.Object[key]; !ok && key != "metadata" && key != "status" {
+			return false
+		}
+	}
+	return reflect.DeepEqual(obj.GetLabels(), existing.GetLabels()) &&
+		reflect.DeepEqual(obj.GetAnnotations(), existing.GetAnnotations()) &&
+		reflect.DeepEqual(obj.GetOwnerReferences(), existing.GetOwnerReferences())
+}
+
+// prune deletes the objects of the allowed kinds applied for the service and not in the applied ones,
+// all of them when the service is unexposed
+func (m *extraManifests) prune(svc *v1.Service, applied map[string]bool) error {
+	if m == nil {
+		return nil
+	}
+	var err error
+	for _, gk := range m.kinds {
+		resource, resourceErr := m.resource(gk)
+		if resourceErr != nil {
+			// such as a kind which is not installed
+			klog.V(2).Infof("Not pruning the %s manifests of service %s/%s: %v", gk, svc.Namespace, svc.Name, resourceErr)
+			continue
+		}
+		provider := providerLabel(m.config)
+		objects := m.dynamicClient.Resource(resource).Namespace(svc.Namespace)
+		list, listErr := objects.List(m.ctx, metav1.ListOptions{
+			LabelSelector: provider.Key + "=" + provider.Value + "," + exposestrategy.ExposedServiceLabelKey + "=" + svc.Name,
+		})
+		if listErr != nil {
+			err = errors.Wrapf(listErr, "failed to list the %s manifests of service %s/%s", gk, svc.Namespace, svc.Name)
+			continue
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if applied[gk.String()+"/"+obj.GetName()] || !provider.IsGenerated(obj.GetAnnotations()) {
+				continue
+			}
+			if m.config.NeverDelete {
+				released := obj.DeepCopy()
+				exposestrategy.ReleaseObject(released, provider)
+				klog.Warningf("Not deleting %s %s/%s, it is released with label %s=true",
+					gk.Kind, obj.GetNamespace(), obj.GetName(), exposestrategy.RetainedLabelKey)
+				_, releaseErr := objects.Update(m.ctx, released, metav1.UpdateOptions{})
+				if releaseErr != nil {
+					err = errors.Wrapf(releaseErr, "failed to release %s %s/%s", gk.Kind, obj.GetNamespace(), obj.GetName())
+				}
+				continue
+			}
+			klog.Infof("Deleting %s %s/%s", gk.Kind, obj.GetNamespace(), obj.GetName())
+			deleteErr := objects.Delete(m.ctx, obj.GetName(), deleteOptions(m.config))
+			if deleteErr != nil && !apierrors.IsNotFound(deleteErr) {
+				err = errors.Wrapf(deleteErr, "failed to delete %s %s/%s", gk.Kind, obj.GetNamespace(), obj.GetName())
+			}
+		}
+	}
+	return err
+}
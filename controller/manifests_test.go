@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var middlewareResource = schema.GroupVersionResource{Group: "traefik.io", Version: "v1alpha1", Resource: "middlewares"}
+
+func newManifestsClients(objects ...runtime.Object) (*fake.Clientset, *dynamicfake.FakeDynamicClient) {
+	client := fake.NewSimpleClientset(objects...)
+	client.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{{
+		GroupVersion: "traefik.io/v1alpha1",
+		APIResources: []metav1.APIResource{{Name: "middlewares", Kind: "Middleware", Namespaced: true}},
+	}, {
+		GroupVersion: "cert-manager.io/v1",
+		APIResources: []metav1.APIResource{{Name: "clusterissuers", Kind: "ClusterIssuer"}},
+	}}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			middlewareResource: "MiddlewareList",
+		})
+	return client, dynamicClient
+}
+
+func TestExtraManifests(t *testing.T) {
+	ctx := context.Background()
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			UID:       "svc-uid",
+			Annotations: map[string]string{
+				ExtraManifestsAnnotationKey: "manifests/traefik",
+			},
+		},
+	}
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "manifests"},
+		Data: map[string]string{
+			"traefik": `apiVersion: traefik.io/v1alpha1
+kind: Middleware
+metadata:
+  name: strip-prefix
+spec:
+  stripPrefix:
+    prefixes: [/api]
+---
+apiVersion: traefik.io/v1alpha1
+kind: Middleware
+metadata:
+  name: auth
+  labels:
+    team: payments
+spec:
+  basicAuth:
+    secret: auth
+`,
+		},
+	}
+	client, dynamicClient := newManifestsClients(svc, cm)
+	manifests, err := newExtraManifests(ctx, client, dynamicClient, &Config{ExtraManifestKinds: []string{"Middleware.traefik.io"}})
+	require.NoError(t, err)
+	require.NoError(t, manifests.apply(svc))
+
+	middlewares := dynamicClient.Resource(middlewareResource).Namespace("main")
+	auth, err := middlewares.Get(ctx, "auth", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"team":                                 "payments",
+		exposestrategy.LegacyProviderLabel.Key: exposestrategy.LegacyProviderLabel.Value,
+		exposestrategy.ExposedServiceLabelKey:  "svc",
+	}, auth.GetLabels())
+	assert.Equal(t, "exposecontroller", auth.GetAnnotations()["fabric8.io/generated-by"])
+	assert.Equal(t, []metav1.OwnerReference{{
+		APIVersion: "v1",
+		Kind:       "Service",
+		Name:       "svc",
+		UID:        "svc-uid",
+	}}, auth.GetOwnerReferences())
+	_, err = middlewares.Get(ctx, "strip-prefix", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	// applying again changes nothing
+	dynamicClient.ClearActions()
+	require.NoError(t, manifests.apply(svc))
+	for _, action := range dynamicClient.Actions() {
+		assert.Contains(t, []string{"get", "list"}, action.GetVerb())
+	}
+
+	// the objects removed from the manifests are deleted
+	cm.Data["traefik"] = `apiVersion: traefik.io/v1alpha1
+kind: Middleware
+metadata:
+  name: auth
+spec:
+  basicAuth:
+    secret: other-auth
+`
+	_, err = client.CoreV1().ConfigMaps("main").Update(ctx, cm, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, manifests.apply(svc))
+	_, err = middlewares.Get(ctx, "strip-prefix", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "strip-prefix deleted")
+	auth, err = middlewares.Get(ctx, "auth", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"basicAuth": map[string]interface{}{"secret": "other-auth"}}, auth.Object["spec"])
+
+	// all of them when the service is unexposed
+	require.NoError(t, manifests.prune(svc, nil))
+	_, err = middlewares.Get(ctx, "auth", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "auth deleted")
+}
+
+func TestExtraManifests_resourceInstalledLater(t *testing.T) {
+	client, dynamicClient := newManifestsClients()
+	manifests, err := newExtraManifests(context.Background(), client, dynamicClient, &Config{ExtraManifestKinds: []string{"Middleware.traefik.io"}})
+	require.NoError(t, err)
+	discovery := client.Discovery().(*fakediscovery.FakeDiscovery)
+	installed := discovery.Resources
+	discovery.Resources = installed[1:]
+	_, err = manifests.resource(schema.GroupKind{Group: "traefik.io", Kind: "Middleware"})
+	assert.Error(t, err)
+
+	// the CRD is installed after the first discovery
+	discovery.Resources = installed
+	resource, err := manifests.resource(schema.GroupKind{Group: "traefik.io", Kind: "Middleware"})
+	require.NoError(t, err)
+	assert.Equal(t, middlewareResource, resource)
+}
+
+func TestExtraManifests_invalid(t *testing.T) {
+	ctx := context.Background()
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "main",
+			Name:        "svc",
+			Annotations: map[string]string{},
+		},
+	}
+	manifest := func(apiVersion, kind, name, namespace string) string {
+		return "apiVersion: " + apiVersion + "\nkind: " + kind + "\nmetadata:\n  name: " + name + "\n  namespace: " + namespace + "\n"
+	}
+	examples := []struct {
+		name      string
+		from      string
+		manifests string
+		err       string
+	}{{
+		name: "no key",
+		from: "manifests",
+		err:  `annotation "fabric8.io/expose.manifests-from" in service main/svc must be "config-map/key", got "manifests"`,
+	}, {
+		name: "missing key",
+		from: "manifests/other",
+		err:  `config map main/manifests referenced by service svc has no key "other"`,
+	}, {
+		name:      "invalid YAML",
+		manifests: "kind: [",
+		err:       "invalid manifests for service main/svc: error converting YAML to JSON: yaml: line 1: did not find expected node content",
+	}, {
+		name:      "no name",
+		manifests: "apiVersion: traefik.io/v1alpha1\nkind: Middleware\n",
+		err:       "manifest 1 for service main/svc has no apiVersion, kind or name",
+	}, {
+		name:      "denied kind",
+		manifests: manifest("rbac.authorization.k8s.io/v1", "RoleBinding", "admin", ""),
+		err:       "kind RoleBinding.rbac.authorization.k8s.io of manifest admin for service main/svc is not one of the extra manifest kinds",
+	}, {
+		name:      "other namespace",
+		manifests: manifest("traefik.io/v1alpha1", "Middleware", "auth", "other"),
+		err:       "manifest Middleware auth for service main/svc must be in the namespace of the service",
+	}, {
+		name:      "cluster scoped",
+		manifests: manifest("cert-manager.io/v1", "ClusterIssuer", "issuer", ""),
+		err:       "kind ClusterIssuer.cert-manager.io is not namespaced",
+	}}
+	for _, example := range examples {
+		cm := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "manifests"},
+			Data:       map[string]string{"manifests": example.manifests},
+		}
+		client, dynamicClient := newManifestsClients(cm)
+		manifests, err := newExtraManifests(ctx, client, dynamicClient, &Config{
+			ExtraManifestKinds: []string{"Middleware.traefik.io", "ClusterIssuer.cert-manager.io"},
+		})
+		require.NoError(t, err)
+		svc.Annotations[ExtraManifestsAnnotationKey] = example.from
+		if example.from == "" {
+			svc.Annotations[ExtraManifestsAnnotationKey] = "manifests/manifests"
+		}
+		assert.EqualError(t, manifests.apply(svc), example.err, example.name)
+	}
+}
+
+func TestNewExtraManifests(t *testing.T) {
+	manifests, err := newExtraManifests(context.Background(), fake.NewSimpleClientset(), nil, &Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, manifests, "no manifest applied")
+	assert.NoError(t, manifests.apply(&v1.Service{}))
+	assert.NoError(t, manifests.prune(&v1.Service{}, nil))
+
+	_, err = newExtraManifests(context.Background(), fake.NewSimpleClientset(), nil, &Config{ExtraManifestKinds: []string{"Middleware.traefik.io"}})
+	assert.EqualError(t, err, "a dynamic client is required to apply extra manifests")
+	_, err = newExtraManifests(context.Background(), fake.NewSimpleClientset(), newFakeDynamicClient(), &Config{ExtraManifestKinds: []string{"middlewares.traefik.io"}})
+	assert.EqualError(t, err, `invalid manifest kind "middlewares.traefik.io", must be "Kind.group"`)
+}
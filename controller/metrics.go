@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// counterVec is a counter by label value, written in the Prometheus text format
+type counterVec struct {
+	name  string
+	help  string
+	label string
+
+	lock   sync.Mutex
+	values map[string]uint64
+}
+
+func newCounterVec(name, help, label string) *counterVec {
+	return &counterVec{
+		name:   name,
+		help:   help,
+		label:  label,
+		values: map[string]uint64{},
+	}
+}
+
+func (c *counterVec) inc(value string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.values[value]++
+}
+
+func (c *counterVec) get(value string) uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.values[value]
+}
+
+func (c *counterVec) write(w io.Writer) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	if err != nil {
+		return err
+	}
+	values := make([]string, 0, len(c.values))
+	for value := range c.values {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	for _, value := range values {
+		_, err = fmt.Fprintf(w, "%s{%s=%s} %d\n", c.name, c.label, strconv.Quote(value), c.values[value])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gauge is a value written in the Prometheus text format
+type gauge struct {
+	name string
+	help string
+
+	lock  sync.Mutex
+	value float64
+}
+
+func newGauge(name, help string) *gauge {
+	return &gauge{
+		name: name,
+		help: help,
+	}
+}
+
+func (g *gauge) set(value float64) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.value = value
+}
+
+func (g *gauge) get() float64 {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.value
+}
+
+func (g *gauge) write(w io.Writer) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", g.name, g.help, g.name, g.name, g.value)
+	return err
+}
+
+// annotationParseFailures counts the annotations of the services that cannot be parsed
+var annotationParseFailures = newCounterVec("exposecontroller_annotation_parse_failures_total",
+	"Number of failures to parse the annotations of the exposed services.", "namespace")
+
+// WriteMetrics writes the metrics of the controller in the Prometheus text format
+func WriteMetrics(w io.Writer) error {
+	for _, c := range []*counterVec{annotationParseFailures, exposeQuotaRefusals} {
+		err := c.write(w)
+		if err != nil {
+			return err
+		}
+	}
+	err := exposureAges.write(w)
+	if err != nil {
+		return err
+	}
+	err = writeIngressHealth(w)
+	if err != nil {
+		return err
+	}
+	return pausedGauge.write(w)
+}
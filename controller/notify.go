@@ -0,0 +1,271 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/util/flowcontrol"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+const (
+	// NotificationExposed is sent when a service is exposed for the first time
+	NotificationExposed = "exposed"
+	// NotificationURLChanged is sent when the exposed URL of a service changes
+	NotificationURLChanged = "url-changed"
+	// NotificationFailed is sent when exposing a service fails repeatedly
+	NotificationFailed = "failed"
+
+	defaultFailureThreshold = 3
+	defaultRateLimit        = 10
+	notificationQueueSize   = 100
+
+	defaultNotificationTemplate = `{{ if eq .Event "exposed" -}}
+Service {{ .Namespace }}/{{ .Service }} is exposed at {{ .URL }}
+{{- else if eq .Event "url-changed" -}}
+Service {{ .Namespace }}/{{ .Service }} moved from {{ .OldURL }} to {{ .URL }}
+{{- else -}}
+Exposing service {{ .Namespace }}/{{ .Service }} failed {{ .Failures }} times: {{ .Error }}
+{{- end }}`
+)
+
+// NotificationsConfig configures the notifications on exposure changes
+type NotificationsConfig struct {
+	WebhookURL      string `yaml:"webhook-url,omitempty" json:"webhook_url"`
+	SlackWebhookURL string `yaml:"slack-webhook-url,omitempty" json:"slack_webhook_url"`
+	// Template is a go template of the message
+	Template string `yaml:"template,omitempty" json:"template"`
+	// FailureThreshold is the number of consecutive failures before notifying, defaults to 3
+	FailureThreshold int `yaml:"failure-threshold,omitempty" json:"failure_threshold"`
+	// RateLimit is the maximum number of notifications per minute, defaults to 10
+	RateLimit int `yaml:"rate-limit,omitempty" json:"rate_limit"`
+}
+
+type notification struct {
+	Event     string `json:"event"`
+	Namespace string `json:"namespace"`
+	Service   string `json:"service"`
+	URL       string `json:"url,omitempty"`
+	OldURL    string `json:"old_url,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Failures  int    `json:"failures,omitempty"`
+	Message   string `json:"message"`
+}
+
+type notificationSink interface {
+	send(ctx context.Context, n *notification) error
+}
+
+// notifier sends the notifications in the background
+// all the methods do nothing on a nil notifier
+type notifier struct {
+	sinks     []notificationSink
+	message   *template.Template
+	threshold int
+	limiter   flowcontrol.PassiveRateLimiter
+
+	lock     sync.Mutex
+	failures map[string]int
+
+	queue chan *notification
+	done  chan struct{}
+}
+
+// newNotifier creates the notifier and starts sending the notifications
+// returns nil if the config is nil
+func newNotifier(ctx context.Context, config *NotificationsConfig) (*notifier, error) {
+	if config == nil {
+		return nil, nil
+	}
+	n := &notifier{
+		threshold: config.FailureThreshold,
+		failures:  map[string]int{},
+		queue:     make(chan *notification, notificationQueueSize),
+		done:      make(chan struct{}),
+	}
+	if config.WebhookURL != "" {
+		n.sinks = append(n.sinks, &webhookNotifier{url: config.WebhookURL, client: http.DefaultClient})
+	}
+	if config.SlackWebhookURL != "" {
+		n.sinks = append(n.sinks, &slackNotifier{url: config.SlackWebhookURL, client: http.DefaultClient})
+	}
+	if len(n.sinks) == 0 {
+		return nil, errors.New("the notifications require a webhook url or a slack webhook url")
+	}
+	text := config.Template
+	if text == "" {
+		text = defaultNotificationTemplate
+	}
+	var err error
+	n.message, err = template.New("notification").Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the notification template")
+	}
+	if n.threshold <= 0 {
+		n.threshold = defaultFailureThreshold
+	}
+	rateLimit := config.RateLimit
+	if rateLimit <= 0 {
+		rateLimit = defaultRateLimit
+	}
+	n.limiter = flowcontrol.NewTokenBucketPassiveRateLimiter(float32(rateLimit)/60, rateLimit)
+
+	go n.run(ctx)
+	return n, nil
+}
+
+// urlChanged notifies when the service gets its first URL or a different one
+func (n *notifier) urlChanged(oldSvc, svc *v1.Service) {
+	if n == nil {
+		return
+	}
+	oldURL := oldSvc.Annotations[exposestrategy.ExposeAnnotationKey]
+	url := svc.Annotations[exposestrategy.ExposeAnnotationKey]
+	if url == "" || url == oldURL {
+		return
+	}
+	event := NotificationExposed
+	if oldURL != "" {
+		event = NotificationURLChanged
+	}
+	n.enqueue(&notification{
+		Event:     event,
+		Namespace: svc.Namespace,
+		Service:   svc.Name,
+		URL:       url,
+		OldURL:    oldURL,
+	})
+}
+
+// exposeResult counts the consecutive failures to expose the service
+// notifies once when the threshold is reached
+func (n *notifier) exposeResult(svc *v1.Service, err error) {
+	if n == nil {
+		return
+	}
+	key := fmt.Sprintf("%s/%s", svc.Namespace, svc.Name)
+	n.lock.Lock()
+	if err == nil {
+		delete(n.failures, key)
+		n.lock.Unlock()
+		return
+	}
+	n.failures[key]++
+	failures := n.failures[key]
+	n.lock.Unlock()
+	if failures != n.threshold {
+		return
+	}
+	n.enqueue(&notification{
+		Event:     NotificationFailed,
+		Namespace: svc.Namespace,
+		Service:   svc.Name,
+		Error:     err.Error(),
+		Failures:  failures,
+	})
+}
+
+// enqueue renders the message and queues the notification, it never blocks
+func (n *notifier) enqueue(notif *notification) {
+	if !n.limiter.TryAccept() {
+		klog.Warningf("Notification rate limit reached, dropping the %s notification of service %s/%s",
+			notif.Event, notif.Namespace, notif.Service)
+		return
+	}
+	var buffer bytes.Buffer
+	err := n.message.Execute(&buffer, notif)
+	if err != nil {
+		klog.Errorf("Failed to render the %s notification of service %s/%s: %v",
+			notif.Event, notif.Namespace, notif.Service, err)
+		return
+	}
+	notif.Message = strings.TrimSpace(buffer.String())
+	select {
+	case n.queue <- notif:
+	default:
+		klog.Warningf("Notification queue full, dropping the %s notification of service %s/%s",
+			notif.Event, notif.Namespace, notif.Service)
+	}
+}
+
+func (n *notifier) run(ctx context.Context) {
+	defer close(n.done)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notif, ok := <-n.queue:
+			if !ok {
+				return
+			}
+			for _, sink := range n.sinks {
+				err := sink.send(ctx, notif)
+				if err != nil {
+					klog.Errorf("Failed to send the %s notification of service %s/%s: %v",
+						notif.Event, notif.Namespace, notif.Service, err)
+				}
+			}
+		}
+	}
+}
+
+// flush sends the queued notifications and stops the notifier
+func (n *notifier) flush() {
+	if n == nil {
+		return
+	}
+	close(n.queue)
+	<-n.done
+}
+
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (s *webhookNotifier) send(ctx context.Context, n *notification) error {
+	return postJSON(ctx, s.client, s.url, n)
+}
+
+type slackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (s *slackNotifier) send(ctx context.Context, n *notification) error {
+	return postJSON(ctx, s.client, s.url, map[string]string{
+		"text": n.Message,
+	})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the notification")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "failed to build the notification request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to post the notification to %s", url)
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return errors.Errorf("failed to post the notification to %s: %s", url, res.Status)
+	}
+	return nil
+}
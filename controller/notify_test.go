@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newNotifiedService(url string) *v1.Service {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "main",
+			Name:        "svc",
+			Annotations: map[string]string{},
+		},
+	}
+	if url != "" {
+		svc.Annotations[exposestrategy.ExposeAnnotationKey] = url
+	}
+	return svc
+}
+
+func TestNotifier(t *testing.T) {
+	webhook := make(chan notification, 10)
+	slack := make(chan map[string]string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		if req.URL.Path == "/slack" {
+			var message map[string]string
+			assert.NoError(t, json.Unmarshal(body, &message))
+			slack <- message
+		} else {
+			var n notification
+			assert.NoError(t, json.Unmarshal(body, &n))
+			webhook <- n
+		}
+	}))
+	defer server.Close()
+
+	n, err := newNotifier(context.Background(), &NotificationsConfig{
+		WebhookURL:       server.URL + "/webhook",
+		SlackWebhookURL:  server.URL + "/slack",
+		FailureThreshold: 2,
+	})
+	require.NoError(t, err)
+
+	n.urlChanged(newNotifiedService(""), newNotifiedService(""))
+	n.urlChanged(newNotifiedService(""), newNotifiedService("http://svc.main.my-domain.com"))
+	n.urlChanged(newNotifiedService("http://svc.main.my-domain.com"), newNotifiedService("http://svc.main.my-domain.com"))
+	n.urlChanged(newNotifiedService("http://svc.main.my-domain.com"), newNotifiedService("https://svc.main.my-domain.com"))
+	svc := newNotifiedService("")
+	n.exposeResult(svc, errors.New("boom"))
+	n.exposeResult(svc, errors.New("boom"))
+	n.exposeResult(svc, errors.New("boom"))
+	n.exposeResult(svc, nil)
+	n.flush()
+
+	require.Len(t, webhook, 3)
+	assert.Equal(t, notification{
+		Event:     NotificationExposed,
+		Namespace: "main",
+		Service:   "svc",
+		URL:       "http://svc.main.my-domain.com",
+		Message:   "Service main/svc is exposed at http://svc.main.my-domain.com",
+	}, <-webhook)
+	assert.Equal(t, notification{
+		Event:     NotificationURLChanged,
+		Namespace: "main",
+		Service:   "svc",
+		URL:       "https://svc.main.my-domain.com",
+		OldURL:    "http://svc.main.my-domain.com",
+		Message:   "Service main/svc moved from http://svc.main.my-domain.com to https://svc.main.my-domain.com",
+	}, <-webhook)
+	assert.Equal(t, notification{
+		Event:     NotificationFailed,
+		Namespace: "main",
+		Service:   "svc",
+		Error:     "boom",
+		Failures:  2,
+		Message:   "Exposing service main/svc failed 2 times: boom",
+	}, <-webhook)
+	require.Len(t, slack, 3)
+	assert.Equal(t, map[string]string{
+		"text": "Service main/svc is exposed at http://svc.main.my-domain.com",
+	}, <-slack)
+}
+
+func TestNotifier_rateLimit(t *testing.T) {
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		received <- string(body)
+	}))
+	defer server.Close()
+
+	n, err := newNotifier(context.Background(), &NotificationsConfig{
+		SlackWebhookURL: server.URL,
+		Template:        "{{ .Event }} {{ .URL }}",
+		RateLimit:       2,
+	})
+	require.NoError(t, err)
+	for _, url := range []string{"http://a", "http://b", "http://c"} {
+		n.urlChanged(newNotifiedService(""), newNotifiedService(url))
+	}
+	n.flush()
+
+	require.Len(t, received, 2)
+	assert.Equal(t, `{"text":"exposed http://a"}`, <-received)
+	assert.Equal(t, `{"text":"exposed http://b"}`, <-received)
+}
+
+func TestNewNotifier(t *testing.T) {
+	n, err := newNotifier(context.Background(), nil)
+	assert.NoError(t, err)
+	n.urlChanged(newNotifiedService(""), newNotifiedService("http://a"))
+	n.exposeResult(newNotifiedService(""), errors.New("boom"))
+	n.flush()
+
+	_, err = newNotifier(context.Background(), &NotificationsConfig{})
+	assert.Error(t, err, "no sink")
+	_, err = newNotifier(context.Background(), &NotificationsConfig{
+		WebhookURL: "http://localhost",
+		Template:   "{{ .Event",
+	})
+	assert.Error(t, err, "invalid template")
+}
@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PauseKey is the key of the pause config map pausing the controller when "true"
+const PauseKey = "paused"
+
+// pausedGauge tells if the controller is paused by its pause config map
+var pausedGauge = newGauge("exposecontroller_paused",
+	"Whether the controller is paused by its pause config map, 1 if paused.")
+
+// pauseSwitch stops all the mutations of the controller while the pause config map is set
+// the services are listed again once unpaused, the missed changes being reconciled then
+// all the methods do nothing on a nil switch
+type pauseSwitch struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	resync    func()
+
+	lock   sync.Mutex
+	paused bool
+}
+
+// newPauseSwitch reads the "namespace/name" pause config map, nil without config map
+func newPauseSwitch(ctx context.Context, client kubernetes.Interface, configMap string, resync chan struct{}) (*pauseSwitch, error) {
+	if configMap == "" {
+		return nil, nil
+	}
+	parts := strings.SplitN(configMap, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.Errorf("invalid pause config map \"%s\", must be \"namespace/name\"", configMap)
+	}
+	p := &pauseSwitch{
+		client:    client,
+		namespace: parts[0],
+		name:      parts[1],
+		resync: func() {
+			if resync == nil {
+				return
+			}
+			select {
+			case resync <- struct{}{}:
+			default:
+			}
+		},
+	}
+	cm, err := client.CoreV1().ConfigMaps(p.namespace).Get(ctx, p.name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, "failed to get the pause config map %s", configMap)
+	}
+	if err == nil {
+		p.update(cm)
+	} else {
+		pausedGauge.set(0)
+	}
+	return p, nil
+}
+
+// isPaused tells if the mutations are stopped
+func (p *pauseSwitch) isPaused() bool {
+	if p == nil {
+		return false
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.paused
+}
+
+// update pauses or resumes the controller from the config map, nil if deleted
+func (p *pauseSwitch) update(cm *v1.ConfigMap) {
+	paused := cm != nil && cm.Data[PauseKey] == "true"
+	p.lock.Lock()
+	changed := p.paused != paused
+	p.paused = paused
+	p.lock.Unlock()
+	if paused {
+		pausedGauge.set(1)
+	} else {
+		pausedGauge.set(0)
+	}
+	if !changed {
+		return
+	} else if paused {
+		klog.Warningf("Paused by config map %s/%s, the services are not reconciled", p.namespace, p.name)
+	} else {
+		klog.Infof("Resumed by config map %s/%s, listing the services again", p.namespace, p.name)
+		p.resync()
+	}
+}
+
+// run watches the pause config map until the context is done
+func (p *pauseSwitch) run(ctx context.Context) {
+	if p == nil {
+		return
+	}
+	configMaps := p.client.CoreV1().ConfigMaps(p.namespace)
+	selector := fields.OneTermEqualSelector("metadata.name", p.name).String()
+	watchList := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return configMaps.List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return configMaps.Watch(ctx, options)
+		},
+	}
+	_, controller := cache.NewInformer(watchList, &v1.ConfigMap{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			p.update(obj.(*v1.ConfigMap))
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			p.update(newObj.(*v1.ConfigMap))
+		},
+		DeleteFunc: func(obj interface{}) {
+			p.update(nil)
+		},
+	})
+	controller.Run(ctx.Done())
+}
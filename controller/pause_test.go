@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemon_pause(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pauseConfigMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "ops",
+			Name:            "exposecontroller-pause",
+			ResourceVersion: "1",
+		},
+		Data: map[string]string{
+			PauseKey: "true",
+		},
+	}
+	client := fake.NewSimpleClientset(pauseConfigMap, &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc1",
+			Annotations: map[string]string{
+				exposestrategy.ExposeAnnotation.Key: exposestrategy.ExposeAnnotation.Value,
+			},
+			ResourceVersion: "1",
+		},
+	})
+	// nothing is synced nor reconciled while paused
+	strategy := fakeStrategy{
+		testing: t,
+	}
+	testStrategy = &strategy
+	defer func() {
+		testStrategy = nil
+	}()
+
+	config := &Config{PauseConfigMap: "ops/exposecontroller-pause"}
+	controller, err := Daemon(ctx, client, nil, "main", config, time.Hour)
+	require.NoError(t, err)
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+	go controller.Run(stopChan)
+
+	time.Sleep(500 * time.Millisecond)
+	strategy.checkEnd()
+	assert.Equal(t, float64(1), pausedGauge.get())
+
+	// the services are listed again once resumed
+	strategy.tasks = []map[string]bool{{
+		"Sync": true,
+	}, {
+		"Add:main/svc1:1": true,
+	}}
+	pauseConfigMap.Data[PauseKey] = "false"
+	pauseConfigMap.ResourceVersion = "2"
+	_, err = client.CoreV1().ConfigMaps("ops").Update(ctx, pauseConfigMap, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	time.Sleep(2 * time.Second)
+	strategy.checkEnd()
+	assert.Equal(t, float64(0), pausedGauge.get())
+
+	err = Run(ctx, client, nil, "main", &Config{PauseConfigMap: "exposecontroller-pause"}, time.Second)
+	assert.EqualError(t, err, `invalid pause config map "exposecontroller-pause", must be "namespace/name"`)
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+// exposeQuotaRefusals counts the exposures refused by the quota of the namespaces
+var exposeQuotaRefusals = newCounterVec("exposecontroller_expose_quota_refusals_total",
+	"Number of exposures refused because the namespace exposes too many services.", "namespace")
+
+// exposeQuota limits the number of services exposed by namespace
+// all the methods are called from the handlers of the informer
+type exposeQuota struct {
+	ctx       context.Context
+	client    kubernetes.Interface
+	max       int
+	scheduler *exposeScheduler
+	// the exposed services by namespace
+	exposed map[string]map[string]bool
+	// the refused services by namespace, an event is emitted once until they are exposed
+	refused map[string]map[string]bool
+}
+
+// newExposeQuota returns nil if the exposed services are not limited
+func newExposeQuota(ctx context.Context, client kubernetes.Interface, max int, scheduler *exposeScheduler) *exposeQuota {
+	if max <= 0 {
+		return nil
+	}
+	return &exposeQuota{
+		ctx:       ctx,
+		client:    client,
+		max:       max,
+		scheduler: scheduler,
+		exposed:   map[string]map[string]bool{},
+		refused:   map[string]map[string]bool{},
+	}
+}
+
+// canExpose tells if the service can be exposed without exceeding the quota of its namespace
+// an already exposed service stays exposed
+func (q *exposeQuota) canExpose(svc *v1.Service) bool {
+	if q == nil {
+		return true
+	}
+	exposed := q.exposed[svc.Namespace]
+	_, alreadyExposed := svc.Annotations[exposestrategy.ExposeAnnotationKey]
+	if exposed[svc.Name] || alreadyExposed || len(exposed) < q.max {
+		if exposed == nil {
+			exposed = map[string]bool{}
+			q.exposed[svc.Namespace] = exposed
+		}
+		exposed[svc.Name] = true
+		delete(q.refused[svc.Namespace], svc.Name)
+		return true
+	}
+	refused := q.refused[svc.Namespace]
+	if refused == nil {
+		refused = map[string]bool{}
+		q.refused[svc.Namespace] = refused
+	}
+	if !refused[svc.Name] {
+		message := fmt.Sprintf("The service is not exposed, namespace %s already exposes %d services", svc.Namespace, len(exposed))
+		klog.Warningf("Service %s/%s: %s", svc.Namespace, svc.Name, message)
+		exposestrategy.EmitServiceEvent(q.ctx, q.client, svc, v1.EventTypeWarning, "ExposeQuotaExceeded", message)
+		exposeQuotaRefusals.inc(svc.Namespace)
+	}
+	refused[svc.Name] = true
+	return false
+}
+
+// forget is called when the service is not exposed anymore
+// the refused services are reconsidered once a service of their namespace is not exposed anymore
+func (q *exposeQuota) forget(svc *v1.Service) {
+	if q == nil {
+		return
+	}
+	delete(q.refused[svc.Namespace], svc.Name)
+	if !q.exposed[svc.Namespace][svc.Name] {
+		return
+	}
+	delete(q.exposed[svc.Namespace], svc.Name)
+	if len(q.refused[svc.Namespace]) > 0 {
+		q.scheduler.scheduleAfter(0)
+	}
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExposeQuota(t *testing.T) {
+	newService := func(namespace, name string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      name,
+			},
+		}
+	}
+	client := fake.NewSimpleClientset()
+	resync := make(chan struct{}, 1)
+	clock := clocktesting.NewFakeClock(time.Now())
+	quota := newExposeQuota(nil, client, 2, newExposeScheduler(resync, clock))
+	refusals := exposeQuotaRefusals.get("previews")
+
+	preview1 := newService("previews", "preview1")
+	preview2 := newService("previews", "preview2")
+	preview3 := newService("previews", "preview3")
+	assert.True(t, quota.canExpose(preview1))
+	assert.True(t, quota.canExpose(preview2))
+	assert.False(t, quota.canExpose(preview3), "quota exceeded")
+	assert.False(t, quota.canExpose(preview3), "quota exceeded")
+	assert.True(t, quota.canExpose(preview1), "already counted")
+	assert.True(t, quota.canExpose(newService("main", "app")), "other namespace")
+	exposedBefore := newService("previews", "preview4")
+	exposedBefore.Annotations = map[string]string{exposestrategy.ExposeAnnotationKey: "http://preview4.previews.my-domain.com"}
+	assert.True(t, quota.canExpose(exposedBefore), "already exposed")
+	assert.Equal(t, refusals+1, exposeQuotaRefusals.get("previews"), "counted once until exposed")
+
+	events, err := client.CoreV1().Events("previews").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	assert.Equal(t, "ExposeQuotaExceeded", events.Items[0].Reason)
+	assert.Equal(t, "preview3", events.Items[0].InvolvedObject.Name)
+	assert.Equal(t, "The service is not exposed, namespace previews already exposes 2 services", events.Items[0].Message)
+
+	// the refused services are reconsidered once there is room
+	quota.forget(preview1)
+	clock.Step(0)
+	assert.Len(t, resync, 1, "resync when a service is not exposed anymore")
+	assert.False(t, quota.canExpose(preview3), "still exceeded by the already exposed service")
+	quota.forget(exposedBefore)
+	assert.True(t, quota.canExpose(preview3))
+	assert.Empty(t, quota.refused["previews"])
+
+	assert.Nil(t, newExposeQuota(nil, client, 0, nil), "unlimited")
+	var none *exposeQuota
+	assert.True(t, none.canExpose(preview3))
+	none.forget(preview3)
+}
@@ -0,0 +1,223 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+// exposureReportKey is the key of the report in the config map
+const exposureReportKey = "report.yaml"
+
+// exposureReport is the exposure state of the services of a namespace
+type exposureReport struct {
+	Exposed  int                   `yaml:"exposed"`
+	Pending  int                   `yaml:"pending"`
+	Failed   int                   `yaml:"failed"`
+	Services []exposureReportEntry `yaml:"services"`
+}
+
+// exposureReportEntry is the URL or the last error of an exposed service
+type exposureReportEntry struct {
+	Service string `yaml:"service"`
+	URL     string `yaml:"url,omitempty"`
+	Error   string `yaml:"error,omitempty"`
+}
+
+// exposureReporter writes the exposure report config map of each namespace after each sync,
+// for the namespace owners without access to the logs and metrics of the controller
+type exposureReporter struct {
+	ctx    context.Context
+	client kubernetes.Interface
+	config *Config
+	name   string
+	store  cache.Store
+	// exposeValues tell which services of the store request the exposure
+	exposeValues *exposestrategy.ExposeValues
+
+	lock sync.Mutex
+	// publishing serializes the publications, such as of the resyncs
+	publishing sync.Mutex
+	// the last error to expose each service, by service key
+	failures map[string]string
+	// the last report written in each namespace
+	written map[string]string
+}
+
+// newExposureReporter returns nil without report config map name
+func newExposureReporter(ctx context.Context, client kubernetes.Interface, config *Config) *exposureReporter {
+	if config.ExposureReport == "" {
+		return nil
+	}
+	return &exposureReporter{
+		ctx:      ctx,
+		client:   client,
+		config:   config,
+		name:     config.ExposureReport,
+		failures: map[string]string{},
+		written:  map[string]string{},
+	}
+}
+
+// result records the result of the exposure of the service
+func (r *exposureReporter) result(svc *v1.Service, err error) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	key := svc.Namespace + "/" + svc.Name
+	if err == nil {
+		delete(r.failures, key)
+	} else {
+		r.failures[key] = err.Error()
+	}
+}
+
+// forget is called when the service is unexposed
+func (r *exposureReporter) forget(svc *v1.Service) {
+	r.result(svc, nil)
+}
+
+// build builds the reports of the namespaces having exposed services, sorted by service
+func (r *exposureReporter) build() map[string]*exposureReport {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	reports := map[string]*exposureReport{}
+	for _, obj := range r.store.List() {
+		svc := obj.(*v1.Service)
+		if !r.exposeValues.IsRequested(svc) || !isServiceWhitelisted(svc.Name, r.config) {
+			continue
+		}
+		report, ok := reports[svc.Namespace]
+		if !ok {
+			report = &exposureReport{Services: []exposureReportEntry{}}
+			reports[svc.Namespace] = report
+		}
+		entry := exposureReportEntry{
+			Service: svc.Name,
+			URL:     svc.Annotations[exposestrategy.ExposeAnnotationKey],
+			Error:   r.failures[svc.Namespace+"/"+svc.Name],
+		}
+		switch {
+		case entry.Error != "":
+			report.Failed++
+		case entry.URL != "":
+			report.Exposed++
+		default:
+			report.Pending++
+		}
+		report.Services = append(report.Services, entry)
+	}
+	for _, report := range reports {
+		sort.Slice(report.Services, func(i, j int) bool {
+			return report.Services[i].Service < report.Services[j].Service
+		})
+	}
+	return reports
+}
+
+// publish writes the changed reports and removes those of the namespaces without exposed service anymore
+func (r *exposureReporter) publish() {
+	if r == nil || r.store == nil {
+		return
+	}
+	r.publishing.Lock()
+	defer r.publishing.Unlock()
+	reports := r.build()
+	namespaces := make([]string, 0, len(reports))
+	for namespace := range reports {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		data, err := yaml.Marshal(reports[namespace])
+		if err != nil {
+			klog.Errorf("Failed to encode the exposure report of namespace %s: %v", namespace, err)
+			continue
+		}
+		if r.written[namespace] == string(data) {
+			continue
+		}
+		err = r.write(namespace, string(data))
+		if err != nil {
+			klog.Errorf("Exposure report update failed: %v", err)
+			continue
+		}
+		r.written[namespace] = string(data)
+	}
+	for namespace := range r.written {
+		if reports[namespace] != nil {
+			continue
+		}
+		err := r.remove(namespace)
+		if err != nil {
+			klog.Errorf("Exposure report removal failed: %v", err)
+			continue
+		}
+		delete(r.written, namespace)
+	}
+}
+
+// write creates or updates the report config map of the namespace
+func (r *exposureReporter) write(namespace, data string) error {
+	return writeGeneratedConfigMap(r.ctx, r.client, r.config, namespace, r.name, "exposure report", map[string]string{exposureReportKey: data})
+}
+
+// remove deletes the report config map of the namespace, it is kept with NeverDelete
+func (r *exposureReporter) remove(namespace string) error {
+	return removeGeneratedConfigMap(r.ctx, r.client, r.config, namespace, r.name, "exposure report")
+}
+
+// writeGeneratedConfigMap creates or updates a config map generated by the controller,
+// the config maps of another owner are not overwritten, what names it in the errors
+func writeGeneratedConfigMap(ctx context.Context, client kubernetes.Interface, config *Config, namespace, name, what string, data map[string]string) error {
+	configMaps := client.CoreV1().ConfigMaps(namespace)
+	provider := providerLabel(config)
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   namespace,
+			Name:        name,
+			Labels:      map[string]string{provider.Key: provider.Value},
+			Annotations: map[string]string{exposestrategy.GeneratedByAnnotationKey: provider.Marker()},
+		},
+		Data: data,
+	}
+	existing, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+		return errors.Wrapf(err, "failed to create the %s %s/%s", what, namespace, name)
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to get the %s %s/%s", what, namespace, name)
+	}
+	if !provider.IsGenerated(existing.Annotations) {
+		return errors.Errorf("config map %s/%s already exists and was not generated by exposecontroller", namespace, name)
+	}
+	cm.ResourceVersion = existing.ResourceVersion
+	_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	return errors.Wrapf(err, "failed to update the %s %s/%s", what, namespace, name)
+}
+
+// removeGeneratedConfigMap deletes a config map generated by the controller, it is kept with NeverDelete
+func removeGeneratedConfigMap(ctx context.Context, client kubernetes.Interface, config *Config, namespace, name, what string) error {
+	if config.NeverDelete {
+		return nil
+	}
+	err := client.CoreV1().ConfigMaps(namespace).Delete(ctx, name, deleteOptions(config))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete the %s %s/%s", what, namespace, name)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExposureReporter(t *testing.T) {
+	ctx := context.Background()
+	newService := func(namespace, name, url string) *v1.Service {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      name,
+				Annotations: map[string]string{
+					exposestrategy.ExposeAnnotation.Key: exposestrategy.ExposeAnnotation.Value,
+				},
+			},
+		}
+		if url != "" {
+			svc.Annotations[exposestrategy.ExposeAnnotationKey] = url
+		}
+		return svc
+	}
+	web := newService("main", "web", "https://web.main.my-domain.com")
+	api := newService("main", "api", "")
+	broken := newService("main", "broken", "")
+	other := newService("other", "web", "https://web.other.my-domain.com")
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, svc := range []*v1.Service{web, api, broken, other, {ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "internal"}}} {
+		require.NoError(t, store.Add(svc))
+	}
+	client := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "exposure-report"},
+	})
+
+	assert.Nil(t, newExposureReporter(ctx, client, &Config{}), "no report by default")
+	reporter := newExposureReporter(ctx, client, &Config{ExposureReport: "exposure-report"})
+	reporter.store = store
+	reporter.result(broken, errors.New("failed to create ingress"))
+	reporter.publish()
+
+	cm, err := client.CoreV1().ConfigMaps("main").Get(ctx, "exposure-report", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "exposecontroller", cm.Annotations["fabric8.io/generated-by"])
+	assert.Equal(t, map[string]string{exposureReportKey: `exposed: 1
+pending: 1
+failed: 1
+services:
+- service: api
+- service: broken
+  error: failed to create ingress
+- service: web
+  url: https://web.main.my-domain.com
+`}, cm.Data)
+	cm, err = client.CoreV1().ConfigMaps("other").Get(ctx, "exposure-report", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, cm.Data, "the config map of another owner is not overwritten")
+
+	// the unchanged reports are not written again
+	client.ClearActions()
+	reporter.publish()
+	for _, action := range client.Actions() {
+		assert.Equal(t, "get", action.GetVerb(), action.GetNamespace())
+	}
+
+	// the report of a namespace without exposed service is deleted
+	reporter.forget(broken)
+	for _, svc := range []*v1.Service{web, api, broken} {
+		require.NoError(t, store.Delete(svc))
+	}
+	reporter.publish()
+	_, err = client.CoreV1().ConfigMaps("main").Get(ctx, "exposure-report", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "report deleted")
+	assert.Empty(t, reporter.failures)
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
+)
+
+const (
+	// exposeRetryPeriod is how soon a service failing with a retryable error is exposed again,
+	// doubled at each failure up to exposeRetryMaxPeriod
+	exposeRetryPeriod    = time.Minute
+	exposeRetryMaxPeriod = 30 * time.Minute
+)
+
+// exposeRetries exposes again the services failing with a retryable error, each one backing off on its own
+// instead of resyncing all the services
+type exposeRetries struct {
+	ctx     context.Context
+	clock   clock.WithDelayedExecution
+	limiter workqueue.RateLimiter
+	// store and handler are set once the informer is created, the handler is called with the service as is
+	store   cache.Store
+	handler cache.ResourceEventHandler
+
+	lock   sync.Mutex
+	timers map[string]clock.Timer
+}
+
+// newExposeRetries returns nil without resync, a single run does not retry
+func newExposeRetries(ctx context.Context, resync chan struct{}, clock clock.WithDelayedExecution) *exposeRetries {
+	if resync == nil {
+		return nil
+	}
+	return &exposeRetries{
+		ctx:     ctx,
+		clock:   clock,
+		limiter: workqueue.NewItemExponentialFailureRateLimiter(exposeRetryPeriod, exposeRetryMaxPeriod),
+	}
+}
+
+// failed schedules the next exposure of the service unless one is already scheduled
+func (r *exposeRetries) failed(svc *v1.Service) {
+	if r == nil {
+		return
+	}
+	key := svc.Namespace + "/" + svc.Name
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.timers[key] != nil {
+		return
+	}
+	if r.timers == nil {
+		r.timers = map[string]clock.Timer{}
+	}
+	delay := r.limiter.When(key)
+	klog.Infof("Exposing service %s again in %s", key, delay)
+	r.timers[key] = r.clock.AfterFunc(delay, func() {
+		r.lock.Lock()
+		delete(r.timers, key)
+		r.lock.Unlock()
+		r.retry(key)
+	})
+}
+
+// forget resets the backoff of the service, once exposed or failing with an error of its own
+func (r *exposeRetries) forget(svc *v1.Service) {
+	if r == nil {
+		return
+	}
+	key := svc.Namespace + "/" + svc.Name
+	r.limiter.Forget(key)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if timer := r.timers[key]; timer != nil {
+		timer.Stop()
+		delete(r.timers, key)
+	}
+}
+
+// retry calls the handler with the cached service, if it still exists
+func (r *exposeRetries) retry(key string) {
+	if r.ctx.Err() != nil || r.store == nil || r.handler == nil {
+		return
+	}
+	obj, exists, err := r.store.GetByKey(key)
+	if err != nil || !exists {
+		return
+	}
+	r.handler.OnUpdate(obj, obj)
+}
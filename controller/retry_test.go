@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExposeRetries(t *testing.T) {
+	assert.Nil(t, newExposeRetries(context.Background(), nil, nil), "single run")
+
+	newService := func(name string) *v1.Service {
+		return &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: name}}
+	}
+	clock := clocktesting.NewFakeClock(time.Now())
+	retries := newExposeRetries(context.Background(), make(chan struct{}, 1), clock)
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	require.NoError(t, store.Add(newService("failing")))
+	require.NoError(t, store.Add(newService("fine")))
+	var retried []string
+	retries.store = store
+	retries.handler = cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			retried = append(retried, newObj.(*v1.Service).Name)
+		},
+	}
+
+	retries.failed(newService("failing"))
+	retries.failed(newService("failing"))
+	retries.failed(newService("fine"))
+	retries.failed(newService("gone"))
+	retries.forget(newService("fine"))
+	clock.Step(exposeRetryPeriod)
+	assert.Equal(t, []string{"failing"}, retried, "only the failing service is retried, once")
+
+	// the service backs off on its own
+	retries.failed(newService("failing"))
+	clock.Step(exposeRetryPeriod)
+	assert.Len(t, retried, 1, "backing off")
+	clock.Step(exposeRetryPeriod)
+	assert.Len(t, retried, 2, "retried after twice the period")
+
+	retries.forget(newService("failing"))
+	retries.failed(newService("failing"))
+	clock.Step(exposeRetryPeriod)
+	assert.Len(t, retried, 3, "backoff reset")
+}
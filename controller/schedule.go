@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	// the controller image may have no time zone database
+	_ "time/tzdata"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/utils/clock"
+)
+
+// ExposeScheduleAnnotationKey annotation restricts the exposure of a service to a weekly window
+// such as "Mon-Fri 08:00-18:00 Europe/Paris", the time zone defaults to UTC
+const ExposeScheduleAnnotationKey = "fabric8.io/expose.schedule"
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// exposeSchedule is a daily window repeated on some days of the week
+// a window ending before its start ends on the next day
+type exposeSchedule struct {
+	days     [7]bool
+	start    time.Duration
+	end      time.Duration
+	location *time.Location
+}
+
+// parseExposeSchedule parses "<days> <HH:MM>-<HH:MM> [time zone]"
+// the days are comma separated days or ranges of days, such as "Mon-Fri" or "Sat,Sun"
+func parseExposeSchedule(text string) (*exposeSchedule, error) {
+	fields := strings.Fields(text)
+	if len(fields) != 2 && len(fields) != 3 {
+		return nil, errors.Errorf("invalid schedule \"%s\", must be \"<days> <HH:MM>-<HH:MM> [time zone]\"", text)
+	}
+	s := &exposeSchedule{location: time.UTC}
+	for _, item := range strings.Split(fields[0], ",") {
+		bounds := strings.SplitN(item, "-", 2)
+		first, ok := weekdays[strings.ToLower(bounds[0])]
+		if !ok {
+			return nil, errors.Errorf("invalid day \"%s\" in schedule \"%s\"", bounds[0], text)
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, ok = weekdays[strings.ToLower(bounds[1])]
+			if !ok {
+				return nil, errors.Errorf("invalid day \"%s\" in schedule \"%s\"", bounds[1], text)
+			}
+		}
+		// the ranges may wrap around the end of the week
+		for day := first; ; day = (day + 1) % 7 {
+			s.days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	hours := strings.SplitN(fields[1], "-", 2)
+	if len(hours) != 2 {
+		return nil, errors.Errorf("invalid hours \"%s\" in schedule \"%s\", must be <HH:MM>-<HH:MM>", fields[1], text)
+	}
+	var err error
+	s.start, err = parseTimeOfDay(hours[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid schedule \"%s\"", text)
+	}
+	s.end, err = parseTimeOfDay(hours[1])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid schedule \"%s\"", text)
+	}
+	if s.start == s.end {
+		return nil, errors.Errorf("empty window in schedule \"%s\"", text)
+	}
+	if len(fields) == 3 {
+		s.location, err = time.LoadLocation(fields[2])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid time zone in schedule \"%s\"", text)
+		}
+	}
+	return s, nil
+}
+
+// parseTimeOfDay parses "HH:MM", "24:00" being the end of the day
+func parseTimeOfDay(text string) (time.Duration, error) {
+	parts := strings.SplitN(text, ":", 2)
+	if len(parts) != 2 {
+		return 0, errors.Errorf("invalid time \"%s\", must be HH:MM", text)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 || hours > 24 {
+		return 0, errors.Errorf("invalid hours in \"%s\"", text)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || len(parts[1]) != 2 || minutes < 0 || minutes > 59 || (hours == 24 && minutes != 0) {
+		return 0, errors.Errorf("invalid minutes in \"%s\"", text)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// window returns the window starting on the day of the given date
+// the times of the day skipped or repeated by daylight saving changes follow the normalization of time.Date
+func (s *exposeSchedule) window(year int, month time.Month, day int) (time.Time, time.Time) {
+	at := func(day int, d time.Duration) time.Time {
+		return time.Date(year, month, day, int(d/time.Hour), int(d%time.Hour/time.Minute), 0, 0, s.location)
+	}
+	start := at(day, s.start)
+	if s.end < s.start {
+		return start, at(day+1, s.end)
+	}
+	return start, at(day, s.end)
+}
+
+// active tells if the time is in a window
+func (s *exposeSchedule) active(t time.Time) bool {
+	local := t.In(s.location)
+	// the window of the day before may not be over
+	for offset := -1; offset <= 0; offset++ {
+		day := local.AddDate(0, 0, offset)
+		if !s.days[day.Weekday()] {
+			continue
+		}
+		start, end := s.window(day.Year(), day.Month(), day.Day())
+		if !t.Before(start) && t.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
+// next returns when a window opens or closes after the time
+func (s *exposeSchedule) next(t time.Time) time.Time {
+	local := t.In(s.location)
+	var next time.Time
+	for offset := -1; offset <= 7; offset++ {
+		day := local.AddDate(0, 0, offset)
+		if !s.days[day.Weekday()] {
+			continue
+		}
+		start, end := s.window(day.Year(), day.Month(), day.Day())
+		for _, boundary := range []time.Time{start, end} {
+			if boundary.After(t) && (next.IsZero() || boundary.Before(next)) {
+				next = boundary
+			}
+		}
+	}
+	return next
+}
+
+// exposeScheduler resyncs when the window of a scheduled service opens or closes
+// or when the services waiting for their endpoints are checked again
+// all the methods do nothing on a nil scheduler
+type exposeScheduler struct {
+	resync func()
+	clock  clock.WithDelayedExecution
+
+	lock  sync.Mutex
+	timer clock.Timer
+	next  time.Time
+}
+
+// newExposeScheduler returns nil without resync, the schedules are then only checked once
+func newExposeScheduler(resync chan struct{}, clock clock.WithDelayedExecution) *exposeScheduler {
+	if resync == nil {
+		return nil
+	}
+	return &exposeScheduler{
+		resync: func() {
+			select {
+			case resync <- struct{}{}:
+			default:
+			}
+		},
+		clock: clock,
+	}
+}
+
+// isExposeActive tells if the schedule of the service allows to expose it now
+// an invalid schedule never does, the next opening or closing of the window is scheduled
+func (s *exposeScheduler) isExposeActive(svc *v1.Service) bool {
+	text := svc.Annotations[ExposeScheduleAnnotationKey]
+	if text == "" {
+		return true
+	}
+	schedule, err := parseExposeSchedule(text)
+	if err != nil {
+		klog.Errorf("Failed to parse annotation \"%s\" in service %s/%s, not exposing it: %v",
+			ExposeScheduleAnnotationKey, svc.Namespace, svc.Name, err)
+		return false
+	}
+	t := time.Now()
+	if s != nil {
+		t = s.clock.Now()
+	}
+	s.schedule(schedule.next(t))
+	return schedule.active(t)
+}
+
+// schedule resyncs at the given time unless an earlier resync is scheduled
+func (s *exposeScheduler) schedule(at time.Time) {
+	if s == nil || at.IsZero() {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.next.IsZero() && !at.Before(s.next) {
+		return
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.next = at
+	s.timer = s.clock.AfterFunc(at.Sub(s.clock.Now()), s.fire)
+}
+
+// scheduleAfter resyncs after the given delay unless an earlier resync is scheduled
+func (s *exposeScheduler) scheduleAfter(d time.Duration) {
+	if s != nil {
+		s.schedule(s.clock.Now().Add(d))
+	}
+}
+
+func (s *exposeScheduler) fire() {
+	s.lock.Lock()
+	s.next = time.Time{}
+	s.timer = nil
+	s.lock.Unlock()
+	klog.Infof("Resyncing the scheduled services")
+	s.resync()
+}
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExposeSchedule(t *testing.T) {
+	s, err := parseExposeSchedule("Mon-Fri 08:00-18:30 Europe/Paris")
+	require.NoError(t, err)
+	assert.Equal(t, [7]bool{false, true, true, true, true, true, false}, s.days)
+	assert.Equal(t, 8*time.Hour, s.start)
+	assert.Equal(t, 18*time.Hour+30*time.Minute, s.end)
+	assert.Equal(t, "Europe/Paris", s.location.String())
+
+	s, err = parseExposeSchedule("fri-mon,wed 22:00-24:00")
+	require.NoError(t, err)
+	assert.Equal(t, [7]bool{true, true, false, true, false, true, true}, s.days)
+	assert.Equal(t, time.UTC, s.location)
+
+	for _, text := range []string{
+		"",
+		"Mon-Fri",
+		"Mon-Fri 08:00-18:00 Europe/Paris extra",
+		"Monday 08:00-18:00",
+		"Mon-Fry 08:00-18:00",
+		"Mon 08:00",
+		"Mon 8h-18h",
+		"Mon 08:00-25:00",
+		"Mon 08:00-18:60",
+		"Mon 08:00-18:5",
+		"Mon 08:00-08:00",
+		"Mon 08:00-18:00 Europe/Nowhere",
+	} {
+		_, err := parseExposeSchedule(text)
+		assert.Error(t, err, text)
+	}
+}
+
+func TestExposeSchedule_active(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+	office, err := parseExposeSchedule("Mon-Fri 08:00-18:00 Europe/Paris")
+	require.NoError(t, err)
+	night, err := parseExposeSchedule("Fri 22:00-06:00 Europe/Paris")
+	require.NoError(t, err)
+
+	examples := []struct {
+		schedule *exposeSchedule
+		time     time.Time
+		active   bool
+	}{
+		{office, time.Date(2026, 10, 14, 7, 59, 0, 0, paris), false},
+		{office, time.Date(2026, 10, 14, 8, 0, 0, 0, paris), true},
+		{office, time.Date(2026, 10, 14, 17, 59, 0, 0, paris), true},
+		{office, time.Date(2026, 10, 14, 18, 0, 0, 0, paris), false},
+		{office, time.Date(2026, 10, 17, 12, 0, 0, 0, paris), false},
+		// on the same UTC time, either side of the time zone
+		{office, time.Date(2026, 10, 14, 6, 30, 0, 0, time.UTC), true},
+		{office, time.Date(2026, 10, 14, 16, 30, 0, 0, time.UTC), false},
+		{night, time.Date(2026, 10, 16, 23, 0, 0, 0, paris), true},
+		{night, time.Date(2026, 10, 17, 5, 0, 0, 0, paris), true},
+		{night, time.Date(2026, 10, 17, 7, 0, 0, 0, paris), false},
+		{night, time.Date(2026, 10, 15, 23, 0, 0, 0, paris), false},
+	}
+	for _, example := range examples {
+		assert.Equal(t, example.active, example.schedule.active(example.time), example.time.String())
+	}
+}
+
+func TestExposeSchedule_nextAcrossDST(t *testing.T) {
+	office, err := parseExposeSchedule("Mon-Fri 08:00-18:00 Europe/Paris")
+	require.NoError(t, err)
+
+	// Paris switches to summer time on Sunday 2026-03-29, 08:00 is 07:00 UTC before and 06:00 UTC after
+	assert.Equal(t, time.Date(2026, 3, 27, 7, 0, 0, 0, time.UTC),
+		office.next(time.Date(2026, 3, 27, 0, 0, 0, 0, time.UTC)).UTC())
+	assert.Equal(t, time.Date(2026, 3, 27, 17, 0, 0, 0, time.UTC),
+		office.next(time.Date(2026, 3, 27, 7, 0, 0, 0, time.UTC)).UTC())
+	assert.Equal(t, time.Date(2026, 3, 30, 6, 0, 0, 0, time.UTC),
+		office.next(time.Date(2026, 3, 27, 17, 0, 0, 0, time.UTC)).UTC())
+	// and back to winter time on Sunday 2026-10-25
+	assert.Equal(t, time.Date(2026, 10, 26, 7, 0, 0, 0, time.UTC),
+		office.next(time.Date(2026, 10, 23, 16, 0, 0, 0, time.UTC)).UTC())
+
+	// 02:30 does not exist on 2026-03-29 and 02:30 happens twice on 2026-10-25
+	sunday, err := parseExposeSchedule("Sun 02:30-04:00 Europe/Paris")
+	require.NoError(t, err)
+	spring := sunday.next(time.Date(2026, 3, 28, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 3, 29, 1, 30, 0, 0, time.UTC), spring.UTC())
+	assert.True(t, sunday.active(spring))
+	assert.Equal(t, time.Date(2026, 3, 29, 2, 0, 0, 0, time.UTC), sunday.next(spring).UTC())
+	fall := sunday.next(time.Date(2026, 10, 24, 0, 0, 0, 0, time.UTC))
+	assert.True(t, sunday.active(fall))
+	assert.Equal(t, time.Date(2026, 10, 25, 3, 0, 0, 0, time.UTC), sunday.next(fall).UTC())
+	assert.Contains(t, []time.Time{
+		time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC),
+		time.Date(2026, 10, 25, 1, 30, 0, 0, time.UTC),
+	}, fall.UTC(), "either 02:30")
+}
+
+func TestExposeScheduler(t *testing.T) {
+	resync := make(chan struct{}, 1)
+	clock := clocktesting.NewFakeClock(time.Date(2026, 10, 14, 7, 59, 0, 0, time.UTC))
+	scheduler := newExposeScheduler(resync, clock)
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				ExposeScheduleAnnotationKey: "Mon-Fri 08:00-18:00",
+			},
+		},
+	}
+
+	assert.False(t, scheduler.isExposeActive(svc))
+	clock.Step(59 * time.Second)
+	assert.Empty(t, resync, "the window is not open yet")
+	clock.Step(time.Second)
+	assert.Len(t, resync, 1, "resync when the window opens")
+	<-resync
+
+	assert.True(t, scheduler.isExposeActive(svc))
+	assert.Equal(t, time.Date(2026, 10, 14, 18, 0, 0, 0, time.UTC), scheduler.next)
+	scheduler.schedule(time.Date(2026, 10, 14, 19, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 10, 14, 18, 0, 0, 0, time.UTC), scheduler.next, "the earliest resync is kept")
+	scheduler.timer.Stop()
+
+	svc.Annotations[ExposeScheduleAnnotationKey] = "sometimes"
+	assert.False(t, scheduler.isExposeActive(svc), "invalid schedule")
+	delete(svc.Annotations, ExposeScheduleAnnotationKey)
+	assert.True(t, scheduler.isExposeActive(svc), "no schedule")
+	var none *exposeScheduler
+	assert.True(t, none.isExposeActive(svc), "no scheduler")
+}
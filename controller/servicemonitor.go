@@ -0,0 +1,280 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+const (
+	// MetricsPortAnnotationKey annotation holds the name or number of the port to scrape the metrics from
+	MetricsPortAnnotationKey = "fabric8.io/metrics.port"
+	// MetricsPathAnnotationKey annotation holds the path to scrape the metrics from
+	MetricsPathAnnotationKey = "fabric8.io/metrics.path"
+	// MonitoredServiceLabelKey label is set on the monitored services to select them from their service monitor
+	MonitoredServiceLabelKey = "fabric8.io/monitored-service"
+
+	defaultMetricsPath = "/metrics"
+)
+
+// ServiceMonitorResource is the resource of the prometheus operator service monitors
+var ServiceMonitorResource = schema.GroupVersionResource{
+	Group:    "monitoring.coreos.com",
+	Version:  "v1",
+	Resource: "servicemonitors",
+}
+
+// buildServiceMonitor builds the service monitor of the service
+// returns nil if the service does not declare a metrics port
+func buildServiceMonitor(svc *v1.Service, skipOwnerRefs bool, provider exposestrategy.ProviderLabel) (*unstructured.Unstructured, error) {
+	metricsPort := svc.Annotations[MetricsPortAnnotationKey]
+	if metricsPort == "" {
+		return nil, nil
+	}
+	path := svc.Annotations[MetricsPathAnnotationKey]
+	if path == "" {
+		path = defaultMetricsPath
+	}
+
+	endpoint := map[string]interface{}{
+		"path": path,
+	}
+	port, err := strconv.Atoi(metricsPort)
+	if err != nil {
+		endpoint["port"] = metricsPort
+	} else {
+		// service monitors reference service ports by name,
+		// falling back to the target port of the pods for unnamed ports
+		var servicePort *v1.ServicePort
+		for i, p := range svc.Spec.Ports {
+			if int(p.Port) == port {
+				servicePort = &svc.Spec.Ports[i]
+				break
+			}
+		}
+		switch {
+		case servicePort == nil:
+			return nil, errors.Errorf("port %d provided in the annotation \"%s\" is not a port of service %s/%s",
+				port, MetricsPortAnnotationKey, svc.Namespace, svc.Name)
+		case servicePort.Name != "":
+			endpoint["port"] = servicePort.Name
+		case servicePort.TargetPort.StrVal != "":
+			endpoint["targetPort"] = servicePort.TargetPort.StrVal
+		case servicePort.TargetPort.IntVal > 0:
+			endpoint["targetPort"] = int64(servicePort.TargetPort.IntVal)
+		default:
+			endpoint["targetPort"] = int64(servicePort.Port)
+		}
+	}
+
+	sm := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{
+					MonitoredServiceLabelKey: svc.Name,
+				},
+			},
+			"namespaceSelector": map[string]interface{}{
+				"matchNames": []interface{}{svc.Namespace},
+			},
+			"endpoints": []interface{}{endpoint},
+		},
+	}}
+	sm.SetAPIVersion(ServiceMonitorResource.GroupVersion().String())
+	sm.SetKind("ServiceMonitor")
+	sm.SetNamespace(svc.Namespace)
+	sm.SetName(svc.Name)
+	sm.SetAnnotations(map[string]string{
+		exposestrategy.GeneratedByAnnotationKey: provider.Marker(),
+	})
+	// without owner references, the service is found back from the labels
+	labels := map[string]string{
+		provider.Key: provider.Value,
+	}
+	if skipOwnerRefs {
+		labels[exposestrategy.ExposedServiceLabelKey] = svc.Name
+	} else {
+		sm.SetOwnerReferences([]metav1.OwnerReference{{
+			Kind:       exposestrategy.ServiceKind,
+			APIVersion: exposestrategy.ServiceAPIVersion,
+			Name:       svc.Name,
+			UID:        svc.UID,
+		}})
+	}
+	sm.SetLabels(labels)
+	return sm, nil
+}
+
+// updateServiceMonitor creates or updates the service monitor of an exposed service
+// and labels the service so that the monitor selects it alone
+// deletes the monitor if the service does not declare a metrics port anymore
+func updateServiceMonitor(ctx context.Context, client kubernetes.Interface, c dynamic.Interface, svc *v1.Service, config *Config) error {
+	if !config.ServiceMonitor {
+		return nil
+	}
+	skipOwnerRefs := exposestrategy.SkipOwnerReferences(svc,
+		config.SetOwnerReferences != nil && !*config.SetOwnerReferences)
+	sm, err := buildServiceMonitor(svc, skipOwnerRefs, providerLabel(config))
+	if err != nil {
+		return err
+	}
+	if sm == nil {
+		return deleteServiceMonitor(ctx, c, svc, config)
+	}
+	err = labelMonitoredService(ctx, client, svc)
+	if err != nil {
+		return err
+	}
+
+	monitors := c.Resource(ServiceMonitorResource).Namespace(svc.Namespace)
+	existing, err := monitors.Get(ctx, sm.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.Infof("Creating ServiceMonitor %s/%s", sm.GetNamespace(), sm.GetName())
+		_, err = monitors.Create(ctx, sm, metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create service monitor %s/%s", sm.GetNamespace(), sm.GetName())
+		}
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "could not check for existing service monitor %s/%s", sm.GetNamespace(), sm.GetName())
+	}
+	if !providerLabel(config).IsGenerated(existing.GetAnnotations()) {
+		klog.Warningf("ServiceMonitor %s/%s was not generated by exposecontroller, ignoring it",
+			existing.GetNamespace(), existing.GetName())
+		return nil
+	}
+	if reflect.DeepEqual(sm.Object["spec"], existing.Object["spec"]) &&
+		reflect.DeepEqual(sm.GetLabels(), existing.GetLabels()) &&
+		reflect.DeepEqual(sm.GetOwnerReferences(), existing.GetOwnerReferences()) {
+		return nil
+	}
+	sm.SetResourceVersion(existing.GetResourceVersion())
+	klog.Infof("Updating ServiceMonitor %s/%s", sm.GetNamespace(), sm.GetName())
+	_, err = monitors.Update(ctx, sm, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to update service monitor %s/%s", sm.GetNamespace(), sm.GetName())
+	}
+	return nil
+}
+
+// labelMonitoredService sets the label selected by the service monitor on the service
+func labelMonitoredService(ctx context.Context, client kubernetes.Interface, svc *v1.Service) error {
+	if svc.Labels[MonitoredServiceLabelKey] == svc.Name {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{
+				MonitoredServiceLabelKey: svc.Name,
+			},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to create the label patch")
+	}
+	_, err = client.CoreV1().Services(svc.Namespace).
+		Patch(ctx, svc.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to label service %s/%s", svc.Namespace, svc.Name)
+	}
+	return nil
+}
+
+// deleteServiceMonitor deletes the service monitor generated for a service
+func deleteServiceMonitor(ctx context.Context, c dynamic.Interface, svc *v1.Service, config *Config) error {
+	if !config.ServiceMonitor {
+		return nil
+	}
+	monitors := c.Resource(ServiceMonitorResource).Namespace(svc.Namespace)
+	existing, err := monitors.Get(ctx, svc.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "could not check for existing service monitor %s/%s", svc.Namespace, svc.Name)
+	}
+	if !providerLabel(config).IsGenerated(existing.GetAnnotations()) {
+		return nil
+	}
+	if config.NeverDelete {
+		return releaseServiceMonitor(ctx, c, existing, config)
+	}
+	klog.Infof("Deleting ServiceMonitor %s/%s", existing.GetNamespace(), existing.GetName())
+	err = monitors.Delete(ctx, existing.GetName(), deleteOptions(config))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete service monitor %s/%s", existing.GetNamespace(), existing.GetName())
+	}
+	return nil
+}
+
+// cleanServiceMonitors deletes the service monitors tracked by label whose service is gone,
+// no garbage collection happens without owner references
+func cleanServiceMonitors(ctx context.Context, client kubernetes.Interface, c dynamic.Interface, namespace string, config *Config) error {
+	if !config.ServiceMonitor {
+		return nil
+	}
+	// the monitors having the legacy provider label are cleaned too
+	var monitors []unstructured.Unstructured
+	for _, selector := range providerLabel(config).Selectors() {
+		list, err := c.Resource(ServiceMonitorResource).Namespace(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: selector + "," + exposestrategy.ExposedServiceLabelKey,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to list service monitors")
+		}
+		monitors = append(monitors, list.Items...)
+	}
+	for _, sm := range monitors {
+		if !providerLabel(config).IsGenerated(sm.GetAnnotations()) || len(sm.GetOwnerReferences()) > 0 {
+			continue
+		}
+		name := sm.GetLabels()[exposestrategy.ExposedServiceLabelKey]
+		_, err := client.CoreV1().Services(sm.GetNamespace()).Get(ctx, name, metav1.GetOptions{})
+		if err == nil {
+			continue
+		} else if !apierrors.IsNotFound(err) {
+			klog.Errorf("error when getting service %s/%s: %s", sm.GetNamespace(), name, err)
+			continue
+		}
+		if config.NeverDelete {
+			err = releaseServiceMonitor(ctx, c, &sm, config)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		klog.Infof("Deleting ServiceMonitor %s/%s", sm.GetNamespace(), sm.GetName())
+		err = c.Resource(ServiceMonitorResource).Namespace(sm.GetNamespace()).Delete(ctx, sm.GetName(), deleteOptions(config))
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete service monitor %s/%s", sm.GetNamespace(), sm.GetName())
+		}
+	}
+	return nil
+}
+
+// releaseServiceMonitor strips the management labels of the service monitor instead of deleting it
+func releaseServiceMonitor(ctx context.Context, c dynamic.Interface, sm *unstructured.Unstructured, config *Config) error {
+	released := sm.DeepCopy()
+	exposestrategy.ReleaseObject(released, providerLabel(config))
+	klog.Warningf("Not deleting ServiceMonitor %s/%s, it is released with label %s=true",
+		sm.GetNamespace(), sm.GetName(), exposestrategy.RetainedLabelKey)
+	_, err := c.Resource(ServiceMonitorResource).Namespace(sm.GetNamespace()).Update(ctx, released, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to release service monitor %s/%s", sm.GetNamespace(), sm.GetName())
+	}
+	return nil
+}
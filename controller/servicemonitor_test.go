@@ -0,0 +1,267 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			ServiceMonitorResource: "ServiceMonitorList",
+		}, objects...)
+}
+
+func TestUpdateServiceMonitor(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeDynamicClient()
+	config := &Config{ServiceMonitor: true}
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			UID:       "svc-uid",
+			Labels: map[string]string{
+				"app": "svc",
+			},
+			Annotations: map[string]string{
+				MetricsPortAnnotationKey: "8081",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name: "http",
+				Port: 8080,
+			}, {
+				Name: "metrics",
+				Port: 8081,
+			}},
+		},
+	}
+	kubeClient := fake.NewSimpleClientset(svc)
+
+	err := updateServiceMonitor(ctx, kubeClient, client, svc, config)
+	require.NoError(t, err)
+	labelled, err := kubeClient.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"app":                    "svc",
+		MonitoredServiceLabelKey: "svc",
+	}, labelled.Labels)
+	sm, err := client.Resource(ServiceMonitorResource).Namespace("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	expected := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{
+				MonitoredServiceLabelKey: "svc",
+			},
+		},
+		"namespaceSelector": map[string]interface{}{
+			"matchNames": []interface{}{"main"},
+		},
+		"endpoints": []interface{}{map[string]interface{}{
+			"port": "metrics",
+			"path": "/metrics",
+		}},
+	}
+	assert.Equal(t, expected, sm.Object["spec"])
+	assert.Equal(t, "exposecontroller", sm.GetAnnotations()["fabric8.io/generated-by"])
+	assert.Equal(t, []metav1.OwnerReference{{
+		APIVersion: "v1",
+		Kind:       "Service",
+		Name:       "svc",
+		UID:        "svc-uid",
+	}}, sm.GetOwnerReferences())
+
+	svc.Annotations[MetricsPortAnnotationKey] = "http"
+	svc.Annotations[MetricsPathAnnotationKey] = "/prometheus"
+	err = updateServiceMonitor(ctx, kubeClient, client, svc, config)
+	require.NoError(t, err)
+	sm, err = client.Resource(ServiceMonitorResource).Namespace("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	endpoints, _, _ := unstructured.NestedSlice(sm.Object, "spec", "endpoints")
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"port": "http",
+		"path": "/prometheus",
+	}}, endpoints)
+
+	delete(svc.Annotations, MetricsPortAnnotationKey)
+	err = updateServiceMonitor(ctx, kubeClient, client, svc, config)
+	require.NoError(t, err)
+	_, err = client.Resource(ServiceMonitorResource).Namespace("main").Get(ctx, "svc", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestUpdateServiceMonitor_disabled(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeDynamicClient()
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Labels: map[string]string{
+				"app": "svc",
+			},
+			Annotations: map[string]string{
+				MetricsPortAnnotationKey: "metrics",
+			},
+		},
+	}
+
+	err := updateServiceMonitor(ctx, fake.NewSimpleClientset(svc), client, svc, &Config{})
+	require.NoError(t, err)
+	list, err := client.Resource(ServiceMonitorResource).Namespace("main").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, list.Items)
+}
+
+func TestBuildServiceMonitor_unnamedPort(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				MetricsPortAnnotationKey: "8081",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Port:       8081,
+				TargetPort: intstr.FromInt(9090),
+			}},
+		},
+	}
+
+	sm, err := buildServiceMonitor(svc, false, exposestrategy.LegacyProviderLabel)
+	require.NoError(t, err)
+	endpoints, _, _ := unstructured.NestedSlice(sm.Object, "spec", "endpoints")
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"targetPort": int64(9090),
+		"path":       "/metrics",
+	}}, endpoints)
+
+	svc.Annotations[MetricsPortAnnotationKey] = "8082"
+	_, err = buildServiceMonitor(svc, false, exposestrategy.LegacyProviderLabel)
+	assert.Error(t, err, "unknown port")
+}
+
+func TestUpdateServiceMonitor_skipOwnerReferences(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeDynamicClient()
+	setOwnerReferences := false
+	config := &Config{ServiceMonitor: true, SetOwnerReferences: &setOwnerReferences}
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			UID:       "svc-uid",
+			Annotations: map[string]string{
+				MetricsPortAnnotationKey: "metrics",
+			},
+		},
+	}
+	kubeClient := fake.NewSimpleClientset(svc)
+
+	err := updateServiceMonitor(ctx, kubeClient, client, svc, config)
+	require.NoError(t, err)
+	sm, err := client.Resource(ServiceMonitorResource).Namespace("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, sm.GetOwnerReferences())
+	assert.Equal(t, map[string]string{
+		"provider":                   "fabric8",
+		"fabric8.io/exposed-service": "svc",
+	}, sm.GetLabels())
+
+	// the annotation of the service overrides the configuration
+	svc.Annotations["fabric8.io/owner.references"] = "true"
+	err = updateServiceMonitor(ctx, kubeClient, client, svc, config)
+	require.NoError(t, err)
+	sm, err = client.Resource(ServiceMonitorResource).Namespace("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Len(t, sm.GetOwnerReferences(), 1)
+	assert.Equal(t, map[string]string{
+		"provider": "fabric8",
+	}, sm.GetLabels())
+}
+
+func TestDeleteServiceMonitor(t *testing.T) {
+	ctx := context.Background()
+	generated := &unstructured.Unstructured{}
+	generated.SetAPIVersion("monitoring.coreos.com/v1")
+	generated.SetKind("ServiceMonitor")
+	generated.SetNamespace("main")
+	generated.SetName("svc1")
+	generated.SetAnnotations(map[string]string{
+		"fabric8.io/generated-by": "exposecontroller",
+	})
+	other := generated.DeepCopy()
+	other.SetName("svc2")
+	other.SetAnnotations(nil)
+	client := newFakeDynamicClient(generated, other)
+	config := &Config{ServiceMonitor: true}
+
+	for _, name := range []string{"svc1", "svc2", "svc3"} {
+		err := deleteServiceMonitor(ctx, client, &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "main",
+				Name:      name,
+			},
+		}, config)
+		assert.NoError(t, err, name)
+	}
+
+	list, err := client.Resource(ServiceMonitorResource).Namespace("main").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	if assert.Len(t, list.Items, 1) {
+		assert.Equal(t, "svc2", list.Items[0].GetName())
+	}
+}
+
+func TestCleanServiceMonitors(t *testing.T) {
+	ctx := context.Background()
+	labelTracked := func(name string) *unstructured.Unstructured {
+		sm := &unstructured.Unstructured{}
+		sm.SetAPIVersion("monitoring.coreos.com/v1")
+		sm.SetKind("ServiceMonitor")
+		sm.SetNamespace("main")
+		sm.SetName(name)
+		sm.SetLabels(map[string]string{
+			"provider":                   "fabric8",
+			"fabric8.io/exposed-service": name,
+		})
+		sm.SetAnnotations(map[string]string{
+			"fabric8.io/generated-by": "exposecontroller",
+		})
+		return sm
+	}
+	client := newFakeDynamicClient(labelTracked("kept"), labelTracked("gone"))
+	kubeClient := fake.NewSimpleClientset(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "kept",
+		},
+	})
+
+	err := cleanServiceMonitors(ctx, kubeClient, client, "main", &Config{ServiceMonitor: true})
+	require.NoError(t, err)
+	list, err := client.Resource(ServiceMonitorResource).Namespace("main").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	if assert.Len(t, list.Items, 1) {
+		assert.Equal(t, "kept", list.Items[0].GetName())
+	}
+}
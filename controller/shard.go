@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+// shardOf returns the shard of the namespaces handled by the controller
+func shardOf(config *Config) exposestrategy.Shard {
+	return exposestrategy.Shard{Index: config.ShardIndex, Count: config.ShardCount}
+}
+
+// filterShardServices keeps the services of the namespaces of the shard
+func filterShardServices(list *v1.ServiceList, shard exposestrategy.Shard) {
+	if !shard.Enabled() {
+		return
+	}
+	items := list.Items[:0]
+	for _, svc := range list.Items {
+		if shard.Owns(svc.Namespace) {
+			items = append(items, svc)
+		}
+	}
+	list.Items = items
+}
+
+// filterShardWatch drops the events of the services of the other shards
+func filterShardWatch(w watch.Interface, shard exposestrategy.Shard) watch.Interface {
+	if !shard.Enabled() {
+		return w
+	}
+	return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+		svc, ok := event.Object.(*v1.Service)
+		return event, !ok || shard.Owns(svc.Namespace)
+	})
+}
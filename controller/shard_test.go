@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardFilters(t *testing.T) {
+	shard := shardOf(&Config{ShardIndex: 1, ShardCount: 2})
+	list := &v1.ServiceList{}
+	for i := 0; i < 20; i++ {
+		list.Items = append(list.Items, v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: fmt.Sprintf("team-%d", i), Name: "web"}})
+	}
+	all := append([]v1.Service{}, list.Items...)
+
+	filterShardServices(list, exposestrategy.Shard{})
+	assert.Len(t, list.Items, len(all), "nothing filtered without sharding")
+	filterShardServices(list, shard)
+	assert.NotEmpty(t, list.Items)
+	assert.True(t, len(list.Items) < len(all))
+	for _, svc := range list.Items {
+		assert.True(t, shard.Owns(svc.Namespace), svc.Namespace)
+	}
+
+	fake := watch.NewFakeWithChanSize(len(all)+1, false)
+	w := filterShardWatch(fake, shard)
+	defer w.Stop()
+	for i := range all {
+		fake.Add(&all[i])
+	}
+	fake.Action(watch.Bookmark, &metav1.Status{})
+	for _, svc := range list.Items {
+		event := <-w.ResultChan()
+		require.Equal(t, watch.Added, event.Type)
+		assert.Equal(t, svc.Namespace, event.Object.(*v1.Service).Namespace)
+	}
+	event := <-w.ResultChan()
+	assert.Equal(t, watch.Bookmark, event.Type, "the events of other objects are kept")
+}
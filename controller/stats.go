@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/clock"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+// defaultStatsInterval is how often the summary is logged by default
+const defaultStatsInterval = 15 * time.Minute
+
+// controllerStats summarizes the activity of the controller in one log line,
+// for the operators checking its health without a metrics stack
+type controllerStats struct {
+	config *Config
+	clock  clock.Clock
+	store  cache.Store
+	// exposeValues tell which services of the store request the exposure
+	exposeValues *exposestrategy.ExposeValues
+
+	lock         sync.Mutex
+	errors       int
+	syncStart    time.Time
+	syncDuration time.Duration
+}
+
+func newControllerStats(config *Config) *controllerStats {
+	return &controllerStats{
+		config: config,
+		clock:  config.clock(),
+	}
+}
+
+// parseStatsInterval parses the interval of the summary, the default one if empty
+func parseStatsInterval(text string) (time.Duration, error) {
+	if text == "" {
+		return defaultStatsInterval, nil
+	}
+	d, err := time.ParseDuration(text)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid stats interval \"%s\"", text)
+	}
+	return d, nil
+}
+
+// failed counts a failure to expose or clean a service
+func (s *controllerStats) failed() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.errors++
+}
+
+// syncStarted is called when the services are listed for a full sync
+func (s *controllerStats) syncStarted() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.syncStart = s.clock.Now()
+}
+
+// synced is called when the listed services are all handled
+func (s *controllerStats) synced() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.syncStart.IsZero() {
+		s.syncDuration = s.clock.Since(s.syncStart)
+		s.syncStart = time.Time{}
+	}
+}
+
+// summary counts the services of the store, the exposed ones have a URL while the pending ones wait for it
+func (s *controllerStats) summary() string {
+	watched, exposed, pending := 0, 0, 0
+	if s.store != nil {
+		for _, obj := range s.store.List() {
+			svc := obj.(*v1.Service)
+			watched++
+			if !s.exposeValues.IsRequested(svc) || !isServiceWhitelisted(svc.Name, s.config) {
+				continue
+			}
+			if svc.Annotations[exposestrategy.ExposeAnnotationKey] != "" {
+				exposed++
+			} else {
+				pending++
+			}
+		}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	lastSync := "none"
+	if s.syncDuration > 0 {
+		lastSync = s.syncDuration.String()
+	}
+	return fmt.Sprintf("%d services watched, %d exposed, %d pending, %d errors since start, last full sync duration: %s",
+		watched, exposed, pending, s.errors, lastSync)
+}
+
+func (s *controllerStats) log() {
+	klog.Infof("Stats: %s", s.summary())
+}
+
+// run logs the summary periodically until the context is done
+func (s *controllerStats) run(ctx context.Context, interval time.Duration) {
+	timer := s.clock.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+			s.log()
+			timer.Reset(interval)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControllerStats(t *testing.T) {
+	clock := clocktesting.NewFakeClock(time.Now())
+	stats := newControllerStats(&Config{Clock: clock})
+	assert.Equal(t, "0 services watched, 0 exposed, 0 pending, 0 errors since start, last full sync duration: none", stats.summary())
+
+	stats.store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for name, annotations := range map[string]map[string]string{
+		"exposed": {
+			exposestrategy.ExposeAnnotation.Key: "true",
+			exposestrategy.ExposeAnnotationKey:  "https://exposed.main.my-domain.com",
+		},
+		"pending": {
+			exposestrategy.ExposeAnnotation.Key: "true",
+			exposestrategy.ExposeAnnotationKey:  "",
+		},
+		"internal": nil,
+	} {
+		require.NoError(t, stats.store.Add(&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: name, Annotations: annotations},
+		}))
+	}
+	stats.syncStarted()
+	clock.Step(1500 * time.Millisecond)
+	stats.synced()
+	stats.failed()
+	stats.synced()
+	assert.Equal(t, "3 services watched, 1 exposed, 1 pending, 1 errors since start, last full sync duration: 1.5s", stats.summary())
+
+	interval, err := parseStatsInterval("")
+	require.NoError(t, err)
+	assert.Equal(t, defaultStatsInterval, interval)
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+)
+
+// namespaceTeardown detects the namespaces being deleted, the state of their services is dropped at once
+// instead of cleaning them one by one: their objects are deleted with the namespace
+// and patching them only fails or races the namespace controller
+type namespaceTeardown struct {
+	ctx      context.Context
+	client   kubernetes.Interface
+	strategy exposestrategy.ExposeStrategy
+
+	lock sync.Mutex
+	// the namespaces being deleted whose services were forgotten, until the next full sync
+	terminating map[string]bool
+}
+
+// newNamespaceTeardown returns nil if the namespaces cannot be read
+func newNamespaceTeardown(ctx context.Context, client kubernetes.Interface, config *Config, strategy exposestrategy.ExposeStrategy) *namespaceTeardown {
+	if config.PermissionProfile == exposestrategy.PermissionProfileNamespace {
+		// namespaces are cluster scoped
+		return nil
+	}
+	return &namespaceTeardown{
+		ctx:         ctx,
+		client:      client,
+		strategy:    strategy,
+		terminating: map[string]bool{},
+	}
+}
+
+// isForgotten tells if the namespace of the service is known to be deleted, without any request
+func (t *namespaceTeardown) isForgotten(svc *v1.Service) bool {
+	if t == nil {
+		return false
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.terminating[svc.Namespace]
+}
+
+// isTerminating tells if the namespace of the service is being deleted
+// the state of all the services of the namespace is dropped from the strategy the first time
+func (t *namespaceTeardown) isTerminating(svc *v1.Service) bool {
+	if t == nil {
+		return false
+	}
+	if t.isForgotten(svc) {
+		return true
+	}
+	ns, err := t.client.CoreV1().Namespaces().Get(t.ctx, svc.Namespace, metav1.GetOptions{})
+	if err != nil {
+		klog.Warningf("Failed to check whether namespace %s is being deleted: %v", svc.Namespace, err)
+		return false
+	}
+	if ns.Status.Phase != v1.NamespaceTerminating && ns.DeletionTimestamp == nil {
+		return false
+	}
+	klog.Infof("Namespace %s is being deleted, forgetting its exposed services", svc.Namespace)
+	t.lock.Lock()
+	t.terminating[svc.Namespace] = true
+	t.lock.Unlock()
+	if forgetter, ok := t.strategy.(exposestrategy.NamespaceForgetter); ok {
+		forgetter.ForgetNamespace(svc.Namespace)
+	}
+	return true
+}
+
+// forget drops the state of the service in the strategies which cannot forget a whole namespace,
+// their Delete only drops their state
+func (t *namespaceTeardown) forget(svc *v1.Service) {
+	if _, ok := t.strategy.(exposestrategy.NamespaceForgetter); ok {
+		return
+	}
+	err := t.strategy.Delete(svc)
+	if err != nil {
+		klog.Errorf("Remove failed: %v", err)
+	}
+}
+
+// reset is called on each full sync, a namespace deleted since may be created again
+func (t *namespaceTeardown) reset() {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.terminating = map[string]bool{}
+}
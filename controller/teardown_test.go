@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// forgettingStrategy records the forgotten namespaces
+type forgettingStrategy struct {
+	fakeStrategy
+	forgotten []string
+}
+
+func (s *forgettingStrategy) ForgetNamespace(namespace string) {
+	s.forgotten = append(s.forgotten, namespace)
+}
+
+func TestNamespaceTeardown(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "active"},
+			Status:     v1.NamespaceStatus{Phase: v1.NamespaceActive},
+		},
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "terminating"},
+			Status:     v1.NamespaceStatus{Phase: v1.NamespaceTerminating},
+		},
+	)
+	strategy := &forgettingStrategy{fakeStrategy: fakeStrategy{testing: t}}
+	teardown := newNamespaceTeardown(context.Background(), client, &Config{}, strategy)
+	service := func(namespace string) *v1.Service {
+		return &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "svc"}}
+	}
+
+	assert.False(t, teardown.isTerminating(service("active")))
+	assert.False(t, teardown.isForgotten(service("active")))
+	assert.True(t, teardown.isTerminating(service("terminating")))
+	assert.True(t, teardown.isForgotten(service("terminating")))
+	assert.True(t, teardown.isTerminating(service("terminating")))
+	assert.False(t, teardown.isTerminating(service("unknown")), "the services are cleaned if the namespace cannot be checked")
+	assert.Equal(t, []string{"terminating"}, strategy.forgotten, "the namespaces are forgotten once")
+	// the strategy forgot the whole namespace
+	teardown.forget(service("terminating"))
+
+	teardown.reset()
+	assert.False(t, teardown.isForgotten(service("terminating")))
+	assert.True(t, teardown.isTerminating(service("terminating")))
+	assert.Equal(t, []string{"terminating", "terminating"}, strategy.forgotten)
+	strategy.checkEnd()
+}
+
+func TestNamespaceTeardown_withoutForgetter(t *testing.T) {
+	now := metav1.Now()
+	client := fake.NewSimpleClientset(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "main", DeletionTimestamp: &now},
+	})
+	strategy := &fakeStrategy{
+		testing: t,
+		tasks:   []map[string]bool{{"Delete:main/svc:": true}},
+	}
+	teardown := newNamespaceTeardown(context.Background(), client, &Config{}, strategy)
+	svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "svc"}}
+	assert.True(t, teardown.isTerminating(svc))
+	// the state of the strategy is dropped by deleting the service
+	teardown.forget(svc)
+	strategy.checkEnd()
+}
+
+func TestNamespaceTeardown_namespaceProfile(t *testing.T) {
+	teardown := newNamespaceTeardown(context.Background(), fake.NewSimpleClientset(), &Config{
+		PermissionProfile: exposestrategy.PermissionProfileNamespace,
+	}, &fakeStrategy{testing: t})
+	svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "svc"}}
+	assert.Nil(t, teardown)
+	assert.False(t, teardown.isTerminating(svc))
+	assert.False(t, teardown.isForgotten(svc))
+	teardown.reset()
+}
@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// WriteBackAnnotationKey annotation of a config map records the values written by the controller
+// by service and key, with the original values to restore when the services are unexposed
+const WriteBackAnnotationKey = "fabric8.io/expose.write-back"
+
+// writeBackRecord is a value written by the controller, the original value is nil if the key was absent
+type writeBackRecord struct {
+	Value    string  `json:"value"`
+	Original *string `json:"original"`
+}
+
+// writeBackRecords are the records of a config map by service name and key
+type writeBackRecords map[string]map[string]writeBackRecord
+
+// writeBackRegistry tracks the config maps written for each service
+// the records are held by the config maps themselves so that they outlive the controller
+type writeBackRegistry struct {
+	lock sync.Mutex
+	// the names of the config maps by service key, in the namespace of the service
+	configMaps map[string]map[string]bool
+}
+
+func newWriteBackRegistry() *writeBackRegistry {
+	return &writeBackRegistry{
+		configMaps: map[string]map[string]bool{},
+	}
+}
+
+func parseWriteBackRecords(cm *v1.ConfigMap) writeBackRecords {
+	records := writeBackRecords{}
+	text := cm.Annotations[WriteBackAnnotationKey]
+	if text == "" {
+		return records
+	}
+	err := json.Unmarshal([]byte(text), &records)
+	if err != nil {
+		klog.Warningf("Failed to parse annotation \"%s\" of ConfigMap %s/%s, the written values are not restored: %v",
+			WriteBackAnnotationKey, cm.Namespace, cm.Name, err)
+		return writeBackRecords{}
+	}
+	return records
+}
+
+func (records writeBackRecords) save(cm *v1.ConfigMap) {
+	if len(records) == 0 {
+		delete(cm.Annotations, WriteBackAnnotationKey)
+		return
+	}
+	data, _ := json.Marshal(records)
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	cm.Annotations[WriteBackAnnotationKey] = string(data)
+}
+
+// sync indexes the config maps holding records, after a restart of the controller
+func (r *writeBackRegistry) sync(ctx context.Context, c kubernetes.Interface, namespace string) error {
+	list, err := c.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list the config maps")
+	}
+	configMaps := map[string]map[string]bool{}
+	for i := range list.Items {
+		cm := &list.Items[i]
+		for name := range parseWriteBackRecords(cm) {
+			key := cm.Namespace + "/" + name
+			if configMaps[key] == nil {
+				configMaps[key] = map[string]bool{}
+			}
+			configMaps[key][cm.Name] = true
+		}
+	}
+	r.lock.Lock()
+	r.configMaps = configMaps
+	r.lock.Unlock()
+	return nil
+}
+
+// writer returns the function writing the values of the service in the config map
+// the function tells if the value changed, the config map is then to be updated
+func (r *writeBackRegistry) writer(cm *v1.ConfigMap, svc *v1.Service) func(key, value string) bool {
+	return func(key, value string) bool {
+		current, exists := cm.Data[key]
+		if exists && current == value {
+			return false
+		}
+		records := parseWriteBackRecords(cm)
+		if records[svc.Name] == nil {
+			records[svc.Name] = map[string]writeBackRecord{}
+		}
+		record, recorded := records[svc.Name][key]
+		if !recorded && exists {
+			record.Original = &current
+		}
+		record.Value = value
+		records[svc.Name][key] = record
+		records.save(cm)
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[key] = value
+
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		svcKey := svc.Namespace + "/" + svc.Name
+		if r.configMaps[svcKey] == nil {
+			r.configMaps[svcKey] = map[string]bool{}
+		}
+		r.configMaps[svcKey][cm.Name] = true
+		return true
+	}
+}
+
+// restore replays the records of the service, deleting the keys it added and restoring the values it replaced
+// the values changed since they were written are kept
+func (r *writeBackRegistry) restore(ctx context.Context, c kubernetes.Interface, svc *v1.Service) error {
+	svcKey := svc.Namespace + "/" + svc.Name
+	r.lock.Lock()
+	names := r.configMaps[svcKey]
+	delete(r.configMaps, svcKey)
+	r.lock.Unlock()
+
+	var err error
+	for name := range names {
+		restoreErr := restoreConfigMap(ctx, c, svc, name)
+		if restoreErr != nil {
+			// retried on the next clean
+			err = restoreErr
+			r.lock.Lock()
+			if r.configMaps[svcKey] == nil {
+				r.configMaps[svcKey] = map[string]bool{}
+			}
+			r.configMaps[svcKey][name] = true
+			r.lock.Unlock()
+		}
+	}
+	return err
+}
+
+// forget drops the records of the service without restoring them, such as when its namespace is being deleted
+func (r *writeBackRegistry) forget(svc *v1.Service) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.configMaps, svc.Namespace+"/"+svc.Name)
+}
+
+func restoreConfigMap(ctx context.Context, c kubernetes.Interface, svc *v1.Service, name string) error {
+	configMaps := c.CoreV1().ConfigMaps(svc.Namespace)
+	cm, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to get ConfigMap %s/%s", svc.Namespace, name)
+	}
+	records := parseWriteBackRecords(cm)
+	if _, ok := records[svc.Name]; !ok {
+		return nil
+	}
+	for key, record := range records[svc.Name] {
+		if current, ok := cm.Data[key]; !ok || current != record.Value {
+			klog.Infof("Keeping key %s of ConfigMap %s/%s changed since it was written", key, svc.Namespace, name)
+		} else if record.Original == nil {
+			delete(cm.Data, key)
+		} else {
+			cm.Data[key] = *record.Original
+		}
+	}
+	delete(records, svc.Name)
+	records.save(cm)
+	klog.Infof("Restoring ConfigMap %s/%s written for service %s", svc.Namespace, name, svc.Name)
+	_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to restore ConfigMap %s/%s", svc.Namespace, name)
+	}
+	err = rollingUpgradeDeployments(ctx, cm, c)
+	if err != nil {
+		klog.Errorf("Failed to update Deployments after change to ConfigMap %s error: %v", name, err)
+	}
+	return nil
+}
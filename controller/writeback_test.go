@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBackRegistry(t *testing.T) {
+	ctx := context.Background()
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+		},
+	}
+	client := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "app",
+			Annotations: map[string]string{
+				"expose.service-key.config.fabric8.io/svc": "url,old-url,changed-url",
+			},
+			ResourceVersion: "1",
+		},
+		Data: map[string]string{
+			"old-url": "http://localhost",
+			"other":   "value",
+		},
+	})
+	registry := newWriteBackRegistry()
+	err := updateOtherConfigMaps(ctx, client, svc, &Config{}, "http://svc.main.my-domain.com", registry)
+	require.NoError(t, err)
+	cm, err := client.CoreV1().ConfigMaps("main").Get(ctx, "app", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"url":         "http://svc.main.my-domain.com",
+		"old-url":     "http://svc.main.my-domain.com",
+		"changed-url": "http://svc.main.my-domain.com",
+		"other":       "value",
+	}, cm.Data)
+	assert.Contains(t, cm.Annotations, WriteBackAnnotationKey)
+
+	// a new URL keeps the original values
+	err = updateOtherConfigMaps(ctx, client, svc, &Config{}, "https://svc.main.my-domain.com", registry)
+	require.NoError(t, err)
+	cm, err = client.CoreV1().ConfigMaps("main").Get(ctx, "app", metav1.GetOptions{})
+	require.NoError(t, err)
+	cm.Data["changed-url"] = "http://elsewhere"
+	_, err = client.CoreV1().ConfigMaps("main").Update(ctx, cm, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// the records outlive the registry
+	registry = newWriteBackRegistry()
+	require.NoError(t, registry.sync(ctx, client, ""))
+	assert.Equal(t, map[string]map[string]bool{"main/svc": {"app": true}}, registry.configMaps)
+	require.NoError(t, registry.restore(ctx, client, svc))
+	cm, err = client.CoreV1().ConfigMaps("main").Get(ctx, "app", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"old-url":     "http://localhost",
+		"changed-url": "http://elsewhere",
+		"other":       "value",
+	}, cm.Data)
+	assert.NotContains(t, cm.Annotations, WriteBackAnnotationKey)
+	assert.Empty(t, registry.configMaps)
+}
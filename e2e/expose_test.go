@@ -0,0 +1,210 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/devopscare/exposecontroller/controller"
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// e2eDomain is the domain of the exposed services, the requests are sent to ingress-nginx on the host with their Host header
+	e2eDomain = "e2e.example.com"
+	// e2eTimeout is the time given to the controller and ingress-nginx to expose or unexpose a service
+	e2eTimeout = 3 * time.Minute
+	// agnhostImage is the backend of the services, serving "/" and "/hostname" on port 8080
+	agnhostImage = "registry.k8s.io/e2e-test-images/agnhost:2.39"
+)
+
+func TestExpose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	namespace := createNamespace(ctx, t)
+	createBackend(ctx, t, namespace)
+	startController(ctx, t, namespace)
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		// path is appended to the exposed URL, the backend only answers 200 on "/" and "/hostname"
+		path string
+	}{
+		{
+			name: "host",
+			path: "/hostname",
+		},
+		{
+			name:        "path",
+			annotations: map[string]string{"fabric8.io/ingress.path": "/hostname"},
+		},
+		{
+			name: "rewrite",
+			annotations: map[string]string{
+				"fabric8.io/ingress.path":        "/echo",
+				"fabric8.io/ingress.annotations": "nginx.ingress.kubernetes.io/rewrite-target: /hostname",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name := "echo-" + test.name
+			createService(ctx, t, namespace, name, test.annotations)
+			exposed := waitForURL(ctx, t, namespace, name)
+			exposed.Path += test.path
+			waitForStatus(t, exposed, http.StatusOK)
+
+			// unexposing removes the URL and the route
+			patch := fmt.Sprintf(`{"metadata":{"annotations":{"%s":"false"}}}`, exposestrategy.ExposeAnnotation.Key)
+			_, err := client.CoreV1().Services(namespace).Patch(ctx, name, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+			require.NoError(t, err)
+			err = wait.PollImmediate(time.Second, e2eTimeout, func() (bool, error) {
+				_, err := client.NetworkingV1().Ingresses(namespace).Get(ctx, name, metav1.GetOptions{})
+				return apierrors.IsNotFound(err), nil
+			})
+			require.NoError(t, err, "ingress deleted")
+			svc, err := client.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.NotContains(t, svc.Annotations, exposestrategy.ExposeAnnotationKey)
+			waitForStatus(t, exposed, http.StatusNotFound)
+		})
+	}
+}
+
+// createNamespace creates the namespace of the test, deleted at its end
+func createNamespace(ctx context.Context, t *testing.T) string {
+	ns, err := client.CoreV1().Namespaces().Create(ctx, &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "e2e-"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.CoreV1().Namespaces().Delete(context.Background(), ns.Name, metav1.DeleteOptions{})
+	})
+	return ns.Name
+}
+
+// createBackend deploys the backend of the services and waits for it to be ready
+func createBackend(ctx context.Context, t *testing.T, namespace string) {
+	labels := map[string]string{"app": "echo"}
+	replicas := int32(1)
+	_, err := client.AppsV1().Deployments(namespace).Create(ctx, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{{
+						Name:  "agnhost",
+						Image: agnhostImage,
+						Args:  []string{"netexec", "--http-port=8080"},
+						Ports: []v1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+						ReadinessProbe: &v1.Probe{
+							ProbeHandler: v1.ProbeHandler{
+								HTTPGet: &v1.HTTPGetAction{Path: "/", Port: intstr.FromString("http")},
+							},
+						},
+					}},
+				},
+			},
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	err = wait.PollImmediate(2*time.Second, e2eTimeout, func() (bool, error) {
+		deployment, err := client.AppsV1().Deployments(namespace).Get(ctx, "echo", metav1.GetOptions{})
+		return err == nil && deployment.Status.ReadyReplicas == replicas, nil
+	})
+	require.NoError(t, err, "backend ready")
+}
+
+// startController runs the controller in the test, watching its namespace
+func startController(ctx context.Context, t *testing.T, namespace string) {
+	config := controller.DefaultConfig
+	config.WatchCurrentNamespace = false
+	config.Exposer = "ingress"
+	config.Domain = e2eDomain
+	config.HTTP = true
+	config.IngressClass = "nginx"
+	require.NoError(t, config.Validate())
+	contr, err := controller.Daemon(ctx, client, nil, namespace, &config, time.Minute)
+	require.NoError(t, err)
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	go contr.Run(stop)
+}
+
+// createService creates an exposed service of the backend
+func createService(ctx context.Context, t *testing.T, namespace, name string, annotations map[string]string) {
+	all := map[string]string{exposestrategy.ExposeAnnotation.Key: exposestrategy.ExposeAnnotation.Value}
+	for key, value := range annotations {
+		all[key] = value
+	}
+	_, err := client.CoreV1().Services(namespace).Create(ctx, &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: all},
+		Spec: v1.ServiceSpec{
+			Selector: map[string]string{"app": "echo"},
+			Ports:    []v1.ServicePort{{Name: "http", Port: 80, TargetPort: intstr.FromString("http")}},
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+}
+
+// waitForURL waits for the controller to publish the URL of the service
+func waitForURL(ctx context.Context, t *testing.T, namespace, name string) *url.URL {
+	var exposed string
+	err := wait.PollImmediate(time.Second, e2eTimeout, func() (bool, error) {
+		svc, err := client.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		exposed = svc.Annotations[exposestrategy.ExposeAnnotationKey]
+		return exposed != "", nil
+	})
+	require.NoError(t, err, "URL of service %s published", name)
+	parsed, err := url.Parse(exposed)
+	require.NoError(t, err)
+	return parsed
+}
+
+// waitForStatus waits for ingress-nginx to answer the URL with the status, sending the request to the mapped port of the host
+func waitForStatus(t *testing.T, exposed *url.URL, status int) {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	last := ""
+	err := wait.PollImmediate(2*time.Second, e2eTimeout, func() (bool, error) {
+		request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d%s", *httpPort, exposed.RequestURI()), nil)
+		if err != nil {
+			return false, err
+		}
+		request.Host = exposed.Host
+		response, err := httpClient.Do(request)
+		if err != nil {
+			last = err.Error()
+			return false, nil
+		}
+		defer response.Body.Close()
+		body, _ := ioutil.ReadAll(response.Body)
+		last = fmt.Sprintf("%d %s", response.StatusCode, body)
+		return response.StatusCode == status, nil
+	})
+	require.NoError(t, err, "%s answers %d, last answer: %s", exposed, status, last)
+}
@@ -0,0 +1,156 @@
+//go:build e2e
+// +build e2e
+
+// Package e2e runs the controller against a kind cluster with ingress-nginx and checks the exposed URLs over HTTP,
+// go test -tags e2e ./e2e -v, with docker, kind and kubectl in the path
+package e2e
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var (
+	clusterName  = flag.String("e2e.cluster", "exposecontroller-e2e", "the name of the kind cluster, reused if it exists")
+	keepCluster  = flag.Bool("e2e.keep", false, "keep the kind cluster created by the tests")
+	httpPort     = flag.Int("e2e.http-port", 80, "the port of the host mapped to the HTTP port of ingress-nginx")
+	ingressNginx = flag.String("e2e.ingress-nginx", "https://raw.githubusercontent.com/kubernetes/ingress-nginx/controller-v1.8.1/deploy/static/provider/kind/deploy.yaml",
+		"the manifest of ingress-nginx for kind")
+)
+
+// kindConfig labels the node for ingress-nginx and maps its HTTP port on the host
+const kindConfig = `kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+nodes:
+- role: control-plane
+  kubeadmConfigPatches:
+  - |
+    kind: InitConfiguration
+    nodeRegistration:
+      kubeletExtraArgs:
+        node-labels: "ingress-ready=true"
+  extraPortMappings:
+  - containerPort: 80
+    hostPort: %d
+    protocol: TCP
+`
+
+// client is the client of the kind cluster
+var client kubernetes.Interface
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	for _, tool := range []string{"docker", "kind", "kubectl"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			fmt.Fprintf(os.Stderr, "skipping the e2e tests, %s is not installed\n", tool)
+			os.Exit(0)
+		}
+	}
+	dir, err := ioutil.TempDir("", "exposecontroller-e2e")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	created, err := setupCluster(dir)
+	code := 1
+	if err == nil {
+		code = m.Run()
+	} else {
+		fmt.Fprintf(os.Stderr, "failed to set up the kind cluster: %s\n", err)
+	}
+	if created && !*keepCluster {
+		if err := run("kind", "delete", "cluster", "--name", *clusterName); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to delete the kind cluster: %s\n", err)
+		}
+	}
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// setupCluster creates the kind cluster unless it exists, installs ingress-nginx and creates the client,
+// tells if the cluster was created
+func setupCluster(dir string) (bool, error) {
+	clusters, err := output("kind", "get", "clusters")
+	if err != nil {
+		return false, err
+	}
+	created := false
+	if !contains(strings.Fields(clusters), *clusterName) {
+		config := filepath.Join(dir, "kind.yaml")
+		if err := ioutil.WriteFile(config, []byte(fmt.Sprintf(kindConfig, *httpPort)), 0600); err != nil {
+			return false, errors.Wrap(err, "failed to write the kind config")
+		}
+		if err := run("kind", "create", "cluster", "--name", *clusterName, "--config", config, "--wait", "2m"); err != nil {
+			return false, err
+		}
+		created = true
+	}
+	kubeConfig, err := output("kind", "get", "kubeconfig", "--name", *clusterName)
+	if err != nil {
+		return created, err
+	}
+	kubeConfigPath := filepath.Join(dir, "kubeconfig")
+	if err := ioutil.WriteFile(kubeConfigPath, []byte(kubeConfig), 0600); err != nil {
+		return created, errors.Wrap(err, "failed to write the kube config")
+	}
+	if err := run("kubectl", "--kubeconfig", kubeConfigPath, "apply", "-f", *ingressNginx); err != nil {
+		return created, err
+	}
+	// the admission webhook of the controller validates the ingresses once ready
+	if err := run("kubectl", "--kubeconfig", kubeConfigPath, "wait", "--namespace", "ingress-nginx", "--for=condition=ready", "pod",
+		"--selector=app.kubernetes.io/component=controller", "--timeout=5m"); err != nil {
+		return created, err
+	}
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
+	if err != nil {
+		return created, errors.Wrap(err, "failed to load the kube config")
+	}
+	client, err = kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return created, errors.Wrap(err, "failed to create the client")
+	}
+	return created, nil
+}
+
+// run runs the command with its output on the output of the tests
+func run(name string, args ...string) error {
+	cmd := exec.CommandContext(context.Background(), name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "%s %s failed", name, strings.Join(args, " "))
+	}
+	return nil
+}
+
+// output runs the command and returns its output
+func output(name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(context.Background(), name, args...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "%s %s failed", name, strings.Join(args, " "))
+	}
+	return string(out), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/devopscare/exposecontroller/controller"
+)
+
+func runExplain(ctx context.Context, args []string) error {
+	f := newCommandFlags("explain", "svc/<name> [-n namespace] [--controller-namespace namespace]")
+	controllerNamespace := f.String("controller-namespace", "", "the namespace of the exposecontroller config map, the one of the service if empty")
+	name, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+	restConfig, namespace, err := f.restConfig()
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to create client")
+	}
+	if *controllerNamespace == "" {
+		*controllerNamespace = namespace
+	}
+	config := tryFindConfig(ctx, client, *controllerNamespace)
+	if config == nil {
+		defaults := controller.DefaultConfig
+		config = &defaults
+	}
+	err = config.Validate()
+	if err != nil {
+		return err
+	}
+	return controller.Explain(ctx, restConfig, namespace, name, config, os.Stdout)
+}
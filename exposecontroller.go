@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -9,7 +10,9 @@ import (
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/devopscare/exposecontroller/controller"
@@ -17,14 +20,15 @@ import (
 	"k8s.io/klog"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/cache"
 )
 
 const (
 	healthPort = 10254
+	// resyncTokenEnv is the environment variable holding the bearer token of the resync endpoint
+	resyncTokenEnv = "EXPOSECONTROLLER_RESYNC_TOKEN"
 )
 
 var (
@@ -41,18 +45,24 @@ var (
 
 	healthzPort = flag.Int("healthz-port", healthPort, "port for healthz endpoint.")
 
+	grpcHealthPort = flag.Int("grpc-health-port", 0, "port for the gRPC health service, disabled if 0.")
+
 	profiling = flag.Bool("profiling", true, `Enable profiling via web interface host:port/debug/pprof/`)
 
 	daemon  = flag.Bool("daemon", false, `Run as daemon mode watching changes as it happens.`)
-	cleanup = flag.Bool("cleanup", false, `Removes Ingress rules that were generated by exposecontroller`)
-
-	domain                = flag.String("domain", "", "Domain to use with your DNS provider (default: .nip.io).")
-	filter                = flag.String("filter", "", "The filter of service names to look for when cleaning up")
-	exposer               = flag.String("exposer", "", "Which strategy exposecontroller should use to access applications")
-	httpb                 = flag.Bool("http", false, `Use HTTP`)
-	watchNamespaces       = flag.String("watch-namespaces", "", "Exposecontroller will only look at the provided namespace")
-	watchCurrentNamespace = flag.Bool("watch-current-namespace", true, `Exposecontroller will look at the current namespace only - (default: 'true' unless --watch-namespace specified)`)
-	services              = flag.String("services", "", "List of comma separated service names which will be exposed, if empty all services from namespace will be considered")
+	cleanup = flag.Bool("cleanup", false, `Removes Ingress rules and HTTP routes that were generated by exposecontroller`)
+
+	filter = flag.String("filter", "", "The filter of service names to look for when cleaning up")
+
+	// the flags named after the keys of the config override them when set on the command line
+	_ = flag.String("domain", "", "Domain to use with your DNS provider (default: .nip.io).")
+	_ = flag.String("exposer", "", "Which strategy exposecontroller should use to access applications")
+	_ = flag.Bool("http", false, `Use HTTP`)
+	_ = flag.String("watch-namespaces", "", "Exposecontroller will only look at the provided namespace")
+	_ = flag.Bool("watch-current-namespace", true, `Exposecontroller will look at the current namespace only - (default: 'true' unless --watch-namespace specified)`)
+	_ = flag.String("services", "", "List of comma separated service names which will be exposed, if empty all services from namespace will be considered")
+	_ = flag.Int("shard-index", 0, "Shard of the namespaces handled by this replica, from 0 to --shard-count excluded")
+	_ = flag.Int("shard-count", 0, "Number of shards splitting the namespaces between the replicas by hash, disabled if less than 2")
 )
 
 func init() {
@@ -60,8 +70,13 @@ func init() {
 }
 
 func main() {
+	// the subcommands have their own flags
+	if runCommand(os.Args[1:]) {
+		return
+	}
 	flag.Parse()
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	var restClientConfig *rest.Config
 	var err error
@@ -75,6 +90,14 @@ func main() {
 	if err != nil {
 		klog.Fatalf("failed to create REST client config: %s", err)
 	}
+	if *chaos > 0 {
+		wrapper, err := newChaosWrapper(*chaos, time.Now().UnixNano())
+		if err != nil {
+			klog.Fatalf("%s", err)
+		}
+		klog.Warningf("Chaos mode, %v of the API requests fail with random errors", *chaos)
+		restClientConfig.Wrap(wrapper)
+	}
 
 	kubeClient, err := kubernetes.NewForConfig(restClientConfig)
 	for i := 0; i < 30; i++ {
@@ -89,6 +112,10 @@ func main() {
 	if err != nil {
 		klog.Fatalf("failed to create client: %s", err)
 	}
+	dynamicClient, err := dynamic.NewForConfig(restClientConfig)
+	if err != nil {
+		klog.Fatalf("failed to create dynamic client: %s", err)
+	}
 	currentNamespace := os.Getenv("KUBERNETES_NAMESPACE")
 	if len(currentNamespace) == 0 {
 		currentNamespace = metav1.NamespaceDefault
@@ -135,28 +162,25 @@ func main() {
 	} else {
 		klog.Infof("Loaded config file %s", *configFile)
 	}
-	klog.Infof("Config file before overrides\n%s", controllerConfig.String())
-
-	if *domain != "" {
-		controllerConfig.Domain = *domain
-	}
-	if *exposer != "" {
-		controllerConfig.Exposer = *exposer
-	}
-	if *httpb {
-		controllerConfig.HTTP = *httpb
+	if controllerConfig == nil {
+		defaults := controller.DefaultConfig
+		controllerConfig = &defaults
 	}
+	klog.Infof("Config file before overrides\n%s", controllerConfig.String())
 
-	if *watchCurrentNamespace {
-		controllerConfig.WatchCurrentNamespace = *watchCurrentNamespace
-	}
-	if *watchNamespaces != "" {
-		controllerConfig.WatchNamespaces = *watchNamespaces
-		controllerConfig.WatchCurrentNamespace = false
+	// the layers are the defaults, the config file, the environment variables, then the flags
+	err = controllerConfig.ApplyEnv(os.LookupEnv)
+	if err != nil {
+		klog.Fatalf("%s", err)
 	}
-
-	if *services != "" {
-		controllerConfig.Services = strings.Split(*services, ",")
+	flag.Visit(func(f *flag.Flag) {
+		if _, err := controllerConfig.Set(f.Name, f.Value.String()); err != nil {
+			klog.Fatalf("invalid flag --%s: %s", f.Name, err)
+		}
+	})
+	err = controllerConfig.Validate()
+	if err != nil {
+		klog.Fatalf("invalid config: %s", err)
 	}
 
 	klog.Infof("Config file after overrides\n%s", controllerConfig.String())
@@ -171,7 +195,16 @@ func main() {
 	}
 
 	if *cleanup {
-		err = exposestrategy.CleanIngressStrategy(ctx, kubeClient, watchNamespaces)
+		provider, err := exposestrategy.ParseProviderLabel(controllerConfig.ProviderLabel)
+		if err != nil {
+			klog.Fatalf("%s", err)
+		}
+		provider.GeneratedBy = controllerConfig.GeneratedBy
+		propagation, err := exposestrategy.ParseDeletePropagation(controllerConfig.DeletePropagation)
+		if err != nil {
+			klog.Fatalf("%s", err)
+		}
+		err = exposestrategy.CleanIngressStrategy(ctx, kubeClient, dynamicClient, watchNamespaces, provider, controllerConfig.NeverDelete, propagation)
 		if err != nil {
 			klog.Fatalf("Could not clean: %v", err)
 		}
@@ -180,16 +213,37 @@ func main() {
 
 	if *daemon {
 		klog.Infof("Watching services in namespaces: `%s`", watchNamespaces)
-		contr, err := controller.Daemon(ctx, kubeClient, watchNamespaces, controllerConfig, *resyncPeriod)
+		contr, err := controller.Daemon(ctx, kubeClient, dynamicClient, watchNamespaces, controllerConfig, *resyncPeriod)
 		if err == nil {
-			go registerHandlers(contr)
-			contr.Run(wait.NeverStop)
+			go registerHandlers(contr, os.Getenv(resyncTokenEnv))
+			if *grpcHealthPort != 0 {
+				go serveGRPCHealth(ctx, contr, *grpcHealthPort)
+			}
+			// SIGHUP forces a resync
+			hangups := make(chan os.Signal, 1)
+			signal.Notify(hangups, syscall.SIGHUP)
+			go func() {
+				for range hangups {
+					contr.Resync()
+				}
+			}()
+			// the context is cancelled on shutdown, the summary is logged once the controller stopped
+			stops := make(chan os.Signal, 1)
+			signal.Notify(stops, syscall.SIGTERM, syscall.SIGINT)
+			go func() {
+				<-stops
+				klog.Infof("Shutting down")
+				cancel()
+			}()
+			contr.Run(ctx.Done())
+			contr.LogStats()
+			klog.Flush()
 		} else {
 			klog.Fatalf("%s", err)
 		}
 	} else {
 		klog.Infof("Running in : `%s`", watchNamespaces)
-		err = controller.Run(ctx, kubeClient, watchNamespaces, controllerConfig, *timeout)
+		err = controller.Run(ctx, kubeClient, dynamicClient, watchNamespaces, controllerConfig, *timeout)
 	}
 
 	if err != nil {
@@ -231,10 +285,11 @@ func tryFindConfig(ctx context.Context, kubeClient kubernetes.Interface, ns stri
 	return controllerConfig
 }
 
-func registerHandlers(controller cache.Controller) {
+func registerHandlers(contr controller.Controller, resyncToken string) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", func(res http.ResponseWriter, req *http.Request) {
-		ready := controller.HasSynced()
+		ready := controllerReady(contr)
+		ingressController := contr.IngressControllerStatus()
 
 		if ready {
 			res.WriteHeader(http.StatusOK)
@@ -242,12 +297,56 @@ func registerHandlers(controller cache.Controller) {
 			res.WriteHeader(http.StatusServiceUnavailable)
 		}
 
+		status := map[string]interface{}{
+			"ready":  ready,
+			"caches": contr.CacheStatus(),
+		}
+		if ingressController != nil {
+			status["ingressController"] = ingressController
+		}
+		enc := json.NewEncoder(res)
+		_ = enc.Encode(status)
+	})
+
+	// the liveness does not depend on the ingress controller, not to restart the controller while it is down
+	mux.HandleFunc("/livez", func(res http.ResponseWriter, req *http.Request) {
+		live := contr.HasSynced()
+		if live {
+			res.WriteHeader(http.StatusOK)
+		} else {
+			res.WriteHeader(http.StatusServiceUnavailable)
+		}
+
 		enc := json.NewEncoder(res)
 		_ = enc.Encode(map[string]interface{}{
-			"ready": ready,
+			"live":   live,
+			"caches": contr.CacheStatus(),
 		})
 	})
 
+	mux.HandleFunc("/metrics", func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_ = controller.WriteMetrics(res)
+	})
+
+	// the resync endpoint is only enabled with a token
+	if resyncToken != "" {
+		mux.HandleFunc("/resync", func(res http.ResponseWriter, req *http.Request) {
+			if req.Method != http.MethodPost {
+				res.Header().Set("Allow", http.MethodPost)
+				res.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			auth := req.Header.Get("Authorization")
+			if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+resyncToken)) != 1 {
+				res.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			contr.Resync()
+			res.WriteHeader(http.StatusAccepted)
+		})
+	}
+
 	if *profiling {
 		mux.HandleFunc("/debug/pprof/", pprof.Index)
 		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
@@ -0,0 +1,120 @@
+package exposestrategy
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// AcmeChallengeHTTP01 lets cert-manager issue the certificate of each ingress with the "kubernetes.io/tls-acme" annotation
+	AcmeChallengeHTTP01 = "http01"
+	// AcmeChallengeDNS01 makes the controller manage a wildcard certificate by domain, for the clusters not reachable by the ACME servers
+	AcmeChallengeDNS01 = "dns01"
+)
+
+// CertificateResource is the resource of the cert-manager certificates
+var CertificateResource = schema.GroupVersionResource{
+	Group:    "cert-manager.io",
+	Version:  "v1",
+	Resource: "certificates",
+}
+
+// parseAcmeChallengeType checks the ACME challenge type, "http01" by default
+func parseAcmeChallengeType(value string) (string, error) {
+	switch value {
+	case "", AcmeChallengeHTTP01:
+		return AcmeChallengeHTTP01, nil
+	case AcmeChallengeDNS01:
+		return AcmeChallengeDNS01, nil
+	default:
+		return "", errors.Errorf("invalid ACME challenge type \"%s\", must be \"%s\" or \"%s\"",
+			value, AcmeChallengeHTTP01, AcmeChallengeDNS01)
+	}
+}
+
+// wildcardSecretName returns the secret of the wildcard certificate covering the host
+func wildcardSecretName(host string) string {
+	return "wildcard." + wildcardDomain(host)
+}
+
+// wildcardDomain returns the domain of the host covered by a wildcard certificate
+func wildcardDomain(host string) string {
+	if strings.HasPrefix(host, "*.") {
+		return host[2:]
+	}
+	if i := strings.Index(host, "."); i >= 0 {
+		return host[i+1:]
+	}
+	return host
+}
+
+// buildCertificate builds the wildcard certificate of the domain, shared by the ingresses of the namespace
+// it has no owner reference and is deleted on sync once no generated ingress uses its secret
+func buildCertificate(namespace, domain, issuer string, provider ProviderLabel) *unstructured.Unstructured {
+	secretName := "wildcard." + domain
+	certificate := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"secretName": secretName,
+			"dnsNames":   []interface{}{"*." + domain},
+			"issuerRef": map[string]interface{}{
+				"name":  issuer,
+				"kind":  "ClusterIssuer",
+				"group": "cert-manager.io",
+			},
+		},
+	}}
+	certificate.SetAPIVersion(CertificateResource.GroupVersion().String())
+	certificate.SetKind("Certificate")
+	certificate.SetNamespace(namespace)
+	certificate.SetName(secretName)
+	provider = provider.orLegacy()
+	certificate.SetLabels(map[string]string{
+		provider.Key: provider.Value,
+	})
+	certificate.SetAnnotations(map[string]string{
+		GeneratedByAnnotationKey: provider.Marker(),
+	})
+	return certificate
+}
+
+// applyCertificates generates the wildcard certificates of the hosts using their secrets
+func (s *IngressStrategy) applyCertificates(namespace string, hosts []ingressHost) error {
+	applied := map[string]bool{}
+	for _, h := range hosts {
+		domain := wildcardDomain(h.name)
+		if h.tlsSecret != wildcardSecretName(h.name) || applied[domain] {
+			continue
+		}
+		applied[domain] = true
+		err := applyGeneratedObject(s.ctx, s.dynamicClient, CertificateResource, buildCertificate(namespace, domain, s.acmeIssuer, s.provider))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanCertificates deletes the generated certificates whose secret is not used by a generated ingress
+// the used secrets are keyed by "namespace/name"
+func (s *IngressStrategy) cleanCertificates(used map[string]bool) error {
+	var unused []*unstructured.Unstructured
+	err := eachGeneratedObject(s.ctx, s.dynamicClient, CertificateResource, s.namespace, s.provider, s.pageSize, func(certificate *unstructured.Unstructured) {
+		secretName, _, _ := unstructured.NestedString(certificate.Object, "spec", "secretName")
+		if s.provider.IsGenerated(certificate.GetAnnotations()) && !used[certificate.GetNamespace()+"/"+secretName] {
+			unused = append(unused, certificate)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for _, certificate := range unused {
+		klog.Infof("the wildcard certificate %s/%s is not used anymore", certificate.GetNamespace(), certificate.GetName())
+		deleteGeneratedObject(s.ctx, s.dynamicClient, CertificateResource, certificate.GetNamespace(), certificate.GetName(), s.neverDelete, s.provider, s.deletePropagation)
+	}
+	return nil
+}
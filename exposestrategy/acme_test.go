@@ -0,0 +1,117 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_AcmeDNS01(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				AdditionalHostsAnnotationKey: "svc.other.com,www.svc.main.my-domain.com=my-secret",
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(service)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			HTTPRouteResource:   "HTTPRouteList",
+			CertificateResource: "CertificateList",
+		})
+	config := &Config{
+		Exposer:           "ingress",
+		Namespace:         "main",
+		Domain:            "my-domain.com",
+		TLSAcme:           true,
+		AcmeChallengeType: "dns01",
+		AcmeIssuer:        "letsencrypt-dns",
+		DynamicClient:     dynamicClient,
+	}
+	strategy, err := NewIngressStrategy(nil, client, config)
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(service))
+
+	ctx := context.Background()
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, ingress.Annotations, "kubernetes.io/tls-acme")
+	assert.Equal(t, []networkingv1.IngressTLS{{
+		Hosts:      []string{"www.svc.main.my-domain.com"},
+		SecretName: "my-secret",
+	}, {
+		Hosts:      []string{"svc.main.my-domain.com"},
+		SecretName: "wildcard.main.my-domain.com",
+	}, {
+		Hosts:      []string{"svc.other.com"},
+		SecretName: "wildcard.other.com",
+	}}, ingress.Spec.TLS)
+
+	certificates := dynamicClient.Resource(CertificateResource).Namespace("main")
+	list, err := certificates.List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, list.Items, 2)
+	certificate, err := certificates.Get(ctx, "wildcard.main.my-domain.com", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "exposecontroller", certificate.GetAnnotations()["fabric8.io/generated-by"])
+	assert.Empty(t, certificate.GetOwnerReferences(), "shared by the ingresses of the namespace")
+	assert.Equal(t, map[string]interface{}{
+		"secretName": "wildcard.main.my-domain.com",
+		"dnsNames":   []interface{}{"*.main.my-domain.com"},
+		"issuerRef": map[string]interface{}{
+			"name":  "letsencrypt-dns",
+			"kind":  "ClusterIssuer",
+			"group": "cert-manager.io",
+		},
+	}, certificate.Object["spec"])
+
+	// the certificates are deleted once no ingress uses them
+	ingress.ResourceVersion = "1"
+	_, err = client.NetworkingV1().Ingresses("main").Update(ctx, ingress, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	service.Annotations = nil
+	require.NoError(t, strategy.Add(service))
+	require.NoError(t, strategy.Sync())
+	list, err = certificates.List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	if assert.Len(t, list.Items, 1) {
+		assert.Equal(t, "wildcard.main.my-domain.com", list.Items[0].GetName())
+	}
+	require.NoError(t, strategy.Delete(service))
+	client.NetworkingV1().Ingresses("main").Delete(ctx, "svc", metav1.DeleteOptions{})
+	require.NoError(t, strategy.Sync())
+	list, err = certificates.List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, list.Items)
+
+	config.AcmeIssuer = ""
+	_, err = NewIngressStrategy(nil, client, config)
+	assert.EqualError(t, err, "an ACME issuer is required with the dns01 challenge")
+	config.AcmeChallengeType = "tls-alpn-01"
+	_, err = NewIngressStrategy(nil, client, config)
+	assert.EqualError(t, err, `invalid ACME challenge type "tls-alpn-01", must be "http01" or "dns01"`)
+}
+
+func TestWildcardDomain(t *testing.T) {
+	assert.Equal(t, "main.my-domain.com", wildcardDomain("svc.main.my-domain.com"))
+	assert.Equal(t, "my-domain.com", wildcardDomain("*.my-domain.com"))
+	assert.Equal(t, "localhost", wildcardDomain("localhost"))
+}
@@ -0,0 +1,42 @@
+package exposestrategy
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+)
+
+const (
+	// TLSAcmeAnnotationKey is "false" for a service to opt out of the ACME certificates of the controller and of the domains,
+	// such as the services authenticating with client certificates
+	TLSAcmeAnnotationKey = "fabric8.io/tls.acme"
+	// TLSSecretAnnotationKey is the secret of the certificate of a service opting out of ACME, exposed in plain HTTP without it
+	TLSSecretAnnotationKey = "fabric8.io/tls.secret-name"
+)
+
+// acmeOptOut is the TLS setting of a service opting out of ACME
+type acmeOptOut struct {
+	enabled    bool
+	secretName string
+}
+
+// parseACMEOptOut parses the annotations of the service opting out of ACME
+func parseACMEOptOut(svc *v1.Service) (acmeOptOut, error) {
+	var optOut acmeOptOut
+	if value, ok := svc.Annotations[TLSAcmeAnnotationKey]; ok {
+		acme, err := strconv.ParseBool(value)
+		if err != nil {
+			return optOut, errors.Errorf("invalid annotation \"%s\" in service %s/%s, must be \"true\" or \"false\"",
+				TLSAcmeAnnotationKey, svc.Namespace, svc.Name)
+		}
+		optOut.enabled = !acme
+	}
+	optOut.secretName = svc.Annotations[TLSSecretAnnotationKey]
+	if optOut.secretName != "" && !optOut.enabled {
+		return optOut, errors.Errorf("annotation \"%s\" in service %s/%s requires \"%s\" to be \"false\"",
+			TLSSecretAnnotationKey, svc.Namespace, svc.Name, TLSAcmeAnnotationKey)
+	}
+	return optOut, nil
+}
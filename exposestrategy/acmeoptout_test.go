@@ -0,0 +1,88 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_ACMEOptOut(t *testing.T) {
+	plain := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "main",
+			Name:            "plain",
+			Annotations:     map[string]string{TLSAcmeAnnotationKey: "false"},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	mtls := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "mtls",
+			Annotations: map[string]string{
+				TLSAcmeAnnotationKey:   "false",
+				TLSSecretAnnotationKey: "mtls-tls",
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8443}},
+		},
+	}
+	client := fake.NewSimpleClientset(plain, mtls)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:   "ingress",
+		Namespace: "main",
+		Domain:    "my-domain.com",
+		TLSAcme:   true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(plain))
+	require.NoError(t, strategy.Add(mtls))
+
+	ctx := context.Background()
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "plain", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, ingress.Annotations, "kubernetes.io/tls-acme")
+	assert.Empty(t, ingress.Spec.TLS)
+	service, err := client.CoreV1().Services("main").Get(ctx, "plain", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "http://plain.main.my-domain.com", service.Annotations[ExposeAnnotationKey])
+
+	ingress, err = client.NetworkingV1().Ingresses("main").Get(ctx, "mtls", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, ingress.Annotations, "kubernetes.io/tls-acme")
+	assert.Equal(t, []networkingv1.IngressTLS{{
+		Hosts:      []string{"mtls.main.my-domain.com"},
+		SecretName: "mtls-tls",
+	}}, ingress.Spec.TLS)
+	service, err = client.CoreV1().Services("main").Get(ctx, "mtls", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://mtls.main.my-domain.com", service.Annotations[ExposeAnnotationKey])
+}
+
+func TestParseACMEOptOut(t *testing.T) {
+	svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "svc"}}
+	optOut, err := parseACMEOptOut(svc)
+	require.NoError(t, err)
+	assert.False(t, optOut.enabled)
+
+	svc.Annotations = map[string]string{TLSAcmeAnnotationKey: "no"}
+	_, err = parseACMEOptOut(svc)
+	assert.EqualError(t, err, `invalid annotation "fabric8.io/tls.acme" in service main/svc, must be "true" or "false"`)
+
+	svc.Annotations = map[string]string{TLSAcmeAnnotationKey: "true", TLSSecretAnnotationKey: "svc-tls"}
+	_, err = parseACMEOptOut(svc)
+	assert.EqualError(t, err, `annotation "fabric8.io/tls.secret-name" in service main/svc requires "fabric8.io/tls.acme" to be "false"`)
+}
@@ -0,0 +1,82 @@
+package exposestrategy
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ALBIngressClass is the ingress class of the AWS load balancer controller by default
+	ALBIngressClass = "alb"
+
+	albAnnotationPrefix = "alb.ingress.kubernetes.io/"
+)
+
+var (
+	albSchemes     = []string{"internet-facing", "internal"}
+	albTargetTypes = []string{"ip", "instance"}
+)
+
+// ALBStrategy is an ingress strategy for the AWS load balancer controller
+// the ingresses have the ALB annotations and prefix paths, TLS is terminated with the ACM certificate if any
+type ALBStrategy struct {
+	*IngressStrategy
+}
+
+// NewALBStrategy creates a new ALBStrategy
+func NewALBStrategy(ctx context.Context, client kubernetes.Interface, config *Config) (ExposeStrategy, error) {
+	scheme := config.ALBScheme
+	if scheme == "" {
+		scheme = albSchemes[0]
+	} else if !contains(albSchemes, scheme) {
+		return nil, errors.Errorf("unknown ALB scheme \"%s\", must be one of %v", scheme, albSchemes)
+	}
+	targetType := config.ALBTargetType
+	if targetType == "" {
+		targetType = albTargetTypes[0]
+	} else if !contains(albTargetTypes, targetType) {
+		return nil, errors.Errorf("unknown ALB target type \"%s\", must be one of %v", targetType, albTargetTypes)
+	}
+	if config.IngressClass == "" {
+		config.IngressClass = ALBIngressClass
+	}
+	strategy, err := NewIngressStrategy(ctx, client, config)
+	if err != nil {
+		return nil, err
+	}
+	s := strategy.(*IngressStrategy)
+	s.pathType = networkingv1.PathTypePrefix
+	s.backendTLS = albBackendTLS
+	s.sslRedirect = albSSLRedirect
+	s.noCanaryIngress = true
+	s.controllerAnnotations = map[string]string{
+		albAnnotationPrefix + "scheme":      scheme,
+		albAnnotationPrefix + "target-type": targetType,
+	}
+	if config.ALBCertificateARN != "" {
+		s.controllerAnnotations[albAnnotationPrefix+"certificate-arn"] = config.ALBCertificateARN
+		s.controllerAnnotations[albAnnotationPrefix+"listen-ports"] = `[{"HTTP": 80}, {"HTTPS": 443}]`
+		s.controllerAnnotations[albAnnotationPrefix+"ssl-redirect"] = "443"
+		s.tlsWithoutSecret = true
+	}
+	// the ingresses of a group share one load balancer
+	if config.ALBGroupName != "" {
+		s.controllerAnnotations[albAnnotationPrefix+"group.name"] = config.ALBGroupName
+	}
+	klog.Infof("Using ALB scheme %s, target type %s and group %s", scheme, targetType, config.ALBGroupName)
+	return &ALBStrategy{IngressStrategy: s}, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package exposestrategy
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestALBStrategy_Add(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key: ExposeAnnotation.Value,
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 80}},
+		},
+	}
+	client := fake.NewSimpleClientset(svc)
+
+	strategy, err := NewALBStrategy(nil, client, &Config{
+		Exposer:           "alb",
+		Namespace:         "main",
+		Domain:            "my-domain.com",
+		ALBCertificateARN: "arn:aws:acm:eu-west-1:123456789012:certificate/abc",
+		ALBGroupName:      "shared",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(svc))
+
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(nil, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "alb", ingress.Annotations["kubernetes.io/ingress.class"])
+	assert.Equal(t, "internet-facing", ingress.Annotations["alb.ingress.kubernetes.io/scheme"])
+	assert.Equal(t, "ip", ingress.Annotations["alb.ingress.kubernetes.io/target-type"])
+	assert.Equal(t, "arn:aws:acm:eu-west-1:123456789012:certificate/abc", ingress.Annotations["alb.ingress.kubernetes.io/certificate-arn"])
+	assert.Equal(t, "443", ingress.Annotations["alb.ingress.kubernetes.io/ssl-redirect"])
+	assert.Equal(t, "shared", ingress.Annotations["alb.ingress.kubernetes.io/group.name"])
+	assert.Empty(t, ingress.Spec.TLS)
+	require.Len(t, ingress.Spec.Rules, 1)
+	path := ingress.Spec.Rules[0].HTTP.Paths[0]
+	assert.Equal(t, "/", path.Path)
+	assert.Equal(t, networkingv1.PathTypePrefix, *path.PathType)
+
+	svc, err = client.CoreV1().Services("main").Get(nil, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://svc.main.my-domain.com", svc.Annotations[ExposeAnnotationKey])
+}
+
+func TestNewALBStrategy(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	strategy, err := NewALBStrategy(nil, client, &Config{
+		Exposer:       "alb",
+		Domain:        "my-domain.com",
+		IngressClass:  "alb-internal",
+		ALBScheme:     "internal",
+		ALBTargetType: "instance",
+	})
+	require.NoError(t, err)
+	s := strategy.(*ALBStrategy)
+	assert.Equal(t, "alb-internal", s.ingressClass)
+	assert.Equal(t, map[string]string{
+		"alb.ingress.kubernetes.io/scheme":      "internal",
+		"alb.ingress.kubernetes.io/target-type": "instance",
+	}, s.controllerAnnotations)
+	assert.False(t, s.tlsWithoutSecret)
+
+	_, err = NewALBStrategy(nil, client, &Config{Exposer: "alb", Domain: "my-domain.com", ALBScheme: "public"})
+	assert.Error(t, err)
+	_, err = NewALBStrategy(nil, client, &Config{Exposer: "alb", Domain: "my-domain.com", ALBTargetType: "pod"})
+	assert.Error(t, err)
+}
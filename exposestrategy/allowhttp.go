@@ -0,0 +1,68 @@
+package exposestrategy
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+)
+
+const (
+	// AllowHTTPAnnotationKey annotation accepts plain HTTP on the ingress of a service exposed with TLS, without redirect to HTTPS,
+	// such as for the webhook consumers not supporting TLS
+	AllowHTTPAnnotationKey = "fabric8.io/expose.allow-http"
+	// ExposeHTTPURLAnnotationKey annotation will be created with the plain HTTP URL of the services allowing HTTP next to HTTPS
+	ExposeHTTPURLAnnotationKey = "fabric8.io/exposeHttpURL"
+)
+
+// sslRedirectAnnotations are the annotations of an ingress controller redirecting HTTP to HTTPS,
+// by the value accepting plain HTTP, the annotations with an empty value are removed instead
+type sslRedirectAnnotations map[string]string
+
+var (
+	nginxSSLRedirect = sslRedirectAnnotations{
+		nginxAnnotationPrefix + "ssl-redirect":       "false",
+		nginxAnnotationPrefix + "force-ssl-redirect": "false",
+	}
+	albSSLRedirect = sslRedirectAnnotations{
+		albAnnotationPrefix + "ssl-redirect": "",
+	}
+)
+
+// allowHTTP sets the annotations accepting plain HTTP
+func (a sslRedirectAnnotations) allowHTTP(annotations map[string]string) {
+	for key, value := range a {
+		if value == "" {
+			delete(annotations, key)
+		} else {
+			annotations[key] = value
+		}
+	}
+}
+
+// parseAllowHTTP tells if the service allows plain HTTP next to HTTPS
+func parseAllowHTTP(svc *v1.Service) (bool, error) {
+	value, ok := svc.Annotations[AllowHTTPAnnotationKey]
+	if !ok {
+		return false, nil
+	}
+	allow, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, newAnnotationParseError(svc, AllowHTTPAnnotationKey, value,
+			errors.Errorf("must be \"true\" or \"false\", got \"%s\"", value))
+	}
+	return allow, nil
+}
+
+// setHTTPURL publishes the plain HTTP URL of the service next to its HTTPS one, removed if the host is empty
+func setHTTPURL(svc *v1.Service, hostName string, port int32, path string) {
+	if hostName == "" {
+		delete(svc.Annotations, ExposeHTTPURLAnnotationKey)
+		return
+	}
+	if annotationPath, ok := svc.Annotations[APIServicePathAnnotationKey]; ok {
+		path = annotationPath
+	}
+	svc.Annotations[ExposeHTTPURLAnnotationKey] = buildURL(hostName, port, path, "http")
+}
@@ -0,0 +1,97 @@
+package exposestrategy
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_AllowHTTP(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key:             ExposeAnnotation.Value,
+				AllowHTTPAnnotationKey:           "true",
+				"fabric8.io/ingress.annotations": "nginx.ingress.kubernetes.io/ssl-redirect: \"true\"",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(svc)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:       "ingress",
+		Namespace:     "main",
+		Domain:        "my-domain.com",
+		TLSSecretName: "tls",
+		HTTPPort:      8080,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(svc))
+	setIngressVersions(t, client)
+
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(nil, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "false", ingress.Annotations["nginx.ingress.kubernetes.io/ssl-redirect"], "the annotation of the service is overridden")
+	assert.Equal(t, "false", ingress.Annotations["nginx.ingress.kubernetes.io/force-ssl-redirect"])
+	assert.NotEmpty(t, ingress.Spec.TLS)
+	svc, err = client.CoreV1().Services("main").Get(nil, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://svc.main.my-domain.com", svc.Annotations[ExposeAnnotationKey])
+	assert.Equal(t, "http://svc.main.my-domain.com:8080", svc.Annotations[ExposeHTTPURLAnnotationKey])
+
+	// the plain HTTP URL is removed with the annotation
+	delete(svc.Annotations, AllowHTTPAnnotationKey)
+	require.NoError(t, strategy.Add(svc))
+	svc, err = client.CoreV1().Services("main").Get(nil, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, svc.Annotations, ExposeHTTPURLAnnotationKey)
+
+	svc.Annotations[AllowHTTPAnnotationKey] = "yes"
+	assert.EqualError(t, strategy.Add(svc),
+		`failed to parse annotation "fabric8.io/expose.allow-http" in service main/svc: must be "true" or "false", got "yes"`)
+}
+
+func TestALBStrategy_AllowHTTP(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key:   ExposeAnnotation.Value,
+				AllowHTTPAnnotationKey: "true",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 80}},
+		},
+	}
+	client := fake.NewSimpleClientset(svc)
+	strategy, err := NewALBStrategy(nil, client, &Config{
+		Exposer:           "alb",
+		Namespace:         "main",
+		Domain:            "my-domain.com",
+		ALBCertificateARN: "arn:aws:acm:eu-west-1:123456789012:certificate/abc",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(svc))
+
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(nil, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, ingress.Annotations, "alb.ingress.kubernetes.io/ssl-redirect")
+	assert.Equal(t, `[{"HTTP": 80}, {"HTTPS": 443}]`, ingress.Annotations["alb.ingress.kubernetes.io/listen-ports"])
+	svc, err = client.CoreV1().Services("main").Get(nil, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://svc.main.my-domain.com", svc.Annotations[ExposeAnnotationKey])
+	assert.Equal(t, "http://svc.main.my-domain.com", svc.Annotations[ExposeHTTPURLAnnotationKey])
+}
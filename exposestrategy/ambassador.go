@@ -38,7 +38,7 @@ func NewAmbassadorStrategy(ctx context.Context, client kubernetes.Interface, con
 
 	var err error
 	if config.Domain == "" {
-		config.Domain, err = getAutoDefaultDomain(ctx, client)
+		config.Domain, err = getAutoDefaultDomain(ctx, client, config)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to get a domain")
 		}
@@ -133,6 +133,9 @@ func (s *AmbassadorStrategy) Add(svc *v1.Service) error {
 	}
 	// Pick the fist port available in the service if no expose port was configured
 	if exposePort == "" {
+		if len(svc.Spec.Ports) == 0 {
+			return newServiceError(svc, ErrNoPorts, "no port to expose in service %s/%s", svc.Namespace, svc.Name)
+		}
 		port := svc.Spec.Ports[0]
 		exposePort = strconv.Itoa(int(port.Port))
 	}
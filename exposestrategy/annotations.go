@@ -0,0 +1,118 @@
+package exposestrategy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// AnnotationParseError is returned when an annotation of a service cannot be parsed
+// the line and column are those of the YAML error in the annotation, 0 if unknown
+type AnnotationParseError struct {
+	Namespace  string
+	Service    string
+	Annotation string
+	Line       int
+	Column     int
+	Err        error
+}
+
+func (e *AnnotationParseError) Error() string {
+	position := ""
+	if e.Line > 0 {
+		position = fmt.Sprintf(" at line %d, column %d", e.Line, e.Column)
+	}
+	return fmt.Sprintf("failed to parse annotation \"%s\" in service %s/%s%s: %v",
+		e.Annotation, e.Namespace, e.Service, position, e.Err)
+}
+
+// Cause returns the YAML error
+func (e *AnnotationParseError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the YAML error
+func (e *AnnotationParseError) Unwrap() error {
+	return e.Err
+}
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+):`)
+
+// parseAnnotationsYAML parses the annotations to pass to the ingress
+// in strict mode, the keys must be unique and valid annotation keys
+func parseAnnotationsYAML(svc *v1.Service, annotation, text string, strict bool, annotations map[string]string) error {
+	var err error
+	if strict {
+		parsed := map[string]string{}
+		err = yaml.UnmarshalStrict([]byte(text), &parsed)
+		if err == nil {
+			keys := make([]string, 0, len(parsed))
+			for key := range parsed {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				value := parsed[key]
+				if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+					parseErr := newAnnotationParseError(svc, annotation, text,
+						errors.Errorf("invalid annotation key \"%s\": %s", key, strings.Join(errs, ", ")))
+					parseErr.locate(text, keyLine(text, key))
+					return parseErr
+				}
+				annotations[key] = value
+			}
+		}
+	} else {
+		err = yaml.Unmarshal([]byte(text), annotations)
+	}
+	if err != nil {
+		return newAnnotationParseError(svc, annotation, text, err)
+	}
+	return nil
+}
+
+// newAnnotationParseError locates the first line mentioned by the YAML error
+// the column is the one of the entry on that line, YAML errors having no column
+func newAnnotationParseError(svc *v1.Service, annotation, text string, err error) *AnnotationParseError {
+	parseErr := &AnnotationParseError{
+		Namespace:  svc.Namespace,
+		Service:    svc.Name,
+		Annotation: annotation,
+		Err:        err,
+	}
+	match := yamlErrorLine.FindStringSubmatch(err.Error())
+	if match == nil {
+		return parseErr
+	}
+	line, _ := strconv.Atoi(match[1])
+	parseErr.locate(text, line)
+	return parseErr
+}
+
+// locate sets the line, and the column of the entry on that line
+func (e *AnnotationParseError) locate(text string, line int) {
+	lines := strings.Split(text, "\n")
+	if line > 0 && line <= len(lines) {
+		e.Line = line
+		e.Column = len(lines[line-1]) - len(strings.TrimLeft(lines[line-1], " \t")) + 1
+	}
+}
+
+// keyLine returns the line of the key in the YAML text, 0 if not found
+func keyLine(text, key string) int {
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.Trim(strings.TrimSpace(line), `"'`)
+		if strings.HasPrefix(line, key) {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,73 @@
+package exposestrategy
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAnnotationsYAML(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+		},
+	}
+	examples := []struct {
+		name   string
+		text   string
+		strict bool
+		line   int
+		column int
+	}{{
+		name: "syntax error",
+		text: "a: b\n  c: d\n",
+		line: 2,
+	}, {
+		name:   "nested value",
+		text:   "a: b\nc:\n    d: e\n",
+		line:   3,
+		column: 5,
+	}, {
+		name:   "duplicate key in strict mode",
+		text:   "a: b\na: c\n",
+		strict: true,
+		line:   2,
+		column: 1,
+	}, {
+		name:   "indented key",
+		text:   "a: b\n  \"bad key!\": c\n",
+		line:   2,
+		column: 3,
+	}, {
+		name:   "invalid key in strict mode",
+		text:   "a: b\n\"bad key!\": c\n",
+		strict: true,
+		line:   2,
+		column: 1,
+	}}
+	for _, example := range examples {
+		annotations := map[string]string{}
+		err := parseAnnotationsYAML(svc, IngressAnnotationsAnnotationKey, example.text, example.strict, annotations)
+		require.Error(t, err, example.name)
+		var parseErr *AnnotationParseError
+		require.True(t, errors.As(errors.Wrap(err, "wrapped"), &parseErr), example.name)
+		assert.Equal(t, "main", parseErr.Namespace)
+		assert.Equal(t, "svc", parseErr.Service)
+		assert.Equal(t, example.line, parseErr.Line, example.name)
+		if example.column > 0 {
+			assert.Equal(t, example.column, parseErr.Column, example.name)
+		}
+	}
+
+	// the last duplicate key wins outside of the strict mode
+	annotations := map[string]string{}
+	err := parseAnnotationsYAML(svc, IngressAnnotationsAnnotationKey, "a: b\na: c\n", false, annotations)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "c"}, annotations)
+}
@@ -24,8 +24,12 @@ const (
 
 // NewAutoStrategy creates a new strategy, choose automatically
 func NewAutoStrategy(ctx context.Context, client kubernetes.Interface, config *Config) (ExposeStrategy, error) {
+	return newAutoStrategy(ctx, client, config, discoverCapabilities(client.Discovery()))
+}
+
+func newAutoStrategy(ctx context.Context, client kubernetes.Interface, config *Config, caps capabilities) (ExposeStrategy, error) {
 	var err error
-	config.Exposer, err = getAutoDefaultExposeRule(client)
+	config.Exposer, err = getAutoDefaultExposeRule(caps)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to automatically get exposer rule.  consider setting 'exposer' type in config.yml")
 	}
@@ -33,34 +37,25 @@ func NewAutoStrategy(ctx context.Context, client kubernetes.Interface, config *C
 
 	// only try to get domain if we need wildcard dns and one wasn't given to us
 	if config.Domain == "" && (strings.EqualFold(ingress, config.Exposer)) {
-		config.Domain, err = getAutoDefaultDomain(ctx, client)
+		config.Domain, err = getAutoDefaultDomain(ctx, client, config)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to get a domain")
 		}
 		klog.Infof("Using domain: %s", config.Domain)
 	}
 
-	return New(ctx, client, config)
+	return newStrategy(ctx, client, config, caps)
 }
 
-func getAutoDefaultExposeRule(c kubernetes.Interface) (string, error) {
-	// lets default to Ingress on kubernetes for now
-	/*
-		nodes, err := c.CoreV1().Nodes().List(metav1.ListOptions{})
-		if err != nil {
-			return "", errors.Wrap(err, "failed to find any nodes")
-		}
-		if len(nodes.Items) == 1 {
-			node := nodes.Items[0]
-			if node.Name == "minishift" || node.Name == "minikube" {
-				return nodePort, nil
-			}
-		}
-	*/
-	return ingress, nil
+// getAutoDefaultExposeRule defaults to Ingress, unless the cluster does not serve the ingresses
+func getAutoDefaultExposeRule(caps capabilities) (string, error) {
+	return caps.autoExposer(), nil
 }
 
-func getAutoDefaultDomain(ctx context.Context, c kubernetes.Interface) (string, error) {
+func getAutoDefaultDomain(ctx context.Context, c kubernetes.Interface, config *Config) (string, error) {
+	if config.PermissionProfile == PermissionProfileNamespace {
+		return "", errors.New("the domain must be configured with the namespace permission profile, the nodes cannot be listed")
+	}
 	nodes, err := c.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return "", errors.Wrap(err, "failed to find any nodes")
@@ -0,0 +1,82 @@
+package exposestrategy
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// AutoDomainNipIO resolves the hosts of <ip>.nip.io to the IP
+	AutoDomainNipIO = "nip.io"
+	// AutoDomainSslipIO resolves the hosts of <ip>.sslip.io to the IP
+	AutoDomainSslipIO = "sslip.io"
+
+	// autoDomainRecheckPeriod is how often the IP of the ingress controller is checked again
+	autoDomainRecheckPeriod = 5 * time.Minute
+)
+
+// autoDomain derives the domain from the load balancer IP of the service of the ingress controller,
+// with a magic DNS resolving the hosts of the domain to the IP
+type autoDomain struct {
+	namespace string
+	name      string
+	provider  string
+}
+
+// parseAutoDomain parses the "namespace/name" of the service of the ingress controller, the provider is nip.io by default
+func parseAutoDomain(service, provider string) (*autoDomain, error) {
+	parts := strings.SplitN(service, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.Errorf("invalid auto domain service \"%s\", must be \"namespace/name\"", service)
+	}
+	switch provider {
+	case "":
+		provider = AutoDomainNipIO
+	case AutoDomainNipIO, AutoDomainSslipIO:
+	default:
+		return nil, errors.Errorf("invalid auto domain provider \"%s\", must be \"%s\" or \"%s\"",
+			provider, AutoDomainNipIO, AutoDomainSslipIO)
+	}
+	return &autoDomain{namespace: parts[0], name: parts[1], provider: provider}, nil
+}
+
+// resolve returns the domain of the load balancer IP of the service
+func (a *autoDomain) resolve(ctx context.Context, client kubernetes.Interface) (string, error) {
+	svc, err := client.CoreV1().Services(a.namespace).Get(ctx, a.name, metav1.GetOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the service %s/%s of the ingress controller", a.namespace, a.name)
+	}
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		// the magic DNS only resolves IPs, not the hostnames of the cloud load balancers
+		if net.ParseIP(ingress.IP) != nil {
+			return ingress.IP + "." + a.provider, nil
+		}
+	}
+	return "", errors.Errorf("the service %s/%s of the ingress controller has no load balancer IP", a.namespace, a.name)
+}
+
+// refreshAutoDomain follows the changes of the load balancer IP of the ingress controller,
+// the ingresses are then updated with the new domain when the services are added again
+func (s *IngressStrategy) refreshAutoDomain() {
+	if s.autoDomain == nil {
+		return
+	}
+	s.scheduleResync(autoDomainRecheckPeriod)
+	domain, err := s.autoDomain.resolve(s.ctx, s.client)
+	if err != nil {
+		klog.Warningf("the domain %s is kept: %v", s.domain, err)
+		return
+	}
+	if domain != s.domain {
+		klog.Infof("the load balancer IP of the ingress controller changed, using domain %s instead of %s", domain, s.domain)
+		s.domain = domain
+	}
+}
@@ -0,0 +1,97 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_AutoDomain(t *testing.T) {
+	controller := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ingress-nginx",
+			Name:      "ingress-nginx-controller",
+		},
+		Spec: v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+		Status: v1.ServiceStatus{LoadBalancer: v1.LoadBalancerStatus{
+			Ingress: []v1.LoadBalancerIngress{{IP: "203.0.113.10"}},
+		}},
+	}
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "main",
+			Name:            "svc",
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(controller, service)
+	var scheduled []time.Duration
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:            "ingress",
+		Namespace:          "main",
+		AutoDomainService:  "ingress-nginx/ingress-nginx-controller",
+		AutoDomainProvider: AutoDomainSslipIO,
+		ScheduleResync: func(d time.Duration) {
+			scheduled = append(scheduled, d)
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(service))
+	assert.Equal(t, []time.Duration{autoDomainRecheckPeriod}, scheduled, "the IP is checked again later")
+
+	ctx := context.Background()
+	ingresses := client.NetworkingV1().Ingresses("main")
+	ingress, err := ingresses.Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "svc.main.203.0.113.10.sslip.io", ingress.Spec.Rules[0].Host)
+
+	// the load balancer gets another IP
+	controller.Status.LoadBalancer.Ingress[0].IP = "203.0.113.20"
+	_, err = client.CoreV1().Services("ingress-nginx").Update(ctx, controller, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	ingress.ResourceVersion = "1"
+	_, err = ingresses.Update(ctx, ingress, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(service))
+	ingress, err = ingresses.Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "svc.main.203.0.113.20.sslip.io", ingress.Spec.Rules[0].Host)
+	service, err = client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "http://svc.main.203.0.113.20.sslip.io", service.Annotations[ExposeAnnotationKey])
+
+	// the domain is kept while the load balancer has no IP
+	controller.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{Hostname: "lb.example.com"}}
+	_, err = client.CoreV1().Services("ingress-nginx").Update(ctx, controller, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	assert.Equal(t, "203.0.113.20.sslip.io", strategy.(*IngressStrategy).domain)
+
+	_, err = NewIngressStrategy(nil, client, &Config{
+		Exposer:           "ingress",
+		AutoDomainService: "ingress-nginx/ingress-nginx-controller",
+	})
+	assert.EqualError(t, err, "failed to get a domain: the service ingress-nginx/ingress-nginx-controller of the ingress controller has no load balancer IP")
+}
+
+func TestParseAutoDomain(t *testing.T) {
+	auto, err := parseAutoDomain("ingress-nginx/controller", "")
+	require.NoError(t, err)
+	assert.Equal(t, &autoDomain{namespace: "ingress-nginx", name: "controller", provider: AutoDomainNipIO}, auto)
+	_, err = parseAutoDomain("controller", "")
+	assert.EqualError(t, err, `invalid auto domain service "controller", must be "namespace/name"`)
+	_, err = parseAutoDomain("ingress-nginx/controller", "xip.io")
+	assert.EqualError(t, err, `invalid auto domain provider "xip.io", must be "nip.io" or "sslip.io"`)
+}
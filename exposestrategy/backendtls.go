@@ -0,0 +1,143 @@
+package exposestrategy
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// BackendTLSAnnotationKey annotation tells that the service only serves TLS, the ingress controller then connects with HTTPS
+	BackendTLSAnnotationKey = "fabric8.io/expose.backend-tls"
+	// BackendCASecretAnnotationKey annotation references the "[namespace/]secret" holding the CA verifying the certificate of the service
+	BackendCASecretAnnotationKey = "fabric8.io/expose.backend-ca-secret"
+
+	nginxAnnotationPrefix = "nginx.ingress.kubernetes.io/"
+)
+
+// backendTLSAnnotations are the annotations of an ingress controller connecting to its backends with HTTPS or gRPC
+type backendTLSAnnotations struct {
+	protocol string
+	// the gRPC backends are set by protocol version if not empty, or by protocol
+	protocolVersion string
+	// the TLS connections are passed through to the backends if not empty
+	passthrough string
+	// the verification annotations, empty if the controller cannot verify the certificates of the backends
+	caSecret   string
+	verify     string
+	serverName string
+	sni        string
+	// the host header sent to the external names
+	vhost string
+}
+
+var (
+	nginxBackendTLS = backendTLSAnnotations{
+		protocol:    nginxAnnotationPrefix + "backend-protocol",
+		passthrough: nginxAnnotationPrefix + "ssl-passthrough",
+		caSecret:    nginxAnnotationPrefix + "proxy-ssl-secret",
+		verify:      nginxAnnotationPrefix + "proxy-ssl-verify",
+		serverName:  nginxAnnotationPrefix + "proxy-ssl-name",
+		sni:         nginxAnnotationPrefix + "proxy-ssl-server-name",
+		vhost:       nginxAnnotationPrefix + "upstream-vhost",
+	}
+	albBackendTLS = backendTLSAnnotations{
+		protocol:        albAnnotationPrefix + "backend-protocol",
+		protocolVersion: albAnnotationPrefix + "backend-protocol-version",
+	}
+)
+
+// appProtocol returns the lower case app protocol of the port, empty if not set
+func appProtocol(port *v1.ServicePort) string {
+	if port == nil || port.AppProtocol == nil {
+		return ""
+	}
+	return strings.ToLower(*port.AppProtocol)
+}
+
+// apply adds the annotations of the backend protocol of the service to the ingress annotations
+// the backend TLS annotation takes precedence over the "https" app protocol of the port
+// a port speaking HTTPS by its app protocol gets the TLS connections passed through if allowed, true is then returned
+func (b backendTLSAnnotations) apply(svc *v1.Service, port *v1.ServicePort, passthrough bool, annotations map[string]string) (bool, error) {
+	caSecret := svc.Annotations[BackendCASecretAnnotationKey]
+	protocol := appProtocol(port)
+	backendTLS := protocol == "https"
+	value, ok := svc.Annotations[BackendTLSAnnotationKey]
+	if ok {
+		var err error
+		backendTLS, err = strconv.ParseBool(value)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid annotation \"%s\" in service %s/%s",
+				BackendTLSAnnotationKey, svc.Namespace, svc.Name)
+		}
+	} else if caSecret != "" && !backendTLS {
+		klog.Warningf("annotation \"%s\" of service %s/%s is ignored without annotation \"%s\"",
+			BackendCASecretAnnotationKey, svc.Namespace, svc.Name, BackendTLSAnnotationKey)
+		caSecret = ""
+	}
+	switch {
+	case protocol == "grpc" && b.protocolVersion != "":
+		annotations[b.protocolVersion] = "GRPC"
+		if backendTLS {
+			annotations[b.protocol] = "HTTPS"
+		}
+	case protocol == "grpc" && backendTLS:
+		annotations[b.protocol] = "GRPCS"
+	case protocol == "grpc":
+		annotations[b.protocol] = "GRPC"
+	case backendTLS && !ok && caSecret == "" && passthrough && b.passthrough != "":
+		annotations[b.passthrough] = "true"
+		return true, nil
+	case backendTLS:
+		annotations[b.protocol] = "HTTPS"
+	}
+	if !backendTLS {
+		return false, nil
+	}
+	// an external name is reached by its own name
+	external := svc.Spec.Type == v1.ServiceTypeExternalName && svc.Spec.ExternalName != ""
+	if external && b.vhost != "" {
+		annotations[b.vhost] = svc.Spec.ExternalName
+	}
+	if caSecret == "" {
+		return false, nil
+	}
+	if b.caSecret == "" {
+		return false, errors.Errorf("annotation \"%s\" in service %s/%s: the ingress controller cannot verify the certificates of the backends",
+			BackendCASecretAnnotationKey, svc.Namespace, svc.Name)
+	}
+	secretRef, err := parseSecretRef(caSecret, svc.Namespace)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid annotation \"%s\" in service %s/%s",
+			BackendCASecretAnnotationKey, svc.Namespace, svc.Name)
+	}
+	serverName := svc.Name + "." + svc.Namespace + ".svc"
+	if external {
+		serverName = svc.Spec.ExternalName
+	}
+	annotations[b.caSecret] = secretRef
+	annotations[b.verify] = "on"
+	annotations[b.serverName] = serverName
+	annotations[b.sni] = "on"
+	return false, nil
+}
+
+// parseSecretRef parses a "[namespace/]secret" reference into "namespace/secret"
+func parseSecretRef(text, namespace string) (string, error) {
+	name := text
+	if parts := strings.SplitN(text, "/", 2); len(parts) == 2 {
+		namespace, name = parts[0], parts[1]
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return "", errors.Errorf("invalid namespace \"%s\": %s", namespace, strings.Join(errs, ", "))
+		}
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", errors.Errorf("invalid secret name \"%s\": %s", name, strings.Join(errs, ", "))
+	}
+	return namespace + "/" + name, nil
+}
@@ -0,0 +1,259 @@
+package exposestrategy
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendTLSAnnotations(t *testing.T) {
+	examples := []struct {
+		name        string
+		annotations map[string]string
+		spec        v1.ServiceSpec
+		appProtocol string
+		passthrough bool
+		tls         backendTLSAnnotations
+		expected    map[string]string
+		err         bool
+	}{{
+		name:     "no backend tls",
+		tls:      nginxBackendTLS,
+		expected: map[string]string{},
+	}, {
+		name:        "disabled",
+		annotations: map[string]string{BackendTLSAnnotationKey: "false"},
+		tls:         nginxBackendTLS,
+		expected:    map[string]string{},
+	}, {
+		name:        "invalid",
+		annotations: map[string]string{BackendTLSAnnotationKey: "yes"},
+		tls:         nginxBackendTLS,
+		err:         true,
+	}, {
+		name:        "nginx",
+		annotations: map[string]string{BackendTLSAnnotationKey: "true"},
+		tls:         nginxBackendTLS,
+		expected: map[string]string{
+			"nginx.ingress.kubernetes.io/backend-protocol": "HTTPS",
+		},
+	}, {
+		name: "nginx with ca",
+		annotations: map[string]string{
+			BackendTLSAnnotationKey:      "true",
+			BackendCASecretAnnotationKey: "dashboard-ca",
+		},
+		tls: nginxBackendTLS,
+		expected: map[string]string{
+			"nginx.ingress.kubernetes.io/backend-protocol":      "HTTPS",
+			"nginx.ingress.kubernetes.io/proxy-ssl-secret":      "main/dashboard-ca",
+			"nginx.ingress.kubernetes.io/proxy-ssl-verify":      "on",
+			"nginx.ingress.kubernetes.io/proxy-ssl-name":        "svc.main.svc",
+			"nginx.ingress.kubernetes.io/proxy-ssl-server-name": "on",
+		},
+	}, {
+		name: "nginx external name",
+		annotations: map[string]string{
+			BackendTLSAnnotationKey:      "true",
+			BackendCASecretAnnotationKey: "certs/api-ca",
+		},
+		spec: v1.ServiceSpec{
+			Type:         v1.ServiceTypeExternalName,
+			ExternalName: "api.internal.my-domain.com",
+		},
+		tls: nginxBackendTLS,
+		expected: map[string]string{
+			"nginx.ingress.kubernetes.io/backend-protocol":      "HTTPS",
+			"nginx.ingress.kubernetes.io/upstream-vhost":        "api.internal.my-domain.com",
+			"nginx.ingress.kubernetes.io/proxy-ssl-secret":      "certs/api-ca",
+			"nginx.ingress.kubernetes.io/proxy-ssl-verify":      "on",
+			"nginx.ingress.kubernetes.io/proxy-ssl-name":        "api.internal.my-domain.com",
+			"nginx.ingress.kubernetes.io/proxy-ssl-server-name": "on",
+		},
+	}, {
+		name: "invalid ca secret",
+		annotations: map[string]string{
+			BackendTLSAnnotationKey:      "true",
+			BackendCASecretAnnotationKey: "Certs/api_ca",
+		},
+		tls: nginxBackendTLS,
+		err: true,
+	}, {
+		name:        "alb",
+		annotations: map[string]string{BackendTLSAnnotationKey: "true"},
+		tls:         albBackendTLS,
+		expected: map[string]string{
+			"alb.ingress.kubernetes.io/backend-protocol": "HTTPS",
+		},
+	}, {
+		name: "alb with ca",
+		annotations: map[string]string{
+			BackendTLSAnnotationKey:      "true",
+			BackendCASecretAnnotationKey: "dashboard-ca",
+		},
+		tls: albBackendTLS,
+		err: true,
+	}, {
+		name:        "https app protocol",
+		appProtocol: "HTTPS",
+		tls:         nginxBackendTLS,
+		expected: map[string]string{
+			"nginx.ingress.kubernetes.io/backend-protocol": "HTTPS",
+		},
+	}, {
+		name:        "https app protocol passed through",
+		appProtocol: "https",
+		passthrough: true,
+		tls:         nginxBackendTLS,
+		expected: map[string]string{
+			"nginx.ingress.kubernetes.io/ssl-passthrough": "true",
+		},
+	}, {
+		name:        "https app protocol overridden",
+		annotations: map[string]string{BackendTLSAnnotationKey: "false"},
+		appProtocol: "https",
+		passthrough: true,
+		tls:         nginxBackendTLS,
+		expected:    map[string]string{},
+	}, {
+		name:        "https app protocol with ca",
+		annotations: map[string]string{BackendCASecretAnnotationKey: "dashboard-ca"},
+		appProtocol: "https",
+		passthrough: true,
+		tls:         nginxBackendTLS,
+		expected: map[string]string{
+			"nginx.ingress.kubernetes.io/backend-protocol":      "HTTPS",
+			"nginx.ingress.kubernetes.io/proxy-ssl-secret":      "main/dashboard-ca",
+			"nginx.ingress.kubernetes.io/proxy-ssl-verify":      "on",
+			"nginx.ingress.kubernetes.io/proxy-ssl-name":        "svc.main.svc",
+			"nginx.ingress.kubernetes.io/proxy-ssl-server-name": "on",
+		},
+	}, {
+		name:        "grpc app protocol",
+		appProtocol: "grpc",
+		tls:         nginxBackendTLS,
+		expected: map[string]string{
+			"nginx.ingress.kubernetes.io/backend-protocol": "GRPC",
+		},
+	}, {
+		name:        "grpc app protocol with tls",
+		annotations: map[string]string{BackendTLSAnnotationKey: "true"},
+		appProtocol: "grpc",
+		tls:         nginxBackendTLS,
+		expected: map[string]string{
+			"nginx.ingress.kubernetes.io/backend-protocol": "GRPCS",
+		},
+	}, {
+		name:        "alb grpc app protocol",
+		appProtocol: "grpc",
+		passthrough: true,
+		tls:         albBackendTLS,
+		expected: map[string]string{
+			"alb.ingress.kubernetes.io/backend-protocol-version": "GRPC",
+		},
+	}, {
+		name:        "alb https app protocol",
+		appProtocol: "https",
+		passthrough: true,
+		tls:         albBackendTLS,
+		expected: map[string]string{
+			"alb.ingress.kubernetes.io/backend-protocol": "HTTPS",
+		},
+	}}
+	for _, example := range examples {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "main",
+				Name:        "svc",
+				Annotations: example.annotations,
+			},
+			Spec: example.spec,
+		}
+		port := &v1.ServicePort{Port: 443}
+		if example.appProtocol != "" {
+			port.AppProtocol = &example.appProtocol
+		}
+		annotations := map[string]string{}
+		passthrough, err := example.tls.apply(svc, port, example.passthrough, annotations)
+		if example.err {
+			assert.Error(t, err, example.name)
+			continue
+		}
+		require.NoError(t, err, example.name)
+		assert.Equal(t, example.expected, annotations, example.name)
+		assert.Equal(t, annotations["nginx.ingress.kubernetes.io/ssl-passthrough"] == "true", passthrough, example.name)
+	}
+}
+
+func TestIngressStrategy_BackendTLS(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "dashboard",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key:         ExposeAnnotation.Value,
+				BackendTLSAnnotationKey:      "true",
+				BackendCASecretAnnotationKey: "dashboard-ca",
+				// the inline annotations take precedence
+				IngressAnnotationsAnnotationKey: "nginx.ingress.kubernetes.io/proxy-ssl-name: dashboard",
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 443}},
+		},
+	}
+	client := fake.NewSimpleClientset(svc)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:   "ingress",
+		Namespace: "main",
+		Domain:    "my-domain.com",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(svc))
+
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(nil, "dashboard", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "HTTPS", ingress.Annotations["nginx.ingress.kubernetes.io/backend-protocol"])
+	assert.Equal(t, "main/dashboard-ca", ingress.Annotations["nginx.ingress.kubernetes.io/proxy-ssl-secret"])
+	assert.Equal(t, "dashboard", ingress.Annotations["nginx.ingress.kubernetes.io/proxy-ssl-name"])
+}
+
+func TestIngressStrategy_AppProtocolPassthrough(t *testing.T) {
+	https := "https"
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "dashboard",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key: ExposeAnnotation.Value,
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 443, AppProtocol: &https}},
+		},
+	}
+	client := fake.NewSimpleClientset(svc)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:   "ingress",
+		Namespace: "main",
+		Domain:    "my-domain.com",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(svc))
+
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(nil, "dashboard", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "true", ingress.Annotations["nginx.ingress.kubernetes.io/ssl-passthrough"])
+	svc, err = client.CoreV1().Services("main").Get(nil, "dashboard", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://dashboard.main.my-domain.com", svc.Annotations[ExposeAnnotationKey])
+}
@@ -0,0 +1,150 @@
+package exposestrategy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// benchmarkSizes are the numbers of exposed services of the benchmarks
+var benchmarkSizes = []int{1000, 10000}
+
+func benchmarkService(i int) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       fmt.Sprintf("ns-%d", i%100),
+			Name:            fmt.Sprintf("svc-%d", i),
+			UID:             "uid",
+			ResourceVersion: "1",
+			Annotations: map[string]string{
+				"fabric8.io/expose": "true",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Name: "http", Port: 8080}},
+		},
+	}
+}
+
+// newBenchmarkStrategy creates a strategy watching all the namespaces, with n services exposed and synced
+func newBenchmarkStrategy(tb testing.TB, n int) (*IngressStrategy, []*v1.Service) {
+	services := make([]*v1.Service, n)
+	objects := make([]runtime.Object, n)
+	for i := range services {
+		services[i] = benchmarkService(i)
+		objects[i] = services[i]
+	}
+	client := fake.NewSimpleClientset(objects...)
+	strategy, err := NewIngressStrategy(context.Background(), client, &Config{
+		Exposer:       "ingress",
+		Domain:        "my-domain.com",
+		TLSSecretName: "tls",
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	s := strategy.(*IngressStrategy)
+	if err = s.Sync(); err != nil {
+		tb.Fatal(err)
+	}
+	for _, svc := range services {
+		if err = s.Add(svc); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err = s.Sync(); err != nil {
+		tb.Fatal(err)
+	}
+	// the services are updated with their URL, like those of the informer
+	for i, svc := range services {
+		services[i], err = client.CoreV1().Services(svc.Namespace).Get(context.Background(), svc.Name, metav1.GetOptions{})
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return s, services
+}
+
+func BenchmarkIngressStrategy_Add(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			s, services := newBenchmarkStrategy(b, n)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := s.Add(services[i%n]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkIngressStrategy_Resync syncs the ingresses then adds all the services, like a resync of the controller
+func BenchmarkIngressStrategy_Resync(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			s, services := newBenchmarkStrategy(b, n)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := s.Sync(); err != nil {
+					b.Fatal(err)
+				}
+				for _, svc := range services {
+					if err := s.Add(svc); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkIngressStrategy_Clean(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			s, services := newBenchmarkStrategy(b, n)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				svc := services[i%n]
+				if err := s.Clean(svc); err != nil {
+					b.Fatal(err)
+				}
+				// the service is exposed again for the next rounds
+				b.StopTimer()
+				if err := s.Add(svc); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+// the allocation budget of the ingress strategy, the number of allocations exposing an unchanged service with the fake client
+// the resyncs add every service again, with thousands of services the allocations of Add dominate those of the controller
+const addAllocationBudget = 100
+
+func TestIngressStrategy_AllocationBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("allocations are not measured in short mode")
+	}
+	s, services := newBenchmarkStrategy(t, 100)
+	i := 0
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := s.Add(services[i%len(services)]); err != nil {
+			t.Fatal(err)
+		}
+		i++
+	})
+	if allocs > addAllocationBudget {
+		t.Errorf("Add allocates %.0f times, over the budget of %d", allocs, addAllocationBudget)
+	}
+}
@@ -0,0 +1,112 @@
+package exposestrategy
+
+import (
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+)
+
+var (
+	// IngressResource is the resource of the networking/v1 ingresses
+	IngressResource = schema.GroupVersionResource{
+		Group:    "networking.k8s.io",
+		Version:  "v1",
+		Resource: "ingresses",
+	}
+	// RouteResource is the resource of the OpenShift routes
+	RouteResource = schema.GroupVersionResource{
+		Group:    "route.openshift.io",
+		Version:  "v1",
+		Resource: "routes",
+	}
+)
+
+// capabilities tells which of the APIs the strategies depend on are served by the cluster
+type capabilities struct {
+	// checked is false when the discovery is not available, the APIs being assumed to be served
+	checked      bool
+	ingresses    bool
+	routes       bool
+	httpRoutes   bool
+	gkeConfigs   bool
+	certificates bool
+}
+
+// discoverCapabilities checks the APIs of the cluster at startup
+// the discovery is cached, so that the group versions are only fetched once
+func discoverCapabilities(client discovery.DiscoveryInterface) capabilities {
+	if client == nil {
+		return capabilities{}
+	}
+	cached := memory.NewMemCacheClient(client)
+	groups, err := cached.ServerGroups()
+	if err != nil || groups == nil || len(groups.Groups) == 0 {
+		klog.Warningf("the APIs of the cluster are not checked, the discovery is not available: %v", err)
+		return capabilities{}
+	}
+	serves := func(resource schema.GroupVersionResource) bool {
+		list, err := cached.ServerResourcesForGroupVersion(resource.GroupVersion().String())
+		if err != nil {
+			return false
+		}
+		for _, r := range list.APIResources {
+			if r.Name == resource.Resource {
+				return true
+			}
+		}
+		return false
+	}
+	c := capabilities{
+		checked:      true,
+		ingresses:    serves(IngressResource),
+		routes:       serves(RouteResource),
+		httpRoutes:   serves(HTTPRouteResource),
+		gkeConfigs:   serves(FrontendConfigResource) && serves(BackendConfigResource),
+		certificates: serves(CertificateResource),
+	}
+	klog.Infof("Cluster APIs: ingresses: %v, OpenShift routes: %v, HTTP routes: %v, GKE configs: %v, certificates: %v",
+		c.ingresses, c.routes, c.httpRoutes, c.gkeConfigs, c.certificates)
+	return c
+}
+
+// check validates that the cluster serves the APIs required by the exposer and the config
+func (c capabilities) check(exposer string, config *Config) error {
+	if !c.checked {
+		return nil
+	}
+	missing := func(resource schema.GroupVersionResource, usage string) error {
+		return errors.Errorf("%s requires the %s of %s, not served by the cluster",
+			usage, resource.Resource, resource.GroupVersion())
+	}
+	if (exposer == ingress || exposer == "alb") && !c.ingresses {
+		return missing(IngressResource, "the "+exposer+" exposer")
+	}
+	if exposer != ingress && exposer != "alb" {
+		return nil
+	}
+	if config.HTTPRoute && !c.httpRoutes {
+		return missing(HTTPRouteResource, "the http-route option")
+	}
+	if config.GKEConfigs && !c.gkeConfigs {
+		return missing(FrontendConfigResource, "the gke-configs option")
+	}
+	if (config.TLSAcme || usesDomainAcme(config.DomainTLSPolicies)) && config.AcmeChallengeType == AcmeChallengeDNS01 && !c.certificates {
+		return missing(CertificateResource, "the dns01 ACME challenge")
+	}
+	return nil
+}
+
+// autoExposer chooses the exposer from the APIs of the cluster, ingress by default
+func (c capabilities) autoExposer() string {
+	if !c.checked || c.ingresses {
+		if c.routes {
+			klog.Infof("OpenShift routes are served, the ingresses are exposed by the OpenShift router")
+		}
+		return ingress
+	}
+	klog.Warningf("the networking/v1 ingresses are not served by the cluster, exposing the services with node ports")
+	return nodePort
+}
@@ -0,0 +1,56 @@
+package exposestrategy
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fakeAPIs(client *fake.Clientset, resources map[string][]string) {
+	discovery := client.Discovery().(*fakediscovery.FakeDiscovery)
+	discovery.Resources = nil
+	for groupVersion, names := range resources {
+		list := &metav1.APIResourceList{GroupVersion: groupVersion}
+		for _, name := range names {
+			list.APIResources = append(list.APIResources, metav1.APIResource{Name: name})
+		}
+		discovery.Resources = append(discovery.Resources, list)
+	}
+}
+
+func TestDiscoverCapabilities(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	caps := discoverCapabilities(client.Discovery())
+	assert.False(t, caps.checked, "no discovery")
+	assert.Equal(t, ingress, caps.autoExposer())
+	assert.NoError(t, caps.check(ingress, &Config{HTTPRoute: true}))
+
+	fakeAPIs(client, map[string][]string{
+		"v1":                    {"services"},
+		"networking.k8s.io/v1":  {"ingresses", "ingressclasses"},
+		"route.openshift.io/v1": {"routes"},
+	})
+	caps = discoverCapabilities(client.Discovery())
+	assert.Equal(t, capabilities{checked: true, ingresses: true, routes: true}, caps)
+	assert.Equal(t, ingress, caps.autoExposer())
+	assert.NoError(t, caps.check(ingress, &Config{}))
+	assert.NoError(t, caps.check(nodePort, &Config{HTTPRoute: true}), "the options of the ingresses only")
+	assert.EqualError(t, caps.check(ingress, &Config{HTTPRoute: true}),
+		"the http-route option requires the httproutes of gateway.networking.k8s.io/v1, not served by the cluster")
+	assert.EqualError(t, caps.check(ingress, &Config{TLSAcme: true, AcmeChallengeType: AcmeChallengeDNS01}),
+		"the dns01 ACME challenge requires the certificates of cert-manager.io/v1, not served by the cluster")
+
+	fakeAPIs(client, map[string][]string{
+		"v1": {"services", "nodes"},
+	})
+	caps = discoverCapabilities(client.Discovery())
+	assert.Equal(t, nodePort, caps.autoExposer())
+	_, err := New(nil, client, &Config{Exposer: "ingress", Domain: "my-domain.com"})
+	require.Error(t, err)
+	assert.Equal(t, "failed to create ingress expose strategy: the ingress exposer requires the ingresses of networking.k8s.io/v1, not served by the cluster", err.Error())
+}
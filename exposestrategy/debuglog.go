@@ -0,0 +1,122 @@
+package exposestrategy
+
+import (
+	"encoding/json"
+	"strings"
+
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+const (
+	// DebugLogLevel is the verbosity logging the patches of the services and ingresses written by the controller, "-v=4"
+	DebugLogLevel klog.Level = 4
+
+	// redactedValue replaces the values of the sensitive annotations in the logged patches
+	redactedValue = "<redacted>"
+)
+
+// sensitiveAnnotationWords are the words of the keys of the annotations whose values are not logged,
+// such as the credentials of the auth annotations of the ingress controllers
+var sensitiveAnnotationWords = []string{"password", "passwd", "token", "secret", "credential", "apikey", "api-key", "auth"}
+
+// isSensitiveAnnotation tells if the value of the annotation may hold secret data
+func isSensitiveAnnotation(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range sensitiveAnnotationWords {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactPatch replaces the values of the sensitive annotations of the patch or of the object, the removed ones are kept as null
+func redactPatch(patch []byte) []byte {
+	var object map[string]interface{}
+	if err := json.Unmarshal(patch, &object); err != nil {
+		return []byte(redactedValue)
+	}
+	metadata, _ := object["metadata"].(map[string]interface{})
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	for key, value := range annotations {
+		if value != nil && isSensitiveAnnotation(key) {
+			annotations[key] = redactedValue
+		}
+	}
+	redacted, err := json.Marshal(object)
+	if err != nil {
+		return []byte(redactedValue)
+	}
+	return redacted
+}
+
+// logPatch logs the patch of the object at debug level
+func logPatch(kind, namespace, name string, patch []byte) {
+	if !klog.V(DebugLogLevel) {
+		return
+	}
+	klog.Infof("patch of the %s %s/%s: %s", kind, namespace, name, redactPatch(patch))
+}
+
+// logChange logs the object created, or the patch from the existing object to the updated one, at debug level
+// the data struct tells how to merge the lists of the patch
+func logChange(kind, namespace, name string, existing, updated, dataStruct interface{}) {
+	if !klog.V(DebugLogLevel) {
+		return
+	}
+	updatedBytes, err := json.Marshal(updated)
+	if err != nil {
+		klog.Warningf("failed to encode the %s %s/%s to log it: %s", kind, namespace, name, err)
+		return
+	}
+	if existing == nil {
+		klog.Infof("creating the %s %s/%s: %s", kind, namespace, name, redactPatch(updatedBytes))
+		return
+	}
+	existingBytes, err := json.Marshal(existing)
+	if err != nil {
+		klog.Warningf("failed to encode the %s %s/%s to log it: %s", kind, namespace, name, err)
+		return
+	}
+	patch, err := strategicpatch.CreateTwoWayMergePatch(existingBytes, updatedBytes, dataStruct)
+	if err != nil {
+		klog.Warningf("failed to diff the %s %s/%s to log it: %s", kind, namespace, name, err)
+		return
+	}
+	logPatch(kind, namespace, name, patch)
+}
+
+// logIngressChange logs the ingress created, or its patch from the existing one, at debug level, the existing ingress is nil on creation
+func logIngressChange(existing, ingress *networkingv1.Ingress) {
+	if existing == nil {
+		logChange("ingress", ingress.Namespace, ingress.Name, nil, ingress, &networkingv1.Ingress{})
+		return
+	}
+	logChange("ingress", ingress.Namespace, ingress.Name, writtenIngressFields(existing), writtenIngressFields(ingress), &networkingv1.Ingress{})
+}
+
+// writtenIngressFields returns the fields of the ingress written by the controller, without those of the server and the status
+func writtenIngressFields(ingress *networkingv1.Ingress) *networkingv1.Ingress {
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:          ingress.Labels,
+			Annotations:     ingress.Annotations,
+			OwnerReferences: ingress.OwnerReferences,
+		},
+		Spec: ingress.Spec,
+	}
+}
+
+// logServiceChange logs the service created, or its patch from the existing one, at debug level, the existing service is nil on creation
+func logServiceChange(existing, svc *v1.Service) {
+	if existing == nil {
+		logChange("service", svc.Namespace, svc.Name, nil, svc, &v1.Service{})
+		return
+	}
+	logChange("service", svc.Namespace, svc.Name, existing, svc, &v1.Service{})
+}
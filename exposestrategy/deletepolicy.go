@@ -0,0 +1,47 @@
+package exposestrategy
+
+import (
+	"github.com/pkg/errors"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DeletePropagationForeground deletes the generated objects once their dependents are deleted,
+	// such as the secrets of cert-manager owned by the ingresses
+	DeletePropagationForeground = "foreground"
+	// DeletePropagationBackground deletes the generated objects at once, their dependents are then garbage collected
+	DeletePropagationBackground = "background"
+	// DeletePropagationOrphan deletes the generated objects and keeps their dependents
+	DeletePropagationOrphan = "orphan"
+)
+
+// ParseDeletePropagation returns the propagation policy of the deletions of the generated objects,
+// nil for the default policy of the API server
+func ParseDeletePropagation(policy string) (*metav1.DeletionPropagation, error) {
+	var propagation metav1.DeletionPropagation
+	switch policy {
+	case "":
+		return nil, nil
+	case DeletePropagationForeground:
+		propagation = metav1.DeletePropagationForeground
+	case DeletePropagationBackground:
+		propagation = metav1.DeletePropagationBackground
+	case DeletePropagationOrphan:
+		propagation = metav1.DeletePropagationOrphan
+	default:
+		return nil, errors.Errorf("invalid delete propagation \"%s\", must be \"%s\", \"%s\" or \"%s\"",
+			policy, DeletePropagationForeground, DeletePropagationBackground, DeletePropagationOrphan)
+	}
+	return &propagation, nil
+}
+
+// DeleteOptions returns the options deleting a generated object with the propagation policy,
+// and the precondition on its resource version if not empty
+func DeleteOptions(propagation *metav1.DeletionPropagation, resourceVersion string) metav1.DeleteOptions {
+	options := metav1.DeleteOptions{PropagationPolicy: propagation}
+	if resourceVersion != "" {
+		options.Preconditions = &metav1.Preconditions{ResourceVersion: &resourceVersion}
+	}
+	return options
+}
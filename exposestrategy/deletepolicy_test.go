@@ -0,0 +1,72 @@
+package exposestrategy
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDeletePropagation(t *testing.T) {
+	propagation, err := ParseDeletePropagation("")
+	require.NoError(t, err)
+	assert.Nil(t, propagation)
+	propagation, err = ParseDeletePropagation("orphan")
+	require.NoError(t, err)
+	assert.Equal(t, metav1.DeletePropagationOrphan, *propagation)
+	_, err = ParseDeletePropagation("Foreground")
+	assert.Error(t, err)
+}
+
+func TestIngressStrategy_DeletePropagation(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				ExposeAnnotationKey: "http://svc.main.my-domain.com",
+			},
+			ResourceVersion: "1",
+		},
+	}
+	client := fake.NewSimpleClientset(service, &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "main",
+			Name:            "svc",
+			Labels:          map[string]string{"provider": "fabric8"},
+			Annotations:     map[string]string{"fabric8.io/generated-by": "exposecontroller"},
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: "v1", Kind: "Service", Name: "svc"}},
+			ResourceVersion: "1",
+		},
+	})
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:           "ingress",
+		Namespace:         "main",
+		Domain:            "my-domain.com",
+		DeletePropagation: "foreground",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Clean(service))
+
+	var deletions []metav1.DeleteOptions
+	for _, action := range client.Actions() {
+		if deletion, ok := action.(ktesting.DeleteActionImpl); ok && deletion.GetResource().Resource == "ingresses" {
+			deletions = append(deletions, deletion.DeleteOptions)
+		}
+	}
+	require.Len(t, deletions, 1)
+	require.NotNil(t, deletions[0].PropagationPolicy)
+	assert.Equal(t, metav1.DeletePropagationForeground, *deletions[0].PropagationPolicy)
+	require.NotNil(t, deletions[0].Preconditions)
+	assert.Equal(t, "1", *deletions[0].Preconditions.ResourceVersion)
+
+	_, err = NewIngressStrategy(nil, client, &Config{Domain: "my-domain.com", DeletePropagation: "cascade"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,102 @@
+package exposestrategy
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// annotationDenylist strips the ingress annotations of the services whose keys match one of its patterns
+// such as the nginx snippets, which let a tenant inject configuration into a shared ingress controller
+// a warning event is emitted on the service once until the stripped keys change
+type annotationDenylist struct {
+	patterns []*regexp.Regexp
+	// the last stripped keys by service key
+	warned map[string]string
+}
+
+// newAnnotationDenylist compiles the patterns, which match whole annotation keys
+// returns nil without patterns
+func newAnnotationDenylist(patterns []string) (*annotationDenylist, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	compiled, err := compileKeyPatterns(patterns, "annotation denylist")
+	if err != nil {
+		return nil, err
+	}
+	return &annotationDenylist{
+		patterns: compiled,
+		warned:   map[string]string{},
+	}, nil
+}
+
+// compileKeyPatterns compiles the patterns of annotation keys, which match whole keys
+func compileKeyPatterns(patterns []string, what string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s pattern \"%s\"", what, pattern)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// matchesKeyPatterns tells if the annotation key matches one of the patterns
+func matchesKeyPatterns(patterns []*regexp.Regexp, key string) bool {
+	for _, re := range patterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// strip removes the denied annotations given by the service
+func (d *annotationDenylist) strip(ctx context.Context, client kubernetes.Interface, svc *v1.Service, annotations map[string]string) {
+	if d == nil {
+		return
+	}
+	var stripped []string
+	for key := range annotations {
+		if matchesKeyPatterns(d.patterns, key) {
+			stripped = append(stripped, key)
+			delete(annotations, key)
+		}
+	}
+	key := svc.Namespace + "/" + svc.Name
+	if len(stripped) == 0 {
+		delete(d.warned, key)
+		return
+	}
+	sort.Strings(stripped)
+	message := "The ingress annotations \"" + strings.Join(stripped, "\", \"") + "\" are denied by the controller and were stripped"
+	if d.warned[key] != message {
+		klog.Warningf("Service %s: %s", key, message)
+		EmitServiceEvent(ctx, client, svc, v1.EventTypeWarning, "AnnotationDenied", message)
+	}
+	d.warned[key] = message
+}
+
+// forget is called when the service is not exposed anymore
+func (d *annotationDenylist) forget(svc *v1.Service) {
+	if d != nil {
+		delete(d.warned, svc.Namespace+"/"+svc.Name)
+	}
+}
+
+// forgetNamespace is called when the namespace is being deleted
+func (d *annotationDenylist) forgetNamespace(namespace string) {
+	if d != nil {
+		forgetNamespaceKeys(d.warned, namespace)
+	}
+}
@@ -0,0 +1,86 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_AnnotationDenylist(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key: ExposeAnnotation.Value,
+				IngressAnnotationsAnnotationKey: `nginx.ingress.kubernetes.io/configuration-snippet: "more_set_headers \"X: y\";"
+nginx.ingress.kubernetes.io/server-snippet: "location / {}"
+nginx.ingress.kubernetes.io/proxy-body-size: 8m`,
+				IngressAnnotationsFromAnnotationKey: "annotations/ingress",
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 80}},
+		},
+	}
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "annotations",
+		},
+		Data: map[string]string{
+			"ingress": "nginx.ingress.kubernetes.io/auth-snippet: deny all;",
+		},
+	}
+	client := fake.NewSimpleClientset(svc, cm)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:            "ingress",
+		Namespace:          "main",
+		Domain:             "my-domain.com",
+		AnnotationDenylist: []string{`nginx\.ingress\.kubernetes\.io/.*-snippet`},
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(svc))
+	require.NoError(t, strategy.Add(svc))
+
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(context.Background(), "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "8m", ingress.Annotations["nginx.ingress.kubernetes.io/proxy-body-size"])
+	assert.NotContains(t, ingress.Annotations, "nginx.ingress.kubernetes.io/configuration-snippet")
+	assert.NotContains(t, ingress.Annotations, "nginx.ingress.kubernetes.io/server-snippet")
+	assert.NotContains(t, ingress.Annotations, "nginx.ingress.kubernetes.io/auth-snippet")
+
+	events, err := client.CoreV1().Events("main").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1, "one event until the stripped annotations change")
+	assert.Equal(t, "AnnotationDenied", events.Items[0].Reason)
+	assert.Equal(t, v1.EventTypeWarning, events.Items[0].Type)
+	assert.Equal(t, `The ingress annotations "nginx.ingress.kubernetes.io/auth-snippet", "nginx.ingress.kubernetes.io/configuration-snippet", `+
+		`"nginx.ingress.kubernetes.io/server-snippet" are denied by the controller and were stripped`, events.Items[0].Message)
+}
+
+func TestNewAnnotationDenylist(t *testing.T) {
+	denylist, err := newAnnotationDenylist(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, denylist, "nothing denied")
+	denylist.strip(nil, nil, &v1.Service{}, map[string]string{"key": "value"})
+
+	denylist, err = newAnnotationDenylist([]string{"alb.ingress.kubernetes.io/actions"})
+	require.NoError(t, err)
+	annotations := map[string]string{
+		"alb.ingress.kubernetes.io/actions.forward": "{}",
+	}
+	denylist.strip(nil, fake.NewSimpleClientset(), &v1.Service{}, annotations)
+	assert.Len(t, annotations, 1, "the patterns match whole keys")
+
+	_, err = newAnnotationDenylist([]string{"*-snippet"})
+	assert.EqualError(t, err, "invalid annotation denylist pattern \"*-snippet\": error parsing regexp: missing argument to repetition operator: `*`")
+}
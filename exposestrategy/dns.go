@@ -0,0 +1,108 @@
+package exposestrategy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// dnsLookupTimeout bounds the lookups of the hosts of an ingress
+const dnsLookupTimeout = 5 * time.Second
+
+// dnsChecker checks that the hosts of the ingresses resolve to the addresses of the ingress controller
+// a warning event is emitted on the service once until the problem changes
+type dnsChecker struct {
+	lookup func(ctx context.Context, host string) ([]string, error)
+	// the last warning by service key
+	warned map[string]string
+}
+
+func newDNSChecker() *dnsChecker {
+	return &dnsChecker{
+		lookup: net.DefaultResolver.LookupHost,
+		warned: map[string]string{},
+	}
+}
+
+// check resolves the hosts, the addresses of the ingress status are compared when known
+func (c *dnsChecker) check(ctx context.Context, client kubernetes.Interface, svc *v1.Service, hosts []string, status []networkingv1.IngressLoadBalancerIngress) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, dnsLookupTimeout)
+	defer cancel()
+
+	expected := map[string]bool{}
+	for _, lb := range status {
+		if lb.IP != "" {
+			expected[lb.IP] = true
+		}
+		if lb.Hostname != "" {
+			addresses, err := c.lookup(lookupCtx, lb.Hostname)
+			if err != nil {
+				klog.Warningf("Failed to resolve the ingress address %s: %v", lb.Hostname, err)
+			}
+			for _, address := range addresses {
+				expected[address] = true
+			}
+		}
+	}
+	var problems []string
+	for _, host := range hosts {
+		if host == "" || net.ParseIP(host) != nil {
+			continue
+		}
+		addresses, err := c.lookup(lookupCtx, host)
+		if err != nil || len(addresses) == 0 {
+			problems = append(problems, fmt.Sprintf("%s does not resolve", host))
+			continue
+		}
+		if len(expected) > 0 && !anyExpected(addresses, expected) {
+			sort.Strings(addresses)
+			problems = append(problems, fmt.Sprintf("%s resolves to %s instead of the ingress address", host, strings.Join(addresses, ", ")))
+		}
+	}
+	key := svc.Namespace + "/" + svc.Name
+	if len(problems) == 0 {
+		delete(c.warned, key)
+		return
+	}
+	message := "The exposed host " + strings.Join(problems, ", ") + ", check the domain"
+	if c.warned[key] != message {
+		klog.Warningf("Service %s: %s", key, message)
+		EmitServiceEvent(ctx, client, svc, v1.EventTypeWarning, "DomainNotResolved", message)
+	}
+	c.warned[key] = message
+}
+
+// forget is called when the service is not exposed anymore
+func (c *dnsChecker) forget(svc *v1.Service) {
+	if c != nil {
+		delete(c.warned, svc.Namespace+"/"+svc.Name)
+	}
+}
+
+// forgetNamespace is called when the namespace is being deleted
+func (c *dnsChecker) forgetNamespace(namespace string) {
+	if c != nil {
+		forgetNamespaceKeys(c.warned, namespace)
+	}
+}
+
+func anyExpected(addresses []string, expected map[string]bool) bool {
+	for _, address := range addresses {
+		if expected[address] {
+			return true
+		}
+	}
+	return false
+}
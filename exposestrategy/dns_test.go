@@ -0,0 +1,62 @@
+package exposestrategy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSChecker(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+		},
+	}
+	records := map[string][]string{
+		"svc.main.my-domain.com":  {"10.0.0.1"},
+		"svc.main.my-other.com":   {"10.0.0.2"},
+		"lb.elb.amazonaws.com":    {"10.0.0.1"},
+		"svc.main.wildcard.local": {"10.0.0.1", "10.0.0.3"},
+	}
+	client := fake.NewSimpleClientset()
+	checker := newDNSChecker()
+	checker.lookup = func(ctx context.Context, host string) ([]string, error) {
+		if addresses, ok := records[host]; ok {
+			return addresses, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	events := func() []v1.Event {
+		list, err := client.CoreV1().Events("main").List(context.Background(), metav1.ListOptions{})
+		require.NoError(t, err)
+		return list.Items
+	}
+	status := []networkingv1.IngressLoadBalancerIngress{{Hostname: "lb.elb.amazonaws.com"}}
+
+	checker.check(nil, client, svc, []string{"svc.main.my-domain.com", "svc.main.wildcard.local"}, status)
+	assert.Empty(t, events())
+
+	checker.check(nil, client, svc, []string{"svc.main.my-domian.com"}, nil)
+	checker.check(nil, client, svc, []string{"svc.main.my-domian.com"}, nil)
+	require.Len(t, events(), 1, "one event until the problem changes")
+	assert.Equal(t, "DomainNotResolved", events()[0].Reason)
+	assert.Equal(t, "The exposed host svc.main.my-domian.com does not resolve, check the domain", events()[0].Message)
+
+	checker.check(nil, client, svc, []string{"svc.main.my-other.com"}, status)
+	require.Len(t, events(), 2)
+	assert.Contains(t, events()[1].Message, "svc.main.my-other.com resolves to 10.0.0.2 instead of the ingress address")
+
+	// the status of a new ingress is unknown
+	checker.check(nil, client, svc, []string{"svc.main.my-other.com"}, nil)
+	assert.Empty(t, checker.warned)
+	checker.forget(svc)
+}
@@ -0,0 +1,55 @@
+package exposestrategy
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+)
+
+const (
+	// ExposeDomainAnnotationKey annotation selects the domain of the service among the configured domains, or "all" of them,
+	// such as the domains of the regions behind a Geo-DNS
+	ExposeDomainAnnotationKey = "fabric8.io/expose.domain"
+	// ExposeDomainURLsAnnotationKey annotation will be created with the URLs of the service on all its domains in JSON,
+	// when there are several
+	ExposeDomainURLsAnnotationKey = "fabric8.io/exposeDomainUrls"
+)
+
+// allDomains exposes the service on all the configured domains
+const allDomains = "all"
+
+// serviceDomains returns the domains of the service selected by its annotation, the first one being the domain of its URL,
+// nil without annotation
+func (s *IngressStrategy) serviceDomains(svc *v1.Service) ([]string, error) {
+	value, ok := svc.Annotations[ExposeDomainAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+	if value == allDomains && len(s.domains) > 0 {
+		return s.domains, nil
+	}
+	for _, domain := range s.domains {
+		if domain == value {
+			return []string{domain}, nil
+		}
+	}
+	return nil, newAnnotationParseError(svc, ExposeDomainAnnotationKey, value,
+		errors.Errorf("%s is not one of the configured domains [%s] nor \"%s\"", value, strings.Join(s.domains, ", "), allDomains))
+}
+
+// setDomainURLs publishes the URLs of the service on all its domains, the annotation is removed with less than 2 URLs
+func setDomainURLs(svc *v1.Service, urls []string) error {
+	if len(urls) < 2 {
+		delete(svc.Annotations, ExposeDomainURLsAnnotationKey)
+		return nil
+	}
+	value, err := json.Marshal(urls)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode the domain URLs of service %s/%s", svc.Namespace, svc.Name)
+	}
+	svc.Annotations[ExposeDomainURLsAnnotationKey] = string(value)
+	return nil
+}
@@ -0,0 +1,73 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_Domains(t *testing.T) {
+	ctx := context.Background()
+	newService := func(name, domain string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "main",
+				Name:            name,
+				Annotations:     map[string]string{ExposeDomainAnnotationKey: domain},
+				ResourceVersion: "1",
+			},
+			Spec: v1.ServiceSpec{Ports: []v1.ServicePort{{Port: 8080}}},
+		}
+	}
+	client := fake.NewSimpleClientset(newService("web", "all"), newService("api", "us.example.com"), newService("bad", "asia.example.com"))
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:   "ingress",
+		Namespace: "main",
+		Domain:    "my-domain.com",
+		Domains:   []string{"eu.example.com", "us.example.com"},
+		HTTP:      true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	get := func(name string) *v1.Service {
+		svc, err := client.CoreV1().Services("main").Get(ctx, name, metav1.GetOptions{})
+		require.NoError(t, err)
+		return svc
+	}
+	hosts := func(name string) []string {
+		ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, name, metav1.GetOptions{})
+		require.NoError(t, err)
+		var hosts []string
+		for _, rule := range ingress.Spec.Rules {
+			hosts = append(hosts, rule.Host)
+		}
+		return hosts
+	}
+
+	require.NoError(t, strategy.Add(get("web")))
+	assert.ElementsMatch(t, []string{"web.main.eu.example.com", "web.main.us.example.com"}, hosts("web"))
+	web := get("web")
+	assert.Equal(t, "http://web.main.eu.example.com", web.Annotations[ExposeAnnotationKey])
+	assert.Equal(t, `["http://web.main.eu.example.com","http://web.main.us.example.com"]`, web.Annotations[ExposeDomainURLsAnnotationKey])
+
+	require.NoError(t, strategy.Add(get("api")))
+	assert.Equal(t, []string{"api.main.us.example.com"}, hosts("api"))
+	assert.NotContains(t, get("api").Annotations, ExposeDomainURLsAnnotationKey)
+
+	err = strategy.Add(get("bad"))
+	assert.Error(t, err, "domain not configured")
+	assert.False(t, Retryable(err), "not retried until the annotation changes")
+
+	// back to the default domain
+	setIngressVersions(t, client)
+	delete(web.Annotations, ExposeDomainAnnotationKey)
+	require.NoError(t, strategy.Add(web))
+	assert.Equal(t, []string{"web.main.my-domain.com"}, hosts("web"))
+	assert.NotContains(t, get("web").Annotations, ExposeDomainURLsAnnotationKey)
+}
@@ -0,0 +1,136 @@
+package exposestrategy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DomainTLSAcme lets cert-manager issue the certificates of the hosts of the domain
+	DomainTLSAcme = "acme"
+	// DomainTLSNone exposes the hosts of the domain in plain HTTP
+	DomainTLSNone = "none"
+)
+
+// DomainTLSPolicy is the TLS policy of the hosts of a domain and its subdomains, the empty values are those of the controller
+type DomainTLSPolicy struct {
+	// TLS is "acme" or "none", the TLS setting of the controller if empty
+	TLS           string `yaml:"tls,omitempty" json:"tls"`
+	TLSSecretName string `yaml:"tls-secret-name,omitempty" json:"tls_secret_name"`
+}
+
+// checkDomainTLSPolicies checks the TLS modes of the domains
+func checkDomainTLSPolicies(policies map[string]DomainTLSPolicy) error {
+	domains := make([]string, 0, len(policies))
+	for domain := range policies {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	for _, domain := range domains {
+		policy := policies[domain]
+		if policy.TLS != "" && policy.TLS != DomainTLSAcme && policy.TLS != DomainTLSNone {
+			return errors.Errorf("invalid TLS \"%s\" of domain %s, must be \"%s\" or \"%s\"",
+				policy.TLS, domain, DomainTLSAcme, DomainTLSNone)
+		}
+		if policy.TLS == DomainTLSNone && policy.TLSSecretName != "" {
+			return errors.Errorf("domain %s cannot have a TLS secret without TLS", domain)
+		}
+	}
+	return nil
+}
+
+// mergeTLSSecretsBySuffix adds the secrets of the host suffixes, such as "*.apps.example.com", to the policies of their domains
+// the policies are copied, a domain cannot have two secrets, nor a secret without TLS
+func mergeTLSSecretsBySuffix(policies map[string]DomainTLSPolicy, secrets map[string]string) (map[string]DomainTLSPolicy, error) {
+	if len(secrets) == 0 {
+		return policies, nil
+	}
+	merged := make(map[string]DomainTLSPolicy, len(policies)+len(secrets))
+	for domain, policy := range policies {
+		merged[domain] = policy
+	}
+	suffixes := make([]string, 0, len(secrets))
+	for suffix := range secrets {
+		suffixes = append(suffixes, suffix)
+	}
+	sort.Strings(suffixes)
+	for _, suffix := range suffixes {
+		secret := secrets[suffix]
+		domain := strings.TrimPrefix(strings.TrimPrefix(suffix, "*"), ".")
+		if domain == "" || strings.Contains(domain, "*") {
+			return nil, errors.Errorf("invalid TLS host suffix \"%s\", must be a domain such as \"*.apps.example.com\"", suffix)
+		}
+		if secret == "" {
+			return nil, errors.Errorf("no TLS secret for host suffix %s", suffix)
+		}
+		policy := merged[domain]
+		if policy.TLSSecretName != "" && policy.TLSSecretName != secret {
+			return nil, errors.Errorf("host suffix %s has the TLS secrets %s and %s", suffix, policy.TLSSecretName, secret)
+		}
+		if policy.TLS == DomainTLSNone {
+			return nil, errors.Errorf("host suffix %s cannot have a TLS secret without TLS", suffix)
+		}
+		policy.TLSSecretName = secret
+		merged[domain] = policy
+	}
+	return merged, nil
+}
+
+// usesDomainAcme tells if a domain lets cert-manager issue its certificates
+func usesDomainAcme(policies map[string]DomainTLSPolicy) bool {
+	for _, policy := range policies {
+		if policy.TLS == DomainTLSAcme {
+			return true
+		}
+	}
+	return false
+}
+
+// domainTLSPolicy returns the policy of the most specific domain of the host
+func (s *IngressStrategy) domainTLSPolicy(host string) DomainTLSPolicy {
+	var policy DomainTLSPolicy
+	matched := ""
+	for domain, p := range s.domainTLSPolicies {
+		if (host == domain || strings.HasSuffix(host, "."+domain)) && len(domain) > len(matched) {
+			policy, matched = p, domain
+		}
+	}
+	return policy
+}
+
+// hostTLS returns the secret of the certificate of the host, empty without TLS,
+// and if the certificate is issued by cert-manager from the "kubernetes.io/tls-acme" annotation
+// secretName is the one of the team of the service, or of the controller
+// the services opting out of ACME use their own secret instead of the certificates issued by cert-manager
+func (s *IngressStrategy) hostTLS(host, secretName, appName string, optOut acmeOptOut) (string, bool) {
+	policy := s.domainTLSPolicy(host)
+	tlsAcme := s.tlsAcme
+	switch policy.TLS {
+	case DomainTLSNone:
+		return "", false
+	case DomainTLSAcme:
+		tlsAcme = true
+	}
+	if policy.TLSSecretName != "" {
+		secretName = policy.TLSSecretName
+	}
+	if !tlsAcme {
+		return secretName, false
+	}
+	if optOut.enabled {
+		return optOut.secretName, false
+	}
+	// with dns01, the hosts use the wildcard certificates of their domains unless a secret is set
+	if s.acmeChallengeType == AcmeChallengeDNS01 {
+		if secretName == "" {
+			return wildcardSecretName(host), false
+		}
+		return secretName, false
+	}
+	if secretName == "" {
+		secretName = "tls-" + appName
+	}
+	return secretName, true
+}
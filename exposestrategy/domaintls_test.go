@@ -0,0 +1,163 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_DomainTLSPolicies(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				"fabric8.io/use.internal.domain": "both",
+				AdditionalHostsAnnotationKey:     "svc.partner.com,svc.other.com",
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(service)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:        "ingress",
+		Namespace:      "main",
+		Domain:         "my-domain.com",
+		InternalDomain: "internal.lan",
+		TLSAcme:        true,
+		DomainTLSPolicies: map[string]DomainTLSPolicy{
+			"internal.lan": {TLS: DomainTLSNone},
+			"partner.com":  {TLSSecretName: "partner-tls"},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(service))
+
+	ctx := context.Background()
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "true", ingress.Annotations["kubernetes.io/tls-acme"])
+	assert.Len(t, ingress.Spec.Rules, 4, "the plain HTTP hosts are still routed")
+	assert.Equal(t, []networkingv1.IngressTLS{{
+		Hosts:      []string{"svc.partner.com"},
+		SecretName: "partner-tls",
+	}, {
+		Hosts:      []string{"svc.main.my-domain.com", "svc.other.com"},
+		SecretName: "tls-svc",
+	}}, ingress.Spec.TLS)
+	service, err = client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://svc.main.my-domain.com", service.Annotations[ExposeAnnotationKey])
+
+	// the hosts of a plain HTTP domain are published in http
+	ingress.ResourceVersion = "1"
+	_, err = client.NetworkingV1().Ingresses("main").Update(ctx, ingress, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	service.Annotations = map[string]string{"fabric8.io/use.internal.domain": "true"}
+	require.NoError(t, strategy.Add(service))
+	ingress, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, ingress.Annotations, "kubernetes.io/tls-acme")
+	assert.Empty(t, ingress.Spec.TLS)
+	service, err = client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "http://svc.main.internal.lan", service.Annotations[ExposeAnnotationKey])
+}
+
+func TestIngressStrategy_DomainTLSPolicyAcme(t *testing.T) {
+	strategy := &IngressStrategy{
+		acmeChallengeType: AcmeChallengeHTTP01,
+		tlsSecretName:     "default-tls",
+		domainTLSPolicies: map[string]DomainTLSPolicy{
+			"my-domain.com":      {TLS: DomainTLSAcme},
+			"main.my-domain.com": {TLS: DomainTLSNone},
+		},
+	}
+	secret, acme := strategy.hostTLS("svc.my-domain.com", "", "svc", acmeOptOut{})
+	assert.Equal(t, "tls-svc", secret)
+	assert.True(t, acme)
+	secret, acme = strategy.hostTLS("svc.main.my-domain.com", "", "svc", acmeOptOut{})
+	assert.Equal(t, "", secret, "the most specific domain applies")
+	assert.False(t, acme)
+	secret, acme = strategy.hostTLS("svc.other.com", "team-tls", "svc", acmeOptOut{})
+	assert.Equal(t, "team-tls", secret)
+	assert.False(t, acme)
+
+	err := checkDomainTLSPolicies(map[string]DomainTLSPolicy{"my-domain.com": {TLS: "plain"}})
+	assert.EqualError(t, err, `invalid TLS "plain" of domain my-domain.com, must be "acme" or "none"`)
+	err = checkDomainTLSPolicies(map[string]DomainTLSPolicy{"my-domain.com": {TLS: DomainTLSNone, TLSSecretName: "tls"}})
+	assert.EqualError(t, err, "domain my-domain.com cannot have a TLS secret without TLS")
+}
+
+func TestIngressStrategy_TLSSecretsBySuffix(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				AdditionalHostsAnnotationKey: "svc.intra.example.com,svc.other.com",
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(service)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:       "ingress",
+		Namespace:     "main",
+		Domain:        "apps.example.com",
+		URLTemplate:   "{{.Service}}.{{.Domain}}",
+		TLSSecretName: "default-tls",
+		TLSSecretsBySuffix: map[string]string{
+			"*.apps.example.com":  "wildcard-apps",
+			"*.intra.example.com": "wildcard-intra",
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(service))
+
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(context.Background(), "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []networkingv1.IngressTLS{{
+		Hosts:      []string{"svc.other.com"},
+		SecretName: "default-tls",
+	}, {
+		Hosts:      []string{"svc.apps.example.com"},
+		SecretName: "wildcard-apps",
+	}, {
+		Hosts:      []string{"svc.intra.example.com"},
+		SecretName: "wildcard-intra",
+	}}, ingress.Spec.TLS)
+}
+
+func TestMergeTLSSecretsBySuffix(t *testing.T) {
+	policies := map[string]DomainTLSPolicy{"apps.example.com": {TLS: DomainTLSAcme}}
+	merged, err := mergeTLSSecretsBySuffix(policies, map[string]string{"*.apps.example.com": "wildcard-apps", ".intra.example.com": "wildcard-intra"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]DomainTLSPolicy{
+		"apps.example.com":  {TLS: DomainTLSAcme, TLSSecretName: "wildcard-apps"},
+		"intra.example.com": {TLSSecretName: "wildcard-intra"},
+	}, merged)
+	assert.Equal(t, DomainTLSPolicy{TLS: DomainTLSAcme}, policies["apps.example.com"], "the policies of the config are not changed")
+
+	_, err = mergeTLSSecretsBySuffix(nil, map[string]string{"*": "tls"})
+	assert.EqualError(t, err, `invalid TLS host suffix "*", must be a domain such as "*.apps.example.com"`)
+	_, err = mergeTLSSecretsBySuffix(map[string]DomainTLSPolicy{"example.com": {TLSSecretName: "a"}}, map[string]string{"*.example.com": "b"})
+	assert.EqualError(t, err, "host suffix *.example.com has the TLS secrets a and b")
+	_, err = mergeTLSSecretsBySuffix(map[string]DomainTLSPolicy{"example.com": {TLS: DomainTLSNone}}, map[string]string{"*.example.com": "b"})
+	assert.EqualError(t, err, "host suffix *.example.com cannot have a TLS secret without TLS")
+}
@@ -0,0 +1,80 @@
+package exposestrategy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"k8s.io/api/core/v1"
+)
+
+var (
+	// ErrNoPorts is returned when the service has no port to expose
+	ErrNoPorts = errors.New("no port to expose")
+	// ErrHostTooLong is returned when the host name of the service has a label of more than 63 characters,
+	// or more than 253 characters
+	ErrHostTooLong = errors.New("host name too long")
+	// ErrConflict is returned when the hosts of the service are claimed by another ingress with the "error" host conflict policy,
+	// or when its ingress is generated by another instance of the controller
+	ErrConflict = errors.New("hosts of the service already claimed")
+	// ErrNotHTTP is returned when the exposed port of the service does not speak HTTP and cannot be exposed by an ingress
+	ErrNotHTTP = errors.New("port not speaking HTTP")
+)
+
+// ServiceError is returned by the strategies when a service cannot be exposed as is,
+// errors.Is matches it with its kind, one of ErrNoPorts, ErrHostTooLong, ErrConflict or ErrNotHTTP
+type ServiceError struct {
+	Namespace string
+	Service   string
+	Kind      error
+	message   string
+}
+
+func newServiceError(svc *v1.Service, kind error, format string, args ...interface{}) *ServiceError {
+	return &ServiceError{
+		Namespace: svc.Namespace,
+		Service:   svc.Name,
+		Kind:      kind,
+		message:   fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *ServiceError) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("service %s/%s: %v", e.Namespace, e.Service, e.Kind)
+	}
+	return e.message
+}
+
+// Unwrap returns the kind of the error
+func (e *ServiceError) Unwrap() error {
+	return e.Kind
+}
+
+// Retryable tells if exposing the service again may succeed without a change of the service,
+// the errors of the service itself, such as the ServiceErrors and AnnotationParseErrors, only go away once it is fixed
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var serviceErr *ServiceError
+	var parseErr *AnnotationParseError
+	return !errors.As(err, &serviceErr) && !errors.As(err, &parseErr)
+}
+
+// checkHostLength returns an ErrHostTooLong error if the host name cannot be a DNS name
+func checkHostLength(svc *v1.Service, hostName string) error {
+	if len(hostName) > validation.DNS1123SubdomainMaxLength {
+		return newServiceError(svc, ErrHostTooLong, "host name \"%s\" of service %s/%s is longer than %d characters",
+			hostName, svc.Namespace, svc.Name, validation.DNS1123SubdomainMaxLength)
+	}
+	for _, label := range strings.Split(hostName, ".") {
+		if len(label) > validation.DNS1123LabelMaxLength {
+			return newServiceError(svc, ErrHostTooLong, "label \"%s\" of host name \"%s\" of service %s/%s is longer than %d characters",
+				label, hostName, svc.Namespace, svc.Name, validation.DNS1123LabelMaxLength)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package exposestrategy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_ServiceErrors(t *testing.T) {
+	newService := func(name string, ports ...v1.ServicePort) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: name},
+			Spec:       v1.ServiceSpec{Ports: ports},
+		}
+	}
+	noPorts := newService("no-ports")
+	long := newService("long", v1.ServicePort{Port: 80})
+	long.Annotations = map[string]string{"fabric8.io/host.name": strings.Repeat("a", 64)}
+	client := fake.NewSimpleClientset(noPorts, long)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:   "ingress",
+		Namespace: "main",
+		Domain:    "my-domain.com",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+
+	err = strategy.Add(noPorts)
+	assert.EqualError(t, err, "no port to expose in service main/no-ports")
+	assert.True(t, errors.Is(err, ErrNoPorts))
+	assert.False(t, Retryable(err))
+	var serviceErr *ServiceError
+	require.True(t, errors.As(err, &serviceErr))
+	assert.Equal(t, "no-ports", serviceErr.Service)
+
+	err = strategy.Add(long)
+	assert.True(t, errors.Is(err, ErrHostTooLong))
+	assert.Contains(t, err.Error(), "is longer than 63 characters")
+	assert.False(t, errors.Is(err, ErrNoPorts))
+}
+
+func TestRetryable(t *testing.T) {
+	assert.False(t, Retryable(nil))
+	assert.True(t, Retryable(errors.New("connection refused")))
+	assert.False(t, Retryable(errors.Wrap(&ServiceError{Kind: ErrConflict}, "failed to expose")))
+	assert.False(t, Retryable(&AnnotationParseError{Err: errors.New("yaml: line 1")}))
+
+	svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "svc"}}
+	assert.NoError(t, checkHostLength(svc, "svc.main.my-domain.com"))
+	assert.True(t, errors.Is(checkHostLength(svc, strings.Repeat("a.", 127)+"com"), ErrHostTooLong))
+}
@@ -0,0 +1,42 @@
+package exposestrategy
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// EmitServiceEvent emits an event on the service, errors are only logged
+func EmitServiceEvent(ctx context.Context, client kubernetes.Interface, svc *v1.Service, eventType, reason, message string) {
+	now := metav1.Now()
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: svc.Namespace,
+			Name:      fmt.Sprintf("%s.%x", svc.Name, now.UnixNano()),
+		},
+		InvolvedObject: v1.ObjectReference{
+			Kind:            ServiceKind,
+			APIVersion:      ServiceAPIVersion,
+			Namespace:       svc.Namespace,
+			Name:            svc.Name,
+			UID:             svc.UID,
+			ResourceVersion: svc.ResourceVersion,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         v1.EventSource{Component: "exposecontroller"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err := client.CoreV1().Events(svc.Namespace).Create(ctx, event, metav1.CreateOptions{})
+	if err != nil {
+		klog.Warningf("Failed to emit event %s on service %s/%s: %v", reason, svc.Namespace, svc.Name, err)
+	}
+}
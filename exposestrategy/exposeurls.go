@@ -0,0 +1,122 @@
+package exposestrategy
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// ExposeURLsAnnotationKey annotation will be created with the URLs of the exposed ports in JSON, when there are several
+const ExposeURLsAnnotationKey = "fabric8.io/exposeUrls"
+
+// portURLKey returns the key of the port in the URLs: its name, its app protocol or its number
+func portURLKey(port *v1.ServicePort) string {
+	if port.Name != "" {
+		return port.Name
+	}
+	if protocol := appProtocol(port); protocol != "" {
+		return protocol
+	}
+	return strconv.Itoa(int(port.Port))
+}
+
+// portProtocol returns the HTTP protocol of the port from its name, its app protocol or its number
+func portProtocol(port *v1.ServicePort) string {
+	if port.Name == "https" || appProtocol(port) == "https" || port.Port == 443 || port.Port == 8443 {
+		return "https"
+	}
+	return "http"
+}
+
+// buildURL builds the URL of the host with the given port, omitted if 0 or the default port of the protocol
+func buildURL(hostName string, port int32, path, protocol string) string {
+	url := protocol + "://" + hostName
+	if port != 0 && !(protocol == "http" && port == 80) && !(protocol == "https" && port == 443) {
+		url = protocol + "://" + net.JoinHostPort(hostName, strconv.Itoa(int(port)))
+	}
+	if len(path) > 0 {
+		url = urlJoin(url, path)
+	}
+	return url
+}
+
+// setServiceURLs publishes the URLs of the exposed ports, the annotation is removed with less than 2 ports
+func setServiceURLs(svc *v1.Service, urls map[string]string) error {
+	if len(urls) < 2 {
+		delete(svc.Annotations, ExposeURLsAnnotationKey)
+		return nil
+	}
+	// the keys of the maps are sorted by the encoding
+	value, err := json.Marshal(urls)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode the URLs of service %s/%s", svc.Namespace, svc.Name)
+	}
+	if svc.Annotations == nil {
+		svc.Annotations = map[string]string{}
+	}
+	svc.Annotations[ExposeURLsAnnotationKey] = string(value)
+	return nil
+}
+
+// findBackendPort returns the port of the service targeted by the backend port, nil if none
+func findBackendPort(svc *v1.Service, backendPort networkingv1.ServiceBackendPort) *v1.ServicePort {
+	for i, p := range svc.Spec.Ports {
+		if (backendPort.Name != "" && backendPort.Name == p.Name) || (backendPort.Name == "" && backendPort.Number == p.Port) {
+			return &svc.Spec.Ports[i]
+		}
+	}
+	return nil
+}
+
+// ingressURLs returns the URLs of the paths of the host routing to the ports of the service,
+// the exposed port keeps the exposed URL while the template of the ingress may add the paths of other ports
+func ingressURLs(svc *v1.Service, rules []networkingv1.IngressRule, hostName string, port int32, protocol string,
+	servicePort *v1.ServicePort, exposedBackend networkingv1.ServiceBackendPort, exposeURL string) map[string]string {
+	urls := map[string]string{portURLKey(servicePort): exposeURL}
+	for _, rule := range rules {
+		if rule.Host != hostName || rule.HTTP == nil {
+			continue
+		}
+		for _, p := range rule.HTTP.Paths {
+			if p.Backend.Service == nil || p.Backend.Service.Port == exposedBackend {
+				continue
+			}
+			backendPort := findBackendPort(svc, p.Backend.Service.Port)
+			if backendPort == nil || backendPort.Port == servicePort.Port {
+				continue
+			}
+			key := portURLKey(backendPort)
+			if _, ok := urls[key]; !ok {
+				urls[key] = buildURL(hostName, port, p.Path, protocol)
+			}
+		}
+	}
+	return urls
+}
+
+// loadBalancerURLs returns the URLs of all the ports of the service on the IP of its load balancer, with the scheme of the non HTTP ports
+func loadBalancerURLs(svc *v1.Service) map[string]string {
+	if svc.Spec.LoadBalancerIP == "" {
+		return nil
+	}
+	urls := map[string]string{}
+	exposed := exposedPort(svc)
+	for i := range svc.Spec.Ports {
+		port := &svc.Spec.Ports[i]
+		scheme := detectPortScheme(port)
+		if port == exposed {
+			// the annotation was checked by the exposure
+			scheme, _ = portScheme(svc, port)
+		}
+		if scheme == "" {
+			scheme = portProtocol(port)
+		}
+		urls[portURLKey(port)] = buildURL(svc.Spec.LoadBalancerIP, port.Port, "", scheme)
+	}
+	return urls
+}
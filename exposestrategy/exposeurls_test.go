@@ -0,0 +1,100 @@
+package exposestrategy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPortsIngressTemplate = `apiVersion: networking.k8s.io/v1
+kind: Ingress
+spec:
+  rules:
+  - host: {{ .Host }}
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: {{ .BackendName }}
+            port:
+              number: {{ .BackendPort }}
+      - path: /grpc
+        pathType: Prefix
+        backend:
+          service:
+            name: {{ .BackendName }}
+            port:
+              name: grpc
+`
+
+func TestIngressStrategy_ExposeURLs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "main",
+			Name:            "svc",
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Name: "http", Port: 8080}, {Name: "grpc", Port: 9090}},
+		},
+	}
+	client := fake.NewSimpleClientset(service)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:         "ingress",
+		Namespace:       "main",
+		Domain:          "my-domain.com",
+		HTTP:            true,
+		IngressTemplate: writeTemplate(t, dir, "ingress.yaml", testPortsIngressTemplate),
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(service))
+
+	ctx := context.Background()
+	service, err = client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "http://svc.main.my-domain.com", service.Annotations[ExposeAnnotationKey])
+	assert.Equal(t, `{"grpc":"http://svc.main.my-domain.com/grpc","http":"http://svc.main.my-domain.com"}`,
+		service.Annotations[ExposeURLsAnnotationKey])
+
+	assert.True(t, removeServiceAnnotation(service))
+	assert.NotContains(t, service.Annotations, ExposeURLsAnnotationKey)
+}
+
+func TestLoadBalancerURLs(t *testing.T) {
+	https := "https"
+	service := &v1.Service{
+		Spec: v1.ServiceSpec{
+			LoadBalancerIP: "1.2.3.4",
+			Ports: []v1.ServicePort{
+				{Name: "web", Port: 80},
+				{Name: "secure", Port: 8443, AppProtocol: &https},
+				{Port: 9090},
+			},
+		},
+	}
+	assert.Equal(t, map[string]string{
+		"web":    "http://1.2.3.4",
+		"secure": "https://1.2.3.4:8443",
+		"9090":   "http://1.2.3.4:9090",
+	}, loadBalancerURLs(service))
+
+	require.NoError(t, setServiceURLs(service, map[string]string{"web": "http://1.2.3.4"}))
+	assert.NotContains(t, service.Annotations, ExposeURLsAnnotationKey, "a single URL is the exposed one")
+	service.Spec.LoadBalancerIP = ""
+	assert.Empty(t, loadBalancerURLs(service))
+}
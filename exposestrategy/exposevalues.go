@@ -0,0 +1,91 @@
+package exposestrategy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+)
+
+// CanonicalExposeValue is the value of the expose label and annotations requesting the exposure of a service,
+// the only one accepted in strict mode
+const CanonicalExposeValue = "true"
+
+// legacyExposeValues are the values of the fabric8 era documentation, accepted only if configured
+var legacyExposeValues = []string{"ingress", "expose"}
+
+// ExposeValues are the values of the expose label and annotations requesting the exposure of the services
+// the configured values are compared regardless of the case and of the surrounding spaces, the default "true" as is
+type ExposeValues struct {
+	accepted map[string]bool
+	// exact compares the values as is, by default or in strict mode
+	exact bool
+}
+
+// NewExposeValues checks the accepted values, "true" as is if empty
+// in strict mode, only "true" is accepted as is
+func NewExposeValues(values []string, strict bool) (*ExposeValues, error) {
+	v := &ExposeValues{accepted: map[string]bool{}, exact: strict || len(values) == 0}
+	if len(values) == 0 {
+		values = []string{CanonicalExposeValue}
+	}
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value != CanonicalExposeValue && !contains(legacyExposeValues, value) {
+			return nil, errors.Errorf("invalid expose value \"%s\", must be one of \"%s\"",
+				value, strings.Join(append([]string{CanonicalExposeValue}, legacyExposeValues...), "\", \""))
+		}
+		if strict && value != CanonicalExposeValue {
+			return nil, errors.Errorf("only \"%s\" is accepted in strict mode, got expose value \"%s\"", CanonicalExposeValue, value)
+		}
+		v.accepted[value] = true
+	}
+	return v, nil
+}
+
+// isAccepted tells if the value requests the exposure, only "true" is accepted if nil
+func (v *ExposeValues) isAccepted(value string) bool {
+	if v == nil {
+		return value == CanonicalExposeValue
+	}
+	if v.exact {
+		return v.accepted[value]
+	}
+	return v.accepted[strings.ToLower(strings.TrimSpace(value))]
+}
+
+// IsRequested tells if the expose label or one of the expose annotations of the service requests its exposure
+func (v *ExposeValues) IsRequested(svc *v1.Service) bool {
+	return v.isAccepted(svc.Labels[ExposeLabel.Key]) ||
+		v.isAccepted(svc.Annotations[ExposeAnnotation.Key]) ||
+		v.isAccepted(svc.Annotations[InjectAnnotation.Key])
+}
+
+// Invalid returns the error of the expose annotations of the service having a value which is neither accepted nor "false",
+// nil if none, the expose label is not checked as other tools may use it
+func (v *ExposeValues) Invalid(svc *v1.Service) error {
+	invalid := []string{}
+	for _, key := range []string{ExposeAnnotation.Key, InjectAnnotation.Key} {
+		value, ok := svc.Annotations[key]
+		if !ok || v.isAccepted(value) || strings.EqualFold(strings.TrimSpace(value), "false") {
+			continue
+		}
+		invalid = append(invalid, key+"=\""+value+"\"")
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+	sort.Strings(invalid)
+	accepted := []string{CanonicalExposeValue}
+	if v != nil {
+		accepted = accepted[:0]
+		for value := range v.accepted {
+			accepted = append(accepted, value)
+		}
+		sort.Strings(accepted)
+	}
+	return errors.Errorf("invalid expose value %s in service %s/%s, the accepted values are \"%s\" and \"false\"",
+		strings.Join(invalid, ", "), svc.Namespace, svc.Name, strings.Join(accepted, "\", \""))
+}
@@ -0,0 +1,95 @@
+package exposestrategy
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExposeValues(t *testing.T) {
+	newService := func(labels, annotations map[string]string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "main",
+				Name:        "svc",
+				Labels:      labels,
+				Annotations: annotations,
+			},
+		}
+	}
+	lenient, err := NewExposeValues([]string{"true", "Ingress"}, false)
+	require.NoError(t, err)
+	strict, err := NewExposeValues(nil, true)
+	require.NoError(t, err)
+
+	examples := []struct {
+		name      string
+		service   *v1.Service
+		values    *ExposeValues
+		requested bool
+		invalid   string
+	}{{
+		name:      "default",
+		service:   newService(nil, map[string]string{ExposeAnnotation.Key: "true"}),
+		requested: true,
+	}, {
+		name:    "default case",
+		service: newService(nil, map[string]string{ExposeAnnotation.Key: " True"}),
+		invalid: `invalid expose value fabric8.io/expose=" True" in service main/svc, the accepted values are "true" and "false"`,
+	}, {
+		name:    "default legacy value",
+		service: newService(nil, map[string]string{ExposeAnnotation.Key: "ingress"}),
+		invalid: `invalid expose value fabric8.io/expose="ingress" in service main/svc, the accepted values are "true" and "false"`,
+	}, {
+		name:      "label",
+		service:   newService(map[string]string{ExposeLabel.Key: "true"}, nil),
+		requested: true,
+	}, {
+		name:    "label not checked",
+		service: newService(map[string]string{ExposeLabel.Key: "public"}, nil),
+	}, {
+		name:    "false",
+		service: newService(nil, map[string]string{ExposeAnnotation.Key: "False", InjectAnnotation.Key: "false"}),
+	}, {
+		name:      "lenient legacy value",
+		service:   newService(nil, map[string]string{InjectAnnotation.Key: " INGRESS "}),
+		values:    lenient,
+		requested: true,
+	}, {
+		name:    "lenient unknown value",
+		service: newService(nil, map[string]string{ExposeAnnotation.Key: "expose", InjectAnnotation.Key: "yes"}),
+		values:  lenient,
+		invalid: `invalid expose value fabric8.io/expose="expose", fabric8.io/inject="yes" in service main/svc, the accepted values are "ingress", "true" and "false"`,
+	}, {
+		name:    "strict case",
+		service: newService(nil, map[string]string{ExposeAnnotation.Key: "True"}),
+		values:  strict,
+		invalid: `invalid expose value fabric8.io/expose="True" in service main/svc, the accepted values are "true" and "false"`,
+	}, {
+		name:      "exposed by another annotation",
+		service:   newService(nil, map[string]string{ExposeAnnotation.Key: "true", InjectAnnotation.Key: "maybe"}),
+		values:    strict,
+		requested: true,
+		invalid:   `invalid expose value fabric8.io/inject="maybe" in service main/svc, the accepted values are "true" and "false"`,
+	}}
+	for _, example := range examples {
+		assert.Equal(t, example.requested, example.values.IsRequested(example.service), example.name)
+		err := example.values.Invalid(example.service)
+		if example.invalid == "" {
+			assert.NoError(t, err, example.name)
+		} else {
+			assert.EqualError(t, err, example.invalid, example.name)
+		}
+	}
+}
+
+func TestNewExposeValues(t *testing.T) {
+	_, err := NewExposeValues([]string{"yes"}, false)
+	assert.EqualError(t, err, `invalid expose value "yes", must be one of "true", "ingress", "expose"`)
+	_, err = NewExposeValues([]string{"true", "expose"}, true)
+	assert.EqualError(t, err, `only "true" is accepted in strict mode, got expose value "expose"`)
+}
@@ -0,0 +1,103 @@
+package exposestrategy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the fuzz tests run their seeds with go test, go test ./exposestrategy -run '^$' -fuzz FuzzParseAnnotationsYAML fuzzes one of them
+
+func FuzzParseAnnotationsYAML(f *testing.F) {
+	f.Add("nginx.ingress.kubernetes.io/proxy-body-size: 8m", true)
+	f.Add("a: b\na: c", true)
+	f.Add("key:\n  nested: value", false)
+	f.Add("- a\n- b", false)
+	f.Add("\"invalid key!\": value\n", true)
+	f.Add("a: &a [*a]", false)
+	f.Add("a: b\n\tc: d", true)
+	svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "svc"}}
+	f.Fuzz(func(t *testing.T, text string, strict bool) {
+		annotations := map[string]string{}
+		err := parseAnnotationsYAML(svc, IngressAnnotationsAnnotationKey, text, strict, annotations)
+		if err != nil {
+			parseErr, ok := err.(*AnnotationParseError)
+			require.True(t, ok, "unexpected error %v", err)
+			if parseErr.Line > 0 {
+				assert.LessOrEqual(t, parseErr.Line, strings.Count(text, "\n")+1)
+				assert.Greater(t, parseErr.Column, 0)
+			}
+			return
+		}
+		if strict {
+			for key := range annotations {
+				assert.Empty(t, validation.IsQualifiedName(key), "invalid key %q", key)
+			}
+		}
+	})
+}
+
+func FuzzGetURLFormat(f *testing.F) {
+	f.Add("{{.Service}}.{{.Namespace}}.{{.Domain}}")
+	f.Add("{{.Service}}-{{.Namespace}}.{{.Domain}}")
+	f.Add("{{.Service}}%d.{{.Domain}}")
+	f.Add("{{.Service}}.{{.Unknown}}")
+	f.Add("{{.Service")
+	f.Add("{{printf \"%s-x\" .Service}}.{{.Domain}}")
+	f.Fuzz(func(t *testing.T, urltemplate string) {
+		format, err := getURLFormat(urltemplate)
+		if err != nil {
+			return
+		}
+		host := fmt.Sprintf(format, "svc", "main", "my-domain.com")
+		assert.Contains(t, host, "svc", "the format %q of template %q is broken", format, urltemplate)
+	})
+}
+
+func FuzzFindExposePort(f *testing.F) {
+	f.Add("8080")
+	f.Add("http")
+	f.Add("-1")
+	f.Add("99999999999999999999")
+	f.Add("+8080")
+	f.Add("")
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "svc"},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Name: "http", Port: 8080}, {Name: "metrics", Port: 9090}},
+		},
+	}
+	f.Fuzz(func(t *testing.T, exposePort string) {
+		port, err := findExposePort(svc, exposePort)
+		if err != nil {
+			assert.Nil(t, port)
+			return
+		}
+		if port != nil {
+			assert.True(t, exposePort == port.Name || strings.TrimLeft(strings.TrimPrefix(exposePort, "+"), "0") == fmt.Sprint(port.Port),
+				"port %v chosen for %q", port, exposePort)
+		}
+	})
+}
+
+func TestGetURLFormat(t *testing.T) {
+	format, err := getURLFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, "%[1]s.%[2]s.%[3]s", format)
+	format, err = getURLFormat("{{.Service}}-100%.{{.Domain}}")
+	require.NoError(t, err)
+	assert.Equal(t, "svc-100%.my-domain.com", fmt.Sprintf(format, "svc", "main", "my-domain.com"), "the percent signs are escaped")
+	_, err = getURLFormat("{{.Service")
+	assert.Error(t, err, "the invalid templates are not ignored")
+	_, err = getURLFormat("{{.Unknown}}")
+	assert.Error(t, err)
+	_, err = getURLFormat("{{.Namespace}}.{{.Domain}}")
+	assert.EqualError(t, err, `URLTemplate "{{.Namespace}}.{{.Domain}}" does not use the {{.Service}}`)
+}
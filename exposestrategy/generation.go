@@ -0,0 +1,63 @@
+package exposestrategy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ExposeGenerationAnnotationKey annotation of the service recreates its ingress from scratch when changed, such as "2",
+// instead of updating it, for the ingresses in a state an update does not repair, it is copied on the ingress
+const ExposeGenerationAnnotationKey = "fabric8.io/expose.generation"
+
+// ingressDeletionRecheckPeriod is how often an ingress being deleted is checked again before creating it again
+const ingressDeletionRecheckPeriod = 15 * time.Second
+
+// needsRecreate tells if the existing ingress generated for the service is of another expose generation,
+// the ingress of a group is shared by its members and never recreated for one of them
+func (s *IngressStrategy) needsRecreate(svc *v1.Service, existing *networkingv1.Ingress) bool {
+	generation := svc.Annotations[ExposeGenerationAnnotationKey]
+	if generation == "" || isGroupIngress(existing) || existing.Annotations[ExposeGenerationAnnotationKey] == generation {
+		return false
+	}
+	return s.provider.IsGenerated(existing.Annotations) && !isUnmanagedIngress(existing, "recreating")
+}
+
+// deleteForRecreate deletes the existing ingress of the service to create it again for its new expose generation
+func (s *IngressStrategy) deleteForRecreate(svc *v1.Service, existing *networkingv1.Ingress) error {
+	generation := svc.Annotations[ExposeGenerationAnnotationKey]
+	klog.Infof("recreating ingress %s/%s for the expose generation %s of service %s/%s",
+		existing.Namespace, existing.Name, generation, svc.Namespace, svc.Name)
+	// the ingress is created again at once, the foreground deletion would keep it until its dependents are deleted
+	options := metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{
+			ResourceVersion: &existing.ResourceVersion,
+		},
+	}
+	err := s.client.NetworkingV1().Ingresses(existing.Namespace).Delete(s.ctx, existing.Name, options)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete ingress %s/%s to recreate it", existing.Namespace, existing.Name)
+	}
+	EmitServiceEvent(s.ctx, s.client, svc, v1.EventTypeNormal, "IngressRecreated",
+		fmt.Sprintf("Recreated ingress %s/%s for the expose generation %s", existing.Namespace, existing.Name, generation))
+	return nil
+}
+
+// isIngressDeleting tells if the existing ingress is still being deleted, such as held by the finalizer of the ALB controller,
+// the service keeps its URL and the ingress is created again once gone
+func (s *IngressStrategy) isIngressDeleting(svc *v1.Service, existing *networkingv1.Ingress) bool {
+	if existing.DeletionTimestamp == nil {
+		return false
+	}
+	klog.Infof("ingress %s/%s of service %s/%s is being deleted, it is created again once gone",
+		existing.Namespace, existing.Name, svc.Namespace, svc.Name)
+	s.scheduleResync(ingressDeletionRecheckPeriod)
+	return true
+}
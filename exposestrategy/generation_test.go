@@ -0,0 +1,132 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_ExposeGeneration(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			UID:       "uid",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 80}},
+		},
+	}
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(svc)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:   "ingress",
+		Namespace: "main",
+		Domain:    "my-domain.com",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	deletes := func() int {
+		count := 0
+		for _, action := range client.Actions() {
+			if action.Matches("delete", "ingresses") {
+				count++
+			}
+		}
+		return count
+	}
+
+	require.NoError(t, strategy.Add(svc))
+	setIngressVersions(t, client)
+	// a broken ingress, such as edited by hand
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	ingress.Annotations["nginx.ingress.kubernetes.io/configuration-snippet"] = "broken"
+	_, err = client.NetworkingV1().Ingresses("main").Update(ctx, ingress, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	svc.Annotations = map[string]string{ExposeGenerationAnnotationKey: "2"}
+	require.NoError(t, strategy.Add(svc))
+	assert.Equal(t, 1, deletes(), "recreated")
+	ingress, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "2", ingress.Annotations[ExposeGenerationAnnotationKey])
+	assert.NotContains(t, ingress.Annotations, "nginx.ingress.kubernetes.io/configuration-snippet")
+	events, err := client.CoreV1().Events("main").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	assert.Equal(t, "IngressRecreated", events.Items[0].Reason)
+
+	setIngressVersions(t, client)
+	require.NoError(t, strategy.Add(svc))
+	assert.Equal(t, 1, deletes(), "same generation")
+}
+
+func TestIngressStrategy_ExposeGenerationFinalizer(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			UID:       "uid",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 80}},
+		},
+	}
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(svc)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:   "ingress",
+		Namespace: "main",
+		Domain:    "my-domain.com",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(svc))
+	setIngressVersions(t, client)
+	svc, err = client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	url := svc.Annotations[ExposeAnnotationKey]
+	require.NotEmpty(t, url)
+
+	// the finalizer of the ALB controller keeps the ingress until the load balancer is deleted
+	client.PrependReactor("delete", "ingresses", func(action ktesting.Action) (bool, runtime.Object, error) {
+		ingress, err := client.Tracker().Get(networkingv1.SchemeGroupVersion.WithResource("ingresses"), "main", "svc")
+		if err != nil {
+			return true, nil, err
+		}
+		deleting := ingress.(*networkingv1.Ingress).DeepCopy()
+		now := metav1.Now()
+		deleting.DeletionTimestamp = &now
+		deleting.Finalizers = []string{"ingress.k8s.aws/resources"}
+		return true, nil, client.Tracker().Update(networkingv1.SchemeGroupVersion.WithResource("ingresses"), deleting, "main")
+	})
+	svc.Annotations[ExposeGenerationAnnotationKey] = "2"
+	client.ClearActions()
+	require.NoError(t, strategy.Add(svc), "pending")
+	require.NoError(t, strategy.Add(svc), "still pending")
+	for _, action := range client.Actions() {
+		assert.False(t, action.Matches("create", "ingresses"), "not created while being deleted")
+		assert.False(t, action.Matches("patch", "services"), "URL kept")
+	}
+	published, err := client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, url, published.Annotations[ExposeAnnotationKey])
+
+	// created again once gone
+	require.NoError(t, client.Tracker().Delete(networkingv1.SchemeGroupVersion.WithResource("ingresses"), "main", "svc"))
+	require.NoError(t, strategy.Add(svc))
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "2", ingress.Annotations[ExposeGenerationAnnotationKey])
+	assert.Nil(t, ingress.DeletionTimestamp)
+}
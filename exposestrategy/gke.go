@@ -0,0 +1,229 @@
+package exposestrategy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// GKEBackendConfigAnnotationKey annotation holds the YAML spec of the BackendConfig of the service
+// such as "cdn: {enabled: true}", no BackendConfig is generated without it
+const GKEBackendConfigAnnotationKey = "fabric8.io/gke.backend-config"
+
+const (
+	// the annotations linking the ingress to its FrontendConfig and the service to its BackendConfig
+	frontendConfigAnnotationKey = "networking.gke.io/v1beta1.FrontendConfig"
+	backendConfigAnnotationKey  = "cloud.google.com/backend-config"
+)
+
+var (
+	// FrontendConfigResource is the resource of the GKE frontend configs
+	FrontendConfigResource = schema.GroupVersionResource{
+		Group:    "networking.gke.io",
+		Version:  "v1beta1",
+		Resource: "frontendconfigs",
+	}
+	// BackendConfigResource is the resource of the GKE backend configs
+	BackendConfigResource = schema.GroupVersionResource{
+		Group:    "cloud.google.com",
+		Version:  "v1",
+		Resource: "backendconfigs",
+	}
+)
+
+// buildGKEConfig builds a GKE config named after the ingress, with its labels and owner references
+func buildGKEConfig(ingress *networkingv1.Ingress, resource schema.GroupVersionResource, kind string, spec map[string]interface{}) *unstructured.Unstructured {
+	config := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": spec,
+	}}
+	config.SetAPIVersion(resource.GroupVersion().String())
+	config.SetKind(kind)
+	config.SetNamespace(ingress.Namespace)
+	config.SetName(ingress.Name)
+	labels := map[string]string{}
+	for k, v := range ingress.Labels {
+		labels[k] = v
+	}
+	config.SetLabels(labels)
+	config.SetAnnotations(map[string]string{
+		GeneratedByAnnotationKey: ingress.Annotations[GeneratedByAnnotationKey],
+	})
+	config.SetOwnerReferences(ingress.OwnerReferences)
+	return config
+}
+
+// buildFrontendConfig builds the FrontendConfig redirecting HTTP to HTTPS when the ingress has TLS
+func buildFrontendConfig(ingress *networkingv1.Ingress, https bool) *unstructured.Unstructured {
+	return buildGKEConfig(ingress, FrontendConfigResource, "FrontendConfig", map[string]interface{}{
+		"redirectToHttps": map[string]interface{}{
+			"enabled": https,
+		},
+	})
+}
+
+// buildBackendConfig builds the BackendConfig from the annotation of the service, nil without annotation
+func buildBackendConfig(ingress *networkingv1.Ingress, svc *v1.Service) (*unstructured.Unstructured, error) {
+	text := svc.Annotations[GKEBackendConfigAnnotationKey]
+	if text == "" {
+		return nil, nil
+	}
+	var value interface{}
+	err := yaml.Unmarshal([]byte(text), &value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse annotation \"%s\" in service %s/%s",
+			GKEBackendConfigAnnotationKey, svc.Namespace, svc.Name)
+	}
+	spec, ok := toJSONValue(value).(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("annotation \"%s\" in service %s/%s must be a YAML object",
+			GKEBackendConfigAnnotationKey, svc.Namespace, svc.Name)
+	}
+	return buildGKEConfig(ingress, BackendConfigResource, "BackendConfig", spec), nil
+}
+
+// toJSONValue converts the values decoded from YAML to the JSON ones held by the unstructured objects
+func toJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for key, item := range v {
+			m[fmt.Sprint(key)] = toJSONValue(item)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, item := range v {
+			l[i] = toJSONValue(item)
+		}
+		return l
+	case int:
+		return int64(v)
+	default:
+		return v
+	}
+}
+
+// backendConfigAnnotation returns the value of the service annotation linking the BackendConfig
+func backendConfigAnnotation(name string) string {
+	return `{"default":"` + name + `"}`
+}
+
+// applyGeneratedObject creates or updates the object generated by the controller, such as a GKE config
+func applyGeneratedObject(ctx context.Context, client dynamic.Interface, resource schema.GroupVersionResource, config *unstructured.Unstructured) error {
+	kind := config.GetKind()
+	configs := client.Resource(resource).Namespace(config.GetNamespace())
+	existing, err := configs.Get(ctx, config.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.Infof("creating %s %s/%s", kind, config.GetNamespace(), config.GetName())
+		_, err = configs.Create(ctx, config, metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create %s %s/%s", kind, config.GetNamespace(), config.GetName())
+		}
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "could not check for existing %s %s/%s", kind, config.GetNamespace(), config.GetName())
+	}
+	if existing.GetAnnotations()[GeneratedByAnnotationKey] != config.GetAnnotations()[GeneratedByAnnotationKey] {
+		return errors.Errorf("%s %s/%s already exists and was not generated by exposecontroller",
+			kind, config.GetNamespace(), config.GetName())
+	}
+	if reflect.DeepEqual(config.Object["spec"], existing.Object["spec"]) &&
+		reflect.DeepEqual(config.GetLabels(), existing.GetLabels()) &&
+		reflect.DeepEqual(config.GetOwnerReferences(), existing.GetOwnerReferences()) {
+		return nil
+	}
+	config.SetResourceVersion(existing.GetResourceVersion())
+	klog.Infof("updating %s %s/%s", kind, config.GetNamespace(), config.GetName())
+	_, err = configs.Update(ctx, config, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to update %s %s/%s", kind, config.GetNamespace(), config.GetName())
+	}
+	return nil
+}
+
+// deleteGeneratedObject deletes the object with the propagation policy if it was generated by the controller, or releases it with neverDelete
+func deleteGeneratedObject(ctx context.Context, client dynamic.Interface, resource schema.GroupVersionResource, namespace, name string, neverDelete bool, provider ProviderLabel, propagation *metav1.DeletionPropagation) {
+	configs := client.Resource(resource).Namespace(namespace)
+	existing, err := configs.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return
+	} else if err != nil {
+		klog.Errorf("error when getting %s %s/%s: %s", resource.Resource, namespace, name, err)
+		return
+	}
+	if !provider.IsGenerated(existing.GetAnnotations()) {
+		return
+	}
+	if neverDelete {
+		releaseUnstructured(ctx, configs, existing, provider)
+		return
+	}
+	klog.Infof("cleaning the %s %s/%s", existing.GetKind(), namespace, name)
+	err = configs.Delete(ctx, name, DeleteOptions(propagation, ""))
+	if err != nil && !apierrors.IsNotFound(err) {
+		klog.Errorf("error when deleting %s %s/%s: %s", existing.GetKind(), namespace, name, err)
+	}
+}
+
+// applyGKEConfigs generates the FrontendConfig of the ingress and the BackendConfig of the service
+// the clone of the service gets the annotation linking the BackendConfig, or loses it
+func (s *IngressStrategy) applyGKEConfigs(ingress *networkingv1.Ingress, svc, clone *v1.Service, https bool) error {
+	err := applyGeneratedObject(s.ctx, s.dynamicClient, FrontendConfigResource, buildFrontendConfig(ingress, https))
+	if err != nil {
+		return err
+	}
+	backendConfig, err := buildBackendConfig(ingress, svc)
+	if err != nil {
+		return err
+	}
+	if backendConfig == nil {
+		deleteGeneratedObject(s.ctx, s.dynamicClient, BackendConfigResource, ingress.Namespace, ingress.Name, s.neverDelete, s.provider, s.deletePropagation)
+		removeBackendConfigAnnotation(clone, ingress.Name)
+		return nil
+	}
+	err = applyGeneratedObject(s.ctx, s.dynamicClient, BackendConfigResource, backendConfig)
+	if err != nil {
+		return err
+	}
+	// a backend config set by the user is kept
+	if value, ok := clone.Annotations[backendConfigAnnotationKey]; ok && value != backendConfigAnnotation(ingress.Name) {
+		klog.Warningf("service %s/%s already has annotation \"%s\", the generated BackendConfig is not used",
+			svc.Namespace, svc.Name, backendConfigAnnotationKey)
+		return nil
+	}
+	if clone.Annotations == nil {
+		clone.Annotations = map[string]string{}
+	}
+	clone.Annotations[backendConfigAnnotationKey] = backendConfigAnnotation(ingress.Name)
+	return nil
+}
+
+// cleanGKEConfigs deletes the GKE configs generated for the ingress
+func (s *IngressStrategy) cleanGKEConfigs(namespace, name string) {
+	if !s.gkeConfigs {
+		return
+	}
+	deleteGeneratedObject(s.ctx, s.dynamicClient, FrontendConfigResource, namespace, name, s.neverDelete, s.provider, s.deletePropagation)
+	deleteGeneratedObject(s.ctx, s.dynamicClient, BackendConfigResource, namespace, name, s.neverDelete, s.provider, s.deletePropagation)
+}
+
+// removeBackendConfigAnnotation removes the annotation linking the generated BackendConfig
+func removeBackendConfigAnnotation(svc *v1.Service, name string) bool {
+	if svc.Annotations[backendConfigAnnotationKey] != backendConfigAnnotation(name) {
+		return false
+	}
+	delete(svc.Annotations, backendConfigAnnotationKey)
+	return true
+}
@@ -0,0 +1,121 @@
+package exposestrategy
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_GKEConfigs(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			UID:       "svc-uid",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key:          ExposeAnnotation.Value,
+				GKEBackendConfigAnnotationKey: "cdn:\n  enabled: true\ntimeoutSec: 40\n",
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 80}},
+		},
+	}
+	client := fake.NewSimpleClientset(svc)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			HTTPRouteResource:      "HTTPRouteList",
+			FrontendConfigResource: "FrontendConfigList",
+			BackendConfigResource:  "BackendConfigList",
+		})
+
+	_, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:    "ingress",
+		Namespace:  "main",
+		Domain:     "my-domain.com",
+		GKEConfigs: true,
+	})
+	assert.Error(t, err, "missing dynamic client")
+
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:       "ingress",
+		Namespace:     "main",
+		Domain:        "my-domain.com",
+		TLSSecretName: "tls",
+		GKEConfigs:    true,
+		DynamicClient: dynamicClient,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(svc))
+
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(nil, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "svc", ingress.Annotations["networking.gke.io/v1beta1.FrontendConfig"])
+	frontendConfig, err := dynamicClient.Resource(FrontendConfigResource).Namespace("main").Get(nil, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"redirectToHttps": map[string]interface{}{"enabled": true},
+	}, frontendConfig.Object["spec"])
+	assert.Equal(t, ingress.OwnerReferences, frontendConfig.GetOwnerReferences())
+	backendConfig, err := dynamicClient.Resource(BackendConfigResource).Namespace("main").Get(nil, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"cdn":        map[string]interface{}{"enabled": true},
+		"timeoutSec": int64(40),
+	}, backendConfig.Object["spec"])
+
+	svc, err = client.CoreV1().Services("main").Get(nil, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, `{"default":"svc"}`, svc.Annotations["cloud.google.com/backend-config"])
+	assert.Equal(t, "https://svc.main.my-domain.com", svc.Annotations[ExposeAnnotationKey])
+
+	// the BackendConfig goes with the annotation
+	delete(svc.Annotations, GKEBackendConfigAnnotationKey)
+	require.NoError(t, strategy.Add(svc))
+	_, err = dynamicClient.Resource(BackendConfigResource).Namespace("main").Get(nil, "svc", metav1.GetOptions{})
+	assert.Error(t, err)
+	svc, err = client.CoreV1().Services("main").Get(nil, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, svc.Annotations, "cloud.google.com/backend-config")
+
+	svc.Annotations[GKEBackendConfigAnnotationKey] = "cdn: {enabled: true}"
+	require.NoError(t, strategy.Add(svc))
+	svc, err = client.CoreV1().Services("main").Get(nil, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Clean(svc))
+	_, err = dynamicClient.Resource(FrontendConfigResource).Namespace("main").Get(nil, "svc", metav1.GetOptions{})
+	assert.Error(t, err)
+	_, err = dynamicClient.Resource(BackendConfigResource).Namespace("main").Get(nil, "svc", metav1.GetOptions{})
+	assert.Error(t, err)
+	svc, err = client.CoreV1().Services("main").Get(nil, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, svc.Annotations, "cloud.google.com/backend-config")
+	assert.NotContains(t, svc.Annotations, ExposeAnnotationKey)
+}
+
+func TestBuildBackendConfig(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				GKEBackendConfigAnnotationKey: "- cdn",
+			},
+		},
+	}
+	_, err := buildBackendConfig(nil, svc)
+	assert.Error(t, err, "not an object")
+	svc.Annotations[GKEBackendConfigAnnotationKey] = "cdn: {"
+	_, err = buildBackendConfig(nil, svc)
+	assert.Error(t, err, "invalid YAML")
+}
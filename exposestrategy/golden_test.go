@@ -0,0 +1,146 @@
+package exposestrategy
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// update regenerates the golden files, go test ./exposestrategy -run Golden -update
+var update = flag.Bool("update", false, "update the golden files under testdata")
+
+// marshalGolden serializes the objects to YAML documents, with the keys of the objects sorted
+func marshalGolden(objects ...interface{}) ([]byte, error) {
+	var docs []string
+	for _, obj := range objects {
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		// JSON is YAML, the generic value drops the empty fields omitted by the JSON tags
+		var generic interface{}
+		err = yaml.Unmarshal(data, &generic)
+		if err != nil {
+			return nil, err
+		}
+		data, err = yaml.Marshal(generic)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, string(data))
+	}
+	return []byte(strings.Join(docs, "---\n")), nil
+}
+
+// assertGolden compares the objects to the golden file testdata/<name>.yaml, written with -update
+func assertGolden(t *testing.T, name string, objects ...interface{}) {
+	t.Helper()
+	actual, err := marshalGolden(objects...)
+	require.NoError(t, err)
+	path := filepath.Join("testdata", name+".yaml")
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, actual, 0644))
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "run the tests with -update to create the golden file")
+	assert.Equal(t, string(expected), string(actual), "run the tests with -update to accept the changes of %s", path)
+}
+
+// TestIngressStrategy_Golden exposes a service per case and compares the generated ingress and the exposed service to their golden file
+func TestIngressStrategy_Golden(t *testing.T) {
+	cases := []struct {
+		name        string
+		config      Config
+		annotations map[string]string
+		ports       []v1.ServicePort
+	}{{
+		name: "default",
+	}, {
+		name:   "http",
+		config: Config{HTTP: true, TLSSecretName: "main-tls"},
+	}, {
+		name:   "tls-acme",
+		config: Config{TLSAcme: true},
+	}, {
+		name:        "tls-acme-opt-out",
+		config:      Config{TLSAcme: true},
+		annotations: map[string]string{TLSAcmeAnnotationKey: "false", TLSSecretAnnotationKey: "svc-tls"},
+	}, {
+		name:   "tls-wildcard",
+		config: Config{TLSSecretName: "main-tls", TLSUseWildcard: true},
+	}, {
+		name:        "path-mode",
+		annotations: map[string]string{"fabric8.io/path.mode": "path", "fabric8.io/ingress.path": "api"},
+	}, {
+		name:        "internal-domain-both",
+		config:      Config{InternalDomain: "internal.lan", TLSSecretName: "main-tls", InternalDomainScheme: "http"},
+		annotations: map[string]string{"fabric8.io/use.internal.domain": "both"},
+	}, {
+		name:        "additional-hosts",
+		config:      Config{TLSSecretName: "main-tls"},
+		annotations: map[string]string{AdditionalHostsAnnotationKey: "svc.partner.com=partner-tls,svc.other.com"},
+	}, {
+		name: "ingress-annotations-and-labels",
+		config: Config{
+			IngressLabels: map[string]string{"team": "platform"},
+		},
+		annotations: map[string]string{
+			IngressAnnotationsAnnotationKey: "nginx.ingress.kubernetes.io/proxy-body-size: 8m",
+			IngressLabelsAnnotationKey:      "tier: frontend",
+		},
+	}, {
+		name:        "expose-port",
+		annotations: map[string]string{ExposePortAnnotationKey: "http"},
+		ports:       []v1.ServicePort{{Name: "metrics", Port: 9090}, {Name: "http", Port: 8080}},
+	}}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:       "main",
+					Name:            "svc",
+					Annotations:     c.annotations,
+					ResourceVersion: "1",
+				},
+				Spec: v1.ServiceSpec{
+					Ports: c.ports,
+				},
+			}
+			if len(service.Spec.Ports) == 0 {
+				service.Spec.Ports = []v1.ServicePort{{Port: 8080}}
+			}
+			client := fake.NewSimpleClientset(service)
+			config := c.config
+			config.Exposer = "ingress"
+			config.Namespace = "main"
+			config.Domain = "my-domain.com"
+			strategy, err := NewIngressStrategy(nil, client, &config)
+			require.NoError(t, err)
+			require.NoError(t, strategy.Sync())
+			require.NoError(t, strategy.Add(service))
+
+			ctx := context.Background()
+			ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+			require.NoError(t, err)
+			service, err = client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+			require.NoError(t, err)
+			assertGolden(t, filepath.Join("ingress", c.name), ingress, service)
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package exposestrategy
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// ExposeGroupAnnotationKey annotation gathers the services of a namespace on one host named after the group, such as "myapp",
+	// with a path by service on a single ingress, the "fabric8.io/ingress.path" of the service or "/<service>" by default
+	ExposeGroupAnnotationKey = "fabric8.io/expose.group"
+	// ExposeGroupURLsAnnotationKey annotation is set on the members of a group with the URLs of all the members in JSON, by service
+	ExposeGroupURLsAnnotationKey = "fabric8.io/exposeGroupUrls"
+	// ExposedGroupLabelKey label holds the group of the ingress gathering the services of a group
+	ExposedGroupLabelKey = "fabric8.io/exposed-group"
+)
+
+// groupIngressKeys are the annotations of the services shaping the labels and annotations of the ingress,
+// the members of a group share one ingress and must agree on them
+var groupIngressKeys = []string{
+	IngressAnnotationsAnnotationKey,
+	IngressAnnotationsFromAnnotationKey,
+	IngressLabelsAnnotationKey,
+	AllowHTTPAnnotationKey,
+	BackendTLSAnnotationKey,
+	BackendCASecretAnnotationKey,
+	HTTP3AnnotationKey,
+	TLSAcmeAnnotationKey,
+}
+
+// parseExposeGroup returns the group of the service, empty without group
+func parseExposeGroup(svc *v1.Service) (string, error) {
+	group := svc.Annotations[ExposeGroupAnnotationKey]
+	if group == "" {
+		return "", nil
+	}
+	if errs := validation.IsDNS1123Label(group); len(errs) > 0 {
+		return "", newAnnotationParseError(svc, ExposeGroupAnnotationKey, group,
+			errors.Errorf("invalid group \"%s\": %s", group, strings.Join(errs, ", ")))
+	}
+	return group, nil
+}
+
+// groupMemberPath returns the path of the member on the host of its group
+func groupMemberPath(svc *v1.Service) string {
+	if path := normalizeURLPath(svc.Annotations["fabric8.io/ingress.path"]); path != "" {
+		return path
+	}
+	return "/" + svc.Name
+}
+
+// groupMembers returns the exposed services of the group of the service, the oldest first, the service included
+func (s *IngressStrategy) groupMembers(svc *v1.Service, group string) ([]*v1.Service, error) {
+	services, err := s.listServices(svc.Namespace)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the services of namespace %s to find the members of group %s", svc.Namespace, group)
+	}
+	members := []*v1.Service{svc}
+	for _, other := range services {
+		if other.Name != svc.Name && other.Annotations[ExposeGroupAnnotationKey] == group && s.exposeValues.IsRequested(other) {
+			members = append(members, other)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return isOlderService(members[i], members[j])
+	})
+	return members, nil
+}
+
+// checkGroupPath returns an ErrConflict error if an older member of the group claims the path of the service
+func checkGroupPath(svc *v1.Service, group string, members []*v1.Service) error {
+	path := groupMemberPath(svc)
+	for _, other := range members {
+		if other.Name == svc.Name {
+			return nil
+		}
+		if groupMemberPath(other) == path {
+			return newServiceError(svc, ErrConflict, "path %s of group %s of service %s/%s is already claimed by service %s",
+				path, group, svc.Namespace, svc.Name, other.Name)
+		}
+	}
+	return nil
+}
+
+// checkGroupAnnotations returns an ErrConflict error if the service asks for other ingress annotations or labels
+// than the oldest member of the group, whose annotations and labels are those of the ingress
+func (s *IngressStrategy) checkGroupAnnotations(svc *v1.Service, group string, members []*v1.Service) error {
+	oldest := members[0]
+	if oldest.Name == svc.Name {
+		return nil
+	}
+	values, oldestValues := s.groupIngressValues(svc), s.groupIngressValues(oldest)
+	for key, value := range values {
+		if oldestValues[key] != value {
+			return newServiceError(svc, ErrConflict, "annotation \"%s\" of service %s/%s differs from the one of service %s, the oldest member of group %s",
+				key, svc.Namespace, svc.Name, oldest.Name, group)
+		}
+	}
+	for key := range oldestValues {
+		if _, ok := values[key]; !ok {
+			return newServiceError(svc, ErrConflict, "annotation \"%s\" of service %s is missing on service %s/%s of group %s",
+				key, oldest.Name, svc.Namespace, svc.Name, group)
+		}
+	}
+	return nil
+}
+
+// groupIngressValues returns the annotations of the service shaping its ingress, the propagated ones included
+func (s *IngressStrategy) groupIngressValues(svc *v1.Service) map[string]string {
+	values := map[string]string{}
+	for _, key := range groupIngressKeys {
+		if value, ok := svc.Annotations[key]; ok {
+			values[key] = value
+		}
+	}
+	s.annotationPropagation.copy(svc, values)
+	return values
+}
+
+// isGroupIngress tells if the ingress gathers the services of a group
+func isGroupIngress(ingress *networkingv1.Ingress) bool {
+	return ingress.Annotations[ExposeGroupAnnotationKey] != ""
+}
+
+// isGroupMemberPath tells if the path of the ingress routes to the service
+func isGroupMemberPath(path networkingv1.HTTPIngressPath, name string) bool {
+	return path.Backend.Service != nil && path.Backend.Service.Name == name
+}
+
+// mergeGroupIngress adds the paths and owner references of the other members to the ingress of the service,
+// from the existing ingress of the group, those of the services which left the group are dropped,
+// the labels, annotations and expose generation of the ingress are those of the oldest member whichever member is exposed
+func (s *IngressStrategy) mergeGroupIngress(svc *v1.Service, ingress *networkingv1.Ingress, group string, members []*v1.Service) error {
+	oldest := members[0]
+	existing, err := s.client.NetworkingV1().Ingresses(ingress.Namespace).Get(s.ctx, ingress.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "could not check for existing ingress %s/%s", ingress.Namespace, ingress.Name)
+	}
+	if err == nil && !isGroupIngress(existing) {
+		existing = nil
+	}
+	// the other members keep the labels and annotations computed for the oldest one
+	if existing != nil && oldest.Name != svc.Name {
+		ingress.Labels = map[string]string{}
+		for key, value := range existing.Labels {
+			ingress.Labels[key] = value
+		}
+		ingress.Annotations = map[string]string{}
+		for key, value := range existing.Annotations {
+			ingress.Annotations[key] = value
+		}
+	}
+	if ingress.Labels == nil {
+		ingress.Labels = map[string]string{}
+	}
+	ingress.Labels[ExposedGroupLabelKey] = group
+	if _, ok := ingress.Labels[ExposedServiceLabelKey]; ok {
+		ingress.Labels[ExposedServiceLabelKey] = oldest.Name
+	}
+	ingress.Annotations[ExposeGroupAnnotationKey] = group
+	if generation := oldest.Annotations[ExposeGenerationAnnotationKey]; generation != "" {
+		ingress.Annotations[ExposeGenerationAnnotationKey] = generation
+	} else {
+		delete(ingress.Annotations, ExposeGenerationAnnotationKey)
+	}
+	if existing == nil {
+		return nil
+	}
+	others := map[string]bool{}
+	for _, member := range members {
+		if member.Name != svc.Name {
+			others[member.Name] = true
+		}
+	}
+	for i := range ingress.Spec.Rules {
+		rule := &ingress.Spec.Rules[i]
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, existingRule := range existing.Spec.Rules {
+			if existingRule.Host != rule.Host || existingRule.HTTP == nil {
+				continue
+			}
+			for _, path := range existingRule.HTTP.Paths {
+				if path.Backend.Service == nil || !others[path.Backend.Service.Name] || hasIngressPath(rule.HTTP.Paths, path) {
+					continue
+				}
+				rule.HTTP.Paths = append(rule.HTTP.Paths, path)
+			}
+		}
+	}
+	for _, owner := range existing.OwnerReferences {
+		if owner.Kind == ServiceKind && others[owner.Name] {
+			ingress.OwnerReferences = append(ingress.OwnerReferences, owner)
+		}
+	}
+	sort.SliceStable(ingress.OwnerReferences, func(i, j int) bool {
+		return ingress.OwnerReferences[i].Name < ingress.OwnerReferences[j].Name
+	})
+	// a single owner can be the controller, the members own the ingress alike
+	if len(ingress.OwnerReferences) > 1 {
+		for i := range ingress.OwnerReferences {
+			ingress.OwnerReferences[i].Controller = nil
+		}
+	}
+	sortIngressSpec(&ingress.Spec)
+	return nil
+}
+
+// hasIngressPath tells if the paths already route the path of the same type
+func hasIngressPath(paths []networkingv1.HTTPIngressPath, path networkingv1.HTTPIngressPath) bool {
+	for _, p := range paths {
+		if p.Path == path.Path && (p.PathType == nil) == (path.PathType == nil) && (p.PathType == nil || *p.PathType == *path.PathType) {
+			return true
+		}
+	}
+	return false
+}
+
+// leaveGroupIngress removes the paths and owner reference of the service from the ingress of its group,
+// the ingress is deleted with its last member, and the URLs of the group are published again on the other members
+func (s *IngressStrategy) leaveGroupIngress(ingress *networkingv1.Ingress, svc *v1.Service) {
+	if isUnmanagedIngress(ingress, "updating") {
+		return
+	}
+	updated := ingress.DeepCopy()
+	remaining := map[string]bool{}
+	for i := range updated.Spec.Rules {
+		rule := &updated.Spec.Rules[i]
+		if rule.HTTP == nil {
+			continue
+		}
+		paths := rule.HTTP.Paths[:0]
+		for _, path := range rule.HTTP.Paths {
+			if !isGroupMemberPath(path, svc.Name) {
+				paths = append(paths, path)
+			}
+			if path.Backend.Service != nil && path.Backend.Service.Name != svc.Name {
+				remaining[path.Backend.Service.Name] = true
+			}
+		}
+		rule.HTTP.Paths = paths
+	}
+	if len(remaining) == 0 {
+		deleteIngress(s.ctx, s.client, ingress, s.neverDelete, s.provider, s.deletePropagation)
+		return
+	}
+	owners := updated.OwnerReferences[:0]
+	for _, owner := range updated.OwnerReferences {
+		if owner.Kind != ServiceKind || owner.Name != svc.Name {
+			owners = append(owners, owner)
+		}
+	}
+	updated.OwnerReferences = owners
+	members, err := s.groupMembers(svc, ingress.Annotations[ExposeGroupAnnotationKey])
+	if err != nil {
+		klog.Errorf("%s", err)
+		return
+	}
+	others := make([]*v1.Service, 0, len(members))
+	for _, member := range members {
+		if member.Name != svc.Name && remaining[member.Name] {
+			others = append(others, member)
+		}
+	}
+	// the oldest remaining member holds the ingress, its labels and annotations are written on its next exposure
+	if updated.Labels[ExposedServiceLabelKey] == svc.Name && len(others) > 0 {
+		updated.Labels[ExposedServiceLabelKey] = others[0].Name
+	}
+	_, err = s.client.NetworkingV1().Ingresses(updated.Namespace).Update(s.ctx, updated, metav1.UpdateOptions{})
+	if err != nil {
+		klog.Errorf("failed to remove service %s/%s from ingress %s/%s of group %s: %s",
+			svc.Namespace, svc.Name, updated.Namespace, updated.Name, ingress.Annotations[ExposeGroupAnnotationKey], err)
+		return
+	}
+	s.publishGroupURLs(nil, others)
+}
+
+// publishGroupURLs sets the URLs of the members on each of them, the published URL of each member or the one of the clone
+// of the exposed service, the clone is updated in place and the other exposed members are patched
+func (s *IngressStrategy) publishGroupURLs(clone *v1.Service, members []*v1.Service) {
+	urls := map[string]string{}
+	for _, member := range members {
+		url := member.Annotations[ExposeAnnotationKey]
+		if clone != nil && member.Name == clone.Name {
+			url = clone.Annotations[ExposeAnnotationKey]
+		}
+		if url != "" {
+			urls[member.Name] = url
+		}
+	}
+	value := ""
+	if len(urls) > 0 {
+		// the keys of the maps are sorted by the encoding
+		data, err := json.Marshal(urls)
+		if err != nil {
+			klog.Errorf("failed to encode the URLs of the group of service %s/%s: %s", members[0].Namespace, members[0].Name, err)
+			return
+		}
+		value = string(data)
+	}
+	for _, member := range members {
+		if clone != nil && member.Name == clone.Name {
+			setGroupURLs(clone, value)
+			continue
+		}
+		if _, ok := urls[member.Name]; !ok {
+			// not exposed yet, or not on the group ingress, such as with a conflicting path
+			continue
+		}
+		updated := member.DeepCopy()
+		setGroupURLs(updated, value)
+		patch, err := createServicePatch(member, updated)
+		if err != nil {
+			klog.Errorf("failed to create patch for service %s/%s: %s", member.Namespace, member.Name, err)
+			continue
+		}
+		if patch == nil {
+			continue
+		}
+		_, err = s.client.CoreV1().Services(member.Namespace).Patch(s.ctx, member.Name, patchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			klog.Errorf("failed to send patch %s/%s: %s", member.Namespace, member.Name, err)
+		}
+	}
+}
+
+// setGroupURLs sets the URLs of the group of the service, removed if empty
+func setGroupURLs(svc *v1.Service, value string) {
+	if value == "" {
+		delete(svc.Annotations, ExposeGroupURLsAnnotationKey)
+		return
+	}
+	if svc.Annotations == nil {
+		svc.Annotations = map[string]string{}
+	}
+	svc.Annotations[ExposeGroupURLsAnnotationKey] = value
+}
@@ -0,0 +1,206 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_Group(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	newService := func(name, path string, age int) *v1.Service {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "main",
+				Name:              name,
+				UID:               types.UID("uid-" + name),
+				CreationTimestamp: metav1.NewTime(created.Add(time.Duration(age) * time.Hour)),
+				Annotations: map[string]string{
+					ExposeAnnotation.Key:     ExposeAnnotation.Value,
+					ExposeGroupAnnotationKey: "myapp",
+				},
+				ResourceVersion: "1",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{Port: 8080}},
+			},
+		}
+		if path != "" {
+			svc.Annotations["fabric8.io/ingress.path"] = path
+		}
+		return svc
+	}
+	client := fake.NewSimpleClientset(
+		newService("frontend", "/", 0),
+		newService("api", "", 1),
+		newService("auth", "/auth", 2),
+		newService("api-v2", "/api", 3),
+	)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:   "ingress",
+		Namespace: "main",
+		Domain:    "my-domain.com",
+		HTTP:      true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	get := func(name string) *v1.Service {
+		svc, err := client.CoreV1().Services("main").Get(ctx, name, metav1.GetOptions{})
+		require.NoError(t, err)
+		return svc
+	}
+
+	for _, name := range []string{"frontend", "api", "auth"} {
+		require.NoError(t, strategy.Add(get(name)), name)
+		setIngressVersions(t, client)
+	}
+	// the older member keeps the path
+	err = strategy.Add(get("api-v2"))
+	assert.True(t, errors.Is(err, ErrConflict))
+	assert.False(t, Retryable(err))
+
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "myapp", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "myapp", ingress.Annotations[ExposeGroupAnnotationKey])
+	require.Len(t, ingress.Spec.Rules, 1)
+	assert.Equal(t, "myapp.main.my-domain.com", ingress.Spec.Rules[0].Host)
+	backends := map[string]string{}
+	for _, path := range ingress.Spec.Rules[0].HTTP.Paths {
+		backends[path.Path] = path.Backend.Service.Name
+	}
+	assert.Equal(t, map[string]string{"/": "frontend", "/api": "api", "/auth": "auth"}, backends)
+	owners := []string{}
+	for _, owner := range ingress.OwnerReferences {
+		owners = append(owners, owner.Name)
+	}
+	assert.Equal(t, []string{"api", "auth", "frontend"}, owners)
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "api", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "no ingress by member")
+
+	assert.Equal(t, "http://myapp.main.my-domain.com/api", get("api").Annotations[ExposeAnnotationKey])
+	all := `{"api":"http://myapp.main.my-domain.com/api","auth":"http://myapp.main.my-domain.com/auth","frontend":"http://myapp.main.my-domain.com/"}`
+	for _, name := range []string{"frontend", "api", "auth"} {
+		assert.Equal(t, all, get(name).Annotations[ExposeGroupURLsAnnotationKey], name)
+	}
+	assert.Empty(t, get("api-v2").Annotations[ExposeGroupURLsAnnotationKey])
+
+	// the ingress and the URLs of the group are updated when a member leaves it
+	require.NoError(t, strategy.Clean(get("api")))
+	ingress, err = client.NetworkingV1().Ingresses("main").Get(ctx, "myapp", metav1.GetOptions{})
+	require.NoError(t, err)
+	paths := []string{}
+	for _, path := range ingress.Spec.Rules[0].HTTP.Paths {
+		paths = append(paths, path.Path)
+	}
+	assert.Equal(t, []string{"/", "/auth"}, paths)
+	assert.Len(t, ingress.OwnerReferences, 2)
+	assert.Empty(t, get("api").Annotations[ExposeGroupURLsAnnotationKey])
+	assert.Equal(t, `{"auth":"http://myapp.main.my-domain.com/auth","frontend":"http://myapp.main.my-domain.com/"}`,
+		get("frontend").Annotations[ExposeGroupURLsAnnotationKey])
+
+	// the ingress is deleted with its last member
+	require.NoError(t, strategy.Delete(get("frontend")))
+	require.NoError(t, strategy.Delete(get("auth")))
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "myapp", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "group ingress deleted")
+
+	key, del := getIngressService(&networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "main",
+			Labels:          map[string]string{"provider": "fabric8"},
+			Annotations:     map[string]string{GeneratedByAnnotationKey: "exposecontroller", ExposeGroupAnnotationKey: "myapp"},
+			OwnerReferences: []metav1.OwnerReference{{Kind: ServiceKind, APIVersion: ServiceAPIVersion, Name: "api"}, {Kind: ServiceKind, APIVersion: ServiceAPIVersion, Name: "auth"}},
+		},
+	}, ProviderLabel{Key: "provider", Value: "fabric8"})
+	assert.Equal(t, "main/api", key)
+	assert.False(t, del, "the group ingress belongs to its members")
+}
+
+func TestIngressStrategy_GroupReAdd(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	newService := func(name, generation, annotations string, age int) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "main",
+				Name:              name,
+				UID:               types.UID("uid-" + name),
+				CreationTimestamp: metav1.NewTime(created.Add(time.Duration(age) * time.Hour)),
+				Annotations: map[string]string{
+					ExposeAnnotation.Key:            ExposeAnnotation.Value,
+					ExposeGroupAnnotationKey:        "myapp",
+					ExposeGenerationAnnotationKey:   generation,
+					IngressAnnotationsAnnotationKey: annotations,
+				},
+				ResourceVersion: "1",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{Port: 8080}},
+			},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		newService("frontend", "2", "nginx.ingress.kubernetes.io/proxy-body-size: 8m", 0),
+		newService("api", "1", "nginx.ingress.kubernetes.io/proxy-body-size: 8m", 1),
+		newService("auth", "3", "nginx.ingress.kubernetes.io/proxy-body-size: 8m", 2),
+		newService("rewrite", "2", "nginx.ingress.kubernetes.io/rewrite-target: /", 3),
+	)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:             "ingress",
+		Namespace:           "main",
+		Domain:              "my-domain.com",
+		HTTP:                true,
+		SkipOwnerReferences: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	get := func(name string) *v1.Service {
+		svc, err := client.CoreV1().Services("main").Get(ctx, name, metav1.GetOptions{})
+		require.NoError(t, err)
+		return svc
+	}
+	getIngress := func() *networkingv1.Ingress {
+		ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "myapp", metav1.GetOptions{})
+		require.NoError(t, err)
+		return ingress
+	}
+
+	// a member cannot set the annotations of the ingress for the paths of the others
+	err = strategy.Add(get("rewrite"))
+	assert.True(t, errors.Is(err, ErrConflict), "%v", err)
+	assert.False(t, Retryable(err))
+	for _, name := range []string{"api", "auth", "frontend"} {
+		require.NoError(t, strategy.Add(get(name)), name)
+		setIngressVersions(t, client)
+	}
+	ingress := getIngress()
+	assert.Len(t, ingress.Spec.Rules[0].HTTP.Paths, 3)
+	assert.Equal(t, "8m", ingress.Annotations["nginx.ingress.kubernetes.io/proxy-body-size"])
+	assert.NotContains(t, ingress.Annotations, "nginx.ingress.kubernetes.io/rewrite-target")
+	assert.Equal(t, "2", ingress.Annotations[ExposeGenerationAnnotationKey], "generation of the oldest member")
+	assert.Equal(t, "frontend", ingress.Labels[ExposedServiceLabelKey], "oldest member")
+	assert.Equal(t, "myapp", ingress.Labels[ExposedGroupLabelKey])
+
+	// exposing the members again in any order changes nothing, their generations differ
+	client.ClearActions()
+	for _, name := range []string{"api", "frontend", "auth", "api"} {
+		require.NoError(t, strategy.Add(get(name)), name)
+	}
+	for _, action := range client.Actions() {
+		assert.False(t, action.GetResource().Resource == "ingresses" && action.GetVerb() != "get" && action.GetVerb() != "list",
+			"%s %s", action.GetVerb(), action.GetResource().Resource)
+	}
+	assert.Equal(t, ingress, getIngress())
+}
@@ -0,0 +1,57 @@
+package exposestrategy
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// HealthCheckPathAnnotationKey annotation is the probe path of the service routed on its hosts for the external uptime checks,
+// the health check path of the config if empty, "false" to route none
+const HealthCheckPathAnnotationKey = "fabric8.io/health-check.path"
+
+// healthCheckPath returns the probe path routed on the hosts of the service, empty if none
+func (s *IngressStrategy) healthCheckPath(svc *v1.Service) (string, error) {
+	path := svc.Annotations[HealthCheckPathAnnotationKey]
+	if path == "" {
+		return s.healthCheckPathDefault, nil
+	}
+	if path == "false" {
+		return "", nil
+	}
+	if !isHealthCheckPath(path) {
+		return "", newAnnotationParseError(svc, HealthCheckPathAnnotationKey, path,
+			errors.Errorf("invalid path \"%s\", must be an absolute path", path))
+	}
+	return path, nil
+}
+
+// addHealthCheckPath routes the exact probe path of every rule to the backend,
+// the ingress controller forwards it as is, the service answering its own probe
+func addHealthCheckPath(rules []networkingv1.IngressRule, path string, backend networkingv1.IngressBackend) {
+	exact := networkingv1.PathTypeExact
+	for _, rule := range rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		routed := false
+		for _, p := range rule.HTTP.Paths {
+			routed = routed || p.Path == path && p.PathType != nil && *p.PathType == exact
+		}
+		if !routed {
+			rule.HTTP.Paths = append(rule.HTTP.Paths, networkingv1.HTTPIngressPath{
+				Path:     path,
+				PathType: &exact,
+				Backend:  backend,
+			})
+		}
+	}
+}
+
+// isHealthCheckPath tells if the path can be the exact path of an ingress rule
+func isHealthCheckPath(path string) bool {
+	return strings.HasPrefix(path, "/") && !strings.ContainsAny(path, " ?#")
+}
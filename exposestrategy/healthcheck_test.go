@@ -0,0 +1,76 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_HealthCheckPath(t *testing.T) {
+	newService := func(name, healthPath string) *v1.Service {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "main",
+				Name:        name,
+				Annotations: map[string]string{"fabric8.io/ingress.path": "/api"},
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{Port: 8080}},
+			},
+		}
+		if healthPath != "" {
+			svc.Annotations[HealthCheckPathAnnotationKey] = healthPath
+		}
+		return svc
+	}
+	ctx := context.Background()
+	for _, test := range []struct {
+		annotation string
+		expected   []string
+	}{
+		{annotation: "", expected: []string{"/api", "/healthz"}},
+		{annotation: "/actuator/health", expected: []string{"/actuator/health", "/api"}},
+		{annotation: "false", expected: []string{"/api"}},
+	} {
+		t.Run(test.annotation, func(t *testing.T) {
+			svc := newService("svc", test.annotation)
+			client := fake.NewSimpleClientset(svc)
+			strategy, err := NewIngressStrategy(nil, client, &Config{
+				Exposer:         "ingress",
+				Namespace:       "main",
+				Domain:          "my-domain.com",
+				HealthCheckPath: "/healthz",
+			})
+			require.NoError(t, err)
+			require.NoError(t, strategy.Sync())
+			require.NoError(t, strategy.Add(svc))
+			ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Len(t, ingress.Spec.Rules, 1)
+			var paths []string
+			for _, path := range ingress.Spec.Rules[0].HTTP.Paths {
+				paths = append(paths, path.Path)
+				assert.Equal(t, "svc", path.Backend.Service.Name)
+				if path.Path != "/api" {
+					assert.Equal(t, networkingv1.PathTypeExact, *path.PathType)
+				}
+			}
+			assert.Equal(t, test.expected, paths)
+		})
+	}
+
+	client := fake.NewSimpleClientset()
+	_, err := NewIngressStrategy(nil, client, &Config{Exposer: "ingress", Namespace: "main", Domain: "my-domain.com", HealthCheckPath: "healthz"})
+	assert.EqualError(t, err, "invalid health check path \"healthz\", must be an absolute path")
+	strategy, err := NewIngressStrategy(nil, client, &Config{Exposer: "ingress", Namespace: "main", Domain: "my-domain.com"})
+	require.NoError(t, err)
+	_, err = strategy.(*IngressStrategy).healthCheckPath(newService("svc", "health?check"))
+	assert.Error(t, err)
+}
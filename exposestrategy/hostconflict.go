@@ -0,0 +1,194 @@
+package exposestrategy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// HostConflictSkip does not generate the ingress of the service, only its URL annotation, when another ingress claims its host
+	HostConflictSkip = "skip"
+	// HostConflictAdopt takes over the other ingress claiming the host of the service
+	HostConflictAdopt = "adopt"
+	// HostConflictError does not expose the service, with a HostConflict event
+	HostConflictError = "error"
+)
+
+// ExposeAdoptedIngressAnnotationKey annotation will be created on the services adopting an ingress with the adopt policy,
+// naming it so that the later exposures keep updating it instead of creating their own
+const ExposeAdoptedIngressAnnotationKey = "fabric8.io/exposeAdoptedIngress"
+
+// parseHostConflictPolicy checks the host conflict policy, empty if the conflicts are not detected
+func parseHostConflictPolicy(value string) (string, error) {
+	switch value {
+	case "", HostConflictSkip, HostConflictAdopt, HostConflictError:
+		return value, nil
+	default:
+		return "", errors.Errorf("invalid host conflict policy \"%s\", must be \"%s\", \"%s\" or \"%s\"",
+			value, HostConflictSkip, HostConflictAdopt, HostConflictError)
+	}
+}
+
+// findHostConflict returns the first ingress not generated by the controller claiming a host of the ingress, nil if none
+// the ingresses of the namespace of the ingress are read from the shared cache, or listed by page
+func (s *IngressStrategy) findHostConflict(ingress *networkingv1.Ingress) (*networkingv1.Ingress, error) {
+	hosts := map[string]bool{}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host != "" {
+			hosts[rule.Host] = true
+		}
+	}
+	if s.ingressLister != nil {
+		list, err := s.ingressLister.Ingresses(ingress.Namespace).List(labels.Everything())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list the ingresses of namespace %s", ingress.Namespace)
+		}
+		for _, other := range list {
+			if claimsHost(other, hosts, s.provider) {
+				// the objects of the cache are shared
+				return other.DeepCopy(), nil
+			}
+		}
+		return nil, nil
+	}
+	options := metav1.ListOptions{Limit: s.pageSize}
+	for {
+		list, err := s.client.NetworkingV1().Ingresses(ingress.Namespace).List(s.ctx, options)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list the ingresses of namespace %s", ingress.Namespace)
+		}
+		for index := range list.Items {
+			other := &list.Items[index]
+			if claimsHost(other, hosts, s.provider) {
+				return other, nil
+			}
+		}
+		if list.Continue == "" {
+			return nil, nil
+		}
+		options.Continue = list.Continue
+	}
+}
+
+// claimsHost tells if the ingress not generated by this instance of the controller claims one of the hosts
+func claimsHost(ingress *networkingv1.Ingress, hosts map[string]bool, provider ProviderLabel) bool {
+	if provider.IsGenerated(ingress.Annotations) {
+		return false
+	}
+	for _, rule := range ingress.Spec.Rules {
+		if hosts[rule.Host] {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHostConflict applies the host conflict policy, returning the conflicting ingress to skip or adopt
+func (s *IngressStrategy) checkHostConflict(svc *v1.Service, ingress *networkingv1.Ingress) (*networkingv1.Ingress, error) {
+	if s.hostConflictPolicy == "" {
+		return nil, nil
+	}
+	svcKey := serviceKey(svc.Namespace, svc.Name)
+	conflict, err := s.findHostConflict(ingress)
+	if err != nil || conflict == nil {
+		delete(s.hostConflicts, svcKey)
+		return nil, err
+	}
+	message := fmt.Sprintf("The hosts of the service are already claimed by ingress %s/%s", conflict.Namespace, conflict.Name)
+	// the event is emitted once by conflicting ingress
+	reported := s.hostConflicts[svcKey] == conflict.Name
+	s.hostConflicts[svcKey] = conflict.Name
+	switch s.hostConflictPolicy {
+	case HostConflictError:
+		if !reported {
+			EmitServiceEvent(s.ctx, s.client, svc, v1.EventTypeWarning, "HostConflict", message)
+		}
+		return nil, newServiceError(svc, ErrConflict, "the hosts of service %s/%s are already claimed by ingress %s/%s",
+			svc.Namespace, svc.Name, conflict.Namespace, conflict.Name)
+	case HostConflictAdopt:
+		klog.Infof("adopting the ingress %s/%s claiming the hosts of service %s/%s",
+			conflict.Namespace, conflict.Name, svc.Namespace, svc.Name)
+		if !reported {
+			EmitServiceEvent(s.ctx, s.client, svc, v1.EventTypeNormal, "IngressAdopted",
+				fmt.Sprintf("Adopted ingress %s/%s claiming the hosts of the service", conflict.Namespace, conflict.Name))
+		}
+	default:
+		klog.Infof("the ingress %s/%s already claims the hosts of service %s/%s, only the url is published",
+			conflict.Namespace, conflict.Name, svc.Namespace, svc.Name)
+	}
+	return conflict, nil
+}
+
+// adoptedIngressName returns the name of the ingress adopted before by the service, empty if none
+// the adopted ingress is generated by the controller once adopted, so not found as a conflict anymore
+func (s *IngressStrategy) adoptedIngressName(svc *v1.Service) string {
+	if s.hostConflictPolicy != HostConflictAdopt {
+		return ""
+	}
+	return svc.Annotations[ExposeAdoptedIngressAnnotationKey]
+}
+
+// setAdoptedIngress records the ingress adopted by the service, removed if empty
+func setAdoptedIngress(svc *v1.Service, name string) {
+	if name == "" {
+		delete(svc.Annotations, ExposeAdoptedIngressAnnotationKey)
+		return
+	}
+	if svc.Annotations == nil {
+		svc.Annotations = map[string]string{}
+	}
+	svc.Annotations[ExposeAdoptedIngressAnnotationKey] = name
+}
+
+// skipConflictingIngress publishes the URL of the service served by the conflicting ingress
+// the ingresses generated for the service are deleted
+func (s *IngressStrategy) skipConflictingIngress(svc *v1.Service, conflict *networkingv1.Ingress, hostName, path string) error {
+	s.deleteServiceIngresses(svc)
+	s.cleanHTTPRoutes(svc, "")
+	// the conflicting ingress terminates TLS if it has a certificate for the host
+	https := false
+	for _, tls := range conflict.Spec.TLS {
+		for _, host := range tls.Hosts {
+			if host == hostName || (strings.HasPrefix(host, "*.") && wildcardDomain(hostName) == host[2:]) {
+				https = true
+			}
+		}
+	}
+	clone := svc.DeepCopy()
+	var err error
+	if !s.http && https {
+		err = addServiceAnnotationWithPort(clone, hostName, s.httpsPort, path, "https")
+	} else {
+		err = addServiceAnnotationWithPort(clone, hostName, s.httpPort, path, "http")
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to add annotation to service %s/%s",
+			svc.Namespace, svc.Name)
+	}
+	// only the exposed URL is known from the conflicting ingress
+	delete(clone.Annotations, ExposeURLsAnnotationKey)
+	delete(clone.Annotations, ExposeDomainURLsAnnotationKey)
+	delete(clone.Annotations, ExposeAdoptedIngressAnnotationKey)
+	patch, err := createServicePatch(svc, clone)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create patch for service %s/%s",
+			svc.Namespace, svc.Name)
+	}
+	if patch != nil {
+		_, err = s.client.CoreV1().Services(svc.Namespace).
+			Patch(s.ctx, svc.Name, patchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to send patch %s/%s",
+				svc.Namespace, svc.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_HostConflict(t *testing.T) {
+	newObjects := func() (*v1.Service, *networkingv1.Ingress) {
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "main",
+				Name:            "svc",
+				UID:             "svc-uid",
+				ResourceVersion: "1",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{Port: 8080}},
+			},
+		}
+		chartIngress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "main",
+				Name:      "chart",
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "Helm",
+				},
+				ResourceVersion: "1",
+			},
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{{Host: "svc.main.my-domain.com"}},
+				TLS: []networkingv1.IngressTLS{{
+					Hosts:      []string{"*.main.my-domain.com"},
+					SecretName: "chart-tls",
+				}},
+			},
+		}
+		return service, chartIngress
+	}
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		policy string
+		check  func(*testing.T, *fake.Clientset, error)
+	}{{
+		policy: "",
+		check: func(t *testing.T, client *fake.Clientset, err error) {
+			require.NoError(t, err)
+			_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+			assert.NoError(t, err, "the ingress is duplicated without policy")
+		},
+	}, {
+		policy: HostConflictSkip,
+		check: func(t *testing.T, client *fake.Clientset, err error) {
+			require.NoError(t, err)
+			_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+			assert.True(t, apierrors.IsNotFound(err))
+			service, err := client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, "https://svc.main.my-domain.com", service.Annotations[ExposeAnnotationKey])
+		},
+	}, {
+		policy: HostConflictAdopt,
+		check: func(t *testing.T, client *fake.Clientset, err error) {
+			require.NoError(t, err)
+			_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+			assert.True(t, apierrors.IsNotFound(err))
+			ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "chart", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, "exposecontroller", ingress.Annotations["fabric8.io/generated-by"])
+			if assert.Len(t, ingress.OwnerReferences, 1) {
+				assert.Equal(t, "svc", ingress.OwnerReferences[0].Name)
+			}
+			events, err := client.CoreV1().Events("main").List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+			if assert.Len(t, events.Items, 1) {
+				assert.Equal(t, "IngressAdopted", events.Items[0].Reason)
+			}
+			service, err := client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, "chart", service.Annotations[ExposeAdoptedIngressAnnotationKey])
+		},
+	}, {
+		policy: HostConflictError,
+		check: func(t *testing.T, client *fake.Clientset, err error) {
+			assert.EqualError(t, err, "the hosts of service main/svc are already claimed by ingress main/chart")
+			assert.True(t, errors.Is(err, ErrConflict))
+			_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+			assert.True(t, apierrors.IsNotFound(err))
+			events, err := client.CoreV1().Events("main").List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+			if assert.Len(t, events.Items, 1) {
+				assert.Equal(t, "HostConflict", events.Items[0].Reason)
+				assert.Equal(t, v1.EventTypeWarning, events.Items[0].Type)
+				assert.Equal(t, "The hosts of the service are already claimed by ingress main/chart", events.Items[0].Message)
+			}
+		},
+	}} {
+		t.Run(test.policy, func(t *testing.T) {
+			service, chartIngress := newObjects()
+			client := fake.NewSimpleClientset(service, chartIngress)
+			strategy, err := NewIngressStrategy(nil, client, &Config{
+				Exposer:            "ingress",
+				Namespace:          "main",
+				Domain:             "my-domain.com",
+				HostConflictPolicy: test.policy,
+			})
+			require.NoError(t, err)
+			require.NoError(t, strategy.Sync())
+			err = strategy.Add(service)
+			test.check(t, client, err)
+		})
+	}
+
+	_, err := NewIngressStrategy(nil, fake.NewSimpleClientset(), &Config{
+		Exposer:            "ingress",
+		Domain:             "my-domain.com",
+		HostConflictPolicy: "merge",
+	})
+	assert.EqualError(t, err, `invalid host conflict policy "merge", must be "skip", "adopt" or "error"`)
+}
+
+func TestIngressStrategy_HostConflict_adoptResync(t *testing.T) {
+	ctx := context.Background()
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "main",
+			Name:            "svc",
+			UID:             "svc-uid",
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	chartIngress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "main",
+			Name:            "chart",
+			ResourceVersion: "1",
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{Host: "svc.main.my-domain.com"}},
+		},
+	}
+	client := fake.NewSimpleClientset(service, chartIngress)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:            "ingress",
+		Namespace:          "main",
+		Domain:             "my-domain.com",
+		HostConflictPolicy: HostConflictAdopt,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(service))
+	adopted, err := client.NetworkingV1().Ingresses("main").Get(ctx, "chart", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	// the next resync keeps the adopted ingress as is
+	updated, err := client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	client.ClearActions()
+	require.NoError(t, strategy.Add(updated))
+	for _, action := range client.Actions() {
+		assert.False(t, action.Matches("delete", "ingresses"), "adopted ingress deleted")
+		assert.False(t, action.Matches("create", "ingresses"), "ingress created")
+		assert.False(t, action.Matches("update", "ingresses"), "adopted ingress updated")
+	}
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+	kept, err := client.NetworkingV1().Ingresses("main").Get(ctx, "chart", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, adopted, kept)
+}
+
+func TestIngressStrategy_findHostConflict_sharedCache(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	generated := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "main",
+			Name:        "svc",
+			Annotations: map[string]string{"fabric8.io/generated-by": "exposecontroller"},
+		},
+		Spec: networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{{Host: "svc.main.my-domain.com"}}},
+	}
+	chart := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "chart"},
+		Spec:       networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{{Host: "svc.main.my-domain.com"}}},
+	}
+	other := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "chart"},
+		Spec:       networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{{Host: "svc.other.my-domain.com"}}},
+	}
+	require.NoError(t, indexer.Add(generated))
+	require.NoError(t, indexer.Add(other))
+	// the API server is not listed
+	strategy := &IngressStrategy{client: fake.NewSimpleClientset(chart), ingressLister: networkinglisters.NewIngressLister(indexer)}
+
+	conflict, err := strategy.findHostConflict(generated)
+	require.NoError(t, err)
+	assert.Nil(t, conflict, "the generated ingresses do not conflict")
+
+	require.NoError(t, indexer.Add(chart))
+	conflict, err = strategy.findHostConflict(generated)
+	require.NoError(t, err)
+	if assert.NotNil(t, conflict) {
+		assert.Equal(t, "chart", conflict.Name)
+		assert.False(t, conflict == chart, "the cached ingress is copied")
+	}
+}
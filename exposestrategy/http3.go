@@ -0,0 +1,56 @@
+package exposestrategy
+
+import (
+	"strconv"
+
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+)
+
+const (
+	// HTTP3AnnotationKey annotation asks for HTTP/3 on the ingress of the service, with the configured HTTP/3 annotations
+	HTTP3AnnotationKey = "fabric8.io/expose.http3"
+	// ExposeHTTP3AnnotationKey annotation will be created on the services asking for HTTP/3, "true" with configured HTTP/3 annotations,
+	// "unsupported" otherwise
+	ExposeHTTP3AnnotationKey = "fabric8.io/exposeHttp3"
+)
+
+// http3Unsupported is the HTTP/3 status of the services without configured HTTP/3 annotations
+const http3Unsupported = "unsupported"
+
+// parseHTTP3 tells if the service asks for HTTP/3
+func parseHTTP3(svc *v1.Service) (bool, error) {
+	value, ok := svc.Annotations[HTTP3AnnotationKey]
+	if !ok {
+		return false, nil
+	}
+	http3, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, newAnnotationParseError(svc, HTTP3AnnotationKey, value, err)
+	}
+	return http3, nil
+}
+
+// applyHTTP3 adds the configured HTTP/3 annotations to the ingress annotations and returns the HTTP/3 status of the service,
+// the ingress controllers such as Traefik and ingress-nginx enable HTTP/3 for the whole controller, not by ingress
+func (s *IngressStrategy) applyHTTP3(svc *v1.Service, ingressClass string, annotations map[string]string) string {
+	if len(s.http3Annotations) == 0 {
+		klog.Warningf("service %s/%s asks for HTTP/3, no HTTP/3 annotation is configured for ingress class \"%s\"",
+			svc.Namespace, svc.Name, ingressClass)
+		return http3Unsupported
+	}
+	for key, value := range s.http3Annotations {
+		annotations[key] = value
+	}
+	return "true"
+}
+
+// setHTTP3Status publishes the HTTP/3 status of the service, removed if empty
+func setHTTP3Status(svc *v1.Service, status string) {
+	if status == "" {
+		delete(svc.Annotations, ExposeHTTP3AnnotationKey)
+		return
+	}
+	svc.Annotations[ExposeHTTP3AnnotationKey] = status
+}
@@ -0,0 +1,95 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_HTTP3(t *testing.T) {
+	ctx := context.Background()
+	newClass := func(name, controller string, isDefault bool) *networkingv1.IngressClass {
+		class := &networkingv1.IngressClass{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       networkingv1.IngressClassSpec{Controller: controller},
+		}
+		if isDefault {
+			class.Annotations = map[string]string{defaultIngressClassAnnotationKey: "true"}
+		}
+		return class
+	}
+	tests := []struct {
+		name             string
+		http3Annotations map[string]string
+		controller       string
+		expected         map[string]string
+		status           string
+	}{
+		{
+			name:       "traefik",
+			controller: "traefik.io/ingress-controller",
+			status:     "unsupported",
+		},
+		{
+			name:       "unsupported",
+			controller: "k8s.io/ingress-nginx",
+			status:     "unsupported",
+		},
+		{
+			name:             "configured",
+			http3Annotations: map[string]string{"example.com/http3": "on"},
+			controller:       "k8s.io/ingress-nginx",
+			expected:         map[string]string{"example.com/http3": "on"},
+			status:           "true",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:       "main",
+					Name:            "web",
+					Annotations:     map[string]string{HTTP3AnnotationKey: "true"},
+					ResourceVersion: "1",
+				},
+				Spec: v1.ServiceSpec{Ports: []v1.ServicePort{{Port: 8080}}},
+			}
+			client := fake.NewSimpleClientset(svc, newClass("public", test.controller, true))
+			strategy, err := NewIngressStrategy(nil, client, &Config{
+				Exposer:          "ingress",
+				Namespace:        "main",
+				Domain:           "my-domain.com",
+				HTTP3Annotations: test.http3Annotations,
+			})
+			require.NoError(t, err)
+			require.NoError(t, strategy.Sync())
+			require.NoError(t, strategy.Add(svc))
+
+			ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "web", metav1.GetOptions{})
+			require.NoError(t, err)
+			for key, value := range test.expected {
+				assert.Equal(t, value, ingress.Annotations[key], key)
+			}
+			// the entrypoints of Traefik are left alone, not to break the plain HTTP redirects
+			assert.NotContains(t, ingress.Annotations, "traefik.ingress.kubernetes.io/router.entrypoints")
+			updated, err := client.CoreV1().Services("main").Get(ctx, "web", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, test.status, updated.Annotations[ExposeHTTP3AnnotationKey])
+
+			// the status is removed once the service does not ask for HTTP/3 anymore
+			setIngressVersions(t, client)
+			delete(updated.Annotations, HTTP3AnnotationKey)
+			require.NoError(t, strategy.Add(updated))
+			updated, err = client.CoreV1().Services("main").Get(ctx, "web", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.NotContains(t, updated.Annotations, ExposeHTTP3AnnotationKey)
+		})
+	}
+}
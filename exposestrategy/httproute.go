@@ -0,0 +1,229 @@
+package exposestrategy
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// URLOwnerIngress tells that the exposed URL is the one of the ingress
+	URLOwnerIngress = "ingress"
+	// URLOwnerHTTPRoute tells that the exposed URL is the one of the HTTP route
+	URLOwnerHTTPRoute = "httproute"
+)
+
+// HTTPRouteResource is the resource of the Gateway API HTTP routes
+var HTTPRouteResource = schema.GroupVersionResource{
+	Group:    "gateway.networking.k8s.io",
+	Version:  "v1",
+	Resource: "httproutes",
+}
+
+// parseURLOwner validates which of the ingress or the HTTP route publishes the exposed URL
+func parseURLOwner(value string) (string, error) {
+	owner := strings.ToLower(value)
+	if owner != URLOwnerIngress && owner != URLOwnerHTTPRoute {
+		return "", errors.Errorf("unknown url owner \"%s\", must be one of \"%s\", \"%s\"",
+			value, URLOwnerIngress, URLOwnerHTTPRoute)
+	}
+	return owner, nil
+}
+
+// buildHTTPRoute builds the HTTP route equivalent to the ingress
+// attached to the given gateway
+func buildHTTPRoute(ingress *networkingv1.Ingress, svc *v1.Service, gatewayName, gatewayNamespace string) *unstructured.Unstructured {
+	parentRef := map[string]interface{}{
+		"name": gatewayName,
+	}
+	if gatewayNamespace != "" {
+		parentRef["namespace"] = gatewayNamespace
+	}
+
+	hostnames := []interface{}{}
+	rules := []interface{}{}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host != "" {
+			hostnames = append(hostnames, rule.Host)
+		}
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, p := range rule.HTTP.Paths {
+			if p.Backend.Service == nil {
+				continue
+			}
+			path := p.Path
+			if path == "" {
+				path = "/"
+			}
+			// HTTP routes only reference the backend ports by number
+			port := p.Backend.Service.Port.Number
+			if port == 0 {
+				for _, sp := range svc.Spec.Ports {
+					if sp.Name == p.Backend.Service.Port.Name {
+						port = sp.Port
+						break
+					}
+				}
+			}
+			matchType := "PathPrefix"
+			if p.PathType != nil && *p.PathType == networkingv1.PathTypeExact {
+				matchType = "Exact"
+			}
+			routeRule := map[string]interface{}{
+				"matches": []interface{}{map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":  matchType,
+						"value": path,
+					},
+				}},
+				"backendRefs": []interface{}{map[string]interface{}{
+					"name": p.Backend.Service.Name,
+					"port": int64(port),
+				}},
+			}
+			// the rules apply to all the hostnames, the ones repeated by host are dropped
+			if !containsRule(rules, routeRule) {
+				rules = append(rules, routeRule)
+			}
+		}
+	}
+
+	route := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"parentRefs": []interface{}{parentRef},
+			"hostnames":  hostnames,
+			"rules":      rules,
+		},
+	}}
+	route.SetAPIVersion(HTTPRouteResource.GroupVersion().String())
+	route.SetKind("HTTPRoute")
+	route.SetNamespace(ingress.Namespace)
+	route.SetName(ingress.Name)
+	labels := map[string]string{}
+	for k, v := range ingress.Labels {
+		labels[k] = v
+	}
+	route.SetLabels(labels)
+	route.SetAnnotations(map[string]string{
+		GeneratedByAnnotationKey: ingress.Annotations[GeneratedByAnnotationKey],
+	})
+	route.SetOwnerReferences(ingress.OwnerReferences)
+	return route
+}
+
+// applyHTTPRoute creates or updates the HTTP route
+func applyHTTPRoute(ctx context.Context, client dynamic.Interface, route *unstructured.Unstructured) error {
+	routes := client.Resource(HTTPRouteResource).Namespace(route.GetNamespace())
+	existing, err := routes.Get(ctx, route.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.Infof("creating http route %s/%s", route.GetNamespace(), route.GetName())
+		_, err = routes.Create(ctx, route, metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create http route %s/%s", route.GetNamespace(), route.GetName())
+		}
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "could not check for existing http route %s/%s", route.GetNamespace(), route.GetName())
+	}
+	if existing.GetAnnotations()[GeneratedByAnnotationKey] != route.GetAnnotations()[GeneratedByAnnotationKey] {
+		return errors.Errorf("http route %s/%s already exists and was not generated by exposecontroller",
+			route.GetNamespace(), route.GetName())
+	}
+	if reflect.DeepEqual(route.Object["spec"], existing.Object["spec"]) &&
+		reflect.DeepEqual(route.GetLabels(), existing.GetLabels()) &&
+		reflect.DeepEqual(route.GetOwnerReferences(), existing.GetOwnerReferences()) {
+		return nil
+	}
+	route.SetResourceVersion(existing.GetResourceVersion())
+	klog.Infof("updating http route %s/%s", route.GetNamespace(), route.GetName())
+	_, err = routes.Update(ctx, route, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to update http route %s/%s", route.GetNamespace(), route.GetName())
+	}
+	return nil
+}
+
+func containsRule(rules []interface{}, rule map[string]interface{}) bool {
+	for _, r := range rules {
+		if reflect.DeepEqual(r, rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// eachHTTPRoute calls fn on the HTTP routes having the provider label or the legacy one
+// the routes are listed by page, all at once if pageSize is 0, none if the Gateway API is not installed
+func eachHTTPRoute(ctx context.Context, client dynamic.Interface, namespace string, provider ProviderLabel, pageSize int64, fn func(*unstructured.Unstructured)) error {
+	return eachGeneratedObject(ctx, client, HTTPRouteResource, namespace, provider, pageSize, fn)
+}
+
+// eachGeneratedObject calls fn on the objects of the resource having the provider label or the legacy one
+// the objects are listed by page, all at once if pageSize is 0, none if the resource is not installed
+func eachGeneratedObject(ctx context.Context, client dynamic.Interface, resource schema.GroupVersionResource, namespace string, provider ProviderLabel, pageSize int64, fn func(*unstructured.Unstructured)) error {
+	provider = provider.orLegacy()
+	for i, selector := range provider.Selectors() {
+		options := metav1.ListOptions{
+			LabelSelector: selector,
+			Limit:         pageSize,
+		}
+		for {
+			list, err := client.Resource(resource).Namespace(namespace).List(ctx, options)
+			if apierrors.IsNotFound(err) {
+				return nil
+			} else if err != nil {
+				return errors.Wrapf(err, "failed to list %s", resource.Resource)
+			}
+			for index := range list.Items {
+				obj := &list.Items[index]
+				// the objects having both labels are already listed
+				if i > 0 && obj.GetLabels()[provider.Key] == provider.Value {
+					continue
+				}
+				fn(obj)
+			}
+			if list.GetContinue() == "" {
+				break
+			}
+			options.Continue = list.GetContinue()
+		}
+	}
+	return nil
+}
+
+// deleteHTTPRoute deletes the HTTP route with the propagation policy if it was generated by the controller, or releases it with neverDelete
+func deleteHTTPRoute(ctx context.Context, client dynamic.Interface, namespace, name string, neverDelete bool, provider ProviderLabel, propagation *metav1.DeletionPropagation) {
+	routes := client.Resource(HTTPRouteResource).Namespace(namespace)
+	existing, err := routes.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return
+	} else if err != nil {
+		klog.Errorf("error when getting http route %s/%s: %s", namespace, name, err)
+		return
+	}
+	if !provider.IsGenerated(existing.GetAnnotations()) {
+		return
+	}
+	if neverDelete {
+		releaseUnstructured(ctx, routes, existing, provider)
+		return
+	}
+	klog.Infof("cleaning the http route %s/%s", namespace, name)
+	err = routes.Delete(ctx, name, DeleteOptions(propagation, ""))
+	if err != nil && !apierrors.IsNotFound(err) {
+		klog.Errorf("error when deleting http route %s/%s: %s", namespace, name, err)
+	}
+}
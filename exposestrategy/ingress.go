@@ -4,18 +4,26 @@ import (
 	"context"
 	"fmt"
 	"reflect"
-	"strconv"
+	"sort"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 	"k8s.io/klog"
 
 	"k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/utils/clock"
 )
 
 const (
@@ -25,6 +33,11 @@ const (
 	ServiceAPIVersion = "v1"
 	// ServiceKind the expected kind of a service
 	ServiceKind = "Service"
+
+	// inline ingress annotations above that size should move to a config map
+	maxInlineIngressAnnotationsSize = 32 * 1024
+	// listPageSize is the number of objects listed per request when syncing
+	listPageSize = 500
 )
 
 // IngressStrategy is a strategy that creates ingresses for the services
@@ -35,6 +48,7 @@ type IngressStrategy struct {
 	namespace      string
 	namePrefix     string
 	domain         string
+	autoDomain     *autoDomain
 	internalDomain string
 	tlsSecretName  string
 	tlsUseWildcard bool
@@ -43,15 +57,144 @@ type IngressStrategy struct {
 	urltemplate    string
 	pathMode       string
 	ingressClass   string
+	pathModeClass  string
+	portMapping    map[int32]int32
+	skipOwnerRefs  bool
+	provider       ProviderLabel
+	pageSize       int64
 	existing       map[string][]string
+	// defaultIngressClass is the default ingress class of the cluster set in the spec of the ingresses without class, none if empty
+	defaultIngressClass string
+	// with the dns01 challenge, the acme issuer issues the wildcard certificates managed by the controller
+	acmeChallengeType string
+	acmeIssuer        string
+	// strictAnnotations rejects the duplicate and invalid keys of the ingress annotations
+	strictAnnotations bool
+	// detectExposePort chooses the HTTP port of the services with several ports
+	detectExposePort bool
+	// teams are the exposure policies by team name
+	teams map[string]TeamConfig
+	// the ports of the ingress controller in the published URLs, the default ones if 0
+	httpPort  int32
+	httpsPort int32
+	// http3Annotations are added to the ingresses of the services asking for HTTP/3
+	http3Annotations map[string]string
+	// shard is the subset of the namespaces whose generated objects are synced
+	shard Shard
+	// domains are the domains selected by the services with their annotation, such as the domains of the regions
+	domains []string
+	// neverDelete releases the generated objects instead of deleting them
+	neverDelete bool
+	// deletePropagation is the propagation policy of the deletions, nil for the default one
+	deletePropagation *metav1.DeletionPropagation
+	// the ingresses of the unexposed services are kept for the grace period, deleted at once if 0
+	deleteGracePeriod time.Duration
+	clock             clock.PassiveClock
+	scheduleResync    func(time.Duration)
+	// the old hosts of the services are redirected to their new one for the grace period, never if 0
+	hostRedirectGracePeriod time.Duration
+	// annotationDenylist strips the denied ingress annotations of the services, nil if none is denied
+	annotationDenylist *annotationDenylist
+	// annotationPropagation copies annotations of the services to their ingresses, nil if none is copied
+	annotationPropagation annotationPropagation
+	// exposeValues tell which services request the exposure, such as those of the other slugs
+	exposeValues *ExposeValues
+	// the caches shared with the controller, the API server is listed if nil
+	serviceLister corelisters.ServiceLister
+	ingressLister networkinglisters.IngressLister
+	// ingressNamespaceName is the namespace of the ingresses backed by ExternalName services, the one of the services if empty
+	ingressNamespaceName string
+	// the templates producing the generated objects instead of the computed ones, nil if not set
+	ingressTemplate   *template.Template
+	httpRouteTemplate *template.Template
+	// hostConflictPolicy tells what to do when an ingress not generated by the controller claims the hosts, not detected if empty
+	hostConflictPolicy string
+	hostConflicts      map[string]string
+	// domainTLSPolicies override the TLS settings for the hosts of their domains
+	domainTLSPolicies map[string]DomainTLSPolicy
+	// ingressStatusCheck publishes the URL once the ingress controller wrote the status of the ingress
+	ingressStatusCheck bool
+	// migrateLegacyAnnotations renames the legacy annotations of the nginx ingress controller instead of duplicating them
+	migrateLegacyAnnotations bool
+	// ingressLabels are stamped on the generated ingresses, such as for the policies selecting them
+	ingressLabels map[string]string
+	// tlsMergePolicy tells whether the TLS entries added to the ingresses by others are preserved
+	tlsMergePolicy string
+	// trailingSlashPolicy adds or strips the trailing slashes of the paths of the published URLs
+	trailingSlashPolicy string
+	// the scheme and the secret of the hosts of the internal domain, their TLS settings apply if empty
+	internalDomainSchemeDefault string
+	internalDomainTLSSecretName string
+	// the owner references of the ingresses to their service are marked as controller and block the deletion of the service if set
+	ownerReferenceController         bool
+	ownerReferenceBlockOwnerDeletion bool
+	// serviceUIDs caches the UIDs of the services by key, for the services given without UID
+	serviceUIDs map[string]types.UID
+	// healthCheckPathDefault is the probe path routed on the hosts of the services for the external uptime checks, none if empty
+	healthCheckPathDefault string
+	// recordLastApplied stores the desired ingresses in their last applied annotation, their labels and annotations being 3-way merged
+	recordLastApplied bool
+
+	// transition mode, HTTP routes are generated next to the ingresses
+	dynamicClient    dynamic.Interface
+	httpRoute        bool
+	gatewayName      string
+	gatewayNamespace string
+	urlOwner         string
+	existingRoutes   map[string][]string
+
+	// GKE FrontendConfigs and BackendConfigs are generated next to the ingresses
+	gkeConfigs bool
+	// dnsChecker checks that the hosts resolve to the ingress controller, nil if not checked
+	dnsChecker *dnsChecker
+
+	// set by the strategies generating ingresses for a specific controller
+	controllerAnnotations map[string]string
+	pathType              networkingv1.PathType
+	backendTLS            backendTLSAnnotations
+	sslRedirect           sslRedirectAnnotations
+	// tlsWithoutSecret tells that the controller terminates TLS without secret
+	tlsWithoutSecret bool
+	// noCanaryIngress tells that the controller does not split the traffic with the canary ingresses of nginx
+	noCanaryIngress bool
 }
 
 // NewIngressStrategy creates a new NewIngressStrategy
 func NewIngressStrategy(ctx context.Context, client kubernetes.Interface, config *Config) (ExposeStrategy, error) {
 
 	var err error
+	if config.IngressNodePortService == DiscoverIngressController {
+		config.IngressNodePortService, err = discoverIngressController(ctx, client, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if config.IngressNodePortService != "" {
+		err = useIngressNodePorts(ctx, client, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the domain follows the load balancer IP of the ingress controller
+	var auto *autoDomain
+	if config.Domain == "" && config.AutoDomainService == DiscoverIngressController {
+		config.AutoDomainService, err = discoverIngressController(ctx, client, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if config.Domain == "" && config.AutoDomainService != "" {
+		auto, err = parseAutoDomain(config.AutoDomainService, config.AutoDomainProvider)
+		if err != nil {
+			return nil, err
+		}
+		config.Domain, err = auto.resolve(ctx, client)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get a domain")
+		}
+	}
 	if config.Domain == "" {
-		config.Domain, err = getAutoDefaultDomain(ctx, client)
+		config.Domain, err = getAutoDefaultDomain(ctx, client, config)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to get a domain")
 		}
@@ -65,12 +208,143 @@ func NewIngressStrategy(ctx context.Context, client kubernetes.Interface, config
 	}
 	klog.Infof("Using url template [%s] format [%s]", config.URLTemplate, urlformat)
 
+	portMapping, err := parsePortMapping(config.PortMapping)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the port mapping")
+	}
+
+	// check the ingress classes against the installed ones
+	classes := listIngressClasses(ctx, client, config)
+
+	if config.HTTPRoute {
+		if config.DynamicClient == nil {
+			return nil, errors.New("a dynamic client is required to generate http routes")
+		}
+		if config.GatewayName == "" {
+			return nil, errors.New("a gateway name is required to generate http routes")
+		}
+		klog.Infof("Using gateway %s/%s for http routes", config.GatewayNamespace, config.GatewayName)
+	}
+	if config.GKEConfigs && config.DynamicClient == nil {
+		return nil, errors.New("a dynamic client is required to generate GKE configs")
+	}
+	if config.IngressNamespace != "" {
+		if errs := validation.IsDNS1123Label(config.IngressNamespace); len(errs) > 0 {
+			return nil, errors.Errorf("invalid ingress namespace \"%s\": %s", config.IngressNamespace, strings.Join(errs, ", "))
+		}
+		klog.Infof("Generating the ingresses in namespace %s", config.IngressNamespace)
+	}
+	deletePropagation, err := ParseDeletePropagation(config.DeletePropagation)
+	if err != nil {
+		return nil, err
+	}
+	acmeChallengeType, err := parseAcmeChallengeType(config.AcmeChallengeType)
+	if err != nil {
+		return nil, err
+	}
+	err = checkDomainTLSPolicies(config.DomainTLSPolicies)
+	if err != nil {
+		return nil, err
+	}
+	// the secrets by host suffix are those of the policies of their domains
+	domainTLSPolicies, err := mergeTLSSecretsBySuffix(config.DomainTLSPolicies, config.TLSSecretsBySuffix)
+	if err != nil {
+		return nil, err
+	}
+	if (config.TLSAcme || usesDomainAcme(config.DomainTLSPolicies)) && acmeChallengeType == AcmeChallengeDNS01 {
+		if config.DynamicClient == nil {
+			return nil, errors.New("a dynamic client is required to generate certificates")
+		}
+		if config.AcmeIssuer == "" {
+			return nil, errors.New("an ACME issuer is required with the dns01 challenge")
+		}
+		klog.Infof("Using wildcard certificates issued by %s", config.AcmeIssuer)
+	}
+	for _, port := range []int{config.HTTPPort, config.HTTPSPort} {
+		if port < 0 || port > 65535 {
+			return nil, errors.Errorf("invalid ingress controller port %d, must be between 1 and 65535", port)
+		}
+	}
+	annotationDenylist, err := newAnnotationDenylist(config.AnnotationDenylist)
+	if err != nil {
+		return nil, err
+	}
+	annotationPropagation, err := newAnnotationPropagation(config.AnnotationPropagation)
+	if err != nil {
+		return nil, err
+	}
+	var dnsChecker *dnsChecker
+	if config.DNSCheck {
+		dnsChecker = newDNSChecker()
+	}
+	err = checkIngressLabels(config.IngressLabels)
+	if err != nil {
+		return nil, err
+	}
+	tlsMergePolicy, err := parseTLSMergePolicy(config.TLSMergePolicy)
+	if err != nil {
+		return nil, err
+	}
+	trailingSlashPolicy, err := parseTrailingSlashPolicy(config.URLTrailingSlash)
+	if err != nil {
+		return nil, err
+	}
+	err = checkInternalDomainScheme(config.InternalDomainScheme)
+	if err != nil {
+		return nil, err
+	}
+	var deleteGracePeriod time.Duration
+	if config.DeleteGracePeriod != "" {
+		deleteGracePeriod, err = time.ParseDuration(config.DeleteGracePeriod)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid delete grace period \"%s\"", config.DeleteGracePeriod)
+		}
+		if deleteGracePeriod < 0 {
+			return nil, errors.Errorf("invalid delete grace period \"%s\", must not be negative", config.DeleteGracePeriod)
+		}
+	}
+	hostRedirectGracePeriod, err := parseHostRedirectGracePeriod(config.HostRedirectGracePeriod)
+	if err != nil {
+		return nil, err
+	}
+	if config.HealthCheckPath != "" && !isHealthCheckPath(config.HealthCheckPath) {
+		return nil, errors.Errorf("invalid health check path \"%s\", must be an absolute path", config.HealthCheckPath)
+	}
+	var passiveClock clock.PassiveClock = clock.RealClock{}
+	if config.Clock != nil {
+		passiveClock = config.Clock
+	}
+	scheduleResync := config.ScheduleResync
+	if scheduleResync == nil {
+		scheduleResync = func(time.Duration) {}
+	}
+	ingressTemplate, err := loadObjectTemplate("ingress", config.IngressTemplate)
+	if err != nil {
+		return nil, err
+	}
+	httpRouteTemplate, err := loadObjectTemplate("http route", config.HTTPRouteTemplate)
+	if err != nil {
+		return nil, err
+	}
+	hostConflictPolicy, err := parseHostConflictPolicy(config.HostConflictPolicy)
+	if err != nil {
+		return nil, err
+	}
+	urlOwner := URLOwnerIngress
+	if config.URLOwner != "" {
+		urlOwner, err = parseURLOwner(config.URLOwner)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &IngressStrategy{
 		ctx:            ctx,
 		client:         client,
 		namespace:      config.Namespace,
 		namePrefix:     config.NamePrefix,
 		domain:         config.Domain,
+		autoDomain:     auto,
 		internalDomain: config.InternalDomain,
 		http:           config.HTTP,
 		tlsAcme:        config.TLSAcme,
@@ -78,69 +352,241 @@ func NewIngressStrategy(ctx context.Context, client kubernetes.Interface, config
 		tlsUseWildcard: config.TLSUseWildcard,
 		urltemplate:    urlformat,
 		pathMode:       config.PathMode,
-		ingressClass:   config.IngressClass,
+		ingressClass:   checkIngressClass(classes, config.IngressClass),
+		pathModeClass:  checkIngressClass(classes, pathModeIngressClass),
+		portMapping:    portMapping,
+		skipOwnerRefs:  config.SkipOwnerReferences,
+		provider:       config.ProviderLabel,
+		pageSize:       listPageSize,
+
+		acmeChallengeType: acmeChallengeType,
+		acmeIssuer:        config.AcmeIssuer,
+
+		strictAnnotations: config.StrictAnnotations,
+		detectExposePort:  config.DetectExposePort,
+		teams:             checkTeams(classes, config.Teams),
+
+		httpPort:           int32(config.HTTPPort),
+		httpsPort:          int32(config.HTTPSPort),
+		annotationDenylist: annotationDenylist,
+		neverDelete:        config.NeverDelete,
+		deletePropagation:  deletePropagation,
+		deleteGracePeriod:  deleteGracePeriod,
+		clock:              passiveClock,
+		scheduleResync:     scheduleResync,
+
+		http3Annotations: config.HTTP3Annotations,
+		shard:            config.Shard,
+		domains:          config.Domains,
+
+		annotationPropagation: annotationPropagation,
+
+		hostRedirectGracePeriod: hostRedirectGracePeriod,
+		defaultIngressClass:     detectDefaultIngressClass(classes, config.IngressClass),
+
+		exposeValues:  config.ExposeValues,
+		serviceLister: config.ServiceLister,
+		ingressLister: config.IngressLister,
+
+		ingressNamespaceName: config.IngressNamespace,
+		ingressTemplate:      ingressTemplate,
+		httpRouteTemplate:    httpRouteTemplate,
+		hostConflictPolicy:   hostConflictPolicy,
+		hostConflicts:        map[string]string{},
+		domainTLSPolicies:    domainTLSPolicies,
+		ingressStatusCheck:   config.IngressStatusCheck,
+		ingressLabels:        config.IngressLabels,
+		tlsMergePolicy:       tlsMergePolicy,
+
+		trailingSlashPolicy: trailingSlashPolicy,
+
+		migrateLegacyAnnotations: config.MigrateLegacyAnnotations,
+
+		internalDomainSchemeDefault: config.InternalDomainScheme,
+		internalDomainTLSSecretName: config.InternalDomainTLSSecretName,
+
+		ownerReferenceController:         config.OwnerReferenceController,
+		ownerReferenceBlockOwnerDeletion: config.OwnerReferenceBlockOwnerDeletion,
+		healthCheckPathDefault:           config.HealthCheckPath,
+		recordLastApplied:                config.RecordLastApplied,
+
+		dynamicClient:    config.DynamicClient,
+		httpRoute:        config.HTTPRoute,
+		gatewayName:      config.GatewayName,
+		gatewayNamespace: config.GatewayNamespace,
+		urlOwner:         urlOwner,
+
+		gkeConfigs:  config.GKEConfigs,
+		backendTLS:  nginxBackendTLS,
+		sslRedirect: nginxSSLRedirect,
+		dnsChecker:  dnsChecker,
 	}, nil
 }
 
-// CleanIngressStrategy deletes all the ingresses created by the controller
-func CleanIngressStrategy(ctx context.Context, client kubernetes.Interface, namespace string) error {
-	// list all existing ingresses
-	selector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
-		MatchLabels: map[string]string{"provider": "fabric8"},
+// CleanIngressStrategy deletes all the ingresses and HTTP routes created by the controller with the propagation policy,
+// they are released instead with neverDelete
+func CleanIngressStrategy(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, provider ProviderLabel, neverDelete bool, propagation *metav1.DeletionPropagation) error {
+	return cleanGeneratedObjects(ctx, client, dynamicClient, namespace, provider, neverDelete, propagation, func(svc string, del bool) bool {
+		return del || svc != ""
+	})
+}
+
+// PruneIngressStrategy removes the ingresses and HTTP routes generated for the services, by "namespace/name" key
+func PruneIngressStrategy(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, provider ProviderLabel, services map[string]bool, neverDelete bool, propagation *metav1.DeletionPropagation) error {
+	return cleanGeneratedObjects(ctx, client, dynamicClient, namespace, provider, neverDelete, propagation, func(svc string, del bool) bool {
+		return services[svc]
+	})
+}
+
+// cleanGeneratedObjects deletes the ingresses and HTTP routes matching the service they belong to
+func cleanGeneratedObjects(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, provider ProviderLabel, neverDelete bool, propagation *metav1.DeletionPropagation, match func(svc string, del bool) bool) error {
+	// check which service is referencing each ingress
+	err := eachIngress(ctx, client, namespace, provider, listPageSize, func(ingress *networkingv1.Ingress) {
+		if match(getIngressService(ingress, provider)) {
+			deleteIngress(ctx, client, ingress, neverDelete, provider, propagation)
+		}
 	})
 	if err != nil {
-		return errors.Wrap(err, "failed to build selector")
-	}
-	listOptions := metav1.ListOptions{
-		LabelSelector: selector.String(),
+		return err
 	}
-	list, err := client.NetworkingV1().Ingresses(namespace).List(ctx, listOptions)
-	if err != nil {
-		return errors.Wrap(err, "failed to list ingresses")
+	if dynamicClient == nil {
+		return nil
 	}
-	// check which service is referencing each ingress
-	for index := range list.Items {
-		ingress := &list.Items[index]
-		svc, del := getIngressService(ingress)
-		if del || svc != "" {
-			deleteIngress(ctx, client, ingress)
+	return eachHTTPRoute(ctx, dynamicClient, namespace, provider, listPageSize, func(route *unstructured.Unstructured) {
+		if match(getObjectService(route, provider)) {
+			deleteHTTPRoute(ctx, dynamicClient, route.GetNamespace(), route.GetName(), neverDelete, provider, propagation)
+		}
+	})
+}
+
+// eachIngress calls fn on the ingresses having the provider label or the legacy one
+// the ingresses are listed by page, all at once if pageSize is 0
+func eachIngress(ctx context.Context, client kubernetes.Interface, namespace string, provider ProviderLabel, pageSize int64, fn func(*networkingv1.Ingress)) error {
+	provider = provider.orLegacy()
+	for i, selector := range provider.Selectors() {
+		options := metav1.ListOptions{
+			LabelSelector: selector,
+			Limit:         pageSize,
+		}
+		for {
+			list, err := client.NetworkingV1().Ingresses(namespace).List(ctx, options)
+			if err != nil {
+				return errors.Wrap(err, "failed to list ingresses")
+			}
+			for index := range list.Items {
+				ingress := &list.Items[index]
+				// the ingresses having both labels are already listed
+				if i > 0 && ingress.Labels[provider.Key] == provider.Value {
+					continue
+				}
+				fn(ingress)
+			}
+			if list.Continue == "" {
+				break
+			}
+			options.Continue = list.Continue
 		}
 	}
 	return nil
 }
 
 // Sync is called before starting / resyncing
-// Get the current list of all ingresses created by the controller
+// Get the current list of all ingresses and HTTP routes created by the controller
+// Deletes the ones tracked by label whose service is gone
 func (s *IngressStrategy) Sync() error {
-	// list all existing ingresses
-	selector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
-		MatchLabels: map[string]string{"provider": "fabric8"},
-	})
-	if err != nil {
-		return errors.Wrap(err, "failed to build selector")
-	}
-	listOptions := metav1.ListOptions{
-		LabelSelector: selector.String(),
-	}
-	list, err := s.client.NetworkingV1().Ingresses(s.namespace).List(s.ctx, listOptions)
-	if err != nil {
-		return errors.Wrap(err, "failed to list ingresses")
-	}
+	s.refreshAutoDomain()
 	// check which service is referencing each ingress
 	existing := map[string][]string{}
-	for index := range list.Items {
-		ingress := &list.Items[index]
-		svc, del := getIngressService(ingress)
+	missing := map[string]bool{}
+	usedSecrets := map[string]bool{}
+	// the ingress namespace is listed too when watching a single namespace
+	namespaces := []string{s.namespace}
+	if s.namespace != "" && s.ingressNamespaceName != "" && s.ingressNamespaceName != s.namespace {
+		namespaces = append(namespaces, s.ingressNamespaceName)
+	}
+	syncIngress := func(ingress *networkingv1.Ingress) {
+		if !s.shard.Owns(exposedServiceNamespace(ingress)) {
+			// handled by another shard, its certificates are still in use
+			for _, tls := range ingress.Spec.TLS {
+				usedSecrets[ingress.Namespace+"/"+tls.SecretName] = true
+			}
+			return
+		}
+		svc, del := getIngressService(ingress, s.provider)
 		if del {
-			deleteIngress(nil, s.client, ingress)
+			deleteIngress(s.ctx, s.client, ingress, s.neverDelete, s.provider, s.deletePropagation)
+		} else if svc != "" && len(ingress.OwnerReferences) == 0 && s.isServiceMissing(ingress, missing) {
+			// no garbage collection without owner references
+			deleteIngress(s.ctx, s.client, ingress, s.neverDelete, s.provider, s.deletePropagation)
+		} else if svc != "" && s.isPendingDeleteOver(ingress) {
+			deleteIngress(s.ctx, s.client, ingress, s.neverDelete, s.provider, s.deletePropagation)
+			s.cleanGKEConfigs(ingress.Namespace, ingress.Name)
+		} else if svc != "" && s.isRedirectOver(ingress) {
+			deleteIngress(s.ctx, s.client, ingress, s.neverDelete, s.provider, s.deletePropagation)
 		} else if svc != "" {
-			existing[svc] = append(existing[svc], ingress.Name)
+			s.repairOwnerReference(ingress)
+			existing[svc] = append(existing[svc], ingressEntry(exposedServiceNamespace(ingress), ingress))
+			for _, tls := range ingress.Spec.TLS {
+				usedSecrets[ingress.Namespace+"/"+tls.SecretName] = true
+			}
+		}
+	}
+	for _, namespace := range namespaces {
+		err := eachIngress(s.ctx, s.client, namespace, s.provider, s.pageSize, syncIngress)
+		if err != nil {
+			return err
 		}
 	}
 	s.existing = existing
+	if s.usesWildcardCertificates() {
+		err := s.cleanCertificates(usedSecrets)
+		if err != nil {
+			klog.Warningf("the generated certificates are not cleaned: %s", err)
+		}
+	}
+
+	// the HTTP routes are tracked even out of transition mode to clean them
+	if s.dynamicClient == nil {
+		return nil
+	}
+	existingRoutes := map[string][]string{}
+	err := eachHTTPRoute(s.ctx, s.dynamicClient, s.namespace, s.provider, s.pageSize, func(route *unstructured.Unstructured) {
+		if !s.shard.Owns(route.GetNamespace()) {
+			return
+		}
+		svc, del := getObjectService(route, s.provider)
+		if del || (svc != "" && len(route.GetOwnerReferences()) == 0 && s.isServiceMissing(route, missing)) {
+			deleteHTTPRoute(s.ctx, s.dynamicClient, route.GetNamespace(), route.GetName(), s.neverDelete, s.provider, s.deletePropagation)
+		} else if svc != "" {
+			existingRoutes[svc] = append(existingRoutes[svc], route.GetName())
+		}
+	})
+	if err != nil && s.httpRoute {
+		return err
+	} else if err != nil {
+		klog.Warningf("the generated http routes are not cleaned: %s", err)
+	}
+	s.existingRoutes = existingRoutes
 	return nil
 }
 
+// isServiceMissing tells if the service tracked by the label of the object does not exist anymore
+// the results are cached in missing by service
+func (s *IngressStrategy) isServiceMissing(obj metav1.Object, missing map[string]bool) bool {
+	name := obj.GetLabels()[ExposedServiceLabelKey]
+	namespace := exposedServiceNamespace(obj)
+	svcKey := serviceKey(namespace, name)
+	if result, ok := missing[svcKey]; ok {
+		return result
+	}
+	_, err := s.client.CoreV1().Services(namespace).Get(s.ctx, name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		klog.Errorf("error when getting service %s: %s", svcKey, err)
+	}
+	missing[svcKey] = apierrors.IsNotFound(err)
+	return missing[svcKey]
+}
+
 // HasSynced tells if the strategy is complete
 // Nothing to do
 func (s *IngressStrategy) HasSynced() bool {
@@ -151,6 +597,16 @@ func (s *IngressStrategy) HasSynced() bool {
 // Creates or updates the related ingress, and deletes the others
 // Updates various service annotations
 func (s *IngressStrategy) Add(svc *v1.Service) error {
+	// choose which of the ingress or the http route publishes the url
+	urlOwner := s.urlOwner
+	if owner, ok := svc.Annotations[URLOwnerAnnotationKey]; ok {
+		var err error
+		urlOwner, err = parseURLOwner(owner)
+		if err != nil {
+			return errors.Wrapf(err, "invalid annotation \"%s\" in service %s/%s",
+				URLOwnerAnnotationKey, svc.Namespace, svc.Name)
+		}
+	}
 	// choose the name of the ingress
 	appName := svc.Annotations["fabric8.io/ingress.name"]
 	if appName == "" {
@@ -160,6 +616,14 @@ func (s *IngressStrategy) Add(svc *v1.Service) error {
 			appName = svc.Name
 		}
 	}
+	// the services of a group share the ingress and the host named after the group
+	group, err := parseExposeGroup(svc)
+	if err != nil {
+		return err
+	}
+	if group != "" {
+		appName = group
+	}
 	ingressName := appName
 	if s.namePrefix != "" {
 		if strings.HasSuffix(s.namePrefix, "-") || strings.HasSuffix(s.namePrefix, ".") {
@@ -168,166 +632,524 @@ func (s *IngressStrategy) Add(svc *v1.Service) error {
 			ingressName = s.namePrefix + "-" + appName
 		}
 	}
+	// the ingress of another namespace is prefixed by the namespace of the service
+	ingressNamespace, err := s.ingressNamespace(svc)
+	if err != nil {
+		return err
+	}
+	if ingressNamespace != svc.Namespace {
+		ingressName = svc.Namespace + "-" + ingressName
+		if errs := validation.IsDNS1035Label(ingressName); len(errs) > 0 {
+			return errors.Errorf("invalid name \"%s\" of the ingress of service %s/%s in namespace %s: %s",
+				ingressName, svc.Namespace, svc.Name, ingressNamespace, strings.Join(errs, ", "))
+		}
+	}
+	// a canary ingress sends a part of the traffic to another service
+	split, err := parseTrafficSplit(svc)
+	if err != nil {
+		return err
+	}
+	if split != nil && ingressNamespace != svc.Namespace {
+		return errors.Errorf("service %s/%s cannot split its traffic with an ingress in namespace %s", svc.Namespace, svc.Name, ingressNamespace)
+	} else if split != nil && s.noCanaryIngress {
+		return errors.Errorf("service %s/%s cannot split its traffic, the ingress controller has no canary ingresses", svc.Namespace, svc.Name)
+	}
+	if group != "" && (split != nil || ingressNamespace != svc.Namespace || s.httpRoute) {
+		return errors.Errorf("service %s/%s of group %s cannot split its traffic, have an ingress in another namespace nor an http route",
+			svc.Namespace, svc.Name, group)
+	}
+	// the team of the service decides of its domain, ingress class and TLS secret
+	team, err := s.teamConfig(svc)
+	if err != nil {
+		return err
+	}
 	// choose the hostname and path of the ingress
-	hostName := svc.Annotations["fabric8.io/host.name"]
-	if hostName == "" {
-		hostName = appName
+	host := svc.Annotations["fabric8.io/host.name"]
+	if group != "" {
+		host = group
+	} else if host == "" {
+		host, err = s.serviceSlug(svc)
+		if err != nil {
+			return err
+		}
+	}
+	if host == "" {
+		host = appName
 	}
-	domain := s.domain
-	if svc.Annotations["fabric8.io/use.internal.domain"] == "true" {
+	domain := team.Domain
+	useInternalDomain := svc.Annotations["fabric8.io/use.internal.domain"] == "true"
+	// the service selects one of the configured domains, or all of them with a host by domain
+	var domains []string
+	if useInternalDomain {
 		domain = s.internalDomain
+	} else {
+		domains, err = s.serviceDomains(svc)
+		if err != nil {
+			return err
+		}
+		if len(domains) > 0 {
+			domain = domains[0]
+		}
 	}
-	hostName = fmt.Sprintf(s.urltemplate, hostName, svc.Namespace, domain)
+	if group != "" && len(domains) > 1 {
+		return newAnnotationParseError(svc, ExposeDomainAnnotationKey, svc.Annotations[ExposeDomainAnnotationKey],
+			errors.Errorf("the services of group %s cannot be exposed on all the domains", group))
+	}
+	internalScheme, err := s.internalDomainScheme(svc)
+	if err != nil {
+		return err
+	}
+	hostName := fmt.Sprintf(s.urltemplate, host, svc.Namespace, domain)
 	tlsHostName := hostName
 	if s.tlsUseWildcard {
 		tlsHostName = "*." + domain
 	}
+	// the service is also exposed on the internal domain, the url keeps the normal one
+	internalHostName, internalTLSHostName := "", ""
+	if svc.Annotations["fabric8.io/use.internal.domain"] == "both" && s.internalDomain != "" {
+		internalHostName = fmt.Sprintf(s.urltemplate, host, svc.Namespace, s.internalDomain)
+		internalTLSHostName = internalHostName
+		if s.tlsUseWildcard {
+			internalTLSHostName = "*." + s.internalDomain
+		}
+	}
 	path := svc.Annotations["fabric8.io/ingress.path"]
 	pathMode := svc.Annotations["fabric8.io/path.mode"]
 	if pathMode == "" {
 		pathMode = s.pathMode
 	}
-	if pathMode == PathModeUsePath {
+	var members []*v1.Service
+	if group != "" {
+		if pathMode == PathModeUsePath {
+			return errors.Errorf("service %s/%s of group %s cannot be exposed with path mode %s", svc.Namespace, svc.Name, group, pathMode)
+		}
+		path = groupMemberPath(svc)
+		members, err = s.groupMembers(svc, group)
+		if err != nil {
+			return err
+		}
+		err = checkGroupPath(svc, group, members)
+		if err != nil {
+			return err
+		}
+		err = s.checkGroupAnnotations(svc, group, members)
+		if err != nil {
+			return err
+		}
+	} else if pathMode == PathModeUsePath {
 		if path == "" {
 			path = "/"
 		}
 		path = URLJoin("/", svc.Namespace, appName, path)
 		hostName = domain
-	} else if path != "" && path[0] != '/' {
-		path = "/" + path
+		if internalHostName != "" {
+			internalHostName = s.internalDomain
+		}
+	} else {
+		path = normalizeURLPath(path)
 	}
-	// choose the target port
-	exposePort := svc.Annotations[ExposePortAnnotationKey]
-	if exposePort != "" {
-		port, err := strconv.Atoi(exposePort)
-		if err != nil {
-			return errors.Wrapf(err, "port \"%s\" provided in the annotation \"%s\" is not a valid number in service %s/%s",
-				exposePort, ExposePortAnnotationKey, svc.Namespace, svc.Name)
+	err = checkHostLength(svc, hostName)
+	if err != nil {
+		return err
+	}
+	// the other domains route the same path on their own hosts
+	var domainHostNames []string
+	for i := 1; i < len(domains); i++ {
+		domainHostName := fmt.Sprintf(s.urltemplate, host, svc.Namespace, domains[i])
+		if pathMode == PathModeUsePath {
+			domainHostName = domains[i]
 		}
-		found := false
-		for _, p := range svc.Spec.Ports {
-			if port == int(p.Port) {
-				found = true
-				break
-			}
+		err = checkHostLength(svc, domainHostName)
+		if err != nil {
+			return err
 		}
-		if !found {
-			klog.Warningf("port \"%s\" provided in the annotation \"%s\" is not available in the ports of service %s/%s",
-				exposePort, ExposePortAnnotationKey, svc.Namespace, svc.Name)
-			exposePort = ""
+		domainHostNames = append(domainHostNames, domainHostName)
+	}
+	// choose the target port, either by number or by name
+	exposePort := svc.Annotations[ExposePortAnnotationKey]
+	servicePort, err := findExposePort(svc, exposePort)
+	if err != nil {
+		return err
+	}
+	// detect the HTTP port of a service with several ports
+	if servicePort == nil && exposePort == "" && s.detectExposePort && len(svc.Spec.Ports) > 1 {
+		servicePort = detectExposePort(s.ctx, s.client, svc)
+		if servicePort != nil {
+			klog.Infof("Detected HTTP Port %d of Service %s/%s", servicePort.Port, svc.Namespace, svc.Name)
 		}
 	}
 	// Pick the fist port available in the service if no expose port was configured
-	if exposePort == "" && len(svc.Spec.Ports) > 0 {
-		port := svc.Spec.Ports[0]
-		exposePort = strconv.Itoa(int(port.Port))
+	if servicePort == nil {
+		if len(svc.Spec.Ports) == 0 {
+			return newServiceError(svc, ErrNoPorts, "no port to expose in service %s/%s", svc.Namespace, svc.Name)
+		}
+		servicePort = &svc.Spec.Ports[0]
 	}
-	servicePort, err := strconv.Atoi(exposePort)
+	klog.Infof("Exposing Port %d of Service %s/%s",
+		servicePort.Port, svc.Namespace, svc.Name)
+	err = checkHTTPPort(svc, servicePort)
 	if err != nil {
-		return errors.Wrapf(err, "failed to convert the exposed port \"%s\" to int in service %s/%s",
-			exposePort, svc.Namespace, svc.Name)
+		return err
+	}
+	// a named port is referenced by its name in the ingress backend
+	backendPort := networkingv1.ServiceBackendPort{Number: servicePort.Port}
+	if servicePort.Name != "" && servicePort.Name == exposePort {
+		backendPort = networkingv1.ServiceBackendPort{Name: servicePort.Name}
+	}
+	// remap the backend port if a rule matches
+	portMapping := s.portMapping
+	if rules, ok := svc.Annotations[PortMappingAnnotationKey]; ok {
+		var err error
+		portMapping, err = parsePortMapping(rules)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse annotation \"%s\" in service %s/%s",
+				PortMappingAnnotationKey, svc.Namespace, svc.Name)
+		}
+	}
+	if mapped, ok := portMapping[servicePort.Port]; ok {
+		klog.Infof("Mapping Port %d of Service %s/%s to backend port %d",
+			servicePort.Port, svc.Namespace, svc.Name, mapped)
+		backendPort = networkingv1.ServiceBackendPort{Number: mapped}
 	}
-	klog.Infof("Exposing Port %d of Service %s/%s",
-		servicePort, svc.Namespace, svc.Name)
 	// gather the annotations of the ingress
 	ingressAnnotations := map[string]string{}
-	// ingress class annotation
-	if s.ingressClass != "" {
-		ingressAnnotations["kubernetes.io/ingress.class"] = s.ingressClass
-		ingressAnnotations["nginx.ingress.kubernetes.io/ingress.class"] = s.ingressClass
+	var ingressClassName *string
+	// ingress class annotation, or the default class in the spec
+	if team.IngressClass != "" {
+		ingressAnnotations["kubernetes.io/ingress.class"] = team.IngressClass
+		ingressAnnotations["nginx.ingress.kubernetes.io/ingress.class"] = team.IngressClass
 	} else if pathMode == PathModeUsePath {
-		ingressAnnotations["kubernetes.io/ingress.class"] = "nginx"
-		ingressAnnotations["nginx.ingress.kubernetes.io/ingress.class"] = "nginx"
+		class := s.pathModeClass
+		if class == "" {
+			class = pathModeIngressClass
+		}
+		ingressAnnotations["kubernetes.io/ingress.class"] = class
+		ingressAnnotations["nginx.ingress.kubernetes.io/ingress.class"] = class
+	} else if s.defaultIngressClass != "" {
+		ingressClassName = &s.defaultIngressClass
 	}
-	// check for tls
-	tlsSecretName := s.tlsSecretName
-	if s.tlsAcme {
-		ingressAnnotations["kubernetes.io/tls-acme"] = "true"
-		if tlsSecretName == "" {
-			tlsSecretName = "tls-" + appName
+	for key, value := range s.controllerAnnotations {
+		ingressAnnotations[key] = value
+	}
+	// HTTP/3 is enabled by the annotations of the controller of the ingress class
+	http3, err := parseHTTP3(svc)
+	if err != nil {
+		return err
+	}
+	http3Status := ""
+	if http3 {
+		class := ingressAnnotations["kubernetes.io/ingress.class"]
+		if ingressClassName != nil {
+			class = *ingressClassName
 		}
+		http3Status = s.applyHTTP3(svc, class, ingressAnnotations)
+	}
+	if s.gkeConfigs {
+		ingressAnnotations[frontendConfigAnnotationKey] = ingressName
+	}
+	// check for tls, the policy of the domain of each host overrides the controller one
+	optOut, err := parseACMEOptOut(svc)
+	if err != nil {
+		return err
+	}
+	tlsSecretName, tlsAcme := s.hostTLS(hostName, team.TLSSecretName, appName, optOut)
+	plainHTTP := s.domainTLSPolicy(hostName).TLS == DomainTLSNone
+	// the scheme of the internal domain overrides the TLS settings of its hosts
+	if useInternalDomain && internalScheme != "" {
+		tlsSecretName, tlsAcme = s.internalHostTLS(hostName, team.TLSSecretName, appName, optOut, internalScheme)
+		plainHTTP = internalScheme == InternalDomainSchemeHTTP
+	}
+	// the TLS connections are passed through to the service if the ingress does not terminate them
+	allowHTTP, err := parseAllowHTTP(svc)
+	if err != nil {
+		return err
+	}
+	passthrough, err := s.backendTLS.apply(svc, servicePort, !s.http && !plainHTTP && tlsSecretName == "" && !s.tlsWithoutSecret, ingressAnnotations)
+	if err != nil {
+		return err
 	}
 
-	var tlsSpec []networkingv1.IngressTLS
-	if tlsSecretName != "" {
-		tlsSpec = []networkingv1.IngressTLS{
-			{
-				Hosts:      []string{tlsHostName},
-				SecretName: tlsSecretName,
-			},
+	// gather the hosts of the ingress and the secrets of their certificates
+	hosts := []ingressHost{{name: hostName, tlsName: tlsHostName, tlsSecret: tlsSecretName}}
+	if internalHostName != "" {
+		secret, acme := s.internalHostTLS(internalHostName, team.TLSSecretName, appName, optOut, internalScheme)
+		hosts = append(hosts, ingressHost{name: internalHostName, tlsName: internalTLSHostName, tlsSecret: secret})
+		tlsAcme = tlsAcme || acme
+	}
+	for i, domainHostName := range domainHostNames {
+		domainTLSHostName := domainHostName
+		if s.tlsUseWildcard {
+			domainTLSHostName = "*." + domains[i+1]
+		}
+		secret, acme := s.hostTLS(domainHostName, team.TLSSecretName, appName, optOut)
+		hosts = append(hosts, ingressHost{name: domainHostName, tlsName: domainTLSHostName, tlsSecret: secret})
+		tlsAcme = tlsAcme || acme
+	}
+	if value := svc.Annotations[AdditionalHostsAnnotationKey]; value != "" {
+		additionalHosts, err := parseAdditionalHosts(value, "")
+		if err != nil {
+			return errors.Wrapf(err, "invalid annotation \"%s\" in service %s/%s",
+				AdditionalHostsAnnotationKey, svc.Namespace, svc.Name)
+		}
+		for i, h := range additionalHosts {
+			if h.tlsSecret == "" {
+				secret, acme := s.hostTLS(h.name, team.TLSSecretName, appName, optOut)
+				additionalHosts[i].tlsSecret = secret
+				tlsAcme = tlsAcme || acme
+			}
+		}
+		hosts = append(hosts, additionalHosts...)
+	}
+	if tlsAcme {
+		ingressAnnotations["kubernetes.io/tls-acme"] = "true"
+	}
+	if s.usesWildcardCertificates() {
+		err = s.applyCertificates(ingressNamespace, hosts)
+		if err != nil {
+			return err
 		}
 	}
-	// add all the other annotations
-	annotationsString := svc.Annotations["fabric8.io/ingress.annotations"]
+	tlsSpec := groupIngressTLS(hosts)
+	// add all the other annotations, the inline ones override those of the config map and the propagated ones
+	serviceAnnotations := map[string]string{}
+	s.annotationPropagation.copy(svc, serviceAnnotations)
+	if from := svc.Annotations[IngressAnnotationsFromAnnotationKey]; from != "" {
+		err := s.addConfigMapAnnotations(svc, from, serviceAnnotations)
+		if err != nil {
+			return err
+		}
+	}
+	annotationsString := svc.Annotations[IngressAnnotationsAnnotationKey]
+	if len(annotationsString) > maxInlineIngressAnnotationsSize {
+		klog.Warningf("annotation \"%s\" of service %s/%s is %d bytes large, consider moving it to a config map referenced by \"%s\"",
+			IngressAnnotationsAnnotationKey, svc.Namespace, svc.Name, len(annotationsString), IngressAnnotationsFromAnnotationKey)
+	}
 	if annotationsString != "" {
-		err := yaml.Unmarshal([]byte(annotationsString), ingressAnnotations)
+		err := parseAnnotationsYAML(svc, IngressAnnotationsAnnotationKey, annotationsString, s.strictAnnotations, serviceAnnotations)
 		if err != nil {
-			return errors.Wrapf(err, "failed to parse annotation \"fabric8.io/ingress.annotations\" in service %s/%s",
-				svc.Namespace, svc.Name)
+			return err
 		}
 	}
+	s.annotationDenylist.strip(s.ctx, s.client, svc, serviceAnnotations)
+	for key, value := range serviceAnnotations {
+		ingressAnnotations[key] = value
+	}
+	// the service accepts plain HTTP whatever its annotations
+	if allowHTTP {
+		s.sslRedirect.allowHTTP(ingressAnnotations)
+	}
 	// that annotation is important and cannot be overridden
-	ingressAnnotations["fabric8.io/generated-by"] = "exposecontroller"
-	pathTypeImplementationSpecific := networkingv1.PathTypeImplementationSpecific
+	ingressAnnotations[GeneratedByAnnotationKey] = s.provider.Marker()
+	var migrated []string
+	if s.migrateLegacyAnnotations {
+		migrated = migrateLegacyAnnotations(ingressAnnotations)
+	}
+	// the labels of the config and of the service are stamped on the ingress, without overriding those of the controller
+	ingressLabels := map[string]string{}
+	for key, value := range s.ingressLabels {
+		ingressLabels[key] = value
+	}
+	if labelsString := svc.Annotations[IngressLabelsAnnotationKey]; labelsString != "" {
+		err := parseLabelsYAML(svc, labelsString, s.strictAnnotations, ingressLabels)
+		if err != nil {
+			return err
+		}
+	}
+	// without owner references, the service is found back from the labels
+	provider := s.provider.orLegacy()
+	ingressLabels[provider.Key] = provider.Value
+	var ownerReferences []metav1.OwnerReference
+	backendName := svc.Name
+	if ingressNamespace != svc.Namespace {
+		// no owner reference across namespaces, the ingress is backed by the service of the same name
+		ingressLabels[ExposedServiceLabelKey] = svc.Name
+		ingressLabels[ExposedNamespaceLabelKey] = svc.Namespace
+		backendName = ingressName
+	} else if SkipOwnerReferences(svc, s.skipOwnerRefs) {
+		ingressLabels[ExposedServiceLabelKey] = svc.Name
+	} else {
+		uid, err := s.serviceUID(svc)
+		if err != nil {
+			return err
+		}
+		ownerReferences = []metav1.OwnerReference{s.serviceOwnerReference(svc.Name, uid)}
+	}
+	pathType := s.pathType
+	if pathType == "" {
+		pathType = networkingv1.PathTypeImplementationSpecific
+	}
+	// a prefix is never empty, the exposed url keeps the path as is
+	ingressPath := path
+	if pathType == networkingv1.PathTypePrefix && ingressPath == "" {
+		ingressPath = "/"
+	}
+	var rules []networkingv1.IngressRule
+	seenHosts := map[string]bool{}
+	for _, h := range hosts {
+		if seenHosts[h.name] {
+			continue
+		}
+		seenHosts[h.name] = true
+		rules = append(rules, networkingv1.IngressRule{
+			Host: h.name,
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Backend: networkingv1.IngressBackend{
+							Service: &networkingv1.IngressServiceBackend{
+								Name: backendName,
+								Port: backendPort},
+						},
+						Path:     ingressPath,
+						PathType: &pathType,
+					}},
+				},
+			},
+		})
+	}
+	if generation := svc.Annotations[ExposeGenerationAnnotationKey]; generation != "" {
+		ingressAnnotations[ExposeGenerationAnnotationKey] = generation
+	}
+	// the external uptime checks probe the service on its hosts
+	healthPath, err := s.healthCheckPath(svc)
+	if err != nil {
+		return err
+	}
+	if healthPath != "" {
+		addHealthCheckPath(rules, healthPath, networkingv1.IngressBackend{
+			Service: &networkingv1.IngressServiceBackend{Name: backendName, Port: backendPort},
+		})
+	}
 	// build the ingress
 	ingress := networkingv1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
-			Namespace: svc.Namespace,
-			Name:      ingressName,
-			Labels: map[string]string{
-				"provider": "fabric8",
-			},
-			Annotations: ingressAnnotations,
-			OwnerReferences: []metav1.OwnerReference{{
-				Kind:       ServiceKind,
-				APIVersion: ServiceAPIVersion,
-				Name:       svc.Name,
-				UID:        svc.UID,
-			}},
+			Namespace:       ingressNamespace,
+			Name:            ingressName,
+			Labels:          ingressLabels,
+			Annotations:     ingressAnnotations,
+			OwnerReferences: ownerReferences,
 		},
 		Spec: networkingv1.IngressSpec{
-			Rules: []networkingv1.IngressRule{{
-				Host: hostName,
-				IngressRuleValue: networkingv1.IngressRuleValue{
-					HTTP: &networkingv1.HTTPIngressRuleValue{
-						Paths: []networkingv1.HTTPIngressPath{{
-							Backend: networkingv1.IngressBackend{
-								Service: &networkingv1.IngressServiceBackend{
-									Name: svc.Name,
-									Port: networkingv1.ServiceBackendPort{Number: int32(servicePort)}},
-							},
-							Path:     path,
-							PathType: &pathTypeImplementationSpecific,
-						}},
-					},
-				},
-			}},
-			TLS: tlsSpec,
+			IngressClassName: ingressClassName,
+			Rules:            rules,
+			TLS:              tlsSpec,
 		},
 	}
+	sortIngressSpec(&ingress.Spec)
+	// the ingress template produces the ingress from the computed one
+	if s.ingressTemplate != nil {
+		rendered, err := renderIngress(s.ingressTemplate, newTemplateData(svc, &ingress, hostName, tlsSecretName), &ingress)
+		if err != nil {
+			return errors.Wrapf(err, "failed to render the ingress of service %s/%s", svc.Namespace, svc.Name)
+		}
+		ingress = *rendered
+		rules = ingress.Spec.Rules
+	}
+	// the other members of the group keep their paths
+	if group != "" {
+		err = s.mergeGroupIngress(svc, &ingress, group, members)
+		if err != nil {
+			return err
+		}
+	}
+	// a renamed service takes over the ingresses of the service it replaces on the same hosts
+	if group == "" {
+		err = s.takeOverReplacedServices(svc, &ingress)
+		if err != nil {
+			return err
+		}
+	}
+	// another ingress may already claim the hosts, such as the one of a Helm chart
+	conflict, err := s.checkHostConflict(svc, &ingress)
+	if err != nil {
+		return err
+	}
+	adopted := s.adoptedIngressName(svc)
+	if conflict != nil && s.hostConflictPolicy == HostConflictSkip {
+		return s.skipConflictingIngress(svc, conflict, hostName, path)
+	} else if conflict != nil {
+		adopted = conflict.Name
+	}
+	if adopted != "" {
+		ingress.Name = adopted
+	}
 	// clean the old ingresses of the service if they have a different name
-	ingresses := s.client.NetworkingV1().Ingresses(svc.Namespace)
-	svcKey := fmt.Sprintf("%s/%s", svc.Namespace, svc.Name)
-
-	for _, name := range s.existing[svcKey] {
-		if name != ingress.Name {
-			existing, err := ingresses.Get(s.ctx, name, metav1.GetOptions{})
-			if err == nil {
-				exKey, del := getIngressService(existing)
+	ingresses := s.client.NetworkingV1().Ingresses(ingressNamespace)
+	svcKey := serviceKey(svc.Namespace, svc.Name)
+	entries := []string{ingressEntry(svc.Namespace, &ingress)}
+	if split != nil {
+		entries = append(entries, ingress.Name+canaryIngressSuffix)
+	}
+	// the hosts published before are redirected to the new one during the grace period
+	redirect := s.redirectsHosts(svc, ingressNamespace) && group == ""
+	oldHost := publishedHost(svc)
+	redirectEntry := ingress.Name + redirectIngressSuffix
+	if redirect && (oldHost != "" && oldHost != hostName || contains(s.existing[svcKey], redirectEntry)) {
+		entries = append(entries, redirectEntry)
+	} else {
+		redirect = false
+	}
+
+	for _, oldEntry := range s.existing[svcKey] {
+		if !contains(entries, oldEntry) {
+			namespace, name := parseIngressEntry(svc.Namespace, oldEntry)
+			existing, err := s.client.NetworkingV1().Ingresses(namespace).Get(s.ctx, name, metav1.GetOptions{})
+			if err == nil && isGroupIngress(existing) {
+				s.leaveGroupIngress(existing, svc)
+			} else if err == nil {
+				exKey, del := getIngressService(existing, s.provider)
 				if del || exKey == svcKey {
-					deleteIngress(nil, s.client, existing)
+					deleteIngress(s.ctx, s.client, existing, s.neverDelete, s.provider, s.deletePropagation)
 				}
 			} else if !apierrors.IsNotFound(err) {
 				klog.Errorf("error when getting ingress %s/%s: %s",
-					svc.Namespace, name, err)
+					namespace, name, err)
 			}
+			s.cleanGKEConfigs(namespace, name)
+		}
+	}
+	s.existing[svcKey] = entries
+	if s.recordLastApplied {
+		err = recordLastApplied(&ingress)
+		if err != nil {
+			return err
 		}
 	}
-	s.existing[svcKey] = []string{ingress.Name}
 	// check for an existing ingress
 	existing, err := ingresses.Get(s.ctx, ingress.Name, metav1.GetOptions{})
+	// the ingress of another instance of the controller is left to it
+	if err == nil && existing.Annotations[GeneratedByAnnotationKey] != "" && !s.provider.IsGenerated(existing.Annotations) {
+		return newServiceError(svc, ErrConflict, "ingress %s/%s of service %s/%s is generated by %s",
+			existing.Namespace, existing.Name, svc.Namespace, svc.Name, existing.Annotations[GeneratedByAnnotationKey])
+	}
+	// a new expose generation creates the ingress again from scratch, once the finalizers let it go
+	deleting := err == nil && s.isIngressDeleting(svc, existing)
+	if err == nil && !deleting && s.needsRecreate(svc, existing) {
+		err = s.deleteForRecreate(svc, existing)
+		if err != nil {
+			return err
+		}
+		existing, err = ingresses.Get(s.ctx, ingress.Name, metav1.GetOptions{})
+		deleting = err == nil && s.isIngressDeleting(svc, existing)
+	}
+	if deleting {
+		return nil
+	}
 
+	upToDate := false
+	applied := existing
+	var previous *networkingv1.Ingress
+	var status []networkingv1.IngressLoadBalancerIngress
 	if err == nil {
+		previous = existing
+		status = existing.Status.LoadBalancer.Ingress
+		s.mergeIngressTLS(&ingress, existing)
+		if s.recordLastApplied {
+			err = mergeLastApplied(&ingress, existing)
+			if err != nil {
+				return err
+			}
+		}
 		// if the ingress is the same in all points, no need to update
 		if reflect.DeepEqual(ingress.Labels, existing.Labels) &&
 			reflect.DeepEqual(ingress.Annotations, existing.Annotations) &&
@@ -335,39 +1157,166 @@ func (s *IngressStrategy) Add(svc *v1.Service) error {
 			reflect.DeepEqual(ingress.Spec, existing.Spec) {
 			klog.Infof("ingress %s/%s already up to date for service %s/%s",
 				ingress.Namespace, ingress.Name, svc.Namespace, svc.Name)
-			return nil
+			upToDate = true
 		}
 		// get the resource version for update
 		ingress.ResourceVersion = existing.ResourceVersion
+		upToDate = upToDate || isUnmanagedIngress(existing, "updating")
 	} else if !apierrors.IsNotFound(err) {
 		return errors.Wrapf(err, "could not check for existing ingress %s/%s", ingress.Namespace, ingress.Name)
+	} else {
+		s.mergeIngressTLS(&ingress, nil)
 	}
 	// create or update the ingress
-	klog.Infof("processing ingress %s/%s for service %s/%s with http: %v, path mode: %s, and path: %s",
-		ingress.Namespace, ingress.Name, svc.Namespace, svc.Name, s.http, pathMode, path)
+	if !upToDate {
+		klog.Infof("processing ingress %s/%s for service %s/%s with http: %v, path mode: %s, and path: %s",
+			ingress.Namespace, ingress.Name, svc.Namespace, svc.Name, s.http, pathMode, path)
+		s.reportMigratedAnnotations(svc, &ingress, migrated)
 
-	if ingress.ResourceVersion == "" {
-		_, err := ingresses.Create(s.ctx, &ingress, metav1.CreateOptions{})
+		logIngressChange(previous, &ingress)
+		if ingress.ResourceVersion == "" {
+			applied, err = ingresses.Create(s.ctx, &ingress, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// the ingress is still being deleted, or created in the meantime
+				klog.Infof("ingress %s/%s of service %s/%s already exists, checked again later",
+					ingress.Namespace, ingress.Name, svc.Namespace, svc.Name)
+				s.scheduleResync(ingressDeletionRecheckPeriod)
+				return nil
+			}
+			if err != nil {
+				// such as rejected by an admission webhook
+				s.unpublishURL(svc)
+				return errors.Wrapf(err, "failed to create ingress %s/%s", ingress.Namespace, ingress.Name)
+			}
+		} else {
+			applied, err = ingresses.Update(s.ctx, &ingress, metav1.UpdateOptions{})
+			if err != nil {
+				return errors.Wrapf(err, "failed to update ingress %s/%s", ingress.Namespace, ingress.Name)
+			}
+		}
+	}
+	if split != nil {
+		err = s.applyCanaryIngress(buildCanaryIngress(&ingress, split))
 		if err != nil {
-			return errors.Wrapf(err, "failed to create ingress %s/%s", ingress.Namespace, ingress.Name)
+			return err
 		}
-	} else {
-		_, err := ingresses.Update(s.ctx, &ingress, metav1.UpdateOptions{})
+	}
+	// the backend service is owned by the ingress of another namespace, to be deleted with it
+	if ingressNamespace != svc.Namespace {
+		err = s.applyExternalNameService(buildExternalNameService(applied, backendName, svc, backendPort, servicePort))
 		if err != nil {
-			return errors.Wrapf(err, "failed to update ingress %s/%s", ingress.Namespace, ingress.Name)
+			return err
 		}
 	}
+	if s.dnsChecker != nil {
+		hosts := make([]string, 0, len(rules))
+		for _, rule := range rules {
+			hosts = append(hosts, rule.Host)
+		}
+		s.dnsChecker.check(s.ctx, s.client, svc, hosts, status)
+	}
+	// create or update the http route in transition mode, and delete the others
+	routeName := ""
+	if s.httpRoute {
+		route := buildHTTPRoute(&ingress, svc, s.gatewayName, s.gatewayNamespace)
+		if split != nil {
+			splitHTTPRoute(route, split)
+		}
+		if s.httpRouteTemplate != nil {
+			data := newTemplateData(svc, &ingress, hostName, tlsSecretName)
+			data.Annotations = route.GetAnnotations()
+			data.GatewayName, data.GatewayNamespace = s.gatewayName, s.gatewayNamespace
+			route, err = renderHTTPRoute(s.httpRouteTemplate, data, route)
+			if err != nil {
+				return errors.Wrapf(err, "failed to render the http route of service %s/%s", svc.Namespace, svc.Name)
+			}
+		}
+		err = applyHTTPRoute(s.ctx, s.dynamicClient, route)
+		if err != nil {
+			return err
+		}
+		routeName = route.GetName()
+	} else {
+		urlOwner = URLOwnerIngress
+	}
+	s.cleanHTTPRoutes(svc, routeName)
 	// build the patch for the service annotations
+	// the gateway terminates TLS for the http routes
+	// the ports of the ingress controller do not apply to the gateway
 	clone := svc.DeepCopy()
-	if !s.http && tlsSecretName != "" {
-		err = addServiceAnnotationWithProtocol(clone, hostName, path, "https")
-	} else {
-		err = addServiceAnnotationWithProtocol(clone, hostName, path, "http")
+	setAdoptedIngress(clone, adopted)
+	httpPort, httpsPort := s.httpPort, s.httpsPort
+	if urlOwner == URLOwnerHTTPRoute {
+		httpPort, httpsPort = 0, 0
+	}
+	protocol, urlPort := "http", httpPort
+	if !s.http && !plainHTTP && (tlsSecretName != "" || s.tlsWithoutSecret || passthrough || urlOwner == URLOwnerHTTPRoute) {
+		protocol, urlPort = "https", httpsPort
+	}
+	if redirect {
+		kept, err := s.applyRedirectIngress(&ingress, previous, oldHost, buildURL(hostName, urlPort, "", protocol)+"$request_uri")
+		if !kept {
+			s.existing[svcKey] = entries[:len(entries)-1]
+		}
+		if err != nil {
+			return err
+		}
 	}
+	// the URL stays pending until the ingress controller admits the ingress
+	urlHost := hostName
+	if urlOwner == URLOwnerIngress && !s.isIngressAdmitted(svc, applied) {
+		urlHost = ""
+	}
+	err = addServiceAnnotationWithPort(clone, urlHost, urlPort, path, protocol)
 	if err != nil {
 		return errors.Wrapf(err, "failed to add annotation to service %s/%s",
 			svc.Namespace, svc.Name)
 	}
+	clone.Annotations[ExposeAnnotationKey] = applyTrailingSlash(s.trailingSlashPolicy, clone.Annotations[ExposeAnnotationKey])
+	// the plain HTTP URL is published along the HTTPS one
+	if allowHTTP && protocol == "https" {
+		setHTTPURL(clone, urlHost, httpPort, path)
+		clone.Annotations[ExposeHTTPURLAnnotationKey] = applyTrailingSlash(s.trailingSlashPolicy, clone.Annotations[ExposeHTTPURLAnnotationKey])
+	} else {
+		delete(clone.Annotations, ExposeHTTPURLAnnotationKey)
+	}
+	// the paths of the other ports of the service are published along the exposed URL
+	var urls map[string]string
+	if urlHost != "" {
+		urls = ingressURLs(svc, rules, hostName, urlPort, protocol,
+			servicePort, backendPort, clone.Annotations[ExposeAnnotationKey])
+		for key, value := range urls {
+			urls[key] = applyTrailingSlash(s.trailingSlashPolicy, value)
+		}
+	}
+	err = setServiceURLs(clone, urls)
+	if err != nil {
+		return err
+	}
+	// the URLs on all the domains, the exposed one first
+	var domainURLs []string
+	if urlHost != "" && len(domainHostNames) > 0 {
+		domainURLs = append(domainURLs, clone.Annotations[ExposeAnnotationKey])
+		for _, domainHostName := range domainHostNames {
+			domainURLs = append(domainURLs, applyTrailingSlash(s.trailingSlashPolicy, buildURL(domainHostName, urlPort, path, protocol)))
+		}
+	}
+	err = setDomainURLs(clone, domainURLs)
+	if err != nil {
+		return err
+	}
+	if group != "" {
+		s.publishGroupURLs(clone, members)
+	} else {
+		delete(clone.Annotations, ExposeGroupURLsAnnotationKey)
+	}
+	setHTTP3Status(clone, http3Status)
+	if s.gkeConfigs {
+		err = s.applyGKEConfigs(&ingress, svc, clone, !s.http && len(tlsSpec) > 0)
+		if err != nil {
+			return err
+		}
+	}
 
 	patch, err := createServicePatch(svc, clone)
 	if err != nil {
@@ -387,27 +1336,154 @@ func (s *IngressStrategy) Add(svc *v1.Service) error {
 	return nil
 }
 
+// ingressHost is a host of the ingress, with the name and secret of its certificate
+type ingressHost struct {
+	name      string
+	tlsName   string
+	tlsSecret string
+}
+
+// parseAdditionalHosts parses the comma separated "host[=secret]" list
+// the hosts without secret use the default one
+func parseAdditionalHosts(value, defaultSecret string) ([]ingressHost, error) {
+	var hosts []ingressHost
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if errs := validation.IsDNS1123Subdomain(strings.TrimPrefix(name, "*.")); len(errs) > 0 {
+			return nil, errors.Errorf("invalid host \"%s\": %s", name, strings.Join(errs, ", "))
+		}
+		secret := defaultSecret
+		if len(parts) == 2 {
+			secret = strings.TrimSpace(parts[1])
+			if errs := validation.IsDNS1123Subdomain(secret); len(errs) > 0 {
+				return nil, errors.Errorf("invalid secret \"%s\" of host \"%s\": %s", secret, name, strings.Join(errs, ", "))
+			}
+		}
+		hosts = append(hosts, ingressHost{name: name, tlsName: name, tlsSecret: secret})
+	}
+	return hosts, nil
+}
+
+// groupIngressTLS groups the hosts sharing a secret into one TLS entry
+// the entries are sorted by secret, the hosts keep their order, the hosts without secret have no TLS
+func groupIngressTLS(hosts []ingressHost) []networkingv1.IngressTLS {
+	var secrets []string
+	bySecret := map[string][]string{}
+	seen := map[string]bool{}
+	for _, h := range hosts {
+		if h.tlsSecret == "" || seen[h.tlsSecret+"/"+h.tlsName] {
+			continue
+		}
+		seen[h.tlsSecret+"/"+h.tlsName] = true
+		if _, ok := bySecret[h.tlsSecret]; !ok {
+			secrets = append(secrets, h.tlsSecret)
+		}
+		bySecret[h.tlsSecret] = append(bySecret[h.tlsSecret], h.tlsName)
+	}
+	sort.Strings(secrets)
+	var tls []networkingv1.IngressTLS
+	for _, secret := range secrets {
+		tls = append(tls, networkingv1.IngressTLS{
+			Hosts:      bySecret[secret],
+			SecretName: secret,
+		})
+	}
+	return tls
+}
+
+// sortIngressSpec sorts the rules by host, their paths by path, and the hosts of the TLS entries
+// the generated ingresses do not depend on the order of the hosts, so they are not updated when it changes
+func sortIngressSpec(spec *networkingv1.IngressSpec) {
+	sort.SliceStable(spec.Rules, func(i, j int) bool {
+		return spec.Rules[i].Host < spec.Rules[j].Host
+	})
+	for _, rule := range spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		paths := rule.HTTP.Paths
+		sort.SliceStable(paths, func(i, j int) bool {
+			return paths[i].Path < paths[j].Path
+		})
+	}
+	for _, tls := range spec.TLS {
+		sort.Strings(tls.Hosts)
+	}
+}
+
+// addConfigMapAnnotations adds the ingress annotations held by the "config-map/key" reference
+func (s *IngressStrategy) addConfigMapAnnotations(svc *v1.Service, from string, annotations map[string]string) error {
+	parts := strings.SplitN(from, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return errors.Errorf("annotation \"%s\" in service %s/%s must be \"config-map/key\", got \"%s\"",
+			IngressAnnotationsFromAnnotationKey, svc.Namespace, svc.Name, from)
+	}
+	cm, err := s.client.CoreV1().ConfigMaps(svc.Namespace).Get(s.ctx, parts[0], metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get config map %s/%s referenced by service %s",
+			svc.Namespace, parts[0], svc.Name)
+	}
+	value, ok := cm.Data[parts[1]]
+	if !ok {
+		return errors.Errorf("config map %s/%s referenced by service %s has no key \"%s\"",
+			svc.Namespace, parts[0], svc.Name, parts[1])
+	}
+	err = parseAnnotationsYAML(svc, IngressAnnotationsFromAnnotationKey, value, s.strictAnnotations, annotations)
+	if err != nil {
+		return errors.Wrapf(err, "invalid key \"%s\" of config map %s/%s", parts[1], svc.Namespace, parts[0])
+	}
+	return nil
+}
+
 // Clean is called when an exposed service is unexposed
-// Deletes the related ingress
+// Deletes the related ingress, or keeps it during the grace period
 // Cleans various ingress annotations
 func (s *IngressStrategy) Clean(svc *v1.Service) error {
-	svcKey := fmt.Sprintf("%s/%s", svc.Namespace, svc.Name)
-	for _, name := range s.existing[svcKey] {
-		existing, err := s.client.NetworkingV1().Ingresses(svc.Namespace).Get(s.ctx, name, metav1.GetOptions{})
-		if err == nil {
-			exKey, del := getIngressService(existing)
-			if del || exKey == svcKey {
-				deleteIngress(nil, s.client, existing)
+	svcKey := serviceKey(svc.Namespace, svc.Name)
+	s.dnsChecker.forget(svc)
+	s.annotationDenylist.forget(svc)
+	delete(s.hostConflicts, svcKey)
+	clone := svc.DeepCopy()
+	changed := false
+	var pending []string
+	for _, entry := range s.existing[svcKey] {
+		kept := false
+		namespace, name := parseIngressEntry(svc.Namespace, entry)
+		existing, err := s.client.NetworkingV1().Ingresses(namespace).Get(s.ctx, name, metav1.GetOptions{})
+		if err == nil && isGroupIngress(existing) {
+			s.leaveGroupIngress(existing, svc)
+		} else if err == nil {
+			exKey, del := getIngressService(existing, s.provider)
+			if del {
+				deleteIngress(s.ctx, s.client, existing, s.neverDelete, s.provider, s.deletePropagation)
+			} else if exKey == svcKey {
+				kept = s.deleteIngressAfterGrace(existing)
 			}
 		} else if !apierrors.IsNotFound(err) {
 			klog.Errorf("error when getting ingress %s/%s: %s",
-				svc.Namespace, name, err)
+				namespace, name, err)
 		}
+		if kept {
+			// the GKE configs are deleted with the ingress
+			pending = append(pending, entry)
+		} else {
+			s.cleanGKEConfigs(namespace, name)
+		}
+		changed = removeBackendConfigAnnotation(clone, name) || changed
 	}
-	delete(s.existing, svcKey)
+	if len(pending) > 0 {
+		s.existing[svcKey] = pending
+	} else {
+		delete(s.existing, svcKey)
+	}
+	s.cleanHTTPRoutes(svc, "")
 
-	clone := svc.DeepCopy()
-	if !removeServiceAnnotation(clone) {
+	if !removeServiceAnnotation(clone) && !changed {
 		return nil
 	}
 
@@ -431,30 +1507,80 @@ func (s *IngressStrategy) Clean(svc *v1.Service) error {
 // Delete is called when an exposed service is deleted
 // Delete the related ingresses
 func (s *IngressStrategy) Delete(svc *v1.Service) error {
-	svcKey := fmt.Sprintf("%s/%s", svc.Namespace, svc.Name)
-	for _, name := range s.existing[svcKey] {
-		existing, err := s.client.NetworkingV1().Ingresses(svc.Namespace).Get(s.ctx, name, metav1.GetOptions{})
-		if err == nil {
-			exKey, del := getIngressService(existing)
-			if del || exKey == svcKey {
-				deleteIngress(nil, s.client, existing)
-			}
-		} else if !apierrors.IsNotFound(err) {
-			klog.Errorf("error when getting ingress %s/%s: %s",
-				svc.Namespace, name, err)
-		}
+	delete(s.serviceUIDs, serviceKey(svc.Namespace, svc.Name))
+	s.dnsChecker.forget(svc)
+	s.annotationDenylist.forget(svc)
+	delete(s.hostConflicts, serviceKey(svc.Namespace, svc.Name))
+	s.deleteServiceIngresses(svc)
+	s.cleanHTTPRoutes(svc, "")
+
+	return nil
+}
+
+// deleteServiceIngresses deletes the ingresses generated for the service
+func (s *IngressStrategy) deleteServiceIngresses(svc *v1.Service) {
+	svcKey := serviceKey(svc.Namespace, svc.Name)
+	for _, entry := range s.existing[svcKey] {
+		namespace, name := parseIngressEntry(svc.Namespace, entry)
+		s.deleteServiceIngress(svc, namespace, name)
 	}
 	delete(s.existing, svcKey)
+}
 
-	return nil
+// deleteServiceIngress deletes an ingress generated for the service, unless another service claimed it since,
+// the service only leaves the ingress of its group
+func (s *IngressStrategy) deleteServiceIngress(svc *v1.Service, namespace, name string) {
+	svcKey := serviceKey(svc.Namespace, svc.Name)
+	existing, err := s.client.NetworkingV1().Ingresses(namespace).Get(s.ctx, name, metav1.GetOptions{})
+	if err == nil && isGroupIngress(existing) {
+		s.leaveGroupIngress(existing, svc)
+	} else if err == nil {
+		exKey, del := getIngressService(existing, s.provider)
+		if del || exKey == svcKey {
+			deleteIngress(s.ctx, s.client, existing, s.neverDelete, s.provider, s.deletePropagation)
+		}
+	} else if !apierrors.IsNotFound(err) {
+		klog.Errorf("error when getting ingress %s/%s: %s",
+			namespace, name, err)
+	}
+	s.cleanGKEConfigs(namespace, name)
 }
 
-func deleteIngress(ctx context.Context, client kubernetes.Interface, ingress *networkingv1.Ingress) {
-	options := metav1.DeleteOptions{
-		Preconditions: &metav1.Preconditions{
-			ResourceVersion: &ingress.ResourceVersion,
-		},
+// cleanHTTPRoutes deletes the HTTP routes generated for the service except the one to keep
+func (s *IngressStrategy) cleanHTTPRoutes(svc *v1.Service, keep string) {
+	if s.dynamicClient == nil {
+		return
 	}
+	svcKey := serviceKey(svc.Namespace, svc.Name)
+	for _, name := range s.existingRoutes[svcKey] {
+		if name != keep {
+			deleteHTTPRoute(s.ctx, s.dynamicClient, svc.Namespace, name, s.neverDelete, s.provider, s.deletePropagation)
+		}
+	}
+	if keep == "" {
+		delete(s.existingRoutes, svcKey)
+	} else {
+		if s.existingRoutes == nil {
+			s.existingRoutes = map[string][]string{}
+		}
+		s.existingRoutes[svcKey] = []string{keep}
+	}
+}
+
+// usesWildcardCertificates tells if the controller manages the wildcard certificates of the domains
+func (s *IngressStrategy) usesWildcardCertificates() bool {
+	return (s.tlsAcme || usesDomainAcme(s.domainTLSPolicies)) && s.acmeChallengeType == AcmeChallengeDNS01
+}
+
+// deleteIngress deletes the ingress with the propagation policy, or releases it with neverDelete
+func deleteIngress(ctx context.Context, client kubernetes.Interface, ingress *networkingv1.Ingress, neverDelete bool, provider ProviderLabel, propagation *metav1.DeletionPropagation) {
+	if isUnmanagedIngress(ingress, "deleting") {
+		return
+	} else if neverDelete {
+		releaseIngress(ctx, client, ingress, provider)
+		return
+	}
+	options := DeleteOptions(propagation, ingress.ResourceVersion)
 	klog.Infof("cleaning the ingress %s/%s", ingress.Namespace, ingress.Name)
 	err := client.NetworkingV1().Ingresses(ingress.Namespace).Delete(ctx, ingress.Name, options)
 	if err != nil {
@@ -463,14 +1589,24 @@ func deleteIngress(ctx context.Context, client kubernetes.Interface, ingress *ne
 	}
 }
 
-func getIngressService(ingress *networkingv1.Ingress) (string, bool) {
-	if ingress.Labels["provider"] != "fabric8" || ingress.Annotations["fabric8.io/generated-by"] != "exposecontroller" {
+func getIngressService(ingress *networkingv1.Ingress, provider ProviderLabel) (string, bool) {
+	return getObjectService(ingress, provider)
+}
+
+// getObjectService returns the key of the service an object generated by the controller belongs to,
+// or tells to delete the object if it belongs to no service, the ingress of a group belongs to its first owner
+func getObjectService(obj metav1.Object, provider ProviderLabel) (string, bool) {
+	labels := obj.GetLabels()
+	ownerReferences := obj.GetOwnerReferences()
+	if !provider.Matches(labels) || !provider.IsGenerated(obj.GetAnnotations()) {
 		return "", false
-	} else if len(ingress.OwnerReferences) != 1 {
+	} else if name := labels[ExposedServiceLabelKey]; name != "" && len(ownerReferences) == 0 {
+		return serviceKey(exposedServiceNamespace(obj), name), false
+	} else if len(ownerReferences) != 1 && (len(ownerReferences) == 0 || obj.GetAnnotations()[ExposeGroupAnnotationKey] == "") {
 		return "", true
-	} else if owner := ingress.OwnerReferences[0]; owner.Kind != ServiceKind || owner.APIVersion != ServiceAPIVersion {
+	} else if owner := ownerReferences[0]; owner.Kind != ServiceKind || owner.APIVersion != ServiceAPIVersion {
 		return "", true
 	} else {
-		return fmt.Sprintf("%s/%s", ingress.Namespace, owner.Name), false
+		return serviceKey(obj.GetNamespace(), owner.Name), false
 	}
 }
@@ -2,14 +2,27 @@ package exposestrategy
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
 	"testing"
 
 	"k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -125,11 +138,25 @@ func TestGetIngressService(t *testing.T) {
 			}},
 		},
 		del: true,
+	}, {
+		name: "service label",
+		meta: metav1.ObjectMeta{
+			Name:      "test-ingress",
+			Namespace: "test-namespace",
+			Labels: map[string]string{
+				"provider":             "fabric8",
+				ExposedServiceLabelKey: "test-service",
+			},
+			Annotations: map[string]string{
+				"fabric8.io/generated-by": "exposecontroller",
+			},
+		},
+		svc: "test-namespace/test-service",
 	}}
 	for _, example := range examples {
 		svc, del := getIngressService(&networkingv1.Ingress{
 			ObjectMeta: example.meta,
-		})
+		}, LegacyProviderLabel)
 		assert.Equal(t, example.svc, svc, example.name)
 		assert.Equal(t, example.del, del, example.name)
 	}
@@ -1377,3 +1404,835 @@ func TestIngressStrategy_update(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 0, len(ingresses.Items))
 }
+
+func TestIngressStrategy_PortMapping(t *testing.T) {
+	svc1 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc1",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key: ExposeAnnotation.Value,
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Port: 8080,
+			}},
+		},
+	}
+	svc2 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc2",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key:     ExposeAnnotation.Value,
+				PortMappingAnnotationKey: "8080->8000",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Port: 8080,
+			}},
+		},
+	}
+	svc3 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc3",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key: ExposeAnnotation.Value,
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Port: 9090,
+			}},
+		},
+	}
+	client := fake.NewSimpleClientset(svc1, svc2, svc3)
+
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:     "ingress",
+		Namespace:   "main",
+		Domain:      "my-domain.com",
+		PortMapping: "8080->80, 8443->443",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+
+	ctx := context.Background()
+	expected := map[string]int32{
+		"svc1": 80,
+		"svc2": 8000,
+		"svc3": 9090,
+	}
+	for _, svc := range []*v1.Service{svc1, svc2, svc3} {
+		err = strategy.Add(svc)
+		require.NoError(t, err, svc.Name)
+		ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, svc.Name, metav1.GetOptions{})
+		if assert.NoError(t, err, svc.Name) {
+			backend := ingress.Spec.Rules[0].HTTP.Paths[0].Backend
+			assert.Equal(t, expected[svc.Name], backend.Service.Port.Number, svc.Name)
+		}
+	}
+
+	_, err = NewIngressStrategy(nil, client, &Config{
+		Exposer:     "ingress",
+		Namespace:   "main",
+		Domain:      "my-domain.com",
+		PortMapping: "8080=>80",
+	})
+	assert.Error(t, err)
+}
+
+func TestIngressStrategy_NamedExposePort(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key:    ExposeAnnotation.Value,
+				ExposePortAnnotationKey: "web",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name: "metrics",
+				Port: 9090,
+			}, {
+				Name: "web",
+				Port: 8080,
+			}},
+		},
+	}
+	other := service.DeepCopy()
+	other.Name = "other"
+	other.Annotations[ExposePortAnnotationKey] = "unknown"
+	client := fake.NewSimpleClientset(service, other)
+
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:   "ingress",
+		Namespace: "main",
+		Domain:    "my-domain.com",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(service))
+
+	ctx := context.Background()
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		backend := ingress.Spec.Rules[0].HTTP.Paths[0].Backend
+		assert.Equal(t, networkingv1.ServiceBackendPort{Name: "web"}, backend.Service.Port)
+	}
+
+	err = strategy.Add(other)
+	assert.Error(t, err, "unknown port name")
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "other", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "no ingress for an unknown port name")
+}
+
+func TestIngressStrategy_SkipOwnerReferences(t *testing.T) {
+	svc1 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc1",
+			UID:       "svc1-uid",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key: ExposeAnnotation.Value,
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Port: 80,
+			}},
+		},
+	}
+	svc2 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc2",
+			UID:       "svc2-uid",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key:         ExposeAnnotation.Value,
+				OwnerReferencesAnnotationKey: "true",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Port: 80,
+			}},
+		},
+	}
+	client := fake.NewSimpleClientset(svc1, svc2)
+
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:             "ingress",
+		Namespace:           "main",
+		Domain:              "my-domain.com",
+		SkipOwnerReferences: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(svc1))
+	require.NoError(t, strategy.Add(svc2))
+
+	ctx := context.Background()
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc1", metav1.GetOptions{})
+	if assert.NoError(t, err, "svc1") {
+		assert.Empty(t, ingress.OwnerReferences, "svc1")
+		assert.Equal(t, map[string]string{
+			"provider":             "fabric8",
+			ExposedServiceLabelKey: "svc1",
+		}, ingress.Labels, "svc1")
+	}
+	ingress, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc2", metav1.GetOptions{})
+	if assert.NoError(t, err, "svc2") {
+		assert.Equal(t, []metav1.OwnerReference{{
+			APIVersion: "v1",
+			Kind:       "Service",
+			Name:       "svc2",
+			UID:        "svc2-uid",
+		}}, ingress.OwnerReferences, "svc2")
+		assert.Equal(t, map[string]string{
+			"provider": "fabric8",
+		}, ingress.Labels, "svc2")
+	}
+
+	// the ingress without owner references is kept by a new sync, then cleaned
+	require.NoError(t, strategy.Sync())
+	assert.Equal(t, []string{"svc1"}, strategy.(*IngressStrategy).existing["main/svc1"])
+	require.NoError(t, strategy.Clean(svc1))
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc1", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "svc1 cleaned")
+}
+
+func TestIngressStrategy_SyncLabelTracked(t *testing.T) {
+	labelTracked := func(name string) *networkingv1.Ingress {
+		return &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "main",
+				Name:      name,
+				Labels: map[string]string{
+					"provider":             "fabric8",
+					ExposedServiceLabelKey: name,
+				},
+				Annotations: map[string]string{
+					"fabric8.io/generated-by": "exposecontroller",
+				},
+			},
+		}
+	}
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "kept",
+		},
+	}
+	client := fake.NewSimpleClientset(svc, labelTracked("kept"), labelTracked("gone"))
+
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:             "ingress",
+		Namespace:           "main",
+		Domain:              "my-domain.com",
+		SkipOwnerReferences: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+
+	ctx := context.Background()
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "kept", metav1.GetOptions{})
+	assert.NoError(t, err, "the ingress of an existing service is kept")
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "gone", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "the ingress of a deleted service is deleted")
+	assert.Equal(t, map[string][]string{
+		"main/kept": {"kept"},
+	}, strategy.(*IngressStrategy).existing)
+}
+
+func TestIngressStrategy_AdditionalHosts(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				"fabric8.io/use.internal.domain": "both",
+				AdditionalHostsAnnotationKey:     "www.example.com, api.example.com=example-tls, example.com=example-tls",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 80}},
+		},
+	}
+	client := fake.NewSimpleClientset(service)
+
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:        "ingress",
+		Namespace:      "main",
+		Domain:         "my-domain.com",
+		InternalDomain: "my-internal-domain.com",
+		TLSSecretName:  "my-tls-secret",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(service))
+
+	ctx := context.Background()
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	hosts := []string{}
+	for _, rule := range ingress.Spec.Rules {
+		hosts = append(hosts, rule.Host)
+	}
+	assert.Equal(t, []string{
+		"api.example.com",
+		"example.com",
+		"svc.main.my-domain.com",
+		"svc.main.my-internal-domain.com",
+		"www.example.com",
+	}, hosts, "sorted by host")
+	assert.Equal(t, []networkingv1.IngressTLS{{
+		Hosts:      []string{"api.example.com", "example.com"},
+		SecretName: "example-tls",
+	}, {
+		Hosts:      []string{"svc.main.my-domain.com", "svc.main.my-internal-domain.com", "www.example.com"},
+		SecretName: "my-tls-secret",
+	}}, ingress.Spec.TLS)
+	service, err = client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://svc.main.my-domain.com", service.Annotations[ExposeAnnotationKey])
+
+	// the order of the hosts does not change the ingress
+	client.ClearActions()
+	service.Annotations[AdditionalHostsAnnotationKey] = "example.com=example-tls, www.example.com, api.example.com=example-tls"
+	require.NoError(t, strategy.Add(service))
+	for _, action := range client.Actions() {
+		assert.False(t, action.Matches("update", "ingresses"), "unexpected update of the ingress")
+	}
+
+	service.Annotations[AdditionalHostsAnnotationKey] = "not a host"
+	assert.Error(t, strategy.Add(service), "invalid host")
+}
+
+func TestGroupIngressTLS(t *testing.T) {
+	assert.Nil(t, groupIngressTLS([]ingressHost{{name: "a.example.com", tlsName: "a.example.com"}}), "no secret")
+	assert.Equal(t, []networkingv1.IngressTLS{{
+		Hosts:      []string{"*.example.com"},
+		SecretName: "wildcard",
+	}}, groupIngressTLS([]ingressHost{
+		{name: "a.example.com", tlsName: "*.example.com", tlsSecret: "wildcard"},
+		{name: "b.example.com", tlsName: "*.example.com", tlsSecret: "wildcard"},
+	}), "the wildcard host is listed once")
+}
+
+func TestIngressStrategy_ProviderLabel(t *testing.T) {
+	legacy := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "main",
+			Name:            "svc1",
+			ResourceVersion: "1",
+			Labels: map[string]string{
+				"provider": "fabric8",
+			},
+			Annotations: map[string]string{
+				"fabric8.io/generated-by": "exposecontroller",
+			},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "Service",
+				Name:       "svc1",
+			}},
+		},
+	}
+	orphan := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "orphan",
+			Labels: map[string]string{
+				"team": "platform",
+			},
+			Annotations: map[string]string{
+				"fabric8.io/generated-by": "exposecontroller",
+			},
+		},
+	}
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc1",
+			UID:       "svc1-uid",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 80}},
+		},
+	}
+	client := fake.NewSimpleClientset(svc, legacy, orphan)
+
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:       "ingress",
+		Namespace:     "main",
+		Domain:        "my-domain.com",
+		ProviderLabel: ProviderLabel{Key: "team", Value: "platform"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	assert.Equal(t, map[string][]string{
+		"main/svc1": {"svc1"},
+	}, strategy.(*IngressStrategy).existing, "the legacy ingress is recognised")
+
+	ctx := context.Background()
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "orphan", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "the ingress of no service is deleted")
+
+	require.NoError(t, strategy.Add(svc))
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc1", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"team": "platform",
+	}, ingress.Labels, "the legacy ingress is adopted")
+}
+
+func TestIngressStrategy_GeneratedBy(t *testing.T) {
+	labelTracked := func(name, generatedBy string) *networkingv1.Ingress {
+		return &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "main",
+				Name:      name,
+				Labels: map[string]string{
+					"provider":             "fabric8",
+					ExposedServiceLabelKey: name,
+				},
+				Annotations: map[string]string{
+					GeneratedByAnnotationKey: generatedBy,
+				},
+			},
+		}
+	}
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc1",
+			UID:       "svc1-uid",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 80}},
+		},
+	}
+	client := fake.NewSimpleClientset(svc, labelTracked("gone", "exposecontroller-blue"), labelTracked("other", "exposecontroller"))
+
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:             "ingress",
+		Namespace:           "main",
+		Domain:              "my-domain.com",
+		SkipOwnerReferences: true,
+		ProviderLabel:       ProviderLabel{Key: "provider", Value: "fabric8", GeneratedBy: "exposecontroller-blue"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+
+	ctx := context.Background()
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "gone", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "the ingress of the instance is deleted with its service")
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "other", metav1.GetOptions{})
+	assert.NoError(t, err, "the ingress of another instance is kept")
+	assert.Empty(t, strategy.(*IngressStrategy).existing)
+
+	require.NoError(t, strategy.Add(svc))
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc1", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "exposecontroller-blue", ingress.Annotations[GeneratedByAnnotationKey])
+
+	// the ingress of the same name generated by another instance is not overwritten
+	other := svc.DeepCopy()
+	other.Name = "other"
+	other.UID = "other-uid"
+	_, err = client.CoreV1().Services("main").Create(ctx, other, metav1.CreateOptions{})
+	require.NoError(t, err)
+	err = strategy.Add(other)
+	assert.EqualError(t, err, "ingress main/other of service main/other is generated by exposecontroller")
+	assert.True(t, errors.Is(err, ErrConflict))
+	ingress, err = client.NetworkingV1().Ingresses("main").Get(ctx, "other", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, labelTracked("other", "exposecontroller"), ingress)
+}
+
+// newPagingServer serves the ingresses of namespace main by page like the API server
+// the continue token is the index of the next ingress, the requests are counted
+func newPagingServer(count int) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/apis/networking.k8s.io/v1/namespaces/main/ingresses" {
+			http.NotFound(res, req)
+			return
+		}
+		atomic.AddInt32(&requests, 1)
+		start, _ := strconv.Atoi(req.URL.Query().Get("continue"))
+		limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+		end := count
+		if limit > 0 && start+limit < count {
+			end = start + limit
+		}
+		list := networkingv1.IngressList{
+			TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "IngressList"},
+		}
+		if end < count {
+			list.Continue = strconv.Itoa(end)
+		}
+		for i := start; i < end; i++ {
+			name := fmt.Sprintf("svc%d", i)
+			list.Items = append(list.Items, networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "main",
+					Name:      name,
+					Labels: map[string]string{
+						"provider": "fabric8",
+					},
+					Annotations: map[string]string{
+						"fabric8.io/generated-by": "exposecontroller",
+					},
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: "v1",
+						Kind:       "Service",
+						Name:       name,
+					}},
+				},
+			})
+		}
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(&list)
+	}))
+	return server, &requests
+}
+
+func newPagingStrategy(t testing.TB, server *httptest.Server) *IngressStrategy {
+	// no client side rate limit
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL, QPS: -1})
+	require.NoError(t, err)
+	strategy, err := NewIngressStrategy(context.Background(), client, &Config{
+		Exposer:   "ingress",
+		Namespace: "main",
+		Domain:    "my-domain.com",
+	})
+	require.NoError(t, err)
+	return strategy.(*IngressStrategy)
+}
+
+func TestIngressStrategy_SyncPaginated(t *testing.T) {
+	server, requests := newPagingServer(1234)
+	defer server.Close()
+	strategy := newPagingStrategy(t, server)
+
+	require.NoError(t, strategy.Sync())
+	assert.Equal(t, int32(3), atomic.LoadInt32(requests), "the ingresses are listed by page of 500")
+	assert.Len(t, strategy.existing, 1234)
+	assert.Equal(t, []string{"svc1233"}, strategy.existing["main/svc1233"])
+}
+
+// BenchmarkIngressStrategy_Sync compares the allocations of syncing 10k ingresses
+// listed all at once and by page
+func BenchmarkIngressStrategy_Sync(b *testing.B) {
+	server, _ := newPagingServer(10000)
+	defer server.Close()
+	for _, pageSize := range []int64{0, listPageSize} {
+		b.Run(fmt.Sprintf("page=%d", pageSize), func(b *testing.B) {
+			strategy := newPagingStrategy(b, server)
+			strategy.pageSize = pageSize
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				err := strategy.Sync()
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestIngressStrategy_HTTPRoute(t *testing.T) {
+	svc1 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc1",
+			UID:       "svc1-uid",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key:    ExposeAnnotation.Value,
+				ExposePortAnnotationKey: "web",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name: "web",
+				Port: 8080,
+			}},
+		},
+	}
+	svc2 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc2",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key:  ExposeAnnotation.Value,
+				URLOwnerAnnotationKey: URLOwnerHTTPRoute,
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Port: 80,
+			}},
+		},
+	}
+	client := fake.NewSimpleClientset(svc1, svc2)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			HTTPRouteResource: "HTTPRouteList",
+		})
+
+	_, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:       "ingress",
+		Namespace:     "main",
+		Domain:        "my-domain.com",
+		HTTPRoute:     true,
+		DynamicClient: dynamicClient,
+	})
+	assert.Error(t, err, "missing gateway")
+
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:          "ingress",
+		Namespace:        "main",
+		Domain:           "my-domain.com",
+		HTTPRoute:        true,
+		GatewayName:      "gateway",
+		GatewayNamespace: "gateway-system",
+		DynamicClient:    dynamicClient,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(svc1))
+	require.NoError(t, strategy.Add(svc2))
+
+	ctx := context.Background()
+	routes := dynamicClient.Resource(HTTPRouteResource).Namespace("main")
+	route, err := routes.Get(ctx, "svc1", metav1.GetOptions{})
+	if assert.NoError(t, err, "svc1 route") {
+		expected := map[string]interface{}{
+			"parentRefs": []interface{}{map[string]interface{}{
+				"name":      "gateway",
+				"namespace": "gateway-system",
+			}},
+			"hostnames": []interface{}{"svc1.main.my-domain.com"},
+			"rules": []interface{}{map[string]interface{}{
+				"matches": []interface{}{map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":  "PathPrefix",
+						"value": "/",
+					},
+				}},
+				"backendRefs": []interface{}{map[string]interface{}{
+					"name": "svc1",
+					"port": int64(8080),
+				}},
+			}},
+		}
+		assert.Equal(t, expected, route.Object["spec"], "svc1 route")
+		assert.Equal(t, []metav1.OwnerReference{{
+			APIVersion: "v1",
+			Kind:       "Service",
+			Name:       "svc1",
+			UID:        "svc1-uid",
+		}}, route.GetOwnerReferences(), "svc1 route")
+	}
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc1", metav1.GetOptions{})
+	assert.NoError(t, err, "svc1 ingress")
+
+	service, err := client.CoreV1().Services("main").Get(ctx, "svc1", metav1.GetOptions{})
+	if assert.NoError(t, err, "svc1") {
+		assert.Equal(t, "http://svc1.main.my-domain.com", service.Annotations[ExposeAnnotationKey], "svc1")
+	}
+	service, err = client.CoreV1().Services("main").Get(ctx, "svc2", metav1.GetOptions{})
+	if assert.NoError(t, err, "svc2") {
+		assert.Equal(t, "https://svc2.main.my-domain.com", service.Annotations[ExposeAnnotationKey], "svc2")
+	}
+
+	require.NoError(t, strategy.Clean(svc1))
+	_, err = routes.Get(ctx, "svc1", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "svc1 route cleaned")
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc1", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "svc1 ingress cleaned")
+	_, err = routes.Get(ctx, "svc2", metav1.GetOptions{})
+	assert.NoError(t, err, "svc2 route")
+}
+
+func TestIngressStrategy_HTTPRouteDisabled(t *testing.T) {
+	generatedRoute := func(name string) *unstructured.Unstructured {
+		route := &unstructured.Unstructured{}
+		route.SetAPIVersion("gateway.networking.k8s.io/v1")
+		route.SetKind("HTTPRoute")
+		route.SetNamespace("main")
+		route.SetName(name)
+		route.SetLabels(map[string]string{
+			"provider": "fabric8",
+		})
+		route.SetAnnotations(map[string]string{
+			"fabric8.io/generated-by": "exposecontroller",
+		})
+		route.SetOwnerReferences([]metav1.OwnerReference{{
+			APIVersion: "v1",
+			Kind:       "Service",
+			Name:       name,
+		}})
+		return route
+	}
+	svc1 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc1",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key: ExposeAnnotation.Value,
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Port: 80,
+			}},
+		},
+	}
+	svc2 := svc1.DeepCopy()
+	svc2.Name = "svc2"
+	svc2.Annotations[URLOwnerAnnotationKey] = "gateway"
+	client := fake.NewSimpleClientset(svc1, svc2)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			HTTPRouteResource: "HTTPRouteList",
+		}, generatedRoute("svc1"), generatedRoute("svc3"))
+
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:       "ingress",
+		Namespace:     "main",
+		Domain:        "my-domain.com",
+		DynamicClient: dynamicClient,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	assert.Error(t, strategy.Add(svc2), "invalid url owner")
+
+	// the routes left from the transition mode are deleted
+	require.NoError(t, strategy.Add(svc1))
+	ctx := context.Background()
+	routes := dynamicClient.Resource(HTTPRouteResource).Namespace("main")
+	_, err = routes.Get(ctx, "svc1", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "svc1 route deleted")
+	_, err = routes.Get(ctx, "svc3", metav1.GetOptions{})
+	assert.NoError(t, err, "svc3 route kept until its service is handled")
+
+	require.NoError(t, CleanIngressStrategy(ctx, client, dynamicClient, "main", LegacyProviderLabel, false, nil))
+	list, err := routes.List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, list.Items, "routes cleaned")
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc1", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "svc1 ingress cleaned")
+}
+
+func TestIngressStrategy_AnnotationsFrom(t *testing.T) {
+	newService := func(name, from string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "main",
+				Name:      name,
+				Annotations: map[string]string{
+					ExposeAnnotation.Key:                ExposeAnnotation.Value,
+					IngressAnnotationsFromAnnotationKey: from,
+					"fabric8.io/ingress.annotations":    "inline: \"true\"\noverridden: inline\n",
+				},
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Port: 80,
+				}},
+			},
+		}
+	}
+	svc := newService("svc", "ingress-annotations/svc.yaml")
+	missingKey := newService("missing-key", "ingress-annotations/other.yaml")
+	missingConfigMap := newService("missing-cm", "other/svc.yaml")
+	invalid := newService("invalid", "ingress-annotations")
+	client := fake.NewSimpleClientset(svc, missingKey, missingConfigMap, invalid, &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "ingress-annotations",
+		},
+		Data: map[string]string{
+			"svc.yaml": "from-cm: \"true\"\noverridden: cm\nfabric8.io/generated-by: me\n",
+		},
+	})
+
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:   "ingress",
+		Namespace: "main",
+		Domain:    "my-domain.com",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(svc))
+	assert.Error(t, strategy.Add(missingKey), "missing key")
+	assert.Error(t, strategy.Add(missingConfigMap), "missing config map")
+	assert.Error(t, strategy.Add(invalid), "invalid reference")
+
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(context.Background(), "svc", metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{
+			"fabric8.io/generated-by": "exposecontroller",
+			"from-cm":                 "true",
+			"inline":                  "true",
+			"overridden":              "inline",
+		}, ingress.Annotations)
+	}
+}
+
+func TestIngressStrategy_Ports(t *testing.T) {
+	examples := []struct {
+		config *Config
+		url    string
+	}{{
+		config: &Config{HTTP: true, HTTPPort: 8080},
+		url:    "http://svc.main.my-domain.com:8080",
+	}, {
+		config: &Config{HTTP: true, HTTPPort: 80, HTTPSPort: 8443},
+		url:    "http://svc.main.my-domain.com",
+	}, {
+		config: &Config{TLSSecretName: "my-tls-secret", HTTPPort: 8080, HTTPSPort: 30443},
+		url:    "https://svc.main.my-domain.com:30443",
+	}, {
+		config: &Config{TLSSecretName: "my-tls-secret", HTTPSPort: 443},
+		url:    "https://svc.main.my-domain.com",
+	}}
+	for _, example := range examples {
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "main",
+				Name:      "svc",
+				Annotations: map[string]string{
+					ExposeHostNameAsAnnotationKey: "host",
+				},
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{Port: 80}},
+			},
+		}
+		client := fake.NewSimpleClientset(service)
+		example.config.Exposer = "ingress"
+		example.config.Namespace = "main"
+		example.config.Domain = "my-domain.com"
+		strategy, err := NewIngressStrategy(nil, client, example.config)
+		require.NoError(t, err)
+		require.NoError(t, strategy.Sync())
+		require.NoError(t, strategy.Add(service))
+
+		service, err = client.CoreV1().Services("main").Get(context.Background(), "svc", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, example.url, service.Annotations[ExposeAnnotationKey])
+		assert.Equal(t, "svc.main.my-domain.com", service.Annotations["host"], "the host name has no port")
+	}
+
+	_, err := NewIngressStrategy(nil, fake.NewSimpleClientset(), &Config{Domain: "my-domain.com", HTTPSPort: 70000})
+	assert.EqualError(t, err, "invalid ingress controller port 70000, must be between 1 and 65535")
+}
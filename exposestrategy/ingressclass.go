@@ -0,0 +1,105 @@
+package exposestrategy
+
+import (
+	"context"
+
+	"k8s.io/klog"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// defaultIngressClassAnnotationKey annotation marks the default ingress class of the cluster
+	defaultIngressClassAnnotationKey = "ingressclass.kubernetes.io/is-default-class"
+	// pathModeIngressClass is the ingress class of the path mode when none is configured
+	pathModeIngressClass = "nginx"
+)
+
+// listIngressClasses lists the ingress classes installed in the cluster
+// returns nil if they cannot be read, ingress classes being cluster scoped
+func listIngressClasses(ctx context.Context, client kubernetes.Interface, config *Config) []networkingv1.IngressClass {
+	if config.PermissionProfile == PermissionProfileNamespace {
+		return nil
+	}
+	list, err := client.NetworkingV1().IngressClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		klog.Warningf("Could not list the ingress classes, the ingress class is not checked: %v", err)
+		return nil
+	}
+	for _, class := range list.Items {
+		klog.Infof("Found ingress class %s of controller %s", class.Name, class.Spec.Controller)
+	}
+	return list.Items
+}
+
+// checkIngressClass returns the ingress class if installed, otherwise warns and returns the default one
+// the default one is the class marked as default, or the only class of the cluster
+// the ingress class is returned as is if no class is installed or none is the default
+func checkIngressClass(classes []networkingv1.IngressClass, ingressClass string) string {
+	if len(classes) == 0 || ingressClass == "" {
+		return ingressClass
+	}
+	for _, class := range classes {
+		if class.Name == ingressClass {
+			return ingressClass
+		}
+	}
+	defaultClass := markedDefaultIngressClass(classes)
+	if defaultClass == "" && len(classes) == 1 {
+		defaultClass = classes[0].Name
+	}
+	if defaultClass == "" {
+		klog.Warningf("Ingress class %s is not installed and the cluster has no default one, using it anyway", ingressClass)
+		return ingressClass
+	}
+	klog.Warningf("Ingress class %s is not installed, using the default ingress class %s", ingressClass, defaultClass)
+	return defaultClass
+}
+
+// markedDefaultIngressClass returns the ingress class marked as the default one of the cluster, empty if none
+func markedDefaultIngressClass(classes []networkingv1.IngressClass) string {
+	defaultClass := ""
+	for _, class := range classes {
+		if class.Annotations[defaultIngressClassAnnotationKey] == "true" {
+			defaultClass = class.Name
+		}
+	}
+	return defaultClass
+}
+
+// detectDefaultIngressClass returns the default ingress class of the cluster when no ingress class is configured, empty otherwise
+// the admission controller of the cluster sets it on the ingresses created without class,
+// setting it on the generated ingresses avoids updating them back and forth
+func detectDefaultIngressClass(classes []networkingv1.IngressClass, ingressClass string) string {
+	if ingressClass != "" {
+		return ""
+	}
+	defaultClass := markedDefaultIngressClass(classes)
+	if defaultClass == "" {
+		return ""
+	}
+	for _, class := range classes {
+		if class.Name == defaultClass {
+			klog.Infof("No ingress class configured, using the default ingress class %s of controller %s", class.Name, class.Spec.Controller)
+		}
+	}
+	return defaultClass
+}
+
+// InstalledIngressClass returns the installed ingress class of the generated ingresses of the ingress class,
+// the default one if empty or not installed, as the classes of the cluster or the only class, nil if none
+func InstalledIngressClass(classes []networkingv1.IngressClass, ingressClass string) *networkingv1.IngressClass {
+	for _, name := range []string{ingressClass, markedDefaultIngressClass(classes)} {
+		for i := range classes {
+			if name != "" && classes[i].Name == name {
+				return &classes[i]
+			}
+		}
+	}
+	if len(classes) == 1 {
+		return &classes[0]
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIngressClass(name string, isDefault bool) networkingv1.IngressClass {
+	class := networkingv1.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: networkingv1.IngressClassSpec{
+			Controller: "example.com/" + name,
+		},
+	}
+	if isDefault {
+		class.Annotations = map[string]string{
+			defaultIngressClassAnnotationKey: "true",
+		}
+	}
+	return class
+}
+
+func TestCheckIngressClass(t *testing.T) {
+	examples := []struct {
+		name     string
+		classes  []networkingv1.IngressClass
+		class    string
+		expected string
+	}{{
+		name:     "no class installed",
+		class:    "nginx",
+		expected: "nginx",
+	}, {
+		name:    "no class configured",
+		classes: []networkingv1.IngressClass{newIngressClass("traefik", true)},
+	}, {
+		name:     "installed",
+		classes:  []networkingv1.IngressClass{newIngressClass("nginx", false), newIngressClass("traefik", true)},
+		class:    "nginx",
+		expected: "nginx",
+	}, {
+		name:     "default class",
+		classes:  []networkingv1.IngressClass{newIngressClass("haproxy", false), newIngressClass("traefik", true)},
+		class:    "nginx",
+		expected: "traefik",
+	}, {
+		name:     "only class",
+		classes:  []networkingv1.IngressClass{newIngressClass("traefik", false)},
+		class:    "nginx",
+		expected: "traefik",
+	}, {
+		name:     "no default class",
+		classes:  []networkingv1.IngressClass{newIngressClass("haproxy", false), newIngressClass("traefik", false)},
+		class:    "nginx",
+		expected: "nginx",
+	}}
+	for _, example := range examples {
+		assert.Equal(t, example.expected, checkIngressClass(example.classes, example.class), example.name)
+	}
+}
+
+func TestIngressStrategy_IngressClassDetection(t *testing.T) {
+	traefik := newIngressClass("traefik", true)
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 80}},
+		},
+	}
+	client := fake.NewSimpleClientset(&traefik, svc)
+
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:      "ingress",
+		Namespace:    "main",
+		Domain:       "my-domain.com",
+		IngressClass: "nginx",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "traefik", strategy.(*IngressStrategy).ingressClass)
+	assert.Equal(t, "traefik", strategy.(*IngressStrategy).pathModeClass)
+
+	// ingress classes are cluster scoped
+	strategy, err = NewIngressStrategy(nil, client, &Config{
+		Exposer:           "ingress",
+		Namespace:         "main",
+		Domain:            "my-domain.com",
+		IngressClass:      "nginx",
+		PermissionProfile: PermissionProfileNamespace,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "nginx", strategy.(*IngressStrategy).ingressClass)
+	assert.Equal(t, "nginx", strategy.(*IngressStrategy).pathModeClass)
+}
+
+func TestIngressStrategy_DefaultIngressClass(t *testing.T) {
+	traefik := newIngressClass("traefik", true)
+	haproxy := newIngressClass("haproxy", false)
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			UID:       "uid",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 80}},
+		},
+	}
+	ctx := context.Background()
+	for _, class := range []string{"", "haproxy"} {
+		client := fake.NewSimpleClientset(&traefik, &haproxy, svc)
+		strategy, err := NewIngressStrategy(nil, client, &Config{
+			Exposer:      "ingress",
+			Namespace:    "main",
+			Domain:       "my-domain.com",
+			IngressClass: class,
+		})
+		require.NoError(t, err)
+		require.NoError(t, strategy.Sync())
+		require.NoError(t, strategy.Add(svc))
+		ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+		require.NoError(t, err)
+		if class == "" {
+			require.NotNil(t, ingress.Spec.IngressClassName)
+			assert.Equal(t, "traefik", *ingress.Spec.IngressClassName)
+			assert.NotContains(t, ingress.Annotations, "kubernetes.io/ingress.class")
+		} else {
+			assert.Nil(t, ingress.Spec.IngressClassName)
+			assert.Equal(t, "haproxy", ingress.Annotations["kubernetes.io/ingress.class"])
+		}
+	}
+
+	assert.Empty(t, detectDefaultIngressClass([]networkingv1.IngressClass{haproxy}, ""), "no default class")
+}
@@ -0,0 +1,71 @@
+package exposestrategy
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DiscoverIngressController is the value of the ingress controller service to discover it from the well-known labels
+const DiscoverIngressController = "auto"
+
+// ingressControllerSelectors are the labels of the services of the well-known ingress controllers, as installed by their charts
+var ingressControllerSelectors = []string{
+	"app.kubernetes.io/name=ingress-nginx,app.kubernetes.io/component=controller",
+	"app=nginx-ingress,component=controller",
+	"app.kubernetes.io/name=traefik",
+	"app.kubernetes.io/name=kubernetes-ingress",
+	"app.kubernetes.io/name=contour,app.kubernetes.io/component=envoy",
+}
+
+// discoverIngressController returns the "namespace/name" of the service of the ingress controller of the cluster,
+// the load balancer services having an IP come first, then the other load balancer and node port services
+func discoverIngressController(ctx context.Context, client kubernetes.Interface, config *Config) (string, error) {
+	if config.PermissionProfile == PermissionProfileNamespace {
+		return "", errors.New("the ingress controller service must be configured with the namespace permission profile, the services of the cluster cannot be listed")
+	}
+	var found []v1.Service
+	for _, selector := range ingressControllerSelectors {
+		list, err := client.CoreV1().Services(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return "", errors.Wrap(err, "failed to list the services of the ingress controllers")
+		}
+		for _, svc := range list.Items {
+			if svc.Spec.Type == v1.ServiceTypeLoadBalancer || svc.Spec.Type == v1.ServiceTypeNodePort {
+				found = append(found, svc)
+			}
+		}
+		// the first well-known controller installed wins
+		if len(found) > 0 {
+			break
+		}
+	}
+	if len(found) == 0 {
+		return "", errors.New("no ingress controller service found from the well-known labels, configure it")
+	}
+	rank := func(svc *v1.Service) int {
+		switch {
+		case svc.Spec.Type == v1.ServiceTypeLoadBalancer && len(svc.Status.LoadBalancer.Ingress) > 0:
+			return 0
+		case svc.Spec.Type == v1.ServiceTypeLoadBalancer:
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		if rank(&found[i]) != rank(&found[j]) {
+			return rank(&found[i]) < rank(&found[j])
+		}
+		return serviceKey(found[i].Namespace, found[i].Name) < serviceKey(found[j].Namespace, found[j].Name)
+	})
+	key := serviceKey(found[0].Namespace, found[0].Name)
+	klog.Infof("Discovered the %s service %s of the ingress controller", found[0].Spec.Type, key)
+	return key, nil
+}
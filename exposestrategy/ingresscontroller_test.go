@@ -0,0 +1,67 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ingressControllerService(namespace, name string, labels map[string]string, serviceType v1.ServiceType, ip string) *v1.Service {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+		Spec: v1.ServiceSpec{
+			Type:  serviceType,
+			Ports: []v1.ServicePort{{Name: "http", Port: 80, NodePort: 30080}, {Name: "https", Port: 443, NodePort: 30443}},
+		},
+	}
+	if ip != "" {
+		svc.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{IP: ip}}
+	}
+	return svc
+}
+
+func TestDiscoverIngressController(t *testing.T) {
+	nginx := map[string]string{"app.kubernetes.io/name": "ingress-nginx", "app.kubernetes.io/component": "controller"}
+	client := fake.NewSimpleClientset(
+		ingressControllerService("ingress-nginx", "ingress-nginx-controller-admission", nginx, v1.ServiceTypeClusterIP, ""),
+		ingressControllerService("ingress-nginx", "ingress-nginx-controller", nginx, v1.ServiceTypeLoadBalancer, ""),
+		ingressControllerService("internal", "ingress-nginx-controller", nginx, v1.ServiceTypeLoadBalancer, "10.0.0.5"),
+		ingressControllerService("traefik", "traefik", map[string]string{"app.kubernetes.io/name": "traefik"}, v1.ServiceTypeLoadBalancer, "10.0.0.6"),
+	)
+	key, err := discoverIngressController(context.Background(), client, &Config{})
+	require.NoError(t, err)
+	assert.Equal(t, "internal/ingress-nginx-controller", key, "the load balancer having an IP comes first")
+
+	_, err = discoverIngressController(context.Background(), fake.NewSimpleClientset(), &Config{})
+	assert.EqualError(t, err, "no ingress controller service found from the well-known labels, configure it")
+	_, err = discoverIngressController(context.Background(), client, &Config{PermissionProfile: PermissionProfileNamespace})
+	assert.Error(t, err)
+}
+
+func TestIngressStrategy_DiscoveredIngressController(t *testing.T) {
+	client := fake.NewSimpleClientset(ingressControllerService("kube-system", "traefik",
+		map[string]string{"app.kubernetes.io/name": "traefik"}, v1.ServiceTypeLoadBalancer, "203.0.113.10"))
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:           "ingress",
+		AutoDomainService: DiscoverIngressController,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.10.nip.io", strategy.(*IngressStrategy).domain)
+
+	config := &Config{
+		Exposer:                "ingress",
+		Domain:                 "my-domain.com",
+		IngressNodePortService: DiscoverIngressController,
+	}
+	_, err = NewIngressStrategy(nil, client, config)
+	require.NoError(t, err)
+	assert.Equal(t, "kube-system/traefik", config.IngressNodePortService)
+	assert.Equal(t, 30080, config.HTTPPort)
+	assert.Equal(t, 30443, config.HTTPSPort)
+}
@@ -0,0 +1,61 @@
+package exposestrategy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// checkLabel checks the key and the value of a label of the generated ingresses
+func checkLabel(key, value string) error {
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return errors.Errorf("invalid label key \"%s\": %s", key, strings.Join(errs, ", "))
+	}
+	if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+		return errors.Errorf("invalid value \"%s\" of label %s: %s", value, key, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// checkIngressLabels checks the labels of the config
+func checkIngressLabels(labels map[string]string) error {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := checkLabel(key, labels[key]); err != nil {
+			return errors.Wrap(err, "invalid ingress labels")
+		}
+	}
+	return nil
+}
+
+// parseLabelsYAML parses the labels to stamp on the ingress, like the annotations,
+// the errors locate the invalid label in the annotation
+func parseLabelsYAML(svc *v1.Service, text string, strict bool, labels map[string]string) error {
+	parsed := map[string]string{}
+	err := parseAnnotationsYAML(svc, IngressLabelsAnnotationKey, text, strict, parsed)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(parsed))
+	for key := range parsed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := checkLabel(key, parsed[key]); err != nil {
+			parseErr := newAnnotationParseError(svc, IngressLabelsAnnotationKey, text, err)
+			parseErr.locate(text, keyLine(text, key))
+			return parseErr
+		}
+		labels[key] = parsed[key]
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_IngressLabels(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				IngressLabelsAnnotationKey: "cost-center: payments\nprovider: other\n",
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(service)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:       "ingress",
+		Namespace:     "main",
+		Domain:        "my-domain.com",
+		IngressLabels: map[string]string{"policy.example.com/tier": "public", "cost-center": "platform"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(service))
+
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(context.Background(), "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"policy.example.com/tier": "public",
+		"cost-center":             "payments",
+		"provider":                "fabric8",
+	}, ingress.Labels, "the service overrides the config, not the provider label")
+
+	service.Annotations[IngressLabelsAnnotationKey] = "team: payments\ncost-center: not a value\n"
+	err = strategy.Add(service)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `failed to parse annotation "fabric8.io/ingress.labels" in service main/svc at line 2, column 1: `+
+		`invalid value "not a value" of label cost-center`)
+
+	_, err = NewIngressStrategy(nil, client, &Config{
+		Exposer:       "ingress",
+		Domain:        "my-domain.com",
+		IngressLabels: map[string]string{"-tier": "public"},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,144 @@
+package exposestrategy
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// IngressNamespaceAnnotationKey annotation generates the ingress of the service in another namespace, such as a central "edge" namespace
+	// the ingress is backed there by an ExternalName service, for the clusters where the namespaces of the applications cannot hold ingresses
+	IngressNamespaceAnnotationKey = "fabric8.io/ingress.namespace"
+	// ExposedNamespaceLabelKey label holds the namespace of the exposed service of an ingress generated in another namespace
+	ExposedNamespaceLabelKey = "fabric8.io/exposed-namespace"
+)
+
+// ingressNamespace returns the namespace of the ingress of the service, its own namespace by default
+func (s *IngressStrategy) ingressNamespace(svc *v1.Service) (string, error) {
+	namespace := s.ingressNamespaceName
+	if value, ok := svc.Annotations[IngressNamespaceAnnotationKey]; ok {
+		if errs := validation.IsDNS1123Label(value); len(errs) > 0 {
+			return "", newAnnotationParseError(svc, IngressNamespaceAnnotationKey, value,
+				errors.Errorf("invalid namespace \"%s\": %s", value, strings.Join(errs, ", ")))
+		}
+		namespace = value
+	}
+	if namespace == "" || namespace == svc.Namespace {
+		return svc.Namespace, nil
+	}
+	if s.httpRoute || s.gkeConfigs {
+		return "", errors.Errorf("the ingress of service %s/%s cannot be generated in namespace %s with HTTP routes or GKE configs",
+			svc.Namespace, svc.Name, namespace)
+	}
+	return namespace, nil
+}
+
+// ingressEntry returns the entry of an ingress of the service in the existing ingresses
+// the name of the ingress in the namespace of the service, "namespace/name" in another namespace
+func ingressEntry(svcNamespace string, ingress metav1.Object) string {
+	if ingress.GetNamespace() == svcNamespace {
+		return ingress.GetName()
+	}
+	return ingress.GetNamespace() + "/" + ingress.GetName()
+}
+
+// parseIngressEntry returns the namespace and name of an entry of the existing ingresses of a service
+func parseIngressEntry(svcNamespace, entry string) (string, string) {
+	if parts := strings.SplitN(entry, "/", 2); len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return svcNamespace, entry
+}
+
+// exposedServiceNamespace returns the namespace of the service tracked by the labels of a generated object
+func exposedServiceNamespace(obj metav1.Object) string {
+	if namespace := obj.GetLabels()[ExposedNamespaceLabelKey]; namespace != "" {
+		return namespace
+	}
+	return obj.GetNamespace()
+}
+
+// buildExternalNameService builds the service of the ingress namespace backing the ingress with the exposed service
+// it is owned by the ingress and garbage collected with it
+func buildExternalNameService(ingress *networkingv1.Ingress, name string, svc *v1.Service, backendPort networkingv1.ServiceBackendPort, servicePort *v1.ServicePort) *v1.Service {
+	port := v1.ServicePort{
+		Name:     backendPort.Name,
+		Protocol: v1.ProtocolTCP,
+		Port:     backendPort.Number,
+	}
+	if backendPort.Name != "" {
+		port.Port = servicePort.Port
+	}
+	labels := map[string]string{}
+	for k, v := range ingress.Labels {
+		labels[k] = v
+	}
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ingress.Namespace,
+			Name:      name,
+			Labels:    labels,
+			Annotations: map[string]string{
+				GeneratedByAnnotationKey: ingress.Annotations[GeneratedByAnnotationKey],
+			},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "networking.k8s.io/v1",
+				Kind:       "Ingress",
+				Name:       ingress.Name,
+				UID:        ingress.UID,
+			}},
+		},
+		Spec: v1.ServiceSpec{
+			Type:         v1.ServiceTypeExternalName,
+			ExternalName: svc.Name + "." + svc.Namespace + ".svc.cluster.local",
+			Ports:        []v1.ServicePort{port},
+		},
+	}
+}
+
+// applyExternalNameService creates or updates the service backing an ingress of another namespace
+func (s *IngressStrategy) applyExternalNameService(backend *v1.Service) error {
+	services := s.client.CoreV1().Services(backend.Namespace)
+	existing, err := services.Get(s.ctx, backend.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.Infof("creating the service %s/%s", backend.Namespace, backend.Name)
+		logServiceChange(nil, backend)
+		_, err = services.Create(s.ctx, backend, metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create service %s/%s", backend.Namespace, backend.Name)
+		}
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "could not check for existing service %s/%s", backend.Namespace, backend.Name)
+	}
+	if !s.provider.IsGenerated(existing.Annotations) {
+		return errors.Errorf("service %s/%s already exists and was not generated by exposecontroller",
+			backend.Namespace, backend.Name)
+	}
+	if reflect.DeepEqual(backend.Labels, existing.Labels) &&
+		reflect.DeepEqual(backend.OwnerReferences, existing.OwnerReferences) &&
+		backend.Spec.ExternalName == existing.Spec.ExternalName &&
+		reflect.DeepEqual(backend.Spec.Ports, existing.Spec.Ports) {
+		return nil
+	}
+	updated := existing.DeepCopy()
+	updated.Labels = backend.Labels
+	updated.OwnerReferences = backend.OwnerReferences
+	updated.Spec = backend.Spec
+	klog.Infof("updating the service %s/%s", backend.Namespace, backend.Name)
+	logServiceChange(existing, updated)
+	_, err = services.Update(s.ctx, updated, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to update service %s/%s", backend.Namespace, backend.Name)
+	}
+	return nil
+}
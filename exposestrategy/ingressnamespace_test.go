@@ -0,0 +1,107 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_IngressNamespace(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "main",
+			Name:            "svc",
+			UID:             "svc-uid",
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Name: "http", Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(service)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:          "ingress",
+		Namespace:        "main",
+		Domain:           "my-domain.com",
+		IngressNamespace: "edge",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(service))
+
+	ctx := context.Background()
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "no ingress in the namespace of the service")
+	ingress, err := client.NetworkingV1().Ingresses("edge").Get(ctx, "main-svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, ingress.OwnerReferences)
+	assert.Equal(t, "svc", ingress.Labels[ExposedServiceLabelKey])
+	assert.Equal(t, "main", ingress.Labels[ExposedNamespaceLabelKey])
+	assert.Equal(t, "svc.main.my-domain.com", ingress.Spec.Rules[0].Host)
+	assert.Equal(t, "main-svc", ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name)
+
+	backend, err := client.CoreV1().Services("edge").Get(ctx, "main-svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, v1.ServiceTypeExternalName, backend.Spec.Type)
+	assert.Equal(t, "svc.main.svc.cluster.local", backend.Spec.ExternalName)
+	assert.Equal(t, []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 8080}}, backend.Spec.Ports)
+	assert.Equal(t, "exposecontroller", backend.Annotations["fabric8.io/generated-by"])
+	if assert.Len(t, backend.OwnerReferences, 1) {
+		assert.Equal(t, "Ingress", backend.OwnerReferences[0].Kind)
+		assert.Equal(t, "main-svc", backend.OwnerReferences[0].Name)
+	}
+
+	// the ingress of the edge namespace is found back on sync, and deleted with the service
+	require.NoError(t, strategy.Sync())
+	assert.Equal(t, map[string][]string{"main/svc": {"edge/main-svc"}}, strategy.(*IngressStrategy).existing)
+	require.NoError(t, strategy.Delete(service))
+	_, err = client.NetworkingV1().Ingresses("edge").Get(ctx, "main-svc", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// the annotation brings the ingress back to the namespace of the service
+	service.Annotations = map[string]string{IngressNamespaceAnnotationKey: "main"}
+	require.NoError(t, strategy.Add(service))
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	service.Annotations = map[string]string{IngressNamespaceAnnotationKey: "Edge"}
+	err = strategy.Add(service)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `failed to parse annotation "fabric8.io/ingress.namespace" in service main/svc: invalid namespace "Edge"`)
+	}
+}
+
+func TestIngressStrategy_IngressNamespaceMissingService(t *testing.T) {
+	client := fake.NewSimpleClientset(&networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "edge",
+			Name:      "main-gone",
+			Labels: map[string]string{
+				"provider":               "fabric8",
+				ExposedServiceLabelKey:   "gone",
+				ExposedNamespaceLabelKey: "main",
+			},
+			Annotations: map[string]string{
+				"fabric8.io/generated-by": "exposecontroller",
+			},
+			ResourceVersion: "1",
+		},
+	})
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:          "ingress",
+		Domain:           "my-domain.com",
+		IngressNamespace: "edge",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	_, err = client.NetworkingV1().Ingresses("edge").Get(context.Background(), "main-gone", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "the service is looked up in its own namespace")
+}
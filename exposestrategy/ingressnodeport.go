@@ -0,0 +1,61 @@
+package exposestrategy
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// useIngressNodePorts publishes the URLs with the node ports of the service of the ingress controller
+// on bare metal, the URLs are then reachable without load balancer, and without DNS with the node IP domain
+// the configured ports and domain take precedence
+func useIngressNodePorts(ctx context.Context, client kubernetes.Interface, config *Config) error {
+	parts := strings.SplitN(config.IngressNodePortService, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return errors.Errorf("invalid ingress node port service \"%s\", must be \"namespace/name\"", config.IngressNodePortService)
+	}
+	svc, err := client.CoreV1().Services(parts[0]).Get(ctx, parts[1], metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the service %s of the ingress controller", config.IngressNodePortService)
+	}
+	var httpPort, httpsPort int32
+	for _, port := range svc.Spec.Ports {
+		switch {
+		case port.Name == "http" || (port.Name != "https" && port.Port == 80):
+			httpPort = port.NodePort
+		case port.Name == "https" || port.Port == 443:
+			httpsPort = port.NodePort
+		}
+	}
+	if svc.Spec.Type == v1.ServiceTypeClusterIP || svc.Spec.Type == v1.ServiceTypeExternalName || (httpPort == 0 && httpsPort == 0) {
+		return errors.Errorf("the service %s of the ingress controller has no node port for http or https", config.IngressNodePortService)
+	}
+	if config.HTTPPort == 0 {
+		config.HTTPPort = int(httpPort)
+	}
+	if config.HTTPSPort == 0 {
+		config.HTTPSPort = int(httpsPort)
+	}
+	if config.Domain == "" {
+		ip := config.NodeIP
+		if ip == "" {
+			if config.PermissionProfile == PermissionProfileNamespace {
+				return errors.New("the domain or the node IP must be configured with the namespace permission profile, the nodes cannot be listed")
+			}
+			ip, err = discoverNodeIP(ctx, client)
+			if err != nil {
+				return errors.Wrap(err, "failed to discover the node IP, configure the domain or the node IP")
+			}
+		}
+		config.Domain = ip + domainExt
+	}
+	klog.Infof("Using the node ports %d and %d of the ingress controller service %s",
+		config.HTTPPort, config.HTTPSPort, config.IngressNodePortService)
+	return nil
+}
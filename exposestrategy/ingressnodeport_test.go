@@ -0,0 +1,98 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_IngressNodePorts(t *testing.T) {
+	controllerService := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ingress-nginx",
+			Name:      "ingress-nginx-controller",
+		},
+		Spec: v1.ServiceSpec{
+			Type: v1.ServiceTypeNodePort,
+			Ports: []v1.ServicePort{{
+				Name:     "http",
+				Port:     80,
+				NodePort: 30080,
+			}, {
+				Name:     "https",
+				Port:     443,
+				NodePort: 30443,
+			}},
+		},
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "my-node",
+		},
+		Status: v1.NodeStatus{
+			Addresses: []v1.NodeAddress{{
+				Type:    v1.NodeInternalIP,
+				Address: "192.168.1.10",
+			}},
+		},
+	}
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	examples := []struct {
+		config *Config
+		host   string
+		url    string
+	}{{
+		config: &Config{HTTP: true},
+		host:   "svc.main.192.168.1.10.nip.io",
+		url:    "http://svc.main.192.168.1.10.nip.io:30080",
+	}, {
+		config: &Config{TLSSecretName: "my-tls-secret", NodeIP: "10.0.0.1"},
+		host:   "svc.main.10.0.0.1.nip.io",
+		url:    "https://svc.main.10.0.0.1.nip.io:30443",
+	}, {
+		config: &Config{HTTP: true, Domain: "my-domain.com", HTTPPort: 8080},
+		host:   "svc.main.my-domain.com",
+		url:    "http://svc.main.my-domain.com:8080",
+	}}
+	for _, example := range examples {
+		client := fake.NewSimpleClientset(controllerService, node, service)
+		example.config.Exposer = "ingress"
+		example.config.Namespace = "main"
+		example.config.IngressNodePortService = "ingress-nginx/ingress-nginx-controller"
+		strategy, err := NewIngressStrategy(nil, client, example.config)
+		require.NoError(t, err)
+		require.NoError(t, strategy.Sync())
+		require.NoError(t, strategy.Add(service))
+
+		ctx := context.Background()
+		ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Len(t, ingress.Spec.Rules, 1)
+		assert.Equal(t, example.host, ingress.Spec.Rules[0].Host)
+		svc, err := client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, example.url, svc.Annotations[ExposeAnnotationKey])
+	}
+
+	client := fake.NewSimpleClientset(service)
+	_, err := NewIngressStrategy(nil, client, &Config{Domain: "my-domain.com", IngressNodePortService: "ingress-nginx-controller"})
+	assert.EqualError(t, err, `invalid ingress node port service "ingress-nginx-controller", must be "namespace/name"`)
+	_, err = NewIngressStrategy(nil, client, &Config{Domain: "my-domain.com", IngressNodePortService: "main/svc"})
+	assert.EqualError(t, err, "the service main/svc of the ingress controller has no node port for http or https")
+	_, err = NewIngressStrategy(nil, client, &Config{IngressNodePortService: "main/missing"})
+	assert.Error(t, err, "missing service")
+}
@@ -0,0 +1,48 @@
+package exposestrategy
+
+import (
+	"time"
+
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ingressStatusRecheckPeriod is how often the ingresses waiting for their status are checked again
+const ingressStatusRecheckPeriod = 15 * time.Second
+
+// isIngressAdmitted tells if the ingress controller admitted the ingress by writing its load balancer status,
+// always true without status check, the ingresses without status are checked again later
+func (s *IngressStrategy) isIngressAdmitted(svc *v1.Service, ingress *networkingv1.Ingress) bool {
+	if !s.ingressStatusCheck || (ingress != nil && len(ingress.Status.LoadBalancer.Ingress) > 0) {
+		return true
+	}
+	if ingress != nil {
+		klog.Infof("ingress %s/%s of service %s/%s has no status yet, its URL is not published",
+			ingress.Namespace, ingress.Name, svc.Namespace, svc.Name)
+	}
+	s.scheduleResync(ingressStatusRecheckPeriod)
+	return false
+}
+
+// unpublishURL removes the URL of the service when its ingress could not be created, so that it does not point at nothing
+func (s *IngressStrategy) unpublishURL(svc *v1.Service) {
+	clone := svc.DeepCopy()
+	if !removeServiceAnnotation(clone) {
+		return
+	}
+	patch, err := createServicePatch(svc, clone)
+	if err != nil {
+		klog.Errorf("failed to create patch for service %s/%s: %s", svc.Namespace, svc.Name, err)
+		return
+	}
+	if patch != nil {
+		_, err = s.client.CoreV1().Services(svc.Namespace).
+			Patch(s.ctx, svc.Name, patchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			klog.Errorf("failed to remove the URL of service %s/%s: %s", svc.Namespace, svc.Name, err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_IngressStatusCheck(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "main",
+			Name:            "svc",
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(service)
+	var resyncs []time.Duration
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:            "ingress",
+		Namespace:          "main",
+		Domain:             "my-domain.com",
+		HTTP:               true,
+		IngressStatusCheck: true,
+		ScheduleResync:     func(d time.Duration) { resyncs = append(resyncs, d) },
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(service))
+
+	ctx := context.Background()
+	service, err = client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "", service.Annotations[ExposeAnnotationKey], "pending until admitted")
+	assert.Equal(t, []time.Duration{ingressStatusRecheckPeriod}, resyncs)
+
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	ingress.ResourceVersion = "1"
+	ingress.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{{IP: "10.0.0.1"}}
+	_, err = client.NetworkingV1().Ingresses("main").UpdateStatus(ctx, ingress, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Add(service))
+	service, err = client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "http://svc.main.my-domain.com", service.Annotations[ExposeAnnotationKey])
+	assert.Len(t, resyncs, 1)
+}
+
+func TestIngressStrategy_RejectedIngress(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				"fabric8.io/ingress.name": "renamed",
+				ExposeAnnotationKey:       "http://svc.main.my-domain.com",
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(service)
+	client.PrependReactor("create", "ingresses", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("admission webhook denied the request")
+	})
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:   "ingress",
+		Namespace: "main",
+		Domain:    "my-domain.com",
+		HTTP:      true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	err = strategy.Add(service)
+	assert.EqualError(t, err, "failed to create ingress main/renamed: admission webhook denied the request")
+
+	service, err = client.CoreV1().Services("main").Get(context.Background(), "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, service.Annotations, ExposeAnnotationKey)
+}
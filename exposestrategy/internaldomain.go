@@ -0,0 +1,57 @@
+package exposestrategy
+
+import (
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+)
+
+const (
+	// InternalDomainSchemeAnnotationKey is the scheme of the URL of the hosts of the internal domain of the service,
+	// overriding the InternalDomainScheme of the controller
+	InternalDomainSchemeAnnotationKey = "fabric8.io/internal.domain.scheme"
+
+	// InternalDomainSchemeHTTP exposes the hosts of the internal domain in plain HTTP
+	InternalDomainSchemeHTTP = "http"
+	// InternalDomainSchemeHTTPS exposes the hosts of the internal domain with TLS,
+	// with the InternalDomainTLSSecretName of the controller if set
+	InternalDomainSchemeHTTPS = "https"
+)
+
+// checkInternalDomainScheme checks the scheme of the internal domain, the TLS settings of the domain apply if empty
+func checkInternalDomainScheme(scheme string) error {
+	switch scheme {
+	case "", InternalDomainSchemeHTTP, InternalDomainSchemeHTTPS:
+		return nil
+	default:
+		return errors.Errorf("invalid internal domain scheme \"%s\", must be \"%s\" or \"%s\"",
+			scheme, InternalDomainSchemeHTTP, InternalDomainSchemeHTTPS)
+	}
+}
+
+// internalDomainScheme returns the scheme of the internal domain of the service, the one of the controller by default
+func (s *IngressStrategy) internalDomainScheme(svc *v1.Service) (string, error) {
+	scheme, ok := svc.Annotations[InternalDomainSchemeAnnotationKey]
+	if !ok {
+		return s.internalDomainSchemeDefault, nil
+	}
+	if err := checkInternalDomainScheme(scheme); err != nil {
+		return "", errors.Wrapf(err, "invalid annotation \"%s\" in service %s/%s",
+			InternalDomainSchemeAnnotationKey, svc.Namespace, svc.Name)
+	}
+	return scheme, nil
+}
+
+// internalHostTLS returns the secret of the certificate of a host of the internal domain, empty in plain HTTP,
+// and if the certificate is issued by cert-manager, the TLS settings of the host apply without scheme
+func (s *IngressStrategy) internalHostTLS(host, secretName, appName string, optOut acmeOptOut, scheme string) (string, bool) {
+	switch scheme {
+	case InternalDomainSchemeHTTP:
+		return "", false
+	case InternalDomainSchemeHTTPS:
+		if s.internalDomainTLSSecretName != "" {
+			return s.internalDomainTLSSecretName, false
+		}
+	}
+	return s.hostTLS(host, secretName, appName, optOut)
+}
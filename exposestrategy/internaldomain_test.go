@@ -0,0 +1,85 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_InternalDomainScheme(t *testing.T) {
+	internal := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "main",
+			Name:            "internal",
+			Annotations:     map[string]string{"fabric8.io/use.internal.domain": "true"},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	both := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "both",
+			Annotations: map[string]string{
+				"fabric8.io/use.internal.domain":  "both",
+				InternalDomainSchemeAnnotationKey: "http",
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(internal, both)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:                     "ingress",
+		Namespace:                   "main",
+		Domain:                      "my-domain.com",
+		InternalDomain:              "internal.lan",
+		TLSSecretName:               "main-tls",
+		InternalDomainScheme:        "https",
+		InternalDomainTLSSecretName: "internal-tls",
+		DomainTLSPolicies: map[string]DomainTLSPolicy{
+			"internal.lan": {TLS: DomainTLSNone},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(internal))
+	require.NoError(t, strategy.Add(both))
+
+	ctx := context.Background()
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "internal", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []networkingv1.IngressTLS{{
+		Hosts:      []string{"internal.main.internal.lan"},
+		SecretName: "internal-tls",
+	}}, ingress.Spec.TLS, "the scheme overrides the TLS policy of the domain")
+	svc, err := client.CoreV1().Services("main").Get(ctx, "internal", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://internal.main.internal.lan", svc.Annotations[ExposeAnnotationKey])
+
+	ingress, err = client.NetworkingV1().Ingresses("main").Get(ctx, "both", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Len(t, ingress.Spec.Rules, 2)
+	assert.Equal(t, []networkingv1.IngressTLS{{
+		Hosts:      []string{"both.main.my-domain.com"},
+		SecretName: "main-tls",
+	}}, ingress.Spec.TLS, "the internal host is in plain HTTP")
+	svc, err = client.CoreV1().Services("main").Get(ctx, "both", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://both.main.my-domain.com", svc.Annotations[ExposeAnnotationKey])
+
+	both.Annotations[InternalDomainSchemeAnnotationKey] = "ftp"
+	err = strategy.Add(both)
+	assert.EqualError(t, err, `invalid annotation "fabric8.io/internal.domain.scheme" in service main/both: invalid internal domain scheme "ftp", must be "http" or "https"`)
+}
@@ -0,0 +1,104 @@
+package exposestrategy
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LastAppliedAnnotationKey annotation holds the ingress last desired by the controller as JSON, its labels, annotations and spec,
+// as kubectl apply does, set with the RecordLastApplied config
+const LastAppliedAnnotationKey = "fabric8.io/last-applied-configuration"
+
+// lastAppliedIngress is the recorded part of the desired ingress
+type lastAppliedIngress struct {
+	Metadata lastAppliedMetadata      `json:"metadata"`
+	Spec     networkingv1.IngressSpec `json:"spec"`
+}
+
+type lastAppliedMetadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// recordLastApplied stores the desired ingress in its last applied annotation
+func recordLastApplied(ingress *networkingv1.Ingress) error {
+	applied := lastAppliedIngress{
+		Metadata: lastAppliedMetadata{Labels: ingress.Labels, Annotations: map[string]string{}},
+		Spec:     ingress.Spec,
+	}
+	for key, value := range ingress.Annotations {
+		if key != LastAppliedAnnotationKey {
+			applied.Metadata.Annotations[key] = value
+		}
+	}
+	data, err := json.Marshal(applied)
+	if err != nil {
+		return errors.Wrapf(err, "failed to record the last applied configuration of ingress %s/%s", ingress.Namespace, ingress.Name)
+	}
+	if ingress.Annotations == nil {
+		ingress.Annotations = map[string]string{}
+	}
+	ingress.Annotations[LastAppliedAnnotationKey] = string(data)
+	return nil
+}
+
+// LastAppliedIngress returns the ingress last desired by the controller from its annotation, nil if not recorded,
+// external tools compare it to the ingress to tell the changes made by others
+func LastAppliedIngress(ingress *networkingv1.Ingress) (*networkingv1.Ingress, error) {
+	data, ok := ingress.Annotations[LastAppliedAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+	var applied lastAppliedIngress
+	err := json.Unmarshal([]byte(data), &applied)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the last applied configuration of ingress %s/%s", ingress.Namespace, ingress.Name)
+	}
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   ingress.Namespace,
+			Name:        ingress.Name,
+			Labels:      applied.Metadata.Labels,
+			Annotations: applied.Metadata.Annotations,
+		},
+		Spec: applied.Spec,
+	}, nil
+}
+
+// mergeLastApplied keeps the labels and annotations of the existing ingress added by others, as a 3-way merge:
+// those the controller never applied are kept, those it applied and does not desire anymore are removed
+// the existing ingresses without last applied annotation are replaced
+func mergeLastApplied(desired, existing *networkingv1.Ingress) error {
+	last, err := LastAppliedIngress(existing)
+	if err != nil || last == nil {
+		return err
+	}
+	desired.Labels = mergeLastAppliedMap(desired.Labels, existing.Labels, last.Labels)
+	desired.Annotations = mergeLastAppliedMap(desired.Annotations, existing.Annotations, last.Annotations)
+	return nil
+}
+
+// mergeLastAppliedMap returns the desired values with the existing ones never applied, the desired map is not modified
+func mergeLastAppliedMap(desired, existing, applied map[string]string) map[string]string {
+	merged, copied := desired, false
+	for key, value := range existing {
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		if _, ok := applied[key]; ok || key == LastAppliedAnnotationKey {
+			continue
+		}
+		if !copied {
+			merged, copied = make(map[string]string, len(desired)+1), true
+			for k, v := range desired {
+				merged[k] = v
+			}
+		}
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,73 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_RecordLastApplied(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			UID:       "uid",
+			Annotations: map[string]string{
+				IngressAnnotationsAnnotationKey: "nginx.ingress.kubernetes.io/proxy-body-size: 8m",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 80}},
+		},
+	}
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(svc)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:           "ingress",
+		Namespace:         "main",
+		Domain:            "my-domain.com",
+		RecordLastApplied: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(svc))
+	setIngressVersions(t, client)
+
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	last, err := LastAppliedIngress(ingress)
+	require.NoError(t, err)
+	require.NotNil(t, last)
+	assert.Equal(t, ingress.Spec, last.Spec)
+	assert.Equal(t, ingress.Labels, last.Labels)
+	assert.Equal(t, "8m", last.Annotations["nginx.ingress.kubernetes.io/proxy-body-size"])
+	assert.NotContains(t, last.Annotations, LastAppliedAnnotationKey)
+
+	// added by another tool
+	ingress.Annotations["external-dns.alpha.kubernetes.io/ttl"] = "60"
+	ingress.Labels["team"] = "web"
+	_, err = client.NetworkingV1().Ingresses("main").Update(ctx, ingress, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	svc.Annotations = map[string]string{}
+	require.NoError(t, strategy.Add(svc))
+	ingress, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, ingress.Annotations, "nginx.ingress.kubernetes.io/proxy-body-size", "not desired anymore")
+	assert.Equal(t, "60", ingress.Annotations["external-dns.alpha.kubernetes.io/ttl"], "never applied")
+	assert.Equal(t, "web", ingress.Labels["team"])
+	last, err = LastAppliedIngress(ingress)
+	require.NoError(t, err)
+	assert.NotContains(t, last.Annotations, "external-dns.alpha.kubernetes.io/ttl")
+
+	delete(ingress.Annotations, LastAppliedAnnotationKey)
+	last, err = LastAppliedIngress(ingress)
+	require.NoError(t, err)
+	assert.Nil(t, last, "not recorded")
+}
@@ -0,0 +1,45 @@
+package exposestrategy
+
+import (
+	"sort"
+	"strings"
+
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// legacyNginxAnnotationPrefix is the prefix of the annotations of the nginx ingress controller before 0.9
+const legacyNginxAnnotationPrefix = "ingress.kubernetes.io/"
+
+// migrateLegacyAnnotations renames the annotations spelled with the legacy prefix of the nginx ingress controller,
+// those already spelled with the current prefix take precedence over them, the migrated keys are returned sorted
+func migrateLegacyAnnotations(annotations map[string]string) []string {
+	var migrated []string
+	for key, value := range annotations {
+		if !strings.HasPrefix(key, legacyNginxAnnotationPrefix) {
+			continue
+		}
+		current := nginxAnnotationPrefix + strings.TrimPrefix(key, legacyNginxAnnotationPrefix)
+		if _, ok := annotations[current]; !ok {
+			annotations[current] = value
+		}
+		delete(annotations, key)
+		migrated = append(migrated, key)
+	}
+	sort.Strings(migrated)
+	return migrated
+}
+
+// reportMigratedAnnotations tells the owners of the service that the legacy annotations of its ingress were migrated
+func (s *IngressStrategy) reportMigratedAnnotations(svc *v1.Service, ingress *networkingv1.Ingress, migrated []string) {
+	if len(migrated) == 0 {
+		return
+	}
+	klog.Infof("the legacy annotations %s of ingress %s/%s of service %s/%s are migrated to the %s prefix",
+		strings.Join(migrated, ", "), ingress.Namespace, ingress.Name, svc.Namespace, svc.Name, nginxAnnotationPrefix)
+	EmitServiceEvent(s.ctx, s.client, svc, v1.EventTypeNormal, "MigratedAnnotations",
+		"the legacy annotations "+strings.Join(migrated, ", ")+" of ingress "+ingress.Namespace+"/"+ingress.Name+
+			" are migrated to the "+nginxAnnotationPrefix+" prefix")
+}
@@ -0,0 +1,71 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_MigrateLegacyAnnotations(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				IngressAnnotationsAnnotationKey: "ingress.kubernetes.io/rewrite-target: /\n" +
+					"ingress.kubernetes.io/ssl-redirect: \"false\"\n" +
+					"nginx.ingress.kubernetes.io/ssl-redirect: \"true\"",
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(service)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:                  "ingress",
+		Namespace:                "main",
+		Domain:                   "my-domain.com",
+		MigrateLegacyAnnotations: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(service))
+	require.NoError(t, strategy.Add(service))
+
+	ctx := context.Background()
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"fabric8.io/generated-by":                    "exposecontroller",
+		"nginx.ingress.kubernetes.io/rewrite-target": "/",
+		"nginx.ingress.kubernetes.io/ssl-redirect":   "true",
+	}, ingress.Annotations, "the current spelling takes precedence")
+	events, err := client.CoreV1().Events("main").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1, "the up to date ingress is not reported again")
+	assert.Equal(t, "MigratedAnnotations", events.Items[0].Reason)
+	assert.Equal(t, "the legacy annotations ingress.kubernetes.io/rewrite-target, ingress.kubernetes.io/ssl-redirect "+
+		"of ingress main/svc are migrated to the nginx.ingress.kubernetes.io/ prefix", events.Items[0].Message)
+}
+
+func TestMigrateLegacyAnnotations(t *testing.T) {
+	annotations := map[string]string{
+		"kubernetes.io/ingress.class":           "nginx",
+		"ingress.kubernetes.io/auth-type":       "basic",
+		"nginx.ingress.kubernetes.io/auth-type": "digest",
+	}
+	assert.Equal(t, []string{"ingress.kubernetes.io/auth-type"}, migrateLegacyAnnotations(annotations))
+	assert.Equal(t, map[string]string{
+		"kubernetes.io/ingress.class":           "nginx",
+		"nginx.ingress.kubernetes.io/auth-type": "digest",
+	}, annotations)
+	assert.Empty(t, migrateLegacyAnnotations(annotations))
+}
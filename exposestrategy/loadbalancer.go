@@ -3,6 +3,8 @@ package exposestrategy
 import (
 	"context"
 	"fmt"
+	"net"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/pkg/errors"
@@ -17,13 +19,24 @@ type LoadBalancerStrategy struct {
 	client kubernetes.Interface
 	// The services to wait for their load balancer IP
 	todo map[string]bool
+	// sourceRanges are the default source ranges of the load balancers, overridden by the annotation of the namespaces
+	sourceRanges      []string
+	permissionProfile string
 }
 
 // NewLoadBalancerStrategy a new LoadBalancerStrategy
 func NewLoadBalancerStrategy(ctx context.Context, client kubernetes.Interface, config *Config) (ExposeStrategy, error) {
+	for _, sourceRange := range config.LoadBalancerSourceRanges {
+		if _, _, err := net.ParseCIDR(sourceRange); err != nil {
+			return nil, errors.Wrapf(err, "invalid load balancer source range \"%s\"", sourceRange)
+		}
+	}
 	return &LoadBalancerStrategy{
 		ctx:    ctx,
 		client: client,
+
+		sourceRanges:      config.LoadBalancerSourceRanges,
+		permissionProfile: config.PermissionProfile,
 	}, nil
 }
 
@@ -46,13 +59,41 @@ func (s *LoadBalancerStrategy) HasSynced() bool {
 func (s *LoadBalancerStrategy) Add(svc *v1.Service) error {
 	delete(s.todo, fmt.Sprintf("%s/%s", svc.Namespace, svc.Name))
 
-	var err error
 	clone := svc.DeepCopy()
 	clone.Spec.Type = v1.ServiceTypeLoadBalancer
-	err = addServiceAnnotation(clone, clone.Spec.LoadBalancerIP)
+	// the default source ranges of the namespace restrict the load balancer
+	ranges, err := s.defaultSourceRanges(svc)
+	if err != nil {
+		return err
+	}
+	err = applySourceRanges(svc, clone, ranges)
+	if err != nil {
+		return err
+	}
+	// the non HTTP ports are published with their port and scheme, such as "tcp://1.2.3.4:5432"
+	port := exposedPort(clone)
+	scheme, err := portScheme(clone, port)
+	if err != nil {
+		return err
+	}
+	switch {
+	case scheme == "":
+		err = addServiceAnnotation(clone, clone.Spec.LoadBalancerIP)
+	case isHTTPScheme(scheme):
+		err = addServiceAnnotationWithProtocol(clone, clone.Spec.LoadBalancerIP, "", scheme)
+	case port == nil:
+		// the URL of a non HTTP scheme needs the port, such as a "tcp" expose protocol on a service without port
+		return newServiceError(svc, ErrNoPorts, "no port to expose in service %s/%s", svc.Namespace, svc.Name)
+	default:
+		err = addServiceAnnotationWithPort(clone, clone.Spec.LoadBalancerIP, port.Port, "", scheme)
+	}
 	if err != nil {
 		return errors.Wrap(err, "failed to add service annotation")
 	}
+	err = setServiceURLs(clone, loadBalancerURLs(clone))
+	if err != nil {
+		return err
+	}
 
 	patch, err := createServicePatch(svc, clone)
 	if err != nil {
@@ -82,6 +123,7 @@ func (s *LoadBalancerStrategy) Clean(svc *v1.Service) error {
 		return nil
 	}
 	clone.Spec.Type = v1.ServiceTypeClusterIP
+	restoreSourceRanges(clone)
 
 	patch, err := createServicePatch(svc, clone)
 	if err != nil {
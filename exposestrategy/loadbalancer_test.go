@@ -8,6 +8,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -224,3 +225,31 @@ func TestLoadBalancerStrategy_Delete(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, strategy.HasSynced(), "unsynced")
 }
+
+func TestLoadBalancerStrategy_AddNoPorts(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "svc",
+			Annotations: map[string]string{
+				ExposeProtocolAnnotationKey: protocolTCP,
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Type:      v1.ServiceTypeClusterIP,
+			ClusterIP: "my-cluster-ip",
+		},
+	}
+	client := fake.NewSimpleClientset(svc)
+	strategy, err := NewLoadBalancerStrategy(nil, client, &Config{})
+	require.NoError(t, err)
+	err = strategy.Sync()
+	require.NoError(t, err)
+	client.ClearActions()
+	err = strategy.Add(svc)
+	assert.True(t, errors.Is(err, ErrNoPorts), "no ports error: %v", err)
+	assert.False(t, Retryable(err), "not retryable")
+	for _, action := range client.Actions() {
+		assert.False(t, action.Matches("patch", "services"), "service not patched")
+	}
+}
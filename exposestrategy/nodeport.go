@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
+	"k8s.io/klog"
 
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/clock"
 )
 
 // NodePortStrategy is a strategy that changes the type of services to NodePort
@@ -18,45 +21,93 @@ type NodePortStrategy struct {
 	ctx    context.Context
 	client kubernetes.Interface
 
+	// the node IP is discovered from the nodes when first needed if not configured
 	nodeIP string
-	// The services to wait for their node port
-	todo map[string]bool
+	// The services to wait for their node port, by the time they started waiting
+	todo map[string]nodePortTodo
+	// the services waiting longer than the deadline are given up
+	deadline time.Duration
+	clock    clock.PassiveClock
 }
 
+// nodePortTodo is a service waiting for its node port
+type nodePortTodo struct {
+	svc   *v1.Service
+	since time.Time
+}
+
+// defaultNodePortDeadline is how long the services wait for their node port by default
+const defaultNodePortDeadline = 5 * time.Minute
+
 // ExternalIPLabel is the node's label to export the external IP of the cluster
 const ExternalIPLabel = "fabric8.io/externalIP"
 
 // NewNodePortStrategy creates a new NodePortStrategy
 func NewNodePortStrategy(ctx context.Context, client kubernetes.Interface, config *Config) (ExposeStrategy, error) {
-	ip := config.NodeIP
-	if len(ip) == 0 {
-		l, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if config.NodeIP == "" && config.PermissionProfile == PermissionProfileNamespace {
+		return nil, errors.New("the node IP must be configured with the namespace permission profile, the nodes cannot be listed")
+	}
+	deadline := defaultNodePortDeadline
+	if config.NodePortDeadline != "" {
+		var err error
+		deadline, err = time.ParseDuration(config.NodePortDeadline)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to list nodes")
-		}
-
-		if len(l.Items) != 1 {
-			return nil, errors.Errorf("node port strategy can only be used with single node clusters - found %d nodes", len(l.Items))
+			return nil, errors.Wrapf(err, "invalid node port deadline \"%s\"", config.NodePortDeadline)
 		}
-
-		n := l.Items[0]
-		ip = n.ObjectMeta.Labels[ExternalIPLabel]
-		if len(ip) == 0 {
-			addr, err := getNodeHostIP(n)
-			if err != nil {
-				return nil, errors.Wrap(err, "cannot discover node IP")
-			}
-			ip = addr.String()
+		if deadline <= 0 {
+			return nil, errors.Errorf("invalid node port deadline \"%s\", must be positive", config.NodePortDeadline)
 		}
 	}
-
+	var passiveClock clock.PassiveClock = clock.RealClock{}
+	if config.Clock != nil {
+		passiveClock = config.Clock
+	}
 	return &NodePortStrategy{
-		ctx:    ctx,
-		client: client,
-		nodeIP: ip,
+		ctx:      ctx,
+		client:   client,
+		nodeIP:   config.NodeIP,
+		deadline: deadline,
+		clock:    passiveClock,
 	}, nil
 }
 
+// getNodeIP returns the configured node IP, or discovers it from the single node of the cluster
+func (s *NodePortStrategy) getNodeIP() (string, error) {
+	if s.nodeIP != "" {
+		return s.nodeIP, nil
+	}
+	ip, err := discoverNodeIP(s.ctx, s.client)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to discover the node IP")
+	}
+	s.nodeIP = ip
+	return ip, nil
+}
+
+// discoverNodeIP returns the IP of the single node of the cluster
+// the external IP label of the node takes precedence over its addresses
+func discoverNodeIP(ctx context.Context, client kubernetes.Interface) (string, error) {
+	l, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list nodes")
+	}
+
+	if len(l.Items) != 1 {
+		return "", errors.Errorf("the node IP can only be discovered in single node clusters - found %d nodes", len(l.Items))
+	}
+
+	n := l.Items[0]
+	ip := n.ObjectMeta.Labels[ExternalIPLabel]
+	if len(ip) == 0 {
+		addr, err := getNodeHostIP(n)
+		if err != nil {
+			return "", errors.Wrap(err, "cannot discover node IP")
+		}
+		ip = addr.String()
+	}
+	return ip, nil
+}
+
 // getNodeHostIP returns the provided node's IP, based on the priority:
 // 1. NodeExternalIP
 // 2. NodeInternalIP
@@ -78,13 +129,23 @@ func getNodeHostIP(node v1.Node) (net.IP, error) {
 // Sync is called before starting / resyncing
 // init the todo map
 func (s *NodePortStrategy) Sync() error {
-	s.todo = map[string]bool{}
+	s.todo = map[string]nodePortTodo{}
 	return nil
 }
 
 // HasSynced tells if the strategy is complete
-// Complete when todo is empty
+// Complete when todo is empty, once the services waiting past the deadline are given up
 func (s *NodePortStrategy) HasSynced() bool {
+	now := s.clock.Now()
+	for key, todo := range s.todo {
+		if now.Sub(todo.since) < s.deadline {
+			continue
+		}
+		delete(s.todo, key)
+		message := fmt.Sprintf("No node port was assigned to the service after %v, it may not be of type NodePort anymore", s.deadline)
+		klog.Errorf("Giving up waiting for the node port of service %s: %s", key, message)
+		EmitServiceEvent(s.ctx, s.client, todo.svc, v1.EventTypeWarning, "NodePortTimeout", message)
+	}
 	return len(s.todo) == 0
 }
 
@@ -92,15 +153,16 @@ func (s *NodePortStrategy) HasSynced() bool {
 // Changes the service type and updates various annotations
 // Adds the service to the todo list if the node port is unknown
 func (s *NodePortStrategy) Add(svc *v1.Service) error {
-	delete(s.todo, fmt.Sprintf("%s/%s", svc.Namespace, svc.Name))
-
-	var err error
-	clone := svc.DeepCopy()
-	clone.Spec.Type = v1.ServiceTypeNodePort
-	clone.Spec.ExternalIPs = nil
+	key := fmt.Sprintf("%s/%s", svc.Namespace, svc.Name)
+	// a service keeps waiting since it was first added
+	since := s.clock.Now()
+	if todo, ok := s.todo[key]; ok {
+		since = todo.since
+	}
+	delete(s.todo, key)
 
 	if len(svc.Spec.Ports) == 0 {
-		return errors.Errorf(
+		return newServiceError(svc, ErrNoPorts,
 			"service %s/%s has no ports specified. Node port strategy requires a node port",
 			svc.Namespace, svc.Name,
 		)
@@ -113,14 +175,38 @@ func (s *NodePortStrategy) Add(svc *v1.Service) error {
 		)
 	}
 
+	// the spec is recorded before it is changed the first time
+	clone := svc.DeepCopy()
+	err := recordNodePortSpec(svc, clone)
+	if err != nil {
+		return err
+	}
+	clone.Spec.Type = v1.ServiceTypeNodePort
+	clone.Spec.ExternalIPs = nil
+
 	port := svc.Spec.Ports[0]
 	portInt := int(port.NodePort)
 	if portInt > 0 {
+		var nodeIP string
+		nodeIP, err = s.getNodeIP()
+		if err != nil {
+			return err
+		}
 		nodePort := strconv.Itoa(portInt)
-		hostName := net.JoinHostPort(s.nodeIP, nodePort)
-		err = addServiceAnnotation(clone, hostName)
+		hostName := net.JoinHostPort(nodeIP, nodePort)
+		// the non HTTP ports are published with their own scheme, such as "tcp://"
+		var scheme string
+		scheme, err = portScheme(svc, &port)
+		if err != nil {
+			return err
+		}
+		if scheme == "" {
+			err = addServiceAnnotation(clone, hostName)
+		} else {
+			err = addServiceAnnotationWithProtocol(clone, hostName, "", scheme)
+		}
 	} else {
-		s.todo[fmt.Sprintf("%s/%s", svc.Namespace, svc.Name)] = true
+		s.todo[key] = nodePortTodo{svc: svc, since: since}
 		err = addServiceAnnotation(clone, "")
 	}
 	if err != nil {
@@ -139,21 +225,22 @@ func (s *NodePortStrategy) Add(svc *v1.Service) error {
 	}
 
 	if portInt <= 0 {
-		s.todo[fmt.Sprintf("%s/%s", svc.Namespace, svc.Name)] = true
+		s.todo[key] = nodePortTodo{svc: svc, since: since}
 	}
 	return nil
 }
 
 // Clean is called when an exposed service is unexposed
-// Restores the service type and cleans various annotations
+// Restores the recorded spec of the service, ClusterIP if none, and cleans various annotations
 // Clears the service form the todo list
 func (s *NodePortStrategy) Clean(svc *v1.Service) error {
 	delete(s.todo, fmt.Sprintf("%s/%s", svc.Namespace, svc.Name))
 	clone := svc.DeepCopy()
-	if !removeServiceAnnotation(clone) {
+	exposed := removeServiceAnnotation(clone)
+	recorded := restoreNodePortSpec(clone)
+	if !exposed && !recorded {
 		return nil
 	}
-	clone.Spec.Type = v1.ServiceTypeClusterIP
 
 	patch, err := createServicePatch(svc, clone)
 	if err != nil {
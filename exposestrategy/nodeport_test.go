@@ -3,10 +3,12 @@ package exposestrategy
 import (
 	"context"
 	"testing"
+	"time"
 
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -23,13 +25,13 @@ func TestNodePortStrategy_NodeIP(t *testing.T) {
 	})
 	strategy, err := NewNodePortStrategy(nil, client, &Config{})
 	if assert.NoError(t, err) {
-		assert.Equal(t, "my-external-ip", strategy.(*NodePortStrategy).nodeIP)
+		assert.Equal(t, "my-external-ip", nodeIP(t, strategy))
 	}
 	strategy, err = NewNodePortStrategy(nil, client, &Config{
 		NodeIP: "my-node-ip",
 	})
 	if assert.NoError(t, err) {
-		assert.Equal(t, "my-node-ip", strategy.(*NodePortStrategy).nodeIP)
+		assert.Equal(t, "my-node-ip", nodeIP(t, strategy))
 	}
 
 	client = fake.NewSimpleClientset(&v1.Node{
@@ -48,13 +50,13 @@ func TestNodePortStrategy_NodeIP(t *testing.T) {
 	})
 	strategy, err = NewNodePortStrategy(nil, client, &Config{})
 	if assert.NoError(t, err) {
-		assert.Equal(t, "192.168.1.200", strategy.(*NodePortStrategy).nodeIP)
+		assert.Equal(t, "192.168.1.200", nodeIP(t, strategy))
 	}
 	strategy, err = NewNodePortStrategy(nil, client, &Config{
 		NodeIP: "my-node-ip",
 	})
 	if assert.NoError(t, err) {
-		assert.Equal(t, "my-node-ip", strategy.(*NodePortStrategy).nodeIP)
+		assert.Equal(t, "my-node-ip", nodeIP(t, strategy))
 	}
 
 	client = fake.NewSimpleClientset(&v1.Node{
@@ -70,13 +72,13 @@ func TestNodePortStrategy_NodeIP(t *testing.T) {
 	})
 	strategy, err = NewNodePortStrategy(nil, client, &Config{})
 	if assert.NoError(t, err) {
-		assert.Equal(t, "192.168.1.100", strategy.(*NodePortStrategy).nodeIP)
+		assert.Equal(t, "192.168.1.100", nodeIP(t, strategy))
 	}
 	strategy, err = NewNodePortStrategy(nil, client, &Config{
 		NodeIP: "my-node-ip",
 	})
 	if assert.NoError(t, err) {
-		assert.Equal(t, "my-node-ip", strategy.(*NodePortStrategy).nodeIP)
+		assert.Equal(t, "my-node-ip", nodeIP(t, strategy))
 	}
 
 	client = fake.NewSimpleClientset(&v1.Node{
@@ -85,15 +87,54 @@ func TestNodePortStrategy_NodeIP(t *testing.T) {
 		},
 	})
 	strategy, err = NewNodePortStrategy(nil, client, &Config{})
-	assert.Error(t, err)
+	if assert.NoError(t, err) {
+		_, err = strategy.(*NodePortStrategy).getNodeIP()
+		assert.Error(t, err)
+	}
 	strategy, err = NewNodePortStrategy(nil, client, &Config{
 		NodeIP: "my-node-ip",
 	})
 	if assert.NoError(t, err) {
-		assert.Equal(t, "my-node-ip", strategy.(*NodePortStrategy).nodeIP)
+		assert.Equal(t, "my-node-ip", nodeIP(t, strategy))
 	}
 }
 
+func nodeIP(t *testing.T, strategy ExposeStrategy) string {
+	ip, err := strategy.(*NodePortStrategy).getNodeIP()
+	assert.NoError(t, err)
+	return ip
+}
+
+func TestNodePortStrategy_PermissionProfile(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	_, err := NewNodePortStrategy(nil, client, &Config{
+		PermissionProfile: PermissionProfileNamespace,
+	})
+	assert.Error(t, err, "node IP required")
+	strategy, err := NewNodePortStrategy(nil, client, &Config{
+		PermissionProfile: PermissionProfileNamespace,
+		NodeIP:            "my-node-ip",
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "my-node-ip", nodeIP(t, strategy))
+	}
+	for _, action := range client.Actions() {
+		assert.NotEqual(t, "nodes", action.GetResource().Resource, "no node access")
+	}
+
+	_, err = New(nil, client, &Config{
+		Exposer:           "ingress",
+		PermissionProfile: PermissionProfileNamespace,
+	})
+	assert.Error(t, err, "domain required")
+	_, err = New(nil, client, &Config{
+		Exposer:           "ingress",
+		Domain:            "my-domain.com",
+		PermissionProfile: "none",
+	})
+	assert.Error(t, err, "unknown profile")
+}
+
 func TestNodePortStrategy_Add(t *testing.T) {
 	client := fake.NewSimpleClientset(&v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
@@ -138,8 +179,9 @@ func TestNodePortStrategy_Add(t *testing.T) {
 				Namespace: "ns",
 				Name:      "svc",
 				Annotations: map[string]string{
-					"test":              "test",
-					ExposeAnnotationKey: "",
+					"test":                            "test",
+					ExposeAnnotationKey:               "",
+					NodePortOriginalSpecAnnotationKey: `{"type":"ClusterIP"}`,
 				},
 			},
 			Spec: v1.ServiceSpec{
@@ -310,6 +352,80 @@ func TestNodePortStrategy_Clean(t *testing.T) {
 	assert.True(t, strategy.HasSynced(), "synced")
 }
 
+func TestNodePortStrategy_OriginalSpec(t *testing.T) {
+	ctx := context.Background()
+	original := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "svc",
+			Annotations: map[string]string{"test": "test"},
+		},
+		Spec: v1.ServiceSpec{
+			Type:        v1.ServiceTypeNodePort,
+			ExternalIPs: []string{"10.0.0.1"},
+			Ports: []v1.ServicePort{{
+				Name:     "http",
+				Port:     80,
+				NodePort: 30080,
+			}},
+		},
+	}
+	client := fake.NewSimpleClientset(original.DeepCopy())
+	strategy, err := NewNodePortStrategy(nil, client, &Config{NodeIP: "my-node-ip"})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+
+	require.NoError(t, strategy.Add(original.DeepCopy()))
+	svc, err := client.CoreV1().Services("ns").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, svc.Spec.ExternalIPs, "the external IPs are cleared while exposed")
+	assert.Equal(t, `{"type":"NodePort","externalIPs":["10.0.0.1"],"nodePorts":{"http":30080}}`,
+		svc.Annotations[NodePortOriginalSpecAnnotationKey])
+	assert.Equal(t, "http://my-node-ip:30080", svc.Annotations[ExposeAnnotationKey])
+
+	// exposing it again keeps the first record
+	require.NoError(t, strategy.Add(svc.DeepCopy()))
+	svc, err = client.CoreV1().Services("ns").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, `{"type":"NodePort","externalIPs":["10.0.0.1"],"nodePorts":{"http":30080}}`,
+		svc.Annotations[NodePortOriginalSpecAnnotationKey])
+
+	// the node port was changed meanwhile
+	svc.Spec.Ports[0].NodePort = 31000
+	require.NoError(t, strategy.Clean(svc.DeepCopy()))
+	svc, err = client.CoreV1().Services("ns").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"test": "test"}, svc.Annotations)
+	assert.Equal(t, original.Spec, svc.Spec, "the spec is restored")
+}
+
+func TestRestoreNodePortSpec(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "svc",
+			Annotations: map[string]string{
+				NodePortOriginalSpecAnnotationKey: "{",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Type:  v1.ServiceTypeNodePort,
+			Ports: []v1.ServicePort{{Port: 80, NodePort: 30080}},
+		},
+	}
+	assert.True(t, restoreNodePortSpec(svc))
+	assert.Equal(t, v1.ServiceTypeClusterIP, svc.Spec.Type, "invalid record")
+	assert.Equal(t, int32(0), svc.Spec.Ports[0].NodePort, "no node port for a ClusterIP service")
+	assert.Empty(t, svc.Annotations)
+
+	svc.Annotations[NodePortOriginalSpecAnnotationKey] = `{"type":"LoadBalancer","nodePorts":{"80":30080}}`
+	assert.True(t, restoreNodePortSpec(svc))
+	assert.Equal(t, v1.ServiceTypeLoadBalancer, svc.Spec.Type)
+	assert.Equal(t, int32(30080), svc.Spec.Ports[0].NodePort, "by port number")
+
+	assert.False(t, restoreNodePortSpec(svc), "not recorded")
+}
+
 func TestNodePortStrategy_Delete(t *testing.T) {
 	svc := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
@@ -349,3 +465,48 @@ func TestNodePortStrategy_Delete(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, strategy.HasSynced(), "unsynced")
 }
+
+func TestNodePortStrategy_Deadline(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "svc",
+		},
+		Spec: v1.ServiceSpec{
+			Type: v1.ServiceTypeClusterIP,
+			Ports: []v1.ServicePort{{
+				Port: 1234,
+			}},
+		},
+	}
+	client := fake.NewSimpleClientset(svc.DeepCopy())
+	clock := clocktesting.NewFakePassiveClock(time.Now())
+	strategy, err := NewNodePortStrategy(nil, client, &Config{
+		NodeIP:           "my-node-ip",
+		NodePortDeadline: "1m",
+		Clock:            clock,
+	})
+	require.NoError(t, err)
+	s := strategy.(*NodePortStrategy)
+	require.NoError(t, s.Sync())
+
+	require.NoError(t, s.Add(svc.DeepCopy()))
+	clock.SetTime(clock.Now().Add(30 * time.Second))
+	// adding the service again does not reset its deadline
+	require.NoError(t, s.Add(svc.DeepCopy()))
+	assert.False(t, s.HasSynced(), "waiting for the node port")
+	clock.SetTime(clock.Now().Add(30 * time.Second))
+	assert.True(t, s.HasSynced(), "given up after the deadline")
+	assert.Empty(t, s.todo)
+	events, err := client.CoreV1().Events("ns").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	assert.Equal(t, "NodePortTimeout", events.Items[0].Reason)
+	assert.Equal(t, v1.EventTypeWarning, events.Items[0].Type)
+	assert.Equal(t, "svc", events.Items[0].InvolvedObject.Name)
+
+	_, err = NewNodePortStrategy(nil, client, &Config{NodeIP: "my-node-ip", NodePortDeadline: "soon"})
+	assert.Error(t, err)
+	_, err = NewNodePortStrategy(nil, client, &Config{NodeIP: "my-node-ip", NodePortDeadline: "-1m"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,90 @@
+package exposestrategy
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+)
+
+// NodePortOriginalSpecAnnotationKey annotation records the spec of the service changed by the NodePort strategy,
+// restored when the service is unexposed
+const NodePortOriginalSpecAnnotationKey = "fabric8.io/nodeport.original-spec"
+
+// nodePortOriginalSpec is the part of the spec of a service changed by the NodePort strategy
+type nodePortOriginalSpec struct {
+	Type        v1.ServiceType `json:"type"`
+	ExternalIPs []string       `json:"externalIPs,omitempty"`
+	// NodePorts are the node ports assigned before the exposure, by port name or number
+	NodePorts map[string]int32 `json:"nodePorts,omitempty"`
+}
+
+// servicePortKey returns the name of the port, its number if unnamed
+func servicePortKey(port v1.ServicePort) string {
+	if port.Name != "" {
+		return port.Name
+	}
+	return strconv.Itoa(int(port.Port))
+}
+
+// recordNodePortSpec records the spec of the service in the annotation of the clone the first time it is exposed
+// nothing is recorded for the services exposed before, their spec was already changed
+func recordNodePortSpec(svc, clone *v1.Service) error {
+	_, recorded := svc.Annotations[NodePortOriginalSpecAnnotationKey]
+	_, exposed := svc.Annotations[ExposeAnnotationKey]
+	if recorded || exposed {
+		return nil
+	}
+	original := nodePortOriginalSpec{Type: svc.Spec.Type, ExternalIPs: svc.Spec.ExternalIPs}
+	if original.Type == "" {
+		original.Type = v1.ServiceTypeClusterIP
+	}
+	for _, port := range svc.Spec.Ports {
+		if port.NodePort > 0 {
+			if original.NodePorts == nil {
+				original.NodePorts = map[string]int32{}
+			}
+			original.NodePorts[servicePortKey(port)] = port.NodePort
+		}
+	}
+	data, err := json.Marshal(original)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode the spec of service %s/%s", svc.Namespace, svc.Name)
+	}
+	if clone.Annotations == nil {
+		clone.Annotations = map[string]string{}
+	}
+	clone.Annotations[NodePortOriginalSpecAnnotationKey] = string(data)
+	return nil
+}
+
+// restoreNodePortSpec restores the recorded spec in the clone and removes the annotation, tells if it was recorded
+// a service without a valid record becomes a ClusterIP service
+func restoreNodePortSpec(clone *v1.Service) bool {
+	text, ok := clone.Annotations[NodePortOriginalSpecAnnotationKey]
+	delete(clone.Annotations, NodePortOriginalSpecAnnotationKey)
+	original := nodePortOriginalSpec{Type: v1.ServiceTypeClusterIP}
+	if ok {
+		if err := json.Unmarshal([]byte(text), &original); err != nil || original.Type == "" {
+			klog.Warningf("invalid annotation \"%s\" in service %s/%s, restoring a ClusterIP service: %s",
+				NodePortOriginalSpecAnnotationKey, clone.Namespace, clone.Name, text)
+			original = nodePortOriginalSpec{Type: v1.ServiceTypeClusterIP}
+		}
+	}
+	clone.Spec.Type = original.Type
+	clone.Spec.ExternalIPs = original.ExternalIPs
+	for i := range clone.Spec.Ports {
+		port := &clone.Spec.Ports[i]
+		switch {
+		case original.Type == v1.ServiceTypeClusterIP:
+			// the node ports of a ClusterIP service are rejected
+			port.NodePort = 0
+		case original.NodePorts[servicePortKey(*port)] > 0:
+			port.NodePort = original.NodePorts[servicePortKey(*port)]
+		}
+	}
+	return ok
+}
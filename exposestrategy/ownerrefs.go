@@ -0,0 +1,96 @@
+package exposestrategy
+
+import (
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// serviceOwnerReference returns the owner reference of a generated ingress to the service
+func (s *IngressStrategy) serviceOwnerReference(name string, uid types.UID) metav1.OwnerReference {
+	reference := metav1.OwnerReference{
+		Kind:       ServiceKind,
+		APIVersion: ServiceAPIVersion,
+		Name:       name,
+		UID:        uid,
+	}
+	if s.ownerReferenceController {
+		reference.Controller = &s.ownerReferenceController
+	}
+	if s.ownerReferenceBlockOwnerDeletion {
+		reference.BlockOwnerDeletion = &s.ownerReferenceBlockOwnerDeletion
+	}
+	return reference
+}
+
+// serviceUID returns the UID of the service, read from the cluster if the service has none, such as built by hand
+// the garbage collector deletes the objects whose owner reference has another UID than the owner
+// it is empty if the service is not found
+func (s *IngressStrategy) serviceUID(svc *v1.Service) (types.UID, error) {
+	key := serviceKey(svc.Namespace, svc.Name)
+	if svc.UID != "" {
+		s.cacheServiceUID(key, svc.UID)
+		return svc.UID, nil
+	}
+	if uid, ok := s.serviceUIDs[key]; ok {
+		return uid, nil
+	}
+	return s.lookupServiceUID(svc.Namespace, svc.Name)
+}
+
+// lookupServiceUID reads the UID of the service from the cache of the services or the API server, and caches it
+func (s *IngressStrategy) lookupServiceUID(namespace, name string) (types.UID, error) {
+	var current *v1.Service
+	var err error
+	if s.serviceLister != nil {
+		current, err = s.serviceLister.Services(namespace).Get(name)
+	} else {
+		current, err = s.client.CoreV1().Services(namespace).Get(s.ctx, name, metav1.GetOptions{})
+	}
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrapf(err, "failed to get the UID of service %s/%s", namespace, name)
+	}
+	s.cacheServiceUID(serviceKey(namespace, name), current.UID)
+	return current.UID, nil
+}
+
+// cacheServiceUID records the UID of the service, the strategies built without constructor have no cache yet
+func (s *IngressStrategy) cacheServiceUID(key string, uid types.UID) {
+	if s.serviceUIDs == nil {
+		s.serviceUIDs = map[string]types.UID{}
+	}
+	s.serviceUIDs[key] = uid
+}
+
+// repairOwnerReference sets the UID of the service in the owner reference of the ingress missing it,
+// the ingresses generated before the UID was resolved are updated on sync
+func (s *IngressStrategy) repairOwnerReference(ingress *networkingv1.Ingress) {
+	if len(ingress.OwnerReferences) != 1 || ingress.OwnerReferences[0].UID != "" || isUnmanagedIngress(ingress, "repairing") {
+		return
+	}
+	name := ingress.OwnerReferences[0].Name
+	uid, err := s.lookupServiceUID(ingress.Namespace, name)
+	if err != nil {
+		klog.Errorf("could not repair the owner reference of ingress %s/%s: %s", ingress.Namespace, ingress.Name, err)
+		return
+	} else if uid == "" {
+		return
+	}
+	existing := ingress.DeepCopy()
+	ingress.OwnerReferences[0].UID = uid
+	klog.Infof("setting the UID of service %s in the owner reference of ingress %s/%s", name, ingress.Namespace, ingress.Name)
+	logIngressChange(existing, ingress)
+	updated, err := s.client.NetworkingV1().Ingresses(ingress.Namespace).Update(s.ctx, ingress, metav1.UpdateOptions{})
+	if err != nil {
+		klog.Errorf("failed to repair the owner reference of ingress %s/%s: %s", ingress.Namespace, ingress.Name, err)
+		return
+	}
+	*ingress = *updated
+}
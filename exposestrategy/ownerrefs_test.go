@@ -0,0 +1,72 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_OwnerReferenceUID(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			UID:       "uid-svc",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 80}},
+		},
+	}
+	legacy := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "main",
+			Name:        "legacy",
+			Labels:      map[string]string{"provider": "fabric8"},
+			Annotations: map[string]string{GeneratedByAnnotationKey: DefaultGeneratedBy},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: ServiceAPIVersion,
+				Kind:       ServiceKind,
+				Name:       "svc",
+			}},
+		},
+	}
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(svc, legacy)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:                          "ingress",
+		Namespace:                        "main",
+		Domain:                           "my-domain.com",
+		OwnerReferenceController:         true,
+		OwnerReferenceBlockOwnerDeletion: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	repaired, err := client.NetworkingV1().Ingresses("main").Get(ctx, "legacy", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, repaired.OwnerReferences, 1)
+	assert.Equal(t, types.UID("uid-svc"), repaired.OwnerReferences[0].UID, "repaired on sync")
+
+	// a service built without UID, such as from an event
+	require.NoError(t, strategy.Add(&v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "svc"}, Spec: svc.Spec}))
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, ingress.OwnerReferences, 1)
+	reference := ingress.OwnerReferences[0]
+	assert.Equal(t, types.UID("uid-svc"), reference.UID)
+	require.NotNil(t, reference.Controller)
+	assert.True(t, *reference.Controller)
+	require.NotNil(t, reference.BlockOwnerDeletion)
+	assert.True(t, *reference.BlockOwnerDeletion)
+
+	uid, err := strategy.(*IngressStrategy).lookupServiceUID("main", "missing")
+	require.NoError(t, err)
+	assert.Empty(t, uid, "not found")
+}
@@ -0,0 +1,67 @@
+package exposestrategy
+
+import (
+	"time"
+
+	"k8s.io/klog"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PendingDeleteAnnotationKey annotation tells when the ingress of an unexposed service was kept for the grace period
+// the ingress is deleted once the grace period is over, unless the service is exposed again
+const PendingDeleteAnnotationKey = "expose.fabric8.io/pending-delete"
+
+// deleteIngressAfterGrace deletes the ingress of an unexposed service, or keeps it during the grace period
+// it tells if the ingress is kept
+func (s *IngressStrategy) deleteIngressAfterGrace(ingress *networkingv1.Ingress) bool {
+	if isUnmanagedIngress(ingress, "deleting") {
+		return false
+	} else if s.deleteGracePeriod <= 0 {
+		deleteIngress(s.ctx, s.client, ingress, s.neverDelete, s.provider, s.deletePropagation)
+		return false
+	}
+	if _, ok := ingress.Annotations[PendingDeleteAnnotationKey]; ok {
+		// the grace period already started
+		s.isPendingDeleteOver(ingress)
+		return true
+	}
+	pending := ingress.DeepCopy()
+	if pending.Annotations == nil {
+		pending.Annotations = map[string]string{}
+	}
+	pending.Annotations[PendingDeleteAnnotationKey] = s.clock.Now().UTC().Format(time.RFC3339)
+	klog.Infof("keeping the ingress %s/%s for %v before deleting it", ingress.Namespace, ingress.Name, s.deleteGracePeriod)
+	logIngressChange(ingress, pending)
+	_, err := s.client.NetworkingV1().Ingresses(ingress.Namespace).Update(s.ctx, pending, metav1.UpdateOptions{})
+	if err != nil {
+		klog.Errorf("error when annotating ingress %s/%s for deletion, deleting it now: %s",
+			ingress.Namespace, ingress.Name, err)
+		deleteIngress(s.ctx, s.client, ingress, s.neverDelete, s.provider, s.deletePropagation)
+		return false
+	}
+	s.scheduleResync(s.deleteGracePeriod)
+	return true
+}
+
+// isPendingDeleteOver tells if the grace period of an ingress pending deletion is over
+// a resync is scheduled at the end of the grace period that is not over
+func (s *IngressStrategy) isPendingDeleteOver(ingress *networkingv1.Ingress) bool {
+	value, ok := ingress.Annotations[PendingDeleteAnnotationKey]
+	if !ok {
+		return false
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		klog.Warningf("invalid annotation %s=%s of ingress %s/%s, deleting it",
+			PendingDeleteAnnotationKey, value, ingress.Namespace, ingress.Name)
+		return true
+	}
+	remaining := s.deleteGracePeriod - s.clock.Now().Sub(since)
+	if remaining <= 0 {
+		return true
+	}
+	s.scheduleResync(remaining)
+	return false
+}
@@ -0,0 +1,89 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_DeleteGracePeriod(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "main",
+			Name:            "svc",
+			UID:             "uid",
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(service)
+	clock := clocktesting.NewFakePassiveClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	var resyncs []time.Duration
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:           "ingress",
+		Namespace:         "main",
+		Domain:            "my-domain.com",
+		DeleteGracePeriod: "10m",
+		Clock:             clock,
+		ScheduleResync: func(d time.Duration) {
+			resyncs = append(resyncs, d)
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(service))
+
+	ctx := context.Background()
+	// the fake client sets no resource version
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	ingress.ResourceVersion = "1"
+	_, err = client.NetworkingV1().Ingresses("main").Update(ctx, ingress, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	getAnnotation := func() (string, bool) {
+		ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+		require.NoError(t, err, "the ingress is kept")
+		value, ok := ingress.Annotations[PendingDeleteAnnotationKey]
+		return value, ok
+	}
+
+	// the annotation flaps
+	require.NoError(t, strategy.Clean(service))
+	value, ok := getAnnotation()
+	assert.True(t, ok)
+	assert.Equal(t, "2024-03-01T12:00:00Z", value)
+	assert.Equal(t, []time.Duration{10 * time.Minute}, resyncs)
+	require.NoError(t, strategy.Add(service))
+	_, ok = getAnnotation()
+	assert.False(t, ok, "the pending deletion is canceled")
+
+	// the annotation is removed for good
+	require.NoError(t, strategy.Clean(service))
+	clock.SetTime(clock.Now().Add(4 * time.Minute))
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Clean(service))
+	value, _ = getAnnotation()
+	assert.Equal(t, "2024-03-01T12:00:00Z", value, "the grace period is not restarted")
+	assert.Equal(t, []time.Duration{10 * time.Minute, 10 * time.Minute, 6 * time.Minute, 6 * time.Minute}, resyncs)
+	clock.SetTime(clock.Now().Add(6 * time.Minute))
+	require.NoError(t, strategy.Sync())
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "the ingress is deleted after the grace period")
+	assert.Empty(t, strategy.(*IngressStrategy).existing)
+
+	for _, period := range []string{"10", "-10m"} {
+		_, err = NewIngressStrategy(nil, client, &Config{Domain: "my-domain.com", DeleteGracePeriod: period})
+		assert.Error(t, err, period)
+	}
+}
@@ -0,0 +1,95 @@
+package exposestrategy
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// detectPodsLimit is the number of pods of a service inspected to detect its HTTP port
+const detectPodsLimit = 10
+
+// httpAppProtocols are the app protocols of the ports serving HTTP
+var httpAppProtocols = []string{"http", "https", "kubernetes.io/h2c"}
+
+// findExposePort returns the port of the service chosen by the expose port annotation, by number or by name,
+// nil without annotation or with a warning if the number is not a port of the service
+func findExposePort(svc *v1.Service, exposePort string) (*v1.ServicePort, error) {
+	if exposePort == "" {
+		return nil, nil
+	}
+	port, err := strconv.Atoi(exposePort)
+	for i, p := range svc.Spec.Ports {
+		if (err == nil && port == int(p.Port)) || (err != nil && exposePort == p.Name) {
+			return &svc.Spec.Ports[i], nil
+		}
+	}
+	if err != nil {
+		return nil, errors.Errorf("port \"%s\" provided in the annotation \"%s\" is neither a valid number nor a port name in service %s/%s",
+			exposePort, ExposePortAnnotationKey, svc.Namespace, svc.Name)
+	}
+	klog.Warningf("port \"%s\" provided in the annotation \"%s\" is not available in the ports of service %s/%s",
+		exposePort, ExposePortAnnotationKey, svc.Namespace, svc.Name)
+	return nil, nil
+}
+
+// detectExposePort chooses the HTTP port of a service, nil if none stands out
+// the ports with an HTTP app protocol come first, then the ports of the HTTP readiness probes of the pods
+func detectExposePort(ctx context.Context, client kubernetes.Interface, svc *v1.Service) *v1.ServicePort {
+	for i, port := range svc.Spec.Ports {
+		if port.AppProtocol != nil && contains(httpAppProtocols, *port.AppProtocol) {
+			return &svc.Spec.Ports[i]
+		}
+	}
+	if len(svc.Spec.Selector) == 0 {
+		return nil
+	}
+	pods, err := client.CoreV1().Pods(svc.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String(),
+		Limit:         detectPodsLimit,
+	})
+	if err != nil {
+		klog.Warningf("Failed to list the pods of service %s/%s to detect its port: %v", svc.Namespace, svc.Name, err)
+		return nil
+	}
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			probe := container.ReadinessProbe
+			if probe == nil || probe.HTTPGet == nil {
+				continue
+			}
+			number, name := probePort(container, probe.HTTPGet.Port)
+			for i, port := range svc.Spec.Ports {
+				target := port.TargetPort
+				if (target.Type == intstr.String && target.StrVal != "" && target.StrVal == name) ||
+					(target.Type == intstr.Int && target.IntVal == number) ||
+					(target.Type == intstr.Int && target.IntVal == 0 && port.Port == number) {
+					return &svc.Spec.Ports[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// probePort resolves the port of a probe to the number and name of the container port
+func probePort(container v1.Container, port intstr.IntOrString) (int32, string) {
+	for _, p := range container.Ports {
+		if (port.Type == intstr.String && p.Name == port.StrVal) || (port.Type == intstr.Int && p.ContainerPort == port.IntVal) {
+			return p.ContainerPort, p.Name
+		}
+	}
+	if port.Type == intstr.Int {
+		return port.IntVal, ""
+	}
+	return 0, port.StrVal
+}
@@ -0,0 +1,139 @@
+package exposestrategy
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newProbedPod(probe intstr.IntOrString, ports ...v1.ContainerPort) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "app-1",
+			Labels:    map[string]string{"app": "app"},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name:  "app",
+				Ports: ports,
+				ReadinessProbe: &v1.Probe{
+					ProbeHandler: v1.ProbeHandler{
+						HTTPGet: &v1.HTTPGetAction{Path: "/ready", Port: probe},
+					},
+				},
+			}},
+		},
+	}
+}
+
+func TestDetectExposePort(t *testing.T) {
+	http := "http"
+	examples := []struct {
+		name     string
+		ports    []v1.ServicePort
+		pods     []runtime.Object
+		expected int32
+	}{{
+		name: "app protocol",
+		ports: []v1.ServicePort{
+			{Name: "grpc", Port: 9090},
+			{Name: "web", Port: 80, AppProtocol: &http},
+		},
+		expected: 80,
+	}, {
+		name: "probe port number",
+		ports: []v1.ServicePort{
+			{Name: "grpc", Port: 9090, TargetPort: intstr.FromInt(9090)},
+			{Name: "web", Port: 80, TargetPort: intstr.FromInt(8080)},
+		},
+		pods:     []runtime.Object{newProbedPod(intstr.FromInt(8080))},
+		expected: 80,
+	}, {
+		name: "probe port name",
+		ports: []v1.ServicePort{
+			{Name: "grpc", Port: 9090, TargetPort: intstr.FromString("grpc")},
+			{Name: "web", Port: 80, TargetPort: intstr.FromString("http")},
+		},
+		pods: []runtime.Object{newProbedPod(intstr.FromInt(8080),
+			v1.ContainerPort{Name: "grpc", ContainerPort: 9090},
+			v1.ContainerPort{Name: "http", ContainerPort: 8080})},
+		expected: 80,
+	}, {
+		name: "probe named port and target number",
+		ports: []v1.ServicePort{
+			{Name: "grpc", Port: 9090},
+			{Name: "web", Port: 8080},
+		},
+		pods: []runtime.Object{newProbedPod(intstr.FromString("http"),
+			v1.ContainerPort{Name: "http", ContainerPort: 8080})},
+		expected: 8080,
+	}, {
+		name: "no pod",
+		ports: []v1.ServicePort{
+			{Name: "grpc", Port: 9090},
+			{Name: "web", Port: 80},
+		},
+	}}
+	for _, example := range examples {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "main",
+				Name:      "app",
+			},
+			Spec: v1.ServiceSpec{
+				Selector: map[string]string{"app": "app"},
+				Ports:    example.ports,
+			},
+		}
+		client := fake.NewSimpleClientset(example.pods...)
+		port := detectExposePort(nil, client, svc)
+		if example.expected == 0 {
+			assert.Nil(t, port, example.name)
+			continue
+		}
+		require.NotNil(t, port, example.name)
+		assert.Equal(t, example.expected, port.Port, example.name)
+	}
+}
+
+func TestIngressStrategy_DetectExposePort(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "app",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key: ExposeAnnotation.Value,
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Selector: map[string]string{"app": "app"},
+			Ports: []v1.ServicePort{
+				{Name: "metrics", Port: 9102},
+				{Name: "web", Port: 8080},
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(svc, newProbedPod(intstr.FromInt(8080)))
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:          "ingress",
+		Namespace:        "main",
+		Domain:           "my-domain.com",
+		DetectExposePort: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(svc))
+
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(nil, "app", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(8080), ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Port.Number)
+}
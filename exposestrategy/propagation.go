@@ -0,0 +1,29 @@
+package exposestrategy
+
+import (
+	"regexp"
+
+	"k8s.io/api/core/v1"
+)
+
+// annotationPropagation copies the annotations of the services whose keys match one of its patterns onto their ingresses,
+// such as the TTL of external-dns or the annotations of a monitoring stack
+type annotationPropagation []*regexp.Regexp
+
+// newAnnotationPropagation compiles the patterns, which match whole annotation keys
+// returns nil without patterns
+func newAnnotationPropagation(patterns []string) (annotationPropagation, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	return compileKeyPatterns(patterns, "annotation propagation")
+}
+
+// copy adds the propagated annotations of the service
+func (p annotationPropagation) copy(svc *v1.Service, annotations map[string]string) {
+	for key, value := range svc.Annotations {
+		if matchesKeyPatterns(p, key) {
+			annotations[key] = value
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_AnnotationPropagation(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key:                         ExposeAnnotation.Value,
+				"external-dns.alpha.kubernetes.io/ttl":       "60",
+				"external-dns.alpha.kubernetes.io/target":    "lb.my-domain.com",
+				"prometheus.io/probe":                        "true",
+				"prometheus.io/scrape":                       "true",
+				"nginx.ingress.kubernetes.io/server-snippet": "location / {}",
+				IngressAnnotationsAnnotationKey:              `prometheus.io/probe: "false"`,
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 80}},
+		},
+	}
+	client := fake.NewSimpleClientset(svc)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:   "ingress",
+		Namespace: "main",
+		Domain:    "my-domain.com",
+		AnnotationPropagation: []string{
+			"external-dns.alpha.kubernetes.io/ttl",
+			`prometheus\.io/.*`,
+			`nginx\.ingress\.kubernetes\.io/.*`,
+		},
+		AnnotationDenylist: []string{`nginx\.ingress\.kubernetes\.io/.*-snippet`},
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(svc))
+
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(context.Background(), "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "60", ingress.Annotations["external-dns.alpha.kubernetes.io/ttl"])
+	assert.NotContains(t, ingress.Annotations, "external-dns.alpha.kubernetes.io/target", "the keys match whole")
+	assert.Equal(t, "true", ingress.Annotations["prometheus.io/scrape"])
+	assert.Equal(t, "false", ingress.Annotations["prometheus.io/probe"], "the ingress annotations of the service take precedence")
+	assert.NotContains(t, ingress.Annotations, "nginx.ingress.kubernetes.io/server-snippet", "the denied annotations are not propagated")
+	assert.NotContains(t, ingress.Annotations, ExposeAnnotation.Key)
+}
+
+func TestNewAnnotationPropagation(t *testing.T) {
+	propagation, err := newAnnotationPropagation(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, propagation, "nothing propagated")
+	annotations := map[string]string{}
+	propagation.copy(&v1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"key": "value"}}}, annotations)
+	assert.Empty(t, annotations)
+
+	_, err = newAnnotationPropagation([]string{"*-ttl"})
+	assert.EqualError(t, err, "invalid annotation propagation pattern \"*-ttl\": error parsing regexp: missing argument to repetition operator: `*`")
+}
@@ -0,0 +1,108 @@
+package exposestrategy
+
+import (
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+)
+
+// ExposeProtocolAnnotationKey annotation overrides the detected protocol of the exposed port,
+// "http", "https", "tcp" or "udp", the ingresses only expose HTTP ports
+const ExposeProtocolAnnotationKey = "fabric8.io/expose.protocol"
+
+const (
+	protocolHTTP  = "http"
+	protocolHTTPS = "https"
+	protocolTCP   = "tcp"
+	protocolUDP   = "udp"
+)
+
+// nonHTTPPorts are the well-known ports of the databases, brokers and other servers not speaking HTTP
+var nonHTTPPorts = map[int32]bool{
+	21:    true, // FTP
+	22:    true, // SSH
+	25:    true, // SMTP
+	53:    true, // DNS
+	389:   true, // LDAP
+	636:   true, // LDAPS
+	1433:  true, // SQL Server
+	1521:  true, // Oracle
+	2181:  true, // ZooKeeper
+	3306:  true, // MySQL
+	4222:  true, // NATS
+	5432:  true, // PostgreSQL
+	5671:  true, // AMQPS
+	5672:  true, // AMQP
+	6379:  true, // Redis
+	9042:  true, // Cassandra
+	9092:  true, // Kafka
+	11211: true, // Memcached
+	27017: true, // MongoDB
+}
+
+// portScheme returns the scheme of the URL of the exposed port, from the protocol annotation of the service
+// or detected from the port, empty for HTTP
+func portScheme(svc *v1.Service, port *v1.ServicePort) (string, error) {
+	if value, ok := svc.Annotations[ExposeProtocolAnnotationKey]; ok {
+		switch value {
+		case protocolHTTP, protocolHTTPS, protocolTCP, protocolUDP:
+			return value, nil
+		default:
+			return "", newAnnotationParseError(svc, ExposeProtocolAnnotationKey, value,
+				errors.Errorf("invalid protocol \"%s\", must be \"%s\", \"%s\", \"%s\" or \"%s\"",
+					value, protocolHTTP, protocolHTTPS, protocolTCP, protocolUDP))
+		}
+	}
+	return detectPortScheme(port), nil
+}
+
+// detectPortScheme returns the scheme of the port from its "tcp" or "udp" app protocol, the UDP and SCTP protocols
+// and the well-known ports, empty for HTTP
+func detectPortScheme(port *v1.ServicePort) string {
+	if port == nil {
+		return ""
+	}
+	switch {
+	case appProtocol(port) == protocolTCP:
+		return protocolTCP
+	case appProtocol(port) == protocolUDP || port.Protocol == v1.ProtocolUDP:
+		return protocolUDP
+	case port.Protocol == v1.ProtocolSCTP:
+		return "sctp"
+	case appProtocol(port) == "" && nonHTTPPorts[port.Port]:
+		return protocolTCP
+	}
+	return ""
+}
+
+// isHTTPScheme tells if the scheme of the port is HTTP, the empty scheme of the detected HTTP ports included
+func isHTTPScheme(scheme string) bool {
+	return scheme == "" || scheme == protocolHTTP || scheme == protocolHTTPS
+}
+
+// checkHTTPPort returns an ErrNotHTTP error if the exposed port of the service does not speak HTTP
+func checkHTTPPort(svc *v1.Service, port *v1.ServicePort) error {
+	scheme, err := portScheme(svc, port)
+	if err != nil {
+		return err
+	}
+	if isHTTPScheme(scheme) {
+		return nil
+	}
+	return newServiceError(svc, ErrNotHTTP,
+		"port %d of service %s/%s speaks %s and cannot be exposed by an ingress, use the NodePort or LoadBalancer strategy, or annotation \"%s\" to force HTTP",
+		port.Port, svc.Namespace, svc.Name, scheme, ExposeProtocolAnnotationKey)
+}
+
+// exposedPort returns the port of the service published in the URL by the node port and load balancer strategies,
+// the one of the expose port annotation or the first one
+func exposedPort(svc *v1.Service) *v1.ServicePort {
+	port, err := findExposePort(svc, svc.Annotations[ExposePortAnnotationKey])
+	if err == nil && port != nil {
+		return port
+	}
+	if len(svc.Spec.Ports) == 0 {
+		return nil
+	}
+	return &svc.Spec.Ports[0]
+}
@@ -0,0 +1,103 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPortScheme(t *testing.T) {
+	tcp := "TCP"
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		port        v1.ServicePort
+		expected    string
+		err         bool
+	}{
+		{name: "http", port: v1.ServicePort{Port: 8080}},
+		{name: "well-known port", port: v1.ServicePort{Port: 5432}, expected: "tcp"},
+		{name: "app protocol", port: v1.ServicePort{Port: 8000, AppProtocol: &tcp}, expected: "tcp"},
+		{name: "udp", port: v1.ServicePort{Port: 8125, Protocol: v1.ProtocolUDP}, expected: "udp"},
+		{name: "forced http", annotations: map[string]string{ExposeProtocolAnnotationKey: "http"}, port: v1.ServicePort{Port: 6379}, expected: "http"},
+		{name: "forced tcp", annotations: map[string]string{ExposeProtocolAnnotationKey: "tcp"}, port: v1.ServicePort{Port: 8080}, expected: "tcp"},
+		{name: "invalid", annotations: map[string]string{ExposeProtocolAnnotationKey: "gopher"}, port: v1.ServicePort{Port: 8080}, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "db", Annotations: test.annotations}}
+			scheme, err := portScheme(svc, &test.port)
+			if test.err {
+				var parseErr *AnnotationParseError
+				assert.True(t, errors.As(err, &parseErr))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, scheme)
+		})
+	}
+}
+
+func TestIngressStrategy_AddNonHTTPPort(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "db", Annotations: map[string]string{}},
+		Spec:       v1.ServiceSpec{Ports: []v1.ServicePort{{Port: 5432}}},
+	}
+	client := fake.NewSimpleClientset(svc)
+	strategy, err := NewIngressStrategy(nil, client, &Config{Domain: "my-domain.com", HTTP: true})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+
+	err = strategy.Add(svc)
+	assert.True(t, errors.Is(err, ErrNotHTTP))
+	assert.False(t, Retryable(err))
+	ingresses, err := client.NetworkingV1().Ingresses("main").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, ingresses.Items)
+
+	svc.Annotations[ExposeProtocolAnnotationKey] = "http"
+	assert.NoError(t, strategy.Add(svc))
+}
+
+func TestNodePortStrategy_AddNonHTTPPort(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "db"},
+		Spec:       v1.ServiceSpec{Ports: []v1.ServicePort{{Port: 6379, NodePort: 30379}}},
+	}
+	client := fake.NewSimpleClientset(svc)
+	strategy, err := NewNodePortStrategy(nil, client, &Config{NodeIP: "10.0.0.1"})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+
+	require.NoError(t, strategy.Add(svc))
+	updated, err := client.CoreV1().Services("main").Get(context.Background(), "db", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "tcp://10.0.0.1:30379", updated.Annotations[ExposeAnnotationKey])
+}
+
+func TestLoadBalancerStrategy_AddNonHTTPPort(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: "db"},
+		Spec: v1.ServiceSpec{
+			LoadBalancerIP: "1.2.3.4",
+			Ports:          []v1.ServicePort{{Name: "postgres", Port: 5432}, {Name: "metrics", Port: 9187}},
+		},
+	}
+	client := fake.NewSimpleClientset(svc)
+	strategy, err := NewLoadBalancerStrategy(nil, client, &Config{})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+
+	require.NoError(t, strategy.Add(svc))
+	updated, err := client.CoreV1().Services("main").Get(context.Background(), "db", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "tcp://1.2.3.4:5432", updated.Annotations[ExposeAnnotationKey])
+	assert.Equal(t, `{"metrics":"http://1.2.3.4:9187","postgres":"tcp://1.2.3.4:5432"}`, updated.Annotations[ExposeURLsAnnotationKey])
+}
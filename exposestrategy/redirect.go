@@ -0,0 +1,235 @@
+package exposestrategy
+
+import (
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// RedirectUntilAnnotationKey annotation tells when the redirect ingress from the old hosts of a service is deleted
+	RedirectUntilAnnotationKey = "expose.fabric8.io/redirect-until"
+
+	// redirectIngressSuffix is appended to the name of the ingress of the service for its redirect ingress
+	redirectIngressSuffix = "-redirect"
+)
+
+// parseHostRedirectGracePeriod parses how long the old hosts are redirected, never if empty
+func parseHostRedirectGracePeriod(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	period, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid host redirect grace period \"%s\"", value)
+	}
+	if period < 0 {
+		return 0, errors.Errorf("invalid host redirect grace period \"%s\", must not be negative", value)
+	}
+	return period, nil
+}
+
+// redirectsHosts tells if the old hosts of the service are redirected to its new one,
+// the redirect relies on the annotations of the nginx ingress controller
+func (s *IngressStrategy) redirectsHosts(svc *v1.Service, ingressNamespace string) bool {
+	return s.hostRedirectGracePeriod > 0 && !s.noCanaryIngress && ingressNamespace == svc.Namespace
+}
+
+// publishedHost returns the host of the URL published by the service, empty if none
+func publishedHost(svc *v1.Service) string {
+	value := svc.Annotations[ExposeAnnotationKey]
+	if value == "" {
+		return ""
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return ""
+	}
+	return parsed.Hostname()
+}
+
+// buildRedirectIngress builds the ingress redirecting the old hosts to the target, with the paths of the ingress
+// the hosts keep the TLS entries of the previous ingress, nil if it did not exist
+func buildRedirectIngress(ingress, previous *networkingv1.Ingress, hosts []string, target string, until time.Time) *networkingv1.Ingress {
+	redirect := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       ingress.Namespace,
+			Name:            ingress.Name + redirectIngressSuffix,
+			Labels:          ingress.Labels,
+			Annotations:     map[string]string{},
+			OwnerReferences: ingress.OwnerReferences,
+		},
+		// the controller of the ingress serves the redirect
+		Spec: networkingv1.IngressSpec{IngressClassName: ingress.Spec.IngressClassName},
+	}
+	for key, value := range ingress.Annotations {
+		if key != "kubernetes.io/tls-acme" && key != generatedTLSAnnotationKey {
+			redirect.Annotations[key] = value
+		}
+	}
+	redirect.Annotations[nginxAnnotationPrefix+"permanent-redirect"] = target
+	redirect.Annotations[RedirectUntilAnnotationKey] = until.UTC().Format(time.RFC3339)
+	var value networkingv1.IngressRuleValue
+	if len(ingress.Spec.Rules) > 0 {
+		value = *ingress.Spec.Rules[0].IngressRuleValue.DeepCopy()
+	}
+	redirected := map[string]bool{}
+	for _, host := range hosts {
+		redirected[host] = true
+		redirect.Spec.Rules = append(redirect.Spec.Rules, networkingv1.IngressRule{
+			Host:             host,
+			IngressRuleValue: *value.DeepCopy(),
+		})
+	}
+	if previous != nil {
+		var tlsHosts []ingressHost
+		for _, tls := range previous.Spec.TLS {
+			for _, host := range tls.Hosts {
+				if coversRedirectedHost(host, redirected) {
+					tlsHosts = append(tlsHosts, ingressHost{name: host, tlsName: host, tlsSecret: tls.SecretName})
+				}
+			}
+		}
+		redirect.Spec.TLS = groupIngressTLS(tlsHosts)
+	}
+	sortIngressSpec(&redirect.Spec)
+	return redirect
+}
+
+// coversRedirectedHost tells if the host of a TLS entry is a redirected host, or the wildcard of one of them
+func coversRedirectedHost(tlsHost string, redirected map[string]bool) bool {
+	if redirected[tlsHost] {
+		return true
+	}
+	if !strings.HasPrefix(tlsHost, "*.") {
+		return false
+	}
+	for host := range redirected {
+		if parts := strings.SplitN(host, ".", 2); len(parts) == 2 && parts[1] == tlsHost[2:] {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRedirectIngress redirects the old host of the service to the hosts of the ingress during the grace period,
+// along with the hosts already redirected, it tells if the redirect ingress is kept
+func (s *IngressStrategy) applyRedirectIngress(ingress, previous *networkingv1.Ingress, oldHost, target string) (bool, error) {
+	current := map[string]bool{}
+	for _, rule := range ingress.Spec.Rules {
+		current[rule.Host] = true
+	}
+	name := ingress.Name + redirectIngressSuffix
+	ingresses := s.client.NetworkingV1().Ingresses(ingress.Namespace)
+	existing, err := ingresses.Get(s.ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		existing = nil
+	} else if err != nil {
+		return true, errors.Wrapf(err, "could not check for existing redirect ingress %s/%s", ingress.Namespace, name)
+	} else if !s.provider.IsGenerated(existing.Annotations) {
+		return false, errors.Errorf("redirect ingress %s/%s already exists and was not generated by exposecontroller",
+			ingress.Namespace, name)
+	}
+	// the grace period restarts when a new host is redirected
+	until := s.clock.Now().Add(s.hostRedirectGracePeriod)
+	hosts := []string{}
+	seen := map[string]bool{}
+	if existing != nil {
+		redirected := false
+		for _, rule := range existing.Spec.Rules {
+			redirected = redirected || rule.Host == oldHost
+		}
+		if value, err := time.Parse(time.RFC3339, existing.Annotations[RedirectUntilAnnotationKey]); err == nil && (redirected || oldHost == "" || current[oldHost]) {
+			until = value
+		}
+	}
+	if oldHost != "" && !current[oldHost] {
+		hosts = append(hosts, oldHost)
+		seen[oldHost] = true
+	}
+	if existing != nil {
+		for _, rule := range existing.Spec.Rules {
+			if !current[rule.Host] && !seen[rule.Host] {
+				hosts = append(hosts, rule.Host)
+				seen[rule.Host] = true
+			}
+		}
+		// the TLS entries of the hosts redirected before are kept
+		merged := previous.DeepCopy()
+		if merged == nil {
+			merged = &networkingv1.Ingress{}
+		}
+		merged.Spec.TLS = append(merged.Spec.TLS, existing.Spec.TLS...)
+		previous = merged
+	}
+	if len(hosts) == 0 {
+		// the service is exposed on its old host again
+		if existing != nil {
+			deleteIngress(s.ctx, s.client, existing, s.neverDelete, s.provider, s.deletePropagation)
+		}
+		return false, nil
+	}
+	if existing != nil && isUnmanagedIngress(existing, "updating") {
+		return true, nil
+	}
+	sort.Strings(hosts)
+	redirect := buildRedirectIngress(ingress, previous, hosts, target, until)
+	if existing == nil {
+		klog.Infof("creating redirect ingress %s/%s from %v to %s", redirect.Namespace, redirect.Name, hosts, target)
+		logIngressChange(nil, redirect)
+		_, err = ingresses.Create(s.ctx, redirect, metav1.CreateOptions{})
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to create redirect ingress %s/%s", redirect.Namespace, redirect.Name)
+		}
+		s.scheduleResync(s.hostRedirectGracePeriod)
+		return true, nil
+	}
+	if reflect.DeepEqual(redirect.Labels, existing.Labels) &&
+		reflect.DeepEqual(redirect.Annotations, existing.Annotations) &&
+		reflect.DeepEqual(redirect.OwnerReferences, existing.OwnerReferences) &&
+		reflect.DeepEqual(redirect.Spec, existing.Spec) {
+		return true, nil
+	}
+	redirect.ResourceVersion = existing.ResourceVersion
+	klog.Infof("updating redirect ingress %s/%s from %v to %s", redirect.Namespace, redirect.Name, hosts, target)
+	logIngressChange(existing, redirect)
+	_, err = ingresses.Update(s.ctx, redirect, metav1.UpdateOptions{})
+	if err != nil {
+		return true, errors.Wrapf(err, "failed to update redirect ingress %s/%s", redirect.Namespace, redirect.Name)
+	}
+	if remaining := until.Sub(s.clock.Now()); remaining > 0 {
+		s.scheduleResync(remaining)
+	}
+	return true, nil
+}
+
+// isRedirectOver tells if the grace period of a redirect ingress is over
+// a resync is scheduled at the end of the grace period that is not over
+func (s *IngressStrategy) isRedirectOver(ingress *networkingv1.Ingress) bool {
+	value, ok := ingress.Annotations[RedirectUntilAnnotationKey]
+	if !ok {
+		return false
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		klog.Warningf("invalid annotation %s=%s of ingress %s/%s, deleting it",
+			RedirectUntilAnnotationKey, value, ingress.Namespace, ingress.Name)
+		return true
+	}
+	remaining := until.Sub(s.clock.Now())
+	if remaining <= 0 {
+		return true
+	}
+	s.scheduleResync(remaining)
+	return false
+}
@@ -0,0 +1,193 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_HostRedirect(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			UID:       "uid",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	// the default class of the cluster is set in the spec of the ingresses
+	class := &networkingv1.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "nginx",
+			Annotations: map[string]string{defaultIngressClassAnnotationKey: "true"},
+		},
+	}
+	client := fake.NewSimpleClientset(service, class)
+	clock := clocktesting.NewFakePassiveClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	var resyncs []time.Duration
+	newStrategy := func(domain string) ExposeStrategy {
+		strategy, err := NewIngressStrategy(nil, client, &Config{
+			Exposer:                 "ingress",
+			Namespace:               "main",
+			Domain:                  domain,
+			TLSSecretName:           "tls",
+			HostRedirectGracePeriod: "24h",
+			Clock:                   clock,
+			ScheduleResync: func(d time.Duration) {
+				resyncs = append(resyncs, d)
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, strategy.Sync())
+		return strategy
+	}
+	ctx := context.Background()
+	addService := func(strategy ExposeStrategy) {
+		svc, err := client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.NoError(t, strategy.Add(svc))
+		setIngressVersions(t, client)
+	}
+	getRedirect := func() (*networkingv1.Ingress, error) {
+		return client.NetworkingV1().Ingresses("main").Get(ctx, "svc"+redirectIngressSuffix, metav1.GetOptions{})
+	}
+
+	addService(newStrategy("old.com"))
+	_, err := getRedirect()
+	assert.True(t, apierrors.IsNotFound(err), "no redirect without a change of host")
+
+	// the domain changes
+	strategy := newStrategy("new.com")
+	addService(strategy)
+	redirect, err := getRedirect()
+	require.NoError(t, err)
+	assert.Equal(t, "https://svc.main.new.com$request_uri", redirect.Annotations[nginxAnnotationPrefix+"permanent-redirect"])
+	assert.Equal(t, "2024-03-02T12:00:00Z", redirect.Annotations[RedirectUntilAnnotationKey])
+	require.Len(t, redirect.Spec.Rules, 1)
+	assert.Equal(t, "svc.main.old.com", redirect.Spec.Rules[0].Host)
+	assert.Equal(t, []networkingv1.IngressTLS{{Hosts: []string{"svc.main.old.com"}, SecretName: "tls"}}, redirect.Spec.TLS)
+	assert.Equal(t, "svc", redirect.OwnerReferences[0].Name)
+	if assert.NotNil(t, redirect.Spec.IngressClassName) {
+		assert.Equal(t, "nginx", *redirect.Spec.IngressClassName)
+	}
+	assert.Equal(t, []time.Duration{24 * time.Hour}, resyncs)
+	svc, err := client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://svc.main.new.com", svc.Annotations[ExposeAnnotationKey])
+
+	// the redirect is kept during the grace period
+	clock.SetTime(clock.Now().Add(10 * time.Hour))
+	strategy = newStrategy("new.com")
+	addService(strategy)
+	redirect, err = getRedirect()
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03-02T12:00:00Z", redirect.Annotations[RedirectUntilAnnotationKey], "the grace period is not restarted")
+	assert.Equal(t, []time.Duration{24 * time.Hour, 14 * time.Hour}, resyncs)
+
+	// another change redirects both old hosts, for a new grace period
+	strategy = newStrategy("other.com")
+	addService(strategy)
+	redirect, err = getRedirect()
+	require.NoError(t, err)
+	require.Len(t, redirect.Spec.Rules, 2)
+	assert.Equal(t, "svc.main.new.com", redirect.Spec.Rules[0].Host)
+	assert.Equal(t, "svc.main.old.com", redirect.Spec.Rules[1].Host)
+	assert.Equal(t, "https://svc.main.other.com$request_uri", redirect.Annotations[nginxAnnotationPrefix+"permanent-redirect"])
+	assert.Equal(t, "2024-03-02T22:00:00Z", redirect.Annotations[RedirectUntilAnnotationKey])
+
+	// the redirect is deleted after the grace period
+	clock.SetTime(clock.Now().Add(24 * time.Hour))
+	strategy = newStrategy("other.com")
+	_, err = getRedirect()
+	assert.True(t, apierrors.IsNotFound(err), "the redirect is deleted after the grace period")
+	addService(strategy)
+	_, err = getRedirect()
+	assert.True(t, apierrors.IsNotFound(err), "the redirect is not created again")
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	assert.NoError(t, err, "the ingress is kept")
+
+	for _, period := range []string{"10", "-10m"} {
+		_, err = NewIngressStrategy(nil, client, &Config{Domain: "my-domain.com", HostRedirectGracePeriod: period})
+		assert.Error(t, err, period)
+	}
+}
+
+func TestIngressStrategy_HostRedirectBack(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			UID:       "uid",
+			Annotations: map[string]string{
+				ExposeAnnotationKey: "http://svc.main.old.com",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(service)
+	newStrategy := func(domain string) ExposeStrategy {
+		strategy, err := NewIngressStrategy(nil, client, &Config{
+			Exposer:                 "ingress",
+			Namespace:               "main",
+			Domain:                  domain,
+			HTTP:                    true,
+			HostRedirectGracePeriod: "1h",
+		})
+		require.NoError(t, err)
+		require.NoError(t, strategy.Sync())
+		return strategy
+	}
+	ctx := context.Background()
+	require.NoError(t, newStrategy("new.com").Add(service))
+	setIngressVersions(t, client)
+	redirect, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc"+redirectIngressSuffix, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "http://svc.main.new.com$request_uri", redirect.Annotations[nginxAnnotationPrefix+"permanent-redirect"])
+	assert.Empty(t, redirect.Spec.TLS)
+
+	// the service is exposed on its old host again, before its new URL was seen
+	strategy := newStrategy("old.com")
+	require.NoError(t, strategy.Add(service))
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc"+redirectIngressSuffix, metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "the redirect is deleted")
+	assert.Equal(t, []string{"svc"}, strategy.(*IngressStrategy).existing["main/svc"])
+
+	// once published, the new host is redirected back to the old one
+	require.NoError(t, newStrategy("new.com").Add(service))
+	svc, err := client.CoreV1().Services("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NoError(t, newStrategy("old.com").Add(svc))
+	redirect, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc"+redirectIngressSuffix, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, redirect.Spec.Rules, 1)
+	assert.Equal(t, "svc.main.new.com", redirect.Spec.Rules[0].Host)
+	assert.Equal(t, "http://svc.main.old.com$request_uri", redirect.Annotations[nginxAnnotationPrefix+"permanent-redirect"])
+}
+
+// setIngressVersions sets the resource version of the ingresses, the fake client sets none
+func setIngressVersions(t *testing.T, client *fake.Clientset) {
+	ctx := context.Background()
+	list, err := client.NetworkingV1().Ingresses("main").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	for i := range list.Items {
+		if list.Items[i].ResourceVersion == "" {
+			list.Items[i].ResourceVersion = "1"
+			_, err = client.NetworkingV1().Ingresses("main").Update(ctx, &list.Items[i], metav1.UpdateOptions{})
+			require.NoError(t, err)
+		}
+	}
+}
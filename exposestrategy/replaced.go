@@ -0,0 +1,120 @@
+package exposestrategy
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// isReplacedBy tells if the service is replaced by the other one, a younger service with the same selector,
+// such as after the rename of a Helm release creating the new service before deleting the old one
+func isReplacedBy(svc, other *v1.Service) bool {
+	return len(svc.Spec.Selector) > 0 && reflect.DeepEqual(svc.Spec.Selector, other.Spec.Selector) && isOlderService(svc, other)
+}
+
+// takeOverReplacedServices deletes the ingresses of the services replaced by the service which route the same host and path
+// as its ingress, their URLs are removed, and returns an ErrConflict error if the service is itself replaced by one of them
+func (s *IngressStrategy) takeOverReplacedServices(svc *v1.Service, ingress *networkingv1.Ingress) error {
+	// the services without selector, such as the ExternalName ones, replace none
+	if len(svc.Spec.Selector) == 0 {
+		return nil
+	}
+	services, err := s.listServices(svc.Namespace)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the services of namespace %s to find the replaced services", svc.Namespace)
+	}
+	for _, other := range services {
+		if other.Name == svc.Name || !s.exposeValues.IsRequested(other) ||
+			(!isReplacedBy(other, svc) && !isReplacedBy(svc, other)) {
+			continue
+		}
+		otherKey := serviceKey(other.Namespace, other.Name)
+		for _, entry := range s.existing[otherKey] {
+			namespace, name := parseIngressEntry(other.Namespace, entry)
+			if namespace != ingress.Namespace || name == ingress.Name {
+				continue
+			}
+			existing, err := s.client.NetworkingV1().Ingresses(namespace).Get(s.ctx, name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return errors.Wrapf(err, "could not check for existing ingress %s/%s", namespace, name)
+			}
+			host := sharedHostPath(ingress, existing)
+			if host == "" || isGroupIngress(existing) || !s.provider.IsGenerated(existing.Annotations) {
+				continue
+			}
+			if isReplacedBy(svc, other) {
+				return newServiceError(svc, ErrConflict, "service %s/%s is replaced by service %s with the same selector on host %s",
+					svc.Namespace, svc.Name, other.Name, host)
+			}
+			klog.Infof("service %s/%s replaces service %s with the same selector on host %s, deleting ingress %s/%s",
+				svc.Namespace, svc.Name, other.Name, host, existing.Namespace, existing.Name)
+			deleteIngress(s.ctx, s.client, existing, s.neverDelete, s.provider, s.deletePropagation)
+			s.cleanGKEConfigs(existing.Namespace, existing.Name)
+			s.existing[otherKey] = removeEntry(s.existing[otherKey], entry)
+			s.releaseReplacedService(other, svc)
+		}
+	}
+	return nil
+}
+
+// releaseReplacedService removes the URLs of the replaced service, with a ServiceReplaced event
+func (s *IngressStrategy) releaseReplacedService(replaced, svc *v1.Service) {
+	EmitServiceEvent(s.ctx, s.client, replaced, v1.EventTypeNormal, "ServiceReplaced",
+		fmt.Sprintf("Replaced by service %s with the same selector, its ingress was deleted", svc.Name))
+	clone := replaced.DeepCopy()
+	if !removeServiceAnnotation(clone) {
+		return
+	}
+	patch, err := createServicePatch(replaced, clone)
+	if err != nil {
+		klog.Errorf("failed to create patch for service %s/%s: %s", replaced.Namespace, replaced.Name, err)
+		return
+	}
+	if patch == nil {
+		return
+	}
+	_, err = s.client.CoreV1().Services(replaced.Namespace).Patch(s.ctx, replaced.Name, patchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		klog.Errorf("failed to send patch %s/%s: %s", replaced.Namespace, replaced.Name, err)
+	}
+}
+
+// sharedHostPath returns the first host both ingresses route with the same path, empty if none
+func sharedHostPath(ingress, other *networkingv1.Ingress) string {
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host == "" || rule.HTTP == nil {
+			continue
+		}
+		for _, otherRule := range other.Spec.Rules {
+			if otherRule.Host != rule.Host || otherRule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				if hasIngressPath(otherRule.HTTP.Paths, path) {
+					return rule.Host
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// removeEntry returns the entries without the given one
+func removeEntry(entries []string, entry string) []string {
+	kept := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e != entry {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
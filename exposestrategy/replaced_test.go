@@ -0,0 +1,84 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_ReplacedService(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	newService := func(name, app string, age int) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "main",
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(created.Add(time.Duration(age) * time.Hour)),
+				Annotations: map[string]string{
+					ExposeAnnotation.Key:   ExposeAnnotation.Value,
+					"fabric8.io/host.name": "web",
+				},
+				ResourceVersion: "1",
+			},
+			Spec: v1.ServiceSpec{
+				Selector: map[string]string{"app": app},
+				Ports:    []v1.ServicePort{{Port: 8080}},
+			},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		newService("web", "web", 0),
+		newService("web-v2", "web", 1),
+		newService("other", "other", 2),
+	)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:   "ingress",
+		Namespace: "main",
+		Domain:    "my-domain.com",
+		HTTP:      true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	get := func(name string) *v1.Service {
+		svc, err := client.CoreV1().Services("main").Get(ctx, name, metav1.GetOptions{})
+		require.NoError(t, err)
+		return svc
+	}
+
+	require.NoError(t, strategy.Add(get("web")))
+	require.NoError(t, strategy.Add(get("other")))
+	setIngressVersions(t, client)
+	require.NoError(t, strategy.Add(get("web-v2")))
+
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "web", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "ingress of the replaced service deleted")
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "other", metav1.GetOptions{})
+	assert.NoError(t, err, "ingress of another selector kept")
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "web-v2", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "web.main.my-domain.com", ingress.Spec.Rules[0].Host)
+	assert.Equal(t, "http://web.main.my-domain.com", get("web-v2").Annotations[ExposeAnnotationKey])
+	_, published := get("web").Annotations[ExposeAnnotationKey]
+	assert.False(t, published, "URL of the replaced service removed")
+	events, err := client.CoreV1().Events("main").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	assert.Equal(t, "ServiceReplaced", events.Items[0].Reason)
+	assert.Equal(t, "web", events.Items[0].InvolvedObject.Name)
+
+	// the replaced service does not take its host back
+	err = strategy.Add(get("web"))
+	assert.True(t, errors.Is(err, ErrConflict))
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "web", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
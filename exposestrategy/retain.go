@@ -0,0 +1,62 @@
+package exposestrategy
+
+import (
+	"context"
+
+	"k8s.io/klog"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// RetainedLabelKey labels the generated objects that were released instead of deleted with the never delete option
+// "kubectl get ingresses,httproutes -A -l fabric8.io/retained=true" reports the objects to delete
+const RetainedLabelKey = "fabric8.io/retained"
+
+// ReleaseObject strips the management labels and the owner references of a generated object
+// neither the controller nor the garbage collector delete it then
+func ReleaseObject(obj metav1.Object, provider ProviderLabel) {
+	provider = provider.orLegacy()
+	labels := map[string]string{}
+	for key, value := range obj.GetLabels() {
+		if key == ExposedServiceLabelKey ||
+			(key == provider.Key && value == provider.Value) ||
+			(key == LegacyProviderLabel.Key && value == LegacyProviderLabel.Value) {
+			continue
+		}
+		labels[key] = value
+	}
+	labels[RetainedLabelKey] = "true"
+	obj.SetLabels(labels)
+	obj.SetOwnerReferences(nil)
+}
+
+// releaseIngress releases the ingress instead of deleting it
+func releaseIngress(ctx context.Context, client kubernetes.Interface, ingress *networkingv1.Ingress, provider ProviderLabel) {
+	released := ingress.DeepCopy()
+	ReleaseObject(released, provider)
+	klog.Warningf("not deleting the ingress %s/%s, it is released with label %s=true",
+		ingress.Namespace, ingress.Name, RetainedLabelKey)
+	logIngressChange(ingress, released)
+	_, err := client.NetworkingV1().Ingresses(ingress.Namespace).Update(ctx, released, metav1.UpdateOptions{})
+	if err != nil {
+		klog.Errorf("error when releasing ingress %s/%s: %s",
+			ingress.Namespace, ingress.Name, err)
+	}
+}
+
+// releaseUnstructured releases the custom resource instead of deleting it
+func releaseUnstructured(ctx context.Context, resources dynamic.ResourceInterface, obj *unstructured.Unstructured, provider ProviderLabel) {
+	released := obj.DeepCopy()
+	ReleaseObject(released, provider)
+	klog.Warningf("not deleting the %s %s/%s, it is released with label %s=true",
+		obj.GetKind(), obj.GetNamespace(), obj.GetName(), RetainedLabelKey)
+	_, err := resources.Update(ctx, released, metav1.UpdateOptions{})
+	if err != nil {
+		klog.Errorf("error when releasing %s %s/%s: %s",
+			obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+	}
+}
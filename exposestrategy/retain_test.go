@@ -0,0 +1,83 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_NeverDelete(t *testing.T) {
+	newIngress := func(name string, labels map[string]string, ownerReferences []metav1.OwnerReference) *networkingv1.Ingress {
+		labels["provider"] = "fabric8"
+		labels["app"] = name
+		return &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "main",
+				Name:      name,
+				Labels:    labels,
+				Annotations: map[string]string{
+					"fabric8.io/generated-by": "exposecontroller",
+				},
+				OwnerReferences: ownerReferences,
+				ResourceVersion: "1",
+			},
+		}
+	}
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				ExposeAnnotationKey: "http://svc.main.my-domain.com",
+			},
+			ResourceVersion: "1",
+		},
+	}
+	client := fake.NewSimpleClientset(
+		service,
+		newIngress("svc", map[string]string{}, []metav1.OwnerReference{{APIVersion: "v1", Kind: "Service", Name: "svc"}}),
+		newIngress("gone", map[string]string{ExposedServiceLabelKey: "gone"}, nil),
+		newIngress("orphan", map[string]string{}, nil),
+	)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:     "ingress",
+		Namespace:   "main",
+		Domain:      "my-domain.com",
+		NeverDelete: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Clean(service))
+
+	ctx := context.Background()
+	list, err := client.NetworkingV1().Ingresses("main").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 3, "nothing is deleted")
+	for _, ingress := range list.Items {
+		assert.Equal(t, map[string]string{
+			"app":            ingress.Name,
+			RetainedLabelKey: "true",
+		}, ingress.Labels, "released ingress %s", ingress.Name)
+		assert.Empty(t, ingress.OwnerReferences, "released ingress %s", ingress.Name)
+	}
+	retained, err := client.NetworkingV1().Ingresses("").List(ctx, metav1.ListOptions{LabelSelector: RetainedLabelKey + "=true"})
+	require.NoError(t, err)
+	assert.Len(t, retained.Items, 3, "the report of the ingresses to delete")
+
+	// the released ingresses are not managed anymore
+	require.NoError(t, strategy.Sync())
+	assert.Empty(t, strategy.(*IngressStrategy).existing)
+
+	client = fake.NewSimpleClientset(newIngress("svc", map[string]string{}, []metav1.OwnerReference{{APIVersion: "v1", Kind: "Service", Name: "svc"}}))
+	require.NoError(t, CleanIngressStrategy(ctx, client, nil, "main", LegacyProviderLabel, true, nil))
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err, "the cleanup releases the ingresses")
+	assert.Equal(t, "true", ingress.Labels[RetainedLabelKey])
+}
@@ -0,0 +1,46 @@
+package exposestrategy
+
+import (
+	"hash/fnv"
+
+	"github.com/pkg/errors"
+)
+
+// Shard is the subset of the namespaces handled by a replica of the controller, by the hash of their names,
+// so that the replicas of a sharded controller share the services of the cluster without overlap
+type Shard struct {
+	// Index is the shard of the replica, from 0 to Count-1
+	Index int
+	// Count is the number of shards, the sharding is disabled if less than 2
+	Count int
+}
+
+// Check validates the index of the shard
+func (s Shard) Check() error {
+	if s.Count < 0 {
+		return errors.Errorf("invalid shard count %d, must be positive", s.Count)
+	}
+	count := s.Count
+	if count == 0 {
+		count = 1
+	}
+	if s.Index < 0 || s.Index >= count {
+		return errors.Errorf("invalid shard index %d, must be between 0 and the shard count %d excluded", s.Index, count)
+	}
+	return nil
+}
+
+// Enabled tells if the namespaces are sharded
+func (s Shard) Enabled() bool {
+	return s.Count > 1
+}
+
+// Owns tells if the namespace belongs to the shard, all of them without sharding
+func (s Shard) Owns(namespace string) bool {
+	if !s.Enabled() {
+		return true
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(namespace))
+	return int(hash.Sum32()%uint32(s.Count)) == s.Index
+}
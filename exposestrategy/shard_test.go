@@ -0,0 +1,81 @@
+package exposestrategy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShard_Check(t *testing.T) {
+	assert.NoError(t, Shard{}.Check())
+	assert.NoError(t, Shard{Index: 2, Count: 3}.Check())
+	assert.Error(t, Shard{Index: 3, Count: 3}.Check())
+	assert.Error(t, Shard{Index: -1, Count: 3}.Check())
+	assert.Error(t, Shard{Index: 1}.Check())
+	assert.Error(t, Shard{Count: -1}.Check())
+}
+
+func TestShard_Owns(t *testing.T) {
+	counts := make([]int, 3)
+	for i := 0; i < 300; i++ {
+		namespace := fmt.Sprintf("team-%d", i)
+		assert.True(t, Shard{}.Owns(namespace), "all the namespaces without sharding")
+		owners := 0
+		for index := range counts {
+			if (Shard{Index: index, Count: len(counts)}).Owns(namespace) {
+				owners++
+				counts[index]++
+			}
+		}
+		assert.Equal(t, 1, owners, namespace)
+	}
+	for index, count := range counts {
+		assert.True(t, count > 50, "shard %d owns %d namespaces", index, count)
+	}
+}
+
+func TestIngressStrategy_SyncShard(t *testing.T) {
+	ctx := context.Background()
+	shard := Shard{Index: 0, Count: 2}
+	var owned, other string
+	for i := 0; owned == "" || other == ""; i++ {
+		namespace := fmt.Sprintf("team-%d", i)
+		if shard.Owns(namespace) {
+			owned = namespace
+		} else {
+			other = namespace
+		}
+	}
+	// the ingresses without owner nor service label are deleted by the sync
+	orphan := func(namespace string) *networkingv1.Ingress {
+		return &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   namespace,
+				Name:        "orphan",
+				Labels:      map[string]string{"provider": "fabric8"},
+				Annotations: map[string]string{"fabric8.io/generated-by": "exposecontroller"},
+			},
+		}
+	}
+	client := fake.NewSimpleClientset(orphan(owned), orphan(other))
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer: "ingress",
+		Domain:  "my-domain.com",
+		Shard:   shard,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+
+	_, err = client.NetworkingV1().Ingresses(owned).Get(ctx, "orphan", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "ingress of the shard deleted")
+	_, err = client.NetworkingV1().Ingresses(other).Get(ctx, "orphan", metav1.GetOptions{})
+	assert.NoError(t, err, "ingress of another shard left alone")
+}
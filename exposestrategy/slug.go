@@ -0,0 +1,66 @@
+package exposestrategy
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// SlugAnnotationKey annotation replaces the name of the service in the URL template, such as "payments"
+// so that the long generated service names do not end up in the public host names
+const SlugAnnotationKey = "fabric8.io/expose.slug"
+
+// serviceSlug returns the slug of the service, "" without slug
+// the slug must be a DNS label not requested by an older exposed service of the namespace, unless the service replaces it
+func (s *IngressStrategy) serviceSlug(svc *v1.Service) (string, error) {
+	slug, ok := svc.Annotations[SlugAnnotationKey]
+	if !ok {
+		return "", nil
+	}
+	if errs := validation.IsDNS1123Label(slug); len(errs) > 0 {
+		return "", newAnnotationParseError(svc, SlugAnnotationKey, slug,
+			errors.Errorf("invalid slug \"%s\": %s", slug, strings.Join(errs, ", ")))
+	}
+	// the services are listed from the API server or the shared cache, the older ones keep the slug whatever the order of the events
+	services, err := s.listServices(svc.Namespace)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to list the services of namespace %s to check the slug", svc.Namespace)
+	}
+	for _, other := range services {
+		if other.Name != svc.Name && other.Annotations[SlugAnnotationKey] == slug &&
+			s.exposeValues.IsRequested(other) && isOlderService(other, svc) && !isReplacedBy(other, svc) {
+			return "", newAnnotationParseError(svc, SlugAnnotationKey, slug,
+				errors.Errorf("slug \"%s\" is already used by service %s", slug, other.Name))
+		}
+	}
+	return slug, nil
+}
+
+// listServices lists the services of the namespace, from the shared cache if any
+func (s *IngressStrategy) listServices(namespace string) ([]*v1.Service, error) {
+	if s.serviceLister != nil {
+		return s.serviceLister.Services(namespace).List(labels.Everything())
+	}
+	list, err := s.client.CoreV1().Services(namespace).List(s.ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	services := make([]*v1.Service, len(list.Items))
+	for index := range list.Items {
+		services[index] = &list.Items[index]
+	}
+	return services, nil
+}
+
+// isOlderService tells if the service was created before the other one, by name if created at the same time
+func isOlderService(svc, other *v1.Service) bool {
+	if !svc.CreationTimestamp.Equal(&other.CreationTimestamp) {
+		return svc.CreationTimestamp.Before(&other.CreationTimestamp)
+	}
+	return svc.Name < other.Name
+}
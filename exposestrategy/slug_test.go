@@ -0,0 +1,78 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_Slug(t *testing.T) {
+	created := metav1.NewTime(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	newService := func(name, slug string, created metav1.Time) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "main",
+				Name:              name,
+				CreationTimestamp: created,
+				Annotations: map[string]string{
+					ExposeAnnotation.Key: ExposeAnnotation.Value,
+					SlugAnnotationKey:    slug,
+				},
+				ResourceVersion: "1",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{Port: 8080}},
+			},
+		}
+	}
+	payments := newService("prod-payments-api", "payments", created)
+	conflicting := newService("prod-payments-api-v2", "payments", metav1.NewTime(created.Add(time.Hour)))
+	invalid := newService("billing", "Billing_API", created)
+	client := fake.NewSimpleClientset(payments, conflicting, invalid)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:   "ingress",
+		Namespace: "main",
+		Domain:    "my-domain.com",
+		HTTP:      true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+
+	// the older service keeps the slug whatever the order
+	err = strategy.Add(conflicting)
+	var parseErr *AnnotationParseError
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, SlugAnnotationKey, parseErr.Annotation)
+		assert.EqualError(t, parseErr.Err, `slug "payments" is already used by service prod-payments-api`)
+	}
+	require.NoError(t, strategy.Add(payments))
+
+	ctx := context.Background()
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "prod-payments-api", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, ingress.Spec.Rules, 1)
+	assert.Equal(t, "payments.main.my-domain.com", ingress.Spec.Rules[0].Host)
+	svc, err := client.CoreV1().Services("main").Get(ctx, "prod-payments-api", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "http://payments.main.my-domain.com", svc.Annotations[ExposeAnnotationKey])
+
+	err = strategy.Add(invalid)
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, SlugAnnotationKey, parseErr.Annotation)
+	}
+
+	// the host name annotation takes precedence
+	conflicting.Annotations["fabric8.io/host.name"] = "payments-v2"
+	require.NoError(t, strategy.Add(conflicting))
+	ingress, err = client.NetworkingV1().Ingresses("main").Get(ctx, "prod-payments-api-v2", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "payments-v2.main.my-domain.com", ingress.Spec.Rules[0].Host)
+}
@@ -0,0 +1,157 @@
+package exposestrategy
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// soak churns the services for the given duration while failing the API calls, go test ./exposestrategy -run Soak -soak 2h -timeout 0
+var soak = flag.Duration("soak", 0, "run the soak tests for the given duration")
+
+// soakSeed replays the logged seed of a failed soak test
+var soakSeed = flag.Int64("soak-seed", 0, "seed of the random operations of the soak tests, the time if 0")
+
+// soakChaosRate is the share of failed API calls while churning
+const soakChaosRate = 0.2
+
+// addChaosReactor fails the share of the API calls of the client while enabled, with the errors of a loaded API server
+func addChaosReactor(client *fake.Clientset, rng *rand.Rand, enabled *int32) {
+	client.PrependReactor("*", "*", func(action ktesting.Action) (bool, runtime.Object, error) {
+		if atomic.LoadInt32(enabled) == 0 || action.GetVerb() == "watch" || rng.Float64() >= soakChaosRate {
+			return false, nil, nil
+		}
+		resource := action.GetResource().GroupResource()
+		switch rng.Intn(4) {
+		case 0:
+			return true, nil, apierrors.NewConflict(resource, "chaos", errors.New("injected conflict"))
+		case 1:
+			return true, nil, apierrors.NewTooManyRequests("injected throttling", 1)
+		case 2:
+			return true, nil, apierrors.NewServerTimeout(resource, action.GetVerb(), 1)
+		default:
+			return true, nil, apierrors.NewInternalError(errors.New("injected failure"))
+		}
+	})
+}
+
+// soakStrategy adds, cleans and deletes random services with failing API calls, until the soak duration is elapsed,
+// then deletes all the services without failure and checks that the strategy forgot them, state returning the size of its state
+func soakStrategy(t *testing.T, client *fake.Clientset, strategy ExposeStrategy, newService func(name string) *v1.Service, state func() int) {
+	ctx := context.Background()
+	seed := *soakSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	t.Logf("seed %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+	var enabled int32 = 1
+	addChaosReactor(client, rand.New(rand.NewSource(rng.Int63())), &enabled)
+
+	services := map[string]bool{}
+	operations, failures := 0, 0
+	for deadline := time.Now().Add(*soak); time.Now().Before(deadline); operations++ {
+		name := fmt.Sprintf("svc%d", rng.Intn(50))
+		var err error
+		switch {
+		case !services[name]:
+			_, err = client.CoreV1().Services("main").Create(ctx, newService(name), metav1.CreateOptions{})
+			if err == nil {
+				services[name] = true
+			}
+		case rng.Intn(10) == 0:
+			err = client.CoreV1().Services("main").Delete(ctx, name, metav1.DeleteOptions{})
+			if err == nil {
+				delete(services, name)
+				err = strategy.Delete(newService(name))
+			}
+		default:
+			var svc *v1.Service
+			svc, err = client.CoreV1().Services("main").Get(ctx, name, metav1.GetOptions{})
+			if err == nil && rng.Intn(5) == 0 {
+				err = strategy.Clean(svc)
+			} else if err == nil {
+				err = strategy.Add(svc)
+			}
+		}
+		if err != nil {
+			failures++
+		}
+	}
+	t.Logf("%d operations, %d failures", operations, failures)
+
+	atomic.StoreInt32(&enabled, 0)
+	for name := range services {
+		require.NoError(t, client.CoreV1().Services("main").Delete(ctx, name, metav1.DeleteOptions{}))
+		require.NoError(t, strategy.Delete(newService(name)))
+	}
+	// the services deleted while their objects failed to be deleted are deleted again, as by a resync
+	for i := 0; i < 50; i++ {
+		require.NoError(t, strategy.Delete(newService(fmt.Sprintf("svc%d", i))))
+	}
+	assert.Equal(t, 0, state(), "state of the deleted services")
+}
+
+func TestIngressStrategy_Soak(t *testing.T) {
+	if *soak == 0 {
+		t.Skip("soak tests only run with -soak")
+	}
+	client := fake.NewSimpleClientset()
+	strategy, err := NewIngressStrategy(nil, client, &Config{Domain: "my-domain.com", TLSAcme: true})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	s := strategy.(*IngressStrategy)
+
+	soakStrategy(t, client, strategy, func(name string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: name, Annotations: map[string]string{}},
+			Spec:       v1.ServiceSpec{Ports: []v1.ServicePort{{Name: "http", Port: 80}}},
+		}
+	}, func() int {
+		return len(s.existing) + len(s.existingRoutes) + len(s.serviceUIDs) + len(s.hostConflicts)
+	})
+
+	// the ingresses failing to be deleted are deleted by the resyncs, or by the garbage collector with owner references,
+	// the fake client has none, the envtest soak of the controller checks that no ingress is left, make soak-envtest
+	require.NoError(t, strategy.Sync())
+	ingresses, err := client.NetworkingV1().Ingresses("main").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	for _, ingress := range ingresses.Items {
+		assert.NotEmpty(t, ingress.OwnerReferences, "ingress %s of a deleted service not garbage collected", ingress.Name)
+	}
+}
+
+func TestLoadBalancerStrategy_Soak(t *testing.T) {
+	if *soak == 0 {
+		t.Skip("soak tests only run with -soak")
+	}
+	client := fake.NewSimpleClientset()
+	strategy, err := NewLoadBalancerStrategy(nil, client, &Config{})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	s := strategy.(*LoadBalancerStrategy)
+
+	soakStrategy(t, client, strategy, func(name string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "main", Name: name},
+			Spec:       v1.ServiceSpec{Type: v1.ServiceTypeClusterIP},
+		}
+	}, func() int {
+		return len(s.todo)
+	})
+}
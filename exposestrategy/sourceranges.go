@@ -0,0 +1,113 @@
+package exposestrategy
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// LoadBalancerSourceRangesAnnotationKey annotation of a namespace holds the default source ranges of the load balancers
+	// of its exposed services, comma separated CIDRs overriding the config, none if empty
+	LoadBalancerSourceRangesAnnotationKey = "fabric8.io/expose.load-balancer-source-ranges"
+	// LoadBalancerOriginalSourceRangesAnnotationKey annotation records the source ranges of the service before the default ones,
+	// restored when the service is unexposed
+	LoadBalancerOriginalSourceRangesAnnotationKey = "fabric8.io/loadbalancer.original-source-ranges"
+)
+
+// parseSourceRanges parses comma separated CIDRs, such as "10.0.0.0/8, 192.168.0.0/16"
+func parseSourceRanges(text string) ([]string, error) {
+	var ranges []string
+	for _, value := range strings.Split(text, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(value); err != nil {
+			return nil, errors.Wrapf(err, "invalid source range \"%s\"", value)
+		}
+		ranges = append(ranges, value)
+	}
+	return ranges, nil
+}
+
+// defaultSourceRanges returns the default source ranges of the load balancers in the namespace of the service,
+// those of its annotation or of the config, the namespace must be read not to open the load balancer
+func (s *LoadBalancerStrategy) defaultSourceRanges(svc *v1.Service) ([]string, error) {
+	// namespaces are cluster scoped
+	if s.permissionProfile == PermissionProfileNamespace {
+		return s.sourceRanges, nil
+	}
+	ns, err := s.client.CoreV1().Namespaces().Get(s.ctx, svc.Namespace, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return s.sourceRanges, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the source ranges of namespace %s", svc.Namespace)
+	}
+	text, ok := ns.Annotations[LoadBalancerSourceRangesAnnotationKey]
+	if !ok {
+		return s.sourceRanges, nil
+	}
+	ranges, err := parseSourceRanges(text)
+	if err != nil {
+		return nil, newAnnotationParseError(svc, LoadBalancerSourceRangesAnnotationKey, text,
+			errors.Wrapf(err, "annotation of namespace %s", svc.Namespace))
+	}
+	return ranges, nil
+}
+
+// applySourceRanges sets the default source ranges on the clone, recording the original ones the first time,
+// the services having their own source ranges keep them
+func applySourceRanges(svc, clone *v1.Service, ranges []string) error {
+	if _, recorded := svc.Annotations[LoadBalancerOriginalSourceRangesAnnotationKey]; !recorded {
+		if len(ranges) == 0 || len(svc.Spec.LoadBalancerSourceRanges) > 0 {
+			return nil
+		}
+		original := svc.Spec.LoadBalancerSourceRanges
+		if original == nil {
+			original = []string{}
+		}
+		data, err := json.Marshal(original)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode the source ranges of service %s/%s", svc.Namespace, svc.Name)
+		}
+		if clone.Annotations == nil {
+			clone.Annotations = map[string]string{}
+		}
+		clone.Annotations[LoadBalancerOriginalSourceRangesAnnotationKey] = string(data)
+	}
+	// the default ones were removed since
+	if len(ranges) == 0 {
+		restoreSourceRanges(clone)
+		return nil
+	}
+	clone.Spec.LoadBalancerSourceRanges = ranges
+	return nil
+}
+
+// restoreSourceRanges restores the recorded source ranges in the clone and removes the annotation
+func restoreSourceRanges(clone *v1.Service) {
+	text, ok := clone.Annotations[LoadBalancerOriginalSourceRangesAnnotationKey]
+	if !ok {
+		return
+	}
+	delete(clone.Annotations, LoadBalancerOriginalSourceRangesAnnotationKey)
+	var original []string
+	if err := json.Unmarshal([]byte(text), &original); err != nil {
+		klog.Warningf("invalid annotation \"%s\" in service %s/%s, removing the source ranges: %s",
+			LoadBalancerOriginalSourceRangesAnnotationKey, clone.Namespace, clone.Name, text)
+		original = nil
+	}
+	if len(original) == 0 {
+		original = nil
+	}
+	clone.Spec.LoadBalancerSourceRanges = original
+}
@@ -0,0 +1,90 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSourceRanges(t *testing.T) {
+	ranges, err := parseSourceRanges("10.0.0.0/8, 192.168.0.0/16,")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.0.0/16"}, ranges)
+	_, err = parseSourceRanges("10.0.0.0")
+	assert.Error(t, err)
+}
+
+func TestLoadBalancerStrategy_SourceRanges(t *testing.T) {
+	ctx := context.Background()
+	newNamespace := func(name string, annotations map[string]string) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+	}
+	newService := func(namespace string, ranges []string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "svc"},
+			Spec: v1.ServiceSpec{
+				Type:                     v1.ServiceTypeClusterIP,
+				Ports:                    []v1.ServicePort{{Port: 8080}},
+				LoadBalancerSourceRanges: ranges,
+			},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		newNamespace("restricted", map[string]string{LoadBalancerSourceRangesAnnotationKey: "192.168.0.0/16"}),
+		newNamespace("open", map[string]string{LoadBalancerSourceRangesAnnotationKey: ""}),
+		newNamespace("invalid", map[string]string{LoadBalancerSourceRangesAnnotationKey: "everywhere"}),
+		newService("restricted", nil),
+		newService("open", nil),
+		newService("invalid", nil),
+		newService("default", nil),
+		newService("own", []string{"172.16.0.0/12"}),
+	)
+	strategy, err := NewLoadBalancerStrategy(nil, client, &Config{LoadBalancerSourceRanges: []string{"10.0.0.0/8"}})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	get := func(namespace string) *v1.Service {
+		svc, err := client.CoreV1().Services(namespace).Get(ctx, "svc", metav1.GetOptions{})
+		require.NoError(t, err)
+		return svc
+	}
+	expected := map[string][]string{
+		"restricted": {"192.168.0.0/16"},
+		"open":       nil,
+		"default":    {"10.0.0.0/8"},
+		"own":        {"172.16.0.0/12"},
+	}
+	for namespace, ranges := range expected {
+		require.NoError(t, strategy.Add(get(namespace)), namespace)
+		assert.Equal(t, ranges, get(namespace).Spec.LoadBalancerSourceRanges, namespace)
+	}
+	err = strategy.Add(get("invalid"))
+	var parseErr *AnnotationParseError
+	assert.True(t, errors.As(err, &parseErr), "parse error: %v", err)
+	assert.False(t, Retryable(err), "not retryable")
+	assert.Equal(t, "[]", get("default").Annotations[LoadBalancerOriginalSourceRangesAnnotationKey])
+	assert.NotContains(t, get("own").Annotations, LoadBalancerOriginalSourceRangesAnnotationKey)
+
+	// the original source ranges are restored once unexposed
+	require.NoError(t, strategy.Clean(get("default")))
+	assert.Nil(t, get("default").Spec.LoadBalancerSourceRanges)
+	assert.NotContains(t, get("default").Annotations, LoadBalancerOriginalSourceRangesAnnotationKey)
+	require.NoError(t, strategy.Clean(get("own")))
+	assert.Equal(t, []string{"172.16.0.0/12"}, get("own").Spec.LoadBalancerSourceRanges)
+
+	// the load balancer is not opened when the namespace cannot be read
+	client.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("timeout")
+	})
+	err = strategy.Add(get("restricted"))
+	assert.True(t, Retryable(err), "retryable: %v", err)
+	assert.Equal(t, []string{"192.168.0.0/16"}, get("restricted").Spec.LoadBalancerSourceRanges)
+}
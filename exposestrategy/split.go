@@ -0,0 +1,154 @@
+package exposestrategy
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// TrafficSplitAnnotationKey annotation sends a percentage of the traffic of the service to another service of its namespace,
+	// such as "other-svc=30", with a canary ingress of the nginx ingress controller and weighted backends in the HTTP route
+	TrafficSplitAnnotationKey = "fabric8.io/expose.split"
+
+	// canaryIngressSuffix is appended to the name of the ingress of the service for its canary ingress
+	canaryIngressSuffix = "-canary"
+)
+
+// trafficSplit is the service receiving the weight percent of the traffic
+type trafficSplit struct {
+	service string
+	weight  int
+}
+
+// parseTrafficSplit parses the "service=weight" split of the service, nil without annotation
+func parseTrafficSplit(svc *v1.Service) (*trafficSplit, error) {
+	value := svc.Annotations[TrafficSplitAnnotationKey]
+	if value == "" {
+		return nil, nil
+	}
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 {
+		return nil, errors.Errorf("annotation \"%s\" in service %s/%s must be \"service=weight\", got \"%s\"",
+			TrafficSplitAnnotationKey, svc.Namespace, svc.Name, value)
+	}
+	name := strings.TrimSpace(parts[0])
+	if errs := validation.IsDNS1035Label(name); len(errs) > 0 {
+		return nil, errors.Errorf("invalid service \"%s\" in annotation \"%s\" of service %s/%s: %s",
+			name, TrafficSplitAnnotationKey, svc.Namespace, svc.Name, strings.Join(errs, ", "))
+	}
+	if name == svc.Name {
+		return nil, errors.Errorf("service %s/%s cannot split its traffic with itself", svc.Namespace, svc.Name)
+	}
+	weight, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || weight < 0 || weight > 100 {
+		return nil, errors.Errorf("invalid weight \"%s\" in annotation \"%s\" of service %s/%s, must be a percentage between 0 and 100",
+			strings.TrimSpace(parts[1]), TrafficSplitAnnotationKey, svc.Namespace, svc.Name)
+	}
+	return &trafficSplit{service: name, weight: weight}, nil
+}
+
+// buildCanaryIngress builds the canary ingress sending the weight of the traffic of the hosts of the ingress to the split service
+// the canary has no TLS entry, the certificates are those of the ingress
+func buildCanaryIngress(ingress *networkingv1.Ingress, split *trafficSplit) *networkingv1.Ingress {
+	canary := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       ingress.Namespace,
+			Name:            ingress.Name + canaryIngressSuffix,
+			Labels:          ingress.Labels,
+			Annotations:     map[string]string{},
+			OwnerReferences: ingress.OwnerReferences,
+		},
+		Spec: *ingress.Spec.DeepCopy(),
+	}
+	for key, value := range ingress.Annotations {
+		if key != "kubernetes.io/tls-acme" && key != generatedTLSAnnotationKey {
+			canary.Annotations[key] = value
+		}
+	}
+	canary.Annotations[nginxAnnotationPrefix+"canary"] = "true"
+	canary.Annotations[nginxAnnotationPrefix+"canary-weight"] = strconv.Itoa(split.weight)
+	canary.Spec.TLS = nil
+	for _, rule := range canary.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for i := range rule.HTTP.Paths {
+			if backend := rule.HTTP.Paths[i].Backend.Service; backend != nil {
+				backend.Name = split.service
+			}
+		}
+	}
+	return canary
+}
+
+// applyCanaryIngress creates or updates the canary ingress, once the ingress it splits exists
+func (s *IngressStrategy) applyCanaryIngress(canary *networkingv1.Ingress) error {
+	ingresses := s.client.NetworkingV1().Ingresses(canary.Namespace)
+	existing, err := ingresses.Get(s.ctx, canary.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.Infof("creating canary ingress %s/%s", canary.Namespace, canary.Name)
+		logIngressChange(nil, canary)
+		_, err = ingresses.Create(s.ctx, canary, metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create canary ingress %s/%s", canary.Namespace, canary.Name)
+		}
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "could not check for existing canary ingress %s/%s", canary.Namespace, canary.Name)
+	}
+	if !s.provider.IsGenerated(existing.Annotations) {
+		return errors.Errorf("canary ingress %s/%s already exists and was not generated by exposecontroller",
+			canary.Namespace, canary.Name)
+	}
+	if isUnmanagedIngress(existing, "updating") {
+		return nil
+	}
+	if reflect.DeepEqual(canary.Labels, existing.Labels) &&
+		reflect.DeepEqual(canary.Annotations, existing.Annotations) &&
+		reflect.DeepEqual(canary.OwnerReferences, existing.OwnerReferences) &&
+		reflect.DeepEqual(canary.Spec, existing.Spec) {
+		return nil
+	}
+	canary.ResourceVersion = existing.ResourceVersion
+	klog.Infof("updating canary ingress %s/%s", canary.Namespace, canary.Name)
+	logIngressChange(existing, canary)
+	_, err = ingresses.Update(s.ctx, canary, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to update canary ingress %s/%s", canary.Namespace, canary.Name)
+	}
+	return nil
+}
+
+// splitHTTPRoute weights the backends of the rules of the HTTP route, the split service gets the weight of the traffic
+// on the same port as the service
+func splitHTTPRoute(route *unstructured.Unstructured, split *trafficSplit) {
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	for _, r := range rules {
+		rule := r.(map[string]interface{})
+		refs, _ := rule["backendRefs"].([]interface{})
+		if len(refs) == 0 {
+			continue
+		}
+		ref := refs[0].(map[string]interface{})
+		ref["weight"] = int64(100 - split.weight)
+		rule["backendRefs"] = []interface{}{ref, map[string]interface{}{
+			"name":   split.service,
+			"port":   ref["port"],
+			"weight": int64(split.weight),
+		}}
+	}
+	if len(rules) > 0 {
+		_ = unstructured.SetNestedSlice(route.Object, rules, "spec", "rules")
+	}
+}
@@ -0,0 +1,185 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTrafficSplit(t *testing.T) {
+	examples := []struct {
+		value string
+		split *trafficSplit
+		err   string
+	}{{
+		value: "",
+	}, {
+		value: "other-svc=30",
+		split: &trafficSplit{service: "other-svc", weight: 30},
+	}, {
+		value: " other-svc = 0 ",
+		split: &trafficSplit{service: "other-svc", weight: 0},
+	}, {
+		value: "other-svc",
+		err:   `annotation "fabric8.io/expose.split" in service main/svc must be "service=weight", got "other-svc"`,
+	}, {
+		value: "Other=30",
+		err:   `invalid service "Other" in annotation "fabric8.io/expose.split" of service main/svc: a DNS-1035 label must consist of lower case alphanumeric characters or '-', start with an alphabetic character, and end with an alphanumeric character (e.g. 'my-name',  or 'abc-123', regex used for validation is '[a-z]([-a-z0-9]*[a-z0-9])?')`,
+	}, {
+		value: "svc=30",
+		err:   "service main/svc cannot split its traffic with itself",
+	}, {
+		value: "other-svc=120",
+		err:   `invalid weight "120" in annotation "fabric8.io/expose.split" of service main/svc, must be a percentage between 0 and 100`,
+	}, {
+		value: "other-svc=a third",
+		err:   `invalid weight "a third" in annotation "fabric8.io/expose.split" of service main/svc, must be a percentage between 0 and 100`,
+	}}
+	for _, example := range examples {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "main",
+				Name:        "svc",
+				Annotations: map[string]string{TrafficSplitAnnotationKey: example.value},
+			},
+		}
+		split, err := parseTrafficSplit(svc)
+		if example.err == "" {
+			assert.NoError(t, err, example.value)
+			assert.Equal(t, example.split, split, example.value)
+		} else {
+			assert.EqualError(t, err, example.err, example.value)
+		}
+	}
+}
+
+func TestIngressStrategy_TrafficSplit(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			UID:       "svc-uid",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key:      ExposeAnnotation.Value,
+				TrafficSplitAnnotationKey: "svc-next=30",
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(svc)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			HTTPRouteResource: "HTTPRouteList",
+		})
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:       "ingress",
+		Namespace:     "main",
+		Domain:        "my-domain.com",
+		TLSSecretName: "tls",
+		TLSAcme:       true,
+		HTTPRoute:     true,
+		GatewayName:   "gateway",
+		DynamicClient: dynamicClient,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(svc))
+
+	ctx := context.Background()
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	canary, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc-canary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "true", canary.Annotations["nginx.ingress.kubernetes.io/canary"])
+	assert.Equal(t, "30", canary.Annotations["nginx.ingress.kubernetes.io/canary-weight"])
+	assert.Equal(t, "exposecontroller", canary.Annotations["fabric8.io/generated-by"])
+	assert.Equal(t, "true", ingress.Annotations["kubernetes.io/tls-acme"])
+	assert.NotContains(t, canary.Annotations, "kubernetes.io/tls-acme", "the certificate is the one of the ingress")
+	assert.Empty(t, canary.Spec.TLS)
+	assert.Equal(t, ingress.OwnerReferences, canary.OwnerReferences)
+	assert.Equal(t, "svc.main.my-domain.com", canary.Spec.Rules[0].Host)
+	assert.Equal(t, networkingv1.IngressServiceBackend{
+		Name: "svc-next",
+		Port: networkingv1.ServiceBackendPort{Number: 8080},
+	}, *canary.Spec.Rules[0].HTTP.Paths[0].Backend.Service)
+	assert.Equal(t, []string{"svc", "svc-canary"}, strategy.(*IngressStrategy).existing["main/svc"])
+
+	route, err := dynamicClient.Resource(HTTPRouteResource).Namespace("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	if assert.Len(t, rules, 1) {
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"name": "svc", "port": int64(8080), "weight": int64(70)},
+			map[string]interface{}{"name": "svc-next", "port": int64(8080), "weight": int64(30)},
+		}, rules[0].(map[string]interface{})["backendRefs"])
+	}
+
+	// the canary is found back on sync, and updated with the weight
+	svc.Annotations[TrafficSplitAnnotationKey] = "svc-next=50"
+	require.NoError(t, strategy.Sync())
+	assert.Equal(t, []string{"svc", "svc-canary"}, strategy.(*IngressStrategy).existing["main/svc"])
+	require.NoError(t, strategy.Add(svc))
+	canary, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc-canary", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "50", canary.Annotations["nginx.ingress.kubernetes.io/canary-weight"])
+
+	// the canary is deleted with the annotation
+	delete(svc.Annotations, TrafficSplitAnnotationKey)
+	require.NoError(t, strategy.Add(svc))
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc-canary", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "canary deleted")
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"svc"}, strategy.(*IngressStrategy).existing["main/svc"])
+}
+
+func TestIngressStrategy_TrafficSplitUnsupported(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Annotations: map[string]string{
+				ExposeAnnotation.Key:      ExposeAnnotation.Value,
+				TrafficSplitAnnotationKey: "svc-next=30",
+			},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(svc)
+	strategy, err := NewALBStrategy(nil, client, &Config{
+		Exposer:   "alb",
+		Namespace: "main",
+		Domain:    "my-domain.com",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	assert.EqualError(t, strategy.Add(svc), "service main/svc cannot split its traffic, the ingress controller has no canary ingresses")
+
+	strategy, err = NewIngressStrategy(nil, client, &Config{
+		Exposer:          "ingress",
+		Namespace:        "main",
+		Domain:           "my-domain.com",
+		IngressNamespace: "edge",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	assert.EqualError(t, strategy.Add(svc), "service main/svc cannot split its traffic with an ingress in namespace edge")
+}
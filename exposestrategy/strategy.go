@@ -3,11 +3,18 @@ package exposestrategy
 import (
 	"context"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/utils/clock"
 )
 
 // ExposeStrategy represents a strategy
@@ -19,6 +26,12 @@ type ExposeStrategy interface {
 	Delete(svc *v1.Service) error
 }
 
+// NamespaceForgetter is implemented by the strategies which can drop the state of all the services of a namespace at once,
+// without any request, when the namespace is being deleted along with the generated objects
+type NamespaceForgetter interface {
+	ForgetNamespace(namespace string)
+}
+
 // Config is the common config to all strategies
 type Config struct {
 	Exposer        string
@@ -34,13 +47,205 @@ type Config struct {
 	URLTemplate    string
 	PathMode       string
 	IngressClass   string
+	PortMapping    string
+	// AcmeChallengeType is "http01" by default, with "dns01" the controller manages a wildcard certificate by domain
+	// the certificates are issued by the AcmeIssuer cluster issuer
+	AcmeChallengeType string
+	AcmeIssuer        string
+	// SkipOwnerReferences tells not to set owner references on the generated objects
+	SkipOwnerReferences bool
+	// HTTPRoute tells to also generate Gateway API HTTP routes next to the ingresses
+	HTTPRoute        bool
+	GatewayName      string
+	GatewayNamespace string
+	// URLOwner tells which of the ingress or the HTTP route publishes the exposed URL
+	URLOwner string
+	// GKEConfigs tells to also generate GKE FrontendConfigs and BackendConfigs next to the ingresses
+	GKEConfigs bool
+	// StrictAnnotations rejects the duplicate and invalid keys of the ingress annotations
+	StrictAnnotations bool
+	// Teams are the exposure policies of the services by team annotation
+	Teams map[string]TeamConfig
+	// DomainTLSPolicies are the TLS policies of the hosts by domain, overriding the TLS settings of the controller
+	DomainTLSPolicies map[string]DomainTLSPolicy
+	// TLSSecretsBySuffix are the secrets of the hosts by suffix, such as "*.apps.example.com", merged into the DomainTLSPolicies
+	TLSSecretsBySuffix map[string]string
+	// HTTPPort and HTTPSPort are the ports of the ingress controller in the published URLs, the default ones if 0
+	HTTPPort  int
+	HTTPSPort int
+	// IngressNodePortService is the "namespace/name" of the NodePort service of the ingress controller giving those ports
+	IngressNodePortService string
+	// HTTP3Annotations are added to the ingresses of the services asking for HTTP/3, for the ingress controllers
+	// not known to support it, such as a build of nginx with HTTP/3
+	HTTP3Annotations map[string]string
+	// Domains are the domains the services select with the "fabric8.io/expose.domain" annotation, one or all of them,
+	// such as the domains of the regions behind a Geo-DNS
+	Domains []string
+	// LoadBalancerSourceRanges are the default source ranges of the load balancers of the LoadBalancer strategy,
+	// overridden by the "fabric8.io/expose.load-balancer-source-ranges" annotation of the namespaces
+	LoadBalancerSourceRanges []string
+	// Shard is the subset of the namespaces handled by the controller, the generated objects of the others are left alone
+	Shard Shard
+	// NeverDelete releases the generated objects by stripping their management labels instead of deleting them
+	NeverDelete bool
+	// DeletePropagation is the propagation policy of the deletions of the generated objects, "foreground", "background" or "orphan",
+	// the default one of the API server if empty
+	DeletePropagation string
+	// DeleteGracePeriod is how long the ingresses of the unexposed services are kept before being deleted, such as "10m"
+	DeleteGracePeriod string
+	// HostRedirectGracePeriod is how long the old hosts of the services are redirected to their new one
+	// after a change of the URL template or of the domain, such as "720h", never if empty
+	HostRedirectGracePeriod string
+	// IngressNamespace is the namespace of the generated ingresses backed by ExternalName services, the one of the services if empty
+	IngressNamespace string
+	// IngressTemplate and HTTPRouteTemplate are the paths of the Go templates producing the generated objects, computed if empty
+	IngressTemplate   string
+	HTTPRouteTemplate string
+	// HostConflictPolicy is "skip", "adopt" or "error" when an ingress not generated by the controller claims the hosts
+	HostConflictPolicy string
+	// AnnotationDenylist are the patterns of the keys stripped from the ingress annotations of the services
+	AnnotationDenylist []string
+	// AnnotationPropagation are the patterns of the keys of the service annotations copied to their ingresses
+	AnnotationPropagation []string
+	// DNSCheck warns with an event when the hosts of the ingresses do not resolve to the ingress controller
+	DNSCheck bool
+	// DetectExposePort chooses the HTTP port of the services with several ports from their app protocols and probes
+	DetectExposePort bool
+	// ExposeValues are the values of the expose label and annotations requesting the exposure, "true" only if nil
+	ExposeValues *ExposeValues
+	// ServiceLister and IngressLister read the caches shared with the controller
+	// instead of listing the API server on each service, nil if not shared
+	ServiceLister corelisters.ServiceLister
+	IngressLister networkinglisters.IngressLister
+	// DynamicClient is used for the custom resources
+	DynamicClient dynamic.Interface
+	// PermissionProfile tells which permissions the controller has, "cluster" by default
+	PermissionProfile string
+	// ProviderLabel is set on the generated objects, LegacyProviderLabel by default
+	ProviderLabel ProviderLabel
+	// NodePortDeadline is how long the NodePort strategy waits for the node port of a service, "5m" by default
+	NodePortDeadline string
+	// Clock tells the time of the deadlines, the real clock if nil
+	Clock clock.PassiveClock
+	// ScheduleResync syncs the strategy again after the delay, such as at the end of the delete grace period
+	ScheduleResync func(time.Duration)
+	// IngressStatusCheck publishes the URL once the ingress controller wrote the load balancer status of the ingress
+	IngressStatusCheck bool
+	// MigrateLegacyAnnotations renames the ingress.kubernetes.io annotations of the ingresses to nginx.ingress.kubernetes.io,
+	// the annotations already spelled with the current prefix take precedence
+	MigrateLegacyAnnotations bool
+	// AutoDomainService is the "namespace/name" of the load balancer service of the ingress controller,
+	// without Domain, the domain is the AutoDomainProvider domain of its IP, "nip.io" or "sslip.io"
+	AutoDomainService  string
+	AutoDomainProvider string
+	// IngressLabels are stamped on the generated ingresses, the labels of the services and of the controller taking precedence
+	IngressLabels map[string]string
+	// TLSMergePolicy is "preserve" to keep the TLS entries added to the ingresses by others, "replace" by default
+	TLSMergePolicy string
+	// URLTrailingSlash is "always" or "never" to end the paths of the published URLs with a slash or not, "preserve" by default
+	URLTrailingSlash string
+	// InternalDomainScheme is "http" or "https" for the hosts of the internal domain, whatever the TLS settings of the domain
+	// with "https", their certificate is in InternalDomainTLSSecretName if set
+	InternalDomainScheme        string
+	InternalDomainTLSSecretName string
+	// OwnerReferenceController and OwnerReferenceBlockOwnerDeletion set the controller and blockOwnerDeletion fields
+	// of the owner references of the generated ingresses to their service
+	OwnerReferenceController         bool
+	OwnerReferenceBlockOwnerDeletion bool
+	// HealthCheckPath is the probe path of the services routed on their hosts for the external uptime checks, none if empty
+	HealthCheckPath string
+	// RecordLastApplied stores the desired ingresses in their last applied annotation, the labels and annotations
+	// added by others to the ingresses are then kept
+	RecordLastApplied bool
+	// ALB strategy, the scheme and target type default to "internet-facing" and "ip"
+	ALBScheme         string
+	ALBTargetType     string
+	ALBCertificateARN string
+	ALBGroupName      string
 }
 
+const (
+	// PermissionProfileCluster tells that the controller can read cluster scoped resources such as nodes
+	PermissionProfileCluster = "cluster"
+	// PermissionProfileNamespace tells that the controller can only access the resources of its namespace
+	PermissionProfileNamespace = "namespace"
+)
+
 type label struct {
 	Key   string
 	Value string
 }
 
+// ProviderLabel is the label identifying the objects generated by the controller
+type ProviderLabel struct {
+	Key   string
+	Value string
+	// GeneratedBy is the value of the generated-by annotation of the objects generated by this instance of the controller,
+	// DefaultGeneratedBy if empty
+	GeneratedBy string
+}
+
+// LegacyProviderLabel is the provider label by default
+// the objects having it are still recognised when another one is configured
+var LegacyProviderLabel = ProviderLabel{Key: "provider", Value: "fabric8"}
+
+// ParseProviderLabel parses a "key=value" provider label, returns the legacy one if empty
+func ParseProviderLabel(text string) (ProviderLabel, error) {
+	if text == "" {
+		return LegacyProviderLabel, nil
+	}
+	parts := strings.SplitN(text, "=", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return ProviderLabel{}, errors.Errorf("invalid provider label \"%s\", must be key=value", text)
+	}
+	if errs := validation.IsQualifiedName(parts[0]); len(errs) > 0 {
+		return ProviderLabel{}, errors.Errorf("invalid provider label key \"%s\": %s", parts[0], strings.Join(errs, ", "))
+	}
+	if errs := validation.IsValidLabelValue(parts[1]); len(errs) > 0 {
+		return ProviderLabel{}, errors.Errorf("invalid provider label value \"%s\": %s", parts[1], strings.Join(errs, ", "))
+	}
+	return ProviderLabel{Key: parts[0], Value: parts[1]}, nil
+}
+
+// orLegacy returns the legacy provider label if none is set
+func (l ProviderLabel) orLegacy() ProviderLabel {
+	if l.Key == "" {
+		return ProviderLabel{Key: LegacyProviderLabel.Key, Value: LegacyProviderLabel.Value, GeneratedBy: l.GeneratedBy}
+	}
+	return l
+}
+
+// Selectors returns the label selectors listing the generated objects
+// the legacy provider label is selected too so that the objects generated before are adopted
+func (l ProviderLabel) Selectors() []string {
+	l = l.orLegacy()
+	selectors := []string{l.Key + "=" + l.Value}
+	if l.Key != LegacyProviderLabel.Key || l.Value != LegacyProviderLabel.Value {
+		selectors = append(selectors, LegacyProviderLabel.Key+"="+LegacyProviderLabel.Value)
+	}
+	return selectors
+}
+
+// Matches tells if the labels have the provider label or the legacy one
+func (l ProviderLabel) Matches(labels map[string]string) bool {
+	l = l.orLegacy()
+	return labels[l.Key] == l.Value || labels[LegacyProviderLabel.Key] == LegacyProviderLabel.Value
+}
+
+// Marker returns the value of the generated-by annotation set on the generated objects
+func (l ProviderLabel) Marker() string {
+	if l.GeneratedBy == "" {
+		return DefaultGeneratedBy
+	}
+	return l.GeneratedBy
+}
+
+// IsGenerated tells if the annotations mark an object generated by this instance of the controller,
+// the objects of the instances with another generated-by value are left to them
+func (l ProviderLabel) IsGenerated(annotations map[string]string) bool {
+	return annotations[GeneratedByAnnotationKey] == l.Marker()
+}
+
 var (
 	// ExposeLabel label tells that the service is exposed
 	ExposeLabel = label{Key: "expose", Value: "true"}
@@ -48,12 +253,32 @@ var (
 	ExposeAnnotation = label{Key: "fabric8.io/expose", Value: "true"}
 	// InjectAnnotation annotation tells that the service is exposed
 	InjectAnnotation = label{Key: "fabric8.io/inject", Value: "true"}
+	// GeneratedByAnnotationKey annotation marks the objects generated by the controller, with the value of its instance
+	GeneratedByAnnotationKey = "fabric8.io/generated-by"
+	// DefaultGeneratedBy is the value of the generated-by annotation by default
+	DefaultGeneratedBy = "exposecontroller"
 	// ExposeHostNameAsAnnotationKey annotation sets the hostname to use
 	ExposeHostNameAsAnnotationKey = "fabric8.io/exposeHostNameAs"
 	// ExposeAnnotationKey annotation will be created with the exposed url
 	ExposeAnnotationKey = "fabric8.io/exposeURL"
 	// ExposePortAnnotationKey annotation sets the service port to export
 	ExposePortAnnotationKey = "fabric8.io/exposePort"
+	// PortMappingAnnotationKey annotation overrides the port mapping rules of the service
+	PortMappingAnnotationKey = "fabric8.io/port.mapping"
+	// OwnerReferencesAnnotationKey annotation overrides whether owner references are set on the generated objects
+	OwnerReferencesAnnotationKey = "fabric8.io/owner.references"
+	// ExposedServiceLabelKey label holds the name of the exposed service when there is no owner reference
+	ExposedServiceLabelKey = "fabric8.io/exposed-service"
+	// URLOwnerAnnotationKey annotation overrides which of the ingress or the HTTP route publishes the exposed URL
+	URLOwnerAnnotationKey = "fabric8.io/url.owner"
+	// IngressAnnotationsAnnotationKey annotation holds the annotations to pass to the ingress, in YAML format
+	IngressAnnotationsAnnotationKey = "fabric8.io/ingress.annotations"
+	// IngressLabelsAnnotationKey annotation holds the labels to stamp on the ingress, in YAML format
+	IngressLabelsAnnotationKey = "fabric8.io/ingress.labels"
+	// IngressAnnotationsFromAnnotationKey annotation references the "config-map/key" holding annotations to pass to the ingress
+	IngressAnnotationsFromAnnotationKey = "fabric8.io/ingress.annotations-from"
+	// AdditionalHostsAnnotationKey annotation lists other "host[=secret]" of the ingress, comma separated
+	AdditionalHostsAnnotationKey = "fabric8.io/ingress.additional-hosts"
 	// APIServicePathAnnotationKey annotation sets the path to export
 	APIServicePathAnnotationKey = "api.service.kubernetes.io/path"
 )
@@ -61,6 +286,7 @@ var (
 type exposeStrategyFunc = func(ctx context.Context, client kubernetes.Interface, config *Config) (ExposeStrategy, error)
 
 var exposeStrategyFuncs map[string]exposeStrategyFunc = map[string]exposeStrategyFunc{
+	"alb":          NewALBStrategy,
 	"ambassador":   NewAmbassadorStrategy,
 	"ingress":      NewIngressStrategy,
 	"loadbalancer": NewLoadBalancerStrategy,
@@ -68,14 +294,33 @@ var exposeStrategyFuncs map[string]exposeStrategyFunc = map[string]exposeStrateg
 }
 
 // New creates a new strategy
+// the APIs served by the cluster choose the automatic exposer and validate the others
 func New(ctx context.Context, client kubernetes.Interface, config *Config) (ExposeStrategy, error) {
+	var discoveryClient discovery.DiscoveryInterface
+	if client != nil {
+		discoveryClient = client.Discovery()
+	}
+	return newStrategy(ctx, client, config, discoverCapabilities(discoveryClient))
+}
+
+func newStrategy(ctx context.Context, client kubernetes.Interface, config *Config, caps capabilities) (ExposeStrategy, error) {
+	switch config.PermissionProfile {
+	case "", PermissionProfileCluster, PermissionProfileNamespace:
+	default:
+		return nil, errors.Errorf("unknown permission profile \"%s\", must be one of \"%s\", \"%s\"",
+			config.PermissionProfile, PermissionProfileCluster, PermissionProfileNamespace)
+	}
 	exposer := strings.ToLower(config.Exposer)
 	if exposer == "" || exposer == "auto" {
-		return NewAutoStrategy(ctx, client, config)
+		return newAutoStrategy(ctx, client, config, caps)
 	}
 
 	f, ok := exposeStrategyFuncs[exposer]
 	if ok {
+		err := caps.check(exposer, config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create %s expose strategy", exposer)
+		}
 		strategy, err := f(ctx, client, config)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to create %s expose strategy", exposer)
@@ -0,0 +1,38 @@
+package exposestrategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProviderLabel(t *testing.T) {
+	provider, err := ParseProviderLabel("")
+	assert.NoError(t, err)
+	assert.Equal(t, LegacyProviderLabel, provider)
+	assert.Equal(t, []string{"provider=fabric8"}, provider.Selectors())
+
+	provider, err = ParseProviderLabel("example.com/team=platform")
+	assert.NoError(t, err)
+	assert.Equal(t, ProviderLabel{Key: "example.com/team", Value: "platform"}, provider)
+	assert.Equal(t, []string{"example.com/team=platform", "provider=fabric8"}, provider.Selectors())
+	assert.True(t, provider.Matches(map[string]string{"example.com/team": "platform"}))
+	assert.True(t, provider.Matches(map[string]string{"provider": "fabric8"}))
+	assert.False(t, provider.Matches(map[string]string{"example.com/team": "other"}))
+
+	for _, text := range []string{"provider", "provider=", "=fabric8", "provider=fab ric8", "in valid=fabric8"} {
+		_, err = ParseProviderLabel(text)
+		assert.Error(t, err, text)
+	}
+}
+
+func TestProviderLabel_IsGenerated(t *testing.T) {
+	assert.Equal(t, DefaultGeneratedBy, LegacyProviderLabel.Marker())
+	assert.True(t, LegacyProviderLabel.IsGenerated(map[string]string{GeneratedByAnnotationKey: "exposecontroller"}))
+	assert.False(t, LegacyProviderLabel.IsGenerated(nil))
+
+	provider := ProviderLabel{GeneratedBy: "exposecontroller-blue"}
+	assert.True(t, provider.IsGenerated(map[string]string{GeneratedByAnnotationKey: "exposecontroller-blue"}))
+	assert.False(t, provider.IsGenerated(map[string]string{GeneratedByAnnotationKey: "exposecontroller"}), "the objects of the default instance")
+	assert.Equal(t, []string{"provider=fabric8"}, provider.Selectors())
+}
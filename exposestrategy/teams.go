@@ -0,0 +1,64 @@
+package exposestrategy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// TeamAnnotationKey annotation tells the team of the service, exposing it with the domain, ingress class and TLS secret of the team
+const TeamAnnotationKey = "fabric8.io/expose.team"
+
+// TeamConfig is the exposure policy of the services of a team, the empty values are those of the controller
+type TeamConfig struct {
+	Domain        string `yaml:"domain,omitempty" json:"domain"`
+	IngressClass  string `yaml:"ingress-class,omitempty" json:"ingress_class"`
+	TLSSecretName string `yaml:"tls-secret-name,omitempty" json:"tls_secret_name"`
+}
+
+// checkTeams checks the ingress classes of the teams against the installed ones
+func checkTeams(classes []networkingv1.IngressClass, teams map[string]TeamConfig) map[string]TeamConfig {
+	checked := make(map[string]TeamConfig, len(teams))
+	for name, team := range teams {
+		team.IngressClass = checkIngressClass(classes, team.IngressClass)
+		checked[name] = team
+	}
+	return checked
+}
+
+// teamConfig returns the config of the team of the service, the controller one without team
+func (s *IngressStrategy) teamConfig(svc *v1.Service) (TeamConfig, error) {
+	controllerConfig := TeamConfig{
+		Domain:        s.domain,
+		IngressClass:  s.ingressClass,
+		TLSSecretName: s.tlsSecretName,
+	}
+	name, ok := svc.Annotations[TeamAnnotationKey]
+	if !ok {
+		return controllerConfig, nil
+	}
+	team, ok := s.teams[name]
+	if !ok {
+		names := make([]string, 0, len(s.teams))
+		for n := range s.teams {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return TeamConfig{}, errors.Errorf("unknown team \"%s\" in annotation \"%s\" of service %s/%s, must be one of \"%s\"",
+			name, TeamAnnotationKey, svc.Namespace, svc.Name, strings.Join(names, "\", \""))
+	}
+	if team.Domain == "" {
+		team.Domain = controllerConfig.Domain
+	}
+	if team.IngressClass == "" {
+		team.IngressClass = controllerConfig.IngressClass
+	}
+	if team.TLSSecretName == "" {
+		team.TLSSecretName = controllerConfig.TLSSecretName
+	}
+	return team, nil
+}
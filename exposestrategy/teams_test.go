@@ -0,0 +1,93 @@
+package exposestrategy
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_Teams(t *testing.T) {
+	newService := func(name, team string) *v1.Service {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "main",
+				Name:      name,
+				Annotations: map[string]string{
+					ExposeAnnotation.Key: ExposeAnnotation.Value,
+				},
+				ResourceVersion: "1",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{Port: 80}},
+			},
+		}
+		if team != "" {
+			svc.Annotations[TeamAnnotationKey] = team
+		}
+		return svc
+	}
+	payments := newService("payments", "red")
+	search := newService("search", "blue")
+	shared := newService("shared", "")
+	unknown := newService("unknown", "green")
+	client := fake.NewSimpleClientset(payments, search, shared, unknown)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:       "ingress",
+		Namespace:     "main",
+		Domain:        "my-domain.com",
+		IngressClass:  "nginx",
+		TLSSecretName: "wildcard-tls",
+		Teams: map[string]TeamConfig{
+			"red": {
+				Domain:        "red.my-domain.com",
+				IngressClass:  "nginx-red",
+				TLSSecretName: "red-tls",
+			},
+			"blue": {
+				Domain: "blue.my-domain.com",
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(payments))
+	require.NoError(t, strategy.Add(search))
+	require.NoError(t, strategy.Add(shared))
+	err = strategy.Add(unknown)
+	assert.EqualError(t, err, `unknown team "green" in annotation "fabric8.io/expose.team" of service main/unknown, must be one of "blue", "red"`)
+
+	examples := []struct {
+		name   string
+		host   string
+		class  string
+		secret string
+	}{{
+		name:   "payments",
+		host:   "payments.main.red.my-domain.com",
+		class:  "nginx-red",
+		secret: "red-tls",
+	}, {
+		name:   "search",
+		host:   "search.main.blue.my-domain.com",
+		class:  "nginx",
+		secret: "wildcard-tls",
+	}, {
+		name:   "shared",
+		host:   "shared.main.my-domain.com",
+		class:  "nginx",
+		secret: "wildcard-tls",
+	}}
+	for _, example := range examples {
+		ingress, err := client.NetworkingV1().Ingresses("main").Get(nil, example.name, metav1.GetOptions{})
+		require.NoError(t, err, example.name)
+		assert.Equal(t, example.host, ingress.Spec.Rules[0].Host, example.name)
+		assert.Equal(t, example.class, ingress.Annotations["kubernetes.io/ingress.class"], example.name)
+		require.Len(t, ingress.Spec.TLS, 1, example.name)
+		assert.Equal(t, example.secret, ingress.Spec.TLS[0].SecretName, example.name)
+	}
+}
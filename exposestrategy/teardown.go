@@ -0,0 +1,45 @@
+package exposestrategy
+
+import (
+	"strings"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ForgetNamespace drops the state of the services of a namespace being deleted, its ingresses are deleted with it
+// only the ingresses generated for its services in another namespace, backed by ExternalName services, are deleted
+func (s *IngressStrategy) ForgetNamespace(namespace string) {
+	prefix := namespace + "/"
+	for svcKey, entries := range s.existing {
+		if !strings.HasPrefix(svcKey, prefix) {
+			continue
+		}
+		svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: strings.TrimPrefix(svcKey, prefix)}}
+		for _, entry := range entries {
+			if ingressNamespace, name := parseIngressEntry(namespace, entry); ingressNamespace != namespace {
+				s.deleteServiceIngress(svc, ingressNamespace, name)
+			}
+		}
+		delete(s.existing, svcKey)
+	}
+	// the HTTP routes are generated in the namespace of the services
+	for svcKey := range s.existingRoutes {
+		if strings.HasPrefix(svcKey, prefix) {
+			delete(s.existingRoutes, svcKey)
+		}
+	}
+	forgetNamespaceKeys(s.hostConflicts, namespace)
+	s.dnsChecker.forgetNamespace(namespace)
+	s.annotationDenylist.forgetNamespace(namespace)
+}
+
+// forgetNamespaceKeys deletes the values of the services of the namespace, by service key
+func forgetNamespaceKeys(values map[string]string, namespace string) {
+	prefix := namespace + "/"
+	for key := range values {
+		if strings.HasPrefix(key, prefix) {
+			delete(values, key)
+		}
+	}
+}
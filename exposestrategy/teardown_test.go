@@ -0,0 +1,70 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_ForgetNamespace(t *testing.T) {
+	newService := func(namespace, name, ingressNamespace string) *v1.Service {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       namespace,
+				Name:            name,
+				UID:             types.UID(name + "-uid"),
+				ResourceVersion: "1",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{Name: "http", Port: 8080}},
+			},
+		}
+		if ingressNamespace != "" {
+			svc.Annotations = map[string]string{IngressNamespaceAnnotationKey: ingressNamespace}
+		}
+		return svc
+	}
+	services := []*v1.Service{
+		newService("main", "svc1", ""),
+		newService("main", "svc2", "main"),
+		newService("other", "svc3", "other"),
+	}
+	client := fake.NewSimpleClientset(services[0], services[1], services[2])
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:          "ingress",
+		Domain:           "my-domain.com",
+		IngressNamespace: "edge",
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	for _, svc := range services {
+		require.NoError(t, strategy.Add(svc))
+	}
+	s := strategy.(*IngressStrategy)
+	s.hostConflicts["main/svc1"] = "conflict"
+	s.hostConflicts["other/svc3"] = "conflict"
+
+	client.ClearActions()
+	s.ForgetNamespace("main")
+	assert.Equal(t, map[string][]string{"other/svc3": {"svc3"}}, s.existing)
+	assert.Equal(t, map[string]string{"other/svc3": "conflict"}, s.hostConflicts)
+	for _, action := range client.Actions() {
+		assert.NotEqual(t, "main", action.GetNamespace(), "no request in the namespace being deleted: %v", action)
+	}
+
+	ctx := context.Background()
+	_, err = client.NetworkingV1().Ingresses("edge").Get(ctx, "main-svc1", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "the ingress of another namespace is deleted")
+	_, err = client.NetworkingV1().Ingresses("main").Get(ctx, "svc2", metav1.GetOptions{})
+	assert.NoError(t, err, "the ingress is deleted with the namespace")
+	_, err = client.NetworkingV1().Ingresses("other").Get(ctx, "svc3", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
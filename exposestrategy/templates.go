@@ -0,0 +1,208 @@
+package exposestrategy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// templateFuncs are the functions available in the object templates
+var templateFuncs = template.FuncMap{
+	"quote": strconv.Quote,
+	"toYaml": func(value interface{}) (string, error) {
+		out, err := yaml.Marshal(value)
+		return strings.TrimSuffix(string(out), "\n"), err
+	},
+	"indent": func(spaces int, text string) string {
+		pad := strings.Repeat(" ", spaces)
+		return pad + strings.Replace(text, "\n", "\n"+pad, -1)
+	},
+}
+
+// templateData is given to the object templates, with the values computed by the controller
+type templateData struct {
+	// Service is the exposed service
+	Service *v1.Service
+	// Name, Namespace, Labels and Annotations are the metadata of the generated object
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
+	// Host is the host of the exposed URL, Hosts all the hosts of the ingress
+	Host  string
+	Hosts []string
+	// Path and PathType are the ones of the ingress rules
+	Path     string
+	PathType string
+	// BackendName is the backend service, BackendPort its port number, or BackendPortName its port name
+	BackendName     string
+	BackendPort     int32
+	BackendPortName string
+	// TLSSecretName is the secret of the certificate of the host, empty without TLS
+	TLSSecretName string
+	// GatewayName and GatewayNamespace are the parent gateway of the HTTP routes
+	GatewayName      string
+	GatewayNamespace string
+}
+
+// newTemplateData gathers the values computed for the ingress of the service
+func newTemplateData(svc *v1.Service, ingress *networkingv1.Ingress, host, tlsSecretName string) *templateData {
+	data := &templateData{
+		Service:       svc,
+		Name:          ingress.Name,
+		Namespace:     ingress.Namespace,
+		Labels:        ingress.Labels,
+		Annotations:   ingress.Annotations,
+		Host:          host,
+		TLSSecretName: tlsSecretName,
+	}
+	for _, rule := range ingress.Spec.Rules {
+		data.Hosts = append(data.Hosts, rule.Host)
+		if data.BackendName != "" || rule.HTTP == nil || len(rule.HTTP.Paths) == 0 {
+			continue
+		}
+		p := rule.HTTP.Paths[0]
+		data.Path = p.Path
+		if p.PathType != nil {
+			data.PathType = string(*p.PathType)
+		}
+		if p.Backend.Service != nil {
+			data.BackendName = p.Backend.Service.Name
+			data.BackendPort = p.Backend.Service.Port.Number
+			data.BackendPortName = p.Backend.Service.Port.Name
+		}
+	}
+	return data
+}
+
+// loadObjectTemplate parses the template file of the generated objects, nil without file
+func loadObjectTemplate(name, path string) (*template.Template, error) {
+	if path == "" {
+		return nil, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the %s template", name)
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(templateFuncs).Parse(string(content))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the %s template", name)
+	}
+	return tmpl, nil
+}
+
+// executeObjectTemplate executes the template and converts the YAML object it produces to JSON
+func executeObjectTemplate(tmpl *template.Template, data *templateData) ([]byte, error) {
+	var buffer bytes.Buffer
+	err := tmpl.Execute(&buffer, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to execute the %s template", tmpl.Name())
+	}
+	content, err := k8syaml.ToJSON(buffer.Bytes())
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid YAML produced by the %s template", tmpl.Name())
+	}
+	return content, nil
+}
+
+// checkTemplateMeta checks the type and namespace set by a template, the name defaulting to the computed one
+// the labels, annotations and owner references of the controller override the ones of the template
+func checkTemplateMeta(tmpl *template.Template, typeMeta metav1.TypeMeta, meta metav1.Object, computed metav1.Object, apiVersion, kind string) error {
+	if (typeMeta.APIVersion != "" && typeMeta.APIVersion != apiVersion) || (typeMeta.Kind != "" && typeMeta.Kind != kind) {
+		return errors.Errorf("the %s template must produce a %s %s, not a %s %s",
+			tmpl.Name(), apiVersion, kind, typeMeta.APIVersion, typeMeta.Kind)
+	}
+	if meta.GetNamespace() != "" && meta.GetNamespace() != computed.GetNamespace() {
+		return errors.Errorf("the %s template must not change the namespace %s to %s",
+			tmpl.Name(), computed.GetNamespace(), meta.GetNamespace())
+	}
+	meta.SetNamespace(computed.GetNamespace())
+	if meta.GetName() == "" {
+		meta.SetName(computed.GetName())
+	}
+	labels := meta.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for k, v := range computed.GetLabels() {
+		labels[k] = v
+	}
+	meta.SetLabels(labels)
+	annotations := meta.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[GeneratedByAnnotationKey] = computed.GetAnnotations()[GeneratedByAnnotationKey]
+	meta.SetAnnotations(annotations)
+	meta.SetOwnerReferences(computed.GetOwnerReferences())
+	return nil
+}
+
+// renderIngress produces the ingress of the service from the ingress template, instead of the computed one
+func renderIngress(tmpl *template.Template, data *templateData, computed *networkingv1.Ingress) (*networkingv1.Ingress, error) {
+	content, err := executeObjectTemplate(tmpl, data)
+	if err != nil {
+		return nil, err
+	}
+	// the unknown fields are rejected
+	ingress := &networkingv1.Ingress{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(ingress)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid ingress produced by the %s template", tmpl.Name())
+	}
+	err = checkTemplateMeta(tmpl, ingress.TypeMeta, ingress, computed, "networking.k8s.io/v1", "Ingress")
+	if err != nil {
+		return nil, err
+	}
+	if len(ingress.Spec.Rules) == 0 && ingress.Spec.DefaultBackend == nil {
+		return nil, errors.Errorf("the ingress produced by the %s template has no rule", tmpl.Name())
+	}
+	ingress.TypeMeta = metav1.TypeMeta{}
+	ingress.Status = networkingv1.IngressStatus{}
+	sortIngressSpec(&ingress.Spec)
+	return ingress, nil
+}
+
+// renderHTTPRoute produces the HTTP route of the service from the HTTP route template, instead of the computed one
+func renderHTTPRoute(tmpl *template.Template, data *templateData, computed *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	content, err := executeObjectTemplate(tmpl, data)
+	if err != nil {
+		return nil, err
+	}
+	// the integers are kept as such for the comparison with the existing route
+	route := &unstructured.Unstructured{}
+	err = utiljson.Unmarshal(content, &route.Object)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid http route produced by the %s template", tmpl.Name())
+	}
+	if route.Object == nil {
+		return nil, errors.Errorf("the http route produced by the %s template is empty", tmpl.Name())
+	}
+	typeMeta := metav1.TypeMeta{APIVersion: route.GetAPIVersion(), Kind: route.GetKind()}
+	err = checkTemplateMeta(tmpl, typeMeta, route, computed, computed.GetAPIVersion(), computed.GetKind())
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := route.Object["spec"].(map[string]interface{}); !ok {
+		return nil, errors.Errorf("the http route produced by the %s template has no spec", tmpl.Name())
+	}
+	route.SetAPIVersion(computed.GetAPIVersion())
+	route.SetKind(computed.GetKind())
+	delete(route.Object, "status")
+	return route, nil
+}
@@ -0,0 +1,205 @@
+package exposestrategy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testIngressTemplate = `apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  labels:
+    team: {{ index .Service.Labels "team" | quote }}
+  annotations:
+{{ toYaml .Annotations | indent 4 }}
+    example.com/rewrite: "true"
+spec:
+  ingressClassName: exotic
+  rules:
+  {{- range .Hosts }}
+  - host: {{ . }}
+    http:
+      paths:
+      - path: /api
+        pathType: Prefix
+        backend:
+          service:
+            name: {{ $.BackendName }}
+            port:
+              number: {{ $.BackendPort }}
+  {{- end }}
+`
+
+const testHTTPRouteTemplate = `spec:
+  parentRefs:
+  - name: {{ .GatewayName }}
+  hostnames:
+  - {{ .Host }}
+  rules:
+  - backendRefs:
+    - name: {{ .BackendName }}
+      port: {{ .BackendPort }}
+    filters:
+    - type: RequestHeaderModifier
+      requestHeaderModifier:
+        add:
+        - name: X-Exposed-By
+          value: exposecontroller
+`
+
+func writeTemplate(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestIngressStrategy_Templates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "main",
+			Name:            "svc",
+			UID:             "svc-uid",
+			Labels:          map[string]string{"team": "payments"},
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(service)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			HTTPRouteResource: "HTTPRouteList",
+		})
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:           "ingress",
+		Namespace:         "main",
+		Domain:            "my-domain.com",
+		HTTPRoute:         true,
+		GatewayName:       "gateway",
+		DynamicClient:     dynamicClient,
+		IngressTemplate:   writeTemplate(t, dir, "ingress.yaml", testIngressTemplate),
+		HTTPRouteTemplate: writeTemplate(t, dir, "httproute.yaml", testHTTPRouteTemplate),
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(service))
+
+	ctx := context.Background()
+	ingress, err := client.NetworkingV1().Ingresses("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"provider": "fabric8", "team": "payments"}, ingress.Labels)
+	assert.Equal(t, "true", ingress.Annotations["example.com/rewrite"])
+	assert.Equal(t, "exposecontroller", ingress.Annotations["fabric8.io/generated-by"])
+	if assert.Len(t, ingress.OwnerReferences, 1) {
+		assert.Equal(t, "svc", ingress.OwnerReferences[0].Name)
+	}
+	pathType := networkingv1.PathTypePrefix
+	className := "exotic"
+	assert.Equal(t, networkingv1.IngressSpec{
+		IngressClassName: &className,
+		Rules: []networkingv1.IngressRule{{
+			Host: "svc.main.my-domain.com",
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     "/api",
+						PathType: &pathType,
+						Backend: networkingv1.IngressBackend{
+							Service: &networkingv1.IngressServiceBackend{
+								Name: "svc",
+								Port: networkingv1.ServiceBackendPort{Number: 8080},
+							},
+						},
+					}},
+				},
+			},
+		}},
+	}, ingress.Spec)
+
+	route, err := dynamicClient.Resource(HTTPRouteResource).Namespace("main").Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "exposecontroller", route.GetAnnotations()["fabric8.io/generated-by"])
+	assert.Equal(t, "HTTPRoute", route.GetKind())
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"backendRefs": []interface{}{map[string]interface{}{
+			"name": "svc",
+			"port": int64(8080),
+		}},
+		"filters": []interface{}{map[string]interface{}{
+			"type": "RequestHeaderModifier",
+			"requestHeaderModifier": map[string]interface{}{
+				"add": []interface{}{map[string]interface{}{
+					"name":  "X-Exposed-By",
+					"value": "exposecontroller",
+				}},
+			},
+		}},
+	}}, route.Object["spec"].(map[string]interface{})["rules"])
+}
+
+func TestRenderIngress(t *testing.T) {
+	computed := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "main",
+			Name:      "svc",
+			Labels:    map[string]string{"provider": "fabric8"},
+		},
+	}
+	data := &templateData{Name: "svc", Namespace: "main"}
+	for _, test := range []struct {
+		template string
+		err      string
+	}{{
+		template: "metadata:\n  name: other\nspec:\n  defaultBackend:\n    service:\n      name: svc\n      port:\n        number: 80\n",
+	}, {
+		template: "metadata:\n  namespace: other\nspec:\n  defaultBackend:\n    service:\n      name: svc\n",
+		err:      "the ingress template must not change the namespace main to other",
+	}, {
+		template: "kind: Service\nspec: {}\n",
+		err:      "the ingress template must produce a networking.k8s.io/v1 Ingress, not a  Service",
+	}, {
+		template: "spec: {}\n",
+		err:      "the ingress produced by the ingress template has no rule",
+	}, {
+		template: "spec:\n  rulez: []\n",
+		err:      `invalid ingress produced by the ingress template: json: unknown field "rulez"`,
+	}, {
+		template: "spec:\n  rules: {{ .Missing }}\n",
+		err:      `failed to execute the ingress template: template: ingress:2:12: executing "ingress" at <.Missing>: can't evaluate field Missing in type *exposestrategy.templateData`,
+	}} {
+		dir, err := ioutil.TempDir("", "templates")
+		require.NoError(t, err)
+		tmpl, err := loadObjectTemplate("ingress", writeTemplate(t, dir, "ingress.yaml", test.template))
+		os.RemoveAll(dir)
+		require.NoError(t, err)
+		ingress, err := renderIngress(tmpl, data, computed)
+		if test.err != "" {
+			assert.EqualError(t, err, test.err, test.template)
+			continue
+		}
+		if assert.NoError(t, err, test.template) {
+			assert.Equal(t, "other", ingress.Name)
+			assert.Equal(t, "main", ingress.Namespace)
+			assert.Equal(t, map[string]string{"provider": "fabric8"}, ingress.Labels)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package exposestrategy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+const (
+	// TLSMergeReplace replaces the TLS entries of the ingresses with those of the controller
+	TLSMergeReplace = "replace"
+	// TLSMergePreserve keeps the TLS entries added to the ingresses by others, such as the ingress-shim of cert-manager
+	TLSMergePreserve = "preserve"
+
+	// generatedTLSAnnotationKey lists the secrets of the TLS entries generated by the controller, comma separated
+	generatedTLSAnnotationKey = "fabric8.io/generated-tls"
+)
+
+// parseTLSMergePolicy checks the TLS merge policy, "replace" by default
+func parseTLSMergePolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return TLSMergeReplace, nil
+	case TLSMergeReplace, TLSMergePreserve:
+		return policy, nil
+	default:
+		return "", errors.Errorf("invalid TLS merge policy \"%s\", must be \"%s\" or \"%s\"",
+			policy, TLSMergeReplace, TLSMergePreserve)
+	}
+}
+
+// mergeIngressTLS records the secrets of the generated TLS entries in the ingress with the preserve policy,
+// and appends the entries of the existing ingress that were never generated by the controller
+// the existing ingress is nil when the ingress is created
+func (s *IngressStrategy) mergeIngressTLS(ingress, existing *networkingv1.Ingress) {
+	if s.tlsMergePolicy != TLSMergePreserve {
+		return
+	}
+	generated := map[string]bool{}
+	secrets := []string{}
+	for _, tls := range ingress.Spec.TLS {
+		if tls.SecretName != "" && !generated[tls.SecretName] {
+			generated[tls.SecretName] = true
+			secrets = append(secrets, tls.SecretName)
+		}
+	}
+	sort.Strings(secrets)
+	if ingress.Annotations == nil {
+		ingress.Annotations = map[string]string{}
+	}
+	ingress.Annotations[generatedTLSAnnotationKey] = strings.Join(secrets, ",")
+	if existing == nil {
+		return
+	}
+	// the entries generated before are not preserved, such as when the secret of the service changes
+	owned := map[string]bool{}
+	for _, secret := range strings.Split(existing.Annotations[generatedTLSAnnotationKey], ",") {
+		owned[secret] = true
+	}
+	for _, tls := range existing.Spec.TLS {
+		if tls.SecretName == "" || generated[tls.SecretName] || owned[tls.SecretName] {
+			continue
+		}
+		ingress.Spec.TLS = append(ingress.Spec.TLS, tls)
+	}
+}
@@ -0,0 +1,83 @@
+package exposestrategy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressStrategy_TLSMergePolicy(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "main",
+			Name:            "svc",
+			ResourceVersion: "1",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Port: 8080}},
+		},
+	}
+	client := fake.NewSimpleClientset(service)
+	strategy, err := NewIngressStrategy(nil, client, &Config{
+		Exposer:        "ingress",
+		Namespace:      "main",
+		Domain:         "my-domain.com",
+		TLSSecretName:  "main-tls",
+		TLSMergePolicy: TLSMergePreserve,
+	})
+	require.NoError(t, err)
+	require.NoError(t, strategy.Sync())
+	require.NoError(t, strategy.Add(service))
+
+	// cert-manager's ingress-shim issues the certificate of a TLS entry added by the user
+	ctx := context.Background()
+	ingresses := client.NetworkingV1().Ingresses("main")
+	ingress, err := ingresses.Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "main-tls", ingress.Annotations[generatedTLSAnnotationKey])
+	userTLS := networkingv1.IngressTLS{Hosts: []string{"svc.example.com"}, SecretName: "user-tls"}
+	ingress.Spec.TLS = append(ingress.Spec.TLS, userTLS)
+	ingress.ResourceVersion = "1"
+	_, err = ingresses.Update(ctx, ingress, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// the secret of the controller changes, its old entry is replaced but the user one is preserved
+	service.Annotations = map[string]string{AdditionalHostsAnnotationKey: "svc.other.com=other-tls"}
+	require.NoError(t, strategy.Add(service))
+	ingress, err = ingresses.Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []networkingv1.IngressTLS{{
+		Hosts:      []string{"svc.main.my-domain.com"},
+		SecretName: "main-tls",
+	}, {
+		Hosts:      []string{"svc.other.com"},
+		SecretName: "other-tls",
+	}, userTLS}, ingress.Spec.TLS)
+	assert.Equal(t, "main-tls,other-tls", ingress.Annotations[generatedTLSAnnotationKey])
+
+	ingress.ResourceVersion = "1"
+	_, err = ingresses.Update(ctx, ingress, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	service.Annotations = nil
+	require.NoError(t, strategy.Add(service))
+	ingress, err = ingresses.Get(ctx, "svc", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []networkingv1.IngressTLS{{
+		Hosts:      []string{"svc.main.my-domain.com"},
+		SecretName: "main-tls",
+	}, userTLS}, ingress.Spec.TLS, "the entries generated before are removed")
+
+	_, err = NewIngressStrategy(nil, client, &Config{
+		Exposer:        "ingress",
+		Domain:         "my-domain.com",
+		TLSMergePolicy: "merge",
+	})
+	assert.EqualError(t, err, `invalid TLS merge policy "merge", must be "replace" or "preserve"`)
+}
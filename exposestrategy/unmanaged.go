@@ -0,0 +1,21 @@
+package exposestrategy
+
+import (
+	"k8s.io/klog"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// UnmanagedAnnotationKey annotation set to "true" on a generated ingress stops the controller from updating or deleting it,
+// such as when the operators edit what the templates of the controller cannot express
+const UnmanagedAnnotationKey = "expose.fabric8.io/unmanaged"
+
+// isUnmanagedIngress tells if the ingress is left alone by the controller, the skipped action is logged
+func isUnmanagedIngress(ingress *networkingv1.Ingress, action string) bool {
+	if ingress.Annotations[UnmanagedAnnotationKey] != "true" {
+		return false
+	}
+	klog.Infof("not %s the ingress %s/%s, it is annotated with %s=true",
+		action, ingress.Namespace, ingress.Name, UnmanagedAnnotationKey)
+	return true
+}
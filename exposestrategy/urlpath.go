@@ -0,0 +1,70 @@
+package exposestrategy
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// TrailingSlashPreserve publishes the paths of the URLs as they are set
+	TrailingSlashPreserve = "preserve"
+	// TrailingSlashAlways ends the paths of the URLs with a slash, such as "https://host/"
+	TrailingSlashAlways = "always"
+	// TrailingSlashNever strips the trailing slashes of the paths of the URLs, such as "https://host"
+	TrailingSlashNever = "never"
+)
+
+// parseTrailingSlashPolicy checks the trailing slash policy of the URLs, "preserve" by default
+func parseTrailingSlashPolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return TrailingSlashPreserve, nil
+	case TrailingSlashPreserve, TrailingSlashAlways, TrailingSlashNever:
+		return policy, nil
+	default:
+		return "", errors.Errorf("invalid trailing slash policy \"%s\", must be \"%s\", \"%s\" or \"%s\"",
+			policy, TrailingSlashAlways, TrailingSlashNever, TrailingSlashPreserve)
+	}
+}
+
+// applyTrailingSlash adds or strips the trailing slash of the path of the URL, the values which are not URLs are kept as is
+func applyTrailingSlash(policy, value string) string {
+	if value == "" || policy == TrailingSlashPreserve || policy == "" {
+		return value
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Host == "" {
+		return value
+	}
+	switch policy {
+	case TrailingSlashAlways:
+		if !strings.HasSuffix(parsed.Path, "/") {
+			parsed.Path += "/"
+			if parsed.RawPath != "" {
+				parsed.RawPath += "/"
+			}
+		}
+	case TrailingSlashNever:
+		parsed.Path = strings.TrimRight(parsed.Path, "/")
+		parsed.RawPath = strings.TrimRight(parsed.RawPath, "/")
+	}
+	return parsed.String()
+}
+
+// normalizeURLPath starts the path with a slash and collapses its repeated slashes, the empty path is kept
+func normalizeURLPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	return collapseSlashes("/" + path)
+}
+
+// collapseSlashes replaces the repeated slashes of the path by one
+func collapseSlashes(path string) string {
+	for strings.Contains(path, "//") {
+		path = strings.ReplaceAll(path, "//", "/")
+	}
+	return path
+}
@@ -0,0 +1,92 @@
+package exposestrategy
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLJoin(t *testing.T) {
+	for expected, paths := range map[string][]string{
+		"/main/app/":         {"/", "main", "app", "/"},
+		"/main/app/callback": {"/", "main", "app", "//callback"},
+		"/main/app/cb/":      {"/", "main/", "", "/app/", "cb//"},
+		"/":                  {"/"},
+		"main/app":           {"main", "app"},
+		"":                   {},
+	} {
+		assert.Equal(t, expected, URLJoin(paths...), "%v", paths)
+	}
+	assert.Equal(t, "https://host/callback", urlJoin("https://host/", "//callback"))
+	assert.Equal(t, "https://host/a/b/", urlJoin("https://host", "/a//b/"))
+}
+
+func TestApplyTrailingSlash(t *testing.T) {
+	for _, test := range []struct {
+		policy, url, expected string
+	}{
+		{TrailingSlashPreserve, "https://host/path/", "https://host/path/"},
+		{TrailingSlashAlways, "https://host", "https://host/"},
+		{TrailingSlashAlways, "https://host:8443/path", "https://host:8443/path/"},
+		{TrailingSlashAlways, "https://host/a%2Fb", "https://host/a%2Fb/"},
+		{TrailingSlashNever, "https://host/", "https://host"},
+		{TrailingSlashNever, "https://host/path//", "https://host/path"},
+		{TrailingSlashAlways, "", ""},
+		{TrailingSlashAlways, "not a url", "not a url"},
+	} {
+		assert.Equal(t, test.expected, applyTrailingSlash(test.policy, test.url), "%s %s", test.policy, test.url)
+	}
+
+	assert.Equal(t, "", normalizeURLPath(""))
+	assert.Equal(t, "/a/b/", normalizeURLPath("a//b/"))
+
+	policy, err := parseTrailingSlashPolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, TrailingSlashPreserve, policy)
+	_, err = parseTrailingSlashPolicy("sometimes")
+	assert.EqualError(t, err, `invalid trailing slash policy "sometimes", must be "always", "never" or "preserve"`)
+}
+
+func TestIngressStrategy_URLTrailingSlash(t *testing.T) {
+	for policy, expected := range map[string]string{
+		TrailingSlashPreserve: "http://my-domain.com/main/svc/callback/",
+		TrailingSlashNever:    "http://my-domain.com/main/svc/callback",
+	} {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "main",
+				Name:      "svc",
+				Annotations: map[string]string{
+					"fabric8.io/ingress.path": "//callback/",
+				},
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{Port: 8080}},
+			},
+		}
+		client := fake.NewSimpleClientset(svc)
+		strategy, err := NewIngressStrategy(nil, client, &Config{
+			Exposer:          "ingress",
+			Namespace:        "main",
+			Domain:           "my-domain.com",
+			HTTP:             true,
+			PathMode:         PathModeUsePath,
+			URLTrailingSlash: policy,
+		})
+		require.NoError(t, err)
+		require.NoError(t, strategy.Sync())
+		require.NoError(t, strategy.Add(svc))
+
+		ingress, err := client.NetworkingV1().Ingresses("main").Get(nil, "svc", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "/main/svc/callback/", ingress.Spec.Rules[0].HTTP.Paths[0].Path, policy)
+		svc, err = client.CoreV1().Services("main").Get(nil, "svc", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, expected, svc.Annotations[ExposeAnnotationKey], policy)
+	}
+}
@@ -0,0 +1,114 @@
+package exposestrategy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+// printfVerbPattern matches the verbs of the printf style URL templates such as "%[1]s.%[2]s.%[3]s"
+var printfVerbPattern = regexp.MustCompile(`%(\[[0-9]+\])?[a-zA-Z]`)
+
+// printfTemplateFields are the fields of the URL template of the arguments of the printf style URL templates
+var printfTemplateFields = []string{"{{.Service}}", "{{.Namespace}}", "{{.Domain}}"}
+
+// isPrintfURLTemplate tells if the URL template is of the deprecated printf style, it uses verbs and no action
+func isPrintfURLTemplate(urltemplate string) bool {
+	return !strings.Contains(urltemplate, "{{") && printfVerbPattern.MatchString(urltemplate)
+}
+
+// convertPrintfURLTemplate converts the printf style URL template to a Go template
+// the verbs are %s or %v, their explicit arguments from 1 to 3 are the service, namespace and domain
+func convertPrintfURLTemplate(urltemplate string) (string, error) {
+	var converted strings.Builder
+	next := 0
+	for i := 0; i < len(urltemplate); i++ {
+		if urltemplate[i] != '%' {
+			converted.WriteByte(urltemplate[i])
+			continue
+		}
+		rest := urltemplate[i+1:]
+		if strings.HasPrefix(rest, "%") {
+			converted.WriteByte('%')
+			i++
+			continue
+		}
+		argument := next
+		if strings.HasPrefix(rest, "[") {
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return "", errors.Errorf("URLTemplate \"%s\" has an unclosed argument index", urltemplate)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 1 || index > len(printfTemplateFields) {
+				return "", errors.Errorf("URLTemplate \"%s\" has an invalid argument index \"%s\", must be from 1 to %d",
+					urltemplate, rest[1:end], len(printfTemplateFields))
+			}
+			argument = index - 1
+			rest = rest[end+1:]
+			i += end + 1
+		}
+		if rest == "" || (rest[0] != 's' && rest[0] != 'v') {
+			return "", errors.Errorf("URLTemplate \"%s\" has an unsupported verb, only %%s and %%v are", urltemplate)
+		}
+		if argument >= len(printfTemplateFields) {
+			return "", errors.Errorf("URLTemplate \"%s\" has more than %d verbs", urltemplate, len(printfTemplateFields))
+		}
+		converted.WriteString(printfTemplateFields[argument])
+		next = argument + 1
+		i++
+	}
+	return converted.String(), nil
+}
+
+// normalizeURLTemplate returns the Go template of the URL template, converting the deprecated printf style ones
+func normalizeURLTemplate(urltemplate string) (string, error) {
+	if !isPrintfURLTemplate(urltemplate) {
+		return urltemplate, nil
+	}
+	converted, err := convertPrintfURLTemplate(urltemplate)
+	if err != nil {
+		return "", err
+	}
+	klog.Warningf("URLTemplate \"%s\" uses the deprecated printf style, use \"%s\" instead", urltemplate, converted)
+	return converted, nil
+}
+
+// ConvertURLTemplate converts the URL template of the deprecated printf style to a Go template and checks it,
+// the Go templates are returned as is
+func ConvertURLTemplate(urltemplate string) (string, error) {
+	converted := urltemplate
+	if isPrintfURLTemplate(urltemplate) {
+		var err error
+		converted, err = convertPrintfURLTemplate(urltemplate)
+		if err != nil {
+			return "", err
+		}
+	}
+	if _, err := getURLFormat(converted); err != nil {
+		return "", err
+	}
+	return converted, nil
+}
+
+// ServiceHostName returns the value of the fabric8.io/host.name annotation of a service of the namespace
+// rendering the host with the URL template and the domain, an error if no value renders it
+func ServiceHostName(urltemplate, namespace, domain, host string) (string, error) {
+	format, err := getURLFormat(urltemplate)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(fmt.Sprintf(format, "\x00", namespace, domain), "\x00")
+	if len(parts) == 2 && len(host) > len(parts[0])+len(parts[1]) &&
+		strings.HasPrefix(host, parts[0]) && strings.HasSuffix(host, parts[1]) {
+		name := host[len(parts[0]) : len(host)-len(parts[1])]
+		if fmt.Sprintf(format, name, namespace, domain) == host {
+			return name, nil
+		}
+	}
+	return "", errors.Errorf("host %s does not match the URL template in namespace %s and domain %s", host, namespace, domain)
+}
@@ -0,0 +1,71 @@
+package exposestrategy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertURLTemplate(t *testing.T) {
+	examples := []struct {
+		template string
+		expected string
+		err      string
+	}{{
+		template: "%[1]s.%[2]s.%[3]s",
+		expected: "{{.Service}}.{{.Namespace}}.{{.Domain}}",
+	}, {
+		template: "%s-%s.%s",
+		expected: "{{.Service}}-{{.Namespace}}.{{.Domain}}",
+	}, {
+		template: "%[1]s-100%%.%[3]s",
+		expected: "{{.Service}}-100%.{{.Domain}}",
+	}, {
+		template: "%[2]s-%s.%[3]s",
+		err:      `URLTemplate "{{.Namespace}}-{{.Domain}}.{{.Domain}}" does not use the {{.Service}}`,
+	}, {
+		template: "{{.Service}}-100%.{{.Domain}}",
+		expected: "{{.Service}}-100%.{{.Domain}}",
+	}, {
+		template: "%[4]s.%[3]s",
+		err:      `URLTemplate "%[4]s.%[3]s" has an invalid argument index "4", must be from 1 to 3`,
+	}, {
+		template: "%[1]d.%[3]s",
+		err:      `URLTemplate "%[1]d.%[3]s" has an unsupported verb, only %s and %v are`,
+	}, {
+		template: "%s.%s.%s.%s",
+		err:      `URLTemplate "%s.%s.%s.%s" has more than 3 verbs`,
+	}}
+	for _, example := range examples {
+		converted, err := ConvertURLTemplate(example.template)
+		if example.err != "" {
+			assert.EqualError(t, err, example.err, example.template)
+			continue
+		}
+		require.NoError(t, err, example.template)
+		assert.Equal(t, example.expected, converted, example.template)
+	}
+}
+
+func TestGetURLFormat_Printf(t *testing.T) {
+	for _, urltemplate := range []string{"%[1]s.%[2]s.%[3]s", "%s.%s.%s", "{{.Service}}.{{.Namespace}}.{{.Domain}}"} {
+		format, err := getURLFormat(urltemplate)
+		require.NoError(t, err, urltemplate)
+		assert.Equal(t, "svc.main.my-domain.com", fmt.Sprintf(format, "svc", "main", "my-domain.com"), urltemplate)
+	}
+}
+
+func TestServiceHostName(t *testing.T) {
+	name, err := ServiceHostName("", "main", "my-domain.com", "web.main.my-domain.com")
+	require.NoError(t, err)
+	assert.Equal(t, "web", name)
+	name, err = ServiceHostName("{{.Service}}-{{.Namespace}}.{{.Domain}}", "main", "my-domain.com", "shop-api-main.my-domain.com")
+	require.NoError(t, err)
+	assert.Equal(t, "shop-api", name)
+	_, err = ServiceHostName("", "main", "my-domain.com", "web.example.com")
+	assert.EqualError(t, err, "host web.example.com does not match the URL template in namespace main and domain my-domain.com")
+	_, err = ServiceHostName("", "main", "my-domain.com", "main.my-domain.com")
+	assert.Error(t, err, "empty host name")
+}
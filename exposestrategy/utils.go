@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"net"
+	"reflect"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -25,10 +27,10 @@ func findHTTPProtocol(svc *v1.Service, hostName string) string {
 			protocol = "https"
 		}
 	}
-	// check if the service port has a name of https
-	for _, port := range svc.Spec.Ports {
-		if port.Name == "https" {
-			protocol = port.Name
+	// check if the service port has a name or an app protocol of https
+	for i, port := range svc.Spec.Ports {
+		if port.Name == "https" || appProtocol(&svc.Spec.Ports[i]) == "https" {
+			protocol = "https"
 		}
 	}
 	return protocol
@@ -40,6 +42,11 @@ func addServiceAnnotation(svc *v1.Service, hostName string) error {
 }
 
 func addServiceAnnotationWithProtocol(svc *v1.Service, hostName, path, protocol string) error {
+	return addServiceAnnotationWithPort(svc, hostName, 0, path, protocol)
+}
+
+// addServiceAnnotationWithPort publishes the URL of the host with the given port, omitted if 0 or the default port of the protocol
+func addServiceAnnotationWithPort(svc *v1.Service, hostName string, port int32, path, protocol string) error {
 	if svc.Annotations == nil {
 		svc.Annotations = map[string]string{}
 	}
@@ -48,14 +55,10 @@ func addServiceAnnotationWithProtocol(svc *v1.Service, hostName, path, protocol
 		return nil
 	}
 
-	exposeURL := protocol + "://" + hostName
 	if annotationPath, ok := svc.Annotations[APIServicePathAnnotationKey]; ok {
 		path = annotationPath
 	}
-	if len(path) > 0 {
-		exposeURL = urlJoin(exposeURL, path)
-	}
-	svc.Annotations[ExposeAnnotationKey] = exposeURL
+	svc.Annotations[ExposeAnnotationKey] = buildURL(hostName, port, path, protocol)
 
 	if key := svc.Annotations[ExposeHostNameAsAnnotationKey]; key != "" {
 		svc.Annotations[key] = hostName
@@ -69,21 +72,36 @@ func removeServiceAnnotation(svc *v1.Service) bool {
 		return false
 	}
 	delete(svc.Annotations, ExposeAnnotationKey)
+	delete(svc.Annotations, ExposeURLsAnnotationKey)
+	delete(svc.Annotations, ExposeHTTPURLAnnotationKey)
+	delete(svc.Annotations, ExposeGroupURLsAnnotationKey)
+	delete(svc.Annotations, ExposeHTTP3AnnotationKey)
+	delete(svc.Annotations, ExposeDomainURLsAnnotationKey)
+	delete(svc.Annotations, ExposeAdoptedIngressAnnotationKey)
 	if key := svc.Annotations[ExposeHostNameAsAnnotationKey]; key != "" {
 		delete(svc.Annotations, key)
 	}
 	return true
 }
 
+// serviceKey returns the "namespace/name" key of a service, cheaper than formatting it
+func serviceKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
 // urlJoin joins the given URL paths so that there is a / separating them but not a double //
 func urlJoin(repo string, path string) string {
-	return strings.TrimSuffix(repo, "/") + "/" + strings.TrimPrefix(path, "/")
+	return strings.TrimRight(repo, "/") + "/" + strings.TrimLeft(collapseSlashes(path), "/")
 }
 
 var patchType types.PatchType = types.StrategicMergePatchType
 var emptyPatch []byte = []byte("{}")
 
 func createServicePatch(origin, modified *v1.Service) ([]byte, error) {
+	// most resyncs change nothing, comparing is cheaper than computing the empty patch
+	if reflect.DeepEqual(origin, modified) {
+		return nil, nil
+	}
 	// add another annotations to avoid a patch that deletes all annotations
 	copy := origin.DeepCopy()
 	if copy.Annotations == nil {
@@ -114,6 +132,7 @@ func createServicePatch(origin, modified *v1.Service) ([]byte, error) {
 	if bytes.Equal(patch, emptyPatch) {
 		return nil, nil
 	}
+	logPatch("service", origin.Namespace, origin.Name, patch)
 	return patch, nil
 }
 
@@ -127,33 +146,86 @@ func getURLFormat(urltemplate string) (string, error) {
 	if urltemplate == "" {
 		urltemplate = "{{.Service}}.{{.Namespace}}.{{.Domain}}"
 	}
-	placeholders := urlTemplateParts{"%[1]s", "%[2]s", "%[3]s"}
+	urltemplate, err := normalizeURLTemplate(urltemplate)
+	if err != nil {
+		return "", err
+	}
+	// the parts are replaced by the verbs of the format once the percent signs of the template are escaped
+	placeholders := urlTemplateParts{"\x00service\x00", "\x00namespace\x00", "\x00domain\x00"}
 	tmpl, err := template.New("format").Parse(urltemplate)
 	if err != nil {
-		errors.Wrap(err, "Failed to parse URLTemplate")
+		return "", errors.Wrap(err, "Failed to parse URLTemplate")
 	}
 	var buffer bytes.Buffer
 	err = tmpl.Execute(&buffer, placeholders)
 	if err != nil {
-		errors.Wrap(err, "Failed to execute URLTemplate")
+		return "", errors.Wrap(err, "Failed to execute URLTemplate")
+	}
+	// the services would share the same host
+	if !strings.Contains(buffer.String(), placeholders.Service) {
+		return "", errors.Errorf("URLTemplate \"%s\" does not use the {{.Service}}", urltemplate)
 	}
-	return buffer.String(), nil
+	format := strings.ReplaceAll(buffer.String(), "%", "%%")
+	return strings.NewReplacer(placeholders.Service, "%[1]s", placeholders.Namespace, "%[2]s", placeholders.Domain, "%[3]s").
+		Replace(format), nil
 }
 
 // URLJoin joins the given paths so that there is only ever one '/' character between the paths
+// the empty paths are skipped, the result starts with a slash like the first path and ends with one like the last path
 func URLJoin(paths ...string) string {
-	var buffer bytes.Buffer
-	last := len(paths) - 1
-	for i, path := range paths {
-		p := path
-		if i > 0 {
-			buffer.WriteString("/")
-			p = strings.TrimPrefix(p, "/")
+	var parts []string
+	for _, path := range paths {
+		if trimmed := strings.Trim(collapseSlashes(path), "/"); trimmed != "" {
+			parts = append(parts, trimmed)
+		}
+	}
+	if len(paths) == 0 {
+		return ""
+	}
+	joined := strings.Join(parts, "/")
+	if strings.HasPrefix(paths[0], "/") {
+		joined = "/" + joined
+	}
+	if last := paths[len(paths)-1]; strings.HasSuffix(last, "/") && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+	return joined
+}
+
+// SkipOwnerReferences tells whether the objects generated for the service have no owner reference,
+// the annotation of the service overrides the given default
+func SkipOwnerReferences(svc *v1.Service, skipByDefault bool) bool {
+	if value, ok := svc.Annotations[OwnerReferencesAnnotationKey]; ok {
+		return value == "false"
+	}
+	return skipByDefault
+}
+
+// parsePortMapping parses port mapping rules such as "8080->80, 8443->443"
+// into a map from the service port to the backend port to use instead
+func parsePortMapping(rules string) (map[int32]int32, error) {
+	mapping := map[int32]int32{}
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		parts := strings.Split(rule, "->")
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid port mapping rule \"%s\", expected \"<port>-><port>\"", rule)
 		}
-		if i < last {
-			p = strings.TrimSuffix(p, "/")
+		ports := make([]int32, 2)
+		for i, part := range parts {
+			port, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid port \"%s\" in port mapping rule \"%s\"", part, rule)
+			}
+			if port <= 0 || port > 65535 {
+				return nil, errors.Errorf("port %d out of range in port mapping rule \"%s\"", port, rule)
+			}
+			ports[i] = int32(port)
 		}
-		buffer.WriteString(p)
+		mapping[ports[0]] = ports[1]
 	}
-	return buffer.String()
+	return mapping, nil
 }
@@ -143,3 +143,50 @@ func TestRemoveServiceAnnotation(t *testing.T) {
 		assert.Equal(t, test.expectedAnnotations, test.svc.Annotations, test.name)
 	}
 }
+
+func TestParsePortMapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    string
+		expected map[int32]int32
+		err      bool
+	}{
+		{
+			name:     "empty",
+			rules:    "",
+			expected: map[int32]int32{},
+		},
+		{
+			name:  "rules",
+			rules: "8080->80, 8443->443,",
+			expected: map[int32]int32{
+				8080: 80,
+				8443: 443,
+			},
+		},
+		{
+			name:  "missing arrow",
+			rules: "8080:80",
+			err:   true,
+		},
+		{
+			name:  "not a number",
+			rules: "http->80",
+			err:   true,
+		},
+		{
+			name:  "out of range",
+			rules: "8080->0",
+			err:   true,
+		},
+	}
+
+	for _, test := range tests {
+		mapping, err := parsePortMapping(test.rules)
+		if test.err {
+			assert.Error(t, err, test.name)
+		} else if assert.NoError(t, err, test.name) {
+			assert.Equal(t, test.expected, mapping, test.name)
+		}
+	}
+}
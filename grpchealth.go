@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/devopscare/exposecontroller/controller"
+	"k8s.io/klog"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// grpcHealthService is the service name of the controller in the gRPC health checks, the empty name is the whole server
+const grpcHealthService = "exposecontroller"
+
+// grpcHealthInterval is the period of the update of the gRPC health status from the readiness of the controller
+const grpcHealthInterval = time.Second
+
+// controllerReady tells if the services are synced by the strategy and the ingress controller is up
+func controllerReady(contr controller.Controller) bool {
+	// the exposed services are unreachable while the ingress controller is down
+	ingressController := contr.IngressControllerStatus()
+	return contr.HasSynced() && (ingressController == nil || ingressController.Ready)
+}
+
+// serveGRPCHealth serves the gRPC health service on the port, SERVING once the controller is ready as with /healthz,
+// on all the interfaces as the healthz port, so that the kubelet probes the pod IP and port-forward works
+func serveGRPCHealth(ctx context.Context, contr controller.Controller, port int) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%v", port))
+	if err != nil {
+		klog.Fatalf("failed to listen for the gRPC health checks: %s", err)
+	}
+	status := health.NewServer()
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, status)
+	go updateGRPCHealth(ctx, status, contr, grpcHealthInterval)
+	klog.Fatal(server.Serve(listener))
+}
+
+// updateGRPCHealth sets the gRPC health status from the readiness of the controller until the context is done,
+// cancelled on SIGTERM, all the services are then NOT_SERVING
+func updateGRPCHealth(ctx context.Context, status *health.Server, contr controller.Controller, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		servingStatus := healthpb.HealthCheckResponse_NOT_SERVING
+		if controllerReady(contr) {
+			servingStatus = healthpb.HealthCheckResponse_SERVING
+		}
+		status.SetServingStatus("", servingStatus)
+		status.SetServingStatus(grpcHealthService, servingStatus)
+		select {
+		case <-ctx.Done():
+			status.Shutdown()
+			return
+		case <-ticker.C:
+		}
+	}
+}
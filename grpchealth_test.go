@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devopscare/exposecontroller/controller"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readyController is a controller whose readiness is set by the test
+type readyController struct {
+	controller.Controller
+	synced            int32
+	ingressController *controller.IngressControllerStatus
+}
+
+func (c *readyController) HasSynced() bool {
+	return atomic.LoadInt32(&c.synced) == 1
+}
+
+func (c *readyController) IngressControllerStatus() *controller.IngressControllerStatus {
+	return c.ingressController
+}
+
+func TestUpdateGRPCHealth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	contr := &readyController{}
+	status := health.NewServer()
+	go updateGRPCHealth(ctx, status, contr, 10*time.Millisecond)
+	check := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		response, err := status.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return healthpb.HealthCheckResponse_UNKNOWN
+		}
+		return response.Status
+	}
+
+	require.Eventually(t, func() bool {
+		return check("") == healthpb.HealthCheckResponse_NOT_SERVING
+	}, time.Second, 5*time.Millisecond, "not serving before the sync")
+	atomic.StoreInt32(&contr.synced, 1)
+	require.Eventually(t, func() bool {
+		return check("") == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, 5*time.Millisecond, "serving once synced")
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, check(grpcHealthService))
+
+	// the controller is stopping
+	cancel()
+	require.Eventually(t, func() bool {
+		return check(grpcHealthService) == healthpb.HealthCheckResponse_NOT_SERVING
+	}, time.Second, 5*time.Millisecond, "not serving once stopped")
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check(""))
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check(grpcHealthService), "not updated anymore")
+}
+
+func TestControllerReady(t *testing.T) {
+	contr := &readyController{synced: 1}
+	assert.True(t, controllerReady(contr))
+	contr.ingressController = &controller.IngressControllerStatus{Ready: false}
+	assert.False(t, controllerReady(contr), "ingress controller down")
+	contr.ingressController.Ready = true
+	assert.True(t, controllerReady(contr))
+	contr.synced = 0
+	assert.False(t, controllerReady(contr))
+}
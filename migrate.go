@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+func runMigrateDomain(ctx context.Context, args []string) error {
+	f := newCommandFlags("migrate-domain", "--from domain --to domain [-n namespace | -A] [--provider-label key=value] [--generated-by value] [--dry-run]")
+	from := f.String("from", "", "the domain the hosts are moved from")
+	to := f.String("to", "", "the domain the hosts are moved to")
+	allNamespaces := f.Bool("A", false, "migrate the ingresses and services of all the namespaces")
+	providerLabel := f.String("provider-label", "", "the provider label of the controller, the legacy one if empty")
+	generatedBy := f.String("generated-by", "", "the generated-by annotation value of the controller, \"exposecontroller\" if empty")
+	dryRun := f.Bool("dry-run", false, "only print the hosts and URLs to migrate")
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+	if f.NArg() > 0 {
+		return errors.Errorf("unexpected arguments %v", f.Args())
+	}
+	if err := checkMigrationDomains(*from, *to); err != nil {
+		return err
+	}
+	provider, err := exposestrategy.ParseProviderLabel(*providerLabel)
+	if err != nil {
+		return err
+	}
+	provider.GeneratedBy = *generatedBy
+	client, namespace, err := f.client()
+	if err != nil {
+		return err
+	}
+	if *allNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+	changes, err := migrateDomain(ctx, client, namespace, *from, *to, provider, *dryRun)
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	return err
+}
+
+// checkMigrationDomains checks the domains of the migration
+func checkMigrationDomains(from, to string) error {
+	if from == "" || to == "" {
+		return errors.New("--from and --to are required")
+	}
+	for _, domain := range []string{from, to} {
+		if errs := validation.IsDNS1123Subdomain(domain); len(errs) > 0 {
+			return errors.Errorf("invalid domain \"%s\": %s", domain, strings.Join(errs, ", "))
+		}
+	}
+	if from == to {
+		return errors.Errorf("the domains to migrate from and to are both \"%s\"", from)
+	}
+	return nil
+}
+
+// migrateHost moves the host of the domain, or one of its subdomains, to the other domain
+// it tells if the host is in the domain
+func migrateHost(host, from, to string) (string, bool) {
+	if host == from {
+		return to, true
+	}
+	if strings.HasSuffix(host, "."+from) {
+		return strings.TrimSuffix(host, from) + to, true
+	}
+	return host, false
+}
+
+// migrateURL moves the host of the URL to the other domain, the URLs of other domains are kept as is
+func migrateURL(value, from, to string) (string, bool) {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Host == "" {
+		return value, false
+	}
+	host, ok := migrateHost(parsed.Hostname(), from, to)
+	if !ok {
+		return value, false
+	}
+	if port := parsed.Port(); port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+	parsed.Host = host
+	return parsed.String(), true
+}
+
+// migrateDomain rewrites the hosts of the ingresses generated by the controller and the URLs published in the services
+// from one domain to the other, the changes are returned as "kind namespace/name: old -> new", sorted by object
+// with dryRun, the changes are only returned
+func migrateDomain(ctx context.Context, client kubernetes.Interface, namespace, from, to string,
+	provider exposestrategy.ProviderLabel, dryRun bool) ([]string, error) {
+	var changes []string
+	ingresses, err := listGeneratedIngresses(ctx, client, namespace, provider)
+	if err != nil {
+		return nil, err
+	}
+	for _, ingress := range ingresses {
+		ingressChanges := migrateIngress(ingress, from, to)
+		if len(ingressChanges) == 0 {
+			continue
+		}
+		changes = append(changes, ingressChanges...)
+		if dryRun {
+			continue
+		}
+		_, err = client.NetworkingV1().Ingresses(ingress.Namespace).Update(ctx, ingress, metav1.UpdateOptions{})
+		if err != nil {
+			return changes, errors.Wrapf(err, "failed to update ingress %s/%s", ingress.Namespace, ingress.Name)
+		}
+	}
+	services, err := client.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return changes, errors.Wrap(err, "failed to list services")
+	}
+	sort.Slice(services.Items, func(i, j int) bool {
+		return services.Items[i].Namespace+"/"+services.Items[i].Name < services.Items[j].Namespace+"/"+services.Items[j].Name
+	})
+	for i := range services.Items {
+		svc := &services.Items[i]
+		annotations, serviceChanges, err := migrateServiceAnnotations(svc, from, to)
+		if err != nil {
+			return changes, err
+		}
+		if len(serviceChanges) == 0 {
+			continue
+		}
+		changes = append(changes, serviceChanges...)
+		if dryRun {
+			continue
+		}
+		err = patchServiceMetadata(ctx, client, svc.Namespace, svc.Name, nil, annotations)
+		if err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+// listGeneratedIngresses lists the ingresses generated and still managed by the controller, sorted by namespace and name
+func listGeneratedIngresses(ctx context.Context, client kubernetes.Interface, namespace string, provider exposestrategy.ProviderLabel) ([]*networkingv1.Ingress, error) {
+	seen := map[string]bool{}
+	var ingresses []*networkingv1.Ingress
+	for _, selector := range provider.Selectors() {
+		list, err := client.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list ingresses")
+		}
+		for i := range list.Items {
+			ingress := &list.Items[i]
+			key := ingress.Namespace + "/" + ingress.Name
+			if seen[key] || !provider.IsGenerated(ingress.Annotations) ||
+				ingress.Annotations[exposestrategy.UnmanagedAnnotationKey] == "true" {
+				continue
+			}
+			seen[key] = true
+			ingresses = append(ingresses, ingress)
+		}
+	}
+	sort.Slice(ingresses, func(i, j int) bool {
+		return ingresses[i].Namespace+"/"+ingresses[i].Name < ingresses[j].Namespace+"/"+ingresses[j].Name
+	})
+	return ingresses, nil
+}
+
+// migrateIngress moves the hosts of the rules and of the TLS entries of the ingress, and returns the changes
+func migrateIngress(ingress *networkingv1.Ingress, from, to string) []string {
+	var changes []string
+	migrate := func(host string) string {
+		migrated, ok := migrateHost(host, from, to)
+		if ok {
+			changes = append(changes, fmt.Sprintf("ingress %s/%s: %s -> %s", ingress.Namespace, ingress.Name, host, migrated))
+		}
+		return migrated
+	}
+	for i := range ingress.Spec.Rules {
+		ingress.Spec.Rules[i].Host = migrate(ingress.Spec.Rules[i].Host)
+	}
+	for i := range ingress.Spec.TLS {
+		for j := range ingress.Spec.TLS[i].Hosts {
+			ingress.Spec.TLS[i].Hosts[j] = migrate(ingress.Spec.TLS[i].Hosts[j])
+		}
+	}
+	return changes
+}
+
+// migrateServiceAnnotations returns the annotations of the service publishing its URLs and host moved to the other domain,
+// and the changes
+func migrateServiceAnnotations(svc *v1.Service, from, to string) (map[string]interface{}, []string, error) {
+	annotations := map[string]interface{}{}
+	var changes []string
+	change := func(old, migrated string) {
+		changes = append(changes, fmt.Sprintf("service %s/%s: %s -> %s", svc.Namespace, svc.Name, old, migrated))
+	}
+	if value := svc.Annotations[exposestrategy.ExposeAnnotationKey]; value != "" {
+		if migrated, ok := migrateURL(value, from, to); ok {
+			annotations[exposestrategy.ExposeAnnotationKey] = migrated
+			change(value, migrated)
+		}
+	}
+	if value := svc.Annotations[exposestrategy.ExposeHTTPURLAnnotationKey]; value != "" {
+		if migrated, ok := migrateURL(value, from, to); ok {
+			annotations[exposestrategy.ExposeHTTPURLAnnotationKey] = migrated
+			change(value, migrated)
+		}
+	}
+	if value := svc.Annotations[exposestrategy.ExposeURLsAnnotationKey]; value != "" {
+		urls := map[string]string{}
+		if err := json.Unmarshal([]byte(value), &urls); err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid annotation \"%s\" in service %s/%s",
+				exposestrategy.ExposeURLsAnnotationKey, svc.Namespace, svc.Name)
+		}
+		migratedURLs := false
+		for port, old := range urls {
+			if migrated, ok := migrateURL(old, from, to); ok {
+				urls[port] = migrated
+				migratedURLs = true
+			}
+		}
+		if migratedURLs {
+			// the keys of the maps are sorted by the encoding
+			encoded, err := json.Marshal(urls)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to encode the URLs of service %s/%s", svc.Namespace, svc.Name)
+			}
+			annotations[exposestrategy.ExposeURLsAnnotationKey] = string(encoded)
+			change(value, string(encoded))
+		}
+	}
+	if key := svc.Annotations[exposestrategy.ExposeHostNameAsAnnotationKey]; key != "" && svc.Annotations[key] != "" {
+		if migrated, ok := migrateHost(svc.Annotations[key], from, to); ok {
+			annotations[key] = migrated
+			change(svc.Annotations[key], migrated)
+		}
+	}
+	return annotations, changes, nil
+}
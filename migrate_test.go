@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateDomain(t *testing.T) {
+	newIngress := func(name string, generated bool, hosts ...string) *networkingv1.Ingress {
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "dev",
+				Name:      name,
+				Labels:    map[string]string{"provider": "fabric8"},
+			},
+			Spec: networkingv1.IngressSpec{
+				TLS: []networkingv1.IngressTLS{{Hosts: []string{"*.old.com"}, SecretName: "tls"}},
+			},
+		}
+		if generated {
+			ingress.Annotations = map[string]string{"fabric8.io/generated-by": "exposecontroller"}
+		}
+		for _, host := range hosts {
+			ingress.Spec.Rules = append(ingress.Spec.Rules, networkingv1.IngressRule{Host: host})
+		}
+		return ingress
+	}
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "dev",
+			Name:      "myapp",
+			Annotations: map[string]string{
+				exposestrategy.ExposeAnnotationKey:           "https://myapp.dev.old.com:8443/path",
+				exposestrategy.ExposeURLsAnnotationKey:       `{"8080":"https://myapp.dev.old.com:8443/path","9090":"https://myapp.dev.old.com:8443/metrics"}`,
+				exposestrategy.ExposeHostNameAsAnnotationKey: "osiris.deislabs.io/ingressHostname",
+				"osiris.deislabs.io/ingressHostname":         "myapp.dev.old.com",
+			},
+		},
+	}
+	other := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "dev",
+			Name:      "other",
+			Annotations: map[string]string{
+				exposestrategy.ExposeAnnotationKey: "https://other.dev.old.com.example.org",
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(
+		newIngress("myapp", true, "myapp.dev.old.com", "myapp.other.com"),
+		newIngress("chart", false, "chart.dev.old.com"),
+		service, other,
+	)
+	ctx := context.Background()
+	expected := []string{
+		"ingress dev/myapp: myapp.dev.old.com -> myapp.dev.new.com",
+		"ingress dev/myapp: *.old.com -> *.new.com",
+		"service dev/myapp: https://myapp.dev.old.com:8443/path -> https://myapp.dev.new.com:8443/path",
+		`service dev/myapp: {"8080":"https://myapp.dev.old.com:8443/path","9090":"https://myapp.dev.old.com:8443/metrics"} -> ` +
+			`{"8080":"https://myapp.dev.new.com:8443/path","9090":"https://myapp.dev.new.com:8443/metrics"}`,
+		"service dev/myapp: myapp.dev.old.com -> myapp.dev.new.com",
+	}
+
+	changes, err := migrateDomain(ctx, client, "dev", "old.com", "new.com", exposestrategy.LegacyProviderLabel, true)
+	require.NoError(t, err)
+	assert.Equal(t, expected, changes)
+	ingress, err := client.NetworkingV1().Ingresses("dev").Get(ctx, "myapp", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "myapp.dev.old.com", ingress.Spec.Rules[0].Host, "dry run")
+
+	changes, err = migrateDomain(ctx, client, "dev", "old.com", "new.com", exposestrategy.LegacyProviderLabel, false)
+	require.NoError(t, err)
+	assert.Equal(t, expected, changes)
+	ingress, err = client.NetworkingV1().Ingresses("dev").Get(ctx, "myapp", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "myapp.dev.new.com", ingress.Spec.Rules[0].Host)
+	assert.Equal(t, "myapp.other.com", ingress.Spec.Rules[1].Host)
+	assert.Equal(t, []string{"*.new.com"}, ingress.Spec.TLS[0].Hosts)
+	chart, err := client.NetworkingV1().Ingresses("dev").Get(ctx, "chart", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "chart.dev.old.com", chart.Spec.Rules[0].Host, "the ingresses not generated by the controller are kept")
+	svc, err := client.CoreV1().Services("dev").Get(ctx, "myapp", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://myapp.dev.new.com:8443/path", svc.Annotations[exposestrategy.ExposeAnnotationKey])
+	assert.Equal(t, "myapp.dev.new.com", svc.Annotations["osiris.deislabs.io/ingressHostname"])
+	svc, err = client.CoreV1().Services("dev").Get(ctx, "other", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://other.dev.old.com.example.org", svc.Annotations[exposestrategy.ExposeAnnotationKey])
+
+	changes, err = migrateDomain(ctx, client, "dev", "old.com", "new.com", exposestrategy.LegacyProviderLabel, false)
+	require.NoError(t, err)
+	assert.Empty(t, changes, "nothing left to migrate")
+}
+
+func TestCheckMigrationDomains(t *testing.T) {
+	assert.NoError(t, checkMigrationDomains("old.com", "new.com"))
+	assert.EqualError(t, checkMigrationDomains("", "new.com"), "--from and --to are required")
+	assert.EqualError(t, checkMigrationDomains("old.com", "old.com"), `the domains to migrate from and to are both "old.com"`)
+	assert.Error(t, checkMigrationDomains("old_com", "new.com"))
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+	"github.com/pkg/errors"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+func runPrune(ctx context.Context, args []string) error {
+	f := newCommandFlags("prune", "[-n namespace | -A] [--selector selector] [--older-than duration] [--provider-label key=value] [--generated-by value] [--never-delete] [--delete-propagation policy] [--expose-values values] [--strict-expose-value] [--dry-run]")
+	allNamespaces := f.Bool("A", false, "prune the services of all the namespaces")
+	selector := f.String("selector", "", "the label selector of the services to prune")
+	olderThan := f.Duration("older-than", 0, "prune the services created for longer, whatever their age if 0")
+	providerLabel := f.String("provider-label", "", "the provider label of the controller, the legacy one if empty")
+	generatedBy := f.String("generated-by", "", "the generated-by annotation value of the controller, \"exposecontroller\" if empty")
+	neverDelete := f.Bool("never-delete", false, "release the generated ingresses and HTTP routes instead of deleting them")
+	deletePropagation := f.String("delete-propagation", "", "the propagation policy of the deletions, foreground, background or orphan, the default one of the API server if empty")
+	exposeValues := f.String("expose-values", "", "the comma separated expose values of the controller, \"true\" if empty")
+	strictExposeValue := f.Bool("strict-expose-value", false, "only accept the \"true\" expose value as is, like the controller")
+	dryRun := f.Bool("dry-run", false, "only print the services to prune")
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+	if f.NArg() > 0 {
+		return errors.Errorf("unexpected arguments %v", f.Args())
+	}
+	// pruning all the exposed services is what the cleanup mode is for
+	if *selector == "" && *olderThan == 0 {
+		return errors.New("--selector or --older-than is required")
+	}
+	provider, err := exposestrategy.ParseProviderLabel(*providerLabel)
+	if err != nil {
+		return err
+	}
+	provider.GeneratedBy = *generatedBy
+	propagation, err := exposestrategy.ParseDeletePropagation(*deletePropagation)
+	if err != nil {
+		return err
+	}
+	var values []string
+	if *exposeValues != "" {
+		values = strings.Split(*exposeValues, ",")
+	}
+	accepted, err := exposestrategy.NewExposeValues(values, *strictExposeValue)
+	if err != nil {
+		return err
+	}
+	restConfig, namespace, err := f.restConfig()
+	if err != nil {
+		return err
+	}
+	if *allNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to create client")
+	}
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to create dynamic client")
+	}
+	pruned, err := pruneServices(ctx, client, dynamicClient, namespace, *selector, *olderThan, provider, accepted, *neverDelete, propagation, *dryRun, time.Now())
+	for _, key := range pruned {
+		fmt.Println(key)
+	}
+	return err
+}
+
+// pruneServices unexposes the services exposed with the expose values matching the selector and created before olderThan,
+// their annotations are stripped first so that a running controller does not generate their objects again
+// the keys of the pruned services are returned, sorted
+func pruneServices(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, namespace, selector string, olderThan time.Duration,
+	provider exposestrategy.ProviderLabel, exposeValues *exposestrategy.ExposeValues, neverDelete bool, propagation *metav1.DeletionPropagation, dryRun bool, now time.Time) ([]string, error) {
+	list, err := client.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list services")
+	}
+	var pruned []string
+	services := map[string]bool{}
+	for i := range list.Items {
+		svc := &list.Items[i]
+		if !isExposedService(svc, exposeValues) || now.Sub(svc.CreationTimestamp.Time) < olderThan {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s", svc.Namespace, svc.Name)
+		if !dryRun {
+			err = stripExposeAnnotations(ctx, client, svc)
+			if err != nil {
+				return pruned, err
+			}
+		}
+		services[key] = true
+		pruned = append(pruned, key)
+	}
+	sort.Strings(pruned)
+	if dryRun || len(services) == 0 {
+		return pruned, nil
+	}
+	return pruned, exposestrategy.PruneIngressStrategy(ctx, client, dynamicClient, namespace, provider, services, neverDelete, propagation)
+}
+
+// isExposedService tells if the service is exposed with the expose values, "true" if nil, or still has the URL published by the controller
+func isExposedService(svc *v1.Service, exposeValues *exposestrategy.ExposeValues) bool {
+	_, published := svc.Annotations[exposestrategy.ExposeAnnotationKey]
+	return published || exposeValues.IsRequested(svc)
+}
+
+// stripExposeAnnotations removes the label and the annotations exposing the service, along with those written by the controller
+func stripExposeAnnotations(ctx context.Context, client kubernetes.Interface, svc *v1.Service) error {
+	labels := map[string]interface{}{
+		exposestrategy.ExposeLabel.Key: nil,
+	}
+	annotations := map[string]interface{}{
+		exposestrategy.ExposeAnnotation.Key:       nil,
+		exposestrategy.InjectAnnotation.Key:       nil,
+		exposestrategy.ExposePortAnnotationKey:    nil,
+		exposestrategy.ExposeAnnotationKey:        nil,
+		exposestrategy.ExposeURLsAnnotationKey:    nil,
+		exposestrategy.ExposeHTTPURLAnnotationKey: nil,
+	}
+	if key := svc.Annotations[exposestrategy.ExposeHostNameAsAnnotationKey]; key != "" {
+		annotations[key] = nil
+	}
+	return patchServiceMetadata(ctx, client, svc.Namespace, svc.Name, labels, annotations)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneServices(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	newService := func(name string, age time.Duration, labels map[string]string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "previews",
+				Name:              name,
+				Labels:            labels,
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+				Annotations: map[string]string{
+					exposestrategy.ExposeAnnotation.Key: "true",
+					exposestrategy.ExposeAnnotationKey:  "https://" + name + ".previews.my-domain.com",
+				},
+			},
+		}
+	}
+	newIngress := func(name string) *networkingv1.Ingress {
+		return &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "previews",
+				Name:        name,
+				Labels:      map[string]string{"provider": "fabric8"},
+				Annotations: map[string]string{"fabric8.io/generated-by": "exposecontroller"},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "v1",
+					Kind:       "Service",
+					Name:       name,
+				}},
+			},
+		}
+	}
+	preview := map[string]string{"preview": "true"}
+	legacy := newService("legacy", 200*time.Hour, preview)
+	legacy.Annotations = map[string]string{exposestrategy.ExposeAnnotation.Key: "ingress"}
+	client := fake.NewSimpleClientset(
+		newService("old", 200*time.Hour, preview), newIngress("old"), legacy,
+		newService("recent", time.Hour, preview), newIngress("recent"),
+		newService("main", 200*time.Hour, nil), newIngress("main"),
+	)
+	ctx := context.Background()
+
+	pruned, err := pruneServices(ctx, client, nil, "previews", "preview=true", 168*time.Hour,
+		exposestrategy.LegacyProviderLabel, nil, false, nil, true, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"previews/old"}, pruned)
+	_, err = client.NetworkingV1().Ingresses("previews").Get(ctx, "old", metav1.GetOptions{})
+	assert.NoError(t, err, "dry run")
+
+	// the services exposed with the expose values of the controller
+	exposeValues, err := exposestrategy.NewExposeValues([]string{"true", "ingress"}, false)
+	require.NoError(t, err)
+	pruned, err = pruneServices(ctx, client, nil, "previews", "preview=true", 168*time.Hour,
+		exposestrategy.LegacyProviderLabel, exposeValues, false, nil, true, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"previews/legacy", "previews/old"}, pruned)
+
+	pruned, err = pruneServices(ctx, client, nil, "previews", "preview=true", 168*time.Hour,
+		exposestrategy.LegacyProviderLabel, nil, false, nil, false, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"previews/old"}, pruned)
+	_, err = client.NetworkingV1().Ingresses("previews").Get(ctx, "old", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+	svc, err := client.CoreV1().Services("previews").Get(ctx, "old", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, svc.Annotations)
+	for _, name := range []string{"recent", "main"} {
+		_, err = client.NetworkingV1().Ingresses("previews").Get(ctx, name, metav1.GetOptions{})
+		assert.NoError(t, err, name)
+	}
+}
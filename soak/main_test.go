@@ -0,0 +1,65 @@
+//go:build envtest
+// +build envtest
+
+// Package soak runs the controller against the API server and etcd of envtest while churning the services,
+// and checks that no ingress nor service is left behind, go test -tags envtest ./soak -v,
+// with KUBEBUILDER_ASSETS pointing to the binaries installed by setup-envtest
+package soak
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// defaultAssets is where envtest looks for its binaries without KUBEBUILDER_ASSETS
+const defaultAssets = "/usr/local/kubebuilder/bin"
+
+// client is the client of the envtest API server
+var client kubernetes.Interface
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	assets := os.Getenv("KUBEBUILDER_ASSETS")
+	if assets == "" {
+		assets = defaultAssets
+	}
+	for _, binary := range []string{"etcd", "kube-apiserver"} {
+		if _, err := os.Stat(filepath.Join(assets, binary)); err != nil {
+			fmt.Fprintf(os.Stderr, "skipping the envtest soak tests, %s is not installed in %s\n", binary, assets)
+			os.Exit(0)
+		}
+	}
+	env := &envtest.Environment{BinaryAssetsDirectory: assets}
+	code := 1
+	err := setupClient(env)
+	if err == nil {
+		code = m.Run()
+	} else {
+		fmt.Fprintf(os.Stderr, "failed to start envtest: %s\n", err)
+	}
+	if err := env.Stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to stop envtest: %s\n", err)
+	}
+	os.Exit(code)
+}
+
+// setupClient starts the API server and etcd and creates the client
+func setupClient(env *envtest.Environment) error {
+	restConfig, err := env.Start()
+	if err != nil {
+		return errors.Wrap(err, "failed to start the API server")
+	}
+	client, err = kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the client")
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+//go:build envtest
+// +build envtest
+
+package soak
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/devopscare/exposecontroller/controller"
+	"github.com/devopscare/exposecontroller/exposestrategy"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	// soak churns the services for the given duration, go test -tags envtest ./soak -soak 2h -timeout 0
+	soak = flag.Duration("soak", time.Minute, "churn the services for the given duration")
+	// soakSeed replays the logged seed of a failed soak test
+	soakSeed = flag.Int64("soak-seed", 0, "seed of the random operations of the soak tests, the time if 0")
+)
+
+const (
+	// soakServices is the number of service names churned
+	soakServices = 20
+	// soakTimeout is the time given to the controller to delete the ingresses once the churn is over
+	soakTimeout = 2 * time.Minute
+)
+
+// TestDaemonSoak churns the services watched by the controller, then deletes them and waits for the controller
+// to delete their ingresses, envtest has no garbage collector and the ingresses left with owner references are leaks
+func TestDaemonSoak(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	namespace := createNamespace(ctx, t)
+	baseServices, baseIngresses := countObjects(ctx, t, namespace)
+	startController(ctx, t, namespace)
+
+	seed := *soakSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	t.Logf("seed %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+	services := client.CoreV1().Services(namespace)
+	exists := map[string]bool{}
+	operations, failures := 0, 0
+	for deadline := time.Now().Add(*soak); time.Now().Before(deadline); operations++ {
+		name := fmt.Sprintf("svc%d", rng.Intn(soakServices))
+		var err error
+		switch {
+		case !exists[name]:
+			_, err = services.Create(ctx, newService(name), metav1.CreateOptions{})
+			if err == nil {
+				exists[name] = true
+			}
+		case rng.Intn(4) == 0:
+			err = services.Delete(ctx, name, metav1.DeleteOptions{})
+			if err == nil {
+				delete(exists, name)
+			}
+		default:
+			// unexposes the service, exposes it again, or moves it to another path
+			var patch string
+			switch rng.Intn(3) {
+			case 0:
+				patch = fmt.Sprintf(`{"metadata":{"annotations":{"%s":"false"}}}`, exposestrategy.ExposeAnnotation.Key)
+			case 1:
+				patch = fmt.Sprintf(`{"metadata":{"annotations":{"%s":"%s"}}}`, exposestrategy.ExposeAnnotation.Key, exposestrategy.ExposeAnnotation.Value)
+			default:
+				patch = fmt.Sprintf(`{"metadata":{"annotations":{"fabric8.io/ingress.path":"/path%d"}}}`, rng.Intn(3))
+			}
+			_, err = services.Patch(ctx, name, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+		}
+		if err != nil {
+			failures++
+		}
+		time.Sleep(time.Duration(rng.Intn(50)) * time.Millisecond)
+	}
+	t.Logf("%d operations, %d failures", operations, failures)
+
+	for name := range exists {
+		require.NoError(t, services.Delete(ctx, name, metav1.DeleteOptions{}))
+	}
+	var countedServices, countedIngresses int
+	err := wait.PollImmediate(time.Second, soakTimeout, func() (bool, error) {
+		countedServices, countedIngresses = countObjects(ctx, t, namespace)
+		return countedServices == baseServices && countedIngresses == baseIngresses, nil
+	})
+	assert.NoError(t, err, "objects of the deleted services left behind")
+	assert.Equal(t, baseServices, countedServices, "services")
+	assert.Equal(t, baseIngresses, countedIngresses, "ingresses")
+}
+
+// createNamespace creates the namespace of the test, deleted at its end
+func createNamespace(ctx context.Context, t *testing.T) string {
+	ns, err := client.CoreV1().Namespaces().Create(ctx, &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "soak-"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.CoreV1().Namespaces().Delete(context.Background(), ns.Name, metav1.DeleteOptions{})
+	})
+	return ns.Name
+}
+
+// startController runs the controller in the test, watching its namespace
+func startController(ctx context.Context, t *testing.T, namespace string) {
+	config := controller.DefaultConfig
+	config.WatchCurrentNamespace = false
+	config.Exposer = "ingress"
+	config.Domain = "soak.example.com"
+	config.HTTP = true
+	require.NoError(t, config.Validate())
+	contr, err := controller.Daemon(ctx, client, nil, namespace, &config, time.Minute)
+	require.NoError(t, err)
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	go contr.Run(stop)
+}
+
+// newService returns an exposed service
+func newService(name string) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{exposestrategy.ExposeAnnotation.Key: exposestrategy.ExposeAnnotation.Value},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Name: "http", Port: 80}},
+		},
+	}
+}
+
+// countObjects returns the number of services and ingresses of the namespace
+func countObjects(ctx context.Context, t *testing.T, namespace string) (int, int) {
+	services, err := client.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	ingresses, err := client.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	return len(services.Items), len(ingresses.Items)
+}